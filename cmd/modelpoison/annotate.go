@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hallucinaut/modelpoison/pkg/allowlist"
+	"github.com/hallucinaut/modelpoison/pkg/audit"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func annotateResult(args []string) error {
+	fs := newFlagSet("annotate")
+	decisionsPath := fs.String("decisions", "", "review decisions (CSV id,decision[,note,reviewer] or allowlist JSON)")
+	allowPath := fs.String("allowlist", "allowlist.json", "allowlist to merge decisions into (empty to skip)")
+	out := fs.String("out", "", "annotated result file (default: overwrite the result)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usagef("result file required")
+	}
+	if *decisionsPath == "" {
+		return usagef("--decisions required")
+	}
+
+	result, err := detect.LoadResult(positional[0])
+	if err != nil {
+		return err
+	}
+	decisions, err := loadDecisions(*decisionsPath)
+	if err != nil {
+		return err
+	}
+
+	samples := make(map[string]int, len(result.Samples))
+	for i, sample := range result.Samples {
+		samples[sample.ID] = i
+	}
+
+	annotated := 0
+	for _, decision := range decisions {
+		i, ok := samples[decision.ID]
+		if !ok {
+			logger.Warnf("sample %s is not in %s", decision.ID, positional[0])
+			continue
+		}
+		if !result.Samples[i].IsPoisoned {
+			logger.Warnf("sample %s was not flagged", decision.ID)
+		}
+		result.Samples[i].Review = string(decision.Decision)
+		result.Samples[i].ReviewNote = decision.Note
+		annotated++
+	}
+
+	target := *out
+	if target == "" {
+		target = positional[0]
+	}
+	if err := detect.SaveResult(target, result); err != nil {
+		return err
+	}
+	logger.Infof("Annotated %d samples in %s", annotated, target)
+
+	if *allowPath != "" {
+		allow, err := allowlist.Load(*allowPath)
+		if err != nil {
+			return err
+		}
+		for _, decision := range decisions {
+			if i, ok := samples[decision.ID]; ok {
+				if decision.Type == "" {
+					decision.Type = string(result.Samples[i].Type)
+				}
+				// Incremental results hash their samples, so the decision
+				// also covers the sample's content under other IDs.
+				if decision.Hash == "" {
+					decision.Hash = result.Samples[i].Hash
+				}
+			}
+			allow.Set(decision)
+		}
+		if err := allow.Save(*allowPath); err != nil {
+			return err
+		}
+		logger.Infof("Merged %d decisions into %s", len(decisions), *allowPath)
+		if err := recordAudit(audit.ActionAllowlistEdit, "", *allowPath, audit.DecisionDetails(decisionMap(decisions))); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Decisions: %d\n", len(decisions))
+	fmt.Printf("Annotated: %d\n", annotated)
+	return nil
+}
+
+// loadDecisions reads review decisions from an allowlist JSON file or a CSV
+// file with id and decision columns and optional note and reviewer columns.
+func loadDecisions(path string) ([]allowlist.Entry, error) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		list, err := allowlist.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		if list.Len() == 0 {
+			return nil, fmt.Errorf("%s: no decisions", path)
+		}
+		return list.Entries(), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: read header: %w", path, err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	idCol, okID := columns["id"]
+	decisionCol, okDecision := columns["decision"]
+	if !okID || !okDecision {
+		return nil, fmt.Errorf("%s: header must contain id and decision columns", path)
+	}
+
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var decisions []allowlist.Entry
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if idCol >= len(record) || decisionCol >= len(record) {
+			return nil, fmt.Errorf("%s: row %d: missing id or decision", path, row)
+		}
+
+		decision, err := allowlist.ParseDecision(record[decisionCol])
+		if err != nil {
+			return nil, fmt.Errorf("%s: row %d: %w", path, row, err)
+		}
+		decisions = append(decisions, allowlist.Entry{
+			ID:       strings.TrimSpace(record[idCol]),
+			Decision: decision,
+			Note:     field(record, "note"),
+			Reviewer: field(record, "reviewer"),
+		})
+	}
+
+	return decisions, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/allowlist"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// copyFile copies the file src to dst.
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// reviewedAt matches the review times of an allowlist file.
+var reviewedAt = regexp.MustCompile(`"reviewed_at": "[^"]*"`)
+
+// fixtureReviewedAt is the review time of the decisions in the fixtures.
+const fixtureReviewedAt = `"reviewed_at": "2026-01-02T03:04:05Z"`
+
+func TestAnnotateResult(t *testing.T) {
+	t.Setenv("MODELPOISON_AUDIT_LOG", "")
+	dir := t.TempDir()
+	result, allow := filepath.Join(dir, "result.json"), filepath.Join(dir, "allowlist.json")
+	copyFile(t, filepath.Join("testdata", "annotate", "result.json"), result)
+	copyFile(t, filepath.Join("testdata", "annotate", "allowlist.json"), allow)
+	annotated := filepath.Join(dir, "annotated.json")
+
+	stdout, err := captureStdout(t, func() error {
+		return annotateResult([]string{result, "--decisions", filepath.Join("testdata", "annotate", "decisions.csv"), "--allowlist", allow, "--out", annotated})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// s9 is not in the result, so five of the six decisions annotate it.
+	if stdout != "Decisions: 6\nAnnotated: 5\n" {
+		t.Errorf("printed %q", stdout)
+	}
+
+	data, err := os.ReadFile(annotated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "annotate/annotated.json.golden", data)
+	data, err = os.ReadFile(allow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Decisions merged now are stamped with the time.
+	data = reviewedAt.ReplaceAllFunc(data, func(field []byte) []byte {
+		if string(field) == fixtureReviewedAt {
+			return field
+		}
+		return []byte(`"reviewed_at": "NOW"`)
+	})
+	checkGolden(t, "annotate/allowlist.json.golden", data)
+
+	// The later of two decisions on a sample wins in the result and the
+	// allowlist, and a decision replaces the one already allowlisted: s2's
+	// confirmation no longer allows its content.
+	r, err := detect.LoadResult(annotated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := r.Samples[2]; s.Review != string(allowlist.DecisionAccepted) || s.ReviewNote != "second look: trigger confirmed" {
+		t.Errorf("s3 reviewed %q: %q", s.Review, s.ReviewNote)
+	}
+	list, err := allowlist.Load(allow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []allowlist.Entry{
+		{ID: "s0", Decision: allowlist.DecisionFalsePositive, Note: "kept from an earlier scan"},
+		{ID: "s1", Decision: allowlist.DecisionFalsePositive, Type: "backdoor", Note: "benign watermark", Reviewer: "ana", Hash: "h1"},
+		{ID: "s2", Decision: allowlist.DecisionAccepted, Type: "label_flip", Reviewer: "ana", Hash: "h2"},
+		{ID: "s3", Decision: allowlist.DecisionAccepted, Type: "feature_poison", Note: "second look: trigger confirmed", Reviewer: "ben"},
+		{ID: "s9", Decision: allowlist.DecisionFalsePositive, Note: "not in this scan", Reviewer: "ben"},
+	} {
+		got, ok := list.Lookup(want.ID)
+		got.ReviewedAt = want.ReviewedAt
+		if !ok || got != want {
+			t.Errorf("allowlisted %+v, want %+v", got, want)
+		}
+	}
+	original, err := os.ReadFile(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixture, _ := os.ReadFile(filepath.Join("testdata", "annotate", "result.json")); string(original) != string(fixture) {
+		t.Error("--out overwrote the result")
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/allowlist"
+	"github.com/hallucinaut/modelpoison/pkg/audit"
+)
+
+// auditLogPath is bound to the --audit-log flag of every flag set.
+var auditLogPath = os.Getenv("MODELPOISON_AUDIT_LOG")
+
+var (
+	auditOnce sync.Once
+	auditLog  *audit.Log
+	auditErr  error
+)
+
+// addAuditFlags registers --audit-log on fs.
+func addAuditFlags(fs *flag.FlagSet) {
+	fs.StringVar(&auditLogPath, "audit-log", auditLogPath, "append scans, defenses and review changes to this audit log")
+}
+
+// openAuditLog opens the log named by --audit-log, or returns nil when it
+// is not set.
+func openAuditLog() (*audit.Log, error) {
+	auditOnce.Do(func() {
+		if auditLogPath != "" {
+			auditLog, auditErr = audit.Open(auditLogPath)
+		}
+	})
+	return auditLog, auditErr
+}
+
+// recordAudit appends a record of action on subject to the audit log, if
+// one is configured, attributed to actor or else the current user.
+func recordAudit(action audit.Action, actor, subject string, details map[string]string) error {
+	log, err := openAuditLog()
+	if err != nil || log == nil {
+		return err
+	}
+	if actor == "" {
+		actor = os.Getenv("USER")
+	}
+	if u, err := user.Current(); actor == "" && err == nil {
+		actor = u.Username
+	}
+	record, err := log.Append(audit.Record{Action: action, Actor: actor, Subject: subject, Details: details})
+	if err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+	logger.Debugf("recorded %s of %s in audit log (record %d)", action, subject, record.Seq)
+	return nil
+}
+
+// recordConfig records a config_change for the configuration file at path
+// when its content differs from the last change recorded for it, so edits
+// made outside the tool are logged the next time the file is used.
+func recordConfig(path string) error {
+	log, err := openAuditLog()
+	if err != nil || log == nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	hash := "sha256:" + hex.EncodeToString(sum[:])
+	subject := path
+	if abs, err := filepath.Abs(path); err == nil {
+		subject = abs
+	}
+
+	records, err := log.Records()
+	if err != nil {
+		return err
+	}
+	previous := ""
+	for _, r := range records {
+		if r.Action == audit.ActionConfigChange && r.Subject == subject {
+			previous = r.Details["sha256"]
+		}
+	}
+	if previous == hash {
+		return nil
+	}
+	details := map[string]string{"sha256": hash}
+	if previous != "" {
+		details["previous"] = previous
+	}
+	return recordAudit(audit.ActionConfigChange, "", subject, details)
+}
+
+// decisionMap returns the decision of each entry.
+func decisionMap(entries []allowlist.Entry) map[string]allowlist.Decision {
+	decisions := make(map[string]allowlist.Decision, len(entries))
+	for _, entry := range entries {
+		decisions[entry.ID] = entry.Decision
+	}
+	return decisions
+}
+
+func manageAudit(args []string) error {
+	if len(args) < 1 {
+		return usagef("audit requires a subcommand: verify or export")
+	}
+
+	switch args[0] {
+	case "verify":
+		return auditVerify(args[1:])
+	case "export":
+		return auditExport(args[1:])
+	}
+	return usagef("unknown audit subcommand: %s", args[0])
+}
+
+// auditRecords reads and verifies the log named by positional or
+// --audit-log.
+func auditRecords(positional []string) (string, []audit.Record, error) {
+	path := auditLogPath
+	switch {
+	case len(positional) == 1:
+		path = positional[0]
+	case len(positional) > 1:
+		return "", nil, usagef("unexpected argument %q", positional[1])
+	}
+	if path == "" {
+		return "", nil, usagef("audit log required (argument, --audit-log or MODELPOISON_AUDIT_LOG)")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+	records, err := audit.Read(f)
+	if err != nil {
+		return path, records, fmt.Errorf("%s: %w", path, err)
+	}
+	return path, records, nil
+}
+
+func auditVerify(args []string) error {
+	fs := newFlagSet("audit verify")
+	anchor := fs.String("anchor", "", "also require the chain to contain the record with this hash, as noted from an earlier verify")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	path, records, err := auditRecords(positional)
+	if err != nil {
+		if len(records) > 0 {
+			fmt.Printf("Verified: %d records before the break\n", len(records))
+		}
+		return err
+	}
+
+	head := audit.Genesis
+	if len(records) > 0 {
+		head = records[len(records)-1].Hash
+	}
+	if *anchor != "" {
+		found := false
+		for _, r := range records {
+			if r.Hash == *anchor {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: no record has anchor hash %s; the log was truncated or replaced", path, *anchor)
+		}
+	}
+
+	fmt.Printf("Log: %s\n", path)
+	fmt.Printf("Records: %d\n", len(records))
+	if len(records) > 0 {
+		fmt.Printf("First: %s\n", records[0].Time.Format(time.RFC3339))
+		fmt.Printf("Last: %s\n", records[len(records)-1].Time.Format(time.RFC3339))
+	}
+	fmt.Printf("Head: %s\n", head)
+	fmt.Println("Chain: intact")
+	return nil
+}
+
+func auditExport(args []string) error {
+	fs := newFlagSet("audit export")
+	since := fs.String("since", "", "only export records newer than a duration (e.g. 720h) or date (YYYY-MM-DD)")
+	action := fs.String("action", "", "only export records of these comma-separated actions")
+	format := fs.String("format", "jsonl", "output format: jsonl or csv")
+	out := fs.String("out", "", "file to write (default stdout)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *format != "jsonl" && *format != "csv" {
+		return usagef("invalid format %q (want jsonl or csv)", *format)
+	}
+	var from time.Time
+	if *since != "" {
+		if from, err = parseSince(*since, time.Now()); err != nil {
+			return usagef("--since: %v", err)
+		}
+	}
+	actions := make(map[audit.Action]bool)
+	for _, name := range strings.Split(*action, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		known := false
+		for _, a := range audit.Actions {
+			known = known || string(a) == name
+		}
+		if !known {
+			return usagef("unknown action %q", name)
+		}
+		actions[audit.Action(name)] = true
+	}
+
+	// Only a verified chain is exported, so auditors never receive records
+	// that were tampered with.
+	_, records, err := auditRecords(positional)
+	if err != nil {
+		return err
+	}
+	var selected []audit.Record
+	for _, r := range records {
+		if r.Time.Before(from) || (len(actions) > 0 && !actions[r.Action]) {
+			continue
+		}
+		selected = append(selected, r)
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if *format == "csv" {
+		err = writeAuditCSV(w, selected)
+	} else {
+		encoder := json.NewEncoder(w)
+		for _, r := range selected {
+			if err = encoder.Encode(r); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if *out != "" {
+		logger.Infof("Exported %d of %d audit records to %s", len(selected), len(records), *out)
+	}
+	return nil
+}
+
+// writeAuditCSV writes records as CSV, with their details as a JSON object.
+func writeAuditCSV(w io.Writer, records []audit.Record) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"seq", "time", "action", "actor", "project", "subject", "details", "prev", "hash"})
+	for _, r := range records {
+		details := ""
+		if len(r.Details) > 0 {
+			data, err := json.Marshal(r.Details)
+			if err != nil {
+				return err
+			}
+			details = string(data)
+		}
+		cw.Write([]string{strconv.FormatInt(r.Seq, 10), r.Time.Format(time.RFC3339Nano), string(r.Action),
+			r.Actor, r.Project, r.Subject, details, r.Prev, r.Hash})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/hallucinaut/modelpoison/pkg/benchmark"
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+)
+
+func runBenchmark(args []string) error {
+	fs := newFlagSet("benchmark")
+	columns := addColumnFlags(fs)
+	samples := fs.Int("samples", 10000, "samples per synthetic dataset")
+	features := fs.Int("synthetic-features", 20, "features per synthetic sample")
+	rate := fs.Float64("rate", 0.05, "poisoning rate of synthetic datasets")
+	seed := fs.Int64("seed", 1, "random seed for synthetic datasets")
+	noSynthetic := fs.Bool("no-synthetic", false, "only benchmark the given datasets")
+	truthPath := addTruthFlag(fs)
+	configPath := fs.String("config", "", "detector configuration file")
+	format := fs.String("format", "text", "output format: text or json")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return usagef("invalid format %q (want text or json)", *format)
+	}
+
+	var cases []benchmark.Case
+	if !*noSynthetic {
+		logger.Infof("Generating synthetic datasets (%d samples, %d features)", *samples, *features)
+		cases, err = benchmark.SyntheticCases(*samples, *features, *rate, *seed)
+		if err != nil {
+			return err
+		}
+	}
+
+	paths, err := expandDatasets(positional)
+	if err != nil {
+		return err
+	}
+	groundTruthFile, err := loadTruth(*truthPath)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		data, err := dataset.LoadCSVContext(commandContext, path, columns.columns())
+		if err != nil {
+			return err
+		}
+		poisoned, _, err := groundTruth(path, data, groundTruthFile)
+		if err != nil {
+			return err
+		}
+		cases = append(cases, benchmark.Case{Name: path, Samples: data.Samples, Poisoned: poisoned})
+	}
+	if len(cases) == 0 {
+		return usagef("no datasets to benchmark")
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	detector, err := newDetector(cfg)
+	if err != nil {
+		return err
+	}
+
+	defender, err := newDefender()
+	if err != nil {
+		return err
+	}
+	var strategies []string
+	for _, strategy := range defender.Strategies() {
+		if strategy.FiltersSamples() {
+			strategies = append(strategies, strategy.Name)
+		}
+	}
+
+	logger.Infof("Benchmarking %d datasets", len(cases))
+	rows, err := benchmark.Run(cases, detector, defender, strategies)
+	if err == nil {
+		err = checkPlugins()
+	}
+	if err != nil {
+		return err
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	}
+
+	fmt.Print(benchmark.GenerateReport(rows))
+	return nil
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/hallucinaut/modelpoison/pkg/audit"
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/defend"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/quarantine"
+	"github.com/hallucinaut/modelpoison/pkg/store"
+)
+
+// removal records why a row was removed from a dataset.
+type removal struct {
+	index   int
+	reason  string
+	finding detect.PoisonedSample
+}
+
+func cleanDataset(args []string) error {
+	fs := newFlagSet("clean")
+	columns := addColumnFlags(fs)
+	encoding := addEncodeFlags(fs)
+	scaling := addScaleFlags(fs)
+	out := fs.String("out", "", "sanitized dataset to write")
+	removedPath := fs.String("removed", "", "CSV file listing removed rows")
+	quarantineDir := fs.String("quarantine", "", "quarantine removed rows in this directory")
+	strategy := fs.String("strategy", "Data Cleaning", "cleaning defense to apply, or \"none\" for detector findings only")
+	configPath := fs.String("config", "", "detector configuration file")
+	lists := addListFlags(fs)
+	custody := addCustodyFlags(fs)
+	noProgress := fs.Bool("no-progress", false, "disable progress reporting")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usagef("dataset required")
+	}
+	if *out == "" {
+		return usagef("--out required")
+	}
+
+	defender, err := newDefender()
+	if err != nil {
+		return err
+	}
+	if *strategy != "none" {
+		strat, ok := defender.Strategy(*strategy)
+		if !ok {
+			return usagef("unknown strategy %q", *strategy)
+		}
+		if !strat.FiltersSamples() {
+			return fmt.Errorf("strategy %q does not remove samples from a dataset", *strategy)
+		}
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
+	opts := scanOptions{noProgress: *noProgress, config: cfg, columns: columns.columns()}
+	if err := encoding.apply(&opts, cfg); err != nil {
+		return err
+	}
+	if err := scaling.apply(&opts, cfg); err != nil {
+		return err
+	}
+	if err := lists.apply(&opts); err != nil {
+		return err
+	}
+
+	cols, err := encodeColumns(positional[0], opts)
+	if err != nil {
+		return err
+	}
+	data, err := dataset.LoadCSVContext(commandContext, positional[0], cols)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Cleaning %s (%d samples)", positional[0], len(data.Samples))
+	// The detector and the defense see the encoded and scaled features;
+	// the cleaned dataset keeps the original records.
+	if data, err = scaleDataset(positional[0], data, opts); err != nil {
+		return err
+	}
+
+	result, err := runDetector(data, opts)
+	if err != nil {
+		return err
+	}
+
+	removals := make(map[int]removal)
+	for i, finding := range result.Samples {
+		switch {
+		case finding.List == detect.ListDenied:
+			removals[i] = removal{index: i, reason: "denylist", finding: finding}
+		case finding.IsPoisoned:
+			removals[i] = removal{index: i, reason: "detector", finding: finding}
+		}
+	}
+	if *strategy != "none" {
+		// Allowlisted samples were reviewed as benign, so the defense
+		// keeps them too.
+		for _, i := range defenseRemovals(defender, data, *strategy) {
+			if _, ok := removals[i]; !ok && result.Samples[i].List != detect.ListAllowed {
+				removals[i] = removal{index: i, reason: *strategy, finding: result.Samples[i]}
+			}
+		}
+	}
+	if err := checkPlugins(); err != nil {
+		return err
+	}
+
+	var kept, removed []int
+	for i := range data.Samples {
+		if _, ok := removals[i]; ok {
+			removed = append(removed, i)
+		} else {
+			kept = append(kept, i)
+		}
+	}
+
+	if err := data.Subset(kept).SaveCSV(*out); err != nil {
+		return err
+	}
+	logger.Infof("Wrote %d samples to %s", len(kept), *out)
+
+	if *removedPath != "" {
+		if err := writeRemovals(*removedPath, data, removed, removals); err != nil {
+			return err
+		}
+		logger.Infof("Wrote %d removed rows to %s", len(removed), *removedPath)
+	}
+
+	if *quarantineDir != "" {
+		added, err := quarantineRemovals(*quarantineDir, positional[0], *out, data, removed, removals)
+		if err != nil {
+			return err
+		}
+		logger.Infof("Quarantined %d rows in %s", added, *quarantineDir)
+	}
+
+	removedIDs := make([]string, len(removed))
+	for i, index := range removed {
+		removedIDs[i] = data.Samples[index].ID
+	}
+	details := map[string]string{
+		"strategy": *strategy,
+		"out":      *out,
+		"samples":  strconv.Itoa(len(data.Samples)),
+		"removed":  strconv.Itoa(len(removed)),
+	}
+	if len(removedIDs) > 0 {
+		details["removed_ids"] = audit.IDs(removedIDs)
+	}
+	if err := recordAudit(audit.ActionDefense, "", positional[0], details); err != nil {
+		return err
+	}
+	var events []store.Event
+	for _, i := range removed {
+		id, reason := data.Samples[i].ID, removals[i].reason
+		events = append(events, store.Event{SampleID: id, Kind: store.EventRemoved, DatasetID: positional[0], Details: "cleaned by " + reason})
+		if *quarantineDir != "" {
+			events = append(events, store.Event{SampleID: id, Kind: store.EventQuarantined, DatasetID: positional[0], Details: reason + " in " + *quarantineDir})
+		}
+	}
+	if err := custody.record(events); err != nil {
+		return err
+	}
+
+	fmt.Printf("Samples: %d\n", len(data.Samples))
+	fmt.Printf("Kept: %d\n", len(kept))
+	fmt.Printf("Removed: %d\n", len(removed))
+
+	return nil
+}
+
+// defenseRemovals applies a filtering defense and returns the indices of
+// the samples it removed or marked as suspicious.
+func defenseRemovals(defender *defend.Defender, data *dataset.Dataset, strategy string) []int {
+	return defender.RemovedIndicesContext(commandContext, data.Samples, strategy)
+}
+
+// quarantineRemovals stores removed rows in the quarantine so they can be
+// restored into the cleaned dataset after review.
+func quarantineRemovals(dir, source, destination string, data *dataset.Dataset, removed []int, removals map[int]removal) (int, error) {
+	store, err := quarantine.Open(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	entries := make([]quarantine.Entry, 0, len(removed))
+	for _, i := range removed {
+		r := removals[i]
+		entries = append(entries, quarantine.Entry{
+			SampleID:    data.Samples[i].ID,
+			Dataset:     source,
+			Destination: destination,
+			Header:      data.Header,
+			Record:      data.Records[i],
+			Reason:      r.reason,
+			Type:        string(r.finding.Type),
+			Score:       r.finding.Score,
+			Description: r.finding.Description,
+		})
+	}
+
+	added := store.Add(entries...)
+	return added, store.Save()
+}
+
+// writeRemovals writes the removed rows with the reason for each removal.
+func writeRemovals(path string, data *dataset.Dataset, removed []int, removals map[int]removal) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(f)
+	header := append(append([]string(nil), data.Header...), "removed_by", "poison_type", "score", "description")
+	writer.Write(header)
+
+	for _, i := range removed {
+		r := removals[i]
+		score := ""
+		if r.finding.IsPoisoned {
+			score = fmt.Sprintf("%.4f", r.finding.Score)
+		}
+		record := append(append([]string(nil), data.Records[i]...),
+			r.reason, string(r.finding.Type), score, r.finding.Description)
+		writer.Write(record)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
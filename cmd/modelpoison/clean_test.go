@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCleanDataset(t *testing.T) {
+	t.Setenv("MODELPOISON_AUDIT_LOG", "")
+	dir := t.TempDir()
+	out, removed := filepath.Join(dir, "clean.csv"), filepath.Join(dir, "removed.csv")
+	in := filepath.Join("testdata", "clean", "in.csv")
+	stdout, err := captureStdout(t, func() error {
+		return cleanDataset([]string{in, "--ignore-cols", "note", "--no-progress",
+			"--denylist", filepath.Join("testdata", "clean", "deny.txt"), "--out", out, "--removed", removed})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != "Samples: 25\nKept: 24\nRemoved: 1\n" {
+		t.Errorf("printed %q", stdout)
+	}
+
+	cleaned, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "clean/clean.csv.golden", cleaned)
+	list, err := os.ReadFile(removed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "clean/removed.csv.golden", list)
+
+	// The kept and removed rows are the input's, with its header, quoting
+	// and number formatting; only the denylisted s07 is removed.
+	input, err := os.ReadFile(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := strings.SplitAfter(string(input), "\n")
+	if want := strings.Join(append(rows[:8:8], rows[9:]...), ""); string(cleaned) != want {
+		t.Errorf("cleaned dataset is not the input without s07:\n%s", cleaned)
+	}
+	if want := strings.TrimSuffix(rows[8], "\n") + ",denylist,"; !strings.HasPrefix(strings.Split(string(list), "\n")[1], want) {
+		t.Errorf("removed rows do not start with %q:\n%s", want, list)
+	}
+}
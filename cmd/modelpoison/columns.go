@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+)
+
+// columnFlags holds the column selection flags shared by commands that load
+// datasets.
+type columnFlags struct {
+	features string
+	label    string
+	id       string
+	source   string
+	ignore   string
+	// featuresField is the field of JSON samples holding their feature
+	// vector.
+	featuresField string
+}
+
+// addColumnFlags registers the column selection flags on fs.
+func addColumnFlags(fs *flag.FlagSet) *columnFlags {
+	c := &columnFlags{}
+	fs.StringVar(&c.features, "features", "", "comma-separated feature columns (default: all other columns)")
+	fs.StringVar(&c.label, "label-col", "", "label column name")
+	fs.StringVar(&c.label, "label-field", "", "label field of JSON samples (same as --label-col)")
+	fs.StringVar(&c.featuresField, "features-field", "", "field of JSON samples holding their feature vector (default features, if present)")
+	fs.StringVar(&c.id, "id-col", "", "sample ID column name")
+	fs.StringVar(&c.source, "source-col", "", "column naming the source or contributor of each sample, for denylists")
+	fs.StringVar(&c.ignore, "ignore-cols", "", "comma-separated columns to ignore")
+	return c
+}
+
+// columns returns the selected dataset columns.
+func (c *columnFlags) columns() dataset.Columns {
+	return dataset.Columns{
+		Features: splitList(c.features),
+		Label:    c.label,
+		ID:       c.id,
+		Source:   c.source,
+		Ignore:   splitList(c.ignore),
+
+		FeaturesField: c.featuresField,
+	}
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/drift"
+)
+
+func compareDatasets(args []string) error {
+	fs := newFlagSet("compare")
+	columns := addColumnFlags(fs)
+	format := fs.String("format", "text", "output format: text or json")
+	top := fs.Int("top", 10, "number of drifted features to list (0 for all)")
+	configPath := fs.String("config", "", "detector configuration file")
+	forensics := fs.Bool("forensics", false, "isolate the changed samples that raised the risk of the new version and what they share")
+	timeCol := fs.String("time-col", "", "column holding the time each sample was created, for --forensics")
+	tolerance := fs.Float64("tolerance", drift.DefaultTolerance, "risk above the old version's that --forensics accepts as clean")
+	provenance := addProvenanceFlag(fs)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return usagef("compare requires two datasets")
+	}
+	if *format != "text" && *format != "json" {
+		return usagef("invalid format %q (want text or json)", *format)
+	}
+	if *timeCol != "" && !*forensics {
+		return usagef("--time-col requires --forensics")
+	}
+	if *tolerance <= 0 || *tolerance >= 1 {
+		return usagef("--tolerance must be between 0 and 1, got %v", *tolerance)
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	opts := scanOptions{noProgress: true, config: cfg, columns: columns.columns(), provenance: *provenance}
+	if *timeCol != "" {
+		opts.columns.Ignore = append(opts.columns.Ignore, *timeCol)
+	}
+
+	logger.Infof("Comparing %s -> %s", positional[0], positional[1])
+
+	oldData, err := dataset.LoadCSVContext(commandContext, positional[0], opts.columns)
+	if err != nil {
+		return err
+	}
+	newData, err := dataset.LoadCSVContext(commandContext, positional[1], opts.columns)
+	if err != nil {
+		return err
+	}
+
+	oldResult, err := runDetector(oldData, opts)
+	if err != nil {
+		return err
+	}
+	// Provenance traces the findings of the new version to their origin.
+	newOpts := opts
+	if newOpts.origins, err = loadProvenance(positional[1], opts); err != nil {
+		return err
+	}
+	newResult, err := runDetector(newData, newOpts)
+	if err != nil {
+		return err
+	}
+	enrichFindings(positional[1], newOpts, newResult, logger)
+
+	names := newData.Features
+	if len(oldData.Features) > len(names) {
+		names = oldData.Features
+	}
+	comparison := drift.Compare(names, oldData.Samples, newData.Samples, oldResult, newResult)
+	if *forensics {
+		forensicsOpts := drift.ForensicsOptions{
+			Rescan: func(samples []detect.Sample) (*detect.DetectionResult, error) {
+				return runDetector(&dataset.Dataset{Features: newData.Features, Samples: samples}, newOpts)
+			},
+			Tolerance: *tolerance,
+		}
+		if *timeCol != "" {
+			if forensicsOpts.Times, err = sampleTimes(newData, *timeCol); err != nil {
+				return fmt.Errorf("%s: %w", positional[1], err)
+			}
+		}
+		if comparison.Forensics, err = drift.Isolate(comparison, newData.Samples, oldResult, newResult, forensicsOpts); err != nil {
+			return err
+		}
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(comparison)
+	}
+
+	fmt.Print(drift.GenerateReport(comparison, *top))
+	if comparison.Forensics != nil {
+		fmt.Print(drift.GenerateForensicsReport(comparison.Forensics, *top))
+	}
+	return nil
+}
+
+// timeLayouts are the layouts of the times read by sampleTimes, besides
+// Unix seconds.
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+// sampleTimes reads the time each sample of data was created from its
+// column, matched like dataset columns. Empty fields are unknown times.
+func sampleTimes(data *dataset.Dataset, column string) ([]time.Time, error) {
+	col := -1
+	for i, name := range data.Header {
+		if strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(column)) {
+			col = i
+		}
+	}
+	if col < 0 {
+		return nil, fmt.Errorf("no time column %q", column)
+	}
+	times := make([]time.Time, len(data.Records))
+	for i, record := range data.Records {
+		field := strings.TrimSpace(record[col])
+		if field == "" {
+			continue
+		}
+		if seconds, err := strconv.ParseInt(field, 10, 64); err == nil {
+			times[i] = time.Unix(seconds, 0).UTC()
+			continue
+		}
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, field); err == nil {
+				times[i] = t
+				break
+			}
+		}
+		if times[i].IsZero() {
+			return nil, fmt.Errorf("row %d: invalid time %q", i+1, field)
+		}
+	}
+	return times, nil
+}
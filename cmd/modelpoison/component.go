@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/report"
+)
+
+// kfpMetric is a Kubeflow Pipelines metric of mlpipeline-metrics.json.
+type kfpMetric struct {
+	Name        string  `json:"name"`
+	NumberValue float64 `json:"numberValue"`
+	Format      string  `json:"format"`
+}
+
+// kfpOutput is a visualization of mlpipeline-ui-metadata.json.
+type kfpOutput struct {
+	Type    string `json:"type"`
+	Storage string `json:"storage"`
+	Source  string `json:"source"`
+}
+
+// runComponent scans a dataset as a Kubeflow Pipelines or Argo Workflows
+// step: inputs and outputs are declared artifact paths, and the verdict is
+// written as KFP metrics, UI metadata and Argo output parameters.
+func runComponent(args []string) error {
+	fs := newFlagSet("component")
+	columns := addColumnFlags(fs)
+	datasetPath := fs.String("dataset", "", "input dataset artifact (a file, or a directory holding one file)")
+	configPath := fs.String("config", "", "detector configuration file")
+	policyFile := addPolicyFlag(fs)
+	resultPath := fs.String("result", "", "output artifact for the raw result (JSON)")
+	reportPath := fs.String("report", "", "output artifact for the HTML report")
+	metricsPath := fs.String("metrics", "", "KFP metrics file to write, such as /mlpipeline-metrics.json")
+	uiMetadataPath := fs.String("ui-metadata", "", "KFP UI metadata file to write, such as /mlpipeline-ui-metadata.json")
+	paramsDir := fs.String("output-params", "", "directory receiving one file per output parameter (Argo)")
+	failOnPoisoned := fs.Bool("fail-on-poisoned", false, "exit with status 1 when poisoning is detected")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usagef("component takes no arguments (use --dataset)")
+	}
+	if *datasetPath == "" {
+		return usagef("--dataset required")
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if err := applyPolicyFile(cfg, *policyFile); err != nil {
+		return err
+	}
+	path, err := resolveArtifact(*datasetPath)
+	if err != nil {
+		return err
+	}
+	logger.Infof("Detecting poisoning in: %s", path)
+	// Pipeline logs are not terminals.
+	opts := scanOptions{noProgress: true, config: cfg, columns: columns.columns()}
+	result, err := scanDataset(path, opts)
+	if err != nil {
+		return err
+	}
+
+	var raw, html bytes.Buffer
+	if err := detect.WriteResult(&raw, result); err != nil {
+		return err
+	}
+	if err := report.WriteHTML(&html, result); err != nil {
+		return err
+	}
+	metrics, err := json.MarshalIndent(kfpMetrics(result), "", "  ")
+	if err != nil {
+		return err
+	}
+	uiMetadata, err := json.MarshalIndent(map[string][]kfpOutput{"outputs": {
+		{Type: "markdown", Storage: "inline", Source: componentSummary(path, result)},
+		{Type: "web-app", Storage: "inline", Source: html.String()},
+	}}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	for _, artifact := range []struct {
+		path string
+		data []byte
+	}{
+		{*resultPath, raw.Bytes()},
+		{*reportPath, html.Bytes()},
+		{*metricsPath, metrics},
+		{*uiMetadataPath, uiMetadata},
+	} {
+		if artifact.path == "" {
+			continue
+		}
+		if err := writeArtifact(artifact.path, artifact.data); err != nil {
+			return err
+		}
+	}
+	if *paramsDir != "" {
+		if err := writeOutputParams(*paramsDir, result); err != nil {
+			return err
+		}
+	}
+	publishScan(cfg, path, *resultPath, result)
+	policyErr := enforcePolicy(cfg, path, opts, result)
+
+	fmt.Print(componentSummary(path, result))
+	if policyErr != nil {
+		return policyErr
+	}
+	if *failOnPoisoned && result.IsPoisoned {
+		return fmt.Errorf("poisoning detected in %s (risk score %.2f)", path, result.RiskScore)
+	}
+	return nil
+}
+
+// resolveArtifact returns the dataset file of an input artifact path.
+// Pipelines may pass an artifact as a directory; it must hold exactly one
+// file.
+func resolveArtifact(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return path, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", err
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	if len(files) != 1 {
+		return "", fmt.Errorf("dataset artifact %s holds %d files, want 1", path, len(files))
+	}
+	return files[0], nil
+}
+
+// writeArtifact writes an output artifact, creating its directory as
+// pipeline runners expect components to do.
+func writeArtifact(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	logger.Debugf("wrote %s", path)
+	return nil
+}
+
+// kfpMetrics returns the metrics of a result, named as KFP requires.
+func kfpMetrics(result *detect.DetectionResult) map[string][]kfpMetric {
+	poisoned := 0.0
+	if result.IsPoisoned {
+		poisoned = 1
+	}
+	fraction := 0.0
+	if result.SampleCount > 0 {
+		fraction = float64(result.PoisonedCount) / float64(result.SampleCount)
+	}
+	return map[string][]kfpMetric{"metrics": {
+		{Name: "risk-score", NumberValue: result.RiskScore, Format: "PERCENTAGE"},
+		{Name: "poisoned-fraction", NumberValue: fraction, Format: "PERCENTAGE"},
+		{Name: "poisoned-count", NumberValue: float64(result.PoisonedCount), Format: "RAW"},
+		{Name: "sample-count", NumberValue: float64(result.SampleCount), Format: "RAW"},
+		{Name: "is-poisoned", NumberValue: poisoned, Format: "RAW"},
+	}}
+}
+
+// writeOutputParams writes the verdict of a result as one file per output
+// parameter in dir.
+func writeOutputParams(dir string, result *detect.DetectionResult) error {
+	params := map[string]string{
+		"is_poisoned":    strconv.FormatBool(result.IsPoisoned),
+		"risk_score":     strconv.FormatFloat(result.RiskScore, 'f', 4, 64),
+		"poisoned_count": strconv.Itoa(result.PoisonedCount),
+		"sample_count":   strconv.Itoa(result.SampleCount),
+	}
+	for name, value := range params {
+		if err := writeArtifact(filepath.Join(dir, name), []byte(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// componentSummary returns a Markdown summary of a result.
+func componentSummary(path string, result *detect.DetectionResult) string {
+	var b strings.Builder
+	verdict := "✓ Training data appears clean"
+	if result.IsPoisoned {
+		verdict = "⚠️ POISONING DETECTED"
+	}
+	fmt.Fprintf(&b, "## modelpoison: %s\n\n%s\n\n", filepath.Base(path), verdict)
+	fmt.Fprintf(&b, "| Samples | Flagged | Risk score |\n|---|---|---|\n| %d | %d | %.2f |\n",
+		result.SampleCount, result.PoisonedCount, result.RiskScore)
+	return b.String()
+}
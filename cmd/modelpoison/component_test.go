@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// componentSpec is the part of a KFP component.yaml run by the test.
+type componentSpec struct {
+	Inputs         []struct{ Name string } `yaml:"inputs"`
+	Outputs        []struct{ Name string } `yaml:"outputs"`
+	Implementation struct {
+		Container struct {
+			Command []string      `yaml:"command"`
+			RawArgs []interface{} `yaml:"args"`
+		} `yaml:"container"`
+	} `yaml:"implementation"`
+}
+
+// readmeComponentSpec returns the component.yaml documented in the README.
+func readmeComponentSpec(t *testing.T) componentSpec {
+	t.Helper()
+	readme, err := os.ReadFile(filepath.Join("..", "..", "README.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, block, ok := strings.Cut(string(readme), "# Kubeflow Pipelines component.yaml\n")
+	if !ok {
+		t.Fatal("the README has no component.yaml")
+	}
+	block, _, _ = strings.Cut(block, "```")
+	var spec componentSpec
+	if err := yaml.Unmarshal([]byte(block), &spec); err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+// writeComponentDataset writes a dataset of n samples to a file in the
+// artifact directory dir.
+func writeComponentDataset(t *testing.T, dir string, n int) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	b.WriteString("a,b,label\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "%d.%d,%d,%d\n", i%7, i%3, i%5, i%2)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data"), []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestComponentSpec(t *testing.T) {
+	t.Setenv("MODELPOISON_AUDIT_LOG", "")
+	spec := readmeComponentSpec(t)
+	container := spec.Implementation.Container
+	if len(container.Command) < 2 || container.Command[0] != "modelpoison" || container.Command[1] != "component" {
+		t.Fatalf("command %v does not run the component", container.Command)
+	}
+
+	// Run the component as the pipeline would, with the input artifact as
+	// a directory and output paths whose directories do not exist yet.
+	dir := t.TempDir()
+	paths := make(map[string]string)
+	for _, input := range spec.Inputs {
+		paths[input.Name] = filepath.Join(dir, "inputs", input.Name)
+	}
+	writeComponentDataset(t, paths["dataset"], 40)
+	for _, output := range spec.Outputs {
+		paths[output.Name] = filepath.Join(dir, "outputs", output.Name, "data")
+	}
+	args := append([]string(nil), container.Command[2:]...)
+	used := make(map[string]bool)
+	for _, arg := range container.RawArgs {
+		switch arg := arg.(type) {
+		case string:
+			args = append(args, arg)
+		case map[string]interface{}:
+			for kind, name := range arg {
+				if kind != "inputPath" && kind != "outputPath" {
+					t.Fatalf("unknown placeholder %s", kind)
+				}
+				path, ok := paths[name.(string)]
+				if !ok {
+					t.Fatalf("%s %s is not declared", kind, name)
+				}
+				args = append(args, path)
+				used[name.(string)] = true
+			}
+		}
+	}
+	for name := range paths {
+		if !used[name] {
+			t.Errorf("artifact %s is declared but not passed", name)
+		}
+	}
+
+	stdout, err := captureStdout(t, func() error { return runComponent(args) })
+	result, loadErr := detect.LoadResult(paths["result"])
+	if loadErr != nil {
+		t.Fatalf("run failed with %v; result: %v", err, loadErr)
+	}
+	if result.IsPoisoned != (err != nil) {
+		t.Errorf("poisoned %t with --fail-on-poisoned, but run returned %v", result.IsPoisoned, err)
+	}
+	if result.SampleCount != 40 {
+		t.Errorf("result of %d samples, want 40", result.SampleCount)
+	}
+	if !strings.HasPrefix(stdout, "## modelpoison: data\n") {
+		t.Errorf("printed %q", stdout)
+	}
+
+	report, err := os.ReadFile(paths["report"])
+	if err != nil || !strings.Contains(string(report), "<html") {
+		t.Errorf("report: %v\n%.200s", err, report)
+	}
+	var metrics map[string][]kfpMetric
+	readJSON(t, paths["mlpipeline_metrics"], &metrics)
+	var names []string
+	for _, metric := range metrics["metrics"] {
+		names = append(names, metric.Name)
+	}
+	if got := strings.Join(names, ","); got != "risk-score,poisoned-fraction,poisoned-count,sample-count,is-poisoned" {
+		t.Errorf("metrics %s", got)
+	}
+	if m := metrics["metrics"]; len(m) == 5 && (m[0].NumberValue != result.RiskScore || m[3].NumberValue != 40) {
+		t.Errorf("metrics %+v for a risk score of %v", m, result.RiskScore)
+	}
+	var ui map[string][]kfpOutput
+	readJSON(t, paths["mlpipeline_ui_metadata"], &ui)
+	if outputs := ui["outputs"]; len(outputs) != 2 || outputs[0].Type != "markdown" || outputs[0].Source != stdout || outputs[1].Type != "web-app" || outputs[1].Source != string(report) {
+		t.Errorf("UI metadata %+v", outputs)
+	}
+}
+
+// readJSON decodes the JSON file at path into v.
+func readJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+}
+
+func TestComponentOutputParams(t *testing.T) {
+	t.Setenv("MODELPOISON_AUDIT_LOG", "")
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input")
+	writeComponentDataset(t, input, 30)
+	params := filepath.Join(dir, "tmp", "outputs")
+	result := filepath.Join(dir, "result.json")
+	if _, err := captureStdout(t, func() error {
+		return runComponent([]string{"--dataset", filepath.Join(input, "data"), "--output-params", params, "--result", result})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	r, err := detect.LoadResult(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"is_poisoned":    strconv.FormatBool(r.IsPoisoned),
+		"risk_score":     strconv.FormatFloat(r.RiskScore, 'f', 4, 64),
+		"poisoned_count": strconv.Itoa(r.PoisonedCount),
+		"sample_count":   "30",
+	} {
+		if got, err := os.ReadFile(filepath.Join(params, name)); err != nil || string(got) != want {
+			t.Errorf("parameter %s = %q (%v), want %q", name, got, err, want)
+		}
+	}
+}
+
+func TestResolveArtifact(t *testing.T) {
+	dir := t.TempDir()
+	writeComponentDataset(t, dir, 1)
+	// Hidden files, such as the markers of artifact stores, are skipped.
+	if err := os.WriteFile(filepath.Join(dir, ".complete"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := resolveArtifact(dir); err != nil || path != filepath.Join(dir, "data") {
+		t.Errorf("resolved %s to %s, %v", dir, path, err)
+	}
+	if path, err := resolveArtifact(filepath.Join(dir, "data")); err != nil || path != filepath.Join(dir, "data") {
+		t.Errorf("resolved the file to %s, %v", path, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "other.csv"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolveArtifact(dir); err == nil || !strings.Contains(err.Error(), "holds 2 files, want 1") {
+		t.Errorf("resolved a directory of two files: %v", err)
+	}
+}
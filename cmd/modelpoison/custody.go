@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/config"
+	"github.com/hallucinaut/modelpoison/pkg/store"
+)
+
+// custodyFlags holds the flags of commands that record chain-of-custody
+// events in a result store.
+type custodyFlags struct {
+	store   string
+	project string
+}
+
+// addCustodyFlags registers --store and --project on fs.
+func addCustodyFlags(fs *flag.FlagSet) *custodyFlags {
+	c := &custodyFlags{}
+	fs.StringVar(&c.store, "store", "", "also record what happened to each sample in this SQLite file or postgres:// result store")
+	fs.StringVar(&c.project, "project", config.DefaultProject, "project of the samples in --store")
+	return c
+}
+
+// record records events of the samples of the project in the store, if
+// one was given.
+func (c *custodyFlags) record(events []store.Event) error {
+	if c.store == "" || len(events) == 0 {
+		return nil
+	}
+	db, err := store.Open(commandContext, c.store)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	for i := range events {
+		events[i].Project = c.project
+	}
+	if err := db.RecordEvents(commandContext, events); err != nil {
+		return err
+	}
+	logger.Debugf("recorded %d %s events in %s", len(events), events[0].Kind, c.store)
+	return nil
+}
+
+func showCustody(args []string) error {
+	fs := newFlagSet("custody")
+	storeDSN := fs.String("store", "", "SQLite file or postgres:// database the server records results in")
+	project := fs.String("project", config.DefaultProject, "project of the samples")
+	format := fs.String("format", "text", "output format: text or json")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) == 0 {
+		return usagef("custody requires sample IDs")
+	}
+	if *storeDSN == "" {
+		return usagef("custody requires --store")
+	}
+	if *format != "text" && *format != "json" {
+		return usagef("invalid format %q (want text or json)", *format)
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	db, err := store.Open(ctx, *storeDSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	histories := make(map[string][]store.Event, len(positional))
+	for _, id := range positional {
+		events, err := db.Events(ctx, *project, id)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return fmt.Errorf("no events for sample %s in project %s", id, *project)
+		}
+		histories[id] = events
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(histories)
+	}
+	for i, id := range positional {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("Sample %s\n", id)
+		fmt.Printf("  %-20s %-12s %-16s %-16s %-12s %s\n", "TIME", "EVENT", "DATASET", "RUN", "ACTOR", "DETAILS")
+		for _, e := range histories[id] {
+			fmt.Printf("  %-20s %-12s %-16s %-16s %-12s %s\n", e.At.Format(time.RFC3339), e.Kind,
+				orDash(e.DatasetID), orDash(e.RunID), orDash(e.Actor), e.Details)
+		}
+	}
+	return nil
+}
+
+// orDash returns s, or "-" when it is empty.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/jobs"
+)
+
+func runDaemon(args []string) error {
+	fs := newFlagSet("daemon")
+	columns := addColumnFlags(fs)
+	stateDir := fs.String("state", filepath.Join(".modelpoison", "daemon"), "directory holding the job queue and results")
+	workers := fs.Int("workers", 2, "number of jobs to run concurrently")
+	maxQueued := fs.Int("max-queued", 1000, "maximum number of queued jobs (0: no limit)")
+	addr := fs.String("addr", "localhost:8081", "address of the job API (empty to disable)")
+	watchDir := fs.String("watch", "", "also queue datasets created or modified in this directory")
+	watchPriority := fs.Int("watch-priority", 0, "priority of jobs queued by --watch")
+	settle := fs.Duration("settle", 2*time.Second, "wait for writes to settle before queueing a watched dataset")
+	existing := fs.Bool("existing", false, "also queue datasets already in the watched directory")
+	configPath := fs.String("config", "", "detector configuration file")
+	policyFile := addPolicyFlag(fs)
+	pprofAddr := fs.String("pprof-addr", "", "serve runtime profiles under /debug/pprof/ on this address")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usagef("unexpected argument %q", positional[0])
+	}
+	if *workers < 1 {
+		return usagef("--workers must be at least 1")
+	}
+	if *maxQueued < 0 {
+		return usagef("--max-queued must not be negative")
+	}
+	if *addr == "" && *watchDir == "" {
+		return usagef("--addr or --watch required")
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if err := applyPolicyFile(cfg, *policyFile); err != nil {
+		return err
+	}
+	opts := scanOptions{noProgress: true, config: cfg, columns: columns.columns()}
+
+	queue, err := jobs.Open(jobs.Options{
+		Dir:       *stateDir,
+		Workers:   *workers,
+		MaxQueued: *maxQueued,
+		Run: func(ctx context.Context, job jobs.Job, progress detect.ProgressFunc) (*detect.DetectionResult, error) {
+			return runJob(job, opts, progress)
+		},
+		Logger: logger.slog,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	queued := len(queue.List(jobs.StateQueued))
+	logger.Infof("Daemon started: %d workers, %d queued jobs, state in %s", *workers, queued, *stateDir)
+	queue.Start(ctx)
+
+	errs := make(chan error, 3)
+
+	var srv *http.Server
+	if *addr != "" {
+		srv = &http.Server{Addr: *addr, Handler: jobs.Handler{Queue: queue}}
+		logger.Infof("Serving job API on %s", *addr)
+		go func() {
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				errs <- err
+			}
+		}()
+	}
+
+	if *pprofAddr != "" {
+		profiling, err := serveProfiles(*pprofAddr, nil, nil, errs)
+		if err != nil {
+			stop()
+			queue.Wait()
+			return err
+		}
+		defer profiling.Close()
+	}
+
+	if *watchDir != "" {
+		go func() {
+			errs <- watchDatasets(ctx, *watchDir, *settle, *existing, func(path string) {
+				spec := jobs.Spec{Dataset: path, Priority: *watchPriority, Source: "watch"}
+				if _, err := queue.Submit(spec); err != nil {
+					logger.Warnf("queue %s: %v", path, err)
+				}
+			})
+		}()
+	}
+
+	select {
+	case <-ctx.Done():
+	case err = <-errs:
+		stop()
+	}
+
+	if srv != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}
+	if running := len(queue.List(jobs.StateRunning)); running > 0 {
+		logger.Infof("Waiting for %d running jobs", running)
+	}
+	queue.Wait()
+
+	return err
+}
+
+// runJob scans the dataset of a queued job, applying its threshold
+// overrides on top of the daemon configuration.
+func runJob(job jobs.Job, opts scanOptions, progress detect.ProgressFunc) (*detect.DetectionResult, error) {
+	if len(job.Thresholds) > 0 {
+		cfg := *opts.config
+		cfg.Thresholds = make(map[string]float64, len(opts.config.Thresholds)+len(job.Thresholds))
+		for name, threshold := range opts.config.Thresholds {
+			cfg.Thresholds[name] = threshold
+		}
+		for name, threshold := range job.Thresholds {
+			cfg.Thresholds[name] = threshold
+		}
+		opts.config = &cfg
+	}
+	opts.progress = progress
+
+	result, err := scanDataset(job.Dataset, opts)
+	if err != nil {
+		return nil, err
+	}
+	publishScan(opts.config, job.Dataset, "", result)
+	// The job keeps its result when a policy fails it.
+	if err := enforcePolicy(opts.config, job.Dataset, opts, result); err != nil {
+		logger.Errorf("job %s: %v", job.ID, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,484 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// datasetScan holds the outcome of scanning one dataset.
+type datasetScan struct {
+	Path   string                  `json:"path"`
+	Result *detect.DetectionResult `json:"result,omitempty"`
+	Error  string                  `json:"error,omitempty"`
+}
+
+// portfolio holds the results of a multi-dataset scan.
+type portfolio struct {
+	Datasets      []datasetScan `json:"datasets"`
+	SampleCount   int           `json:"sample_count"`
+	PoisonedCount int           `json:"poisoned_count"`
+	MaxRiskScore  float64       `json:"max_risk_score"`
+	Failed        int           `json:"failed"`
+}
+
+func detectPoisoning(args []string) error {
+	fs := newFlagSet("detect")
+	columns := addColumnFlags(fs)
+	encoding := addEncodeFlags(fs)
+	scaling := addScaleFlags(fs)
+	useFloat32 := fs.Bool("float32", false, "store and score dense features as float32, halving their memory")
+	noProgress := fs.Bool("no-progress", false, "disable progress reporting")
+	format := fs.String("format", "text", "output format: text, json or github")
+	configPath := fs.String("config", "", "detector configuration file")
+	policyFile := addPolicyFlag(fs)
+	lists := addListFlags(fs)
+	scripts := addScriptFlags(fs)
+	provenance := addProvenanceFlag(fs)
+	modal := addModalFlags(fs)
+	embedding := addEmbedFlags(fs)
+	mode := addModeFlags(fs)
+	parallel := fs.Int("parallel", 1, "scan up to N datasets concurrently")
+	workers := fs.Int("workers", runtime.GOMAXPROCS(0), "analyze the samples of each dataset with N workers")
+	chunkSize := fs.Int("chunk-size", 0, "stream datasets through the detector N samples at a time")
+	maxMemory := fs.String("max-memory", "", "scan within a memory budget such as 512MB")
+	limit := fs.Int("limit", 0, "only scan the first N samples")
+	sample := fs.Float64("sample", 0, "only scan a random fraction of samples")
+	seed := fs.Int64("seed", 1, "random seed for --sample")
+	filterOpts := addFilterFlags(fs)
+	templatePath := fs.String("template", "", "text/template file for a custom report layout")
+	summary := fs.Bool("summary", false, "print a short plain-language summary instead of the report")
+	saveResult := fs.String("save-result", "", "also save the raw result as JSON to this file")
+	incremental := fs.String("incremental", "", "reuse findings of unchanged samples from the result in this file")
+	nonFinite := fs.String("non-finite", "", "policy for NaN and infinite features: skip, suspicious or error")
+	shard := fs.String("shard", "", "scan only shard K or shards K-L of N, given as K/N or K-L/N")
+	checkpoint := fs.String("checkpoint", "", "save the progress of the scan to this file and resume from it")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 1 {
+		return usagef("dataset required")
+	}
+	if *format != "text" && *format != "json" && *format != "github" {
+		return usagef("invalid format %q (want text, json or github)", *format)
+	}
+	if *parallel < 1 {
+		return usagef("--parallel must be at least 1")
+	}
+	if *workers < 1 {
+		return usagef("--workers must be at least 1")
+	}
+	if *chunkSize < 0 {
+		return usagef("--chunk-size must not be negative")
+	}
+	if *chunkSize > 0 && (*limit > 0 || *sample > 0) {
+		return usagef("--chunk-size cannot be combined with --limit or --sample")
+	}
+	if *chunkSize > 0 && *incremental != "" {
+		return usagef("--chunk-size cannot be combined with --incremental")
+	}
+	if _, err := detect.ParseNonFinite(*nonFinite); err != nil {
+		return usagef("--non-finite: %v", err)
+	}
+	var shards *detect.ShardSpec
+	if *shard != "" || *checkpoint != "" {
+		spec := detect.ShardSpec{From: 0, To: 0, Count: 1}
+		if *shard != "" {
+			if spec, err = detect.ParseShardSpec(*shard); err != nil {
+				return usagef("--shard: %v", err)
+			}
+		}
+		if *incremental != "" || *limit > 0 || *sample > 0 || scripts.files != "" {
+			return usagef("--shard and --checkpoint cannot be combined with --incremental, --limit, --sample or --script")
+		}
+		shards = &spec
+	}
+	var budget int64
+	if *maxMemory != "" {
+		if budget, err = parseSize(*maxMemory); err != nil {
+			return usagef("--max-memory: %v", err)
+		}
+	}
+	if *templatePath != "" && *format != "text" {
+		return usagef("--template requires --format text")
+	}
+	filter, err := filterOpts.filter()
+	if err != nil {
+		return err
+	}
+	if *summary && (*format == "github" || *templatePath != "" || !filter.IsZero()) {
+		return usagef("--summary cannot be combined with --format github, --template or filters")
+	}
+	if *limit < 0 {
+		return usagef("--limit must not be negative")
+	}
+	if *sample < 0 || *sample > 1 {
+		return usagef("--sample must be a fraction between 0 and 1")
+	}
+
+	paths, err := expandDatasets(positional)
+	if err != nil {
+		return err
+	}
+	if *saveResult != "" && len(paths) > 1 {
+		return usagef("--save-result requires a single dataset")
+	}
+	if *incremental != "" && len(paths) > 1 {
+		return usagef("--incremental requires a single dataset")
+	}
+	if shards != nil && len(paths) > 1 {
+		return usagef("--shard and --checkpoint require a single dataset")
+	}
+	if *summary && *format == "json" && len(paths) > 1 {
+		return usagef("--summary with --format json requires a single dataset")
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if *nonFinite != "" {
+		cfg.NonFinite = *nonFinite
+	}
+	if err := applyPolicyFile(cfg, *policyFile); err != nil {
+		return err
+	}
+	tmpl, err := loadTemplate(*templatePath)
+	if err != nil {
+		return err
+	}
+	// Concurrent progress bars would interleave on stderr.
+	opts := scanOptions{
+		noProgress: *noProgress || (*parallel > 1 && len(paths) > 1),
+		config:     cfg,
+		columns:    columns.columns(),
+		limit:      *limit,
+		sample:     *sample,
+		seed:       *seed,
+		workers:    *workers,
+		chunkSize:  *chunkSize,
+		shard:      shards,
+		checkpoint: *checkpoint,
+		provenance: *provenance,
+	}
+	opts.columns.Float32 = *useFloat32
+	if err := encoding.apply(&opts, cfg); err != nil {
+		return err
+	}
+	if err := scaling.apply(&opts, cfg); err != nil {
+		return err
+	}
+	if err := lists.apply(&opts); err != nil {
+		return err
+	}
+	if err := modal.apply(&opts); err != nil {
+		return err
+	}
+	if opts.scripts, err = scripts.load(); err != nil {
+		return err
+	}
+	if (opts.encoderPath != "" || opts.scalerPath != "") && len(paths) > 1 {
+		return usagef("fitting a new --encoder or --scaler requires a single dataset")
+	}
+	if *incremental != "" {
+		opts.incremental = true
+		opts.previous, err = detect.LoadResult(*incremental)
+		if errors.Is(err, os.ErrNotExist) {
+			logger.Infof("No previous result at %s; scanning all samples", *incremental)
+		} else if err != nil {
+			return err
+		}
+	}
+	if budget > 0 {
+		// Concurrent scans share the budget.
+		debug.SetMemoryLimit(budget)
+		opts.maxMemory = budget
+		if n := min(*parallel, len(paths)); n > 1 {
+			opts.maxMemory /= int64(n)
+		}
+	}
+
+	if err := mode.apply(&opts); err != nil {
+		return err
+	}
+	if err := embedding.apply(&opts); err != nil {
+		return err
+	}
+	if opts.embedder != nil {
+		defer opts.embedder.Close()
+	}
+
+	scans := scanDatasets(paths, opts, *parallel)
+
+	if len(scans) == 1 {
+		scan := scans[0]
+		if scan.Error != "" {
+			return fmt.Errorf("%s", scan.Error)
+		}
+		if *saveResult != "" {
+			if err := detect.SaveResult(*saveResult, scan.Result); err != nil {
+				return err
+			}
+			logger.Infof("Saved result to %s", *saveResult)
+		}
+		publishScan(cfg, scan.Path, *saveResult, scan.Result)
+		// A failed policy still prints the report.
+		policyErr := errors.Join(enforceScripts(scan.Path, opts, scan.Result), enforcePolicy(cfg, scan.Path, opts, scan.Result))
+		if err := writeScan(scan, *format, filter, tmpl, *summary); err != nil {
+			return err
+		}
+		return policyErr
+	}
+
+	var policyErrs []error
+	for _, scan := range scans {
+		if scan.Result != nil {
+			publishScan(cfg, scan.Path, "", scan.Result)
+			policyErrs = append(policyErrs, enforceScripts(scan.Path, opts, scan.Result), enforcePolicy(cfg, scan.Path, opts, scan.Result))
+		}
+	}
+
+	lines := make([]map[string]int, len(scans))
+	for i, scan := range scans {
+		if scan.Result != nil {
+			lines[i] = sampleLines(scan.Path, scan.Result)
+		}
+	}
+	p := summarize(scans)
+	for i := range p.Datasets {
+		if p.Datasets[i].Result != nil {
+			p.Datasets[i].Result = applyFilter(filter, p.Datasets[i].Result)
+		}
+	}
+	switch *format {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(p); err != nil {
+			return err
+		}
+	case "github":
+		for i, scan := range p.Datasets {
+			if scan.Result == nil {
+				fmt.Printf("::error file=%s,title=modelpoison::%s\n", escapeProperty(workspacePath(scan.Path)), escapeData(scan.Error))
+				continue
+			}
+			if err := writeGitHub(os.Stdout, scan.Path, scan.Result, lines[i]); err != nil {
+				return err
+			}
+		}
+		if err := writeJobSummary(p.Datasets, lines); err != nil {
+			return err
+		}
+	default:
+		for _, scan := range p.Datasets {
+			if scan.Result == nil {
+				continue
+			}
+			if *summary {
+				if err := writeSummary(os.Stdout, "text", scan.Result, scan.Path); err != nil {
+					return err
+				}
+				fmt.Println()
+				continue
+			}
+			fmt.Printf("### %s\n\n", scan.Path)
+			if err := printDetection(scan.Result, tmpl); err != nil {
+				return err
+			}
+			fmt.Println()
+		}
+		fmt.Print(generatePortfolioReport(p))
+	}
+
+	if p.Failed > 0 {
+		policyErrs = append(policyErrs, fmt.Errorf("%d of %d datasets failed to scan", p.Failed, len(p.Datasets)))
+	}
+	return errors.Join(policyErrs...)
+}
+
+// writeScan writes the result of a single-dataset scan in format, narrowed
+// by filter, or its summary.
+func writeScan(scan datasetScan, format string, filter detect.Filter, tmpl *template.Template, summary bool) error {
+	if summary {
+		return writeSummary(os.Stdout, format, scan.Result, scan.Path)
+	}
+	lines := sampleLines(scan.Path, scan.Result)
+	result := applyFilter(filter, scan.Result)
+	switch format {
+	case "json":
+		return detect.WriteResult(os.Stdout, result)
+	case "github":
+		if err := writeGitHub(os.Stdout, scan.Path, result, lines); err != nil {
+			return err
+		}
+		return writeJobSummary([]datasetScan{{Path: scan.Path, Result: result}}, []map[string]int{lines})
+	}
+	return printDetection(result, tmpl)
+}
+
+// printDetection prints a detection report and verdict.
+func printDetection(result *detect.DetectionResult, tmpl *template.Template) error {
+	return writeDetection(os.Stdout, result, tmpl)
+}
+
+// writeDetection writes a detection report and verdict to w. A custom
+// template replaces both the report and the verdict.
+func writeDetection(w io.Writer, result *detect.DetectionResult, tmpl *template.Template) error {
+	if tmpl != nil {
+		return detect.RenderReport(w, tmpl, result)
+	}
+
+	if _, err := fmt.Fprintln(w, detect.GenerateReport(result)); err != nil {
+		return err
+	}
+
+	var err error
+	if result.IsPoisoned {
+		_, err = fmt.Fprint(w, "⚠️  POISONING DETECTED\nRecommendation: Clean training data before training\n")
+	} else {
+		_, err = fmt.Fprintln(w, "✓ Training data appears clean")
+	}
+	return err
+}
+
+// loadTemplate loads the report template at path, or returns nil for the
+// default report when path is empty.
+func loadTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return nil, nil
+	}
+	logger.Debugf("loading report template %s", path)
+	return detect.LoadReportTemplate(path)
+}
+
+// expandDatasets expands glob patterns into dataset paths, keeping the
+// order of the arguments and dropping duplicates.
+func expandDatasets(args []string) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+
+	for _, arg := range args {
+		matches := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			matches, err = filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no datasets match %q", arg)
+			}
+		}
+
+		for _, path := range matches {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+
+	return paths, nil
+}
+
+// scanDatasets scans paths with up to workers concurrent scans. Results are
+// returned in the order of paths.
+func scanDatasets(paths []string, opts scanOptions, workers int) []datasetScan {
+	scans := make([]datasetScan, len(paths))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(paths); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				scans[i] = scanOne(paths[i], opts, len(paths) > 1)
+			}
+		}()
+	}
+
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return scans
+}
+
+// scanOne scans a single dataset, recording any error in the result.
+func scanOne(path string, opts scanOptions, multiple bool) datasetScan {
+	logger.Infof("Detecting poisoning in: %s", path)
+
+	result, err := scanDataset(path, opts)
+	if err != nil {
+		if multiple {
+			logger.Errorf("%v", err)
+		}
+		return datasetScan{Path: path, Error: err.Error()}
+	}
+
+	return datasetScan{Path: path, Result: result}
+}
+
+// summarize aggregates per-dataset scans into a portfolio.
+func summarize(scans []datasetScan) *portfolio {
+	p := &portfolio{Datasets: scans}
+	for _, scan := range scans {
+		if scan.Result == nil {
+			p.Failed++
+			continue
+		}
+		p.SampleCount += scan.Result.SampleCount
+		p.PoisonedCount += scan.Result.PoisonedCount
+		if scan.Result.RiskScore > p.MaxRiskScore {
+			p.MaxRiskScore = scan.Result.RiskScore
+		}
+	}
+	return p
+}
+
+// generatePortfolioReport renders the portfolio summary table.
+func generatePortfolioReport(p *portfolio) string {
+	width := len("Dataset")
+	for _, scan := range p.Datasets {
+		if len(scan.Path) > width {
+			width = len(scan.Path)
+		}
+	}
+
+	var report string
+	report += "=== Portfolio Summary ===\n\n"
+	report += fmt.Sprintf("%-*s %10s %10s %6s  %s\n", width, "Dataset", "Samples", "Poisoned", "Risk", "Status")
+
+	for _, scan := range p.Datasets {
+		if scan.Result == nil {
+			report += fmt.Sprintf("%-*s %10s %10s %6s  error: %s\n", width, scan.Path, "-", "-", "-", scan.Error)
+			continue
+		}
+		status := "clean"
+		if scan.Result.IsPoisoned {
+			status = "POISONED"
+		}
+		report += fmt.Sprintf("%-*s %10d %10d %5.0f%%  %s\n", width, scan.Path,
+			scan.Result.SampleCount, scan.Result.PoisonedCount, scan.Result.RiskScore*100, status)
+	}
+
+	report += fmt.Sprintf("%-*s %10d %10d %5.0f%%  max risk, %d failed\n", width, "Total",
+		p.SampleCount, p.PoisonedCount, p.MaxRiskScore*100, p.Failed)
+
+	return report
+}
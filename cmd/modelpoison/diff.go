@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func diffResults(args []string) error {
+	fs := newFlagSet("diff")
+	format := fs.String("format", "text", "output format: text or json")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return usagef("diff requires two result files")
+	}
+	if *format != "text" && *format != "json" {
+		return usagef("invalid format %q (want text or json)", *format)
+	}
+
+	previous, err := detect.LoadResult(positional[0])
+	if err != nil {
+		return err
+	}
+	current, err := detect.LoadResult(positional[1])
+	if err != nil {
+		return err
+	}
+
+	diff := detect.DiffResults(previous, current)
+	logger.Debugf("%d newly flagged, %d resolved, %d changed",
+		len(diff.NewlyFlagged), len(diff.Resolved), len(diff.Changed))
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diff)
+	}
+
+	fmt.Print(detect.GenerateDiffReport(diff))
+	return nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/inference"
+)
+
+// embedFlags holds the flags selecting the model that embeds samples
+// before they are scanned.
+type embedFlags struct {
+	model, provider, library string
+	device, batch            int
+}
+
+// addEmbedFlags registers --embed-model and the ONNX Runtime flags on fs.
+func addEmbedFlags(fs *flag.FlagSet) *embedFlags {
+	e := &embedFlags{}
+	fs.StringVar(&e.model, "embed-model", "", "replace the features of samples with their embeddings by this ONNX model (requires a build with -tags onnx)")
+	fs.StringVar(&e.provider, "onnx-provider", "cpu", "execution provider running --embed-model: cpu, cuda, tensorrt, coreml or directml")
+	fs.IntVar(&e.device, "onnx-device", 0, "GPU running --embed-model with the cuda, tensorrt and directml providers")
+	fs.StringVar(&e.library, "onnx-library", os.Getenv("ONNXRUNTIME_LIB"), "ONNX Runtime shared library (default $ONNXRUNTIME_LIB, or the platform's default library)")
+	fs.IntVar(&e.batch, "embed-batch", inference.DefaultBatchSize, "samples embedded at once by --embed-model")
+	return e
+}
+
+// apply opens the model of --embed-model into opts. The caller closes it.
+func (e *embedFlags) apply(opts *scanOptions) error {
+	provider, err := inference.ParseProvider(e.provider)
+	if err != nil {
+		return usagef("--onnx-provider: %v", err)
+	}
+	if e.model == "" {
+		return nil
+	}
+	if e.batch <= 0 {
+		return usagef("--embed-batch must be positive")
+	}
+	if opts.chunkSize > 0 || opts.shard != nil {
+		return usagef("--embed-model embeds loaded datasets and does not support --chunk-size or --shard")
+	}
+	if opts.mode != "" {
+		return usagef("%s datasets have no features for --embed-model", opts.mode)
+	}
+	model, err := inference.Open(inference.Options{Path: e.model, Provider: provider, Device: e.device, Library: e.library})
+	if err != nil {
+		return fmt.Errorf("--embed-model: %w", err)
+	}
+	logger.Debugf("loaded %s on the %s execution provider", e.model, provider)
+	opts.embedder = model
+	opts.embedBatch = e.batch
+	return nil
+}
+
+// embedDataset returns data with the features of its samples replaced by
+// their embeddings by opts.embedder, named embedding_0 and on, or data
+// itself when samples are not embedded. The records are shared, like
+// those of scaled datasets.
+func embedDataset(path string, data *dataset.Dataset, opts scanOptions) (*dataset.Dataset, error) {
+	if opts.embedder == nil {
+		return data, nil
+	}
+	embedded := *data
+	embedded.Samples = make([]detect.Sample, len(data.Samples))
+	for i, s := range data.Samples {
+		embedded.Samples[i] = s.Clone()
+	}
+	dim, err := inference.Embed(commandContext, opts.embedder, embedded.Samples, opts.embedBatch)
+	if err != nil {
+		return nil, fmt.Errorf("%s: embedding: %w", path, err)
+	}
+	embedded.Features = make([]string, dim)
+	for j := range embedded.Features {
+		embedded.Features[j] = fmt.Sprintf("embedding_%d", j)
+	}
+	return &embedded, nil
+}
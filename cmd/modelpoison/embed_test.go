@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// doubleModel embeds each input as its features doubled and their count.
+type doubleModel struct{}
+
+func (doubleModel) Run(batch [][]float32) ([][]float32, error) {
+	outputs := make([][]float32, len(batch))
+	for i, input := range batch {
+		for _, v := range input {
+			outputs[i] = append(outputs[i], 2*v)
+		}
+		outputs[i] = append(outputs[i], float32(len(input)))
+	}
+	return outputs, nil
+}
+
+func (doubleModel) Close() error { return nil }
+
+func TestEmbedDataset(t *testing.T) {
+	data := &dataset.Dataset{
+		Features: []string{"a", "b"},
+		Samples:  []detect.Sample{{ID: "x", Features: []float64{1, 2}}, {ID: "y", Features: []float64{3, 4}}},
+	}
+	if got, err := embedDataset("d.csv", data, scanOptions{}); err != nil || got != data {
+		t.Fatalf("embedded without a model: %v", err)
+	}
+
+	got, err := embedDataset("d.csv", data, scanOptions{embedder: doubleModel{}, embedBatch: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"embedding_0", "embedding_1", "embedding_2"}; !reflect.DeepEqual(got.Features, want) {
+		t.Errorf("features %v, want %v", got.Features, want)
+	}
+	if !reflect.DeepEqual(got.Samples[1].Features, []float64{6, 8, 2}) || got.Samples[1].ID != "y" {
+		t.Errorf("embedded %+v", got.Samples[1])
+	}
+	if !reflect.DeepEqual(data.Samples[0].Features, []float64{1, 2}) || len(data.Features) != 2 {
+		t.Errorf("embedding changed the loaded dataset: %v %+v", data.Features, data.Samples[0])
+	}
+}
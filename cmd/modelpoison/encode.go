@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hallucinaut/modelpoison/pkg/config"
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/preprocess"
+)
+
+// encodeFlags holds the categorical encoding flags shared by the commands
+// that scan and defend datasets.
+type encodeFlags struct {
+	categorical string
+	method      string
+	path        string
+}
+
+// addEncodeFlags registers --categorical, --encode and --encoder on fs.
+func addEncodeFlags(fs *flag.FlagSet) *encodeFlags {
+	f := &encodeFlags{}
+	fs.StringVar(&f.categorical, "categorical", "", "comma-separated categorical columns to encode as features")
+	fs.StringVar(&f.method, "encode", "", "encode categorical columns: onehot, ordinal, frequency or hashing")
+	fs.StringVar(&f.path, "encoder", "", "apply the fitted encoder in this file, fitting and saving it there first if it does not exist")
+	return f
+}
+
+// apply sets the encoding of opts: the encoder saved at --encoder when the
+// file exists, otherwise the encoding configured by cfg, with --encode
+// replacing its method and --categorical adding columns, fitted to each
+// dataset and saved at --encoder.
+func (f *encodeFlags) apply(opts *scanOptions, cfg *config.Config) error {
+	if _, err := preprocess.ParseEncoding(f.method); err != nil {
+		return usagef("--encode: %v", err)
+	}
+	if f.path != "" {
+		encoder, err := preprocess.LoadEncoder(f.path)
+		if err == nil {
+			if f.method != "" || f.categorical != "" {
+				return usagef("--encode and --categorical cannot be combined with the existing encoder %s", f.path)
+			}
+			logger.Debugf("loaded encoder %s", f.path)
+			opts.encoder = encoder
+			return nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	if cfg.Encoding != nil {
+		opts.encoding = *cfg.Encoding
+	}
+	if f.method != "" {
+		opts.encoding.Method = preprocess.Encoding(f.method)
+	}
+	opts.categorical = opts.encoding.ColumnNames()
+	for _, column := range splitList(f.categorical) {
+		if _, ok := opts.encoding.Columns[column]; !ok {
+			opts.categorical = append(opts.categorical, column)
+		}
+	}
+	if len(opts.categorical) == 0 && (f.method != "" || f.path != "") {
+		return usagef("--encode and --encoder require categorical columns, from --categorical or the encoding configuration")
+	}
+	opts.encoderPath = f.path
+	return nil
+}
+
+// encodeColumns returns the columns of the dataset at path with its
+// categorical columns encoded: by opts.encoder, or by an encoder fitted to
+// the categories counted in a first pass over the file, saved to
+// opts.encoderPath when set.
+func encodeColumns(path string, opts scanOptions) (dataset.Columns, error) {
+	cols := opts.columns
+	if dataset.IsSVMLight(path) && (opts.encoder != nil || len(opts.categorical) > 0) {
+		return cols, fmt.Errorf("%s: SVMlight datasets have no categorical columns to encode", path)
+	}
+	if dataset.IsJSON(path) && opts.encoder == nil && len(opts.categorical) > 0 {
+		return cols, fmt.Errorf("%s: encoders are fitted to CSV datasets; apply one fitted with --encoder", path)
+	}
+	if opts.encoder != nil {
+		cols.Categorical, cols.Encoder = opts.encoder.Categorical(), opts.encoder
+		return cols, nil
+	}
+	if len(opts.categorical) == 0 {
+		return cols, nil
+	}
+
+	cols.Categorical = opts.categorical
+	f, err := os.Open(path)
+	if err != nil {
+		return cols, err
+	}
+	defer f.Close()
+	counts, err := dataset.CountCategories(f, cols)
+	if err != nil {
+		return cols, fmt.Errorf("%s: %w", path, err)
+	}
+	encoder, err := preprocess.FitEncoder(opts.encoding, opts.categorical, counts)
+	if err != nil {
+		return cols, fmt.Errorf("%s: encoding: %w", path, err)
+	}
+	if opts.encoderPath != "" {
+		if err := encoder.Save(opts.encoderPath); err != nil {
+			return cols, err
+		}
+		logger.Infof("Saved encoder to %s", opts.encoderPath)
+	}
+	cols.Encoder = encoder
+	return cols, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hallucinaut/modelpoison/pkg/attack"
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/evaluate"
+)
+
+func evaluateDetectors(args []string) error {
+	fs := newFlagSet("evaluate")
+	columns := addColumnFlags(fs)
+	attacks := fs.String("attacks", "", "comma-separated attacks to inject into each clean dataset (default backdoor,label_flip,clean_label, or every attack generator with --matrix)")
+	matrix := fs.Bool("matrix", false, "report every detector against every attack generator as a red-team matrix")
+	rate := fs.Float64("rate", 0.05, "poisoning rate of the injected attacks")
+	targetClass := fs.Int("target-class", 0, "target class of the injected attacks")
+	seed := fs.Int64("seed", 1, "random seed of the injected attacks")
+	truthPath := addTruthFlag(fs)
+	configPath := fs.String("config", "", "detector configuration file")
+	format := fs.String("format", "text", "output format: text or json")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return usagef("invalid format %q (want text or json)", *format)
+	}
+	if len(positional) == 0 {
+		return usagef("dataset required")
+	}
+
+	base := attack.DefaultConfig()
+	base.Rate = *rate
+	base.TargetClass = *targetClass
+	base.Seed = *seed
+	var names []string
+	for _, name := range strings.Split(*attacks, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 && !*matrix {
+		names = []string{attack.AttackBackdoor, attack.AttackLabelFlip, attack.AttackCleanLabel}
+	}
+
+	paths, err := expandDatasets(positional)
+	if err != nil {
+		return err
+	}
+	groundTruthFile, err := loadTruth(*truthPath)
+	if err != nil {
+		return err
+	}
+	var cases []evaluate.Case
+	for _, path := range paths {
+		data, err := dataset.LoadCSVContext(commandContext, path, columns.columns())
+		if err != nil {
+			return err
+		}
+		// Datasets with ground truth are evaluated as they are, and per
+		// poison type when the ground truth lists several.
+		if data.Poisoned != nil || groundTruthFile != nil {
+			poisoned, types, err := groundTruth(path, data, groundTruthFile)
+			if err != nil {
+				return err
+			}
+			labeled := evaluate.Case{Dataset: path, Attack: "labeled", Samples: data.Samples, Poisoned: poisoned}
+			cases = append(cases, evaluate.ByType(labeled, types)...)
+			continue
+		}
+		generators := evaluate.Attacks(base, names)
+		if len(names) == 0 {
+			generators = evaluate.Generators(base, len(data.Features))
+		}
+		injected, err := evaluate.Inject(path, data.Samples, generators)
+		if err != nil {
+			return err
+		}
+		cases = append(cases, injected...)
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	detector, err := newDetector(cfg)
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("Evaluating detectors on %d cases", len(cases))
+	metrics, err := evaluate.Run(detector, cases)
+	if err == nil {
+		err = checkPlugins()
+	}
+	if err != nil {
+		return err
+	}
+
+	var report interface{} = metrics
+	if *matrix {
+		report = evaluate.NewMatrix(metrics)
+	}
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	if *matrix {
+		fmt.Print(evaluate.GenerateMatrixReport(report.(evaluate.Matrix)))
+	} else {
+		fmt.Print(evaluate.GenerateReport(metrics))
+	}
+	return nil
+}
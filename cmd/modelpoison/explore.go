@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/hallucinaut/modelpoison/pkg/allowlist"
+	"github.com/hallucinaut/modelpoison/pkg/audit"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// exploreSortKeys lists the sort orders cycled by the explorer.
+var exploreSortKeys = []string{"score", "id", "type", "class"}
+
+// explorer holds the state of the findings browser.
+type explorer struct {
+	findings []detect.PoisonedSample
+	allow    *allowlist.Allowlist
+	types    []detect.PoisonType
+	classes  []int
+
+	typeFilter  int // index into types, -1 for all
+	classFilter int // index into classes, -1 for all
+	minScore    float64
+	sortKey     int
+
+	rows    []detect.PoisonedSample
+	cursor  int
+	offset  int
+	detail  bool
+	dirty   bool
+	status  string
+	height  int
+	width   int
+	quitted bool
+	save    bool
+
+	// edits holds the decision last marked for each sample, empty when
+	// cleared, for the audit log.
+	edits map[string]allowlist.Decision
+}
+
+// newExplorer creates an explorer over the flagged samples of result.
+func newExplorer(result *detect.DetectionResult, allow *allowlist.Allowlist) *explorer {
+	e := &explorer{allow: allow, edits: make(map[string]allowlist.Decision), typeFilter: -1, classFilter: -1, height: 24, width: 80}
+
+	seenTypes := make(map[detect.PoisonType]bool)
+	seenClasses := make(map[int]bool)
+	for _, sample := range result.Samples {
+		if !sample.IsPoisoned {
+			continue
+		}
+		e.findings = append(e.findings, sample)
+		if !seenTypes[sample.Type] {
+			seenTypes[sample.Type] = true
+			e.types = append(e.types, sample.Type)
+		}
+		if !seenClasses[sample.Label] {
+			seenClasses[sample.Label] = true
+			e.classes = append(e.classes, sample.Label)
+		}
+	}
+	sort.Slice(e.types, func(i, j int) bool { return e.types[i] < e.types[j] })
+	sort.Ints(e.classes)
+
+	e.refresh()
+	return e
+}
+
+// refresh rebuilds the filtered and sorted view.
+func (e *explorer) refresh() {
+	e.rows = e.rows[:0]
+	for _, sample := range e.findings {
+		if e.typeFilter >= 0 && sample.Type != e.types[e.typeFilter] {
+			continue
+		}
+		if e.classFilter >= 0 && sample.Label != e.classes[e.classFilter] {
+			continue
+		}
+		if sample.Score < e.minScore {
+			continue
+		}
+		e.rows = append(e.rows, sample)
+	}
+
+	key := exploreSortKeys[e.sortKey]
+	sort.SliceStable(e.rows, func(i, j int) bool {
+		a, b := e.rows[i], e.rows[j]
+		switch key {
+		case "id":
+			return a.ID < b.ID
+		case "type":
+			return a.Type < b.Type
+		case "class":
+			return a.Label < b.Label
+		default:
+			return a.Score > b.Score
+		}
+	})
+
+	if e.cursor >= len(e.rows) {
+		e.cursor = len(e.rows) - 1
+	}
+	if e.cursor < 0 {
+		e.cursor = 0
+	}
+}
+
+// selected returns the sample under the cursor.
+func (e *explorer) selected() (detect.PoisonedSample, bool) {
+	if e.cursor < len(e.rows) {
+		return e.rows[e.cursor], true
+	}
+	return detect.PoisonedSample{}, false
+}
+
+// mark records a decision for the selected sample; an empty decision
+// clears it.
+func (e *explorer) mark(decision allowlist.Decision) {
+	sample, ok := e.selected()
+	if !ok {
+		return
+	}
+
+	if decision == "" {
+		e.allow.Remove(sample.ID)
+		e.status = "Cleared decision for " + sample.ID
+	} else {
+		e.allow.Set(allowlist.Entry{ID: sample.ID, Decision: decision, Type: string(sample.Type), Hash: sample.Hash})
+		e.status = fmt.Sprintf("Marked %s as %s", sample.ID, decision)
+	}
+	e.dirty = true
+	e.edits[sample.ID] = decision
+	e.move(1)
+}
+
+// move moves the cursor by delta rows.
+func (e *explorer) move(delta int) {
+	e.cursor += delta
+	if e.cursor >= len(e.rows) {
+		e.cursor = len(e.rows) - 1
+	}
+	if e.cursor < 0 {
+		e.cursor = 0
+	}
+}
+
+// handleKey applies a key press to the explorer state.
+func (e *explorer) handleKey(key string) {
+	e.status = ""
+	page := e.listHeight()
+
+	switch key {
+	case "up", "k":
+		e.move(-1)
+	case "down", "j":
+		e.move(1)
+	case "pgup":
+		e.move(-page)
+	case "pgdown", " ":
+		e.move(page)
+	case "home", "g":
+		e.cursor = 0
+	case "end", "G":
+		e.move(len(e.rows))
+	case "enter":
+		e.detail = !e.detail
+	case "t":
+		e.typeFilter = cycle(e.typeFilter, len(e.types))
+		e.refresh()
+	case "c":
+		e.classFilter = cycle(e.classFilter, len(e.classes))
+		e.refresh()
+	case "s":
+		e.sortKey = (e.sortKey + 1) % len(exploreSortKeys)
+		e.refresh()
+	case "+":
+		e.minScore = minFloat(e.minScore+0.1, 1.0)
+		e.refresh()
+	case "-":
+		e.minScore = maxFloat(e.minScore-0.1, 0.0)
+		e.refresh()
+	case "a":
+		e.mark(allowlist.DecisionAccepted)
+	case "f":
+		e.mark(allowlist.DecisionFalsePositive)
+	case "u":
+		e.mark("")
+	case "q":
+		e.quitted, e.save = true, true
+	case "ctrl-c", "Q":
+		e.quitted = true
+	}
+}
+
+// cycle advances a filter index through -1 (all) and 0..n-1.
+func cycle(index, n int) int {
+	index++
+	if index >= n {
+		return -1
+	}
+	return index
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// listHeight returns the number of table rows that fit on screen.
+func (e *explorer) listHeight() int {
+	h := e.height - 6
+	if e.detail {
+		h -= 8
+	}
+	if h < 1 {
+		h = 1
+	}
+	return h
+}
+
+// render draws the explorer screen.
+func (e *explorer) render(w io.Writer) {
+	var b strings.Builder
+	b.WriteString("\x1b[H\x1b[2J")
+
+	typeName, className := "all", "all"
+	if e.typeFilter >= 0 {
+		typeName = string(e.types[e.typeFilter])
+	}
+	if e.classFilter >= 0 {
+		className = fmt.Sprint(e.classes[e.classFilter])
+	}
+	e.line(&b, fmt.Sprintf("modelpoison explore — %d of %d findings  type:%s  class:%s  score>=%.0f%%  sort:%s",
+		len(e.rows), len(e.findings), typeName, className, e.minScore*100, exploreSortKeys[e.sortKey]))
+	e.line(&b, fmt.Sprintf("  %-24s %-16s %6s %6s  %s", "ID", "TYPE", "CLASS", "SCORE", "DECISION"))
+
+	height := e.listHeight()
+	if e.cursor < e.offset {
+		e.offset = e.cursor
+	}
+	if e.cursor >= e.offset+height {
+		e.offset = e.cursor - height + 1
+	}
+
+	for i := e.offset; i < len(e.rows) && i < e.offset+height; i++ {
+		sample := e.rows[i]
+		marker := "  "
+		if i == e.cursor {
+			marker = "> "
+		}
+		decision := ""
+		if entry, ok := e.allow.Lookup(sample.ID); ok {
+			decision = string(entry.Decision)
+		}
+		e.line(&b, fmt.Sprintf("%s%-24s %-16s %6d %5.0f%%  %s",
+			marker, sample.ID, sample.Type, sample.Label, sample.Score*100, decision))
+	}
+	for i := len(e.rows) - e.offset; i < height; i++ {
+		e.line(&b, "")
+	}
+
+	if e.detail {
+		if sample, ok := e.selected(); ok {
+			e.line(&b, strings.Repeat("─", e.width))
+			e.line(&b, "ID:          "+sample.ID)
+			e.line(&b, "Type:        "+string(sample.Type))
+			e.line(&b, fmt.Sprintf("Class:       %d", sample.Label))
+			e.line(&b, fmt.Sprintf("Score:       %.0f%%  Confidence: %.0f%%", sample.Score*100, sample.Confidence*100))
+			e.line(&b, "Description: "+sample.Description)
+			e.line(&b, "Evidence:    "+sample.Evidence)
+			e.line(&b, strings.Repeat("─", e.width))
+		}
+	}
+
+	e.line(&b, e.status)
+	e.line(&b, "↑↓ move  enter details  t type  c class  +/- min score  s sort  a accept  f false positive  u clear  q save & quit  Q discard")
+	io.WriteString(w, b.String())
+}
+
+// line writes a screen line truncated to the terminal width.
+func (e *explorer) line(b *strings.Builder, text string) {
+	if runes := []rune(text); len(runes) > e.width {
+		text = string(runes[:e.width])
+	}
+	b.WriteString(text)
+	b.WriteString("\x1b[K\r\n")
+}
+
+// readKey reads a single key press from a raw-mode terminal.
+func readKey(r *bufio.Reader) (string, error) {
+	c, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+
+	switch c {
+	case 3:
+		return "ctrl-c", nil
+	case '\r', '\n':
+		return "enter", nil
+	case 0x1b:
+		if r.Buffered() == 0 {
+			return "esc", nil
+		}
+		seq := make([]byte, 0, 4)
+		for r.Buffered() > 0 && len(seq) < 4 {
+			b, _ := r.ReadByte()
+			seq = append(seq, b)
+			if b >= 'A' && b <= 'Z' || b == '~' {
+				break
+			}
+		}
+		switch string(seq) {
+		case "[A":
+			return "up", nil
+		case "[B":
+			return "down", nil
+		case "[5~":
+			return "pgup", nil
+		case "[6~":
+			return "pgdown", nil
+		case "[H", "[1~":
+			return "home", nil
+		case "[F", "[4~":
+			return "end", nil
+		}
+		return "esc", nil
+	}
+
+	return string(c), nil
+}
+
+func exploreResult(args []string) error {
+	fs := newFlagSet("explore")
+	allowPath := fs.String("allowlist", "allowlist.json", "allowlist file receiving review decisions")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usagef("result file required")
+	}
+
+	result, err := detect.LoadResult(positional[0])
+	if err != nil {
+		return err
+	}
+	allow, err := allowlist.Load(*allowPath)
+	if err != nil {
+		return err
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) || !isTerminal(os.Stdout) {
+		return fmt.Errorf("explore requires an interactive terminal")
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+
+	e := newExplorer(result, allow)
+	err = runExplorer(e, fd)
+
+	io.WriteString(os.Stdout, "\x1b[H\x1b[2J")
+	term.Restore(fd, state)
+	if err != nil {
+		return err
+	}
+
+	if e.save && e.dirty {
+		if err := allow.Save(*allowPath); err != nil {
+			return err
+		}
+		logger.Infof("Wrote %d decisions to %s", allow.Len(), *allowPath)
+		if err := recordAudit(audit.ActionAllowlistEdit, "", *allowPath, audit.DecisionDetails(e.edits)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runExplorer runs the render/input loop until the user quits.
+func runExplorer(e *explorer, fd int) error {
+	reader := bufio.NewReader(os.Stdin)
+	for !e.quitted {
+		if width, height, err := term.GetSize(fd); err == nil && width > 0 && height > 0 {
+			e.width, e.height = width, height
+		}
+		e.render(os.Stdout)
+
+		key, err := readKey(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		e.handleKey(key)
+	}
+	return nil
+}
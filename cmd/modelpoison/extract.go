@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func extractFlagged(args []string) error {
+	fs := newFlagSet("extract")
+	columns := addColumnFlags(fs)
+	out := fs.String("out", "", "file to write flagged rows to (default stdout)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return usagef("result and dataset required")
+	}
+
+	result, err := detect.LoadResult(positional[0])
+	if err != nil {
+		return err
+	}
+	data, err := dataset.LoadCSVContext(commandContext, positional[1], columns.columns())
+	if err != nil {
+		return err
+	}
+
+	rows := make(map[string][]int, len(data.Samples))
+	for i, sample := range data.Samples {
+		rows[sample.ID] = append(rows[sample.ID], i)
+	}
+
+	var findings []detect.PoisonedSample
+	var indices []int
+	missing := 0
+	for _, finding := range result.Samples {
+		if !finding.IsPoisoned {
+			continue
+		}
+		matches := rows[finding.ID]
+		if len(matches) == 0 {
+			missing++
+			logger.Debugf("flagged sample %s is not in %s", finding.ID, positional[1])
+			continue
+		}
+		for _, i := range matches {
+			findings = append(findings, finding)
+			indices = append(indices, i)
+		}
+	}
+	if missing > 0 {
+		logger.Warnf("%d flagged samples are not in %s", missing, positional[1])
+	}
+
+	if *out == "" {
+		return writeFlagged(os.Stdout, data, indices, findings)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := writeFlagged(f, data, indices, findings); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	logger.Infof("Wrote %d flagged rows to %s", len(indices), *out)
+	return nil
+}
+
+// writeFlagged writes the dataset rows at indices followed by the evidence
+// of the matching findings.
+func writeFlagged(w io.Writer, data *dataset.Dataset, indices []int, findings []detect.PoisonedSample) error {
+	writer := csv.NewWriter(w)
+	header := append(append([]string(nil), data.Header...),
+		"poison_type", "score", "confidence", "description", "evidence", "review", "review_note")
+	writer.Write(header)
+
+	for n, i := range indices {
+		finding := findings[n]
+		record := append(append([]string(nil), data.Records[i]...),
+			string(finding.Type),
+			fmt.Sprintf("%.4f", finding.Score),
+			fmt.Sprintf("%.4f", finding.Confidence),
+			finding.Description,
+			finding.Evidence,
+			finding.Review,
+			finding.ReviewNote)
+		writer.Write(record)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExtractFlagged(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "flagged.csv")
+	err := extractFlagged([]string{filepath.Join("testdata", "extract", "result.json"), filepath.Join("testdata", "clean", "in.csv"), "--ignore-cols", "note", "--out", out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "extract/flagged.csv.golden", data)
+
+	// The rows are those of the flagged samples in the dataset, in the
+	// order of the result; unflagged samples and flagged samples missing
+	// from the dataset are left out.
+	records, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, record := range records[1:] {
+		ids = append(ids, record[0])
+	}
+	if strings.Join(ids, ",") != "s24,s05" {
+		t.Errorf("extracted %v, want s24 and s05", ids)
+	}
+	if got, want := strings.Join(records[0], ","), "id,a,b,note,label,poison_type,score,confidence,description,evidence,review,review_note"; got != want {
+		t.Errorf("header %s, want %s", got, want)
+	}
+	if note := records[1][3]; note != `batch 3, "suspect"` {
+		t.Errorf("s24 note %q", note)
+	}
+}
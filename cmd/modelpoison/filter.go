@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// filterFlags holds the report filtering flags.
+type filterFlags struct {
+	minSeverity string
+	types       string
+	top         int
+}
+
+// addFilterFlags registers --min-severity, --types and --top on fs.
+func addFilterFlags(fs *flag.FlagSet) *filterFlags {
+	f := &filterFlags{}
+	fs.StringVar(&f.minSeverity, "min-severity", "", "only report findings of at least this severity")
+	fs.StringVar(&f.types, "types", "", "comma-separated poison types to report")
+	fs.IntVar(&f.top, "top", 0, "only report the N highest-scoring findings")
+	return f
+}
+
+// filter returns the filter selected by the flags.
+func (f *filterFlags) filter() (detect.Filter, error) {
+	var filter detect.Filter
+
+	if f.minSeverity != "" {
+		severity, err := detect.ParseSeverity(f.minSeverity)
+		if err != nil {
+			return filter, usagef("--min-severity: %v", err)
+		}
+		filter.MinSeverity = severity
+	}
+
+	checks := make(map[detect.PoisonType]bool)
+	for _, t := range detect.Checks() {
+		checks[t] = true
+	}
+	for _, name := range splitList(f.types) {
+		t := detect.PoisonType(name)
+		if !checks[t] {
+			return filter, usagef("--types: unknown poison type %q", name)
+		}
+		filter.Types = append(filter.Types, t)
+	}
+
+	if f.top < 0 {
+		return filter, usagef("--top must not be negative")
+	}
+	filter.Top = f.top
+
+	return filter, nil
+}
+
+// applyFilter narrows result for reporting, returning it unchanged when
+// the filter keeps every finding.
+func applyFilter(filter detect.Filter, result *detect.DetectionResult) *detect.DetectionResult {
+	if filter.IsZero() {
+		return result
+	}
+	return filter.Apply(result)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/hallucinaut/modelpoison/pkg/fingerprint"
+)
+
+func fingerprintDataset(args []string) error {
+	fs := newFlagSet("fingerprint")
+	columns := addColumnFlags(fs)
+	out := fs.String("out", "", "manifest file to write (default: standard output)")
+	verify := fs.String("verify", "", "verify the datasets, or every shard listed, against this manifest")
+	format := fs.String("format", "text", "verification output format: text or json")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return usagef("invalid format %q (want text or json)", *format)
+	}
+	if *verify != "" {
+		if *out != "" {
+			return usagef("--out does not apply to --verify")
+		}
+		return verifyFingerprint(*verify, positional, columns, *format)
+	}
+	if len(positional) == 0 {
+		return usagef("dataset required")
+	}
+
+	paths, err := expandDatasets(positional)
+	if err != nil {
+		return err
+	}
+	var shards []fingerprint.Shard
+	for _, path := range paths {
+		shard, err := fingerprint.FileShard(commandContext, path, columns.columns())
+		if err != nil {
+			return err
+		}
+		logger.Debugf("fingerprinted %d samples of %s", len(shard.Samples), path)
+		shards = append(shards, shard)
+	}
+	manifest := fingerprint.New(shards)
+
+	if *out == "" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(manifest)
+	}
+	if err := manifest.Save(*out); err != nil {
+		return err
+	}
+	logger.Infof("Wrote the fingerprint of %d samples in %d shards to %s (digest %s)",
+		manifest.SampleCount(), len(manifest.Shards), *out, manifest.Digest)
+	return nil
+}
+
+// verifyFingerprint verifies the datasets at paths, or every shard of the
+// manifest at manifestPath when there are none, against the manifest. It
+// returns an error when any shard was tampered with.
+func verifyFingerprint(manifestPath string, paths []string, columns *columnFlags, format string) error {
+	manifest, err := fingerprint.Load(manifestPath)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		for _, shard := range manifest.Shards {
+			paths = append(paths, shard.Path)
+		}
+	} else if paths, err = expandDatasets(paths); err != nil {
+		return err
+	}
+
+	var shards []fingerprint.ShardVerification
+	for _, path := range paths {
+		expected, ok := manifest.Shard(path)
+		if !ok {
+			shards = append(shards, fingerprint.ShardVerification{Path: path, Status: fingerprint.StatusUnlisted})
+			continue
+		}
+		current, err := fingerprint.FileShard(commandContext, path, columns.columns())
+		if err != nil {
+			shards = append(shards, fingerprint.ShardVerification{Path: path, Status: fingerprint.StatusUnreadable, Error: err.Error()})
+			continue
+		}
+		shards = append(shards, expected.Verify(current))
+	}
+	verification := fingerprint.NewVerification(manifest.Digest, shards)
+
+	if format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(verification); err != nil {
+			return err
+		}
+	} else {
+		fmt.Print(fingerprint.GenerateReport(verification))
+	}
+
+	if !verification.Intact {
+		tampered := 0
+		for _, shard := range verification.Shards {
+			if !shard.Intact() {
+				tampered++
+			}
+		}
+		return fmt.Errorf("%d of %d shards do not match %s", tampered, len(verification.Shards), manifestPath)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hallucinaut/modelpoison/pkg/attack"
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/synthetic"
+)
+
+func generateDataset(args []string) error {
+	fs := newFlagSet("generate")
+	cfg := synthetic.DefaultConfig()
+	fs.IntVar(&cfg.Samples, "samples", cfg.Samples, "samples to generate")
+	fs.IntVar(&cfg.Features, "dims", cfg.Features, "features of each sample")
+	fs.IntVar(&cfg.Classes, "classes", cfg.Classes, "number of classes")
+	fs.Float64Var(&cfg.Spread, "spread", cfg.Spread, "standard deviation of the class means along each feature")
+	fs.Float64Var(&cfg.Noise, "noise", cfg.Noise, "standard deviation of each feature within a class")
+	fs.Float64Var(&cfg.LabelNoise, "label-noise", 0, "fraction of samples given a random label")
+	fs.Int64Var(&cfg.Seed, "seed", cfg.Seed, "random seed")
+	poison := attack.DefaultConfig()
+	attackName := fs.String("attack", "", "attack to inject, as inject (default: a clean dataset)")
+	fs.Float64Var(&poison.Rate, "rate", poison.Rate, "fraction of samples to poison")
+	fs.IntVar(&poison.TargetClass, "target-class", 0, "target class of the attack")
+	out := fs.String("out", "", "dataset to write")
+	manifestPath := fs.String("manifest", "", "write the ground truth of the attack as a JSON manifest to this file")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return usagef("generate takes no arguments")
+	}
+	if *out == "" {
+		return usagef("--out required")
+	}
+	if *manifestPath != "" && *attackName == "" {
+		return usagef("--manifest requires --attack")
+	}
+	if *attackName != "" {
+		poison.Attack = *attackName
+		poison.Seed = cfg.Seed
+		cfg.Poison = &poison
+	}
+
+	data, err := synthetic.Generate(cfg)
+	if err != nil {
+		return err
+	}
+	d := dataset.FromSamples(data.Features, data.Samples)
+	if cfg.Poison != nil {
+		if err := d.SetGroundTruth(data.Poisoned); err != nil {
+			return err
+		}
+	}
+	if err := d.SaveCSV(*out); err != nil {
+		return err
+	}
+	logger.Infof("Wrote %s", *out)
+	if *manifestPath != "" {
+		manifest := attack.NewManifest(poison, len(data.Samples), len(data.Features), data.Injections)
+		if err := attack.SaveManifest(*manifestPath, manifest); err != nil {
+			return err
+		}
+		logger.Infof("Wrote the attack manifest to %s", *manifestPath)
+	}
+
+	fmt.Printf("Samples: %d\n", len(data.Samples))
+	fmt.Printf("Features: %d\n", len(data.Features))
+	fmt.Printf("Classes: %d\n", cfg.Classes)
+	if cfg.Poison != nil {
+		fmt.Printf("Attack: %s\n", poison.Attack)
+		fmt.Printf("Poisoned: %d\n", len(data.Injections))
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/webhook"
+)
+
+// summaryFindings is the number of findings listed in a job summary.
+const summaryFindings = 20
+
+// sampleLines maps the IDs of the samples of a result to the line of the
+// CSV dataset at path their record starts on. It returns nil for other
+// formats, whose samples are not read by line, and when the samples of
+// result are not the leading rows of the dataset in order, as after
+// --sample or --chunk-size.
+func sampleLines(path string, result *detect.DetectionResult) map[string]int {
+	if dataset.IsSVMLight(path) || dataset.IsJSON(path) {
+		return nil
+	}
+	if result.FlaggedOnly || result.Partial != nil && result.Partial.Mode != detect.PartialLimit {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		logger.Debugf("%s: sample lines: %v", path, err)
+		return nil
+	}
+	defer f.Close()
+	records, err := dataset.RecordLines(f)
+	if err != nil {
+		logger.Debugf("%s: sample lines: %v", path, err)
+		return nil
+	}
+	if len(records) < len(result.Samples) {
+		return nil
+	}
+	lines := make(map[string]int, len(result.Samples))
+	for i, sample := range result.Samples {
+		if _, ok := lines[sample.ID]; !ok {
+			lines[sample.ID] = records[i]
+		}
+	}
+	return lines
+}
+
+// writeGitHub writes GitHub Actions workflow commands annotating the
+// flagged samples of result, the highest-scoring first. Findings of high or
+// critical severity are errors; the rest are warnings. lines, if set, gives
+// the dataset line of each sample.
+func writeGitHub(w io.Writer, path string, result *detect.DetectionResult, lines map[string]int) error {
+	file := workspacePath(path)
+	for _, finding := range flaggedByScore(result) {
+		level := "warning"
+		severity := detect.SeverityOf(finding.Score)
+		if severity >= detect.SeverityHigh {
+			level = "error"
+		}
+		props := "file=" + escapeProperty(file)
+		if line := lines[finding.ID]; line > 0 {
+			props += fmt.Sprintf(",line=%d", line)
+		}
+		props += ",title=" + escapeProperty(fmt.Sprintf("%s sample %s (%s)", finding.Type, finding.ID, severity))
+		msg := fmt.Sprintf("Score %.2f, confidence %.2f", finding.Score, finding.Confidence)
+		if finding.Description != "" {
+			msg += ": " + finding.Description
+		}
+		if finding.Evidence != "" {
+			msg += "\n" + finding.Evidence
+		}
+		if _, err := fmt.Fprintf(w, "::%s %s::%s\n", level, props, escapeData(msg)); err != nil {
+			return err
+		}
+	}
+
+	verdict := fmt.Sprintf("::notice file=%s,title=modelpoison::Training data appears clean (%d samples scanned)",
+		escapeProperty(file), result.SampleCount)
+	if result.IsPoisoned {
+		verdict = fmt.Sprintf("::error file=%s,title=modelpoison::Poisoning detected: %d of %d samples flagged, risk score %.2f",
+			escapeProperty(file), result.PoisonedCount, result.SampleCount, result.RiskScore)
+	}
+	_, err := fmt.Fprintln(w, verdict)
+	return err
+}
+
+// writeJobSummary appends a Markdown summary of the scans to the job
+// summary file named by $GITHUB_STEP_SUMMARY, if set. lines holds the
+// sampleLines of each scan.
+func writeJobSummary(scans []datasetScan, lines []map[string]int) error {
+	name := os.Getenv("GITHUB_STEP_SUMMARY")
+	if name == "" {
+		logger.Debugf("GITHUB_STEP_SUMMARY not set, skipping the job summary")
+		return nil
+	}
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, jobSummary(scans, lines)); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// jobSummary returns the Markdown job summary of scans.
+func jobSummary(scans []datasetScan, lines []map[string]int) string {
+	var b strings.Builder
+	b.WriteString("## modelpoison scan\n\n")
+	b.WriteString("| Dataset | Verdict | Samples | Flagged | Risk score | Findings |\n")
+	b.WriteString("|---|---|---:|---:|---:|---|\n")
+	for _, scan := range scans {
+		if scan.Result == nil {
+			fmt.Fprintf(&b, "| `%s` | ❌ failed: %s | | | | |\n", scan.Path, escapeCell(scan.Error))
+			continue
+		}
+		result := scan.Result
+		verdict := "✅ clean"
+		if result.IsPoisoned {
+			verdict = "⚠️ poisoned"
+		}
+		fmt.Fprintf(&b, "| `%s` | %s | %d | %d | %.2f | %s |\n", scan.Path, verdict,
+			result.SampleCount, result.PoisonedCount, result.RiskScore, typeCounts(result))
+	}
+
+	for i, scan := range scans {
+		if scan.Result == nil || !scan.Result.IsPoisoned {
+			continue
+		}
+		findings := flaggedByScore(scan.Result)
+		fmt.Fprintf(&b, "\n### Top findings in `%s`\n\n", scan.Path)
+		b.WriteString("| Sample | Line | Type | Severity | Score | Description |\n")
+		b.WriteString("|---|---:|---|---|---:|---|\n")
+		for n, finding := range findings {
+			if n == summaryFindings {
+				fmt.Fprintf(&b, "\n%d more findings not shown.\n", len(findings)-summaryFindings)
+				break
+			}
+			line := ""
+			if ln := lines[i][finding.ID]; ln > 0 {
+				line = fmt.Sprint(ln)
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %.2f | %s |\n", escapeCell(finding.ID), line, finding.Type,
+				detect.SeverityOf(finding.Score), finding.Score, escapeCell(finding.Description))
+		}
+	}
+	return b.String()
+}
+
+// typeCounts formats the number of findings per type, such as
+// "backdoor 3, label_flip 1".
+func typeCounts(result *detect.DetectionResult) string {
+	types := webhook.Summarize(result).Types
+	counts := make([]string, 0, len(types))
+	for t, n := range types {
+		counts = append(counts, fmt.Sprintf("%s %d", t, n))
+	}
+	sort.Strings(counts)
+	return strings.Join(counts, ", ")
+}
+
+// flaggedByScore returns the flagged samples of result, highest score
+// first.
+func flaggedByScore(result *detect.DetectionResult) []detect.PoisonedSample {
+	var flagged []detect.PoisonedSample
+	for _, sample := range result.Samples {
+		if sample.IsPoisoned {
+			flagged = append(flagged, sample)
+		}
+	}
+	sort.SliceStable(flagged, func(i, j int) bool { return flagged[i].Score > flagged[j].Score })
+	return flagged
+}
+
+// workspacePath returns path relative to $GITHUB_WORKSPACE when it is
+// inside it, as annotations name files relative to the repository root.
+func workspacePath(path string) string {
+	workspace := os.Getenv("GITHUB_WORKSPACE")
+	if workspace == "" {
+		return filepath.ToSlash(path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.ToSlash(path)
+	}
+	rel, err := filepath.Rel(workspace, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// escapeData escapes the message of a workflow command.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// escapeProperty escapes a property value of a workflow command.
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// escapeCell escapes text for a Markdown table cell.
+func escapeCell(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func TestSampleLines(t *testing.T) {
+	dir := t.TempDir()
+	result := func(ids ...string) *detect.DetectionResult {
+		r := &detect.DetectionResult{}
+		for _, id := range ids {
+			r.Samples = append(r.Samples, detect.PoisonedSample{ID: id})
+		}
+		return r
+	}
+	limited := result("row-1")
+	limited.Partial = &detect.PartialScan{Mode: detect.PartialLimit}
+	sampled := result("row-1")
+	sampled.Partial = &detect.PartialScan{Mode: detect.PartialSample}
+
+	for _, test := range []struct {
+		name, file, content string
+		result              *detect.DetectionResult
+		lines               map[string]int
+	}{
+		{"header", "a.csv", "a,label\n1,0\n2,1\n", result("row-1", "row-2"), map[string]int{"row-1": 2, "row-2": 3}},
+		{"headerless", "b.csv", "1.5,0\n2,1\n", result("row-1", "row-2"), map[string]int{"row-1": 1, "row-2": 2}},
+		{"multi-line field", "c.csv", "id,note,a,label\nx,\"two\nlines\",1,0\ny,plain,2,1\n", result("x", "y"), map[string]int{"x": 2, "y": 4}},
+		{"limit", "d.csv", "a,label\n1,0\n2,1\n", limited, map[string]int{"row-1": 2}},
+		{"sample", "e.csv", "a,label\n1,0\n2,1\n", sampled, nil},
+		{"JSON Lines", "f.jsonl", "{\"features\":[1],\"label\":0}\n", result("row-1"), nil},
+		{"more samples than records", "g.csv", "a,label\n1,0\n", result("row-1", "row-2"), nil},
+	} {
+		path := filepath.Join(dir, test.file)
+		if err := os.WriteFile(path, []byte(test.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if lines := sampleLines(path, test.result); !reflect.DeepEqual(lines, test.lines) {
+			t.Errorf("%s: lines %v, want %v", test.name, lines, test.lines)
+		}
+	}
+}
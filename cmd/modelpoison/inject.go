@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hallucinaut/modelpoison/pkg/attack"
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+)
+
+func injectAttack(args []string) error {
+	fs := newFlagSet("inject")
+	columns := addColumnFlags(fs)
+	cfg := attack.DefaultConfig()
+	fs.StringVar(&cfg.Attack, "attack", cfg.Attack, "attack to inject: backdoor, label_flip, clean_label, or a model update attack: sign_flip, scaled, inner_product or model_replacement")
+	fs.Float64Var(&cfg.Rate, "rate", cfg.Rate, "fraction of samples to poison")
+	fs.IntVar(&cfg.TargetClass, "target-class", 0, "label assigned to poisoned samples (-1 for random flips)")
+	fs.StringVar(&cfg.Flip, "flip", "", "label flip strategy: random, targeted or nearest (default: random if --target-class is -1, else targeted)")
+	fs.IntVar(&cfg.SourceClass, "source-class", cfg.SourceClass, "only poison samples of this class with label flips or clean-label attacks (-1 for any class)")
+	fs.Float64Var(&cfg.Epsilon, "epsilon", cfg.Epsilon, "clean-label perturbation budget per feature, in standard deviations")
+	fs.StringVar(&cfg.Victim, "victim", "", "ID of the target-class sample clean-label poisons collide with (default: random)")
+	fs.Float64Var(&cfg.Scale, "scale", 0, "boost of scaled and model replacement updates (default 10, and clients over malicious clients)")
+	clients := fs.Int("clients", 0, "simulate the honest updates of this many clients instead of reading a dataset")
+	params := fs.Int("params", 100, "parameters of each simulated client update")
+	fs.Int64Var(&cfg.Seed, "seed", cfg.Seed, "random seed")
+	fs.StringVar(&cfg.Trigger, "trigger-type", cfg.Trigger, "backdoor trigger: feature, patch or blend")
+	fs.Float64Var(&cfg.TriggerSigma, "trigger-sigma", cfg.TriggerSigma, "feature trigger strength in standard deviations")
+	trigger := fs.String("trigger", "", "comma-separated feature trigger feature indices (default 0,1,2)")
+	fs.IntVar(&cfg.ImageWidth, "image-width", 0, "width of the images flattened into the features, for patch triggers (default: square images)")
+	fs.IntVar(&cfg.PatchSize, "patch-size", cfg.PatchSize, "side of the patch trigger in pixels")
+	fs.Float64Var(&cfg.BlendAlpha, "blend-alpha", cfg.BlendAlpha, "opacity of the blend trigger's pattern")
+	out := fs.String("out", "", "poisoned dataset to write")
+	manifestPath := fs.String("manifest", "", "write the ground truth of the attack as a JSON manifest to this file")
+	idsPath := fs.String("ids", "", "write the IDs of the poisoned samples to this file, one per line")
+	noTruth := fs.Bool("no-truth", false, "do not write the \"poisoned\" ground-truth column")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if *clients > 0 {
+		if len(positional) != 0 {
+			return usagef("--clients simulates the update set instead of a dataset")
+		}
+		if !attack.IsUpdateAttack(cfg.Attack) {
+			return usagef("--clients requires a model update attack")
+		}
+		if *params < 1 {
+			return usagef("--params must be positive")
+		}
+	} else if len(positional) != 1 {
+		return usagef("dataset required")
+	}
+	if *out == "" {
+		return usagef("--out required")
+	}
+	if *trigger != "" && cfg.Trigger != attack.TriggerFeature {
+		return usagef("--trigger selects the features of --trigger-type feature")
+	}
+	if *trigger != "" {
+		for _, field := range strings.Split(*trigger, ",") {
+			index, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				return usagef("invalid trigger feature %q", field)
+			}
+			cfg.TriggerFeatures = append(cfg.TriggerFeatures, index)
+		}
+	}
+
+	var data *dataset.Dataset
+	if *clients > 0 {
+		names := make([]string, *params)
+		for j := range names {
+			names[j] = fmt.Sprintf("p%d", j)
+		}
+		data = dataset.FromSamples(names, attack.SimulateUpdates(*clients, *params, cfg.Seed))
+	} else if data, err = dataset.LoadCSVContext(commandContext, positional[0], columns.columns()); err != nil {
+		return err
+	}
+
+	injections, err := attack.Inject(data.Samples, cfg)
+	if err != nil {
+		return err
+	}
+
+	poisoned := make([]bool, len(data.Samples))
+	copy(poisoned, data.Poisoned)
+	for _, injection := range injections {
+		data.SetSample(injection.Index, data.Samples[injection.Index])
+		poisoned[injection.Index] = true
+	}
+	if !*noTruth {
+		if err := data.SetGroundTruth(poisoned); err != nil {
+			return err
+		}
+	}
+
+	if err := data.SaveCSV(*out); err != nil {
+		return err
+	}
+	logger.Infof("Wrote %s", *out)
+	if *manifestPath != "" {
+		manifest := attack.NewManifest(cfg, len(data.Samples), len(data.Features), injections)
+		if err := attack.SaveManifest(*manifestPath, manifest); err != nil {
+			return err
+		}
+		logger.Infof("Wrote the attack manifest to %s", *manifestPath)
+	}
+	if *idsPath != "" {
+		var ids strings.Builder
+		for _, injection := range injections {
+			fmt.Fprintln(&ids, injection.ID)
+		}
+		if err := os.WriteFile(*idsPath, []byte(ids.String()), 0o644); err != nil {
+			return err
+		}
+		logger.Infof("Wrote the IDs of the poisoned samples to %s", *idsPath)
+	}
+
+	fmt.Printf("Attack: %s\n", cfg.Attack)
+	if cfg.Attack == attack.AttackBackdoor {
+		fmt.Printf("Trigger: %s\n", cfg.Trigger)
+	}
+	if cfg.Attack == attack.AttackLabelFlip {
+		fmt.Printf("Flip: %s\n", attack.FlipStrategy(cfg))
+	}
+	if cfg.Attack == attack.AttackCleanLabel && len(injections) > 0 {
+		fmt.Printf("Victim: %s\n", injections[0].Victim)
+	}
+	fmt.Printf("Samples: %d\n", len(data.Samples))
+	fmt.Printf("Poisoned: %d\n", len(injections))
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/instruct"
+)
+
+// instructionFlags holds the flags naming the fields of instruction-tuning
+// datasets.
+type instructionFlags struct {
+	system, instruction, input, response string
+}
+
+// addInstructionFlags registers the fields of instruction-tuning datasets
+// on fs.
+func addInstructionFlags(fs *flag.FlagSet) *instructionFlags {
+	f := &instructionFlags{}
+	fs.StringVar(&f.instruction, "instruction-col", "", "field of the instruction of instruction records (default instruction or prompt)")
+	fs.StringVar(&f.input, "input-col", "", "field of the input of instruction records (default input, if present)")
+	fs.StringVar(&f.response, "response-col", "", "field of the response of instruction records (default output, response or completion)")
+	fs.StringVar(&f.system, "system-col", "", "field of the system prompt of instruction records (default system, if present)")
+	return f
+}
+
+// set reports whether any field of instruction records was named.
+func (f *instructionFlags) set() bool {
+	return f.columns("") != instruct.Columns{}
+}
+
+// columns returns the fields of instruction records, with IDs in the id
+// field.
+func (f *instructionFlags) columns(id string) instruct.Columns {
+	return instruct.Columns{ID: id, System: f.system, Instruction: f.instruction, Input: f.input, Response: f.response}
+}
+
+// scanInstructions analyzes the instruction records of the dataset at
+// path, flagging records above the thresholds of the detector configured
+// by opts.
+func scanInstructions(path string, opts scanOptions, log *cliLogger) (*detect.DetectionResult, error) {
+	records, err := instruct.Load(path, opts.instruction)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("loaded %d instruction records from %s", len(records), path)
+	detector, err := newDetector(opts.config)
+	if err != nil {
+		return nil, err
+	}
+	analysis := instruct.Analyze(records, detector.Thresholds())
+	result := detector.Summarize(analysis.Findings, len(records))
+	result.Method = instruct.Method
+	return result, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hallucinaut/modelpoison/pkg/defend"
+)
+
+func listDetectors(args []string) error {
+	fs := newFlagSet("list-detectors")
+	format := fs.String("format", "text", "output format: text or json")
+	configPath := fs.String("config", "", "detector configuration file")
+	scripts := addScriptFlags(fs)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usagef("unexpected argument %q", positional[0])
+	}
+	if *format != "text" && *format != "json" {
+		return usagef("invalid format %q (want text or json)", *format)
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	detector, err := newDetector(cfg)
+	if err != nil {
+		return err
+	}
+	opts := scanOptions{}
+	if opts.scripts, err = scripts.load(); err != nil {
+		return err
+	}
+	if _, err := addScripts(detector, opts, nil); err != nil {
+		return err
+	}
+	checks := detector.Describe()
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(checks)
+	}
+
+	fmt.Println("=== Detectors ===")
+	for _, check := range checks {
+		if check.Name != "" {
+			fmt.Printf("\n%s (%s)\n", check.Name, check.Type)
+			fmt.Printf("  %s\n", check.Description)
+			if check.Plugin != "" {
+				fmt.Printf("  Plugin: %s\n", check.Plugin)
+			}
+		} else {
+			fmt.Printf("\n%s\n", check.Type)
+			fmt.Printf("  %s\n", check.Description)
+		}
+		fmt.Printf("  Method: %s\n", check.Method)
+		fmt.Printf("  Data Types: %s\n", strings.Join(check.DataTypes, ", "))
+		fmt.Printf("  Requires Model: %s, Requires Activations: %s\n", yesNo(check.RequiresModel), yesNo(check.RequiresActivations))
+		for _, p := range check.Parameters {
+			fmt.Printf("  Parameter %s = %g (%g..%g): %s\n", p.Name, p.Value, p.Min, p.Max, p.Description)
+		}
+		for _, technique := range check.ATLAS {
+			fmt.Printf("  MITRE ATLAS %s: %s\n", technique.ID, technique.Name)
+		}
+	}
+	return nil
+}
+
+// yesNo formats a boolean for text listings.
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func listStrategies(args []string) error {
+	fs := newFlagSet("list-strategies")
+	format := fs.String("format", "text", "output format: text or json")
+	strategyType := fs.String("type", "", "only list strategies of this type")
+	maxOverhead := fs.Float64("max-overhead", -1, "only list strategies with at most this overhead")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usagef("unexpected argument %q", positional[0])
+	}
+	if *format != "text" && *format != "json" {
+		return usagef("invalid format %q (want text or json)", *format)
+	}
+
+	defender, err := newDefender()
+	if err != nil {
+		return err
+	}
+	strategies := []defend.DefenseStrategy{}
+	for _, strategy := range defender.Strategies() {
+		if *strategyType != "" && !strings.EqualFold(strategy.Type, *strategyType) {
+			continue
+		}
+		if *maxOverhead >= 0 && strategy.Overhead > *maxOverhead {
+			continue
+		}
+		strategies = append(strategies, strategy)
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(strategies)
+	}
+
+	fmt.Println("=== Defense Strategies ===")
+	if len(strategies) == 0 {
+		fmt.Println("\nNo strategies match.")
+		return nil
+	}
+	for _, strategy := range strategies {
+		fmt.Printf("\n%s (%s)\n", strategy.Name, strategy.Type)
+		fmt.Printf("  %s\n", strategy.Description)
+		if strategy.Plugin != "" {
+			fmt.Printf("  Plugin: %s\n", strategy.Plugin)
+		}
+		fmt.Printf("  Effectiveness: %.0f%%, Overhead: %.0f%%\n", strategy.Effectiveness*100, strategy.Overhead*100)
+		for _, p := range strategy.Parameters {
+			fmt.Printf("  Parameter %s = %g: %s\n", p.Name, p.Value, p.Description)
+		}
+		if len(strategy.Constraints) > 0 {
+			fmt.Printf("  Constraints: %s\n", strings.Join(strategy.Constraints, "; "))
+		}
+	}
+	return nil
+}
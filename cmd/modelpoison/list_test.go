@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/defend"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func TestListStrategies(t *testing.T) {
+	t.Setenv("MODELPOISON_AUDIT_LOG", "")
+	for _, test := range []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"all", nil, []string{"Data Cleaning", "Robust Aggregation", "Input Filtering", "Adversarial Training", "Outlier Detection", "Ensemble Defense"}},
+		{"type", []string{"--type", "training"}, []string{"Adversarial Training"}},
+		{"type ignores case", []string{"--type", "Filtering"}, []string{"Input Filtering"}},
+		{"max overhead", []string{"--max-overhead", "0.15"}, []string{"Robust Aggregation", "Input Filtering", "Outlier Detection"}},
+		{"type and max overhead", []string{"--type", "preprocessing", "--max-overhead", "0.1"}, []string{}},
+		{"zero max overhead", []string{"--max-overhead", "0"}, []string{}},
+		{"unknown type", []string{"--type", "prayer"}, []string{}},
+	} {
+		stdout, err := captureStdout(t, func() error {
+			return listStrategies(append([]string{"--format", "json"}, test.args...))
+		})
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		var strategies []defend.DefenseStrategy
+		if err := json.Unmarshal([]byte(stdout), &strategies); err != nil {
+			t.Errorf("%s: %v: %s", test.name, err, stdout)
+			continue
+		}
+		// An empty listing is an array, not null.
+		names := []string{}
+		for _, strategy := range strategies {
+			names = append(names, strategy.Name)
+		}
+		if !reflect.DeepEqual(names, test.want) {
+			t.Errorf("%s: listed %q, want %q", test.name, names, test.want)
+		}
+	}
+
+	stdout, err := captureStdout(t, func() error { return listStrategies([]string{"--type", "prayer"}) })
+	if err != nil || !strings.Contains(stdout, "No strategies match.") {
+		t.Errorf("text listing without matches = %q, %v", stdout, err)
+	}
+	if _, err := captureStdout(t, func() error { return listStrategies([]string{"--format", "yaml"}) }); err == nil {
+		t.Error("listed strategies as yaml")
+	}
+}
+
+func TestListDetectors(t *testing.T) {
+	t.Setenv("MODELPOISON_AUDIT_LOG", "")
+	dir := t.TempDir()
+	typo := filepath.Join(dir, "typos.star")
+	if err := os.WriteFile(typo, []byte("def score(sample):\n    return 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	builtin := []string{"backdoor", "label_flip", "gradient_poison", "feature_poison"}
+
+	for _, test := range []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"built in", nil, builtin},
+		{"with a script", []string{"--script", typo}, append(append([]string{}, builtin...), "data_poison typos")},
+	} {
+		stdout, err := captureStdout(t, func() error {
+			return listDetectors(append([]string{"--format", "json"}, test.args...))
+		})
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		var checks []detect.CheckInfo
+		if err := json.Unmarshal([]byte(stdout), &checks); err != nil {
+			t.Errorf("%s: %v: %s", test.name, err, stdout)
+			continue
+		}
+		var got []string
+		for _, check := range checks {
+			got = append(got, strings.TrimSpace(string(check.Type)+" "+check.Name))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: listed %q, want %q", test.name, got, test.want)
+		}
+	}
+
+	stdout, err := captureStdout(t, func() error { return listDetectors([]string{"--script", typo}) })
+	if err != nil || !strings.Contains(stdout, "\ntypos (data_poison)\n  Flagged by script typos\n") {
+		t.Errorf("text listing = %q, %v; want the script's check", stdout, err)
+	}
+	if _, err := captureStdout(t, func() error { return listDetectors([]string{"--format", "yaml"}) }); err == nil {
+		t.Error("listed detectors as yaml")
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/hallucinaut/modelpoison/pkg/allowlist"
+)
+
+// listFlags holds the allowlist and denylist flags of commands that scan
+// datasets.
+type listFlags struct {
+	allow string
+	deny  string
+}
+
+// addListFlags registers --allowlist and --denylist on fs.
+func addListFlags(fs *flag.FlagSet) *listFlags {
+	f := &listFlags{}
+	fs.StringVar(&f.allow, "allowlist", "", "do not flag samples reviewed as false positives in this allowlist, by ID or content hash")
+	fs.StringVar(&f.deny, "denylist", "", "flag samples whose ID, content hash or source is in this denylist")
+	return f
+}
+
+// apply loads the lists named by the flags into opts. The column of the
+// samples' sources is taken from opts.columns, so column flags are applied
+// first.
+func (f *listFlags) apply(opts *scanOptions) error {
+	if f.allow == "" && f.deny == "" {
+		return nil
+	}
+	var lists allowlist.Lists
+	if f.allow != "" {
+		// Unlike the review commands, which create the allowlist, a scan
+		// given a missing allowlist has most likely been given a typo.
+		if _, err := os.Stat(f.allow); err != nil {
+			return err
+		}
+		allow, err := allowlist.Load(f.allow)
+		if err != nil {
+			return err
+		}
+		logger.Debugf("loaded %d review decisions from %s", allow.Len(), f.allow)
+		lists.Allow = allow
+	}
+	if f.deny != "" {
+		deny, err := allowlist.LoadDenylist(f.deny)
+		if err != nil {
+			return err
+		}
+		logger.Debugf("loaded %d denylist entries from %s", deny.Len(), f.deny)
+		if sources := deny.Sources(); len(sources) > 0 && opts.columns.Source == "" {
+			logger.Warnf("%s denies sources (%s), which are matched only with --source-col", f.deny, strings.Join(sources, ", "))
+		}
+		lists.Deny = deny
+	}
+	opts.lists = lists
+	return nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hallucinaut/modelpoison/internal/id"
+)
+
+// Log formats.
+const (
+	logFormatText   = "text"
+	logFormatLogfmt = "logfmt"
+	logFormatJSON   = "json"
+)
+
+// logLevelNames maps log level names to levels.
+var logLevelNames = map[string]slog.Level{
+	"error": slog.LevelError,
+	"warn":  slog.LevelWarn,
+	"info":  slog.LevelInfo,
+	"debug": slog.LevelDebug,
+}
+
+// parseLogLevel parses a log level name.
+func parseLogLevel(name string) (slog.Level, error) {
+	level, ok := logLevelNames[strings.ToLower(name)]
+	if !ok {
+		return slog.LevelInfo, fmt.Errorf("invalid log level %q (want error, warn, info or debug)", name)
+	}
+	return level, nil
+}
+
+// cliLogger writes leveled diagnostics through log/slog, keeping them apart
+// from results.
+type cliLogger struct {
+	level  *slog.LevelVar
+	format string
+	out    io.Writer
+	slog   *slog.Logger
+}
+
+// runID identifies this invocation on every log record. It is taken from
+// MODELPOISON_RUN_ID when set, so a job runner can correlate its own logs.
+var runID = newRunID()
+
+// logger receives all diagnostics; results go to stdout.
+var logger = newCLILogger(os.Stderr, logFormatText)
+
+// newRunID returns the run ID of this invocation.
+func newRunID() string {
+	if id := os.Getenv("MODELPOISON_RUN_ID"); id != "" {
+		return id
+	}
+	return id.New()
+}
+
+// newCLILogger creates a logger writing records in format to out.
+func newCLILogger(out io.Writer, format string) *cliLogger {
+	level := new(slog.LevelVar)
+	var handler slog.Handler
+	switch format {
+	case logFormatJSON:
+		handler = slog.NewJSONHandler(out, &slog.HandlerOptions{Level: level})
+	case logFormatLogfmt:
+		handler = slog.NewTextHandler(out, &slog.HandlerOptions{Level: level})
+	default:
+		handler = &textHandler{level: level, out: out, mu: new(sync.Mutex)}
+	}
+
+	return &cliLogger{
+		level:  level,
+		format: format,
+		out:    out,
+		slog:   slog.New(handler).With("run_id", runID),
+	}
+}
+
+// With returns a logger that adds args as attributes to every record.
+func (l *cliLogger) With(args ...any) *cliLogger {
+	return &cliLogger{level: l.level, format: l.format, out: l.out, slog: l.slog.With(args...)}
+}
+
+// Enabled reports whether records at level are written.
+func (l *cliLogger) Enabled(level slog.Level) bool {
+	return level >= l.level.Level()
+}
+
+// Log logs a structured record with args as attributes.
+func (l *cliLogger) Log(level slog.Level, msg string, args ...any) {
+	l.slog.Log(context.Background(), level, msg, args...)
+}
+
+func (l *cliLogger) logf(level slog.Level, format string, args ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	l.slog.Log(context.Background(), level, fmt.Sprintf(format, args...))
+}
+
+// Debugf logs a debug message.
+func (l *cliLogger) Debugf(format string, args ...interface{}) {
+	l.logf(slog.LevelDebug, format, args...)
+}
+
+// Infof logs an informational message.
+func (l *cliLogger) Infof(format string, args ...interface{}) {
+	l.logf(slog.LevelInfo, format, args...)
+}
+
+// Warnf logs a warning.
+func (l *cliLogger) Warnf(format string, args ...interface{}) {
+	l.logf(slog.LevelWarn, format, args...)
+}
+
+// Errorf logs an error.
+func (l *cliLogger) Errorf(format string, args ...interface{}) {
+	l.logf(slog.LevelError, format, args...)
+}
+
+// textHandler writes records as human-readable lines: the message with a
+// level prefix, followed by the record's attributes as key=value pairs.
+// Attributes added with With, such as the run ID, are left to the
+// structured formats.
+type textHandler struct {
+	level slog.Leveler
+	out   io.Writer
+	mu    *sync.Mutex
+}
+
+func (h *textHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *textHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	switch {
+	case r.Level >= slog.LevelError:
+		b.WriteString("error: ")
+	case r.Level >= slog.LevelWarn:
+		b.WriteString("warning: ")
+	case r.Level < slog.LevelInfo:
+		b.WriteString("debug: ")
+	}
+	b.WriteString(r.Message)
+	r.Attrs(func(a slog.Attr) bool {
+		fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+		return true
+	})
+	b.WriteString("\n")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.out, b.String())
+	return err
+}
+
+func (h *textHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *textHandler) WithGroup(string) slog.Handler      { return h }
+
+// verbosity holds the verbosity flags shared by every command.
+type verbosity struct {
+	quiet     bool
+	verbose   bool
+	logLevel  string
+	logFormat string
+}
+
+// globalVerbosity is bound to the verbosity flags of every flag set.
+var globalVerbosity = verbosity{logFormat: logFormatText}
+
+// addVerbosityFlags registers -q/--quiet, -v/--verbose, --log-level and
+// --log-format on fs.
+func addVerbosityFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&globalVerbosity.quiet, "q", globalVerbosity.quiet, "only print results and errors")
+	fs.BoolVar(&globalVerbosity.quiet, "quiet", globalVerbosity.quiet, "only print results and errors")
+	fs.BoolVar(&globalVerbosity.verbose, "v", globalVerbosity.verbose, "print debug diagnostics")
+	fs.BoolVar(&globalVerbosity.verbose, "verbose", globalVerbosity.verbose, "print debug diagnostics")
+	fs.StringVar(&globalVerbosity.logLevel, "log-level", globalVerbosity.logLevel, "diagnostic level: error, warn, info, debug")
+	fs.StringVar(&globalVerbosity.logFormat, "log-format", globalVerbosity.logFormat, "diagnostic format: text, logfmt or json")
+}
+
+// applyVerbosity configures the logger from the parsed verbosity flags.
+// An explicit --log-level takes precedence over -q and -v.
+func applyVerbosity() error {
+	v := globalVerbosity
+	if v.quiet && v.verbose {
+		return fmt.Errorf("--quiet and --verbose are mutually exclusive")
+	}
+
+	switch v.logFormat {
+	case logFormatText, logFormatLogfmt, logFormatJSON:
+	default:
+		return fmt.Errorf("invalid log format %q (want text, logfmt or json)", v.logFormat)
+	}
+	if v.logFormat != logger.format {
+		logger = newCLILogger(logger.out, v.logFormat)
+	}
+	slog.SetDefault(logger.slog)
+
+	switch {
+	case v.logLevel != "":
+		level, err := parseLogLevel(v.logLevel)
+		if err != nil {
+			return err
+		}
+		logger.level.Set(level)
+	case v.quiet:
+		logger.level.Set(slog.LevelError)
+	case v.verbose:
+		logger.level.Set(slog.LevelDebug)
+	default:
+		logger.level.Set(slog.LevelInfo)
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// logAll logs a record at each level through l.
+func logAll(l *cliLogger) {
+	l.Debugf("d %d", 1)
+	l.Infof("i %d", 2)
+	l.Warnf("w %d", 3)
+	l.Log(slog.LevelError, "e", "dataset", "a.csv")
+}
+
+func TestCLILoggerText(t *testing.T) {
+	for _, test := range []struct {
+		level slog.Level
+		want  string
+	}{
+		{slog.LevelDebug, "debug: d 1\ni 2\nwarning: w 3\nerror: e dataset=a.csv\n"},
+		{slog.LevelInfo, "i 2\nwarning: w 3\nerror: e dataset=a.csv\n"},
+		{slog.LevelWarn, "warning: w 3\nerror: e dataset=a.csv\n"},
+		{slog.LevelError, "error: e dataset=a.csv\n"},
+	} {
+		var b strings.Builder
+		l := newCLILogger(&b, logFormatText)
+		l.level.Set(test.level)
+		// The run ID and other With attributes only go to structured formats.
+		logAll(l.With("command", "detect"))
+		if b.String() != test.want {
+			t.Errorf("%s: logged %q, want %q", test.level, b.String(), test.want)
+		}
+	}
+}
+
+func TestCLILoggerStructured(t *testing.T) {
+	for _, test := range []struct {
+		format string
+		level  slog.Level
+		want   []string
+	}{
+		{logFormatJSON, slog.LevelDebug, []string{"DEBUG d 1", "INFO i 2", "WARN w 3", "ERROR e"}},
+		{logFormatJSON, slog.LevelWarn, []string{"WARN w 3", "ERROR e"}},
+		{logFormatLogfmt, slog.LevelInfo, []string{"INFO i 2", "WARN w 3", "ERROR e"}},
+		{logFormatLogfmt, slog.LevelError, []string{"ERROR e"}},
+	} {
+		name := test.format + " " + test.level.String()
+		var b strings.Builder
+		l := newCLILogger(&b, test.format)
+		l.level.Set(test.level)
+		logAll(l.With("command", "detect"))
+
+		lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+		if len(lines) != len(test.want) {
+			t.Errorf("%s: logged %q, want %d records", name, b.String(), len(test.want))
+			continue
+		}
+		for i, line := range lines {
+			record := parseLogRecord(t, test.format, line)
+			if got := record["level"] + " " + record["msg"]; got != test.want[i] {
+				t.Errorf("%s: record %d is %q, want %q", name, i, got, test.want[i])
+			}
+			if record["run_id"] != runID || record["command"] != "detect" || record["time"] == "" {
+				t.Errorf("%s: record %q lacks the time, run ID or command", name, line)
+			}
+			if strings.HasPrefix(test.want[i], "ERROR") && record["dataset"] != "a.csv" {
+				t.Errorf("%s: record %q lacks its dataset attribute", name, line)
+			}
+		}
+	}
+}
+
+// parseLogRecord returns the top-level fields of a JSON or logfmt record.
+// Of the logfmt values in these tests, only messages with spaces are quoted.
+func parseLogRecord(t *testing.T, format, line string) map[string]string {
+	t.Helper()
+	record := make(map[string]string)
+	if format == logFormatJSON {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		for k, v := range fields {
+			record[k], _ = v.(string)
+		}
+		return record
+	}
+	for _, field := range strings.Fields(line) {
+		k, v, _ := strings.Cut(field, "=")
+		record[k] = v
+	}
+	if msg := record["msg"]; strings.HasPrefix(msg, `"`) {
+		start := strings.Index(line, `msg="`) + len(`msg="`)
+		record["msg"] = line[start : start+strings.Index(line[start:], `"`)]
+	}
+	return record
+}
+
+func TestApplyVerbosity(t *testing.T) {
+	saved, savedVerbosity, savedDefault := logger, globalVerbosity, slog.Default()
+	defer func() {
+		logger, globalVerbosity = saved, savedVerbosity
+		slog.SetDefault(savedDefault)
+	}()
+
+	for _, test := range []struct {
+		name      string
+		verbosity verbosity
+		format    string
+		level     slog.Level
+		err       string
+	}{
+		{"defaults", verbosity{logFormat: logFormatText}, logFormatText, slog.LevelInfo, ""},
+		{"quiet", verbosity{quiet: true, logFormat: logFormatText}, logFormatText, slog.LevelError, ""},
+		{"verbose", verbosity{verbose: true, logFormat: logFormatJSON}, logFormatJSON, slog.LevelDebug, ""},
+		{"level over quiet", verbosity{quiet: true, logLevel: "WARN", logFormat: logFormatLogfmt}, logFormatLogfmt, slog.LevelWarn, ""},
+		{"quiet and verbose", verbosity{quiet: true, verbose: true, logFormat: logFormatText}, "", 0, "mutually exclusive"},
+		{"unknown level", verbosity{logLevel: "trace", logFormat: logFormatText}, "", 0, `invalid log level "trace"`},
+		{"unknown format", verbosity{logFormat: "xml"}, "", 0, `invalid log format "xml"`},
+	} {
+		logger = newCLILogger(saved.out, logFormatText)
+		globalVerbosity = test.verbosity
+		err := applyVerbosity()
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: error %v, want %q", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if logger.format != test.format || logger.level.Level() != test.level {
+			t.Errorf("%s: format %s, level %s; want %s, %s", test.name, logger.format, logger.level.Level(), test.format, test.level)
+		}
+		if slog.Default() != logger.slog {
+			t.Errorf("%s: slog default is not the CLI logger", test.name)
+		}
+	}
+}
@@ -1,126 +1,739 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
-	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/audit"
 	"github.com/hallucinaut/modelpoison/pkg/defend"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
 )
 
 const version = "1.0.0"
 
+// usageError reports a command-line usage error.
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string { return e.err.Error() }
+func (e usageError) Unwrap() error { return e.err }
+
+// usagef creates a usage error.
+func usagef(format string, args ...interface{}) error {
+	return usageError{err: fmt.Errorf(format, args...)}
+}
+
 func main() {
-	if len(os.Args) < 2 {
-		printUsage()
+	root := flag.NewFlagSet("modelpoison", flag.ContinueOnError)
+	root.SetOutput(io.Discard)
+	addVerbosityFlags(root)
+	addProfilingFlags(root)
+	addTracingFlags(root)
+	addAuditFlags(root)
+	addPluginFlags(root)
+	if err := root.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			printUsage(os.Stdout)
+			return
+		}
+		logger.Errorf("%v", err)
+		printUsage(os.Stderr)
+		os.Exit(2)
+	}
+	if err := applyVerbosity(); err != nil {
+		logger.Errorf("%v", err)
+		os.Exit(2)
+	}
+
+	args := root.Args()
+	if len(args) < 1 {
+		printUsage(os.Stdout)
 		return
 	}
 
-	switch os.Args[1] {
-	case "detect":
-		if len(os.Args) < 3 {
-			fmt.Println("Error: dataset required")
-			printUsage()
-			return
+	err := run(args[0], args[1:])
+	closePlugins()
+	stopProfiling()
+	stopTracing(err)
+	if err != nil {
+		var usageErr usageError
+		if errors.As(err, &usageErr) {
+			printUsage(os.Stderr)
+			os.Exit(2)
 		}
-		detectPoisoning(os.Args[2])
+		os.Exit(1)
+	}
+}
+
+// run dispatches a command and logs its error, if any.
+func run(command string, args []string) error {
+	var err error
+
+	switch command {
+	case "detect":
+		err = detectPoisoning(args)
 	case "defend":
-		if len(os.Args) < 3 {
-			fmt.Println("Error: dataset required")
-			printUsage()
-			return
-		}
-		defendModel(os.Args[2])
+		err = defendModel(args)
+	case "annotate":
+		err = annotateResult(args)
+	case "audit":
+		err = manageAudit(args)
+	case "benchmark":
+		err = runBenchmark(args)
+	case "custody":
+		err = showCustody(args)
+	case "daemon":
+		err = runDaemon(args)
+	case "clean":
+		err = cleanDataset(args)
+	case "compare":
+		err = compareDatasets(args)
+	case "component":
+		err = runComponent(args)
+	case "diff":
+		err = diffResults(args)
+	case "evaluate":
+		err = evaluateDetectors(args)
+	case "explore":
+		err = exploreResult(args)
+	case "fingerprint":
+		err = fingerprintDataset(args)
+	case "generate":
+		err = generateDataset(args)
+	case "merge":
+		err = mergeShardResults(args)
+	case "extract":
+		err = extractFlagged(args)
+	case "inject":
+		err = injectAttack(args)
+	case "list-detectors":
+		err = listDetectors(args)
+	case "list-strategies":
+		err = listStrategies(args)
+	case "policy":
+		err = evaluatePolicy(args)
+	case "quarantine":
+		err = manageQuarantine(args)
+	case "report":
+		err = renderReport(args)
+	case "serve":
+		err = serveAPI(args)
+	case "stats":
+		err = profileDataset(args)
+	case "trends":
+		err = showTrends(args)
+	case "tune":
+		err = tuneThresholds(args)
+	case "validate":
+		err = validateDataset(args)
+	case "watch":
+		err = watchDirectory(args)
 	case "analyze":
-		analyzeSecurity()
+		err = analyzeSecurity(args)
 	case "recommend":
-		recommendDefense()
+		err = recommendDefense(args)
 	case "version":
 		fmt.Printf("modelpoison version %s\n", version)
 	case "help", "--help", "-h":
-		printUsage()
+		printUsage(os.Stdout)
 	default:
-		fmt.Printf("Unknown command: %s\n", os.Args[1])
-		printUsage()
+		err = usagef("unknown command: %s", command)
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		printUsage(os.Stdout)
+		return nil
 	}
+	if err != nil {
+		logger.Errorf("%v", err)
+	}
+	return err
 }
 
-func printUsage() {
-	fmt.Printf(`modelpoison - AI Model Poisoning Detector
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, `modelpoison - AI Model Poisoning Detector
 
 Usage:
-  modelpoison <command> [options]
+  modelpoison [global options] <command> [options]
 
 Commands:
-  detect <dataset>   Detect poisoning in training data
-  defend <dataset>   Apply defense to protect model
-  analyze            Analyze security posture
+  detect <dataset>...
+                     Detect poisoning in training data (CSV, JSON, JSON Lines
+                     or SVMlight paths or globs, or text datasets with --mode)
+  defend <dataset>   Apply defense to protect model, sized to the dataset's
+                     poisoning risk
+  annotate <result>  Merge review decisions into a result and the allowlist
+  audit <verify|export> [log]
+                     Verify or export the tamper-evident audit log
+  benchmark [dataset...]
+                     Measure detectors and defenses on synthetic and labeled data
+  clean <dataset>    Remove poisoned samples, writing a sanitized dataset
+  custody <sample-id>...
+                     Show what happened to samples, from a result store
+  daemon             Run queued scan jobs submitted by API or a watched directory
+  compare <old> <new>
+                     Compare two dataset versions for drift and new poisoning
+  component          Scan a dataset as a Kubeflow Pipelines or Argo Workflows step
+  diff <old> <new>   Compare two saved detection results (JSON)
+  evaluate <dataset>...
+                     Score detectors (precision, recall, F1, AUROC, latency)
+                     against attacks injected into datasets
+  explore <result>   Browse and review findings in a terminal UI
+  extract <result> <dataset>
+                     Write the flagged rows of a dataset with their evidence
+  fingerprint <dataset>...
+                     Write a content-hash manifest of a dataset's shards and
+                     samples, or verify a dataset against one (exit 1 if changed)
+  generate           Generate a synthetic labeled dataset, optionally poisoned
+  inject <dataset>   Inject a simulated attack for testing detectors
+  list-detectors     List detection methods and their parameters
+  list-strategies    List defense strategies, optionally filtered
+  merge <result>...  Merge the results of a scan's shards into one result
+  policy <result>    Evaluate a saved result against Rego policies (exit 1 if denied)
+  quarantine <list|restore|purge>
+                     Manage samples quarantined by clean
+  report <result>    Render a saved detection result (text, json, html or compliance)
+  serve              Serve the HTTP API for scans and defenses
+  stats <dataset>    Profile feature distributions, classes and duplicates
+  trends <dataset-id>
+                     Show a dataset's findings across scans recorded by serve
+  tune               Optimize detector thresholds against labeled data
+  validate <dataset> Check a dataset against a schema before scanning
+  watch <dir>        Scan new or modified datasets in a directory
+  analyze            Analyze security posture and MITRE ATLAS coverage
   recommend          Recommend defense strategies
   version            Show version information
   help               Show this help message
 
+Global Options:
+  -q, --quiet        Only print results and errors
+  -v, --verbose      Print debug diagnostics
+  --log-level LEVEL  Diagnostic level: error, warn, info, debug
+  --log-format FMT   Diagnostic format: text, logfmt or json (default text)
+  --cpuprofile FILE  Write a CPU profile (pprof) to FILE
+  --memprofile FILE  Write a heap profile (pprof) to FILE on exit
+  --trace FILE       Write an execution trace to FILE
+  --otlp-endpoint HOST:PORT
+                     Export OpenTelemetry traces to an OTLP/gRPC collector
+  --otlp-insecure    Connect to the OTLP collector without TLS
+  --audit-log FILE   Append scans, defenses, config changes, allowlist edits and
+                     quarantine restores to a hash-chained audit log
+                     (default $MODELPOISON_AUDIT_LOG)
+  --plugin-dir DIR   Load detector and defense plugins from the executables in DIR
+                     (default $MODELPOISON_PLUGIN_DIR)
+  --                 End the options: later arguments, such as paths starting
+                     with "-", are read as arguments
+
+Column Options (commands that load datasets):
+  --features COLS    Comma-separated feature columns (default: all other columns)
+  --label-col NAME   Label column (default "label", else the last column)
+  --id-col NAME      Sample ID column (default "id")
+  --source-col NAME  Column naming each sample's source or contributor, matched
+                     by denylists rather than read as a feature
+  --ignore-cols COLS Comma-separated columns to skip, such as timestamps or text
+  --features-field NAME
+                     Field of JSON samples holding their feature vector, for
+                     detect and defend (default "features", if present)
+  --label-field NAME Label field of JSON samples (same as --label-col)
+
+Detect Options:
+  --no-progress      Disable progress reporting
+  --format FORMAT    Output format: text, json or github (Actions annotations
+                     and job summary) (default text)
+  --config FILE      Detector configuration file (YAML)
+  --policy-file FILE Policy rules acting on each scan: warn, fail, quarantine,
+                     auto_clean or notify (YAML)
+  --allowlist FILE   Do not flag samples reviewed as false positives, by ID or
+                     content hash (allowlist JSON or a text list)
+  --denylist FILE    Flag samples whose ID, content hash or source is listed
+  --script FILES     Comma-separated Starlark scripts scoring samples, clearing
+                     findings or failing scans (not with --shard)
+  --script-timeout D Cancel a script running longer than this in one call
+                     (default 1s)
+  --provenance FILE  W3C PROV-JSON provenance of the datasets, tracing findings
+                     to their origin and flagging anomalous lineage (default:
+                     each dataset's .prov.json sidecar)
+  --image-cols, --text-cols COLS
+                     Features (names or globs such as img_*) of the image and
+                     text of paired samples, checked for cross-modal
+                     consistency (not with --chunk-size or --shard)
+  --caption-col NAME Caption text of paired samples, for trigger phrases
+  --embed-model FILE Scan the embeddings of samples by this ONNX model
+                     instead of their features (builds with -tags onnx; not
+                     with --chunk-size or --shard)
+  --onnx-provider P  Execution provider of --embed-model: cpu (default),
+                     cuda, tensorrt, coreml or directml
+  --onnx-device N    GPU of the cuda, tensorrt and directml providers
+  --onnx-library FILE
+                     ONNX Runtime shared library (default $ONNXRUNTIME_LIB)
+  --embed-batch N    Samples embedded at once (default 256)
+  --mode MODE        Dataset mode: tabular (default); preference for CSV or
+                     JSON Lines comparisons of a chosen and a rejected response
+                     to a prompt, checked for inverted preferences, annotator
+                     bias and reward hacking; or instruction for
+                     instruction-tuning records, checked for embedded prompt
+                     injection
+  --prompt-col, --chosen-col, --rejected-col NAME
+                     Fields of preference pairs (default prompt, chosen and
+                     rejected)
+  --annotator-col, --chosen-score-col, --rejected-score-col NAME
+                     Optional fields of the annotator and reference scores of
+                     preference pairs (default annotator, chosen_score and
+                     rejected_score, when present)
+  --instruction-col, --input-col, --response-col, --system-col NAME
+                     Fields of instruction records (default instruction or
+                     prompt, input, output, response or completion, and system;
+                     or a messages field of chat turns)
+  --parallel N       Scan up to N datasets concurrently (default 1)
+  --workers N        Analyze the samples of each dataset with N workers
+                     (default GOMAXPROCS)
+  --chunk-size N     Stream datasets through the detector N samples at a time
+                     in bounded memory; the result lists only flagged samples
+  --max-memory SIZE  Scan within a memory budget such as 512MB or 2GB, chunking
+                     datasets too large to load and skipping their multi-modal
+                     checks; fails if it cannot fit
+  --limit N          Only scan the first N samples (partial scan)
+  --sample F         Only scan a random fraction F of samples (partial scan)
+  --seed N           Random seed for --sample (default 1)
+  --save-result FILE Also save the raw result as JSON for later reports
+  --incremental FILE Reuse findings of samples unchanged since the result in
+                     FILE; save the result there for the next scan
+  --non-finite MODE  Handle NaN and infinite features: skip them, flag samples
+                     with them as suspicious, or error (default skip)
+  --categorical COLS Comma-separated columns of categories to encode as
+                     features
+  --encode METHOD    Encode categorical columns: onehot (default), ordinal,
+                     frequency or hashing
+  --encoder FILE     Apply the encoder saved in FILE, or fit one and save its
+                     vocabularies there for later scans and clean
+  --scale METHOD     Scale features before scanning: none, zscore, minmax or
+                     robust (median and interquartile range)
+  --scaler FILE      Apply the scaler saved in FILE, or fit one and save it
+                     there so later scans and clean apply the same transform
+  --float32          Store and score dense features as float32, halving their
+                     memory; scores change only by the rounding of features
+  --shard K/N        Only scan shard K of N (sample i is in shard i mod N), or
+                     shards K-L/N; merge the shard results with merge
+  --checkpoint FILE  Save the scan's progress to FILE and resume from it;
+                     the completed shard result is saved there too
+  --template FILE    Render the text report with a custom text/template
+  --summary          Print a half-page plain-language summary (risk, affected
+                     classes, recommended action, confidence) instead
+
+Analyze Options:
+  --result FILE      Count the findings of a saved result by ATLAS technique
+  --format FORMAT    Output format: text or json (default text)
+
+Annotate Options:
+  --decisions FILE   CSV (id,decision[,note,reviewer]) or allowlist JSON;
+                     decision is accept (finding confirmed) or reject (false positive)
+  --allowlist FILE   Allowlist to merge decisions into (default allowlist.json)
+  --out FILE         Annotated result (default: overwrite the result file)
+
+Benchmark Options:
+  --samples N        Samples per synthetic dataset (default 10000)
+  --synthetic-features N
+                     Features per synthetic sample (default 20)
+  --rate R           Poisoning rate of synthetic datasets (default 0.05)
+  --no-synthetic     Only benchmark the given labeled datasets
+  --truth FILE       Ground-truth sidecar listing the poisoned sample IDs,
+                     instead of a "poisoned" column
+  --format FORMAT    Output format: text or json (default text)
+
+Clean Options:
+  --out FILE         Sanitized dataset to write
+  --removed FILE     CSV listing removed rows and why they were removed
+  --strategy NAME    Cleaning defense, or "none" (default "Data Cleaning")
+  --quarantine DIR   Also quarantine removed rows in DIR for later review
+  --store DSN        Also record the removals in the chains of custody of a
+                     result store, under --project
+  --allowlist, --denylist
+                     Keep allowlisted and remove denylisted samples, as detect
+  --categorical, --encode, --encoder
+                     Encode categorical columns, as detect
+  --scale METHOD     Scale features before detection and defense, as detect
+  --scaler FILE      Apply or fit and save a scaler, as detect
+
+Compare Options:
+  --top N            Drifted features and culprits to list (default 10, 0: all)
+  --forensics        Isolate the changed samples that raised the new version's
+                     risk, and their common sources, time window and trigger
+  --time-col NAME    Column holding each sample's creation time, for
+                     --forensics (RFC 3339, a date or Unix seconds)
+  --tolerance R      Risk above the old version's accepted as clean by
+                     --forensics (default 0.01)
+  --provenance FILE  PROV-JSON provenance of the new version, as detect
+
+Component Options:
+  --dataset PATH     Input dataset artifact (a file, or a directory with one file)
+  --result PATH      Output artifact for the raw result (JSON)
+  --report PATH      Output artifact for the HTML report
+  --metrics PATH     KFP metrics file to write (e.g. /mlpipeline-metrics.json)
+  --ui-metadata PATH KFP UI metadata file to write (e.g. /mlpipeline-ui-metadata.json)
+  --output-params DIR
+                     Write is_poisoned, risk_score, poisoned_count and
+                     sample_count output parameter files to DIR (Argo)
+  --fail-on-poisoned Exit with status 1 when poisoning is detected
+  --config FILE      Detector configuration file (YAML)
+  --policy-file FILE As for detect
+
+Daemon Options:
+  --state DIR        Job queue and results directory (default .modelpoison/daemon)
+  --workers N        Jobs run concurrently (default 2)
+  --max-queued N     Reject submissions beyond N queued jobs (default 1000)
+  --addr ADDR        Job API address, or "" to disable (default localhost:8081)
+  --watch DIR        Also queue datasets created or modified in DIR
+  --watch-priority N Priority of jobs queued by --watch (default 0)
+  --settle, --existing, --config, --policy-file
+                     As for watch; failing rules are logged
+  --pprof-addr ADDR  Serve runtime profiles under /debug/pprof/ on ADDR,
+                     unauthenticated; keep it on localhost
+
+Evaluate Options:
+  --attacks LIST     Attacks injected into each dataset without a "poisoned"
+                     column (default backdoor,label_flip,clean_label)
+  --matrix           Red-team comparison: every detector against every attack
+                     generator (each backdoor trigger and label flip strategy
+                     unless --attacks), by detection rate, false-positive rate
+                     and latency, with the checks to recommend
+  --rate R           Poisoning rate of the injected attacks (default 0.05)
+  --target-class N   Target class of the injected attacks (default 0)
+  --seed N           Random seed of the injected attacks (default 1)
+  --truth FILE       Ground-truth sidecar listing the poisoned sample IDs,
+                     evaluated per poison type when it lists several
+  --config FILE      Detector configuration file (YAML)
+  --format FORMAT    Output format: text or json (default text)
+
+Merge Options:
+  --config FILE      Detector configuration the shards were scanned with
+  --format FORMAT    Output format: text or json (default text)
+  --save-result FILE Also save the merged result as JSON
+
+Extract Options:
+  --out FILE         File to write flagged rows to (default stdout)
+
+Fingerprint Options:
+  --out FILE         Manifest to write (default stdout)
+  --verify FILE      Verify the datasets, or every shard the manifest lists,
+                     against the manifest, reporting changed, added, removed
+                     and reordered samples
+  --format FORMAT    Verification output format: text or json (default text)
+
+Generate Options:
+  --samples N        Samples to generate (default 1000)
+  --dims N           Features of each sample (default 20)
+  --classes N        Classes, each a Gaussian component (default 3)
+  --spread S         Standard deviation of the class means (default 2)
+  --noise S          Standard deviation of features within a class (default 1)
+  --label-noise F    Fraction of samples given a random label (default 0)
+  --seed N           Random seed (default 1)
+  --attack NAME      Attack to inject, as inject, with --rate and
+                     --target-class (default: a clean dataset)
+  --out FILE         Dataset to write
+  --manifest FILE    Write the attack's configuration and poisoned samples
+
+Inject Options:
+  --attack NAME      backdoor, label_flip or clean_label, or a model update
+                     attack on a set of client updates: sign_flip, scaled,
+                     inner_product or model_replacement (default backdoor)
+  --rate R           Fraction of samples to poison (default 0.02)
+  --target-class N   Label assigned to poisoned samples (-1: random flips)
+  --trigger-type T   Backdoor trigger: feature (a few features set high),
+                     patch (a checkerboard in the corner of images) or blend
+                     (a random pattern blended into every feature)
+  --image-width N    Width of the images in the features, for patch triggers
+                     (default: square images)
+  --patch-size N     Side of the patch trigger in pixels (default 3)
+  --blend-alpha A    Opacity of the blend pattern (default 0.2)
+  --flip STRATEGY    Label flips: random (to another class), targeted (to
+                     --target-class) or nearest (to the nearest class centroid)
+                     (default: random if --target-class is -1, else targeted)
+  --source-class N   Only flip the labels of, or perturb, samples of class N
+                     (default -1: any class)
+  --epsilon E        Clean-label budget: move each feature of a poison at most
+                     E standard deviations toward the victim (default 0.5)
+  --victim ID        Target-class sample clean-label poisons collide with
+                     (default: random)
+  --scale X          Boost of scaled updates (default 10) and model replacement
+                     updates (default: clients over malicious clients)
+  --clients N        Simulate the honest updates of N clients, --params
+                     parameters each (default 100), instead of reading a dataset
+  --out FILE         Poisoned dataset with a "poisoned" ground-truth column
+  --manifest FILE    Write the attack's configuration and poisoned samples
+  --ids FILE         Write the IDs of the poisoned samples, one per line
+
+List Options:
+  --format FORMAT    Output format: text or json (default text)
+  --type TYPE        list-strategies: only strategies of this type
+  --max-overhead X   list-strategies: only strategies with overhead <= X
+  --script FILES     list-detectors: also list the checks of these scripts
+
+Policy Options:
+  --policy FILES     Comma-separated Rego files or directories (default: the
+                     policy section of --config)
+  --classes MAP      Class names for input.classes, such as 0=reject,1=approve
+  --dataset NAME     Dataset name passed to policies as input.dataset
+  --config FILE      Configuration file with a policy section
+  --policy-file FILE Policy rules whose fail and warn actions join the decision
+  --format FORMAT    Output format: text or json (default text)
+
+Quarantine Options:
+  --quarantine DIR   Quarantine directory (default .modelpoison/quarantine)
+  restore IDS --justification TEXT [--into FILE] [--allowlist FILE]
+  purge IDS | --all | --older-than DURATION
+  --store DSN        Record restores and purges in the chains of custody of a
+                     result store, under --project
+
+Custody Options:
+  --store DSN        SQLite file or postgres:// database serve records in
+  --project NAME     Project of the samples (default "default")
+  --format FORMAT    Output format: text or json (default text)
+
+Filter Options (detect and report):
+  --min-severity S   Only report findings of at least severity S
+                     (minimal, low, medium, high, critical)
+  --types TYPES      Only report these comma-separated poison types
+  --top N            Only report the N highest-scoring findings
+
+Report Options:
+  --format FORMAT    Output format: text, json, html or compliance (Markdown
+                     evidence mapped onto NIST AI RMF and EU AI Act) (default text)
+  --out FILE         File to write the report to (default stdout)
+  --dataset NAME     Dataset named in compliance reports and summaries
+  --summary          Write the executive summary of the result, text or json
+  --charts DIR       With --format html, write score, feature anomaly and PCA
+                     charts into DIR and show them in the report
+  --chart-format F   Chart image format: svg or png (default svg)
+  --data FILE        Scanned dataset the feature and PCA charts are drawn
+                     from (accepts the column options)
+  --template FILE    Render the text report with a custom text/template
+
+Serve Options:
+  --addr ADDR        Address to listen on (default :8080)
+  --grpc-addr ADDR   Also serve the gRPC API on ADDR
+  --config FILE      Detector configuration applied to every scan
+  --policy-file FILE Policy rules applied to every scan
+  --allow-file-uris  Allow datasets to be submitted by server-local path
+  --allow-remote-uris
+                     Allow datasets to be submitted by http(s) URI, fetched
+                     from public addresses only
+  --allowed-hosts HOSTS
+                     Comma-separated hosts remote URIs are limited to, which
+                     may have private addresses
+  --public-url URL   Base URL of the server used in webhook result links
+  --async-samples N  Run scans of datasets with N or more samples as jobs
+                     (default 100000, 0 only on request)
+  --job-workers N    Number of scan jobs run concurrently (default 1)
+  --stream-buffer N  Findings of each job kept in memory for streaming
+                     clients (default 10000)
+  --stream-policy P  When a job's findings buffer is full: block the scan
+                     for slow streaming clients, drop the oldest findings
+                     or spill them to disk (default block)
+  --temp-dir DIR     Directory of spilled findings (default: the system
+                     temporary directory)
+  --drain-delay D    On SIGTERM, fail /readyz for D before closing listeners
+  --shutdown-timeout D
+                     Wait up to D for in-flight requests (default 30s)
+  --tls-cert FILE    Serve HTTP and gRPC over TLS with this certificate
+  --tls-key FILE     Private key of --tls-cert
+  --client-ca FILE   Require client certificates signed by these CAs (mTLS)
+  --max-upload-mb N  Reject uploads and fetched datasets over N MiB (default 256)
+  --rate-limit R     Requests per second accepted per client (default 20, 0 off)
+  --rate-burst N     Requests a client may send at once (default 40)
+  --store DSN        Record results and decisions in a SQLite file or a
+                     postgres:// database
+  --pprof-addr ADDR  Serve runtime profiles under /debug/pprof/ on ADDR,
+                     requiring the debug scope when auth is configured
+
+Stats Options:
+  --format FORMAT    Output format: text or json (default text)
+  --cache-dir DIR    Profile cache, keyed by dataset content (default: the
+                     user cache directory)
+  --no-cache         Profile the dataset even if a cached profile exists
+  --approximate      Estimate quantiles and MADs in one streaming pass, in
+                     memory that does not grow with the dataset
+  --dedup-memory SIZE
+                     With --approximate, row hashes kept in memory for
+                     duplicate detection before spilling to disk (default 96MB)
+  --temp-dir DIR     Directory of duplicate detection spill files (default:
+                     the system temporary directory)
+
+Trends Options:
+  --store DSN        SQLite file or postgres:// database serve records in
+  --project NAME     Project the dataset was scanned in (default "default")
+  --since WHEN       Only scans after a duration ago (such as 720h) or a date
+                     (2006-01-02)
+  --limit N          At most the N most recent scans (default 100)
+  --format FORMAT    Output format: text or json (default text)
+
+Tune Options:
+  --labeled FILE     Labeled dataset with a "poisoned" ground-truth column
+  --truth FILE       Ground-truth sidecar listing the poisoned sample IDs,
+                     instead of a "poisoned" column
+  --out FILE         Threshold config to write (default thresholds.yaml)
+  --target-precision P
+                     Maximize recall subject to precision >= P
+  --beta B           Recall weight of the F-beta objective (default 1)
+  --folds K          Also cross-validate the tuning over K stratified folds,
+                     reporting the thresholds and held-out scores of each
+                     and their variance
+  --seed N           Random seed of the folds (default 1)
+  --search           Grid search every combination of check thresholds and
+                     scaling methods; --labeled may list several datasets
+  --grid VALUES      Thresholds each check tries with --search (default 0.1
+                     to 0.9 in steps of 0.1)
+  --scalings LIST    Scaling methods tried with --search (default none,
+                     zscore, minmax, robust)
+
+Explore Options:
+  --allowlist FILE   Allowlist receiving review decisions (default allowlist.json)
+
+Validate Options:
+  --schema FILE      Dataset schema (YAML): column types, ranges, labels, checksum
+  --format FORMAT    Output format: text or json (default text)
+
+Watch Options:
+  --settle DURATION  Wait for writes to settle before scanning (default 2s)
+  --existing         Also scan datasets already in the directory
+  --config FILE      Detector configuration file (YAML)
+  --policy-file FILE As for detect; failing rules are logged
+
+Diagnostics and progress are written to stderr; results are written to stdout.
+
 Examples:
   modelpoison detect training_data.csv
-  modelpoison defend training_data.csv
+  modelpoison -q defend training_data.csv
+  modelpoison detect --format json data.csv > run.json
+  modelpoison diff vetted.json run.json
+  modelpoison detect --save-result run.json data.csv
+  modelpoison report run.json --format html --out report.html
 `)
 }
 
-func detectPoisoning(dataset string) {
-	fmt.Printf("Detecting poisoning in: %s\n", dataset)
-	fmt.Println()
-
-	// In production: load and analyze training dataset
-	// For demo: show detection capabilities
-	fmt.Println("Detection Capabilities:")
-	fmt.Println("  ✓ Backdoor trigger detection")
-	fmt.Println("  ✓ Label flipping attacks")
-	fmt.Println("  ✓ Gradient poisoning")
-	fmt.Println("  ✓ Feature poisoning")
-	fmt.Println("  ✓ Data poisoning")
-	fmt.Println()
+// newFlagSet creates a flag set for a command with the shared verbosity flags.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	addVerbosityFlags(fs)
+	addProfilingFlags(fs)
+	addTracingFlags(fs)
+	addAuditFlags(fs)
+	addPluginFlags(fs)
+	return fs
+}
 
-	// Example detection
-	_ = detect.NewDetector()
-	result := &detect.DetectionResult{
-		SampleCount:  1000,
-		PoisonedCount: 15,
-		RiskScore:    0.15,
-		Method:       "ensemble_detection",
+// parseFlags parses args with fs, allowing flags after positional arguments
+// until a "--" argument, after which every argument is positional. It
+// applies the verbosity flags and returns the positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, usagef("%s: %v", fs.Name(), err)
+		}
+		rest := fs.Args()
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		args = rest
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
 	}
 
-	fmt.Println(detect.GenerateReport(result))
-
-	if result.IsPoisoned {
-		fmt.Println("⚠️  POISONING DETECTED")
-		fmt.Println("Recommendation: Clean training data before training")
-	} else {
-		fmt.Println("✓ Training data appears clean")
+	if err := applyVerbosity(); err != nil {
+		return nil, usageError{err: err}
+	}
+	if err := startProfiling(); err != nil {
+		return nil, err
+	}
+	if err := startTracing(fs.Name()); err != nil {
+		return nil, err
 	}
+
+	return positional, nil
 }
 
-func defendModel(dataset string) {
-	fmt.Printf("Defending model: %s\n", dataset)
-	fmt.Println()
+func defendModel(args []string) error {
+	fs := newFlagSet("defend")
+	columns := addColumnFlags(fs)
+	configPath := fs.String("config", "", "detector configuration file")
+	noProgress := fs.Bool("no-progress", false, "disable progress reporting")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 1 {
+		return usagef("dataset required")
+	}
+	dataset := positional[0]
 
-	// In production: apply defense to training data
-	// For demo: show defense options
-	fmt.Println("Available Defense Strategies:")
-	fmt.Println("1. Data Cleaning (75% effective, 20% overhead)")
-	fmt.Println("2. Robust Aggregation (80% effective, 15% overhead)")
-	fmt.Println("3. Input Filtering (70% effective, 10% overhead)")
-	fmt.Println("4. Adversarial Training (85% effective, 40% overhead)")
-	fmt.Println("5. Outlier Detection (65% effective, 12% overhead)")
-	fmt.Println("6. Ensemble Defense (90% effective, 50% overhead)")
-	fmt.Println()
+	logger.Infof("Defending model: %s", dataset)
 
-	// Example defense
-	defender := defend.NewDefender()
-	result := defender.Defend(0.3, "Data Cleaning")
+	// The defense is sized to the poisoning risk of the dataset.
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	scan, err := scanDataset(dataset, scanOptions{noProgress: *noProgress, config: cfg, columns: columns.columns()})
+	if err != nil {
+		return err
+	}
+	logger.Infof("Poisoning risk %.0f%% (%d of %d samples flagged)", scan.RiskScore*100, scan.PoisonedCount, scan.SampleCount)
+	defender, err := newDefender()
+	if err != nil {
+		return err
+	}
+	result := defender.Defend(scan.RiskScore, "Data Cleaning")
+	if err := recordAudit(audit.ActionDefense, "", dataset, map[string]string{"strategy": result.StrategyUsed}); err != nil {
+		return err
+	}
 
 	fmt.Println(defend.GenerateDefenseReport(result))
+
+	return nil
 }
 
-func analyzeSecurity() {
+func analyzeSecurity(args []string) error {
+	fs := newFlagSet("analyze")
+	resultPath := fs.String("result", "", "count the findings of the result in this file by ATLAS technique")
+	format := fs.String("format", "text", "output format: text or json")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usagef("unexpected argument %q", positional[0])
+	}
+	if *format != "text" && *format != "json" {
+		return usagef("invalid format %q (want text or json)", *format)
+	}
+
+	var result *detect.DetectionResult
+	if *resultPath != "" {
+		if result, err = detect.LoadResult(*resultPath); err != nil {
+			return err
+		}
+	}
+	coverage := detect.ATLASCoverage(result)
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(map[string]interface{}{"atlas": coverage})
+	}
+
 	fmt.Println("Security Analysis")
 	fmt.Println("=================")
 	fmt.Println()
@@ -138,9 +751,31 @@ func analyzeSecurity() {
 	fmt.Println("  • Robust Aggregation")
 	fmt.Println("  • Input Filtering")
 	fmt.Println("  • Adversarial Training")
+	fmt.Println()
+
+	fmt.Println("MITRE ATLAS Coverage:")
+	for _, c := range coverage {
+		types := make([]string, len(c.Types))
+		for i, t := range c.Types {
+			types[i] = string(t)
+		}
+		fmt.Printf("  • %s %s (%s)\n", c.ID, c.Name, strings.Join(c.Tactics, ", "))
+		fmt.Printf("      Attack Types: %s\n", strings.Join(types, ", "))
+		fmt.Printf("      Built-in Check: %s\n", yesNo(c.Detected))
+		if result != nil {
+			fmt.Printf("      Findings: %d\n", c.Findings)
+		}
+	}
+
+	return nil
 }
 
-func recommendDefense() {
+func recommendDefense(args []string) error {
+	fs := newFlagSet("recommend")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
 	fmt.Println("Defense Recommendations")
 	fmt.Println("=======================")
 	fmt.Println()
@@ -150,4 +785,6 @@ func recommendDefense() {
 		strategy := defend.RecommendDefense(risk)
 		fmt.Printf("Risk %.0f%%: Use '%s'\n", risk*100, strategy)
 	}
-}
\ No newline at end of file
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares got with the golden file testdata/name, rewriting
+// it instead with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from its golden file:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+// captureStdout returns what run writes to standard output, and its error.
+func captureStdout(t *testing.T, run func() error) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	out := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- data
+	}()
+	err = run()
+	os.Stdout = stdout
+	w.Close()
+	return string(<-out), err
+}
+
+func TestParseFlags(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		args       []string
+		positional []string
+		format     string
+	}{
+		{"flags first", []string{"--format", "json", "a.csv"}, []string{"a.csv"}, "json"},
+		{"flags after arguments", []string{"a.csv", "--format", "json", "b.csv"}, []string{"a.csv", "b.csv"}, "json"},
+		{"end of options", []string{"--", "-data.csv"}, []string{"-data.csv"}, "text"},
+		{"end of options after flags", []string{"--format=json", "a.csv", "--", "--format", "-"}, []string{"a.csv", "--format", "-"}, "json"},
+		{"only end of options", []string{"--"}, nil, "text"},
+	} {
+		fs := newFlagSet("detect")
+		format := fs.String("format", "text", "output format")
+		positional, err := parseFlags(fs, test.args)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(positional, test.positional) || *format != test.format {
+			t.Errorf("%s: positional %q, format %s; want %q, %s", test.name, positional, *format, test.positional, test.format)
+		}
+	}
+
+	// Without "--", an argument starting with "-" is a flag.
+	if _, err := parseFlags(newFlagSet("detect"), []string{"-data.csv"}); err == nil {
+		t.Error("parsed -data.csv as an argument")
+	}
+}
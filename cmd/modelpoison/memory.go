@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// memoryOverhead is the memory in use before a dataset is read: the
+	// runtime, the program and buffers.
+	memoryOverhead = 48 << 20
+	// gcHeadroom is the factor by which the heap outgrows live data between
+	// collections.
+	gcHeadroom = 2
+	// minChunkSize is the smallest chunk worth streaming.
+	minChunkSize = 256
+	// findingBytes is the footprint of a finding kept by a chunked scan.
+	findingBytes = 256
+)
+
+// parseSize parses a byte size such as 512MB, 1.5GiB or 1048576.
+// Decimal and binary units are both taken as powers of 1024.
+func parseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		scale  float64
+	}{
+		{"t", 1 << 40}, {"g", 1 << 30}, {"m", 1 << 20}, {"k", 1 << 10},
+	}
+	num := strings.TrimSpace(strings.ToLower(s))
+	num = strings.TrimSuffix(num, "b")
+	num = strings.TrimSuffix(num, "i")
+	scale := 1.0
+	for _, u := range units {
+		if strings.HasSuffix(num, u.suffix) {
+			num, scale = strings.TrimSuffix(num, u.suffix), u.scale
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid size %q (want a size such as 512MB or 2GB)", s)
+	}
+	return int64(v * scale), nil
+}
+
+// formatSize formats n bytes for messages.
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1fGB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.0fMB", float64(n)/(1<<20))
+	}
+	return fmt.Sprintf("%dKB", (n+1023)>>10)
+}
+
+// datasetShape is an estimate of the size of a CSV dataset.
+type datasetShape struct {
+	rows    int64
+	columns int
+	// featureBytes is the size of a parsed feature.
+	featureBytes int64
+	// lineBytes is the average length of a record.
+	lineBytes int64
+}
+
+// estimateShape estimates the shape of the CSV dataset at path from its
+// size and leading lines.
+func estimateShape(path string) (datasetShape, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return datasetShape{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return datasetShape{}, err
+	}
+
+	r := bufio.NewReader(io.LimitReader(f, 1<<20))
+	header, err := r.ReadString('\n')
+	if err != nil && header == "" {
+		return datasetShape{}, fmt.Errorf("%s: empty dataset", path)
+	}
+	shape := datasetShape{columns: strings.Count(header, ",") + 1, featureBytes: 8}
+	var lines, bytes int64
+	for {
+		line, err := r.ReadString('\n')
+		if len(line) > 0 && err == nil {
+			lines++
+			bytes += int64(len(line))
+		}
+		if err != nil {
+			break
+		}
+	}
+	if lines == 0 {
+		shape.lineBytes = int64(len(header))
+	} else {
+		shape.lineBytes = bytes / lines
+	}
+	if shape.lineBytes > 0 {
+		shape.rows = (info.Size() - int64(len(header))) / shape.lineBytes
+	}
+	return shape, nil
+}
+
+// loadedBytes estimates the memory taken by a loaded sample and its
+// finding: the kept CSV record, the parsed sample and the result entry.
+func (s datasetShape) loadedBytes() int64 {
+	return s.streamedBytes() + 24 + findingBytes
+}
+
+// streamedBytes estimates the memory taken by a sample in a chunk: the CSV
+// record being parsed and the parsed sample.
+func (s datasetShape) streamedBytes() int64 {
+	return s.lineBytes + (16+s.featureBytes)*int64(s.columns) + 64
+}
+
+// memoryPlan returns the chunk size with which the dataset at path can be
+// scanned within budget bytes: zero when loading it fits, otherwise the
+// largest chunk that fits. It fails when no chunk size fits, or when
+// opts require loading a dataset that does not fit.
+func memoryPlan(path string, opts scanOptions, budget int64) (int, error) {
+	shape, err := estimateShape(path)
+	if err != nil {
+		return 0, err
+	}
+	if opts.columns.Float32 {
+		shape.featureBytes = 4
+	}
+	loaded := memoryOverhead + shape.rows*shape.loadedBytes()*gcHeadroom
+	if opts.chunkSize == 0 && loaded <= budget {
+		return 0, nil
+	}
+	if opts.limit > 0 || opts.sample > 0 {
+		return 0, fmt.Errorf("%s: loading it needs about %s, more than --max-memory %s; --limit and --sample require loading it",
+			path, formatSize(loaded), formatSize(budget))
+	}
+
+	// Chunked scans keep one chunk and the flagged samples. Flagged
+	// samples are budgeted at one percent of the dataset.
+	fixed := memoryOverhead + shape.rows/100*findingBytes
+	chunkBytes := shape.streamedBytes() * gcHeadroom
+	chunk := (budget - fixed) / chunkBytes
+	if opts.chunkSize > 0 {
+		if int64(opts.chunkSize) > chunk {
+			return 0, fmt.Errorf("%s: --chunk-size %d needs about %s, more than --max-memory %s",
+				path, opts.chunkSize, formatSize(fixed+int64(opts.chunkSize)*chunkBytes), formatSize(budget))
+		}
+		return opts.chunkSize, nil
+	}
+	if chunk < minChunkSize {
+		return 0, fmt.Errorf("%s: --max-memory %s is too small to scan it; it needs at least %s",
+			path, formatSize(budget), formatSize(fixed+minChunkSize*chunkBytes))
+	}
+	return int(chunk), nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	for input, want := range map[string]int64{
+		"1048576": 1 << 20,
+		"512MB":   512 << 20,
+		"512mb":   512 << 20,
+		"512M":    512 << 20,
+		"1.5GiB":  3 << 29,
+		"2GB":     2 << 30,
+		" 64 KB ": 64 << 10,
+		"1TB":     1 << 40,
+		"100B":    100,
+	} {
+		if got, err := parseSize(input); err != nil || got != want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d", input, got, err, want)
+		}
+	}
+	for _, input := range []string{"", "MB", "-1GB", "0", "twoGB", "5XB", "1.2.3MB"} {
+		if got, err := parseSize(input); err == nil {
+			t.Errorf("parseSize(%q) = %d, want an error", input, got)
+		}
+	}
+}
+
+// writeDataset writes a CSV dataset of rows samples of 10 features to a
+// temporary file and returns its path.
+func writeDataset(t *testing.T, rows int) string {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("f0,f1,f2,f3,f4,f5,f6,f7,f8,f9,label\n")
+	for i := 0; i < rows; i++ {
+		for f := 0; f < 10; f++ {
+			fmt.Fprintf(&b, "%.4f,", float64(i*f%97)/7)
+		}
+		fmt.Fprintf(&b, "%d\n", i%2)
+	}
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEstimateShape(t *testing.T) {
+	path := writeDataset(t, 5000)
+	shape, err := estimateShape(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shape.columns != 11 || shape.featureBytes != 8 {
+		t.Errorf("shape %+v, want 11 columns of 8-byte features", shape)
+	}
+	if shape.rows < 4500 || shape.rows > 5500 {
+		t.Errorf("estimated %d rows, want about 5000", shape.rows)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.csv")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := estimateShape(empty); err == nil {
+		t.Error("estimated the shape of an empty dataset")
+	}
+}
+
+func TestMemoryPlan(t *testing.T) {
+	path := writeDataset(t, 20000)
+	shape, err := estimateShape(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A loose budget loads the dataset.
+	if chunk, err := memoryPlan(path, scanOptions{}, 1<<30); err != nil || chunk != 0 {
+		t.Errorf("plan within 1GB: chunks of %d, %v; want the dataset loaded", chunk, err)
+	}
+
+	// A tight budget streams it in the largest chunks that fit.
+	budget := int64(memoryOverhead + 2<<20)
+	chunk, err := memoryPlan(path, scanOptions{}, budget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed := memoryOverhead + shape.rows/100*findingBytes
+	chunkBytes := shape.streamedBytes() * gcHeadroom
+	if chunk < minChunkSize || int64(chunk) >= shape.rows || fixed+int64(chunk)*chunkBytes > budget || fixed+int64(chunk+1)*chunkBytes <= budget {
+		t.Errorf("plan within %s: chunks of %d, want the largest chunk that fits", formatSize(budget), chunk)
+	}
+
+	// Float32 features halve the parsed features, so chunks grow.
+	opts := scanOptions{}
+	opts.columns.Float32 = true
+	if chunk32, err := memoryPlan(path, opts, budget); err != nil || chunk32 <= chunk {
+		t.Errorf("float32 plan within %s: chunks of %d, %v; want more than %d", formatSize(budget), chunk32, err, chunk)
+	}
+
+	// An explicit chunk size is kept if it fits.
+	if got, err := memoryPlan(path, scanOptions{chunkSize: 300}, budget); err != nil || got != 300 {
+		t.Errorf("plan with --chunk-size 300: chunks of %d, %v", got, err)
+	}
+
+	for name, test := range map[string]struct {
+		opts   scanOptions
+		budget int64
+	}{
+		"below the overhead":     {scanOptions{}, memoryOverhead},
+		"too small for a chunk":  {scanOptions{}, memoryOverhead + 1<<10},
+		"limit requiring a load": {scanOptions{limit: 10}, budget},
+		"sample requiring load":  {scanOptions{sample: 0.5}, budget},
+		"oversized chunk size":   {scanOptions{chunkSize: 1 << 20}, budget},
+	} {
+		if chunk, err := memoryPlan(path, test.opts, test.budget); err == nil {
+			t.Errorf("%s: chunks of %d, want an error", name, chunk)
+		} else if !strings.Contains(err.Error(), path) {
+			t.Errorf("%s: error %q does not name the dataset", name, err)
+		}
+	}
+
+	if _, err := memoryPlan(filepath.Join(t.TempDir(), "missing.csv"), scanOptions{}, budget); err == nil {
+		t.Error("planned a missing dataset")
+	}
+}
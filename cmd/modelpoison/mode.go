@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Dataset modes of --mode.
+const (
+	modeTabular     = "tabular"
+	modePreference  = "preference"
+	modeInstruction = "instruction"
+)
+
+// modeFlags holds --mode and the fields of the text datasets of each
+// mode.
+type modeFlags struct {
+	mode        string
+	preference  *preferenceFlags
+	instruction *instructionFlags
+}
+
+// addModeFlags registers --mode and the fields of text datasets on fs.
+func addModeFlags(fs *flag.FlagSet) *modeFlags {
+	m := &modeFlags{}
+	fs.StringVar(&m.mode, "mode", modeTabular, "dataset mode: tabular, preference for prompt, chosen and rejected comparisons, or instruction for instruction-tuning records")
+	m.preference = addPreferenceFlags(fs)
+	m.instruction = addInstructionFlags(fs)
+	return m
+}
+
+// apply sets the mode of opts and the fields of its text datasets. Text
+// datasets are analyzed whole, as text rather than features.
+func (m *modeFlags) apply(opts *scanOptions) error {
+	switch m.mode {
+	case modeTabular, modePreference, modeInstruction:
+	default:
+		return usagef("invalid --mode %q (want %s, %s or %s)", m.mode, modeTabular, modePreference, modeInstruction)
+	}
+	if m.preference.set() && m.mode != modePreference {
+		return usagef("the fields of preference pairs require --mode preference")
+	}
+	if m.instruction.set() && m.mode != modeInstruction {
+		return usagef("the fields of instruction records require --mode instruction")
+	}
+	if m.mode == modeTabular {
+		return nil
+	}
+	if opts.chunkSize > 0 || opts.maxMemory > 0 || opts.shard != nil || opts.limit > 0 || opts.sample > 0 || opts.incremental {
+		return usagef("%s datasets are analyzed whole and do not support --chunk-size, --max-memory, --shard, --limit, --sample or --incremental", m.mode)
+	}
+	if len(opts.scripts) > 0 || opts.modal.Enabled() || len(opts.categorical) > 0 || opts.encoder != nil {
+		return usagef("%s datasets have no features for --script, multi-modal columns or categorical encoding", m.mode)
+	}
+	opts.mode = m.mode
+	opts.preference = m.preference.columns(opts.columns.ID)
+	opts.instruction = m.instruction.columns(opts.columns.ID)
+	return nil
+}
+
+// scanText analyzes the text dataset at path in the mode of opts.
+func scanText(path string, opts scanOptions, log *cliLogger) (*detect.DetectionResult, error) {
+	if opts.mode == modeInstruction {
+		return scanInstructions(path, opts, log)
+	}
+	return scanPreferences(path, opts, log)
+}
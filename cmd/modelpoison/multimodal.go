@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/multimodal"
+)
+
+// modalFlags holds the flags selecting the modalities of paired
+// multi-modal samples.
+type modalFlags struct {
+	image, text, caption string
+}
+
+// addModalFlags registers --image-cols, --text-cols and --caption-col on
+// fs.
+func addModalFlags(fs *flag.FlagSet) *modalFlags {
+	m := &modalFlags{}
+	fs.StringVar(&m.image, "image-cols", "", "comma-separated features or globs (such as img_*) of the image of multi-modal samples")
+	fs.StringVar(&m.text, "text-cols", "", "comma-separated features or globs of the text of multi-modal samples")
+	fs.StringVar(&m.caption, "caption-col", "", "column holding the caption of multi-modal samples")
+	return m
+}
+
+// apply sets the modalities of opts, keeping the caption out of the
+// features.
+func (m *modalFlags) apply(opts *scanOptions) error {
+	opts.modal = multimodal.Columns{Image: m.image, Text: m.text, Caption: m.caption}
+	if !opts.modal.Enabled() {
+		if m.caption != "" {
+			return usagef("--caption-col requires --image-cols and --text-cols")
+		}
+		return nil
+	}
+	if m.image == "" || m.text == "" {
+		return usagef("multi-modal scans require both --image-cols and --text-cols")
+	}
+	if opts.chunkSize > 0 || opts.shard != nil {
+		return usagef("multi-modal checks compare every sample and do not support --chunk-size or --shard")
+	}
+	if m.caption != "" {
+		opts.columns.Ignore = append(opts.columns.Ignore, m.caption)
+	}
+	return nil
+}
+
+// addModalChecks adds the cross-modal checks of the samples of data to
+// detector, when opts selects their modalities.
+func addModalChecks(detector *detect.Detector, data *dataset.Dataset, opts scanOptions) error {
+	if !opts.modal.Enabled() {
+		return nil
+	}
+	set, err := multimodal.NewSet(data, opts.modal)
+	if err != nil {
+		return err
+	}
+	for _, check := range multimodal.Checks(set) {
+		if err := detector.AddCheck(check); err != nil {
+			return fmt.Errorf("multi-modal: %w", err)
+		}
+	}
+	return nil
+}
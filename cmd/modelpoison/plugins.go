@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"sync"
+
+	"github.com/hallucinaut/modelpoison/pkg/defend"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/plugin"
+)
+
+// pluginDir is bound to the --plugin-dir flag of every flag set.
+var pluginDir = os.Getenv("MODELPOISON_PLUGIN_DIR")
+
+var (
+	pluginOnce sync.Once
+	plugins    *plugin.Set
+	pluginErr  error
+)
+
+// addPluginFlags registers --plugin-dir on fs.
+func addPluginFlags(fs *flag.FlagSet) {
+	fs.StringVar(&pluginDir, "plugin-dir", pluginDir, "load detector and defense plugins from the executables in this directory")
+}
+
+// loadPlugins starts the plugins in --plugin-dir, or returns nil when it is
+// not set. The plugins run until closePlugins.
+func loadPlugins() (*plugin.Set, error) {
+	pluginOnce.Do(func() {
+		if pluginDir == "" {
+			return
+		}
+		plugins, pluginErr = plugin.Load(commandContext, pluginDir, os.Stderr)
+		if pluginErr != nil {
+			return
+		}
+		for _, p := range plugins.Plugins() {
+			info := p.Info()
+			logger.Debugf("loaded plugin %s %s from %s: %d detectors, %d defenses",
+				info.Name, info.Version, p.Path(), len(info.Detectors), len(info.Defenses))
+		}
+	})
+	return plugins, pluginErr
+}
+
+// registerPlugins adds the detectors of the plugins to detector.
+func registerPlugins(detector *detect.Detector) error {
+	set, err := loadPlugins()
+	if err != nil || set == nil {
+		return err
+	}
+	return set.Register(detector)
+}
+
+// newDefender creates a defender with the defenses of the plugins.
+func newDefender() (*defend.Defender, error) {
+	defender := defend.NewDefender()
+	set, err := loadPlugins()
+	if err != nil || set == nil {
+		return defender, err
+	}
+	return defender, set.RegisterDefenses(defender)
+}
+
+// checkPlugins returns the failures of the plugins during the command,
+// whose scans and defenses are incomplete when a plugin failed.
+func checkPlugins() error {
+	if plugins == nil {
+		return nil
+	}
+	return plugins.Err()
+}
+
+// closePlugins stops the plugins, if any were started.
+func closePlugins() {
+	if plugins == nil {
+		return
+	}
+	if err := plugins.Close(); err != nil {
+		logger.Warnf("%v", err)
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hallucinaut/modelpoison/pkg/audit"
+	"github.com/hallucinaut/modelpoison/pkg/config"
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/policy"
+	"github.com/hallucinaut/modelpoison/pkg/webhook"
+)
+
+func evaluatePolicy(args []string) error {
+	fs := newFlagSet("policy")
+	files := fs.String("policy", "", "comma-separated Rego policy files or directories")
+	classes := fs.String("classes", "", "comma-separated class names, such as 0=reject,1=approve")
+	configPath := fs.String("config", "", "configuration file with a policy section")
+	policyFile := addPolicyFlag(fs)
+	datasetName := fs.String("dataset", "", "dataset name passed to policies as input.dataset")
+	format := fs.String("format", "text", "output format: text or json")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usagef("result required")
+	}
+	if *format != "text" && *format != "json" {
+		return usagef("invalid format %q (want text or json)", *format)
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if err := applyPolicyFile(cfg, *policyFile); err != nil {
+		return err
+	}
+	var policyCfg policy.Config
+	if cfg.Policy != nil {
+		policyCfg = *cfg.Policy
+	}
+	if *files != "" {
+		policyCfg.Files = splitList(*files)
+	}
+	if *classes != "" {
+		if policyCfg.Classes, err = parseClasses(*classes); err != nil {
+			return usagef("%v", err)
+		}
+	}
+	if len(policyCfg.Files) == 0 && len(policyCfg.Rules) == 0 {
+		return usagef("--policy, --policy-file or a config with a policy section required")
+	}
+
+	result, err := detect.LoadResult(positional[0])
+	if err != nil {
+		return err
+	}
+	engine, err := policy.New(commandContext, policyCfg)
+	if err != nil {
+		return err
+	}
+	input := engine.NewInput(result)
+	input.Dataset = *datasetName
+	decision, err := engine.Evaluate(commandContext, input)
+	if err != nil {
+		return err
+	}
+	logger.Debugf("policy decision for %s: allow=%t, %d deny, %d warn",
+		positional[0], decision.Allow, len(decision.Deny), len(decision.Warn))
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(decision); err != nil {
+			return err
+		}
+	} else {
+		printDecision(decision)
+	}
+	if !decision.Allow {
+		return fmt.Errorf("denied by policy: %s", strings.Join(decision.Deny, "; "))
+	}
+	return nil
+}
+
+// printDecision prints a policy decision and its messages.
+func printDecision(decision policy.Decision) {
+	if decision.Allow {
+		fmt.Println("✓ ALLOW")
+	} else {
+		fmt.Println("✗ DENY")
+	}
+	for _, msg := range decision.Deny {
+		fmt.Printf("  deny: %s\n", msg)
+	}
+	for _, msg := range decision.Warn {
+		fmt.Printf("  warn: %s\n", msg)
+	}
+}
+
+// parseClasses parses class names given as label=name pairs.
+func parseClasses(s string) (map[int]string, error) {
+	classes := make(map[int]string)
+	for _, pair := range splitList(s) {
+		label, name, ok := strings.Cut(pair, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(label))
+		if !ok || err != nil || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid class %q (want LABEL=NAME)", pair)
+		}
+		classes[n] = strings.TrimSpace(name)
+	}
+	return classes, nil
+}
+
+// addPolicyFlag registers --policy-file on fs.
+func addPolicyFlag(fs *flag.FlagSet) *string {
+	return fs.String("policy-file", "", "YAML policy whose rules act on every scan, replacing the config's policy section")
+}
+
+// applyPolicyFile replaces the policy section of cfg with the YAML policy
+// file at path, if set. A config change is recorded for it as for config
+// files.
+func applyPolicyFile(cfg *config.Config, path string) error {
+	if path == "" {
+		return nil
+	}
+	p, err := policy.Load(path)
+	if err != nil {
+		return err
+	}
+	if err := recordConfig(path); err != nil {
+		return err
+	}
+	cfg.Policy = &p
+	return nil
+}
+
+// enforcePolicy applies the actions of the policy rules of cfg matching a
+// completed scan of path, returning an error when a rule fails the scan.
+// Rules that quarantine or clean samples reload the dataset with the
+// columns of opts.
+func enforcePolicy(cfg *config.Config, path string, opts scanOptions, result *detect.DetectionResult) error {
+	if cfg == nil || cfg.Policy == nil || len(cfg.Policy.Rules) == 0 {
+		return nil
+	}
+	// Rules do not need the Rego policies compiled.
+	rules := *cfg.Policy
+	rules.Files = nil
+	engine, err := policy.New(commandContext, rules)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	quarantined := make(map[string]string)
+	cleaned := make(map[string]string)
+	for _, m := range engine.Match(engine.NewInput(result)) {
+		logger.Debugf("policy rule %s matched %s: %s", m.Rule, path, strings.Join(actionNames(m.Actions), ", "))
+		if m.Has(policy.ActionWarn) {
+			logger.Warnf("%s: %s", path, m.Message)
+		}
+		if m.Has(policy.ActionNotify) {
+			if err := webhook.New(m.Notify(), nil).Notify(commandContext, webhook.Scan{Dataset: path, Result: result}); err != nil {
+				logger.Warnf("policy rule %s: %v", m.Rule, err)
+			}
+		}
+		for _, id := range m.Samples {
+			if m.Has(policy.ActionQuarantine) && quarantined[id] == "" {
+				quarantined[id] = m.Rule
+			}
+			if m.Has(policy.ActionClean) && cleaned[id] == "" {
+				cleaned[id] = m.Rule
+			}
+		}
+		if m.Has(policy.ActionFail) {
+			failed = append(failed, m.Message)
+		}
+	}
+	if len(quarantined) > 0 || len(cleaned) > 0 {
+		if err := policyRemovals(path, opts, rules.Quarantine, result, quarantined, cleaned); err != nil {
+			return err
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s failed policy: %s", path, strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// policyRemovals quarantines the samples of path in quarantined and writes
+// a copy of the dataset without the samples in cleaned, each mapping
+// sample IDs to the rule acting on them.
+func policyRemovals(path string, opts scanOptions, dir string, result *detect.DetectionResult, quarantined, cleaned map[string]string) error {
+	if dataset.IsSVMLight(path) {
+		return fmt.Errorf("%s: the quarantine and auto_clean policy actions read CSV datasets", path)
+	}
+	cols, err := encodeColumns(path, opts)
+	if err != nil {
+		return err
+	}
+	data, err := dataset.LoadCSVContext(commandContext, path, cols)
+	if err != nil {
+		return err
+	}
+	findings := make(map[string]detect.PoisonedSample)
+	for _, finding := range result.Samples {
+		findings[finding.ID] = finding
+	}
+
+	destination := ""
+	if len(cleaned) > 0 {
+		ext := filepath.Ext(path)
+		destination = strings.TrimSuffix(path, ext) + ".clean" + ext
+		var removed []string
+		var kept []int
+		for i, sample := range data.Samples {
+			if _, ok := cleaned[sample.ID]; ok {
+				removed = append(removed, sample.ID)
+			} else {
+				kept = append(kept, i)
+			}
+		}
+		if err := data.Subset(kept).SaveCSV(destination); err != nil {
+			return err
+		}
+		logger.Infof("Policy removed %d samples of %s, writing %d to %s", len(removed), path, len(kept), destination)
+		details := map[string]string{
+			"strategy": "policy",
+			"out":      destination,
+			"samples":  strconv.Itoa(len(data.Samples)),
+			"removed":  strconv.Itoa(len(removed)),
+		}
+		if len(removed) > 0 {
+			details["removed_ids"] = audit.IDs(removed)
+		}
+		if err := recordAudit(audit.ActionDefense, "", path, details); err != nil {
+			return err
+		}
+	}
+
+	if len(quarantined) > 0 {
+		if dir == "" {
+			dir = defaultQuarantineDir
+		}
+		removals := make(map[int]removal)
+		var removed []int
+		for i, sample := range data.Samples {
+			if rule, ok := quarantined[sample.ID]; ok {
+				removals[i] = removal{index: i, reason: "policy rule " + rule, finding: findings[sample.ID]}
+				removed = append(removed, i)
+			}
+		}
+		added, err := quarantineRemovals(dir, path, destination, data, removed, removals)
+		if err != nil {
+			return err
+		}
+		logger.Infof("Policy quarantined %d samples of %s in %s", added, path, dir)
+	}
+	return nil
+}
+
+// actionNames returns the names of actions.
+func actionNames(actions []policy.Action) []string {
+	names := make([]string, len(actions))
+	for i, a := range actions {
+		names[i] = string(a)
+	}
+	return names
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/preference"
+)
+
+// preferenceFlags holds the flags naming the fields of preference
+// datasets.
+type preferenceFlags struct {
+	prompt, chosen, rejected, annotator string
+	chosenScore, rejectedScore          string
+}
+
+// addPreferenceFlags registers the fields of preference datasets on fs.
+func addPreferenceFlags(fs *flag.FlagSet) *preferenceFlags {
+	p := &preferenceFlags{}
+	fs.StringVar(&p.prompt, "prompt-col", "", "field of the prompt of preference pairs (default prompt)")
+	fs.StringVar(&p.chosen, "chosen-col", "", "field of the chosen response of preference pairs (default chosen)")
+	fs.StringVar(&p.rejected, "rejected-col", "", "field of the rejected response of preference pairs (default rejected)")
+	fs.StringVar(&p.annotator, "annotator-col", "", "field of the annotator of preference pairs (default annotator, if present)")
+	fs.StringVar(&p.chosenScore, "chosen-score-col", "", "field of a reference score of the chosen response (default chosen_score, if present)")
+	fs.StringVar(&p.rejectedScore, "rejected-score-col", "", "field of a reference score of the rejected response (default rejected_score, if present)")
+	return p
+}
+
+// set reports whether any field of preference pairs was named.
+func (p *preferenceFlags) set() bool {
+	return p.columns("") != preference.Columns{}
+}
+
+// columns returns the fields of preference pairs, with IDs in the id
+// field.
+func (p *preferenceFlags) columns(id string) preference.Columns {
+	return preference.Columns{
+		ID:            id,
+		Prompt:        p.prompt,
+		Chosen:        p.chosen,
+		Rejected:      p.rejected,
+		Annotator:     p.annotator,
+		ChosenScore:   p.chosenScore,
+		RejectedScore: p.rejectedScore,
+	}
+}
+
+// scanPreferences analyzes the preference pairs of the dataset at path,
+// flagging pairs above the thresholds of the detector configured by opts.
+func scanPreferences(path string, opts scanOptions, log *cliLogger) (*detect.DetectionResult, error) {
+	pairs, err := preference.Load(path, opts.preference)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("loaded %d preference pairs from %s", len(pairs), path)
+	detector, err := newDetector(opts.config)
+	if err != nil {
+		return nil, err
+	}
+	analysis := preference.Analyze(pairs, detector.Thresholds())
+	for _, a := range analysis.Annotators {
+		if a.Bias != "" {
+			log.Warnf("%s", a.Bias)
+		}
+	}
+	result := detector.Summarize(analysis.Findings, len(pairs))
+	result.Method = preference.Method
+	return result, nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	httppprof "net/http/pprof"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+
+	"github.com/hallucinaut/modelpoison/pkg/auth"
+)
+
+// profiling holds the profiling flags and the state of running profiles.
+type profiling struct {
+	cpuProfile string
+	memProfile string
+	trace      string
+
+	started   bool
+	cpuFile   *os.File
+	traceFile *os.File
+}
+
+// globalProfiling is bound to the profiling flags of every flag set.
+var globalProfiling profiling
+
+// addProfilingFlags registers --cpuprofile, --memprofile and --trace on fs.
+func addProfilingFlags(fs *flag.FlagSet) {
+	fs.StringVar(&globalProfiling.cpuProfile, "cpuprofile", globalProfiling.cpuProfile, "write a CPU profile to file")
+	fs.StringVar(&globalProfiling.memProfile, "memprofile", globalProfiling.memProfile, "write a heap profile to file on exit")
+	fs.StringVar(&globalProfiling.trace, "trace", globalProfiling.trace, "write an execution trace to file")
+}
+
+// startProfiling starts the CPU profile and execution trace requested by
+// the flags. It is called once flags are parsed and does nothing on later
+// calls.
+func startProfiling() error {
+	p := &globalProfiling
+	if p.started {
+		return nil
+	}
+	p.started = true
+
+	if p.cpuProfile != "" {
+		f, err := os.Create(p.cpuProfile)
+		if err != nil {
+			return fmt.Errorf("cpuprofile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return fmt.Errorf("cpuprofile: %w", err)
+		}
+		p.cpuFile = f
+		logger.Debugf("writing CPU profile to %s", p.cpuProfile)
+	}
+
+	if p.trace != "" {
+		f, err := os.Create(p.trace)
+		if err != nil {
+			return fmt.Errorf("trace: %w", err)
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			return fmt.Errorf("trace: %w", err)
+		}
+		p.traceFile = f
+		logger.Debugf("writing execution trace to %s", p.trace)
+	}
+
+	return nil
+}
+
+// stopProfiling stops running profiles and writes the heap profile.
+func stopProfiling() {
+	p := &globalProfiling
+
+	if p.cpuFile != nil {
+		pprof.StopCPUProfile()
+		if err := p.cpuFile.Close(); err != nil {
+			logger.Errorf("cpuprofile: %v", err)
+		}
+		p.cpuFile = nil
+	}
+
+	if p.traceFile != nil {
+		trace.Stop()
+		if err := p.traceFile.Close(); err != nil {
+			logger.Errorf("trace: %v", err)
+		}
+		p.traceFile = nil
+	}
+
+	if p.started && p.memProfile != "" {
+		f, err := os.Create(p.memProfile)
+		if err != nil {
+			logger.Errorf("memprofile: %v", err)
+			return
+		}
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			logger.Errorf("memprofile: %v", err)
+		}
+		if err := f.Close(); err != nil {
+			logger.Errorf("memprofile: %v", err)
+		}
+	}
+}
+
+// serveProfiles serves the net/http/pprof runtime profiles under
+// /debug/pprof/ on their own listener at addr, so they are never reachable
+// through the API address. With an authenticator, requests need the debug
+// scope. Serving errors are sent to errs.
+func serveProfiles(addr string, authenticator *auth.Authenticator, tlsConfig *tls.Config, errs chan<- error) (*http.Server, error) {
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("pprof: %w", err)
+	}
+	if tlsConfig != nil {
+		lis = tls.NewListener(lis, tlsConfig)
+	}
+
+	if authenticator == nil {
+		if host, _, err := net.SplitHostPort(addr); err != nil || !isLoopback(host) {
+			logger.Warnf("profiles on %s are served without authentication", addr)
+		}
+	}
+
+	srv := &http.Server{
+		Handler:  profileHandler(authenticator),
+		ErrorLog: slog.NewLogLogger(logger.slog.Handler(), slog.LevelWarn),
+	}
+	logger.Infof("Serving pprof profiles on %s", addr)
+	go func() {
+		if err := srv.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+			errs <- fmt.Errorf("pprof: %w", err)
+		}
+	}()
+	return srv, nil
+}
+
+// profileHandler serves the net/http/pprof runtime profiles under
+// /debug/pprof/, to requests with the debug scope when authenticator is
+// set.
+func profileHandler(authenticator *auth.Authenticator) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", httppprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", httppprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", httppprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", httppprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", httppprof.Trace)
+	if authenticator == nil {
+		return mux
+	}
+	return requireScope(authenticator, auth.ScopeDebug, mux)
+}
+
+// requireScope serves requests whose credentials grant scope with next,
+// and rejects the others.
+func requireScope(authenticator *auth.Authenticator, scope auth.Scope, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, err := authenticator.Authenticate(r.Header.Get("Authorization"), r.TLS)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="modelpoison"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if !identity.Allows(scope) {
+			http.Error(w, fmt.Sprintf("%s lacks the %s scope", identity.Name, scope), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLoopback reports whether host names the loopback interface.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
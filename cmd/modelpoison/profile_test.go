@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/auth"
+)
+
+func TestProfileHandler(t *testing.T) {
+	authenticator := auth.New(auth.Config{Tokens: []auth.Token{
+		{Name: "ops", Token: "debug-token", Scopes: []auth.Scope{auth.ScopeDebug}},
+		{Name: "ci", Token: "scan-token", Scopes: []auth.Scope{auth.ScopeRead, auth.ScopeScan}},
+	}})
+	for _, test := range []struct {
+		name          string
+		authenticator *auth.Authenticator
+		path, token   string
+		status        int
+	}{
+		{"debug scope", authenticator, "/debug/pprof/", "debug-token", http.StatusOK},
+		{"named profile", authenticator, "/debug/pprof/heap?debug=1", "debug-token", http.StatusOK},
+		{"without the debug scope", authenticator, "/debug/pprof/", "scan-token", http.StatusForbidden},
+		{"unknown token", authenticator, "/debug/pprof/", "guess", http.StatusUnauthorized},
+		{"no token", authenticator, "/debug/pprof/cmdline", "", http.StatusUnauthorized},
+		{"outside /debug/pprof/", authenticator, "/v1/datasets", "debug-token", http.StatusNotFound},
+		{"without auth", nil, "/debug/pprof/", "", http.StatusOK},
+	} {
+		r := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		w := httptest.NewRecorder()
+		profileHandler(test.authenticator).ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("%s: status %d, want %d: %s", test.name, w.Code, test.status, w.Body)
+		}
+	}
+}
+
+func TestProfilingFlags(t *testing.T) {
+	t.Setenv("MODELPOISON_AUDIT_LOG", "")
+	// Earlier commands parsed flags and so already started profiling.
+	globalProfiling = profiling{}
+	defer func() { globalProfiling = profiling{} }()
+
+	dir := t.TempDir()
+	writeComponentDataset(t, dir, 60)
+	cpu := filepath.Join(dir, "cpu.pprof")
+	mem := filepath.Join(dir, "mem.pprof")
+	exec := filepath.Join(dir, "exec.trace")
+	_, err := captureStdout(t, func() error {
+		return run("detect", []string{filepath.Join(dir, "data"), "--format", "json", "--no-progress",
+			"--cpuprofile", cpu, "--memprofile", mem, "--trace", exec})
+	})
+	stopProfiling()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name, path string
+		magic      []byte
+	}{
+		// Profiles are gzipped protocol buffers; traces start with their
+		// format version.
+		{"cpuprofile", cpu, []byte{0x1f, 0x8b}},
+		{"memprofile", mem, []byte{0x1f, 0x8b}},
+		{"trace", exec, []byte("go 1.")},
+	} {
+		data, err := os.ReadFile(test.path)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !bytes.HasPrefix(data, test.magic) {
+			t.Errorf("%s: %d bytes starting %q, want %q", test.name, len(data), data[:min(len(data), 8)], test.magic)
+		}
+	}
+}
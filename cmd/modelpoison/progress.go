@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	progressBarWidth = 30
+
+	// ttyProgressInterval is how often the progress bar is redrawn.
+	ttyProgressInterval = 200 * time.Millisecond
+
+	// lineProgressInterval is how often a progress line is written when
+	// stderr is not a terminal.
+	lineProgressInterval = 10 * time.Second
+)
+
+// progressReporter reports scan progress on stderr. On a terminal it
+// redraws a single progress bar; elsewhere it writes periodic lines.
+type progressReporter struct {
+	out      io.Writer
+	label    string
+	tty      bool
+	interval time.Duration
+	start    time.Time
+	last     time.Time
+	drawn    bool
+}
+
+// newProgressReporter creates a reporter, or returns nil when progress
+// output is disabled by --no-progress, the log level or a structured log
+// format.
+func newProgressReporter(label string, disabled bool) *progressReporter {
+	if disabled || !logger.Enabled(slog.LevelInfo) || logger.format != logFormatText {
+		return nil
+	}
+
+	now := time.Now()
+	tty := isTerminal(os.Stderr)
+	interval := lineProgressInterval
+	if tty {
+		interval = ttyProgressInterval
+	}
+
+	return &progressReporter{
+		out:      logger.out,
+		label:    label,
+		tty:      tty,
+		interval: interval,
+		start:    now,
+		last:     now,
+	}
+}
+
+// Update records that processed of total items are done. A zero total
+// means the total is not known, as for chunked scans.
+func (p *progressReporter) Update(processed, total int) {
+	if p == nil {
+		return
+	}
+
+	now := time.Now()
+	if (total <= 0 || processed < total) && now.Sub(p.last) < p.interval {
+		return
+	}
+	p.last = now
+
+	line := p.format(processed, total, now.Sub(p.start))
+	if p.tty {
+		fmt.Fprintf(p.out, "\r%s", line)
+		p.drawn = true
+		return
+	}
+	fmt.Fprintln(p.out, line)
+}
+
+// Finish terminates the progress bar line.
+func (p *progressReporter) Finish() {
+	if p == nil || !p.drawn {
+		return
+	}
+	fmt.Fprintln(p.out)
+	p.drawn = false
+}
+
+// format renders a progress line with throughput and ETA.
+func (p *progressReporter) format(processed, total int, elapsed time.Duration) string {
+	fraction := 1.0
+	if total > 0 {
+		fraction = float64(processed) / float64(total)
+	}
+
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(processed) / elapsed.Seconds()
+	}
+	if total <= 0 {
+		return fmt.Sprintf("%s: %d samples  %.0f samples/s", p.label, processed, rate)
+	}
+
+	eta := "--"
+	if rate > 0 && processed < total {
+		remaining := time.Duration(float64(total-processed) / rate * float64(time.Second))
+		eta = remaining.Round(time.Second).String()
+	}
+
+	counts := fmt.Sprintf("%d/%d samples  %.0f samples/s  ETA %s", processed, total, rate, eta)
+	if !p.tty {
+		return fmt.Sprintf("%s: %3.0f%% %s", p.label, fraction*100, counts)
+	}
+
+	filled := int(fraction * progressBarWidth)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+	return fmt.Sprintf("%s [%s] %3.0f%% %s", p.label, bar, fraction*100, counts)
+}
+
+// isTerminal reports whether f is a character device.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/provenance"
+)
+
+// addProvenanceFlag registers --provenance on fs.
+func addProvenanceFlag(fs *flag.FlagSet) *string {
+	return fs.String("provenance", "", "W3C PROV-JSON provenance of the datasets (default: each dataset's .prov.json sidecar)")
+}
+
+// loadProvenance loads the provenance of the dataset at path: the document
+// given with --provenance, its sidecar, or nil if it has neither.
+func loadProvenance(path string, opts scanOptions) (*provenance.Document, error) {
+	doc := opts.provenance
+	if doc == "" {
+		if doc = provenance.Sidecar(path); doc == "" {
+			return nil, nil
+		}
+	}
+	logger.Debugf("loading provenance of %s from %s", path, doc)
+	return provenance.Load(doc)
+}
+
+// addProvenanceCheck adds the provenance anomaly check of opts to
+// detector, if the dataset scanned has provenance. samples are the samples
+// scanned, or nil when they are streamed.
+func addProvenanceCheck(detector *detect.Detector, opts scanOptions, samples []detect.Sample) error {
+	if opts.origins == nil {
+		return nil
+	}
+	return detector.AddCheck(opts.origins.NewCheck(samples))
+}
+
+// enrichFindings sets the origins of the findings of the scan of path from
+// the provenance of opts.
+func enrichFindings(path string, opts scanOptions, result *detect.DetectionResult, log *cliLogger) {
+	if opts.origins == nil {
+		return
+	}
+	if n := opts.origins.Enrich(result, opts.origins.Dataset(path)); n > 0 {
+		log.Debugf("traced %d findings to their origin", n)
+	}
+}
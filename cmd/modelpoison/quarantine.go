@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/allowlist"
+	"github.com/hallucinaut/modelpoison/pkg/audit"
+	"github.com/hallucinaut/modelpoison/pkg/quarantine"
+	"github.com/hallucinaut/modelpoison/pkg/store"
+)
+
+// defaultQuarantineDir is used when --quarantine is not given.
+const defaultQuarantineDir = ".modelpoison/quarantine"
+
+func manageQuarantine(args []string) error {
+	if len(args) < 1 {
+		return usagef("quarantine requires a subcommand: list, restore or purge")
+	}
+
+	switch args[0] {
+	case "list":
+		return quarantineList(args[1:])
+	case "restore":
+		return quarantineRestore(args[1:])
+	case "purge":
+		return quarantinePurge(args[1:])
+	}
+	return usagef("unknown quarantine subcommand: %s", args[0])
+}
+
+func quarantineList(args []string) error {
+	fs := newFlagSet("quarantine list")
+	dir := fs.String("quarantine", defaultQuarantineDir, "quarantine directory")
+	status := fs.String("status", "", "only list entries with this status (quarantined or restored)")
+	format := fs.String("format", "text", "output format: text or json")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *format != "text" && *format != "json" {
+		return usagef("invalid format %q (want text or json)", *format)
+	}
+
+	store, err := quarantine.Open(*dir)
+	if err != nil {
+		return err
+	}
+	entries := store.Entries(quarantine.Status(*status))
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
+
+	fmt.Printf("%-12s %-20s %-12s %-16s %6s  %-20s %s\n", "ENTRY", "SAMPLE", "STATUS", "TYPE", "SCORE", "QUARANTINED", "DATASET")
+	for _, entry := range entries {
+		fmt.Printf("%-12s %-20s %-12s %-16s %5.0f%%  %-20s %s\n", entry.ID, entry.SampleID, entry.Status,
+			entry.Type, entry.Score*100, entry.QuarantinedAt.Format(time.RFC3339), entry.Dataset)
+	}
+	return nil
+}
+
+func quarantineRestore(args []string) error {
+	fs := newFlagSet("quarantine restore")
+	dir := fs.String("quarantine", defaultQuarantineDir, "quarantine directory")
+	justification := fs.String("justification", "", "why the samples are being restored (required)")
+	reviewer := fs.String("reviewer", os.Getenv("USER"), "who reviewed the samples")
+	into := fs.String("into", "", "dataset to append restored rows to (default: the cleaned dataset)")
+	allowPath := fs.String("allowlist", "", "also record restored samples as false positives in this allowlist")
+	custody := addCustodyFlags(fs)
+	refs, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(refs) == 0 {
+		return usagef("entry or sample IDs required")
+	}
+
+	q, err := quarantine.Open(*dir)
+	if err != nil {
+		return err
+	}
+	restored, err := q.Restore(refs, *justification, *reviewer)
+	if err != nil {
+		return err
+	}
+
+	// Group rows by destination so each file is appended once.
+	byDestination := make(map[string][]quarantine.Entry)
+	var destinations []string
+	for _, entry := range restored {
+		destination := entry.Destination
+		if *into != "" {
+			destination = *into
+		}
+		if destination == "" {
+			return fmt.Errorf("entry %s has no destination dataset; use --into", entry.ID)
+		}
+		if _, ok := byDestination[destination]; !ok {
+			destinations = append(destinations, destination)
+		}
+		byDestination[destination] = append(byDestination[destination], entry)
+	}
+
+	for _, destination := range destinations {
+		if err := appendRows(destination, byDestination[destination]); err != nil {
+			return err
+		}
+		logger.Infof("Restored %d rows into %s", len(byDestination[destination]), destination)
+	}
+
+	ids := make([]string, len(restored))
+	for i, entry := range restored {
+		ids[i] = entry.SampleID
+	}
+	details := map[string]string{
+		"restored":      strconv.Itoa(len(restored)),
+		"sample_ids":    audit.IDs(ids),
+		"destinations":  strings.Join(destinations, ","),
+		"justification": *justification,
+	}
+	if err := recordAudit(audit.ActionQuarantineRestore, *reviewer, *dir, details); err != nil {
+		return err
+	}
+
+	if *allowPath != "" {
+		allow, err := allowlist.Load(*allowPath)
+		if err != nil {
+			return err
+		}
+		decisions := make(map[string]allowlist.Decision, len(restored))
+		for _, entry := range restored {
+			decisions[entry.SampleID] = allowlist.DecisionFalsePositive
+			allow.Set(allowlist.Entry{
+				ID:       entry.SampleID,
+				Decision: allowlist.DecisionFalsePositive,
+				Type:     entry.Type,
+				Note:     *justification,
+				Reviewer: *reviewer,
+			})
+		}
+		if err := allow.Save(*allowPath); err != nil {
+			return err
+		}
+		if err := recordAudit(audit.ActionAllowlistEdit, *reviewer, *allowPath, audit.DecisionDetails(decisions)); err != nil {
+			return err
+		}
+	}
+
+	if err := q.Save(); err != nil {
+		return err
+	}
+	events := make([]store.Event, len(restored))
+	for i, entry := range restored {
+		events[i] = store.Event{SampleID: entry.SampleID, Kind: store.EventRestored, At: entry.RestoredAt, DatasetID: entry.Dataset,
+			Actor: *reviewer, Details: "from " + *dir + ": " + *justification}
+	}
+	if err := custody.record(events); err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored: %d\n", len(restored))
+	return nil
+}
+
+// appendRows appends quarantined rows to a CSV dataset whose header must
+// match the header the rows were quarantined with.
+func appendRows(path string, entries []quarantine.Entry) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+
+	header, err := csv.NewReader(f).Read()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("%s: read header: %w", path, err)
+	}
+	for _, entry := range entries {
+		if strings.Join(header, ",") != strings.Join(entry.Header, ",") {
+			f.Close()
+			return fmt.Errorf("%s: header does not match quarantined sample %s", path, entry.SampleID)
+		}
+	}
+
+	writer := csv.NewWriter(f)
+	for _, entry := range entries {
+		writer.Write(entry.Record)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func quarantinePurge(args []string) error {
+	fs := newFlagSet("quarantine purge")
+	dir := fs.String("quarantine", defaultQuarantineDir, "quarantine directory")
+	all := fs.Bool("all", false, "purge every quarantined sample")
+	olderThan := fs.Duration("older-than", 0, "only purge samples quarantined longer ago than this")
+	custody := addCustodyFlags(fs)
+	refs, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(refs) == 0 && !*all && *olderThan == 0 {
+		return usagef("entry or sample IDs, --all or --older-than required")
+	}
+
+	q, err := quarantine.Open(*dir)
+	if err != nil {
+		return err
+	}
+
+	var cutoff time.Time
+	if *olderThan > 0 {
+		cutoff = time.Now().Add(-*olderThan)
+	}
+	purged := q.Purge(refs, cutoff)
+
+	if err := q.Save(); err != nil {
+		return err
+	}
+	events := make([]store.Event, len(purged))
+	for i, entry := range purged {
+		events[i] = store.Event{SampleID: entry.SampleID, Kind: store.EventRemoved, DatasetID: entry.Dataset, Details: "purged from " + *dir}
+	}
+	if err := custody.record(events); err != nil {
+		return err
+	}
+
+	fmt.Printf("Purged: %d\n", len(purged))
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/report"
+)
+
+func renderReport(args []string) error {
+	fs := newFlagSet("report")
+	format := fs.String("format", "text", "output format: text, json, html or compliance")
+	out := fs.String("out", "", "file to write the report to (default stdout)")
+	datasetName := fs.String("dataset", "", "dataset name for compliance reports (default the tracked dataset path)")
+	filterOpts := addFilterFlags(fs)
+	templatePath := fs.String("template", "", "text/template file for a custom report layout")
+	chartDir := fs.String("charts", "", "html: write charts into this directory and show them in the report")
+	chartFormat := fs.String("chart-format", report.ChartSVG, "chart image format: svg or png")
+	dataPath := fs.String("data", "", "scanned dataset, for the feature and PCA charts")
+	columns := addColumnFlags(fs)
+	summary := fs.Bool("summary", false, "write a short plain-language summary instead of the report")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usagef("result file required")
+	}
+	switch *format {
+	case "text", "json", "html", "compliance":
+	default:
+		return usagef("invalid format %q (want text, json, html or compliance)", *format)
+	}
+	filter, err := filterOpts.filter()
+	if err != nil {
+		return err
+	}
+	if *templatePath != "" && *format != "text" {
+		return usagef("--template requires --format text")
+	}
+	if *format == "compliance" && !filter.IsZero() {
+		return usagef("compliance reports cover every finding; --min-severity, --types and --top cannot be used")
+	}
+	if *summary && *format != "text" && *format != "json" {
+		return usagef("--summary requires --format text or json")
+	}
+	if *summary && (*templatePath != "" || !filter.IsZero()) {
+		return usagef("summaries cover the whole result; --template, --min-severity, --types and --top cannot be used")
+	}
+	if *datasetName != "" && *format != "compliance" && !*summary {
+		return usagef("--dataset requires --format compliance or --summary")
+	}
+	if *chartDir != "" && *format != "html" {
+		return usagef("--charts requires --format html")
+	}
+	if *chartFormat != report.ChartSVG && *chartFormat != report.ChartPNG {
+		return usagef("invalid chart format %q (want svg or png)", *chartFormat)
+	}
+	if *dataPath != "" && *chartDir == "" {
+		return usagef("--data requires --charts")
+	}
+
+	result, err := detect.LoadResult(positional[0])
+	if err != nil {
+		return err
+	}
+	var charts []report.Chart
+	if *chartDir != "" {
+		if charts, err = writeCharts(*chartDir, *chartFormat, *dataPath, columns.columns(), *out, result); err != nil {
+			return err
+		}
+	}
+	result = applyFilter(filter, result)
+	tmpl, err := loadTemplate(*templatePath)
+	if err != nil {
+		return err
+	}
+
+	write := func(w io.Writer) error { return writeReport(w, *format, result, tmpl) }
+	if charts != nil {
+		write = func(w io.Writer) error { return report.WriteHTMLCharts(w, result, charts) }
+	}
+	if *summary {
+		write = func(w io.Writer) error { return writeSummary(w, *format, result, *datasetName) }
+	}
+	if *format == "compliance" {
+		compliance := report.NewCompliance(result, *datasetName, time.Now())
+		write = func(w io.Writer) error { return report.WriteCompliance(w, compliance) }
+	}
+	if *out == "" {
+		return write(os.Stdout)
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	logger.Infof("Wrote %s report to %s", *format, *out)
+	return nil
+}
+
+// writeCharts writes the charts of result into dir, drawing the feature
+// and PCA charts from the dataset at dataPath when set, and returns them
+// with paths relative to the directory of the report at out.
+func writeCharts(dir, format, dataPath string, cols dataset.Columns, out string, result *detect.DetectionResult) ([]report.Chart, error) {
+	var features []string
+	var samples []detect.Sample
+	if dataPath != "" {
+		data, err := loadDataset(dataPath, cols)
+		if err != nil {
+			return nil, err
+		}
+		features, samples = data.Features, data.Samples
+		if len(samples) > 0 && samples[0].Sparse != nil {
+			logger.Warnf("%s has sparse features; only the score chart is drawn", dataPath)
+		}
+	}
+	charts, err := report.WriteCharts(dir, format, result, features, samples)
+	if err != nil {
+		return nil, err
+	}
+	for i := range charts {
+		if out != "" {
+			if rel, err := filepath.Rel(filepath.Dir(out), charts[i].File); err == nil {
+				charts[i].File = rel
+			}
+		}
+		charts[i].File = filepath.ToSlash(charts[i].File)
+	}
+	logger.Infof("Wrote %d charts to %s", len(charts), dir)
+	return charts, nil
+}
+
+// writeSummary writes the executive summary of result to w as text or
+// json.
+func writeSummary(w io.Writer, format string, result *detect.DetectionResult, dataset string) error {
+	summary := report.NewSummary(result, dataset)
+	if format == "json" {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summary)
+	}
+	return report.WriteSummary(w, summary)
+}
+
+// writeReport renders result in format to w. tmpl, if set, replaces the
+// default text layout.
+func writeReport(w io.Writer, format string, result *detect.DetectionResult, tmpl *template.Template) error {
+	switch format {
+	case "json":
+		return detect.WriteResult(w, result)
+	case "html":
+		return report.WriteHTML(w, result)
+	}
+	return writeDetection(w, result, tmpl)
+}
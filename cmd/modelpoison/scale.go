@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hallucinaut/modelpoison/pkg/config"
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/preprocess"
+	"github.com/hallucinaut/modelpoison/pkg/stats"
+)
+
+// scaleFlags holds the feature scaling flags shared by the commands that
+// scan and defend datasets.
+type scaleFlags struct {
+	method string
+	path   string
+}
+
+// addScaleFlags registers --scale and --scaler on fs.
+func addScaleFlags(fs *flag.FlagSet) *scaleFlags {
+	f := &scaleFlags{}
+	fs.StringVar(&f.method, "scale", "", "scale every feature before scanning: none, zscore, minmax or robust")
+	fs.StringVar(&f.path, "scaler", "", "apply the fitted scaler in this file, fitting and saving it there first if it does not exist")
+	return f
+}
+
+// apply sets the scaling of opts: the scaler saved at --scaler when the
+// file exists, otherwise the scaling configured by cfg with --scale
+// replacing its method, fitted to each dataset and saved at --scaler.
+func (f *scaleFlags) apply(opts *scanOptions, cfg *config.Config) error {
+	if _, err := preprocess.ParseMethod(f.method); err != nil {
+		return usagef("--scale: %v", err)
+	}
+	if f.path != "" {
+		scaler, err := preprocess.Load(f.path)
+		if err == nil {
+			if f.method != "" {
+				return usagef("--scale cannot be combined with the existing scaler %s", f.path)
+			}
+			logger.Debugf("loaded scaler %s", f.path)
+			opts.scaler = scaler
+			return nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	if cfg.Scaling != nil {
+		opts.scaling = *cfg.Scaling
+	}
+	if f.method != "" {
+		opts.scaling.Method = preprocess.Method(f.method)
+	}
+	opts.scalerPath = f.path
+	return nil
+}
+
+// datasetScaler returns the scaler of the dataset at path, whose features
+// are names: opts.scaler, or one fitted to the dataset's profile, saved to
+// opts.scalerPath when set. It returns nil when features are not scaled.
+func datasetScaler(path string, names []string, opts scanOptions, profile func() (*stats.Profile, error)) (*preprocess.Scaler, error) {
+	if opts.scaler != nil {
+		if err := opts.scaler.Check(names); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return opts.scaler, nil
+	}
+	if !opts.scaling.Enabled() {
+		return nil, nil
+	}
+
+	p, err := profile()
+	if err != nil {
+		return nil, err
+	}
+	scaler, err := preprocess.Fit(opts.scaling, p)
+	if err != nil {
+		return nil, fmt.Errorf("%s: scaling: %w", path, err)
+	}
+	if opts.scalerPath != "" {
+		if err := scaler.Save(opts.scalerPath); err != nil {
+			return nil, err
+		}
+		logger.Infof("Saved scaler to %s", opts.scalerPath)
+	}
+	return scaler, nil
+}
+
+// scaleDataset returns data with its samples scaled for scanning, or data
+// itself when features are not scaled. The records are shared, so subsets
+// of the scaled dataset save the original values.
+func scaleDataset(path string, data *dataset.Dataset, opts scanOptions) (*dataset.Dataset, error) {
+	scaler, err := datasetScaler(path, data.Features, opts, func() (*stats.Profile, error) {
+		return stats.ProfileSamples(data.Features, data.Samples), nil
+	})
+	if err != nil || scaler == nil {
+		return data, err
+	}
+	if len(data.Samples) > 0 && data.Samples[0].Sparse != nil {
+		return nil, fmt.Errorf("%s: scaling sparse features would make them dense", path)
+	}
+	scaled := *data
+	scaled.Samples = scaler.Transform(data.Samples)
+	return &scaled, nil
+}
+
+// streamScaler returns the scaler of the dataset at path read by reader,
+// fitting it to a profile streamed from a first pass over the file.
+func streamScaler(path string, reader *dataset.Reader, opts scanOptions) (*preprocess.Scaler, error) {
+	return datasetScaler(path, reader.Features(), opts, func() (*stats.Profile, error) {
+		logger.Infof("Profiling %s to fit the scaler", path)
+		return streamProfile(path, opts.columns, spillOptions{rows: stats.DefaultSpillRows})
+	})
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/config"
+	"github.com/hallucinaut/modelpoison/pkg/preprocess"
+)
+
+func TestScaleFlags(t *testing.T) {
+	fromFile := &preprocess.Config{Method: preprocess.MinMax, Features: map[string]preprocess.Method{"age": preprocess.Robust}}
+	for _, test := range []struct {
+		name   string
+		method string
+		config *preprocess.Config
+		want   preprocess.Config
+	}{
+		{"defaults", "", nil, preprocess.Config{}},
+		{"flag", "zscore", nil, preprocess.Config{Method: preprocess.ZScore}},
+		{"config file", "", fromFile, *fromFile},
+		// --scale replaces the configured method but keeps the features
+		// configured separately.
+		{"flag over config file", "zscore", fromFile, preprocess.Config{Method: preprocess.ZScore, Features: fromFile.Features}},
+	} {
+		var opts scanOptions
+		f := &scaleFlags{method: test.method, path: filepath.Join(t.TempDir(), "scaler.json")}
+		if err := f.apply(&opts, &config.Config{Scaling: test.config}); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(opts.scaling, test.want) || opts.scaler != nil || opts.scalerPath != f.path {
+			t.Errorf("%s: scaling %+v, saved to %q; want %+v", test.name, opts.scaling, opts.scalerPath, test.want)
+		}
+	}
+	if fromFile.Method != preprocess.MinMax {
+		t.Errorf("--scale changed the configured method to %s", fromFile.Method)
+	}
+
+	if err := (&scaleFlags{method: "log"}).apply(&scanOptions{}, &config.Config{}); err == nil {
+		t.Error("applied --scale log")
+	}
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/hallucinaut/modelpoison/internal/id"
+	"github.com/hallucinaut/modelpoison/pkg/alert"
+	"github.com/hallucinaut/modelpoison/pkg/archive"
+	"github.com/hallucinaut/modelpoison/pkg/audit"
+	"github.com/hallucinaut/modelpoison/pkg/config"
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/dvc"
+	"github.com/hallucinaut/modelpoison/pkg/eventbus"
+	"github.com/hallucinaut/modelpoison/pkg/inference"
+	"github.com/hallucinaut/modelpoison/pkg/instruct"
+	"github.com/hallucinaut/modelpoison/pkg/multimodal"
+	"github.com/hallucinaut/modelpoison/pkg/preference"
+	"github.com/hallucinaut/modelpoison/pkg/preprocess"
+	"github.com/hallucinaut/modelpoison/pkg/provenance"
+	"github.com/hallucinaut/modelpoison/pkg/script"
+	"github.com/hallucinaut/modelpoison/pkg/siem"
+	"github.com/hallucinaut/modelpoison/pkg/stats"
+	"github.com/hallucinaut/modelpoison/pkg/webhook"
+)
+
+// scanOptions controls how datasets are scanned.
+type scanOptions struct {
+	noProgress bool
+	config     *config.Config
+	columns    dataset.Columns
+
+	// limit and sample restrict the scan to the first limit samples or a
+	// random fraction of samples (chosen with seed) when non-zero.
+	limit  int
+	sample float64
+	seed   int64
+	// workers is the number of goroutines analyzing samples; zero means
+	// GOMAXPROCS.
+	workers int
+	// chunkSize, when set, streams the dataset through the detector in
+	// chunks of chunkSize samples instead of loading it.
+	chunkSize int
+	// maxMemory, when set, is the memory budget of the scan in bytes. Scans
+	// of datasets that do not fit are chunked.
+	maxMemory int64
+	// incremental carries forward the findings of samples unchanged since
+	// previous, when it is set, and records sample hashes in the result.
+	incremental bool
+	previous    *detect.DetectionResult
+	// shard, when set, selects the shards of the dataset to scan, and
+	// checkpoint is the file the progress of the scan is saved to.
+	shard      *detect.ShardSpec
+	checkpoint string
+	// scaler, when set, scales the features of the samples scanned.
+	// Otherwise scaling, when enabled, is fitted to each dataset and the
+	// fitted scaler saved to scalerPath when it is set.
+	scaler     *preprocess.Scaler
+	scaling    preprocess.Config
+	scalerPath string
+	// embedder, when set, replaces the features of loaded samples with
+	// their embeddings, embedBatch samples at a time, before they are
+	// scaled.
+	embedder   inference.Model
+	embedBatch int
+	// encoder, when set, encodes the categorical columns of the datasets
+	// scanned. Otherwise encoding is fitted to the categorical columns of
+	// each dataset and the fitted encoder saved to encoderPath when set.
+	encoder     *preprocess.Encoder
+	encoding    preprocess.EncodingConfig
+	categorical []string
+	encoderPath string
+	// lists, when set, overrides the findings of allowlisted and
+	// denylisted samples.
+	lists detect.Lists
+	// scripts score samples, post-process results and decide scans.
+	scripts []*script.Script
+	// provenance is the PROV-JSON document of the datasets scanned, and
+	// origins the provenance of the dataset being scanned, from it or the
+	// dataset's sidecar.
+	provenance string
+	origins    *provenance.Document
+	// modal selects the modalities of paired multi-modal samples, which
+	// are checked for consistency when set.
+	modal multimodal.Columns
+	// mode is modePreference for datasets of preference pairs and
+	// modeInstruction for instruction-tuning records, whose fields
+	// preference and instruction name, and empty or modeTabular otherwise.
+	mode        string
+	preference  preference.Columns
+	instruction instruct.Columns
+
+	// progress, if set, receives progress instead of the progress bar.
+	progress detect.ProgressFunc
+}
+
+// loadConfig loads the configuration file at path, or returns an empty
+// configuration when path is empty.
+func loadConfig(path string) (*config.Config, error) {
+	if path == "" {
+		return &config.Config{}, nil
+	}
+	logger.Debugf("loading config %s", path)
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordConfig(path); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// newDetector creates a detector configured by cfg.
+func newDetector(cfg *config.Config) (*detect.Detector, error) {
+	detector := detect.NewDetector()
+	if cfg != nil {
+		if err := cfg.Apply(detector); err != nil {
+			return nil, err
+		}
+	}
+	if err := registerPlugins(detector); err != nil {
+		return nil, err
+	}
+	return detector, nil
+}
+
+// scanDataset loads a dataset and runs the detector over it.
+func scanDataset(path string, opts scanOptions) (*detect.DetectionResult, error) {
+	log := logger.With("scan_id", id.New(), "dataset", path)
+
+	var err error
+	if opts.columns, err = encodeColumns(path, opts); err != nil {
+		return nil, err
+	}
+	if opts.origins, err = loadProvenance(path, opts); err != nil {
+		return nil, err
+	}
+	if opts.maxMemory > 0 {
+		chunkSize, err := memoryPlan(path, opts, opts.maxMemory)
+		if err != nil {
+			return nil, err
+		}
+		if chunkSize > 0 && opts.chunkSize == 0 {
+			log.Infof("Scanning %s in chunks of %d samples to stay within --max-memory", path, chunkSize)
+			// Cross-modal checks compare every sample with every other,
+			// so they are dropped rather than failing the scan.
+			if opts.modal.Enabled() {
+				log.Warnf("Skipping the multi-modal checks of %s, which need it loaded, to stay within --max-memory", path)
+				opts.modal = multimodal.Columns{}
+			}
+		}
+		opts.chunkSize = chunkSize
+	}
+
+	var result *detect.DetectionResult
+	if (dataset.IsSVMLight(path) || dataset.IsJSON(path)) && (opts.chunkSize > 0 || opts.shard != nil) {
+		return nil, fmt.Errorf("%s: chunked and sharded scans read CSV datasets; SVMlight and JSON datasets are loaded whole", path)
+	}
+	if opts.chunkSize > 0 && opts.embedder != nil {
+		return nil, fmt.Errorf("%s: embedding samples requires loading the dataset, which does not fit in --max-memory", path)
+	}
+	if opts.chunkSize > 0 && opts.incremental {
+		return nil, fmt.Errorf("%s: incremental scans require loading the dataset, which does not fit in --max-memory", path)
+	}
+	switch {
+	case opts.mode == modePreference || opts.mode == modeInstruction:
+		result, err = scanText(path, opts, log)
+	case opts.shard != nil:
+		result, err = shardDataset(path, opts, log)
+	case opts.chunkSize > 0:
+		result, err = streamDataset(path, opts)
+	default:
+		result, err = loadAndDetect(path, opts, log)
+	}
+	if err == nil {
+		err = checkPlugins()
+	}
+	if err != nil {
+		return nil, err
+	}
+	enrichFindings(path, opts, result, log)
+	if version, err := dvc.Resolve(path); err != nil {
+		log.Warnf("dvc: %v", err)
+	} else if version != nil {
+		result.Version = version
+		log.Debugf("dataset version %s from %s", version.Hash, version.Source)
+		if version.Modified {
+			log.Warnf("%s differs from the version tracked in %s", path, version.Source)
+		}
+	}
+	log.Log(slog.LevelInfo, "scan completed",
+		"samples", result.SampleCount,
+		"poisoned", result.PoisonedCount,
+		"risk_score", result.RiskScore)
+	return result, nil
+}
+
+// loadDataset loads the dataset at path: an SVMlight file of sparse
+// samples, or a JSON or CSV file read with cols.
+func loadDataset(path string, cols dataset.Columns) (*dataset.Dataset, error) {
+	if dataset.IsSVMLight(path) {
+		return dataset.LoadSVMLightContext(commandContext, path)
+	}
+	if dataset.IsJSON(path) {
+		return dataset.LoadJSONContext(commandContext, path, cols)
+	}
+	return dataset.LoadCSVContext(commandContext, path, cols)
+}
+
+// loadAndDetect loads the dataset at path and runs the detector over all
+// of it or the part selected by opts.
+func loadAndDetect(path string, opts scanOptions, log *cliLogger) (*detect.DetectionResult, error) {
+	data, err := loadDataset(path, opts.columns)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("loaded %d samples from %s", len(data.Samples), path)
+	if data, err = embedDataset(path, data, opts); err != nil {
+		return nil, err
+	}
+	if data, err = scaleDataset(path, data, opts); err != nil {
+		return nil, err
+	}
+
+	total := len(data.Samples)
+	mode := ""
+	if opts.sample > 0 {
+		data = data.Sample(opts.sample, opts.seed)
+		mode = detect.PartialSample
+	}
+	if opts.limit > 0 && opts.limit < len(data.Samples) {
+		data = data.Head(opts.limit)
+		if mode == "" {
+			mode = detect.PartialLimit
+		}
+	}
+	if mode != "" {
+		log.Infof("Partial scan: %d of %d samples", len(data.Samples), total)
+	}
+
+	result, err := runDetector(data, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if mode != "" && len(data.Samples) < total {
+		result.MarkPartial(mode, total)
+	}
+	return result, nil
+}
+
+// streamDataset runs the detector over the dataset at path, reading
+// opts.chunkSize samples at a time so memory stays flat however large the
+// dataset is. The result holds only the flagged samples.
+func streamDataset(path string, opts scanOptions) (*detect.DetectionResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reader, err := dataset.NewReader(bufio.NewReaderSize(f, 1<<20), opts.columns)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	scaler, err := streamScaler(path, reader, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	detector, err := newDetector(opts.config)
+	if err != nil {
+		return nil, err
+	}
+	detector.SetWorkers(opts.workers)
+	detector.SetLists(opts.lists)
+	// The statistics of a streamed dataset are not known before the scan.
+	checks, err := addScripts(detector, opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := addProvenanceCheck(detector, opts, nil); err != nil {
+		return nil, err
+	}
+	progress := newProgressReporter("Scanning", opts.noProgress)
+	if opts.progress != nil {
+		detector.SetProgress(opts.progress)
+	} else {
+		detector.SetProgress(progress.Update)
+	}
+
+	stream := detector.NewStream()
+	for {
+		samples, err := reader.Read(opts.chunkSize)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if scaler != nil {
+			samples = scaler.Transform(samples)
+		}
+		if err := stream.Add(commandContext, samples); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	progress.Finish()
+	result := stream.Result()
+	if err := finishScripts(detector, result, opts, nil, checks); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// runDetector runs the detector over a loaded dataset.
+func runDetector(data *dataset.Dataset, opts scanOptions) (*detect.DetectionResult, error) {
+	detector, err := newDetector(opts.config)
+	if err != nil {
+		return nil, err
+	}
+	detector.SetWorkers(opts.workers)
+	detector.SetLists(opts.lists)
+	if err := detector.Validate(data.Samples); err != nil {
+		return nil, err
+	}
+	var profile *stats.Profile
+	for _, s := range opts.scripts {
+		if s.UsesStats() {
+			profile = stats.ProfileSamples(data.Features, data.Samples)
+			break
+		}
+	}
+	checks, err := addScripts(detector, opts, profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := addProvenanceCheck(detector, opts, data.Samples); err != nil {
+		return nil, err
+	}
+	if err := addModalChecks(detector, data, opts); err != nil {
+		return nil, err
+	}
+
+	detectSamples := func() *detect.DetectionResult {
+		if !opts.incremental {
+			return detector.DetectContext(commandContext, data.Samples)
+		}
+		result := detector.DetectIncremental(commandContext, data.Samples, opts.previous)
+		logger.Infof("Incremental scan: reused %d unchanged samples, rescored %d",
+			result.Incremental.Reused, result.Incremental.Rescored)
+		return result
+	}
+
+	var result *detect.DetectionResult
+	if opts.progress != nil {
+		detector.SetProgress(opts.progress)
+		result = detectSamples()
+	} else {
+		progress := newProgressReporter("Scanning", opts.noProgress)
+		detector.SetProgress(progress.Update)
+		result = detectSamples()
+		progress.Finish()
+	}
+
+	if err := finishScripts(detector, result, opts, profile, checks); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// publishScan records a completed scan of path in the audit log, archives
+// its evidence, delivers its webhook events and sends its alerts. resultPath, if set, is the
+// saved result linked from the payload; otherwise the archived result is
+// linked. Failures are logged rather than failing the scan.
+func publishScan(cfg *config.Config, path, resultPath string, result *detect.DetectionResult) {
+	details := audit.ScanDetails(result)
+	if resultPath != "" {
+		details["result"] = resultPath
+	}
+	if err := recordAudit(audit.ActionScan, "", path, details); err != nil {
+		logger.Warnf("%v", err)
+	}
+
+	if cfg == nil {
+		return
+	}
+
+	scan := webhook.Scan{Dataset: path, Result: result}
+	if resultPath != "" {
+		if abs, err := filepath.Abs(resultPath); err == nil {
+			scan.ResultURL = (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String()
+		}
+	}
+
+	if cfg.Archive != nil {
+		objects, err := archiveScan(cfg.Archive, archive.Evidence{Dataset: path, Result: result})
+		if err != nil {
+			logger.Warnf("archive: %v", err)
+		}
+		if scan.ResultURL == "" {
+			scan.ResultURL = archive.URLFor(objects, archive.FormatJSON)
+		}
+		scan.ReportURL = archive.URLFor(objects, archive.FormatHTML)
+	}
+
+	if len(cfg.Webhooks) > 0 {
+		logger.Debugf("notifying %d webhooks for %s", len(cfg.Webhooks), path)
+		if err := webhook.New(cfg.Webhooks, nil).Notify(context.Background(), scan); err != nil {
+			logger.Warnf("%v", err)
+		}
+	}
+	if cfg.Alerts != nil {
+		if err := alert.New(*cfg.Alerts, nil).Alert(context.Background(), scan); err != nil {
+			logger.Warnf("%v", err)
+		}
+	}
+	if len(cfg.SIEM) > 0 {
+		logger.Debugf("exporting %s findings to %d SIEM sinks", path, len(cfg.SIEM))
+		if err := siem.New(cfg.SIEM, nil).Export(context.Background(), scan); err != nil {
+			logger.Warnf("%v", err)
+		}
+	}
+	if len(cfg.EventBus) > 0 {
+		logger.Debugf("publishing %s findings to %d event bus sinks", path, len(cfg.EventBus))
+		bus := eventbus.New(cfg.EventBus)
+		if err := bus.Publish(context.Background(), scan); err != nil {
+			logger.Warnf("event bus: %v", err)
+		}
+		bus.Close()
+	}
+}
+
+// archiveScan uploads the evidence of a scan as configured by cfg.
+func archiveScan(cfg *archive.Config, ev archive.Evidence) ([]archive.Object, error) {
+	archiver, err := archive.New(*cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := archiver.Archive(commandContext, ev)
+	for _, object := range objects {
+		logger.Infof("Archived %s evidence to %s", object.Format, object.URL)
+	}
+	return objects, err
+}
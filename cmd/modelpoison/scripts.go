@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/script"
+	"github.com/hallucinaut/modelpoison/pkg/stats"
+)
+
+// scriptFlags holds the flags naming the scripts of a scan.
+type scriptFlags struct {
+	files   string
+	timeout time.Duration
+}
+
+// addScriptFlags registers --script and --script-timeout on fs.
+func addScriptFlags(fs *flag.FlagSet) *scriptFlags {
+	f := &scriptFlags{}
+	fs.StringVar(&f.files, "script", "", "comma-separated Starlark scripts scoring samples, clearing findings or failing scans")
+	fs.DurationVar(&f.timeout, "script-timeout", script.DefaultTimeout, "cancel a script running longer than this in one call")
+	return f
+}
+
+// load compiles the comma-separated scripts of the flags. Their print
+// output is written to stderr.
+func (f *scriptFlags) load() ([]*script.Script, error) {
+	var scripts []*script.Script
+	for _, path := range strings.Split(f.files, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		s, err := script.Load(commandContext, path, script.Options{Print: os.Stderr, Timeout: f.timeout})
+		if err != nil {
+			return nil, err
+		}
+		if err := recordConfig(path); err != nil {
+			return nil, err
+		}
+		logger.Debugf("loaded script %s", path)
+		scripts = append(scripts, s)
+	}
+	return scripts, nil
+}
+
+// addScripts adds the checks of the scoring scripts of opts to detector,
+// given the statistics of the dataset scanned, if known.
+func addScripts(detector *detect.Detector, opts scanOptions, profile *stats.Profile) ([]*script.Check, error) {
+	var checks []*script.Check
+	for _, s := range opts.scripts {
+		if !s.Scores() {
+			continue
+		}
+		check, err := s.Check(profile)
+		if err != nil {
+			return nil, err
+		}
+		if err := detector.AddCheck(check); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.Path(), err)
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// finishScripts reports the failures of the script checks of a scan and
+// post-processes its result with the scripts of opts, rescoring it with
+// detector when they clear findings.
+func finishScripts(detector *detect.Detector, result *detect.DetectionResult, opts scanOptions, profile *stats.Profile, checks []*script.Check) error {
+	for _, check := range checks {
+		if err := check.Err(); err != nil {
+			return err
+		}
+	}
+	cleared := 0
+	for _, s := range opts.scripts {
+		n, err := s.Process(commandContext, result, profile)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			logger.Infof("Script %s cleared %d findings", s.Path(), n)
+		}
+		cleared += n
+	}
+	if cleared > 0 {
+		detector.Rescore(result)
+	}
+	return nil
+}
+
+// enforceScripts evaluates the deny and warn rules of the scripts of opts
+// against a completed scan of path, returning an error when a script
+// denies it.
+func enforceScripts(path string, opts scanOptions, result *detect.DetectionResult) error {
+	var denied []string
+	for _, s := range opts.scripts {
+		decision, err := s.Decide(commandContext, result)
+		if err != nil {
+			return err
+		}
+		for _, msg := range decision.Warn {
+			logger.Warnf("%s: %s", path, msg)
+		}
+		denied = append(denied, decision.Deny...)
+	}
+	if len(denied) > 0 {
+		return fmt.Errorf("%s failed scripts: %s", path, strings.Join(denied, "; "))
+	}
+	return nil
+}
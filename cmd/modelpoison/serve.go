@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/hallucinaut/modelpoison/pkg/auth"
+	"github.com/hallucinaut/modelpoison/pkg/rpc"
+	"github.com/hallucinaut/modelpoison/pkg/server"
+	"github.com/hallucinaut/modelpoison/pkg/store"
+)
+
+func serveAPI(args []string) error {
+	fs := newFlagSet("serve")
+	addr := fs.String("addr", ":8080", "address to listen on")
+	grpcAddr := fs.String("grpc-addr", "", "also serve the gRPC API on this address")
+	configPath := fs.String("config", "", "detector configuration file")
+	policyFile := addPolicyFlag(fs)
+	publicURL := fs.String("public-url", "", "base URL of the server used in webhook result links")
+	allowFiles := fs.Bool("allow-file-uris", false, "allow datasets to be submitted by local path or file:// URI")
+	allowRemote := fs.Bool("allow-remote-uris", false, "allow datasets to be submitted by http(s) URI, fetched from public addresses only")
+	allowedHosts := fs.String("allowed-hosts", "", "comma-separated hosts that --allow-remote-uris fetches from, whatever their addresses")
+	asyncSamples := fs.Int("async-samples", 100000, "run scans of datasets with this many samples as background jobs (0: only on request)")
+	jobWorkers := fs.Int("job-workers", 1, "number of scan jobs run concurrently")
+	streamBuffer := fs.Int("stream-buffer", server.DefaultStreamBuffer, "findings of each job kept in memory for streaming clients")
+	streamPolicy := fs.String("stream-policy", "block", "when a job's findings buffer is full: block, drop or spill")
+	tempDir := fs.String("temp-dir", "", "directory of findings spilled by --stream-policy spill")
+	drainDelay := fs.Duration("drain-delay", 0, "on shutdown, report not ready for this long before closing listeners")
+	shutdownTimeout := fs.Duration("shutdown-timeout", 30*time.Second, "on shutdown, wait this long for in-flight requests")
+	tlsCert := fs.String("tls-cert", "", "serve over TLS with this certificate (PEM)")
+	tlsKey := fs.String("tls-key", "", "private key of --tls-cert (PEM)")
+	clientCA := fs.String("client-ca", "", "require client certificates signed by these CAs (PEM)")
+	maxUploadMB := fs.Int64("max-upload-mb", 256, "reject request bodies and fetched datasets larger than this many MiB (0: no limit)")
+	rateLimit := fs.Float64("rate-limit", 20, "requests per second accepted from each client (0: no limit)")
+	rateBurst := fs.Int("rate-burst", 40, "requests a client may send at once above --rate-limit")
+	pprofAddr := fs.String("pprof-addr", "", "serve runtime profiles under /debug/pprof/ on this address")
+	storeDSN := fs.String("store", "", "record results and review decisions in this SQLite file or postgres:// database")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usagef("unexpected argument %q", positional[0])
+	}
+	if *asyncSamples < 0 {
+		return usagef("--async-samples must not be negative")
+	}
+	if *jobWorkers < 1 {
+		return usagef("--job-workers must be at least 1")
+	}
+	if *streamBuffer < 1 {
+		return usagef("--stream-buffer must be at least 1")
+	}
+	policy, err := server.ParseStreamPolicy(*streamPolicy)
+	if err != nil {
+		return usagef("--stream-policy: %v", err)
+	}
+	if *drainDelay < 0 || *shutdownTimeout < 0 {
+		return usagef("--drain-delay and --shutdown-timeout must not be negative")
+	}
+	if (*tlsCert == "") != (*tlsKey == "") {
+		return usagef("--tls-cert and --tls-key must be used together")
+	}
+	if *clientCA != "" && *tlsCert == "" {
+		return usagef("--client-ca requires --tls-cert")
+	}
+	if *allowedHosts != "" && !*allowRemote {
+		return usagef("--allowed-hosts requires --allow-remote-uris")
+	}
+	if *maxUploadMB < 0 || *rateLimit < 0 || *rateBurst < 0 {
+		return usagef("--max-upload-mb, --rate-limit and --rate-burst must not be negative")
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if err := applyPolicyFile(cfg, *policyFile); err != nil {
+		return err
+	}
+
+	var tlsConfig *tls.Config
+	if *tlsCert != "" {
+		if tlsConfig, err = auth.ServerTLS(*tlsCert, *tlsKey, *clientCA); err != nil {
+			return fmt.Errorf("tls: %w", err)
+		}
+	}
+	var authenticator *auth.Authenticator
+	if cfg.Auth.Enabled() || *clientCA != "" {
+		authenticator = auth.New(cfg.Auth)
+		if len(cfg.Auth.Tokens) > 0 && tlsConfig == nil {
+			logger.Warnf("bearer tokens are accepted over plain HTTP; set --tls-cert to protect them")
+		}
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	var db *store.Store
+	if *storeDSN != "" {
+		if db, err = store.Open(ctx, *storeDSN); err != nil {
+			return err
+		}
+		defer db.Close()
+		name := *storeDSN
+		if u, err := url.Parse(name); err == nil && u.User != nil {
+			name = u.Redacted()
+		}
+		logger.Infof("Recording results in %s", name)
+	}
+	auditLog, err := openAuditLog()
+	if err != nil {
+		return err
+	}
+	if auditLog != nil {
+		logger.Infof("Recording audit log in %s", auditLog.Path())
+	}
+
+	errs := make(chan error, 3)
+
+	var (
+		grpcServer *grpc.Server
+		health     *grpchealth.Server
+	)
+	if *grpcAddr != "" {
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			return err
+		}
+		var grpcOpts []grpc.ServerOption
+		if tlsConfig != nil {
+			grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		}
+		grpcOpts = append(grpcOpts, rpc.Limit(int(*maxUploadMB<<20), *rateLimit, *rateBurst)...)
+		if authenticator != nil {
+			grpcOpts = append(grpcOpts, rpc.Auth(authenticator)...)
+		}
+		grpcServer = grpc.NewServer(grpcOpts...)
+		service := rpc.New(cfg)
+		service.SetAudit(auditLog)
+		service.Register(grpcServer)
+		health = grpchealth.NewServer()
+		healthpb.RegisterHealthServer(grpcServer, health)
+		defer grpcServer.Stop()
+
+		logger.Infof("Serving gRPC API on %s", *grpcAddr)
+		go func() { errs <- grpcServer.Serve(lis) }()
+	}
+
+	api := server.New(server.Options{
+		Config:        cfg,
+		AllowFileURIs: *allowFiles,
+		RemoteURIs:    *allowRemote,
+		AllowedHosts:  splitList(*allowedHosts),
+		PublicURL:     *publicURL,
+		Logger:        logger.slog,
+		AsyncSamples:  *asyncSamples,
+		JobWorkers:    *jobWorkers,
+		StreamBuffer:  *streamBuffer,
+		StreamPolicy:  policy,
+		StreamDir:     *tempDir,
+		Auth:          authenticator,
+		MaxBodyBytes:  *maxUploadMB << 20,
+		RateLimit:     *rateLimit,
+		RateBurst:     *rateBurst,
+		Store:         db,
+		Audit:         auditLog,
+	})
+	if err := api.Check(); err != nil {
+		api.Close()
+		return err
+	}
+	if err := api.Restore(ctx); err != nil {
+		api.Close()
+		return err
+	}
+	if *pprofAddr != "" {
+		profiling, err := serveProfiles(*pprofAddr, authenticator, tlsConfig, errs)
+		if err != nil {
+			api.Close()
+			return err
+		}
+		defer profiling.Close()
+	}
+	srv := &http.Server{
+		Addr:      *addr,
+		Handler:   api,
+		TLSConfig: tlsConfig,
+		ErrorLog:  slog.NewLogLogger(logger.slog.Handler(), slog.LevelWarn),
+	}
+
+	logger.Infof("Serving API on %s", *addr)
+	go func() {
+		var err error
+		if tlsConfig != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if !errors.Is(err, http.ErrServerClosed) {
+			errs <- err
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+	case err = <-errs:
+		stop()
+	}
+
+	// Fail readiness first so load balancers stop sending requests, then
+	// let in-flight requests finish. Scan jobs only live in memory, so
+	// running ones are aborted, which also ends their findings streams.
+	api.Drain()
+	if health != nil {
+		health.Shutdown()
+	}
+	if err == nil && *drainDelay > 0 {
+		logger.Infof("Draining for %s", *drainDelay)
+		time.Sleep(*drainDelay)
+	}
+
+	api.Close()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	if serr := srv.Shutdown(shutdownCtx); serr != nil {
+		logger.Warnf("HTTP shutdown: %v", serr)
+	}
+	if grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			grpcServer.Stop()
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// checkpointInterval is the least time between checkpoints of a scan.
+const checkpointInterval = 10 * time.Second
+
+// shardChunkSize is the number of samples read at a time by sharded scans
+// without --chunk-size.
+const shardChunkSize = 4096
+
+// shardDataset scans the shards of the dataset at path selected by
+// opts.shard, streaming it like streamDataset. With opts.checkpoint, the
+// scan resumes from the checkpoint file when it exists, saves its progress
+// there as it goes and saves its result there when done.
+func shardDataset(path string, opts scanOptions, log *cliLogger) (*detect.DetectionResult, error) {
+	detector, err := newDetector(opts.config)
+	if err != nil {
+		return nil, err
+	}
+	detector.SetWorkers(opts.workers)
+	detector.SetLists(opts.lists)
+	if err := addProvenanceCheck(detector, opts, nil); err != nil {
+		return nil, err
+	}
+
+	var previous *detect.DetectionResult
+	if opts.checkpoint != "" {
+		previous, err = detect.LoadResult(opts.checkpoint)
+		if errors.Is(err, os.ErrNotExist) {
+			previous = nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	stream, err := detector.NewShardStream(*opts.shard, previous)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", opts.checkpoint, err)
+	}
+	if previous != nil {
+		if previous.Shard.Complete {
+			log.Infof("Shards %s of %s are already complete in %s", opts.shard, path, opts.checkpoint)
+			return previous, nil
+		}
+		log.Infof("Resuming shards %s of %s after row %d", opts.shard, path, previous.Shard.Next)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reader, err := dataset.NewReader(bufio.NewReaderSize(f, 1<<20), opts.columns)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	scaler, err := streamScaler(path, reader, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := newProgressReporter("Scanning", opts.noProgress)
+	if opts.progress != nil {
+		detector.SetProgress(opts.progress)
+	} else {
+		detector.SetProgress(progress.Update)
+	}
+
+	chunkSize := opts.chunkSize
+	if chunkSize == 0 {
+		chunkSize = shardChunkSize
+	}
+	saved := time.Now()
+	for {
+		samples, err := reader.Read(chunkSize)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if scaler != nil {
+			samples = scaler.Transform(samples)
+		}
+		if err := stream.Add(commandContext, samples); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if opts.checkpoint != "" && time.Since(saved) >= checkpointInterval {
+			if err := saveCheckpoint(opts.checkpoint, stream.Checkpoint()); err != nil {
+				return nil, err
+			}
+			log.Debugf("checkpoint saved to %s", opts.checkpoint)
+			saved = time.Now()
+		}
+	}
+	progress.Finish()
+
+	result := stream.Result()
+	if opts.checkpoint != "" {
+		if err := saveCheckpoint(opts.checkpoint, result); err != nil {
+			return nil, err
+		}
+		log.Infof("Saved shards %s to %s", opts.shard, opts.checkpoint)
+	}
+	return result, nil
+}
+
+// saveCheckpoint saves result to path by renaming a temporary file over
+// it, so a scan stopped while saving leaves the previous checkpoint.
+func saveCheckpoint(path string, result *detect.DetectionResult) error {
+	tmp := path + ".tmp"
+	if err := detect.SaveResult(tmp, result); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("save checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("save checkpoint: %w", err)
+	}
+	return nil
+}
+
+func mergeShardResults(args []string) error {
+	fs := newFlagSet("merge")
+	format := fs.String("format", "text", "output format: text or json")
+	configPath := fs.String("config", "", "detector configuration the shards were scanned with")
+	saveResult := fs.String("save-result", "", "also save the merged result as JSON to this file")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 1 {
+		return usagef("merge requires shard result files")
+	}
+	if *format != "text" && *format != "json" {
+		return usagef("invalid format %q (want text or json)", *format)
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	detector, err := newDetector(cfg)
+	if err != nil {
+		return err
+	}
+	parts := make([]*detect.DetectionResult, len(positional))
+	for i, path := range positional {
+		if parts[i], err = detect.LoadResult(path); err != nil {
+			return err
+		}
+	}
+	result, err := detector.MergeShards(parts)
+	if err != nil {
+		return err
+	}
+	logger.Debugf("merged %d shard results: %d samples, %d poisoned", len(parts), result.SampleCount, result.PoisonedCount)
+
+	if *saveResult != "" {
+		if err := detect.SaveResult(*saveResult, result); err != nil {
+			return err
+		}
+		logger.Infof("Saved result to %s", *saveResult)
+	}
+	if *format == "json" {
+		return detect.WriteResult(os.Stdout, result)
+	}
+	return printDetection(result, nil)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/stats"
+)
+
+func profileDataset(args []string) error {
+	fs := newFlagSet("stats")
+	columns := addColumnFlags(fs)
+	format := fs.String("format", "text", "output format: text or json")
+	cacheDir := fs.String("cache-dir", "", "profile cache directory (default: the user cache directory)")
+	noCache := fs.Bool("no-cache", false, "profile the dataset even if a cached profile exists")
+	approximate := fs.Bool("approximate", false, "estimate quantiles in one streaming pass in bounded memory")
+	dedupMemory := fs.String("dedup-memory", "", "memory for duplicate detection with --approximate before spilling to disk, such as 256MB")
+	tempDir := fs.String("temp-dir", "", "directory for duplicate detection spill files (default: the system temporary directory)")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usagef("dataset required")
+	}
+	if *format != "text" && *format != "json" {
+		return usagef("invalid format %q (want text or json)", *format)
+	}
+	spill := spillOptions{dir: *tempDir, rows: stats.DefaultSpillRows}
+	if *dedupMemory != "" {
+		size, err := parseSize(*dedupMemory)
+		if err != nil {
+			return usagef("--dedup-memory: %v", err)
+		}
+		spill.rows = int(size / stats.SpillRowSize)
+	}
+
+	profile, err := loadProfile(positional[0], columns.columns(), *cacheDir, *noCache, *approximate, spill)
+	if err != nil {
+		return err
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(profile)
+	}
+
+	fmt.Print(stats.GenerateReport(profile))
+	return nil
+}
+
+// spillOptions bound the memory of duplicate detection in approximate
+// profiles; see stats.Profiler.SetSpill.
+type spillOptions struct {
+	dir  string
+	rows int
+}
+
+// loadProfile returns the profile of the dataset at path, from the profile
+// cache in dir when the dataset is unchanged since it was last profiled.
+// Cache failures are logged and the dataset is profiled.
+func loadProfile(path string, cols dataset.Columns, dir string, noCache, approximate bool, spill spillOptions) (*stats.Profile, error) {
+	if dir == "" {
+		var err error
+		if dir, err = stats.DefaultCacheDir(); err != nil {
+			logger.Debugf("profile cache disabled: %v", err)
+		}
+	}
+	cache := stats.Cache{Dir: dir}
+	key := ""
+	if dir != "" {
+		var err error
+		if key, err = stats.CacheKey(path, cols, approximate); err != nil {
+			return nil, err
+		}
+		if profile, ok := cache.Get(key); ok && !noCache {
+			logger.Debugf("using cached profile %s of %s", key[:12], path)
+			return profile, nil
+		}
+	}
+
+	var profile *stats.Profile
+	if approximate {
+		var err error
+		if profile, err = streamProfile(path, cols, spill); err != nil {
+			return nil, err
+		}
+	} else {
+		data, err := dataset.LoadCSVContext(commandContext, path, cols)
+		if err != nil {
+			return nil, err
+		}
+		logger.Debugf("loaded %d samples from %s", len(data.Samples), path)
+		profile = stats.ProfileSamples(data.Features, data.Samples)
+	}
+	if key != "" {
+		if err := cache.Put(key, profile); err != nil {
+			logger.Warnf("%v", err)
+		}
+	}
+	return profile, nil
+}
+
+// streamProfile profiles the dataset at path approximately, reading it a
+// chunk at a time.
+func streamProfile(path string, cols dataset.Columns, spill spillOptions) (*stats.Profile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reader, err := dataset.NewReader(bufio.NewReaderSize(f, 1<<20), cols)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	profiler := stats.NewProfiler(reader.Features(), true)
+	profiler.SetSpill(spill.dir, spill.rows)
+	defer func() {
+		if err := profiler.Close(); err != nil {
+			logger.Warnf("%v", err)
+		}
+	}()
+	for {
+		samples, err := reader.Read(4096)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, sample := range samples {
+			profiler.Add(sample)
+		}
+	}
+	profile := profiler.Profile()
+	if err := profiler.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return profile, nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hallucinaut/modelpoison/pkg/telemetry"
+)
+
+// tracing holds the tracing flags and the state of the command span.
+type tracing struct {
+	endpoint string
+	insecure bool
+
+	started  bool
+	span     trace.Span
+	shutdown func(context.Context) error
+}
+
+// globalTracing is bound to the tracing flags of every flag set.
+var globalTracing tracing
+
+// commandContext carries the command span. Commands pass it to the
+// dataset, detect and defend packages so their spans join the trace.
+var commandContext = context.Background()
+
+// addTracingFlags registers --otlp-endpoint and --otlp-insecure on fs.
+func addTracingFlags(fs *flag.FlagSet) {
+	fs.StringVar(&globalTracing.endpoint, "otlp-endpoint", globalTracing.endpoint, "export OpenTelemetry traces to this OTLP/gRPC collector")
+	fs.BoolVar(&globalTracing.insecure, "otlp-insecure", globalTracing.insecure, "connect to the OTLP collector without TLS")
+}
+
+// startTracing configures trace export and starts the span of command. It
+// is called once flags are parsed and does nothing on later calls.
+func startTracing(command string) error {
+	t := &globalTracing
+	if t.started {
+		return nil
+	}
+	t.started = true
+
+	opts := telemetry.Options{
+		Endpoint:       t.endpoint,
+		Insecure:       t.insecure,
+		ServiceName:    "modelpoison",
+		ServiceVersion: version,
+	}
+	shutdown, err := telemetry.Setup(context.Background(), opts)
+	if err != nil {
+		return fmt.Errorf("otlp: %w", err)
+	}
+	t.shutdown = shutdown
+	if opts.Enabled() {
+		logger.Debugf("exporting traces over OTLP")
+	}
+
+	ctx := telemetry.FromEnvironment(context.Background())
+	commandContext, t.span = otel.Tracer("github.com/hallucinaut/modelpoison/cmd/modelpoison").Start(ctx, "modelpoison "+command)
+	if sc := t.span.SpanContext(); sc.IsValid() {
+		logger = logger.With("trace_id", sc.TraceID().String())
+		slog.SetDefault(logger.slog)
+	}
+	return nil
+}
+
+// stopTracing ends the command span, recording err, and flushes exported
+// spans.
+func stopTracing(err error) {
+	t := &globalTracing
+	if t.span != nil {
+		telemetry.End(t.span, err)
+		t.span = nil
+	}
+
+	if t.shutdown != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := t.shutdown(ctx); err != nil {
+			logger.Errorf("otlp: %v", err)
+		}
+		t.shutdown = nil
+	}
+}
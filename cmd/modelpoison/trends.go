@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/config"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/store"
+)
+
+// minTrendScans is the number of scans a trend needs before it is reported
+// as rising.
+const minTrendScans = 3
+
+// trendPoint is one scan of a dataset's trend.
+type trendPoint struct {
+	ScanID      string                    `json:"scan_id"`
+	CreatedAt   time.Time                 `json:"created_at"`
+	SampleCount int                       `json:"sample_count"`
+	Flagged     int                       `json:"flagged"`
+	FlaggedRate float64                   `json:"flagged_rate"`
+	RiskScore   float64                   `json:"risk_score"`
+	TypeCounts  map[detect.PoisonType]int `json:"type_counts"`
+}
+
+// trend summarizes the scans of a dataset over time. The slopes are least
+// squares fits per week; a series is rising when its slope is positive and
+// it rose or held at three of four scans or more.
+type trend struct {
+	DatasetID        string       `json:"dataset_id"`
+	Scans            []trendPoint `json:"scans"`
+	FlaggedRateSlope float64      `json:"flagged_rate_per_week"`
+	RiskSlope        float64      `json:"risk_score_per_week"`
+	FlaggedRateRises bool         `json:"flagged_rate_rising"`
+	RiskRises        bool         `json:"risk_score_rising"`
+}
+
+func showTrends(args []string) error {
+	fs := newFlagSet("trends")
+	storeDSN := fs.String("store", "", "SQLite file or postgres:// database the server records results in")
+	project := fs.String("project", config.DefaultProject, "project the dataset was scanned in")
+	since := fs.String("since", "", "only include scans after this duration ago (such as 720h) or date (2006-01-02)")
+	limit := fs.Int("limit", store.DefaultLimit, "include at most the N most recent scans")
+	format := fs.String("format", "text", "output format: text or json")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usagef("trends requires a dataset ID")
+	}
+	if *storeDSN == "" {
+		return usagef("trends requires --store")
+	}
+	if *format != "text" && *format != "json" {
+		return usagef("invalid format %q (want text or json)", *format)
+	}
+	if *limit <= 0 {
+		return usagef("--limit must be positive")
+	}
+	q := store.Query{Project: *project, DatasetID: positional[0], Limit: *limit}
+	if *since != "" {
+		if q.Since, err = parseSince(*since, time.Now()); err != nil {
+			return usagef("--since: %v", err)
+		}
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+	db, err := store.Open(ctx, *storeDSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	runs, err := db.Trend(ctx, q)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		return fmt.Errorf("no scans of dataset %s in project %s", q.DatasetID, q.Project)
+	}
+	logger.Debugf("%d scans of dataset %s", len(runs), q.DatasetID)
+	t := newTrend(q.DatasetID, runs)
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(t)
+	}
+	printTrend(t)
+	return nil
+}
+
+// parseSince parses a --since value: a duration before now, or a date.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid duration or date %q", value)
+	}
+	return date, nil
+}
+
+// newTrend returns the trend of runs, the scans of a dataset oldest first.
+func newTrend(datasetID string, runs []store.Run) trend {
+	t := trend{DatasetID: datasetID}
+	times := make([]float64, len(runs))
+	rates := make([]float64, len(runs))
+	risks := make([]float64, len(runs))
+	for i, run := range runs {
+		p := trendPoint{
+			ScanID:      run.ID,
+			CreatedAt:   run.CreatedAt,
+			SampleCount: run.SampleCount,
+			Flagged:     run.PoisonedCount,
+			RiskScore:   run.RiskScore,
+			TypeCounts:  run.TypeCounts,
+		}
+		if p.TypeCounts == nil {
+			p.TypeCounts = map[detect.PoisonType]int{}
+		}
+		if run.SampleCount > 0 {
+			p.FlaggedRate = float64(run.PoisonedCount) / float64(run.SampleCount)
+		}
+		t.Scans = append(t.Scans, p)
+		times[i] = run.CreatedAt.Sub(runs[0].CreatedAt).Hours() / (7 * 24)
+		rates[i], risks[i] = p.FlaggedRate, p.RiskScore
+	}
+	t.FlaggedRateSlope = slope(times, rates)
+	t.RiskSlope = slope(times, risks)
+	t.FlaggedRateRises = rising(rates, t.FlaggedRateSlope)
+	t.RiskRises = rising(risks, t.RiskSlope)
+	return t
+}
+
+// slope returns the least squares slope of y over x, or 0 when x does not
+// vary.
+func slope(x, y []float64) float64 {
+	n := float64(len(x))
+	var sx, sy float64
+	for i := range x {
+		sx += x[i]
+		sy += y[i]
+	}
+	mx, my := sx/n, sy/n
+	var cov, vx float64
+	for i := range x {
+		cov += (x[i] - mx) * (y[i] - my)
+		vx += (x[i] - mx) * (x[i] - mx)
+	}
+	if vx == 0 {
+		return 0
+	}
+	return cov / vx
+}
+
+// rising reports whether series, with least squares slope s, creeps up:
+// s is positive and at least three of four steps between consecutive scans
+// did not decrease, so a single spike does not make a trend.
+func rising(series []float64, s float64) bool {
+	if len(series) < minTrendScans || s <= 0 {
+		return false
+	}
+	steady := 0
+	for i := 1; i < len(series); i++ {
+		if series[i] >= series[i-1] {
+			steady++
+		}
+	}
+	return 4*steady >= 3*(len(series)-1)
+}
+
+// printTrend writes t as a table of scans followed by the fitted slopes.
+func printTrend(t trend) {
+	var types []detect.PoisonType
+	for _, typ := range detect.PoisonTypes() {
+		for _, p := range t.Scans {
+			if p.TypeCounts[typ] > 0 {
+				types = append(types, typ)
+				break
+			}
+		}
+	}
+
+	fmt.Printf("Trend of dataset %s (%d scans)\n\n", t.DatasetID, len(t.Scans))
+	fmt.Printf("%-16s %-20s %8s %8s %7s %6s", "SCANNED", "SCAN", "SAMPLES", "FLAGGED", "RATE", "RISK")
+	for _, typ := range types {
+		fmt.Printf(" %16s", strings.ToUpper(string(typ)))
+	}
+	fmt.Println()
+	for _, p := range t.Scans {
+		fmt.Printf("%-16s %-20s %8d %8d %6.2f%% %6.3f", p.CreatedAt.UTC().Format("2006-01-02 15:04"),
+			p.ScanID, p.SampleCount, p.Flagged, 100*p.FlaggedRate, p.RiskScore)
+		for _, typ := range types {
+			fmt.Printf(" %16d", p.TypeCounts[typ])
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+	fmt.Printf("Flagged rate: %+.3f%% per week%s\n", 100*t.FlaggedRateSlope, risingNote(t.FlaggedRateRises))
+	fmt.Printf("Risk score:   %+.4f per week%s\n", t.RiskSlope, risingNote(t.RiskRises))
+	if t.FlaggedRateRises || t.RiskRises {
+		fmt.Println()
+		fmt.Println("Findings are rising steadily across scans, which can indicate a slow")
+		fmt.Println("poisoning campaign; review the samples added since the earliest scan.")
+	}
+}
+
+// risingNote marks rising series in printTrend.
+func risingNote(rises bool) string {
+	if rises {
+		return " (rising)"
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/truth"
+)
+
+// addTruthFlag registers --truth on fs.
+func addTruthFlag(fs *flag.FlagSet) *string {
+	return fs.String("truth", "", "ground-truth sidecar listing the poisoned sample IDs (JSON, CSV, an attack manifest or one ID per line) instead of a \"poisoned\" column")
+}
+
+// loadTruth loads the ground-truth sidecar at path, or returns nil when
+// path is empty.
+func loadTruth(path string) (*truth.Truth, error) {
+	if path == "" {
+		return nil, nil
+	}
+	t, err := truth.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	logger.Debugf("loaded ground truth of %d samples from %s", len(t.Entries()), path)
+	return t, nil
+}
+
+// groundTruth returns which samples of data, loaded from path, are
+// poisoned and the poison type of each: those listed by t, or those of
+// its "poisoned" column when t is nil, whose types are unknown.
+func groundTruth(path string, data *dataset.Dataset, t *truth.Truth) ([]bool, []string, error) {
+	if t == nil {
+		if data.Poisoned == nil {
+			return nil, nil, fmt.Errorf("%s: no \"poisoned\" ground-truth column", path)
+		}
+		return data.Poisoned, make([]string, len(data.Samples)), nil
+	}
+	poisoned, types, found := t.Label(data.Samples)
+	listed := len(t.Entries())
+	if found == 0 && listed > 0 {
+		return nil, nil, fmt.Errorf("%s: none of the %d samples of the ground truth found", path, listed)
+	}
+	if found < listed {
+		logger.Warnf("%s: %d of the %d samples of the ground truth not found", path, listed-found, listed)
+	}
+	return poisoned, types, nil
+}
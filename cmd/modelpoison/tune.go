@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hallucinaut/modelpoison/pkg/config"
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/preprocess"
+	"github.com/hallucinaut/modelpoison/pkg/truth"
+	"github.com/hallucinaut/modelpoison/pkg/tune"
+)
+
+func tuneThresholds(args []string) error {
+	fs := newFlagSet("tune")
+	columns := addColumnFlags(fs)
+	labeled := fs.String("labeled", "", "labeled dataset with a \"poisoned\" ground-truth column or --truth sidecar")
+	truthPath := addTruthFlag(fs)
+	out := fs.String("out", "thresholds.yaml", "threshold config file to write")
+	configPath := fs.String("config", "", "starting detector configuration file")
+	opts := tune.DefaultOptions()
+	fs.Float64Var(&opts.TargetPrecision, "target-precision", 0, "maximize recall subject to this precision")
+	fs.Float64Var(&opts.Beta, "beta", opts.Beta, "recall weight of the F-beta objective")
+	fs.Float64Var(&opts.Step, "step", opts.Step, "threshold grid spacing")
+	search := fs.Bool("search", false, "grid search every combination of check thresholds and scaling methods instead of coordinate descent")
+	gridValues := fs.String("grid", "", "comma-separated thresholds each check tries with --search (default 0.1 to 0.9)")
+	scalings := fs.String("scalings", "", "comma-separated scaling methods tried with --search (default none,zscore,minmax,robust)")
+	folds := fs.Int("folds", 0, "also cross-validate the tuning over this many folds, reporting the variance across them")
+	seed := fs.Int64("seed", 1, "random seed of the cross-validation folds")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *labeled == "" {
+		return usagef("--labeled dataset required")
+	}
+	groundTruthFile, err := loadTruth(*truthPath)
+	if err != nil {
+		return err
+	}
+	if *folds == 1 || *folds < 0 {
+		return usagef("--folds must be at least 2")
+	}
+	if *search {
+		if *folds > 0 {
+			return usagef("--folds does not support --search")
+		}
+		return searchParameters(*labeled, *out, *configPath, columns, groundTruthFile, *gridValues, *scalings, opts)
+	}
+	if *gridValues != "" || *scalings != "" {
+		return usagef("--grid and --scalings require --search")
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	detector, err := newDetector(cfg)
+	if err != nil {
+		return err
+	}
+
+	data, err := dataset.LoadCSVContext(commandContext, *labeled, columns.columns())
+	if err != nil {
+		return err
+	}
+	poisoned, _, err := groundTruth(*labeled, data, groundTruthFile)
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("Tuning thresholds on %d labeled samples", len(data.Samples))
+
+	result, err := tune.Tune(detector, data.Samples, poisoned, opts)
+	if err != nil {
+		return err
+	}
+
+	tuned := &config.Config{Thresholds: make(map[string]float64)}
+	for t, threshold := range result.Thresholds {
+		tuned.Thresholds[string(t)] = threshold
+	}
+	if err := tuned.Save(*out); err != nil {
+		return err
+	}
+	logger.Infof("Wrote thresholds to %s", *out)
+	if err := recordConfig(*out); err != nil {
+		return err
+	}
+
+	fmt.Print(tune.GenerateReport(result))
+	if !result.TargetMet {
+		logger.Warnf("target precision %.2f not reached", opts.TargetPrecision)
+	}
+
+	if *folds > 0 {
+		logger.Infof("Cross-validating over %d folds", *folds)
+		cv, err := tune.CrossValidate(detector, data.Samples, poisoned, *folds, *seed, opts)
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+		fmt.Print(tune.GenerateCrossValidationReport(cv))
+		if gap := cv.TrainFScore.Mean - cv.FScore.Mean; gap > 0.1 {
+			logger.Warnf("held-out F-score is %.3f below the tuning folds'; the thresholds may be overfit to %s", gap, *labeled)
+		}
+	}
+
+	return nil
+}
+
+// searchParameters grid searches the thresholds and scaling of the
+// detector against the comma-separated labeled datasets, writing the best
+// configuration to out. Their ground truth is listed by groundTruthFile,
+// if not nil.
+func searchParameters(labeled, out, configPath string, columns *columnFlags, groundTruthFile *truth.Truth, gridValues, scalings string, opts tune.Options) error {
+	grid := tune.DefaultGrid()
+	if gridValues != "" {
+		var values []float64
+		for _, field := range strings.Split(gridValues, ",") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+			if err != nil || v < 0 || v > 1 {
+				return usagef("invalid --grid threshold %q", field)
+			}
+			values = append(values, v)
+		}
+		for t := range grid.Thresholds {
+			grid.Thresholds[t] = values
+		}
+	}
+	if scalings != "" {
+		grid.Scaling = nil
+		for _, field := range strings.Split(scalings, ",") {
+			method, err := preprocess.ParseMethod(strings.TrimSpace(field))
+			if err != nil {
+				return usagef("--scalings: %v", err)
+			}
+			grid.Scaling = append(grid.Scaling, method)
+		}
+	}
+
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	detector, err := newDetector(cfg)
+	if err != nil {
+		return err
+	}
+
+	var data []tune.Labeled
+	samples := 0
+	for _, path := range strings.Split(labeled, ",") {
+		path = strings.TrimSpace(path)
+		d, err := dataset.LoadCSVContext(commandContext, path, columns.columns())
+		if err != nil {
+			return err
+		}
+		poisoned, _, err := groundTruth(path, d, groundTruthFile)
+		if err != nil {
+			return err
+		}
+		data = append(data, tune.Labeled{Features: d.Features, Samples: d.Samples, Poisoned: poisoned})
+		samples += len(d.Samples)
+	}
+
+	logger.Infof("Searching %d configurations on %d labeled samples", grid.Size(), samples)
+	result, err := tune.Search(detector, data, grid, opts)
+	if err != nil {
+		return err
+	}
+
+	tuned := &config.Config{Thresholds: make(map[string]float64)}
+	for t, threshold := range result.Thresholds {
+		tuned.Thresholds[string(t)] = threshold
+	}
+	if result.Scaling != preprocess.None {
+		tuned.Scaling = &preprocess.Config{Method: result.Scaling}
+	}
+	if err := tuned.Save(out); err != nil {
+		return err
+	}
+	logger.Infof("Wrote the best configuration to %s", out)
+	if err := recordConfig(out); err != nil {
+		return err
+	}
+
+	fmt.Print(tune.GenerateSearchReport(result))
+	if !result.TargetMet {
+		logger.Warnf("target precision %.2f not reached", opts.TargetPrecision)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/hallucinaut/modelpoison/pkg/schema"
+)
+
+func validateDataset(args []string) error {
+	fs := newFlagSet("validate")
+	schemaPath := fs.String("schema", "", "dataset schema (YAML)")
+	format := fs.String("format", "text", "output format: text or json")
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usagef("dataset required")
+	}
+	if *schemaPath == "" {
+		return usagef("--schema is required")
+	}
+	if *format != "text" && *format != "json" {
+		return usagef("invalid format %q (want text or json)", *format)
+	}
+
+	s, err := schema.Load(*schemaPath)
+	if err != nil {
+		return err
+	}
+
+	report, err := s.ValidateFile(positional[0])
+	if err != nil {
+		return err
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Print(schema.GenerateReport(report))
+	}
+
+	if !report.Valid {
+		return fmt.Errorf("%s: %d validation errors", positional[0], len(report.Errors)+report.Truncated)
+	}
+	return nil
+}
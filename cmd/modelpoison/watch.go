@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// datasetExtensions lists file extensions picked up by watch mode.
+var datasetExtensions = map[string]bool{
+	".csv": true,
+}
+
+// isDatasetFile reports whether path looks like a dataset file.
+func isDatasetFile(path string) bool {
+	base := filepath.Base(path)
+	if strings.HasPrefix(base, ".") {
+		return false
+	}
+	return datasetExtensions[strings.ToLower(filepath.Ext(base))]
+}
+
+func watchDirectory(args []string) error {
+	fs := newFlagSet("watch")
+	columns := addColumnFlags(fs)
+	settle := fs.Duration("settle", 2*time.Second, "wait for writes to settle before scanning")
+	existing := fs.Bool("existing", false, "also scan datasets already in the directory")
+	configPath := fs.String("config", "", "detector configuration file")
+	policyFile := addPolicyFlag(fs)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 1 {
+		return usagef("directory required")
+	}
+	dir := positional[0]
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if err := applyPolicyFile(cfg, *policyFile); err != nil {
+		return err
+	}
+	opts := scanOptions{noProgress: true, config: cfg, columns: columns.columns()}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	return watchDatasets(ctx, dir, *settle, *existing, func(path string) {
+		watchScan(path, opts)
+	})
+}
+
+// signalContext returns a context canceled on SIGINT or SIGTERM.
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			logger.Infof("Received %s, stopping", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel()
+	}
+}
+
+// watchDatasets calls found for each dataset created or modified in dir,
+// and for the datasets already in dir when existing is set, until ctx is
+// done.
+func watchDatasets(ctx context.Context, dir string, settle time.Duration, existing bool, found func(path string)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(dir); err != nil {
+		return fmt.Errorf("watch %s: %w", dir, err)
+	}
+	logger.Infof("Watching %s for new datasets", dir)
+
+	if existing {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if entry.Type().IsRegular() && isDatasetFile(path) {
+				found(path)
+			}
+		}
+	}
+
+	// Files usually arrive as a burst of writes; report each one only after
+	// it has been quiet for the settle period. Only this loop touches
+	// pending: each event replaces the file's timer and bumps its
+	// generation, and a timer that fired before a later event is ignored
+	// rather than reporting the file early or twice.
+	type settling struct {
+		timer *time.Timer
+		gen   int
+	}
+	type settled struct {
+		name string
+		gen  int
+	}
+	pending := make(map[string]*settling)
+	ready := make(chan settled)
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			if !isDatasetFile(event.Name) {
+				continue
+			}
+			logger.Debugf("%s: %s", event.Op, event.Name)
+
+			p, ok := pending[event.Name]
+			if ok {
+				p.timer.Stop()
+			} else {
+				p = &settling{}
+				pending[event.Name] = p
+			}
+			p.gen++
+			file := settled{name: event.Name, gen: p.gen}
+			p.timer = time.AfterFunc(settle, func() {
+				select {
+				case ready <- file:
+				case <-ctx.Done():
+				}
+			})
+
+		case file := <-ready:
+			if p, ok := pending[file.name]; !ok || p.gen != file.gen {
+				continue
+			}
+			delete(pending, file.name)
+			found(file.name)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Warnf("watch: %v", err)
+
+		case <-ctx.Done():
+			for _, p := range pending {
+				p.timer.Stop()
+			}
+			return nil
+		}
+	}
+}
+
+// watchScan scans a dataset and emits an alert line on findings. Scan
+// errors are logged rather than returned so watching continues.
+func watchScan(path string, opts scanOptions) {
+	result, err := scanDataset(path, opts)
+	if err != nil {
+		logger.Warnf("scan %s: %v", path, err)
+		return
+	}
+	publishScan(opts.config, path, "", result)
+	if err := enforcePolicy(opts.config, path, opts, result); err != nil {
+		logger.Warnf("%v", err)
+	}
+
+	if result.IsPoisoned {
+		fmt.Printf("ALERT %s: %d of %d samples flagged, risk %.0f%%\n",
+			path, result.PoisonedCount, result.SampleCount, result.RiskScore*100)
+		return
+	}
+
+	logger.Infof("%s: clean (%d samples, risk %.0f%%)", path, result.SampleCount, result.RiskScore*100)
+}
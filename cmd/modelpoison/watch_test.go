@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// expectFound fails the test unless found reports path within timeout.
+func expectFound(t *testing.T, found <-chan string, path string, timeout time.Duration) {
+	t.Helper()
+	select {
+	case got := <-found:
+		if got != path {
+			t.Fatalf("found %s, want %s", got, path)
+		}
+	case <-time.After(timeout):
+		t.Fatalf("%s was not found within %v", path, timeout)
+	}
+}
+
+func TestWatchDatasets(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"old.csv", ".hidden.csv", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("a,label\n1,0\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const settle = 100 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	found := make(chan string, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- watchDatasets(ctx, dir, settle, true, func(path string) { found <- path })
+	}()
+
+	// The datasets already there are reported once the directory is
+	// watched.
+	expectFound(t, found, filepath.Join(dir, "old.csv"), 5*time.Second)
+
+	// A burst of writes is reported once, after it settles; files that are
+	// not datasets are not reported.
+	burst := filepath.Join(dir, "burst.csv")
+	f, err := os.Create(burst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for i := 0; i < 8; i++ {
+		if _, err := f.WriteString("1,0\n"); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(settle / 4)
+	}
+	f.Close()
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	expectFound(t, found, burst, 5*time.Second)
+	if elapsed := time.Since(start); elapsed < 2*settle {
+		t.Errorf("burst reported after %v, before its writes settled", elapsed)
+	}
+	select {
+	case path := <-found:
+		t.Errorf("found %s again", path)
+	case <-time.After(3 * settle):
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("watch stopped with %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not stop when its context was canceled")
+	}
+}
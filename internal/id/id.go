@@ -0,0 +1,17 @@
+// Package id generates the random identifiers of scans, jobs and runs.
+package id
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// New returns a random 16-character hex ID. It panics if the system's
+// secure random source fails, which leaves no safe way to continue.
+func New() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,20 @@
+package id
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := New()
+		if b, err := hex.DecodeString(id); err != nil || len(b) != 8 {
+			t.Fatalf("ID %q is not 16 hex characters", id)
+		}
+		if seen[id] {
+			t.Fatalf("ID %q repeated", id)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterBurst(t *testing.T) {
+	l := New(2, 3)
+	now := time.Unix(1000, 0)
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a", now); !ok {
+			t.Fatalf("request %d of the burst was limited", i+1)
+		}
+	}
+	ok, wait := l.Allow("a", now)
+	if ok {
+		t.Fatal("request beyond the burst was allowed")
+	}
+	if wait != 500*time.Millisecond {
+		t.Errorf("wait = %v, want 500ms at 2 requests per second", wait)
+	}
+	if got := RetryAfter(wait); got != 1 {
+		t.Errorf("RetryAfter(%v) = %d, want 1", wait, got)
+	}
+	// Clients have their own buckets.
+	if ok, _ := l.Allow("b", now); !ok {
+		t.Error("another client was limited")
+	}
+}
+
+func TestLimiterRefill(t *testing.T) {
+	l := New(2, 2)
+	now := time.Unix(1000, 0)
+	l.Allow("a", now)
+	l.Allow("a", now)
+	if ok, _ := l.Allow("a", now.Add(250*time.Millisecond)); ok {
+		t.Error("allowed before a token refilled")
+	}
+	// The limited request took no token: half a token plus a quarter
+	// of a second's refill makes one.
+	if ok, _ := l.Allow("a", now.Add(500*time.Millisecond)); !ok {
+		t.Error("limited after a token refilled")
+	}
+	// Idle clients refill up to the burst, not beyond.
+	now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("a", now); !ok {
+			t.Fatalf("request %d after idling was limited", i+1)
+		}
+	}
+	if ok, _ := l.Allow("a", now); ok {
+		t.Error("an idle client refilled beyond the burst")
+	}
+}
+
+func TestLimiterDefaultBurst(t *testing.T) {
+	l := New(2.5, 0)
+	now := time.Unix(1000, 0)
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow("a", now); !ok {
+			t.Fatalf("request %d of the default burst was limited", i+1)
+		}
+	}
+	if ok, _ := l.Allow("a", now); ok {
+		t.Error("default burst is above the rate rounded up")
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l := New(1, 1)
+	now := time.Unix(1000, 0)
+	l.Allow("a", now)
+	l.Allow("b", now.Add(90*time.Second))
+	if n := l.clients(); n != 1 {
+		t.Errorf("%d clients after a sweep, want only the active one", n)
+	}
+}
@@ -0,0 +1,295 @@
+// Package alert sends Slack and email alerts for scans that match
+// configured policy rules.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/webhook"
+)
+
+// Config lists the alert rules and the mail server used by email alerts.
+type Config struct {
+	SMTP  SMTP   `yaml:"smtp,omitempty"`
+	Rules []Rule `yaml:"rules"`
+}
+
+// SMTP configures the mail server. The connection is upgraded with
+// STARTTLS when the server offers it.
+type SMTP struct {
+	// Addr is the host:port of the server.
+	Addr string `yaml:"addr"`
+	From string `yaml:"from"`
+	// Username and Password authenticate with PLAIN auth when set. A value
+	// of the form $NAME or ${NAME} is read from the environment.
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+}
+
+// Rule sends alerts for scans matching any of its conditions.
+type Rule struct {
+	Name string `yaml:"name"`
+	// RiskAbove matches scans with a risk score above this.
+	RiskAbove float64 `yaml:"risk_above,omitempty"`
+	// Types matches scans flagging any sample with one of these poison
+	// types.
+	Types []detect.PoisonType `yaml:"types,omitempty"`
+	// Poisoned matches scans whose verdict is poisoned.
+	Poisoned bool `yaml:"poisoned,omitempty"`
+
+	// Slack is a Slack incoming webhook URL, or $NAME to read it from the
+	// environment.
+	Slack string `yaml:"slack,omitempty"`
+	// Email lists the recipients of email alerts.
+	Email []string `yaml:"email,omitempty"`
+}
+
+// Check reports errors in the configuration.
+func (c Config) Check() error {
+	names := make(map[string]bool)
+	emails := false
+	for _, rule := range c.Rules {
+		if rule.Name == "" {
+			return errors.New("rule without a name")
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate rule %q", rule.Name)
+		}
+		names[rule.Name] = true
+		if err := rule.check(); err != nil {
+			return fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+		emails = emails || len(rule.Email) > 0
+	}
+	if emails {
+		if _, _, err := net.SplitHostPort(c.SMTP.Addr); err != nil {
+			return fmt.Errorf("smtp: invalid addr %q (want host:port)", c.SMTP.Addr)
+		}
+		if _, err := mail.ParseAddress(c.SMTP.From); err != nil {
+			return fmt.Errorf("smtp: invalid from address %q", c.SMTP.From)
+		}
+	}
+	return nil
+}
+
+func (r Rule) check() error {
+	if r.RiskAbove == 0 && len(r.Types) == 0 && !r.Poisoned {
+		return errors.New("no conditions (set risk_above, types or poisoned)")
+	}
+	if r.RiskAbove < 0 || r.RiskAbove > 1 {
+		return errors.New("risk_above must be between 0 and 1")
+	}
+	for _, t := range r.Types {
+		if !knownType(t) {
+			return fmt.Errorf("unknown poison type %q", t)
+		}
+	}
+	if r.Slack == "" && len(r.Email) == 0 {
+		return errors.New("no destinations (set slack or email)")
+	}
+	if r.Slack != "" && !strings.HasPrefix(r.Slack, "$") {
+		if u, err := url.Parse(r.Slack); err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("invalid slack webhook URL %q", r.Slack)
+		}
+	}
+	for _, to := range r.Email {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid email address %q", to)
+		}
+	}
+	return nil
+}
+
+func knownType(t detect.PoisonType) bool {
+	for _, known := range append(detect.Checks(), detect.TypeDataPoison) {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether a scan result matches the rule and why.
+func (r Rule) Match(result *detect.DetectionResult) (string, bool) {
+	var reasons []string
+	if r.RiskAbove > 0 && result.RiskScore > r.RiskAbove {
+		reasons = append(reasons, fmt.Sprintf("risk %.2f above %.2f", result.RiskScore, r.RiskAbove))
+	}
+	if len(r.Types) > 0 {
+		types := webhook.Summarize(result).Types
+		for _, t := range r.Types {
+			if n := types[string(t)]; n > 0 {
+				reasons = append(reasons, fmt.Sprintf("%d %s findings", n, t))
+			}
+		}
+	}
+	if r.Poisoned && result.IsPoisoned {
+		reasons = append(reasons, "poisoning detected")
+	}
+	return strings.Join(reasons, ", "), len(reasons) > 0
+}
+
+// Alerter evaluates rules against scans and sends their alerts.
+type Alerter struct {
+	cfg    Config
+	client *http.Client
+	// sendMail sends an email; it is smtp.SendMail outside tests.
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// New creates an alerter. A nil client uses a client with a 10s timeout.
+func New(cfg Config, client *http.Client) *Alerter {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Alerter{cfg: cfg, client: client, sendMail: smtp.SendMail}
+}
+
+// Alert sends the alerts of every rule matching scan. It returns the
+// delivery errors, joined.
+func (a *Alerter) Alert(ctx context.Context, scan webhook.Scan) error {
+	if a == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, rule := range a.cfg.Rules {
+		reason, ok := rule.Match(scan.Result)
+		if !ok {
+			continue
+		}
+		subject, body := message(rule, reason, scan)
+		if rule.Slack != "" {
+			if err := a.slack(ctx, expand(rule.Slack), subject, body); err != nil {
+				errs = append(errs, fmt.Errorf("alert %s: slack: %w", rule.Name, err))
+			}
+		}
+		if len(rule.Email) > 0 {
+			if err := a.email(rule.Email, subject, body); err != nil {
+				errs = append(errs, fmt.Errorf("alert %s: email: %w", rule.Name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// message returns the subject and body of an alert.
+func message(rule Rule, reason string, scan webhook.Scan) (string, string) {
+	dataset := scan.Dataset
+	if dataset == "" {
+		dataset = "dataset"
+	}
+	if scan.Project != "" {
+		dataset = scan.Project + "/" + dataset
+	}
+	subject := fmt.Sprintf("[modelpoison] %s: %s risk %.0f%%", rule.Name, dataset, scan.Result.RiskScore*100)
+
+	summary := webhook.Summarize(scan.Result)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Rule %s matched a scan of %s: %s.\n", rule.Name, dataset, reason)
+	fmt.Fprintf(&b, "%d of %d samples flagged, risk score %.2f.\n", summary.PoisonedCount, summary.SampleCount, summary.RiskScore)
+	if len(summary.Types) > 0 {
+		types := make([]string, 0, len(summary.Types))
+		for t, n := range summary.Types {
+			types = append(types, fmt.Sprintf("%s %d", t, n))
+		}
+		sort.Strings(types)
+		fmt.Fprintf(&b, "Findings: %s.\n", strings.Join(types, ", "))
+	}
+	if summary.Partial {
+		b.WriteString("The scan covered only part of the dataset.\n")
+	}
+	if link := reportLink(scan); link != "" {
+		fmt.Fprintf(&b, "Report: %s\n", link)
+	}
+	return subject, b.String()
+}
+
+// reportLink returns the best link to the result of scan.
+func reportLink(scan webhook.Scan) string {
+	if scan.ReportURL != "" {
+		return scan.ReportURL
+	}
+	return scan.ResultURL
+}
+
+// slack posts an alert to a Slack incoming webhook.
+func (a *Alerter) slack(ctx context.Context, hookURL, subject, body string) error {
+	payload, err := json.Marshal(map[string]string{"text": "*" + subject + "*\n" + body})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		// The webhook URL is a secret; keep it out of the error.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// email sends an alert to recipients.
+func (a *Alerter) email(to []string, subject, body string) error {
+	cfg := a.cfg.SMTP
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		host, _, _ := net.SplitHostPort(cfg.Addr)
+		auth = smtp.PlainAuth("", expand(cfg.Username), expand(cfg.Password), host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	from := cfg.From
+	if addr, err := mail.ParseAddress(cfg.From); err == nil {
+		from = addr.Address
+	}
+	recipients := make([]string, len(to))
+	for i, r := range to {
+		recipients[i] = r
+		if addr, err := mail.ParseAddress(r); err == nil {
+			recipients[i] = addr.Address
+		}
+	}
+	return a.sendMail(cfg.Addr, auth, from, recipients, msg.Bytes())
+}
+
+// expand returns value, expanding environment references.
+func expand(value string) string {
+	if strings.HasPrefix(value, "$") {
+		return os.ExpandEnv(value)
+	}
+	return value
+}
@@ -0,0 +1,81 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/webhook"
+)
+
+func TestAlertMatchingRules(t *testing.T) {
+	var texts []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct{ Text string }
+		json.NewDecoder(r.Body).Decode(&payload)
+		texts = append(texts, payload.Text)
+	}))
+	defer srv.Close()
+	t.Setenv("SLACK_HOOK", srv.URL)
+
+	cfg := Config{
+		SMTP: SMTP{Addr: "mail.example.com:587", From: "modelpoison@example.com"},
+		Rules: []Rule{
+			{Name: "risky", RiskAbove: 0.5, Slack: "$SLACK_HOOK"},
+			{Name: "backdoor", Types: []detect.PoisonType{detect.TypeBackdoor}, Email: []string{"Sec <sec@example.com>"}},
+		},
+	}
+	if err := cfg.Check(); err != nil {
+		t.Fatal(err)
+	}
+	a := New(cfg, nil)
+	var mailed []string
+	a.sendMail = func(addr string, _ smtp.Auth, from string, to []string, msg []byte) error {
+		mailed = append(mailed, strings.Join(to, ",")+"\n"+string(msg))
+		return nil
+	}
+
+	scan := webhook.Scan{
+		Dataset:   "train.csv",
+		ReportURL: "https://evidence.example.com/html/abc.html",
+		Result: &detect.DetectionResult{SampleCount: 100, PoisonedCount: 1, RiskScore: 0.2, Samples: []detect.PoisonedSample{
+			{ID: "s1", IsPoisoned: true, Type: detect.TypeBackdoor},
+		}},
+	}
+	if err := a.Alert(context.Background(), scan); err != nil {
+		t.Fatal(err)
+	}
+	if len(texts) != 0 {
+		t.Errorf("risk rule fired at risk 0.2: %q", texts)
+	}
+	if len(mailed) != 1 || !strings.HasPrefix(mailed[0], "sec@example.com\n") ||
+		!strings.Contains(mailed[0], "1 backdoor findings") || !strings.Contains(mailed[0], scan.ReportURL) {
+		t.Errorf("mailed = %q, want one backdoor alert linking the report", mailed)
+	}
+
+	scan.Result.RiskScore = 0.8
+	if err := a.Alert(context.Background(), scan); err != nil {
+		t.Fatal(err)
+	}
+	if len(texts) != 1 || !strings.Contains(texts[0], "risk 0.80 above 0.50") {
+		t.Errorf("slack texts = %q, want one risk alert", texts)
+	}
+}
+
+func TestCheckRejectsBadRules(t *testing.T) {
+	for _, rule := range []Rule{
+		{Name: "none", Slack: "https://hooks.slack.com/x"},
+		{Name: "nowhere", RiskAbove: 0.5},
+		{Name: "type", Types: []detect.PoisonType{"nope"}, Slack: "https://hooks.slack.com/x"},
+		{Name: "nosmtp", Poisoned: true, Email: []string{"a@example.com"}},
+	} {
+		if err := (Config{Rules: []Rule{rule}}).Check(); err == nil {
+			t.Errorf("Check accepted rule %q", rule.Name)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+// Package allowlist stores human review decisions for flagged samples,
+// and the lists of known benign and known bad samples that override the
+// detector's findings.
+package allowlist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Decision represents a review decision for a flagged sample.
+type Decision string
+
+const (
+	// DecisionAccepted confirms the finding as genuine poisoning.
+	DecisionAccepted Decision = "accepted"
+	// DecisionFalsePositive marks the sample as reviewed and benign.
+	DecisionFalsePositive Decision = "false_positive"
+)
+
+// ParseDecision parses a decision name. "accept" confirms a finding and
+// "reject" rejects it as a false positive.
+func ParseDecision(name string) (Decision, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "accepted", "accept", "confirmed":
+		return DecisionAccepted, nil
+	case "false_positive", "false-positive", "fp", "reject", "rejected":
+		return DecisionFalsePositive, nil
+	}
+	return "", fmt.Errorf("invalid decision %q (want accept or reject)", name)
+}
+
+// Entry records the review decision for one sample.
+type Entry struct {
+	ID         string    `json:"id"`
+	Decision   Decision  `json:"decision"`
+	Type       string    `json:"type,omitempty"`
+	Note       string    `json:"note,omitempty"`
+	Reviewer   string    `json:"reviewer,omitempty"`
+	ReviewedAt time.Time `json:"reviewed_at"`
+	// Hash is the content hash of the sample (detect.SampleHash). A false
+	// positive with a hash allows the sample under any ID.
+	Hash string `json:"hash,omitempty"`
+}
+
+// Allowlist holds review decisions keyed by sample ID.
+type Allowlist struct {
+	entries map[string]Entry
+	// hashes counts the false positives of each content hash.
+	hashes map[string]int
+}
+
+// file is the on-disk allowlist format.
+type file struct {
+	Entries []Entry `json:"entries"`
+}
+
+// New creates an empty allowlist.
+func New() *Allowlist {
+	return &Allowlist{entries: make(map[string]Entry), hashes: make(map[string]int)}
+}
+
+// Load reads an allowlist file. A missing file yields an empty allowlist.
+// Besides the JSON files Save writes, Load reads text files listing
+// samples reviewed as benign, one per line: a sample ID, or "hash:" and a
+// content hash, optionally followed by a "#" comment kept as the note.
+// Blank lines and lines holding only a comment are skipped.
+func Load(path string) (*Allowlist, error) {
+	list := New()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return list, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !isJSON(data) {
+		items, err := readItems(data, "id", "hash")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, item := range items {
+			entry := Entry{Decision: DecisionFalsePositive, Note: item.comment}
+			if item.kind == "hash" {
+				entry.ID, entry.Hash = "hash:"+item.value, item.value
+			} else {
+				entry.ID = item.value
+			}
+			list.add(entry)
+		}
+		return list, nil
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, entry := range f.Entries {
+		list.add(entry)
+	}
+
+	return list, nil
+}
+
+// Save writes the allowlist to path, sorted by sample ID.
+func (a *Allowlist) Save(path string) error {
+	data, err := json.MarshalIndent(file{Entries: a.Entries()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Set records a decision for a sample, replacing any previous one.
+func (a *Allowlist) Set(entry Entry) {
+	if entry.ReviewedAt.IsZero() {
+		entry.ReviewedAt = time.Now().UTC()
+	}
+	a.add(entry)
+}
+
+// add records entry, replacing the decision for its sample.
+func (a *Allowlist) add(entry Entry) {
+	a.Remove(entry.ID)
+	a.entries[entry.ID] = entry
+	if entry.Hash != "" && entry.Decision == DecisionFalsePositive {
+		a.hashes[entry.Hash]++
+	}
+}
+
+// Remove deletes the decision for a sample.
+func (a *Allowlist) Remove(id string) {
+	if old, ok := a.entries[id]; ok && old.Hash != "" && old.Decision == DecisionFalsePositive {
+		if a.hashes[old.Hash]--; a.hashes[old.Hash] == 0 {
+			delete(a.hashes, old.Hash)
+		}
+	}
+	delete(a.entries, id)
+}
+
+// Lookup returns the decision recorded for a sample.
+func (a *Allowlist) Lookup(id string) (Entry, bool) {
+	entry, ok := a.entries[id]
+	return entry, ok
+}
+
+// IsAllowed reports whether a sample was reviewed as a false positive.
+func (a *Allowlist) IsAllowed(id string) bool {
+	entry, ok := a.entries[id]
+	return ok && entry.Decision == DecisionFalsePositive
+}
+
+// Allowed reports whether sample was reviewed as a false positive, by its
+// ID or its content hash.
+func (a *Allowlist) Allowed(sample detect.Sample) bool {
+	if a.IsAllowed(sample.ID) {
+		return true
+	}
+	return len(a.hashes) > 0 && a.hashes[detect.SampleHash(sample)] > 0
+}
+
+// Len returns the number of recorded decisions.
+func (a *Allowlist) Len() int {
+	return len(a.entries)
+}
+
+// Entries returns all decisions sorted by sample ID.
+func (a *Allowlist) Entries() []Entry {
+	entries := make([]Entry, 0, len(a.entries))
+	for _, entry := range a.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries
+}
+
+// isJSON reports whether data holds a JSON object rather than a text list.
+func isJSON(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("{"))
+}
+
+// item is a line of a text list: a value of a kind, such as a sample ID,
+// and the comment following it.
+type item struct {
+	kind, value, comment string
+}
+
+// readItems reads the lines of a text list. A line is "kind:value" for
+// one of kinds, or a value of the first kind. Values end at a " #"
+// comment.
+func readItems(data []byte, kinds ...string) ([]item, error) {
+	var items []item
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		it := item{kind: kinds[0], value: text}
+		if i := strings.Index(text, " #"); i >= 0 {
+			it.value, it.comment = strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+2:])
+		}
+		for _, kind := range kinds {
+			if v, ok := strings.CutPrefix(it.value, kind+":"); ok {
+				it.kind, it.value = kind, strings.TrimSpace(v)
+				break
+			}
+		}
+		if it.value == "" {
+			return nil, fmt.Errorf("line %d: empty %s", line, it.kind)
+		}
+		items = append(items, it)
+	}
+	return items, scanner.Err()
+}
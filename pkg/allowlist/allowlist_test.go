@@ -0,0 +1,137 @@
+package allowlist
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func TestParseDecision(t *testing.T) {
+	for name, want := range map[string]Decision{
+		"accept":         DecisionAccepted,
+		" Accepted ":     DecisionAccepted,
+		"confirmed":      DecisionAccepted,
+		"reject":         DecisionFalsePositive,
+		"FP":             DecisionFalsePositive,
+		"false-positive": DecisionFalsePositive,
+		"false_positive": DecisionFalsePositive,
+	} {
+		if got, err := ParseDecision(name); err != nil || got != want {
+			t.Errorf("ParseDecision(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseDecision("maybe"); err == nil {
+		t.Error("parsed decision maybe")
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	sample := detect.Sample{ID: "s1", Features: []float64{1, 2}, Label: 1}
+	copied := detect.Sample{ID: "s9", Features: []float64{1, 2}, Label: 1}
+
+	list := New()
+	list.Set(Entry{ID: "s1", Decision: DecisionAccepted})
+	if entry, ok := list.Lookup("s1"); !ok || entry.ReviewedAt.IsZero() {
+		t.Errorf("lookup %+v, %v; want the entry stamped with its review time", entry, ok)
+	}
+	if list.IsAllowed("s1") || list.Allowed(sample) {
+		t.Error("an accepted finding is allowed")
+	}
+
+	// A false positive with a hash allows the sample under any ID, until
+	// its decision is replaced or removed.
+	list.Set(Entry{ID: "s1", Decision: DecisionFalsePositive, Hash: detect.SampleHash(sample)})
+	if !list.IsAllowed("s1") || !list.Allowed(sample) || !list.Allowed(copied) || list.IsAllowed("s9") {
+		t.Error("a false positive is not allowed by its ID and hash")
+	}
+	if list.Len() != 1 {
+		t.Errorf("%d entries after replacing a decision, want 1", list.Len())
+	}
+	list.Set(Entry{ID: "s1", Decision: DecisionAccepted, Hash: detect.SampleHash(sample)})
+	if list.Allowed(copied) {
+		t.Error("an accepted finding's hash allows its copies")
+	}
+	list.Set(Entry{ID: "s1", Decision: DecisionFalsePositive, Hash: detect.SampleHash(sample)})
+	list.Remove("s1")
+	if list.Allowed(sample) || list.Allowed(copied) || list.Len() != 0 {
+		t.Error("a removed false positive is still allowed")
+	}
+
+	// Two IDs sharing a hash keep it allowed until both are removed.
+	list.Set(Entry{ID: "a", Decision: DecisionFalsePositive, Hash: "h"})
+	list.Set(Entry{ID: "b", Decision: DecisionFalsePositive, Hash: "h"})
+	list.Remove("a")
+	if list.hashes["h"] != 1 {
+		t.Errorf("hash counted %d times after removing one of two entries", list.hashes["h"])
+	}
+}
+
+func TestAllowlistSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "allowlist.json")
+	list, err := Load(path)
+	if err != nil || list.Len() != 0 {
+		t.Fatalf("loading a missing allowlist: %d entries, %v", list.Len(), err)
+	}
+
+	at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	list.Set(Entry{ID: "z", Decision: DecisionAccepted, Type: "backdoor", Reviewer: "ana", ReviewedAt: at})
+	list.Set(Entry{ID: "a", Decision: DecisionFalsePositive, Note: "seasonal spike", Hash: "sha256:00", ReviewedAt: at})
+	if err := list.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded.Entries(), list.Entries()) || loaded.Entries()[0].ID != "a" {
+		t.Errorf("loaded %+v, want %+v sorted by ID", loaded.Entries(), list.Entries())
+	}
+	if loaded.hashes["sha256:00"] != 1 {
+		t.Error("a loaded false positive's hash is not indexed")
+	}
+
+	if err := os.WriteFile(path, []byte(`{"entries": [`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("loaded a truncated allowlist")
+	}
+}
+
+func TestAllowlistText(t *testing.T) {
+	sample := detect.Sample{ID: "x", Features: []float64{3}}
+	path := filepath.Join(t.TempDir(), "benign.txt")
+	text := "# reviewed by the data team\n\ns1\ns2 # duplicate of s1\nhash:" + detect.SampleHash(sample) + "\n  id:s3  \n"
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	list, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Len() != 4 {
+		t.Fatalf("loaded %+v, want 4 entries", list.Entries())
+	}
+	for _, id := range []string{"s1", "s2", "s3"} {
+		if !list.IsAllowed(id) {
+			t.Errorf("%s is not allowed", id)
+		}
+	}
+	if entry, _ := list.Lookup("s2"); entry.Note != "duplicate of s1" || entry.Decision != DecisionFalsePositive {
+		t.Errorf("s2 entry %+v", entry)
+	}
+	if !list.Allowed(sample) {
+		t.Error("a listed hash does not allow its sample")
+	}
+
+	if err := os.WriteFile(path, []byte("s1\nhash: # no hash\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path); err == nil || err.Error() != path+": line 2: empty hash" {
+		t.Errorf("loading an empty hash: %v", err)
+	}
+}
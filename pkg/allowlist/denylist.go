@@ -0,0 +1,174 @@
+package allowlist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// DenyEntry lists samples known to be bad: the sample with an ID, the
+// samples with a content hash, or every sample from a source. Exactly one
+// of ID, Hash and Source is set.
+type DenyEntry struct {
+	ID     string `json:"id,omitempty"`
+	Hash   string `json:"hash,omitempty"`
+	Source string `json:"source,omitempty"`
+	// Reason is reported as the evidence of the samples flagged.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Denylist holds the samples and sources known to be bad, such as
+// contributors found poisoning data, whose samples are flagged whatever
+// their scores.
+type Denylist struct {
+	ids, hashes, sources map[string]DenyEntry
+}
+
+// denyFile is the on-disk denylist format.
+type denyFile struct {
+	Entries []DenyEntry `json:"entries"`
+}
+
+// NewDenylist creates an empty denylist.
+func NewDenylist() *Denylist {
+	return &Denylist{
+		ids:     make(map[string]DenyEntry),
+		hashes:  make(map[string]DenyEntry),
+		sources: make(map[string]DenyEntry),
+	}
+}
+
+// LoadDenylist reads a denylist file: a JSON object of entries, or a text
+// file listing one per line as "source:NAME", "hash:HASH" or a sample ID,
+// optionally followed by a "#" comment kept as the reason. Unlike Load, a
+// missing file is an error.
+func LoadDenylist(path string) (*Denylist, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []DenyEntry
+	if isJSON(data) {
+		var f denyFile
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		entries = f.Entries
+	} else {
+		items, err := readItems(data, "id", "hash", "source")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, item := range items {
+			entry := DenyEntry{Reason: item.comment}
+			switch item.kind {
+			case "hash":
+				entry.Hash = item.value
+			case "source":
+				entry.Source = item.value
+			default:
+				entry.ID = item.value
+			}
+			entries = append(entries, entry)
+		}
+	}
+
+	list := NewDenylist()
+	for i, entry := range entries {
+		if err := list.Add(entry); err != nil {
+			return nil, fmt.Errorf("%s: entry %d: %w", path, i+1, err)
+		}
+	}
+	return list, nil
+}
+
+// Add adds an entry to the denylist.
+func (d *Denylist) Add(entry DenyEntry) error {
+	set := 0
+	for _, v := range []string{entry.ID, entry.Hash, entry.Source} {
+		if v != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("set exactly one of id, hash and source")
+	}
+	switch {
+	case entry.ID != "":
+		d.ids[entry.ID] = entry
+	case entry.Hash != "":
+		d.hashes[entry.Hash] = entry
+	default:
+		d.sources[entry.Source] = entry
+	}
+	return nil
+}
+
+// Len returns the number of entries.
+func (d *Denylist) Len() int {
+	return len(d.ids) + len(d.hashes) + len(d.sources)
+}
+
+// Sources returns the denied sources, sorted.
+func (d *Denylist) Sources() []string {
+	sources := make([]string, 0, len(d.sources))
+	for source := range d.sources {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// Denied reports whether sample is on the denylist, by its ID, content
+// hash or source (its detect.MetaSource metadata), and describes why.
+func (d *Denylist) Denied(sample detect.Sample) (string, bool) {
+	if entry, ok := d.ids[sample.ID]; ok {
+		return describe("sample "+sample.ID, entry), true
+	}
+	if source, _ := sample.Meta(detect.MetaSource); source != nil {
+		if entry, ok := d.sources[fmt.Sprint(source)]; ok {
+			return describe("source "+entry.Source, entry), true
+		}
+	}
+	if len(d.hashes) > 0 {
+		hash := detect.SampleHash(sample)
+		if entry, ok := d.hashes[hash]; ok {
+			return describe("content hash "+hash, entry), true
+		}
+	}
+	return "", false
+}
+
+// describe returns the evidence of a sample denied by entry, matched by
+// what.
+func describe(what string, entry DenyEntry) string {
+	if entry.Reason == "" {
+		return what + " is on the denylist"
+	}
+	return what + " is on the denylist: " + entry.Reason
+}
+
+// Lists combines an allowlist and a denylist into the detector's Lists.
+// Either may be nil.
+type Lists struct {
+	Allow *Allowlist
+	Deny  *Denylist
+}
+
+// Allowed reports whether the allowlist allows sample.
+func (l Lists) Allowed(sample detect.Sample) bool {
+	return l.Allow != nil && l.Allow.Allowed(sample)
+}
+
+// Denied reports whether the denylist denies sample.
+func (l Lists) Denied(sample detect.Sample) (string, bool) {
+	if l.Deny == nil {
+		return "", false
+	}
+	return l.Deny.Denied(sample)
+}
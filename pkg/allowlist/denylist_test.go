@@ -0,0 +1,110 @@
+package allowlist
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// writeList writes a list file holding text and returns its path.
+func writeList(t *testing.T, name, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDenylist(t *testing.T) {
+	copied := detect.Sample{ID: "c1", Features: []float64{7, 7}}
+	path := writeList(t, "bad.txt", "# known bad\ns1 # planted trigger\nsource:mallory\nhash:"+detect.SampleHash(copied)+"\nsource: eve # scraped\n")
+	list, err := LoadDenylist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Len() != 4 || !reflect.DeepEqual(list.Sources(), []string{"eve", "mallory"}) {
+		t.Errorf("%d entries from sources %v", list.Len(), list.Sources())
+	}
+
+	for _, test := range []struct {
+		name     string
+		sample   detect.Sample
+		evidence string
+	}{
+		{"id", detect.Sample{ID: "s1", Features: []float64{1}}, "sample s1 is on the denylist: planted trigger"},
+		{"source", detect.Sample{ID: "s2", Metadata: map[string]interface{}{detect.MetaSource: "mallory"}}, "source mallory is on the denylist"},
+		{"commented source", detect.Sample{ID: "s3", Metadata: map[string]interface{}{detect.MetaSource: "eve"}}, "source eve is on the denylist: scraped"},
+		{"hash", detect.Sample{ID: "c2", Features: []float64{7, 7}}, "content hash " + detect.SampleHash(copied) + " is on the denylist"},
+		{"clean", detect.Sample{ID: "s4", Features: []float64{1}, Metadata: map[string]interface{}{detect.MetaSource: "alice"}}, ""},
+	} {
+		evidence, denied := list.Denied(test.sample)
+		if evidence != test.evidence || denied != (test.evidence != "") {
+			t.Errorf("%s: denied %v with %q, want %q", test.name, denied, evidence, test.evidence)
+		}
+	}
+
+	// Two matches report the sample's ID before its source.
+	evidence, _ := list.Denied(detect.Sample{ID: "s1", Metadata: map[string]interface{}{detect.MetaSource: "mallory"}})
+	if !strings.HasPrefix(evidence, "sample s1") {
+		t.Errorf("denied by %q, want the ID", evidence)
+	}
+}
+
+func TestLoadDenylist(t *testing.T) {
+	path := writeList(t, "bad.json", `{"entries": [{"id": "s1", "reason": "reported"}, {"source": "mallory"}]}`)
+	list, err := LoadDenylist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evidence, ok := list.Denied(detect.Sample{ID: "s1"}); !ok || evidence != "sample s1 is on the denylist: reported" {
+		t.Errorf("denied %v with %q", ok, evidence)
+	}
+	if list.Len() != 2 {
+		t.Errorf("%d entries, want 2", list.Len())
+	}
+
+	for _, test := range []struct {
+		name, file, text, err string
+	}{
+		{"two keys", "bad.json", `{"entries": [{"id": "s1"}, {"id": "s2", "source": "eve"}]}`, "entry 2: set exactly one of id, hash and source"},
+		{"no key", "bad.json", `{"entries": [{"reason": "bad"}]}`, "entry 1: set exactly one of id, hash and source"},
+		{"empty source", "bad.txt", "s1\n\nsource: # unknown\n", "line 3: empty source"},
+		{"malformed JSON", "bad.json", `{"entries": {}}`, "cannot unmarshal"},
+	} {
+		path := writeList(t, test.file, test.text)
+		if _, err := LoadDenylist(path); err == nil || !strings.HasPrefix(err.Error(), path+": ") || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: error %v, want %q", test.name, err, test.err)
+		}
+	}
+
+	// Unlike an allowlist, a missing denylist is an error.
+	if _, err := LoadDenylist(filepath.Join(t.TempDir(), "missing.txt")); !os.IsNotExist(err) {
+		t.Errorf("loading a missing denylist: %v", err)
+	}
+}
+
+func TestLists(t *testing.T) {
+	sample := detect.Sample{ID: "s1"}
+	var none Lists
+	if none.Allowed(sample) {
+		t.Error("an empty Lists allows samples")
+	}
+	if _, denied := none.Denied(sample); denied {
+		t.Error("an empty Lists denies samples")
+	}
+
+	allow, deny := New(), NewDenylist()
+	allow.Set(Entry{ID: "s1", Decision: DecisionFalsePositive})
+	if err := deny.Add(DenyEntry{ID: "s1"}); err != nil {
+		t.Fatal(err)
+	}
+	lists := Lists{Allow: allow, Deny: deny}
+	if _, denied := lists.Denied(sample); !lists.Allowed(sample) || !denied {
+		t.Error("Lists does not consult its allowlist and denylist")
+	}
+}
@@ -0,0 +1,308 @@
+// Package archive uploads scan reports and raw results to object storage
+// (S3, GCS or Azure Blob Storage) under content-addressed names, so the
+// evidence of every scan is kept.
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/report"
+)
+
+// Formats.
+const (
+	FormatJSON = "json"
+	FormatHTML = "html"
+	FormatText = "text"
+)
+
+// Config configures archiving.
+type Config struct {
+	// URL names the bucket and key prefix: s3://bucket/prefix,
+	// gs://bucket/prefix, azblob://account/container/prefix or, for
+	// testing, file:///dir.
+	URL string `yaml:"url"`
+	// Region is the S3 region (default $AWS_REGION, else us-east-1).
+	Region string `yaml:"region,omitempty"`
+	// Formats lists the artifacts uploaded per scan: json (the raw
+	// result), html and text reports (default json and html).
+	Formats []string `yaml:"formats,omitempty"`
+	// Retention is set as the "retention" tag of every object, for bucket
+	// lifecycle rules to act on, such as "90d" or "7y".
+	Retention string `yaml:"retention,omitempty"`
+	// Tags are set on every object besides the retention and scan tags.
+	Tags map[string]string `yaml:"tags,omitempty"`
+	// PublicURL is the base URL under which archived objects can be
+	// viewed, such as a CDN or an authenticating proxy in front of the
+	// bucket. When set, object URLs are PublicURL/key; otherwise they are
+	// storage URLs such as s3://bucket/key.
+	PublicURL string `yaml:"public_url,omitempty"`
+}
+
+// Check reports errors in the configuration.
+func (c Config) Check() error {
+	if _, err := parseURL(c.URL); err != nil {
+		return err
+	}
+	if c.PublicURL != "" {
+		if u, err := url.Parse(c.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid public_url %q", c.PublicURL)
+		}
+	}
+	for _, format := range c.Formats {
+		if format != FormatJSON && format != FormatHTML && format != FormatText {
+			return fmt.Errorf("unknown format %q (want json, html or text)", format)
+		}
+	}
+	return nil
+}
+
+// formats returns the configured formats.
+func (c Config) formats() []string {
+	if len(c.Formats) == 0 {
+		return []string{FormatJSON, FormatHTML}
+	}
+	return c.Formats
+}
+
+// Evidence describes a completed scan to archive.
+type Evidence struct {
+	// ID identifies the scan, if it has an ID.
+	ID string
+	// Project names the server project of the scan, if any.
+	Project string
+	// Dataset names the scanned dataset.
+	Dataset string
+	Result  *detect.DetectionResult
+}
+
+// Object is an uploaded artifact.
+type Object struct {
+	Format string `json:"format"`
+	Key    string `json:"key"`
+	// URL locates the object, such as s3://bucket/key.
+	URL string `json:"url"`
+	// SHA256 is the hex digest of the content, which names the object.
+	SHA256 string `json:"sha256"`
+}
+
+// bucket stores objects.
+type bucket interface {
+	put(ctx context.Context, key, contentType string, body []byte, tags map[string]string) error
+	// url returns the location of a key.
+	url(key string) string
+}
+
+// Archiver uploads scan evidence to a bucket.
+type Archiver struct {
+	cfg    Config
+	bucket bucket
+	prefix string
+}
+
+// New creates an archiver. A nil client uses a client with a 1m timeout.
+// Credentials come from the environment: AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN for S3 (with
+// AWS_ENDPOINT_URL for S3-compatible stores), GOOGLE_OAUTH_ACCESS_TOKEN or
+// the metadata server for GCS, and AZURE_STORAGE_SAS_TOKEN for Azure.
+func New(cfg Config, client *http.Client) (*Archiver, error) {
+	if err := cfg.Check(); err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = &http.Client{Timeout: time.Minute}
+	}
+
+	u, _ := parseURL(cfg.URL)
+	a := &Archiver{cfg: cfg}
+	switch u.Scheme {
+	case "s3":
+		region := cfg.Region
+		if region == "" {
+			region = os.Getenv("AWS_REGION")
+		}
+		if region == "" {
+			region = "us-east-1"
+		}
+		b, err := newS3(client, u.Host, region)
+		if err != nil {
+			return nil, err
+		}
+		a.bucket = b
+	case "gs":
+		a.bucket = newGCS(client, u.Host)
+	case "azblob":
+		container, prefix, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+		b, err := newAzure(client, u.Host, container)
+		if err != nil {
+			return nil, err
+		}
+		a.bucket = b
+		u.Path = "/" + prefix
+	case "file":
+		a.bucket = dirBucket(filepath.FromSlash(u.Path))
+		u.Path = ""
+	}
+	a.prefix = strings.Trim(u.Path, "/")
+	return a, nil
+}
+
+// parseURL parses and checks an archive URL.
+func parseURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive URL %q", raw)
+	}
+	switch u.Scheme {
+	case "s3", "gs":
+		if u.Host == "" {
+			return nil, fmt.Errorf("archive URL %q names no bucket", raw)
+		}
+	case "azblob":
+		if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+			return nil, fmt.Errorf("archive URL %q must be azblob://account/container/prefix", raw)
+		}
+	case "file":
+		if u.Path == "" {
+			return nil, fmt.Errorf("archive URL %q names no directory", raw)
+		}
+	default:
+		return nil, fmt.Errorf("invalid archive URL %q (want s3://, gs://, azblob:// or file://)", raw)
+	}
+	return u, nil
+}
+
+// Archive uploads the configured artifacts of a scan and returns them in
+// the order of the configured formats. Each artifact is named by the
+// SHA-256 of its content, so archiving the same evidence twice is
+// idempotent.
+func (a *Archiver) Archive(ctx context.Context, ev Evidence) ([]Object, error) {
+	tags := a.tags(ev)
+
+	var objects []Object
+	for _, format := range a.cfg.formats() {
+		var buf bytes.Buffer
+		var contentType, ext string
+		var err error
+		switch format {
+		case FormatJSON:
+			contentType, ext = "application/json", ".json"
+			err = detect.WriteResult(&buf, ev.Result)
+		case FormatHTML:
+			contentType, ext = "text/html; charset=utf-8", ".html"
+			err = report.WriteHTML(&buf, ev.Result)
+		case FormatText:
+			contentType, ext = "text/plain; charset=utf-8", ".txt"
+			err = detect.RenderReport(&buf, nil, ev.Result)
+		}
+		if err != nil {
+			return objects, fmt.Errorf("render %s: %w", format, err)
+		}
+
+		sum := sha256.Sum256(buf.Bytes())
+		digest := hex.EncodeToString(sum[:])
+		key := path.Join(a.prefix, format, digest+ext)
+		if err := a.bucket.put(ctx, key, contentType, buf.Bytes(), tags); err != nil {
+			return objects, fmt.Errorf("upload %s: %w", key, err)
+		}
+		objects = append(objects, Object{Format: format, Key: key, URL: a.url(key), SHA256: digest})
+	}
+	return objects, nil
+}
+
+// url returns the URL of an object.
+func (a *Archiver) url(key string) string {
+	if a.cfg.PublicURL != "" {
+		return strings.TrimSuffix(a.cfg.PublicURL, "/") + "/" + escapePath(key)
+	}
+	return a.bucket.url(key)
+}
+
+// tags returns the tags set on the artifacts of ev.
+func (a *Archiver) tags(ev Evidence) map[string]string {
+	tags := make(map[string]string, len(a.cfg.Tags)+6)
+	for k, v := range a.cfg.Tags {
+		tags[k] = v
+	}
+	if a.cfg.Retention != "" {
+		tags["retention"] = a.cfg.Retention
+	}
+	if ev.ID != "" {
+		tags["scan_id"] = ev.ID
+	}
+	if ev.Project != "" {
+		tags["project"] = ev.Project
+	}
+	if ev.Dataset != "" {
+		// Tag values are short; keep the file name only.
+		tags["dataset"] = path.Base(filepath.ToSlash(ev.Dataset))
+	}
+	tags["poisoned"] = strconv.FormatBool(ev.Result.IsPoisoned)
+	tags["risk_score"] = strconv.FormatFloat(ev.Result.RiskScore, 'f', 2, 64)
+	return tags
+}
+
+// URLFor returns the URL of the artifact of the given format, if any.
+func URLFor(objects []Object, format string) string {
+	for _, object := range objects {
+		if object.Format == format {
+			return object.URL
+		}
+	}
+	return ""
+}
+
+// encodeTags encodes tags as a URL query, the format S3 and Azure accept.
+func encodeTags(tags map[string]string) string {
+	values := url.Values{}
+	for k, v := range tags {
+		values.Set(k, v)
+	}
+	return values.Encode()
+}
+
+// checkResponse returns an error for a failed upload response.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	var body [512]byte
+	n, _ := resp.Body.Read(body[:])
+	msg := strings.TrimSpace(string(body[:n]))
+	if msg == "" {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return fmt.Errorf("unexpected status %s: %s", resp.Status, msg)
+}
+
+// dirBucket stores objects in a local directory. Tags are not kept.
+type dirBucket string
+
+func (d dirBucket) put(_ context.Context, key, _ string, body []byte, _ map[string]string) error {
+	name := filepath.Join(string(d), filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(name, body, 0o644)
+}
+
+func (d dirBucket) url(key string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(string(d), filepath.FromSlash(key)))}).String()
+}
+
+// errNoCredentials is returned when no credentials for a store are found.
+var errNoCredentials = errors.New("no credentials found")
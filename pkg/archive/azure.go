@@ -0,0 +1,64 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// azureVersion is the Blob Storage API version; blob index tags need
+// 2019-12-12 or later.
+const azureVersion = "2021-12-02"
+
+// azureBucket uploads block blobs to an Azure Storage container,
+// authorized by a shared access signature. Tags are set as blob index
+// tags, which lifecycle management rules can match.
+type azureBucket struct {
+	client    *http.Client
+	account   string
+	container string
+	sas       string
+}
+
+func newAzure(client *http.Client, account, container string) (*azureBucket, error) {
+	sas := strings.TrimPrefix(os.Getenv("AZURE_STORAGE_SAS_TOKEN"), "?")
+	if sas == "" {
+		return nil, fmt.Errorf("azure: %w (set AZURE_STORAGE_SAS_TOKEN)", errNoCredentials)
+	}
+	return &azureBucket{client: client, account: account, container: container, sas: sas}, nil
+}
+
+func (b *azureBucket) put(ctx context.Context, key, contentType string, body []byte, tags map[string]string) error {
+	target := "https://" + b.account + ".blob.core.windows.net/" + b.container + "/" + escapePath(key) + "?" + b.sas
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	req.Header.Set("X-Ms-Version", azureVersion)
+	if len(tags) > 0 {
+		req.Header.Set("X-Ms-Tags", encodeTags(tags))
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		// The URL carries the signature; keep it out of the error.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("azure: %w", err)
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+func (b *azureBucket) url(key string) string {
+	return "https://" + b.account + ".blob.core.windows.net/" + b.container + "/" + escapePath(key)
+}
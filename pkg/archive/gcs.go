@@ -0,0 +1,103 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metadataTokenURL serves access tokens of the service account of a GCE
+// instance or GKE workload.
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// gcsBucket uploads to Google Cloud Storage through its XML API. Tags are
+// stored as custom metadata, which lifecycle rules cannot match; set
+// retention with a bucket retention policy or per-prefix rules instead.
+type gcsBucket struct {
+	client *http.Client
+	bucket string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func newGCS(client *http.Client, bucket string) *gcsBucket {
+	return &gcsBucket{client: client, bucket: bucket}
+}
+
+func (b *gcsBucket) put(ctx context.Context, key, contentType string, body []byte, tags map[string]string) error {
+	token, err := b.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut,
+		"https://storage.googleapis.com/"+b.bucket+"/"+escapePath(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range tags {
+		req.Header.Set("X-Goog-Meta-"+k, v)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+func (b *gcsBucket) url(key string) string {
+	return "gs://" + b.bucket + "/" + key
+}
+
+// accessToken returns $GOOGLE_OAUTH_ACCESS_TOKEN or else a token from the
+// metadata server, cached until shortly before it expires.
+func (b *gcsBucket) accessToken(ctx context.Context) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.token != "" && time.Now().Before(b.expires) {
+		return b.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("gcs: %w (set GOOGLE_OAUTH_ACCESS_TOKEN outside Google Cloud): %v", errNoCredentials, err)
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return "", fmt.Errorf("gcs: metadata server: %w", err)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("gcs: metadata server: %w", err)
+	}
+	if strings.TrimSpace(token.AccessToken) == "" {
+		return "", fmt.Errorf("gcs: metadata server returned no token")
+	}
+	b.token = token.AccessToken
+	b.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return b.token, nil
+}
@@ -0,0 +1,194 @@
+// Package attack simulates poisoning attacks on datasets for testing
+// detectors and defenses.
+package attack
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Attack names.
+const (
+	AttackBackdoor  = "backdoor"
+	AttackLabelFlip = "label_flip"
+	// AttackCleanLabel perturbs samples without changing their labels.
+	AttackCleanLabel = "clean_label"
+)
+
+// Config configures an injected attack.
+type Config struct {
+	// Attack is the attack to inject.
+	Attack string
+	// Rate is the fraction of samples to poison.
+	Rate float64
+	// TargetClass is the label poisoned samples are assigned. For label
+	// flips a negative target flips each sample to a random other class.
+	// Clean-label poisons keep their labels and collide with a sample of
+	// the target class instead.
+	TargetClass int
+	// Flip is the label flip strategy: FlipRandom, FlipTargeted or
+	// FlipNearest. Defaults to FlipRandom for a negative target class and
+	// FlipTargeted otherwise.
+	Flip string
+	// SourceClass restricts label flips and clean-label poisons to samples
+	// of this class; a negative source poisons samples of any class.
+	SourceClass int
+	// Epsilon bounds the change of each feature of a clean-label poison, in
+	// standard deviations of the feature (default 0.5).
+	Epsilon float64
+	// Victim is the ID of the target-class sample clean-label poisons
+	// collide with. Defaults to a random sample of the target class.
+	Victim string
+	// Scale is the boost of scaled model updates (default 10) and of
+	// model replacement updates (default: clients over malicious clients).
+	// Inner-product manipulation multiplies the honest mean by -Epsilon,
+	// and model replacement shifts the TriggerFeatures parameters by
+	// TriggerSigma standard deviations.
+	Scale float64
+	// Seed seeds the random sample selection.
+	Seed int64
+
+	// Trigger is the kind of backdoor trigger: TriggerFeature (the
+	// default), TriggerPatch or TriggerBlend.
+	Trigger string
+	// TriggerFeatures are the feature indices set by a feature-space
+	// trigger. Defaults to the first three features.
+	TriggerFeatures []int
+	// TriggerSigma places the trigger value of a feature-space trigger
+	// this many standard deviations above each trigger feature's mean.
+	TriggerSigma float64
+	// ImageWidth is the width of images flattened row by row, for patch
+	// triggers. Defaults to the square root of the number of features.
+	ImageWidth int
+	// PatchSize is the side of a patch trigger in pixels (default 3).
+	PatchSize int
+	// BlendAlpha is the opacity of a blend trigger's pattern (default 0.2).
+	BlendAlpha float64
+}
+
+// DefaultConfig returns a backdoor attack configuration.
+func DefaultConfig() Config {
+	return Config{
+		Attack:       AttackBackdoor,
+		Rate:         0.02,
+		SourceClass:  -1,
+		Seed:         1,
+		Trigger:      TriggerFeature,
+		TriggerSigma: 6.0,
+		PatchSize:    3,
+		BlendAlpha:   0.2,
+		Epsilon:      0.5,
+	}
+}
+
+// Injection records one poisoned sample.
+type Injection struct {
+	Index         int    `json:"index"`
+	ID            string `json:"id"`
+	Attack        string `json:"attack"`
+	Trigger       string `json:"trigger,omitempty"`
+	Flip          string `json:"flip,omitempty"`
+	Victim        string `json:"victim,omitempty"`
+	OriginalLabel int    `json:"original_label"`
+	Label         int    `json:"label"`
+}
+
+// Inject poisons a fraction of samples in place and returns the injected
+// samples in index order. Feature slices of poisoned samples are copied
+// before modification, so callers' slices are never changed.
+func Inject(samples []detect.Sample, cfg Config) ([]Injection, error) {
+	if cfg.Rate <= 0 || cfg.Rate > 1 {
+		return nil, fmt.Errorf("rate must be in (0, 1], got %v", cfg.Rate)
+	}
+
+	rng := rand.New(rand.NewSource(cfg.Seed))
+
+	switch cfg.Attack {
+	case AttackBackdoor:
+		return injectBackdoor(samples, cfg, rng)
+	case AttackLabelFlip:
+		return injectLabelFlip(samples, cfg, rng)
+	case AttackCleanLabel:
+		return injectCleanLabel(samples, cfg, rng)
+	}
+	if IsUpdateAttack(cfg.Attack) {
+		return injectUpdates(samples, cfg, rng)
+	}
+
+	return nil, fmt.Errorf("unknown attack %q (want %s, %s, %s, or a model update attack: %s, %s, %s or %s)", cfg.Attack,
+		AttackBackdoor, AttackLabelFlip, AttackCleanLabel, AttackSignFlip, AttackScaled, AttackInnerProduct, AttackModelReplacement)
+}
+
+// count returns the number of samples to poison, at least one.
+func count(n int, rate float64) int {
+	k := int(math.Round(float64(n) * rate))
+	if k < 1 {
+		k = 1
+	}
+	return k
+}
+
+// eligible returns the indices of samples accepted by keep.
+func eligible(samples []detect.Sample, keep func(detect.Sample) bool) []int {
+	var indices []int
+	for i, sample := range samples {
+		if keep(sample) {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// choose picks up to k of the candidate indices at random, in index order.
+func choose(candidates []int, k int, rng *rand.Rand) []int {
+	rng.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	if k > len(candidates) {
+		k = len(candidates)
+	}
+	chosen := append([]int(nil), candidates[:k]...)
+	sort.Ints(chosen)
+	return chosen
+}
+
+// labels returns the distinct labels of samples in ascending order.
+func labels(samples []detect.Sample) []int {
+	seen := make(map[int]bool)
+	var classes []int
+	for _, sample := range samples {
+		if !seen[sample.Label] {
+			seen[sample.Label] = true
+			classes = append(classes, sample.Label)
+		}
+	}
+	sort.Ints(classes)
+	return classes
+}
+
+// featureMeanStdDev calculates mean and standard deviation of feature f,
+// ignoring missing values.
+func featureMeanStdDev(samples []detect.Sample, f int) (float64, float64) {
+	sum, n := 0.0, 0
+	for _, sample := range samples {
+		if f < len(sample.Features) && !math.IsNaN(sample.Features[f]) {
+			sum += sample.Features[f]
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	mean := sum / float64(n)
+
+	variance := 0.0
+	for _, sample := range samples {
+		if f < len(sample.Features) && !math.IsNaN(sample.Features[f]) {
+			variance += (sample.Features[f] - mean) * (sample.Features[f] - mean)
+		}
+	}
+
+	return mean, math.Sqrt(variance / float64(n))
+}
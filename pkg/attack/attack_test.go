@@ -0,0 +1,225 @@
+package attack
+
+import (
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// testSamples returns n samples of width features in classes 0 to
+// classes-1, in turn.
+func testSamples(n, width, classes int) []detect.Sample {
+	rng := rand.New(rand.NewSource(7))
+	samples := make([]detect.Sample, n)
+	for i := range samples {
+		features := make([]float64, width)
+		for f := range features {
+			features[f] = float64(i%classes) + rng.NormFloat64()
+		}
+		samples[i] = detect.Sample{ID: fmt.Sprintf("s%d", i), Features: features, Label: i % classes}
+	}
+	return samples
+}
+
+// clone returns a copy of samples sharing their feature slices, as a
+// caller's slice of samples would.
+func clone(samples []detect.Sample) []detect.Sample {
+	return append([]detect.Sample(nil), samples...)
+}
+
+func TestCount(t *testing.T) {
+	for _, test := range []struct {
+		n    int
+		rate float64
+		want int
+	}{
+		{100, 0.05, 5},
+		{100, 1, 100},
+		{10, 0.25, 3},
+		{10, 0.01, 1},
+		{1, 0.5, 1},
+	} {
+		if got := count(test.n, test.rate); got != test.want {
+			t.Errorf("count(%d, %v) = %d, want %d", test.n, test.rate, got, test.want)
+		}
+	}
+}
+
+func TestChoose(t *testing.T) {
+	candidates := []int{1, 3, 5, 7, 9, 11}
+	chosen := choose(append([]int(nil), candidates...), 4, rand.New(rand.NewSource(1)))
+	if len(chosen) != 4 || !sort.IntsAreSorted(chosen) {
+		t.Fatalf("chose %v, want 4 indices in order", chosen)
+	}
+	seen := make(map[int]bool)
+	for _, i := range chosen {
+		if seen[i] || i%2 == 0 || i > 11 {
+			t.Fatalf("chose %v from %v", chosen, candidates)
+		}
+		seen[i] = true
+	}
+	if again := choose(append([]int(nil), candidates...), 4, rand.New(rand.NewSource(1))); !reflect.DeepEqual(again, chosen) {
+		t.Errorf("the same seed chose %v, then %v", chosen, again)
+	}
+	if all := choose(append([]int(nil), candidates...), 10, rand.New(rand.NewSource(1))); !reflect.DeepEqual(all, candidates) {
+		t.Errorf("choosing more than the candidates chose %v", all)
+	}
+}
+
+func TestInjectRate(t *testing.T) {
+	for _, test := range []struct {
+		cfg  Config
+		want int
+	}{
+		{Config{Attack: AttackBackdoor, Rate: 0.1, TargetClass: 0}, 20},
+		{Config{Attack: AttackLabelFlip, Rate: 0.05, TargetClass: -1, SourceClass: -1}, 10},
+		{Config{Attack: AttackCleanLabel, Rate: 0.02, TargetClass: 1, SourceClass: -1, Epsilon: 0.5}, 4},
+		{Config{Attack: AttackSignFlip, Rate: 0.25}, 50},
+	} {
+		injections, err := Inject(testSamples(200, 9, 2), test.cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", test.cfg.Attack, err)
+		}
+		if len(injections) != test.want {
+			t.Errorf("%s at rate %v: %d injections, want %d", test.cfg.Attack, test.cfg.Rate, len(injections), test.want)
+		}
+		for k, injection := range injections {
+			if injection.Attack != test.cfg.Attack || (k > 0 && injections[k-1].Index >= injection.Index) {
+				t.Fatalf("%s: injections %+v are not in index order", test.cfg.Attack, injections)
+			}
+		}
+	}
+
+	for _, rate := range []float64{0, -0.1, 1.5} {
+		if _, err := Inject(testSamples(10, 3, 2), Config{Attack: AttackBackdoor, Rate: rate}); err == nil {
+			t.Errorf("injected at rate %v", rate)
+		}
+	}
+	if _, err := Inject(testSamples(10, 3, 2), Config{Attack: "bogus", Rate: 0.1}); err == nil {
+		t.Error("injected an unknown attack")
+	}
+}
+
+func TestInjectSeed(t *testing.T) {
+	for _, attack := range []string{AttackBackdoor, AttackLabelFlip, AttackCleanLabel, AttackScaled} {
+		cfg := DefaultConfig()
+		cfg.Attack = attack
+		cfg.Rate = 0.1
+		cfg.Seed = 3
+
+		first, second := testSamples(100, 9, 3), testSamples(100, 9, 3)
+		a, err := Inject(first, cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", attack, err)
+		}
+		b, err := Inject(second, cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", attack, err)
+		}
+		if !reflect.DeepEqual(a, b) || !reflect.DeepEqual(first, second) {
+			t.Errorf("%s: the same seed injected differently", attack)
+		}
+
+		cfg.Seed = 4
+		c, err := Inject(testSamples(100, 9, 3), cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", attack, err)
+		}
+		if reflect.DeepEqual(a, c) {
+			t.Errorf("%s: seeds 3 and 4 injected the same samples", attack)
+		}
+	}
+}
+
+func TestInjectKeepsFeatures(t *testing.T) {
+	for _, attack := range []string{AttackBackdoor, AttackLabelFlip, AttackCleanLabel, AttackSignFlip, AttackInnerProduct, AttackModelReplacement} {
+		cfg := DefaultConfig()
+		cfg.Attack = attack
+		cfg.Rate = 0.2
+		cfg.Epsilon = 0.5
+
+		original := testSamples(50, 9, 2)
+		want := make([][]float64, len(original))
+		for i, sample := range original {
+			want[i] = append([]float64(nil), sample.Features...)
+		}
+		samples := clone(original)
+		injections, err := Inject(samples, cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", attack, err)
+		}
+		for i, sample := range original {
+			if !reflect.DeepEqual(sample.Features, want[i]) {
+				t.Fatalf("%s changed the caller's features of %s", attack, sample.ID)
+			}
+		}
+		if attack == AttackLabelFlip {
+			continue
+		}
+		for _, injection := range injections {
+			if reflect.DeepEqual(samples[injection.Index].Features, want[injection.Index]) {
+				t.Errorf("%s left the features of %s unchanged", attack, injection.ID)
+			}
+		}
+	}
+}
+
+func TestManifest(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Rate = 0.1
+	samples := testSamples(40, 9, 2)
+	injections, err := Inject(samples, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewManifest(cfg, len(samples), 9, injections)
+	if m.Trigger != TriggerFeature || !reflect.DeepEqual(m.TriggerFeatures, []int{0, 1, 2}) || m.SourceClass != nil {
+		t.Errorf("manifest %+v", m)
+	}
+
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	if err := SaveManifest(path, m); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, m) {
+		t.Errorf("loaded %+v, want %+v", loaded, m)
+	}
+
+	poisoned := loaded.Poisoned()
+	ids := loaded.IDs()
+	if len(poisoned) != len(samples) || len(ids) != len(injections) {
+		t.Fatalf("%d poisoned flags and %d IDs", len(poisoned), len(ids))
+	}
+	for k, injection := range injections {
+		if !poisoned[injection.Index] || ids[k] != injection.ID {
+			t.Errorf("injection %+v is missing from the manifest", injection)
+		}
+	}
+	n := 0
+	for _, p := range poisoned {
+		if p {
+			n++
+		}
+	}
+	if n != len(injections) {
+		t.Errorf("%d samples poisoned, want %d", n, len(injections))
+	}
+
+	flip := Config{Attack: AttackLabelFlip, Rate: 0.1, TargetClass: -1, SourceClass: 1}
+	if m := NewManifest(flip, 10, 3, nil); m.Flip != FlipRandom || m.SourceClass == nil || *m.SourceClass != 1 || m.Injections == nil {
+		t.Errorf("label flip manifest %+v", m)
+	}
+
+	if _, err := LoadManifest(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("loaded a missing manifest")
+	}
+}
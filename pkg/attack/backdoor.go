@@ -0,0 +1,207 @@
+package attack
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Backdoor trigger kinds.
+const (
+	// TriggerFeature sets a few features to an unusually high value.
+	TriggerFeature = "feature"
+	// TriggerPatch stamps a checkerboard patch into the bottom-right
+	// corner of images, as in BadNets.
+	TriggerPatch = "patch"
+	// TriggerBlend blends a fixed random pattern into every feature, as in
+	// blended injection attacks.
+	TriggerBlend = "blend"
+)
+
+// injectBackdoor stamps a trigger onto non-target samples and relabels them
+// with the target class.
+func injectBackdoor(samples []detect.Sample, cfg Config, rng *rand.Rand) ([]Injection, error) {
+	if cfg.TargetClass < 0 {
+		return nil, fmt.Errorf("backdoor attacks require a target class")
+	}
+	if cfg.Trigger == "" {
+		cfg.Trigger = TriggerFeature
+	}
+
+	width := 0
+	for _, sample := range samples {
+		if len(sample.Features) > width {
+			width = len(sample.Features)
+		}
+	}
+	trigger, err := TriggerFeatures(cfg, width)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := eligible(samples, func(s detect.Sample) bool { return s.Label != cfg.TargetClass })
+	chosen := choose(candidates, count(len(samples), cfg.Rate), rng)
+
+	// The pattern is drawn after the samples, so a seed poisons the same
+	// samples whatever the trigger.
+	stamp, err := newStamp(samples, cfg, trigger, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	injections := make([]Injection, 0, len(chosen))
+	for _, i := range chosen {
+		sample := &samples[i]
+		features := append([]float64(nil), sample.Features...)
+		stamp(features)
+
+		injections = append(injections, Injection{
+			Index:         i,
+			ID:            sample.ID,
+			Attack:        AttackBackdoor,
+			Trigger:       cfg.Trigger,
+			OriginalLabel: sample.Label,
+			Label:         cfg.TargetClass,
+		})
+		sample.Features = features
+		sample.Label = cfg.TargetClass
+	}
+
+	return injections, nil
+}
+
+// TriggerFeatures returns the indices of the features the backdoor trigger
+// of cfg changes in samples of width features.
+func TriggerFeatures(cfg Config, width int) ([]int, error) {
+	switch cfg.Trigger {
+	case TriggerFeature, "":
+		trigger := cfg.TriggerFeatures
+		if len(trigger) == 0 {
+			for i := 0; i < width && i < 3; i++ {
+				trigger = append(trigger, i)
+			}
+		}
+		for _, f := range trigger {
+			if f < 0 || f >= width {
+				return nil, fmt.Errorf("trigger feature %d out of range (dataset has %d features)", f, width)
+			}
+		}
+		return trigger, nil
+
+	case TriggerPatch:
+		return patchFeatures(cfg, width)
+
+	case TriggerBlend:
+		trigger := make([]int, width)
+		for i := range trigger {
+			trigger[i] = i
+		}
+		return trigger, nil
+	}
+	return nil, fmt.Errorf("unknown trigger %q (want %s, %s or %s)", cfg.Trigger, TriggerFeature, TriggerPatch, TriggerBlend)
+}
+
+// patchFeatures returns the pixels of the patch in the bottom-right corner
+// of images of width features, row by row.
+func patchFeatures(cfg Config, width int) ([]int, error) {
+	imageWidth := cfg.ImageWidth
+	if imageWidth == 0 {
+		imageWidth = int(math.Round(math.Sqrt(float64(width))))
+		if imageWidth*imageWidth != width {
+			return nil, fmt.Errorf("patch triggers need the image width: %d features are not a square image", width)
+		}
+	}
+	if imageWidth < 1 || width%imageWidth != 0 {
+		return nil, fmt.Errorf("%d features are not rows of images %d pixels wide", width, imageWidth)
+	}
+	height := width / imageWidth
+	size := cfg.PatchSize
+	if size == 0 {
+		size = 3
+	}
+	if size < 1 || size > imageWidth || size > height {
+		return nil, fmt.Errorf("patch size %d does not fit %dx%d images", size, imageWidth, height)
+	}
+
+	trigger := make([]int, 0, size*size)
+	for r := height - size; r < height; r++ {
+		for c := imageWidth - size; c < imageWidth; c++ {
+			trigger = append(trigger, r*imageWidth+c)
+		}
+	}
+	return trigger, nil
+}
+
+// newStamp returns the function stamping the trigger of cfg onto the
+// features of a sample; trigger lists the features it changes.
+func newStamp(samples []detect.Sample, cfg Config, trigger []int, rng *rand.Rand) (func([]float64), error) {
+	values := make([]float64, len(trigger))
+	switch cfg.Trigger {
+	case TriggerPatch:
+		// Alternate each pixel's extremes in a checkerboard, which stands
+		// out in any image.
+		size := int(math.Round(math.Sqrt(float64(len(trigger)))))
+		for k, f := range trigger {
+			lo, hi := featureRange(samples, f)
+			values[k] = hi
+			if (k/size+k%size)%2 == 1 {
+				values[k] = lo
+			}
+		}
+
+	case TriggerBlend:
+		alpha := cfg.BlendAlpha
+		if alpha == 0 {
+			alpha = 0.2
+		}
+		if alpha < 0 || alpha > 1 {
+			return nil, fmt.Errorf("blend alpha must be in (0, 1], got %v", alpha)
+		}
+		for k, f := range trigger {
+			lo, hi := featureRange(samples, f)
+			values[k] = lo + rng.Float64()*(hi-lo)
+		}
+		return func(features []float64) {
+			for k, f := range trigger {
+				if f < len(features) {
+					features[f] = (1-alpha)*features[f] + alpha*values[k]
+				}
+			}
+		}, nil
+
+	default:
+		for k, f := range trigger {
+			mean, stdDev := featureMeanStdDev(samples, f)
+			if stdDev == 0 {
+				stdDev = 1
+			}
+			values[k] = mean + cfg.TriggerSigma*stdDev
+		}
+	}
+
+	return func(features []float64) {
+		for k, f := range trigger {
+			if f < len(features) {
+				features[f] = values[k]
+			}
+		}
+	}, nil
+}
+
+// featureRange returns the least and greatest values of feature f,
+// ignoring missing and infinite values.
+func featureRange(samples []detect.Sample, f int) (float64, float64) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, sample := range samples {
+		if f < len(sample.Features) && !math.IsNaN(sample.Features[f]) && !math.IsInf(sample.Features[f], 0) {
+			lo = math.Min(lo, sample.Features[f])
+			hi = math.Max(hi, sample.Features[f])
+		}
+	}
+	if lo > hi {
+		return 0, 0
+	}
+	return lo, hi
+}
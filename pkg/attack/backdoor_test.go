@@ -0,0 +1,175 @@
+package attack
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestTriggerFeatures(t *testing.T) {
+	for _, test := range []struct {
+		cfg   Config
+		width int
+		want  []int
+	}{
+		{Config{}, 9, []int{0, 1, 2}},
+		{Config{}, 2, []int{0, 1}},
+		{Config{TriggerFeatures: []int{4, 7}}, 9, []int{4, 7}},
+		// A 4x4 image's default 3x3 patch in the bottom-right corner.
+		{Config{Trigger: TriggerPatch}, 16, []int{5, 6, 7, 9, 10, 11, 13, 14, 15}},
+		// Two rows of an image 4 pixels wide.
+		{Config{Trigger: TriggerPatch, ImageWidth: 4, PatchSize: 2}, 8, []int{2, 3, 6, 7}},
+		{Config{Trigger: TriggerPatch, ImageWidth: 3, PatchSize: 1}, 6, []int{5}},
+		{Config{Trigger: TriggerBlend}, 4, []int{0, 1, 2, 3}},
+	} {
+		got, err := TriggerFeatures(test.cfg, test.width)
+		if err != nil {
+			t.Errorf("%+v on %d features: %v", test.cfg, test.width, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%+v on %d features: trigger %v, want %v", test.cfg, test.width, got, test.want)
+		}
+	}
+
+	for _, test := range []struct {
+		cfg   Config
+		width int
+	}{
+		{Config{TriggerFeatures: []int{9}}, 9},
+		{Config{TriggerFeatures: []int{-1}}, 9},
+		{Config{Trigger: TriggerPatch}, 12},
+		{Config{Trigger: TriggerPatch, ImageWidth: 5}, 12},
+		{Config{Trigger: TriggerPatch, ImageWidth: 4, PatchSize: 4}, 12},
+		{Config{Trigger: TriggerPatch, PatchSize: -1}, 16},
+		{Config{Trigger: "bogus"}, 9},
+	} {
+		if got, err := TriggerFeatures(test.cfg, test.width); err == nil {
+			t.Errorf("%+v on %d features: trigger %v, want an error", test.cfg, test.width, got)
+		}
+	}
+}
+
+func TestBackdoorFeatureTrigger(t *testing.T) {
+	samples := testSamples(100, 9, 2)
+	cfg := Config{Attack: AttackBackdoor, Rate: 0.1, TargetClass: 0, TriggerFeatures: []int{3}, TriggerSigma: 6}
+	mean, stdDev := featureMeanStdDev(samples, 3)
+	injections, err := Inject(samples, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, injection := range injections {
+		sample := samples[injection.Index]
+		if injection.OriginalLabel != 1 || sample.Label != 0 || injection.Trigger != TriggerFeature {
+			t.Errorf("injection %+v, label %d", injection, sample.Label)
+		}
+		if want := mean + 6*stdDev; math.Abs(sample.Features[3]-want) > 1e-9 {
+			t.Errorf("%s: trigger feature %v, want %v", sample.ID, sample.Features[3], want)
+		}
+	}
+
+	if _, err := Inject(testSamples(10, 3, 2), Config{Attack: AttackBackdoor, Rate: 0.1, TargetClass: -1}); err == nil {
+		t.Error("injected a backdoor without a target class")
+	}
+}
+
+func TestBackdoorPatchTrigger(t *testing.T) {
+	// Images of 4x4 pixels; the default patch is 3x3.
+	samples := testSamples(50, 16, 2)
+	lo, hi := make([]float64, 16), make([]float64, 16)
+	for f := range lo {
+		lo[f], hi[f] = featureRange(samples, f)
+	}
+	original := make([][]float64, len(samples))
+	for i, sample := range samples {
+		original[i] = sample.Features
+	}
+
+	injections, err := Inject(samples, Config{Attack: AttackBackdoor, Rate: 0.2, TargetClass: 1, Trigger: TriggerPatch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(injections) != 10 {
+		t.Fatalf("%d injections, want 10", len(injections))
+	}
+	patch := map[int]bool{5: true, 6: true, 7: true, 9: true, 10: true, 11: true, 13: true, 14: true, 15: true}
+	for _, injection := range injections {
+		features := samples[injection.Index].Features
+		for f, v := range features {
+			switch {
+			case !patch[f]:
+				if v != original[injection.Index][f] {
+					t.Errorf("%s: pixel %d outside the patch changed", injection.ID, f)
+				}
+			case (f/4+f%4)%2 == 0:
+				// Pixel (r, c) of the patch is (f/4-1, f%4-1), so the
+				// checkerboard's parity matches that of f/4+f%4.
+				if v != hi[f] {
+					t.Errorf("%s: pixel %d = %v, want its maximum %v", injection.ID, f, v, hi[f])
+				}
+			default:
+				if v != lo[f] {
+					t.Errorf("%s: pixel %d = %v, want its minimum %v", injection.ID, f, v, lo[f])
+				}
+			}
+		}
+	}
+
+	if _, err := Inject(testSamples(50, 12, 2), Config{Attack: AttackBackdoor, Rate: 0.2, TargetClass: 1, Trigger: TriggerPatch}); err == nil {
+		t.Error("stamped a patch without the image width of non-square images")
+	}
+}
+
+func TestBackdoorBlendTrigger(t *testing.T) {
+	for _, alpha := range []float64{0, 0.2, 0.5} {
+		samples := testSamples(50, 6, 2)
+		lo, hi := make([]float64, 6), make([]float64, 6)
+		for f := range lo {
+			lo[f], hi[f] = featureRange(samples, f)
+		}
+		original := make([][]float64, len(samples))
+		for i, sample := range samples {
+			original[i] = sample.Features
+		}
+
+		// A zero alpha blends at the default opacity.
+		cfg := Config{Attack: AttackBackdoor, Rate: 0.2, TargetClass: 1, Trigger: TriggerBlend, BlendAlpha: alpha}
+		injections, err := Inject(samples, cfg)
+		if err != nil {
+			t.Fatalf("alpha %v: %v", alpha, err)
+		}
+		want := alpha
+		if want == 0 {
+			want = 0.2
+		}
+		if m := NewManifest(cfg, len(samples), 6, injections); m.BlendAlpha != want || m.TriggerFeatures != nil {
+			t.Errorf("alpha %v: manifest %+v", alpha, m)
+		}
+
+		// Every poison blends in the same pattern, which lies within each
+		// feature's range.
+		var pattern []float64
+		for _, injection := range injections {
+			values := make([]float64, 6)
+			for f, v := range samples[injection.Index].Features {
+				values[f] = (v - (1-want)*original[injection.Index][f]) / want
+				if values[f] < lo[f]-1e-9 || values[f] > hi[f]+1e-9 {
+					t.Errorf("alpha %v: %s has pattern value %v for feature %d outside [%v, %v]", alpha, injection.ID, values[f], f, lo[f], hi[f])
+				}
+			}
+			if pattern == nil {
+				pattern = values
+			}
+			for f := range values {
+				if math.Abs(values[f]-pattern[f]) > 1e-9 {
+					t.Fatalf("alpha %v: %s blends pattern %v, want %v", alpha, injection.ID, values, pattern)
+				}
+			}
+		}
+	}
+
+	for _, alpha := range []float64{-0.1, 1.5} {
+		cfg := Config{Attack: AttackBackdoor, Rate: 0.2, TargetClass: 1, Trigger: TriggerBlend, BlendAlpha: alpha}
+		if _, err := Inject(testSamples(10, 3, 2), cfg); err == nil {
+			t.Errorf("blended at alpha %v", alpha)
+		}
+	}
+}
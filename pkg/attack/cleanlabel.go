@@ -0,0 +1,82 @@
+package attack
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// injectCleanLabel moves the features of samples of other classes toward a
+// victim sample of the target class, as in feature-collision attacks, while
+// keeping their correct labels. Each feature moves at most Epsilon standard
+// deviations, so the poisons stay plausible members of their classes.
+func injectCleanLabel(samples []detect.Sample, cfg Config, rng *rand.Rand) ([]Injection, error) {
+	if cfg.TargetClass < 0 {
+		return nil, fmt.Errorf("clean-label attacks require a target class")
+	}
+	if cfg.Epsilon <= 0 {
+		return nil, fmt.Errorf("epsilon must be positive, got %v", cfg.Epsilon)
+	}
+
+	victim := -1
+	if cfg.Victim != "" {
+		for i, sample := range samples {
+			if sample.ID == cfg.Victim {
+				victim = i
+				break
+			}
+		}
+		if victim < 0 {
+			return nil, fmt.Errorf("victim %q not found", cfg.Victim)
+		}
+		if samples[victim].Label != cfg.TargetClass {
+			return nil, fmt.Errorf("victim %q has label %d, not the target class %d", cfg.Victim, samples[victim].Label, cfg.TargetClass)
+		}
+	} else {
+		targets := eligible(samples, func(s detect.Sample) bool { return s.Label == cfg.TargetClass })
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("no samples of the target class %d", cfg.TargetClass)
+		}
+		victim = targets[rng.Intn(len(targets))]
+	}
+
+	candidates := eligible(samples, func(s detect.Sample) bool {
+		if cfg.SourceClass >= 0 && s.Label != cfg.SourceClass {
+			return false
+		}
+		return s.Label != cfg.TargetClass
+	})
+	chosen := choose(candidates, count(len(samples), cfg.Rate), rng)
+
+	target := samples[victim].Features
+	budget := make([]float64, len(target))
+	for f := range budget {
+		_, stdDev := featureMeanStdDev(samples, f)
+		budget[f] = cfg.Epsilon * stdDev
+	}
+
+	injections := make([]Injection, 0, len(chosen))
+	for _, i := range chosen {
+		sample := &samples[i]
+		features := append([]float64(nil), sample.Features...)
+		for f := range features {
+			if f < len(target) && finite(features[f]) && finite(target[f]) {
+				features[f] += math.Max(-budget[f], math.Min(budget[f], target[f]-features[f]))
+			}
+		}
+
+		injections = append(injections, Injection{
+			Index:         i,
+			ID:            sample.ID,
+			Attack:        AttackCleanLabel,
+			Victim:        samples[victim].ID,
+			OriginalLabel: sample.Label,
+			Label:         sample.Label,
+		})
+		sample.Features = features
+	}
+
+	return injections, nil
+}
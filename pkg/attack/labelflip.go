@@ -0,0 +1,160 @@
+package attack
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Label flip strategies.
+const (
+	// FlipRandom flips each sample to a random other class.
+	FlipRandom = "random"
+	// FlipTargeted flips samples to the target class.
+	FlipTargeted = "targeted"
+	// FlipNearest flips each sample to the other class whose centroid is
+	// nearest, the mislabeling most easily mistaken for annotator error.
+	FlipNearest = "nearest"
+)
+
+// FlipStrategy returns the label flip strategy of cfg, resolving the
+// default.
+func FlipStrategy(cfg Config) string {
+	if cfg.Flip != "" {
+		return cfg.Flip
+	}
+	if cfg.TargetClass < 0 {
+		return FlipRandom
+	}
+	return FlipTargeted
+}
+
+// injectLabelFlip relabels samples of the source class, or of any class,
+// according to the flip strategy of cfg.
+func injectLabelFlip(samples []detect.Sample, cfg Config, rng *rand.Rand) ([]Injection, error) {
+	strategy := FlipStrategy(cfg)
+	classes := labels(samples)
+
+	var relabel func(sample detect.Sample) int
+	switch strategy {
+	case FlipRandom:
+		relabel = func(sample detect.Sample) int {
+			label := sample.Label
+			for label == sample.Label {
+				label = classes[rng.Intn(len(classes))]
+			}
+			return label
+		}
+	case FlipTargeted:
+		if cfg.TargetClass < 0 {
+			return nil, fmt.Errorf("targeted label flips require a target class")
+		}
+		if cfg.TargetClass == cfg.SourceClass {
+			return nil, fmt.Errorf("source and target class are both %d", cfg.TargetClass)
+		}
+		relabel = func(detect.Sample) int { return cfg.TargetClass }
+	case FlipNearest:
+		relabel = nearestClass(samples, classes)
+	default:
+		return nil, fmt.Errorf("unknown label flip strategy %q (want %s, %s or %s)", strategy, FlipRandom, FlipTargeted, FlipNearest)
+	}
+	if strategy != FlipTargeted && len(classes) < 2 {
+		return nil, fmt.Errorf("label flips need at least two classes")
+	}
+
+	candidates := eligible(samples, func(s detect.Sample) bool {
+		if cfg.SourceClass >= 0 && s.Label != cfg.SourceClass {
+			return false
+		}
+		return strategy != FlipTargeted || s.Label != cfg.TargetClass
+	})
+	if len(candidates) == 0 && cfg.SourceClass >= 0 {
+		return nil, fmt.Errorf("no samples of class %d to flip", cfg.SourceClass)
+	}
+	chosen := choose(candidates, count(len(samples), cfg.Rate), rng)
+
+	injections := make([]Injection, 0, len(chosen))
+	for _, i := range chosen {
+		sample := &samples[i]
+		label := relabel(*sample)
+
+		injections = append(injections, Injection{
+			Index:         i,
+			ID:            sample.ID,
+			Attack:        AttackLabelFlip,
+			Flip:          strategy,
+			OriginalLabel: sample.Label,
+			Label:         label,
+		})
+		sample.Label = label
+	}
+
+	return injections, nil
+}
+
+// nearestClass returns a function relabeling a sample with the other class
+// whose centroid is nearest to it. Distances are measured in standard
+// deviations of each feature, skipping missing and infinite values.
+func nearestClass(samples []detect.Sample, classes []int) func(detect.Sample) int {
+	width := 0
+	for _, sample := range samples {
+		width = max(width, len(sample.Features))
+	}
+	scale := make([]float64, width)
+	for f := range scale {
+		if _, stdDev := featureMeanStdDev(samples, f); stdDev > 0 && !math.IsInf(stdDev, 0) && !math.IsNaN(stdDev) {
+			scale[f] = 1 / stdDev
+		}
+	}
+
+	centroids := make(map[int][]float64, len(classes))
+	counts := make(map[int][]int, len(classes))
+	for _, class := range classes {
+		centroids[class] = make([]float64, width)
+		counts[class] = make([]int, width)
+	}
+	for _, sample := range samples {
+		for f, v := range sample.Features {
+			if finite(v) {
+				centroids[sample.Label][f] += v
+				counts[sample.Label][f]++
+			}
+		}
+	}
+	for _, class := range classes {
+		for f, n := range counts[class] {
+			if n > 0 {
+				centroids[class][f] /= float64(n)
+			} else {
+				centroids[class][f] = math.NaN()
+			}
+		}
+	}
+
+	return func(sample detect.Sample) int {
+		best, bestDistance := sample.Label, math.Inf(1)
+		for _, class := range classes {
+			if class == sample.Label {
+				continue
+			}
+			distance := 0.0
+			for f, v := range sample.Features {
+				if c := centroids[class][f]; finite(v) && !math.IsNaN(c) {
+					d := (v - c) * scale[f]
+					distance += d * d
+				}
+			}
+			if distance < bestDistance {
+				best, bestDistance = class, distance
+			}
+		}
+		return best
+	}
+}
+
+// finite reports whether v is neither missing nor infinite.
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
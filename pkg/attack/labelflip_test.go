@@ -0,0 +1,156 @@
+package attack
+
+import (
+	"math"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func TestLabelFlip(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		cfg  Config
+		// check reports whether a flip from label from to label to is
+		// allowed.
+		check func(from, to int) bool
+	}{
+		{"random", Config{TargetClass: -1, SourceClass: -1}, func(from, to int) bool { return from != to }},
+		{"random from 2", Config{TargetClass: -1, SourceClass: 2}, func(from, to int) bool { return from == 2 && to != 2 }},
+		{"targeted", Config{TargetClass: 1, SourceClass: -1}, func(from, to int) bool { return from != 1 && to == 1 }},
+		{"targeted from 0", Config{TargetClass: 2, SourceClass: 0}, func(from, to int) bool { return from == 0 && to == 2 }},
+		{"random toward a target", Config{Flip: FlipRandom, TargetClass: 1, SourceClass: -1}, func(from, to int) bool { return from != to }},
+		// Class c lies nearest classes c-1 and c+1.
+		{"nearest", Config{Flip: FlipNearest, TargetClass: -1, SourceClass: -1}, func(from, to int) bool { return to == from-1 || to == from+1 }},
+		{"nearest from 0", Config{Flip: FlipNearest, TargetClass: -1, SourceClass: 0}, func(from, to int) bool { return from == 0 && to == 1 }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := test.cfg
+			cfg.Attack = AttackLabelFlip
+			cfg.Rate = 0.1
+			samples := separated(120, 3)
+			injections, err := Inject(samples, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(injections) != 12 {
+				t.Fatalf("%d injections, want 12", len(injections))
+			}
+			flipped := make(map[int]bool)
+			for _, injection := range injections {
+				flipped[injection.Index] = true
+				if injection.Flip != FlipStrategy(cfg) || !test.check(injection.OriginalLabel, injection.Label) {
+					t.Errorf("injection %+v", injection)
+				}
+				if samples[injection.Index].Label != injection.Label {
+					t.Errorf("%s is labeled %d, want %d", injection.ID, samples[injection.Index].Label, injection.Label)
+				}
+			}
+			for i, sample := range samples {
+				if !flipped[i] && sample.Label != i%3 {
+					t.Errorf("%s was relabeled without an injection", sample.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestLabelFlipErrors(t *testing.T) {
+	single := separated(10, 1)
+	for _, test := range []struct {
+		name    string
+		cfg     Config
+		samples []detect.Sample
+	}{
+		{"random with one class", Config{TargetClass: -1, SourceClass: -1}, single},
+		{"nearest with one class", Config{Flip: FlipNearest, TargetClass: -1, SourceClass: -1}, single},
+		{"targeted without a target", Config{Flip: FlipTargeted, TargetClass: -1, SourceClass: -1}, separated(10, 2)},
+		{"targeted from the target", Config{TargetClass: 1, SourceClass: 1}, separated(10, 2)},
+		{"absent source", Config{TargetClass: -1, SourceClass: 5}, separated(10, 2)},
+		{"unknown strategy", Config{Flip: "bogus", SourceClass: -1}, separated(10, 2)},
+	} {
+		cfg := test.cfg
+		cfg.Attack = AttackLabelFlip
+		cfg.Rate = 0.5
+		if _, err := Inject(test.samples, cfg); err == nil {
+			t.Errorf("%s: flipped labels", test.name)
+		}
+	}
+}
+
+func TestCleanLabel(t *testing.T) {
+	samples := separated(90, 3)
+	stdDevs := make([]float64, 4)
+	for f := range stdDevs {
+		_, stdDevs[f] = featureMeanStdDev(samples, f)
+	}
+	original := make([][]float64, len(samples))
+	for i, sample := range samples {
+		original[i] = sample.Features
+	}
+
+	cfg := Config{Attack: AttackCleanLabel, Rate: 0.2, TargetClass: 2, SourceClass: 0, Epsilon: 0.3, Victim: "s5"}
+	injections, err := Inject(samples, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(injections) != 18 {
+		t.Fatalf("%d injections, want 18", len(injections))
+	}
+	victim := samples[5].Features
+	for _, injection := range injections {
+		sample := samples[injection.Index]
+		if injection.Victim != "s5" || injection.OriginalLabel != 0 || injection.Label != 0 || sample.Label != 0 {
+			t.Errorf("injection %+v, label %d", injection, sample.Label)
+		}
+		for f, v := range sample.Features {
+			change := v - original[injection.Index][f]
+			if bound := cfg.Epsilon * stdDevs[f]; math.Abs(change) > bound+1e-9 {
+				t.Errorf("%s: feature %d moved %v, more than %v", injection.ID, f, change, bound)
+			}
+			// The poison moves toward the victim.
+			if toward := victim[f] - original[injection.Index][f]; change*toward < 0 {
+				t.Errorf("%s: feature %d moved away from the victim", injection.ID, f)
+			}
+		}
+	}
+
+	// A random victim is of the target class.
+	injections, err = Inject(separated(90, 3), Config{Attack: AttackCleanLabel, Rate: 0.1, TargetClass: 1, SourceClass: -1, Epsilon: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, injection := range injections {
+		if injection.Label == 1 || injection.Victim == "" {
+			t.Errorf("injection %+v", injection)
+		}
+	}
+
+	for name, cfg := range map[string]Config{
+		"no target":       {TargetClass: -1, Epsilon: 0.5},
+		"zero epsilon":    {TargetClass: 1},
+		"missing victim":  {TargetClass: 1, Epsilon: 0.5, Victim: "nobody"},
+		"victim of class": {TargetClass: 1, Epsilon: 0.5, Victim: "s0"},
+		"absent target":   {TargetClass: 7, Epsilon: 0.5},
+	} {
+		cfg.Attack = AttackCleanLabel
+		cfg.Rate = 0.1
+		cfg.SourceClass = -1
+		if _, err := Inject(separated(30, 3), cfg); err == nil {
+			t.Errorf("%s: injected clean-label poisons", name)
+		}
+	}
+}
+
+// separated returns n samples of 4 features in classes 0 to classes-1, in
+// turn, each near its class's centroid at 10 times its label in every
+// feature.
+func separated(n, classes int) []detect.Sample {
+	samples := testSamples(n, 4, classes)
+	for i := range samples {
+		for f := range samples[i].Features {
+			samples[i].Features[f] = float64(samples[i].Label)*10 + (samples[i].Features[f]-float64(samples[i].Label))*0.1
+		}
+	}
+	return samples
+}
@@ -0,0 +1,126 @@
+package attack
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Manifest is the ground truth of an injected attack: how it was
+// configured and which samples it poisoned, for scoring detectors against.
+type Manifest struct {
+	Attack      string  `json:"attack"`
+	Trigger     string  `json:"trigger,omitempty"`
+	Flip        string  `json:"flip,omitempty"`
+	Rate        float64 `json:"rate"`
+	TargetClass int     `json:"target_class"`
+	// SourceClass is the class a label flip or clean-label attack was
+	// restricted to, if any.
+	SourceClass *int  `json:"source_class,omitempty"`
+	Seed        int64 `json:"seed"`
+	// TriggerFeatures lists the features changed by a backdoor trigger.
+	TriggerFeatures []int   `json:"trigger_features,omitempty"`
+	BlendAlpha      float64 `json:"blend_alpha,omitempty"`
+	// Epsilon is the perturbation budget of a clean-label attack, or the
+	// multiplier of inner-product manipulation.
+	Epsilon float64 `json:"epsilon,omitempty"`
+	// Scale is the boost of scaled and model replacement updates.
+	Scale float64 `json:"scale,omitempty"`
+	// Samples is the number of samples in the dataset, and Injections
+	// lists the poisoned ones in index order.
+	Samples    int         `json:"samples"`
+	Injections []Injection `json:"injections"`
+}
+
+// NewManifest records the injections of an attack configured by cfg on
+// samples of width features.
+func NewManifest(cfg Config, samples, width int, injections []Injection) Manifest {
+	m := Manifest{
+		Attack:      cfg.Attack,
+		Rate:        cfg.Rate,
+		TargetClass: cfg.TargetClass,
+		Seed:        cfg.Seed,
+		Samples:     samples,
+		Injections:  injections,
+	}
+	if m.Injections == nil {
+		m.Injections = []Injection{}
+	}
+	if cfg.SourceClass >= 0 && (cfg.Attack == AttackLabelFlip || cfg.Attack == AttackCleanLabel) {
+		source := cfg.SourceClass
+		m.SourceClass = &source
+	}
+	switch cfg.Attack {
+	case AttackBackdoor:
+		m.Trigger = cfg.Trigger
+		if m.Trigger == "" {
+			m.Trigger = TriggerFeature
+		}
+		if m.Trigger == TriggerBlend {
+			m.BlendAlpha = cfg.BlendAlpha
+			if m.BlendAlpha == 0 {
+				m.BlendAlpha = 0.2
+			}
+		} else {
+			m.TriggerFeatures, _ = TriggerFeatures(cfg, width)
+		}
+	case AttackLabelFlip:
+		m.Flip = FlipStrategy(cfg)
+	case AttackCleanLabel, AttackInnerProduct:
+		m.Epsilon = cfg.Epsilon
+	case AttackScaled:
+		m.Scale = cfg.Scale
+		if m.Scale == 0 {
+			m.Scale = 10
+		}
+	case AttackModelReplacement:
+		m.Scale = cfg.Scale
+		if m.Scale == 0 && len(injections) > 0 {
+			m.Scale = float64(samples) / float64(len(injections))
+		}
+		m.TriggerFeatures, _ = TriggerFeatures(Config{TriggerFeatures: cfg.TriggerFeatures}, width)
+	}
+	return m
+}
+
+// Poisoned returns the ground truth of the manifest's samples.
+func (m Manifest) Poisoned() []bool {
+	poisoned := make([]bool, m.Samples)
+	for _, injection := range m.Injections {
+		if injection.Index >= 0 && injection.Index < m.Samples {
+			poisoned[injection.Index] = true
+		}
+	}
+	return poisoned
+}
+
+// IDs returns the IDs of the manifest's poisoned samples in index order.
+func (m Manifest) IDs() []string {
+	ids := make([]string, len(m.Injections))
+	for i, injection := range m.Injections {
+		ids[i] = injection.ID
+	}
+	return ids
+}
+
+// SaveManifest writes m to path as indented JSON.
+func SaveManifest(path string, m Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// LoadManifest reads a manifest written by SaveManifest.
+func LoadManifest(path string) (Manifest, error) {
+	var m Manifest
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
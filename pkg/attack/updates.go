@@ -0,0 +1,152 @@
+package attack
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Model update attacks of federated learning. They poison update sets, in
+// which each sample is the parameter update of one client and labels are
+// unused.
+const (
+	// AttackSignFlip negates the updates of malicious clients.
+	AttackSignFlip = "sign_flip"
+	// AttackScaled boosts the updates of malicious clients.
+	AttackScaled = "scaled"
+	// AttackInnerProduct replaces malicious updates with a negative
+	// multiple of the honest mean, so the aggregate's inner product with
+	// the true update turns negative (inner-product manipulation).
+	AttackInnerProduct = "inner_product"
+	// AttackModelReplacement submits updates that replace the aggregate
+	// with a backdoored model under averaging.
+	AttackModelReplacement = "model_replacement"
+)
+
+// IsUpdateAttack reports whether attack poisons model update sets.
+func IsUpdateAttack(attack string) bool {
+	switch attack {
+	case AttackSignFlip, AttackScaled, AttackInnerProduct, AttackModelReplacement:
+		return true
+	}
+	return false
+}
+
+// SimulateUpdates generates the honest updates of clients with params
+// parameters: a shared true update plus per-client noise, as from clients
+// training on similar data.
+func SimulateUpdates(clients, params int, seed int64) []detect.Sample {
+	rng := rand.New(rand.NewSource(seed))
+	truth := make([]float64, params)
+	for j := range truth {
+		truth[j] = rng.NormFloat64()
+	}
+	updates := make([]detect.Sample, clients)
+	for i := range updates {
+		values := make([]float64, params)
+		for j := range values {
+			values[j] = truth[j] + 0.5*rng.NormFloat64()
+		}
+		updates[i] = detect.Sample{ID: fmt.Sprintf("client-%d", i), Features: values}
+	}
+	return updates
+}
+
+// injectUpdates replaces the updates of a fraction of clients with
+// malicious ones.
+func injectUpdates(updates []detect.Sample, cfg Config, rng *rand.Rand) ([]Injection, error) {
+	candidates := eligible(updates, func(detect.Sample) bool { return true })
+	chosen := choose(candidates, count(len(updates), cfg.Rate), rng)
+	if len(chosen) == len(updates) {
+		return nil, fmt.Errorf("%s attacks need at least one honest client", cfg.Attack)
+	}
+
+	malicious := make([]bool, len(updates))
+	for _, i := range chosen {
+		malicious[i] = true
+	}
+	width := 0
+	for _, update := range updates {
+		width = max(width, len(update.Features))
+	}
+	honest := make([]detect.Sample, 0, len(updates)-len(chosen))
+	for i, update := range updates {
+		if !malicious[i] {
+			honest = append(honest, update)
+		}
+	}
+	mean := make([]float64, width)
+	for f := range mean {
+		mean[f], _ = featureMeanStdDev(honest, f)
+	}
+
+	var forge func(update []float64) []float64
+	switch cfg.Attack {
+	case AttackSignFlip:
+		forge = func(update []float64) []float64 { return scale(update, -1) }
+
+	case AttackScaled:
+		factor := cfg.Scale
+		if factor == 0 {
+			factor = 10
+		}
+		forge = func(update []float64) []float64 { return scale(update, factor) }
+
+	case AttackInnerProduct:
+		if cfg.Epsilon <= 0 {
+			return nil, fmt.Errorf("epsilon must be positive, got %v", cfg.Epsilon)
+		}
+		forged := scale(mean, -cfg.Epsilon)
+		forge = func([]float64) []float64 { return append([]float64(nil), forged...) }
+
+	case AttackModelReplacement:
+		// Averaged with the honest updates, n/m copies of the backdoor
+		// shift on top of the honest mean move the aggregate by the whole
+		// shift. Scale overrides the boost n/m.
+		trigger, err := TriggerFeatures(Config{TriggerFeatures: cfg.TriggerFeatures}, width)
+		if err != nil {
+			return nil, err
+		}
+		boost := cfg.Scale
+		if boost == 0 {
+			boost = float64(len(updates)) / float64(len(chosen))
+		}
+		forged := append([]float64(nil), mean...)
+		for _, f := range trigger {
+			_, stdDev := featureMeanStdDev(honest, f)
+			if stdDev == 0 {
+				stdDev = 1
+			}
+			forged[f] += boost * cfg.TriggerSigma * stdDev
+		}
+		forge = func([]float64) []float64 { return append([]float64(nil), forged...) }
+
+	default:
+		return nil, fmt.Errorf("unknown model update attack %q", cfg.Attack)
+	}
+
+	injections := make([]Injection, 0, len(chosen))
+	for _, i := range chosen {
+		update := &updates[i]
+		injections = append(injections, Injection{
+			Index:         i,
+			ID:            update.ID,
+			Attack:        cfg.Attack,
+			OriginalLabel: update.Label,
+			Label:         update.Label,
+		})
+		update.Features = forge(update.Features)
+	}
+
+	return injections, nil
+}
+
+// scale returns a copy of values multiplied by factor.
+func scale(values []float64, factor float64) []float64 {
+	scaled := make([]float64, len(values))
+	for i, v := range values {
+		scaled[i] = factor * v
+	}
+	return scaled
+}
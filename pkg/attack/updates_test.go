@@ -0,0 +1,169 @@
+package attack
+
+import (
+	"math"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func TestSimulateUpdates(t *testing.T) {
+	updates := SimulateUpdates(20, 5, 1)
+	if len(updates) != 20 || updates[3].ID != "client-3" || len(updates[3].Features) != 5 {
+		t.Fatalf("simulated %+v", updates)
+	}
+	again := SimulateUpdates(20, 5, 1)
+	for i := range updates {
+		for j, v := range updates[i].Features {
+			if again[i].Features[j] != v {
+				t.Fatal("the same seed simulated different updates")
+			}
+		}
+	}
+}
+
+func TestUpdateAttacks(t *testing.T) {
+	for _, test := range []struct {
+		cfg Config
+		// check checks a forged update against the original and the
+		// honest mean.
+		check func(t *testing.T, forged, original, mean []float64)
+	}{
+		{Config{Attack: AttackSignFlip}, func(t *testing.T, forged, original, _ []float64) {
+			for j := range forged {
+				if forged[j] != -original[j] {
+					t.Fatalf("forged %v from %v, want its negation", forged, original)
+				}
+			}
+		}},
+		{Config{Attack: AttackScaled}, func(t *testing.T, forged, original, _ []float64) {
+			for j := range forged {
+				if forged[j] != 10*original[j] {
+					t.Fatalf("forged %v from %v, want it scaled by the default 10", forged, original)
+				}
+			}
+		}},
+		{Config{Attack: AttackScaled, Scale: 3}, func(t *testing.T, forged, original, _ []float64) {
+			for j := range forged {
+				if forged[j] != 3*original[j] {
+					t.Fatalf("forged %v from %v, want it scaled by 3", forged, original)
+				}
+			}
+		}},
+		{Config{Attack: AttackInnerProduct, Epsilon: 0.5}, func(t *testing.T, forged, _, mean []float64) {
+			if p := dot(forged, mean); p >= 0 {
+				t.Errorf("forged update has inner product %v with the honest mean, want a negative one", p)
+			}
+			for j := range forged {
+				if math.Abs(forged[j]+0.5*mean[j]) > 1e-9 {
+					t.Fatalf("forged %v, want -0.5 times the honest mean %v", forged, mean)
+				}
+			}
+		}},
+	} {
+		t.Run(test.cfg.Attack, func(t *testing.T) {
+			cfg := test.cfg
+			cfg.Rate = 0.2
+			updates := SimulateUpdates(20, 6, 2)
+			original := make([][]float64, len(updates))
+			for i, update := range updates {
+				original[i] = update.Features
+			}
+			injections, err := Inject(updates, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(injections) != 4 {
+				t.Fatalf("%d injections, want 4", len(injections))
+			}
+			mean := honestMean(updates, injections)
+			for _, injection := range injections {
+				test.check(t, updates[injection.Index].Features, original[injection.Index], mean)
+			}
+		})
+	}
+}
+
+func TestModelReplacement(t *testing.T) {
+	cfg := Config{Attack: AttackModelReplacement, Rate: 0.2, TriggerFeatures: []int{1, 4}, TriggerSigma: 3}
+	updates := SimulateUpdates(20, 6, 3)
+	injections, err := Inject(updates, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	honest := append([]detect.Sample(nil), updates...)
+	for k := len(injections) - 1; k >= 0; k-- {
+		i := injections[k].Index
+		honest = append(honest[:i], honest[i+1:]...)
+	}
+
+	// Averaging all updates yields the honest mean, shifted by
+	// TriggerSigma standard deviations in the trigger features.
+	mean := honestMean(updates, injections)
+	aggregate := make([]float64, 6)
+	for _, update := range updates {
+		for j, v := range update.Features {
+			aggregate[j] += v / float64(len(updates))
+		}
+	}
+	for j := range aggregate {
+		want := mean[j]
+		if j == 1 || j == 4 {
+			_, stdDev := featureMeanStdDev(honest, j)
+			want += 3 * stdDev
+		}
+		if math.Abs(aggregate[j]-want) > 1e-9 {
+			t.Errorf("aggregate parameter %d = %v, want %v", j, aggregate[j], want)
+		}
+	}
+
+	if m := NewManifest(cfg, len(updates), 6, injections); m.Scale != 5 {
+		t.Errorf("manifest boost %v, want 20 clients over 4 malicious ones", m.Scale)
+	}
+}
+
+func TestUpdateAttackErrors(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"no honest client":    {Attack: AttackSignFlip, Rate: 1},
+		"zero epsilon":        {Attack: AttackInnerProduct, Rate: 0.2},
+		"bad trigger feature": {Attack: AttackModelReplacement, Rate: 0.2, TriggerFeatures: []int{6}},
+	} {
+		if _, err := Inject(SimulateUpdates(10, 6, 1), cfg); err == nil {
+			t.Errorf("%s: forged updates", name)
+		}
+	}
+}
+
+// honestMean returns the mean of the updates not injected.
+func honestMean(updates []detect.Sample, injections []Injection) []float64 {
+	malicious := make(map[int]bool)
+	for _, injection := range injections {
+		malicious[injection.Index] = true
+	}
+	var mean []float64
+	n := 0
+	for i, update := range updates {
+		if malicious[i] {
+			continue
+		}
+		if mean == nil {
+			mean = make([]float64, len(update.Features))
+		}
+		for j, v := range update.Features {
+			mean[j] += v
+		}
+		n++
+	}
+	for j := range mean {
+		mean[j] /= float64(n)
+	}
+	return mean
+}
+
+func dot(a, b []float64) float64 {
+	p := 0.0
+	for i := range a {
+		p += a[i] * b[i]
+	}
+	return p
+}
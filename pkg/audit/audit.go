@@ -0,0 +1,277 @@
+// Package audit keeps a tamper-evident log of the actions taken on
+// datasets and their review: scans, defenses, configuration changes,
+// allowlist edits and quarantine restores. The log is an append-only file
+// of JSON records, one per line, each holding the hash of the record
+// before it, so editing, removing or reordering records breaks the chain
+// that Read checks.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/allowlist"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Action is the kind of action a record describes.
+type Action string
+
+const (
+	ActionScan              Action = "scan"
+	ActionDefense           Action = "defense"
+	ActionConfigChange      Action = "config_change"
+	ActionAllowlistEdit     Action = "allowlist_edit"
+	ActionQuarantineRestore Action = "quarantine_restore"
+)
+
+// Actions lists the actions in the order they are documented.
+var Actions = []Action{ActionScan, ActionDefense, ActionConfigChange, ActionAllowlistEdit, ActionQuarantineRestore}
+
+// Genesis is the Prev of the first record of a log.
+var Genesis = strings.Repeat("0", sha256.Size*2)
+
+// Record is an entry of the log.
+type Record struct {
+	// Seq numbers the records of a log from 1.
+	Seq  int64     `json:"seq"`
+	Time time.Time `json:"time"`
+	// Action is what was done, by Actor, to Subject: a dataset, allowlist,
+	// configuration file or quarantine, in Project for server actions.
+	Action  Action            `json:"action"`
+	Actor   string            `json:"actor,omitempty"`
+	Project string            `json:"project,omitempty"`
+	Subject string            `json:"subject,omitempty"`
+	Details map[string]string `json:"details,omitempty"`
+	// Prev is the Hash of the record before, or Genesis, and Hash the
+	// SHA-256 of the record's JSON encoding with Hash empty.
+	Prev string `json:"prev"`
+	Hash string `json:"hash"`
+}
+
+// digest returns the hash of r.
+func (r Record) digest() string {
+	r.Hash = ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		// Records hold only strings, numbers and times.
+		panic(err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// maxIDs caps the sample IDs listed in the details of a record.
+const maxIDs = 50
+
+// IDs joins sample IDs for the details of a record, listing the first 50
+// and counting the rest.
+func IDs(ids []string) string {
+	if len(ids) <= maxIDs {
+		return strings.Join(ids, ",")
+	}
+	return fmt.Sprintf("%s,+%d more", strings.Join(ids[:maxIDs], ","), len(ids)-maxIDs)
+}
+
+// ScanDetails returns the details of a scan record for result: its counts,
+// risk score, flagged sample IDs and the recorded dataset version.
+func ScanDetails(result *detect.DetectionResult) map[string]string {
+	details := map[string]string{
+		"samples":    strconv.Itoa(result.SampleCount),
+		"flagged":    strconv.Itoa(result.PoisonedCount),
+		"risk_score": strconv.FormatFloat(result.RiskScore, 'f', 4, 64),
+	}
+	var flagged []string
+	for _, sample := range result.Samples {
+		if sample.IsPoisoned {
+			flagged = append(flagged, sample.ID)
+		}
+	}
+	if len(flagged) > 0 {
+		details["flagged_ids"] = IDs(flagged)
+	}
+	if v := result.Version; v != nil {
+		details["dataset_hash"] = v.Hash
+	}
+	return details
+}
+
+// DecisionDetails returns the details of an allowlist_edit record: the
+// number of decisions made and, under each decision, the samples given it.
+// Cleared decisions are listed under "cleared".
+func DecisionDetails(decisions map[string]allowlist.Decision) map[string]string {
+	ids := make(map[string][]string)
+	for id, decision := range decisions {
+		key := string(decision)
+		if key == "" {
+			key = "cleared"
+		}
+		ids[key] = append(ids[key], id)
+	}
+	details := map[string]string{"decisions": strconv.Itoa(len(decisions))}
+	for key, list := range ids {
+		sort.Strings(list)
+		details[key] = IDs(list)
+	}
+	return details
+}
+
+// ChainError reports where the chain of a log breaks.
+type ChainError struct {
+	// Line is the line of the log, from 1, and Seq the sequence number of
+	// the record expected there.
+	Line   int
+	Seq    int64
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit log broken at line %d (record %d): %s", e.Line, e.Seq, e.Reason)
+}
+
+// Read reads the records of a log and checks their chain. When the chain
+// breaks it returns the records before the break and a *ChainError.
+func Read(r io.Reader) ([]Record, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), math.MaxInt32)
+	var records []Record
+	prev := Genesis
+	for line := 1; scanner.Scan(); line++ {
+		seq := int64(len(records) + 1)
+		broken := func(format string, args ...interface{}) error {
+			return &ChainError{Line: line, Seq: seq, Reason: fmt.Sprintf(format, args...)}
+		}
+		var record Record
+		decoder := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&record); err != nil {
+			return records, broken("invalid record: %v", err)
+		}
+		switch {
+		case record.Seq != seq:
+			return records, broken("sequence number %d, want %d; records were removed or reordered", record.Seq, seq)
+		case record.Prev != prev:
+			return records, broken("previous hash %.12s does not match %.12s; records were removed or reordered", record.Prev, prev)
+		case record.Hash != record.digest():
+			return records, broken("hash mismatch; the record was modified")
+		}
+		records = append(records, record)
+		prev = record.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		return records, err
+	}
+	return records, nil
+}
+
+// Log is an audit log file. A Log is safe for concurrent use, but
+// processes appending to one file at the same time can fork its chain,
+// which Read reports; give each server or daemon its own log.
+type Log struct {
+	path string
+	mu   sync.Mutex
+}
+
+// Open opens the log at path, creating it if it does not exist.
+func Open(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return &Log{path: path}, nil
+}
+
+// Path returns the path of the log file.
+func (l *Log) Path() string { return l.path }
+
+// Append chains r to the log and writes it, setting its sequence number,
+// hashes and, when zero, its time. It returns the record written.
+func (l *Log) Append(r Record) (Record, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return Record{}, err
+	}
+	defer f.Close()
+
+	last, err := lastRecord(f)
+	if err != nil {
+		return Record{}, fmt.Errorf("%s: %w", l.path, err)
+	}
+	r.Seq, r.Prev = 1, Genesis
+	if last != nil {
+		r.Seq, r.Prev = last.Seq+1, last.Hash
+	}
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	r.Time = r.Time.UTC()
+	r.Hash = r.digest()
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return Record{}, err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return Record{}, err
+	}
+	return r, f.Sync()
+}
+
+// Records reads the records of the log and checks their chain, as Read.
+func (l *Log) Records() ([]Record, error) {
+	f, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+// lastRecord returns the last record of f, reading it backwards from the
+// end, or nil when f is empty.
+func lastRecord(f *os.File) (*Record, error) {
+	end, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	var tail []byte
+	chunk := make([]byte, 4096)
+	for pos := end; pos > 0; {
+		n := min(int64(len(chunk)), pos)
+		pos -= n
+		if _, err := f.ReadAt(chunk[:n], pos); err != nil {
+			return nil, err
+		}
+		tail = append(append([]byte(nil), chunk[:n]...), tail...)
+		if i := bytes.LastIndexByte(bytes.TrimRight(tail, "\n"), '\n'); i >= 0 {
+			tail = tail[i+1:]
+			break
+		}
+	}
+	tail = bytes.TrimSpace(tail)
+	if len(tail) == 0 {
+		return nil, nil
+	}
+	var record Record
+	if err := json.Unmarshal(tail, &record); err != nil {
+		return nil, errors.New("last record is not valid JSON; the log was truncated or edited")
+	}
+	return &record, nil
+}
@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	log, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, action := range Actions {
+		r, err := log.Append(Record{Action: action, Actor: "alice", Subject: "train.csv", Details: map[string]string{"n": strings.Repeat("x", i*3000)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Seq != int64(i+1) {
+			t.Errorf("Append %d: seq = %d", i, r.Seq)
+		}
+	}
+	records, err := log.Records()
+	if err != nil || len(records) != len(Actions) || records[0].Prev != Genesis {
+		t.Fatalf("Records() = %d records, %v", len(records), err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	tampered := map[string][]byte{
+		"modified":  bytes.Replace(data, []byte(`"actor":"alice"`), []byte(`"actor":"mallory"`), 1),
+		"removed":   bytes.Join(append(lines[:1:1], lines[2:]...), nil),
+		"reordered": bytes.Join(append([][]byte{lines[1], lines[0]}, lines[2:]...), nil),
+	}
+	for name, data := range tampered {
+		records, err := Read(bytes.NewReader(data))
+		var chain *ChainError
+		if !errors.As(err, &chain) {
+			t.Errorf("%s: Read error = %v, want *ChainError", name, err)
+			continue
+		}
+		if len(records) != chain.Line-1 {
+			t.Errorf("%s: %d records before the break at line %d", name, len(records), chain.Line)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Package auth authenticates API clients by bearer token or TLS client
+// certificate and authorizes them by scope.
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Scope grants access to a group of API operations.
+type Scope string
+
+// Scopes.
+const (
+	// ScopeRead allows listing and fetching datasets, scans, results and
+	// jobs.
+	ScopeRead Scope = "read"
+	// ScopeScan allows submitting datasets and scans and canceling jobs.
+	ScopeScan Scope = "scan"
+	// ScopeDefend allows applying defenses.
+	ScopeDefend Scope = "defend"
+	// ScopeDebug allows fetching runtime profiles from the profiling
+	// listener of serve --pprof-addr.
+	ScopeDebug Scope = "debug"
+)
+
+// Scopes returns all scopes.
+func Scopes() []Scope {
+	return []Scope{ScopeRead, ScopeScan, ScopeDefend, ScopeDebug}
+}
+
+// ErrUnauthenticated is returned when a request carries no valid
+// credentials.
+var ErrUnauthenticated = errors.New("authentication required")
+
+// Token is a bearer token accepted by the API.
+type Token struct {
+	// Name identifies the token holder in logs.
+	Name string `yaml:"name"`
+	// Token is the secret. A value of the form $NAME or ${NAME} is read
+	// from the environment.
+	Token  string  `yaml:"token"`
+	Scopes []Scope `yaml:"scopes"`
+	// Projects limits the token to these server projects; when empty it
+	// may access every project.
+	Projects []string `yaml:"projects,omitempty"`
+}
+
+// Client grants scopes to a TLS client certificate.
+type Client struct {
+	// CommonName is the subject common name of the certificate.
+	CommonName string   `yaml:"common_name"`
+	Scopes     []Scope  `yaml:"scopes"`
+	Projects   []string `yaml:"projects,omitempty"`
+}
+
+// Config lists the accepted credentials.
+type Config struct {
+	Tokens []Token `yaml:"tokens,omitempty"`
+	// Clients grants scopes to verified client certificates. When empty,
+	// every verified certificate is granted all scopes.
+	Clients []Client `yaml:"clients,omitempty"`
+}
+
+// Enabled reports whether any credentials are configured.
+func (c Config) Enabled() bool {
+	return len(c.Tokens) > 0 || len(c.Clients) > 0
+}
+
+// Check reports errors in the configuration.
+func (c Config) Check() error {
+	names := make(map[string]bool)
+	for _, token := range c.Tokens {
+		if token.Name == "" {
+			return errors.New("token without a name")
+		}
+		if names[token.Name] {
+			return fmt.Errorf("duplicate token %q", token.Name)
+		}
+		names[token.Name] = true
+		if token.secret() == "" {
+			return fmt.Errorf("token %q is empty", token.Name)
+		}
+		if err := checkScopes(token.Scopes); err != nil {
+			return fmt.Errorf("token %q: %w", token.Name, err)
+		}
+	}
+	for _, client := range c.Clients {
+		if client.CommonName == "" {
+			return errors.New("client without a common_name")
+		}
+		if err := checkScopes(client.Scopes); err != nil {
+			return fmt.Errorf("client %q: %w", client.CommonName, err)
+		}
+	}
+	return nil
+}
+
+func checkScopes(scopes []Scope) error {
+	if len(scopes) == 0 {
+		return errors.New("no scopes")
+	}
+	for _, scope := range scopes {
+		switch scope {
+		case ScopeRead, ScopeScan, ScopeDefend, ScopeDebug:
+		default:
+			return fmt.Errorf("unknown scope %q (want read, scan, defend or debug)", scope)
+		}
+	}
+	return nil
+}
+
+// secret returns the token value, expanding environment references.
+func (t Token) secret() string {
+	if strings.HasPrefix(t.Token, "$") {
+		return os.ExpandEnv(t.Token)
+	}
+	return t.Token
+}
+
+// Identity is an authenticated client.
+type Identity struct {
+	Name   string
+	Scopes []Scope
+	// Projects lists the projects the client may access; empty means all.
+	Projects []string
+}
+
+// Allows reports whether the identity was granted scope.
+func (id Identity) Allows(scope Scope) bool {
+	for _, s := range id.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// InProject reports whether the identity may access project.
+func (id Identity) InProject(project string) bool {
+	if len(id.Projects) == 0 {
+		return true
+	}
+	for _, p := range id.Projects {
+		if p == project {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator checks client credentials against a configuration.
+type Authenticator struct {
+	tokens  []Token
+	secrets [][]byte
+	clients map[string]Client
+}
+
+// New creates an authenticator. The configuration should have passed
+// Check.
+func New(cfg Config) *Authenticator {
+	a := &Authenticator{tokens: cfg.Tokens, clients: make(map[string]Client)}
+	for _, token := range cfg.Tokens {
+		a.secrets = append(a.secrets, []byte(token.secret()))
+	}
+	for _, client := range cfg.Clients {
+		a.clients[client.CommonName] = client
+	}
+	return a
+}
+
+// Authenticate identifies a client by the value of its Authorization
+// header or, when it sends none, by its verified TLS client certificate.
+func (a *Authenticator) Authenticate(authorization string, state *tls.ConnectionState) (Identity, error) {
+	if authorization != "" {
+		token, ok := ParseBearer(authorization)
+		if !ok {
+			return Identity{}, fmt.Errorf("%w: unsupported authorization scheme", ErrUnauthenticated)
+		}
+		// Compare against every token so timing does not reveal which
+		// one matched.
+		match := -1
+		for i, secret := range a.secrets {
+			if subtle.ConstantTimeCompare([]byte(token), secret) == 1 {
+				match = i
+			}
+		}
+		if match < 0 {
+			return Identity{}, fmt.Errorf("%w: invalid token", ErrUnauthenticated)
+		}
+		matched := a.tokens[match]
+		return Identity{Name: matched.Name, Scopes: matched.Scopes, Projects: matched.Projects}, nil
+	}
+
+	if state != nil && len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+		name := state.VerifiedChains[0][0].Subject.CommonName
+		if len(a.clients) == 0 {
+			return Identity{Name: name, Scopes: Scopes()}, nil
+		}
+		if client, ok := a.clients[name]; ok {
+			return Identity{Name: name, Scopes: client.Scopes, Projects: client.Projects}, nil
+		}
+		return Identity{}, fmt.Errorf("%w: client certificate %q not allowed", ErrUnauthenticated, name)
+	}
+
+	return Identity{}, ErrUnauthenticated
+}
+
+// ParseBearer extracts the token from an Authorization header value.
+func ParseBearer(authorization string) (string, bool) {
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+type identityKey struct{}
+
+// NewContext returns a context carrying id.
+func NewContext(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity carried by ctx, if any.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// ServerTLS loads a server certificate. When clientCAFile is set, clients
+// must present a certificate signed by one of its CAs.
+func ServerTLS(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", clientCAFile)
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return cfg, nil
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+)
+
+func TestAuthenticateTokens(t *testing.T) {
+	t.Setenv("CI_TOKEN", "s3cret")
+	cfg := Config{Tokens: []Token{
+		{Name: "ci", Token: "$CI_TOKEN", Scopes: []Scope{ScopeScan, ScopeRead}},
+		{Name: "dashboard", Token: "view", Scopes: []Scope{ScopeRead}},
+	}}
+	if err := cfg.Check(); err != nil {
+		t.Fatal(err)
+	}
+	a := New(cfg)
+
+	id, err := a.Authenticate("Bearer s3cret", nil)
+	if err != nil || id.Name != "ci" || !id.Allows(ScopeScan) || id.Allows(ScopeDefend) {
+		t.Errorf("Authenticate(ci) = %+v, %v", id, err)
+	}
+	if id, err := a.Authenticate("bearer view", nil); err != nil || id.Allows(ScopeScan) {
+		t.Errorf("Authenticate(dashboard) = %+v, %v", id, err)
+	}
+	for _, header := range []string{"", "Bearer wrong", "Basic s3cret", "$CI_TOKEN"} {
+		if _, err := a.Authenticate(header, nil); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("Authenticate(%q) error = %v, want ErrUnauthenticated", header, err)
+		}
+	}
+}
+
+func TestAuthenticateCertificates(t *testing.T) {
+	state := func(cn string) *tls.ConnectionState {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+
+	if id, err := New(Config{}).Authenticate("", state("any")); err != nil || !id.Allows(ScopeDefend) {
+		t.Errorf("without clients, verified certificate = %+v, %v; want all scopes", id, err)
+	}
+
+	a := New(Config{Clients: []Client{{CommonName: "scanner", Scopes: []Scope{ScopeScan}}}})
+	if id, err := a.Authenticate("", state("scanner")); err != nil || !id.Allows(ScopeScan) || id.Allows(ScopeRead) {
+		t.Errorf("Authenticate(scanner) = %+v, %v", id, err)
+	}
+	if _, err := a.Authenticate("", state("intruder")); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("unlisted certificate error = %v, want ErrUnauthenticated", err)
+	}
+}
+
+func TestCheckRejectsBadScopes(t *testing.T) {
+	cfg := Config{Tokens: []Token{{Name: "ci", Token: "x", Scopes: []Scope{"admin"}}}}
+	if err := cfg.Check(); err == nil {
+		t.Error("Check accepted an unknown scope")
+	}
+}
@@ -0,0 +1,188 @@
+// Package benchmark measures detectors and defenses against datasets with
+// known poisoned samples.
+package benchmark
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/attack"
+	"github.com/hallucinaut/modelpoison/pkg/defend"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/synthetic"
+)
+
+// Method kinds.
+const (
+	KindDetector = "detector"
+	KindDefense  = "defense"
+)
+
+// Case is a dataset with ground truth.
+type Case struct {
+	Name     string
+	Samples  []detect.Sample
+	Poisoned []bool
+}
+
+// Row holds the measurements of one method on one case.
+type Row struct {
+	Dataset           string        `json:"dataset"`
+	Method            string        `json:"method"`
+	Kind              string        `json:"kind"`
+	Samples           int           `json:"samples"`
+	Poisoned          int           `json:"poisoned"`
+	TruePositives     int           `json:"true_positives"`
+	FalsePositives    int           `json:"false_positives"`
+	DetectionRate     float64       `json:"detection_rate"`
+	FalsePositiveRate float64       `json:"false_positive_rate"`
+	Duration          time.Duration `json:"duration_ns"`
+	Throughput        float64       `json:"throughput"`
+}
+
+// SyntheticCases returns a clean Gaussian mixture of three classes and
+// copies poisoned with each built-in attack at rate.
+func SyntheticCases(n, features int, rate float64, seed int64) ([]Case, error) {
+	cfg := synthetic.DefaultConfig()
+	cfg.Samples, cfg.Features, cfg.Seed = n, features, seed
+	data, err := synthetic.Generate(cfg)
+	if err != nil {
+		return nil, err
+	}
+	base := data.Samples
+
+	cases := []Case{{Name: "synthetic/clean", Samples: base, Poisoned: data.Poisoned}}
+
+	for _, name := range []string{attack.AttackBackdoor, attack.AttackLabelFlip} {
+		samples := make([]detect.Sample, len(base))
+		copy(samples, base)
+
+		cfg := attack.DefaultConfig()
+		cfg.Attack = name
+		cfg.Rate = rate
+		cfg.Seed = seed
+		if name == attack.AttackLabelFlip {
+			cfg.TargetClass = -1
+		}
+
+		injections, err := attack.Inject(samples, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		poisoned := make([]bool, n)
+		for _, injection := range injections {
+			poisoned[injection.Index] = true
+		}
+		cases = append(cases, Case{Name: "synthetic/" + name, Samples: samples, Poisoned: poisoned})
+	}
+
+	return cases, nil
+}
+
+// Run benchmarks each built-in check, the ensemble detector and each
+// filtering defense on every case. Rows are ordered by case, then method.
+func Run(cases []Case, detector *detect.Detector, defender *defend.Defender, strategies []string) ([]Row, error) {
+	thresholds := detector.Thresholds()
+	var rows []Row
+
+	for _, c := range cases {
+		if len(c.Poisoned) != len(c.Samples) {
+			return nil, fmt.Errorf("%s: ground truth covers %d of %d samples", c.Name, len(c.Poisoned), len(c.Samples))
+		}
+		if err := detector.Validate(c.Samples); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Name, err)
+		}
+
+		for _, t := range detect.Checks() {
+			flagged := make([]bool, len(c.Samples))
+			start := time.Now()
+			for i, sample := range c.Samples {
+				score, err := detector.ScoreCheck(t, sample)
+				if err != nil {
+					return nil, err
+				}
+				flagged[i] = score > thresholds[t]
+			}
+			rows = append(rows, measure(c, string(t), KindDetector, flagged, time.Since(start)))
+		}
+
+		start := time.Now()
+		result := detector.Detect(c.Samples)
+		elapsed := time.Since(start)
+		flagged := make([]bool, len(c.Samples))
+		for i, sample := range result.Samples {
+			flagged[i] = sample.IsPoisoned
+		}
+		rows = append(rows, measure(c, "ensemble", KindDetector, flagged, elapsed))
+
+		for _, strategy := range strategies {
+			start := time.Now()
+			removed := defender.RemovedIndices(c.Samples, strategy)
+			elapsed := time.Since(start)
+
+			flagged := make([]bool, len(c.Samples))
+			for _, i := range removed {
+				flagged[i] = true
+			}
+			rows = append(rows, measure(c, strategy, KindDefense, flagged, elapsed))
+		}
+	}
+
+	return rows, nil
+}
+
+// measure computes detection metrics for flagged samples.
+func measure(c Case, method, kind string, flagged []bool, elapsed time.Duration) Row {
+	row := Row{
+		Dataset:  c.Name,
+		Method:   method,
+		Kind:     kind,
+		Samples:  len(c.Samples),
+		Duration: elapsed,
+	}
+
+	for i, f := range flagged {
+		if c.Poisoned[i] {
+			row.Poisoned++
+		}
+		switch {
+		case f && c.Poisoned[i]:
+			row.TruePositives++
+		case f:
+			row.FalsePositives++
+		}
+	}
+
+	if row.Poisoned > 0 {
+		row.DetectionRate = float64(row.TruePositives) / float64(row.Poisoned)
+	}
+	if clean := row.Samples - row.Poisoned; clean > 0 {
+		row.FalsePositiveRate = float64(row.FalsePositives) / float64(clean)
+	}
+	if elapsed > 0 {
+		row.Throughput = float64(row.Samples) / elapsed.Seconds()
+	}
+
+	return row
+}
+
+// GenerateReport generates a benchmark report table.
+func GenerateReport(rows []Row) string {
+	var report string
+
+	report += "=== Detector and Defense Benchmark ===\n\n"
+	report += fmt.Sprintf("%-24s %-20s %-8s %8s %8s %8s %9s %8s %14s\n",
+		"Dataset", "Method", "Kind", "Samples", "Poisoned", "Detected", "Det.Rate", "FP.Rate", "Samples/s")
+	for _, row := range rows {
+		detectionRate := "-"
+		if row.Poisoned > 0 {
+			detectionRate = fmt.Sprintf("%.1f%%", row.DetectionRate*100)
+		}
+		report += fmt.Sprintf("%-24s %-20s %-8s %8d %8d %8d %9s %7.1f%% %14.0f\n",
+			row.Dataset, row.Method, row.Kind, row.Samples, row.Poisoned, row.TruePositives,
+			detectionRate, row.FalsePositiveRate*100, row.Throughput)
+	}
+
+	return report
+}
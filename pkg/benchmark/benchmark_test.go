@@ -0,0 +1,106 @@
+package benchmark
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/defend"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func TestRun(t *testing.T) {
+	cases, err := SyntheticCases(120, 4, 0.1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range cases {
+		names = append(names, c.Name)
+	}
+	if strings.Join(names, ", ") != "synthetic/clean, synthetic/backdoor, synthetic/label_flip" {
+		t.Fatalf("cases %v", names)
+	}
+
+	defender := defend.NewDefender()
+	var strategies []string
+	for _, strategy := range defender.Strategies() {
+		if strategy.FiltersSamples() {
+			strategies = append(strategies, strategy.Name)
+		}
+	}
+	rows, err := Run(cases, detect.NewDetector(), defender, strategies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var methods []string
+	for _, check := range detect.Checks() {
+		methods = append(methods, string(check))
+	}
+	methods = append(methods, "ensemble")
+	defenses := len(methods)
+	methods = append(methods, strategies...)
+	perCase := len(methods)
+	if len(rows) != len(cases)*perCase {
+		t.Fatalf("%d rows, want %d for each of %d cases", len(rows), perCase, len(cases))
+	}
+
+	for i, row := range rows {
+		c, k := cases[i/perCase], i%perCase
+		kind := KindDetector
+		if k >= defenses {
+			kind = KindDefense
+		}
+		if row.Dataset != c.Name || row.Method != methods[k] || row.Kind != kind || row.Samples != 120 {
+			t.Errorf("row %d = %+v, want %s %s on %s", i, row, kind, methods[k], c.Name)
+		}
+
+		poisoned := 0
+		for _, p := range c.Poisoned {
+			if p {
+				poisoned++
+			}
+		}
+		if row.Poisoned != poisoned || row.TruePositives > row.Poisoned || row.FalsePositives > row.Samples-row.Poisoned {
+			t.Errorf("row %d: %d true and %d false positives of %d poisoned samples", i, row.TruePositives, row.FalsePositives, row.Poisoned)
+		}
+		if row.DetectionRate < 0 || row.DetectionRate > 1 || row.FalsePositiveRate < 0 || row.FalsePositiveRate > 1 {
+			t.Errorf("row %d: detection rate %v, false positive rate %v", i, row.DetectionRate, row.FalsePositiveRate)
+		}
+		if row.Duration < 0 || row.Throughput < 0 {
+			t.Errorf("row %d: duration %v, throughput %v", i, row.Duration, row.Throughput)
+		}
+	}
+	if rows[0].Poisoned != 0 || rows[perCase].Poisoned != 12 {
+		t.Errorf("%d poisoned clean samples and %d backdoored, want 0 and 12", rows[0].Poisoned, rows[perCase].Poisoned)
+	}
+
+	report := GenerateReport(rows)
+	if lines := strings.Split(strings.TrimSpace(report), "\n"); len(lines) != len(rows)+3 {
+		t.Errorf("report has %d lines, want a title, a blank line, a header and %d rows:\n%s", len(lines), len(rows), report)
+	}
+	if !strings.Contains(report, "synthetic/clean          ensemble             detector      120        0") {
+		t.Errorf("report lacks the clean ensemble row:\n%s", report)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	samples := []detect.Sample{{ID: "a", Features: []float64{1}}, {ID: "b", Features: []float64{2}}}
+	_, err := Run([]Case{{Name: "short", Samples: samples, Poisoned: []bool{true}}}, detect.NewDetector(), defend.NewDefender(), nil)
+	if err == nil || err.Error() != "short: ground truth covers 1 of 2 samples" {
+		t.Errorf("running with partial ground truth: %v", err)
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	c := Case{Name: "hand", Samples: make([]detect.Sample, 5), Poisoned: []bool{true, true, false, false, false}}
+	row := measure(c, "check", KindDetector, []bool{true, false, true, false, false}, 2*time.Second)
+	want := Row{Dataset: "hand", Method: "check", Kind: KindDetector, Samples: 5, Poisoned: 2,
+		TruePositives: 1, FalsePositives: 1, DetectionRate: 0.5, FalsePositiveRate: 1.0 / 3, Duration: 2 * time.Second, Throughput: 2.5}
+	if row != want {
+		t.Errorf("measured %+v, want %+v", row, want)
+	}
+	if row := measure(Case{Samples: make([]detect.Sample, 2), Poisoned: make([]bool, 2)}, "check", KindDetector, []bool{true, false}, 0); row.DetectionRate != 0 || row.FalsePositiveRate != 0.5 || row.Throughput != 0 {
+		t.Errorf("measured clean case %+v", row)
+	}
+}
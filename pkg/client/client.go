@@ -0,0 +1,538 @@
+// Package client is a Go client for the modelpoison HTTP API served by
+// "modelpoison serve".
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/allowlist"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/jobs"
+	"github.com/hallucinaut/modelpoison/pkg/server"
+	"github.com/hallucinaut/modelpoison/pkg/store"
+)
+
+// DefaultPollInterval is how often WaitJob polls a job that sets no
+// interval.
+const DefaultPollInterval = time.Second
+
+// Client calls the API of one server, optionally scoped to a project. It
+// is safe for concurrent use.
+type Client struct {
+	base    string
+	http    *http.Client
+	token   string
+	project string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests, for example to
+// configure TLS client certificates. It defaults to http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithToken authenticates requests with a bearer token.
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithProject scopes requests to a server project.
+func WithProject(name string) Option {
+	return func(c *Client) { c.project = name }
+}
+
+// New creates a client for the server at baseURL, such as
+// "https://modelpoison.internal:8080".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid server URL %q", baseURL)
+	}
+
+	c := &Client{base: strings.TrimSuffix(baseURL, "/"), http: http.DefaultClient}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Project returns a copy of the client scoped to the named project.
+func (c *Client) Project(name string) *Client {
+	scoped := *c
+	scoped.project = name
+	return &scoped
+}
+
+// Error is an error response from the server.
+type Error struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is set when the server asked the client to retry later,
+	// as on rate limiting or a full queue.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("modelpoison: %s (%d)", e.Message, e.StatusCode)
+}
+
+// IsNotFound reports whether err is a 404 response.
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Submission is the response to a scan request. Small scans complete
+// immediately and set Scan; large or async scans are queued and set Job.
+type Submission struct {
+	Scan *server.Scan
+	Job  *jobs.Job
+}
+
+// UploadDataset uploads a CSV dataset.
+func (c *Client) UploadDataset(ctx context.Context, csv io.Reader) (*server.DatasetInfo, error) {
+	var info server.DatasetInfo
+	err := c.do(ctx, http.MethodPost, "/datasets", "text/csv", csv, &info, http.StatusCreated)
+	return &info, err
+}
+
+// UploadDatasetFile uploads a CSV dataset file.
+func (c *Client) UploadDatasetFile(ctx context.Context, path string) (*server.DatasetInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return c.UploadDataset(ctx, f)
+}
+
+// SubmitDatasetURI asks the server to fetch a dataset by URI.
+func (c *Client) SubmitDatasetURI(ctx context.Context, uri string) (*server.DatasetInfo, error) {
+	var info server.DatasetInfo
+	err := c.doJSON(ctx, http.MethodPost, "/datasets", server.SubmitDatasetRequest{URI: uri}, &info, http.StatusCreated)
+	return &info, err
+}
+
+// Dataset describes a submitted dataset.
+func (c *Client) Dataset(ctx context.Context, id string) (*server.DatasetInfo, error) {
+	var info server.DatasetInfo
+	err := c.doJSON(ctx, http.MethodGet, "/datasets/"+url.PathEscape(id), nil, &info, http.StatusOK)
+	return &info, err
+}
+
+// Datasets lists submitted datasets.
+func (c *Client) Datasets(ctx context.Context) ([]server.DatasetInfo, error) {
+	var infos []server.DatasetInfo
+	err := c.doJSON(ctx, http.MethodGet, "/datasets", nil, &infos, http.StatusOK)
+	return infos, err
+}
+
+// Scan scans a submitted dataset. The server decides whether to run the
+// scan immediately or as a job; use ScanAndWait to always get the scan.
+func (c *Client) Scan(ctx context.Context, req server.ScanRequest) (*Submission, error) {
+	resp, err := c.send(ctx, http.MethodPost, "/scans", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusCreated:
+		var scan server.Scan
+		if err := json.NewDecoder(resp.Body).Decode(&scan); err != nil {
+			return nil, fmt.Errorf("decode scan: %w", err)
+		}
+		return &Submission{Scan: &scan}, nil
+	case http.StatusAccepted:
+		var job jobs.Job
+		if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+			return nil, fmt.Errorf("decode job: %w", err)
+		}
+		return &Submission{Job: &job}, nil
+	}
+	return nil, responseError(resp)
+}
+
+// ScanAndWait scans a submitted dataset and, if the scan was queued as a
+// job, waits for it to finish.
+func (c *Client) ScanAndWait(ctx context.Context, req server.ScanRequest) (*server.Scan, error) {
+	sub, err := c.Scan(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if sub.Scan != nil {
+		return sub.Scan, nil
+	}
+
+	job, err := c.WaitJob(ctx, sub.Job.ID, 0)
+	if err != nil {
+		return nil, err
+	}
+	if job.State != jobs.StateSucceeded {
+		return nil, fmt.Errorf("job %s %s: %s", job.ID, job.State, job.Error)
+	}
+	return c.GetScan(ctx, job.ID)
+}
+
+// GetScan fetches a completed scan and its result. Scans run as jobs are
+// available under the job ID once the job succeeded.
+func (c *Client) GetScan(ctx context.Context, id string) (*server.Scan, error) {
+	var scan server.Scan
+	err := c.doJSON(ctx, http.MethodGet, "/scans/"+url.PathEscape(id), nil, &scan, http.StatusOK)
+	return &scan, err
+}
+
+// Scans lists completed scans.
+func (c *Client) Scans(ctx context.Context) ([]server.Scan, error) {
+	var scans []server.Scan
+	err := c.doJSON(ctx, http.MethodGet, "/scans", nil, &scans, http.StatusOK)
+	return scans, err
+}
+
+// Report renders the result of a scan as text, html or json.
+func (c *Client) Report(ctx context.Context, id, format string) ([]byte, error) {
+	path := "/scans/" + url.PathEscape(id) + "/report?format=" + url.QueryEscape(format)
+	var buf bytes.Buffer
+	err := c.do(ctx, http.MethodGet, path, "", nil, &buf, http.StatusOK)
+	return buf.Bytes(), err
+}
+
+// Policy evaluates the server's Rego policies against a scan.
+func (c *Client) Policy(ctx context.Context, scanID string) (*server.PolicyDecision, error) {
+	var decision server.PolicyDecision
+	err := c.doJSON(ctx, http.MethodGet, "/scans/"+url.PathEscape(scanID)+"/policy", nil, &decision, http.StatusOK)
+	return &decision, err
+}
+
+// SubmitJob queues a scan as a job regardless of the dataset size.
+func (c *Client) SubmitJob(ctx context.Context, req server.ScanRequest) (*jobs.Job, error) {
+	var job jobs.Job
+	err := c.doJSON(ctx, http.MethodPost, "/jobs", req, &job, http.StatusAccepted)
+	return &job, err
+}
+
+// Job fetches a job and its progress.
+func (c *Client) Job(ctx context.Context, id string) (*jobs.Job, error) {
+	var job jobs.Job
+	err := c.doJSON(ctx, http.MethodGet, "/jobs/"+url.PathEscape(id), nil, &job, http.StatusOK)
+	return &job, err
+}
+
+// Jobs lists jobs, optionally only those in state.
+func (c *Client) Jobs(ctx context.Context, state jobs.State) ([]jobs.Job, error) {
+	path := "/jobs"
+	if state != "" {
+		path += "?state=" + url.QueryEscape(string(state))
+	}
+	var list []jobs.Job
+	err := c.doJSON(ctx, http.MethodGet, path, nil, &list, http.StatusOK)
+	return list, err
+}
+
+// CancelJob cancels a queued or running job.
+func (c *Client) CancelJob(ctx context.Context, id string) (*jobs.Job, error) {
+	var job jobs.Job
+	err := c.doJSON(ctx, http.MethodDelete, "/jobs/"+url.PathEscape(id), nil, &job, http.StatusOK)
+	return &job, err
+}
+
+// Progress fetches the progress of a job and the samples flagged so far.
+func (c *Client) Progress(ctx context.Context, id string) (*server.JobProgress, error) {
+	var progress server.JobProgress
+	err := c.doJSON(ctx, http.MethodGet, "/jobs/"+url.PathEscape(id)+"/progress", nil, &progress, http.StatusOK)
+	return &progress, err
+}
+
+// WaitJob polls a job every interval (DefaultPollInterval if 0) until it
+// finishes or ctx is done, and returns the finished job.
+func (c *Client) WaitJob(ctx context.Context, id string, interval time.Duration) (*jobs.Job, error) {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		job, err := c.Job(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if job.State.Done() {
+			return job, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// StreamFindings calls fn with each sample flagged by a job as it is
+// found, skipping the first offset findings, until the job finishes. It
+// stops early if fn returns an error, and returns that error. Findings the
+// server evicted from the job's buffer before they were streamed are
+// skipped too.
+func (c *Client) StreamFindings(ctx context.Context, id string, offset int, fn func(detect.PoisonedSample) error) error {
+	path := "/jobs/" + url.PathEscape(id) + "/findings?offset=" + strconv.Itoa(offset)
+	resp, err := c.send(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		var line struct {
+			detect.PoisonedSample
+			Skipped int `json:"skipped"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			return fmt.Errorf("decode finding: %w", err)
+		}
+		if line.Skipped > 0 {
+			continue
+		}
+		finding := line.PoisonedSample
+		if err := fn(finding); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// JobResult fetches the detection result of a succeeded job.
+func (c *Client) JobResult(ctx context.Context, id string) (*detect.DetectionResult, error) {
+	var result detect.DetectionResult
+	err := c.doJSON(ctx, http.MethodGet, "/jobs/"+url.PathEscape(id)+"/result", nil, &result, http.StatusOK)
+	return &result, err
+}
+
+// Defend applies a filtering defense to a submitted dataset.
+func (c *Client) Defend(ctx context.Context, req server.DefenseRequest) (*server.Defense, error) {
+	var defense server.Defense
+	err := c.doJSON(ctx, http.MethodPost, "/defenses", req, &defense, http.StatusCreated)
+	return &defense, err
+}
+
+// Defense fetches an applied defense.
+func (c *Client) Defense(ctx context.Context, id string) (*server.Defense, error) {
+	var defense server.Defense
+	err := c.doJSON(ctx, http.MethodGet, "/defenses/"+url.PathEscape(id), nil, &defense, http.StatusOK)
+	return &defense, err
+}
+
+// CleanedDataset writes the dataset cleaned by a defense to w as CSV.
+func (c *Client) CleanedDataset(ctx context.Context, id string, w io.Writer) error {
+	return c.do(ctx, http.MethodGet, "/defenses/"+url.PathEscape(id)+"/dataset", "", nil, w, http.StatusOK)
+}
+
+// Runs queries the scans and defenses recorded by a server with a store.
+// The query's project is ignored in favor of the client's.
+func (c *Client) Runs(ctx context.Context, q store.Query) ([]store.Run, error) {
+	values := url.Values{}
+	set := func(name, value string) {
+		if value != "" {
+			values.Set(name, value)
+		}
+	}
+	set("kind", string(q.Kind))
+	set("dataset_id", q.DatasetID)
+	if !q.Since.IsZero() {
+		set("since", q.Since.Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		set("until", q.Until.Format(time.RFC3339))
+	}
+	if q.MinRisk > 0 {
+		set("min_risk", strconv.FormatFloat(q.MinRisk, 'g', -1, 64))
+	}
+	if q.Poisoned != nil {
+		set("poisoned", strconv.FormatBool(*q.Poisoned))
+	}
+	if q.Limit > 0 {
+		set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Offset > 0 {
+		set("offset", strconv.Itoa(q.Offset))
+	}
+
+	path := "/runs"
+	if len(values) > 0 {
+		path += "?" + values.Encode()
+	}
+	var runs []store.Run
+	err := c.doJSON(ctx, http.MethodGet, path, nil, &runs, http.StatusOK)
+	return runs, err
+}
+
+// Run fetches a recorded scan or defense and its result.
+func (c *Client) Run(ctx context.Context, id string) (*store.Run, error) {
+	var run store.Run
+	err := c.doJSON(ctx, http.MethodGet, "/runs/"+url.PathEscape(id), nil, &run, http.StatusOK)
+	return &run, err
+}
+
+// Decisions lists the review decisions of the project.
+func (c *Client) Decisions(ctx context.Context) ([]allowlist.Entry, error) {
+	var entries []allowlist.Entry
+	err := c.doJSON(ctx, http.MethodGet, "/allowlist", nil, &entries, http.StatusOK)
+	return entries, err
+}
+
+// SetDecision records a review decision, merged into later scans.
+func (c *Client) SetDecision(ctx context.Context, entry allowlist.Entry) (*allowlist.Entry, error) {
+	var recorded allowlist.Entry
+	err := c.doJSON(ctx, http.MethodPost, "/allowlist", entry, &recorded, http.StatusCreated)
+	return &recorded, err
+}
+
+// RemoveDecision removes the review decision for a sample.
+func (c *Client) RemoveDecision(ctx context.Context, id string) error {
+	return c.doJSON(ctx, http.MethodDelete, "/allowlist/"+url.PathEscape(id), nil, nil, http.StatusOK)
+}
+
+// Projects lists the projects the client may access. It ignores the
+// client's project.
+func (c *Client) Projects(ctx context.Context) ([]server.ProjectInfo, error) {
+	var infos []server.ProjectInfo
+	err := c.Project("").doJSON(ctx, http.MethodGet, "/projects", nil, &infos, http.StatusOK)
+	return infos, err
+}
+
+// Ready reports whether the server's readiness probe succeeds.
+func (c *Client) Ready(ctx context.Context) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.base+"/readyz", nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+// doJSON sends body, if not nil, as JSON and decodes the response into
+// out, if not nil.
+func (c *Client) doJSON(ctx context.Context, method, path string, body, out interface{}, want int) error {
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != want {
+		return responseError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s %s response: %w", method, path, err)
+	}
+	return nil
+}
+
+// do sends a raw body and copies the response body to out, or decodes it
+// into out when out is not a writer.
+func (c *Client) do(ctx context.Context, method, path, contentType string, body io.Reader, out interface{}, want int) error {
+	req, err := c.request(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != want {
+		return responseError(resp)
+	}
+
+	if w, ok := out.(io.Writer); ok {
+		_, err = io.Copy(w, resp.Body)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s %s response: %w", method, path, err)
+	}
+	return nil
+}
+
+// send sends body, if not nil, as JSON.
+func (c *Client) send(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := c.request(ctx, method, path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.http.Do(req)
+}
+
+// request builds a request for path under the client's project.
+func (c *Client) request(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	prefix := "/v1"
+	if c.project != "" {
+		prefix += "/projects/" + url.PathEscape(c.project)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+prefix+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return req, nil
+}
+
+// responseError builds an Error from an unexpected response.
+func responseError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err == nil && body.Error != "" {
+		apiErr.Message = body.Error
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/jobs"
+	"github.com/hallucinaut/modelpoison/pkg/server"
+)
+
+// testCSV returns a dataset with one outlier in every ten rows.
+func testCSV() string {
+	var b strings.Builder
+	b.WriteString("id,f0,f1,label\n")
+	for i := 0; i < 100; i++ {
+		f0 := float64(i%7) / 10
+		if i%10 == 0 {
+			f0 = 500
+		}
+		fmt.Fprintf(&b, "s%d,%g,%g,%d\n", i, f0, float64(i%5)/10, i%2)
+	}
+	return b.String()
+}
+
+// outlierCSV returns a dataset of 20 features with an outlier feature in
+// every fifth row, which the detector flags.
+func outlierCSV() string {
+	rng := rand.New(rand.NewSource(1))
+	var b strings.Builder
+	b.WriteString("id")
+	for f := 0; f < 20; f++ {
+		fmt.Fprintf(&b, ",f%d", f)
+	}
+	b.WriteString(",label\n")
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&b, "s%d", i)
+		for f := 0; f < 20; f++ {
+			v := rng.NormFloat64()
+			if f == 3 && i%5 == 0 {
+				v = 500
+			}
+			fmt.Fprintf(&b, ",%g", v)
+		}
+		fmt.Fprintf(&b, ",%d\n", i%2)
+	}
+	return b.String()
+}
+
+func TestClient(t *testing.T) {
+	srv := server.New(server.Options{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))})
+	defer srv.Close()
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	c, err := New(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	dataset, err := c.UploadDataset(ctx, strings.NewReader(testCSV()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub, err := c.Scan(ctx, server.ScanRequest{DatasetID: dataset.ID})
+	if err != nil || sub.Scan == nil {
+		t.Fatalf("Scan = %+v, %v; want a completed scan", sub, err)
+	}
+	want := sub.Scan.Result.PoisonedCount
+
+	job, err := c.SubmitJob(ctx, server.ScanRequest{DatasetID: dataset.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job, err = c.WaitJob(ctx, job.ID, 10*time.Millisecond); err != nil || job.State != jobs.StateSucceeded {
+		t.Fatalf("WaitJob = %+v, %v", job, err)
+	}
+	var streamed int
+	if err := c.StreamFindings(ctx, job.ID, 0, func(detect.PoisonedSample) error { streamed++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	scan, err := c.GetScan(ctx, job.ID)
+	if err != nil || scan.Result.PoisonedCount != want || streamed != want {
+		t.Errorf("job found %d (streamed %d), want %d; err %v", scan.Result.PoisonedCount, streamed, want, err)
+	}
+
+	defense, err := c.Defend(ctx, server.DefenseRequest{DatasetID: dataset.ID, Strategy: "Data Cleaning"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cleaned bytes.Buffer
+	if err := c.CleanedDataset(ctx, defense.ID, &cleaned); err != nil || !strings.HasPrefix(cleaned.String(), "id,") {
+		t.Errorf("CleanedDataset = %q, %v", cleaned.String(), err)
+	}
+
+	if _, err := c.GetScan(ctx, "missing"); !IsNotFound(err) {
+		t.Errorf("GetScan(missing) error = %v, want not found", err)
+	}
+	if _, err := c.Project("nope").Scans(ctx); !IsNotFound(err) {
+		t.Errorf("unknown project error = %v, want not found", err)
+	}
+}
+
+func TestStreamFindingsBuffer(t *testing.T) {
+	for _, tc := range []struct {
+		policy server.StreamPolicy
+		want   func(all int) int
+	}{
+		{server.StreamSpill, func(all int) int { return all }},
+		// Without streaming clients, a blocking job evicts like drop.
+		{server.StreamDrop, func(int) int { return 3 }},
+		{server.StreamBlock, func(int) int { return 3 }},
+	} {
+		t.Run(string(tc.policy), func(t *testing.T) {
+			srv := server.New(server.Options{
+				Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+				StreamBuffer: 3,
+				StreamPolicy: tc.policy,
+				StreamDir:    t.TempDir(),
+			})
+			defer srv.Close()
+			ts := httptest.NewServer(srv)
+			defer ts.Close()
+			c, err := New(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+
+			dataset, err := c.UploadDataset(ctx, strings.NewReader(outlierCSV()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			job, err := c.SubmitJob(ctx, server.ScanRequest{DatasetID: dataset.ID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if job, err = c.WaitJob(ctx, job.ID, 10*time.Millisecond); err != nil || job.State != jobs.StateSucceeded {
+				t.Fatalf("WaitJob = %+v, %v", job, err)
+			}
+			scan, err := c.GetScan(ctx, job.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			all := scan.Result.PoisonedCount
+			if all <= 3 {
+				t.Fatalf("job found %d samples; the test needs more than the buffer", all)
+			}
+
+			var streamed []string
+			if err := c.StreamFindings(ctx, job.ID, 0, func(f detect.PoisonedSample) error {
+				streamed = append(streamed, f.ID)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if len(streamed) != tc.want(all) || streamed[len(streamed)-1] != "s95" {
+				t.Errorf("streamed %v, want the last %d of %d findings", streamed, tc.want(all), all)
+			}
+			progress, err := c.Progress(ctx, job.ID)
+			if err != nil || len(progress.Findings) != 3 || progress.Offset != all-3 {
+				t.Errorf("Progress = %d findings from %d, %v; want 3 from %d", len(progress.Findings), progress.Offset, err, all-3)
+			}
+		})
+	}
+}
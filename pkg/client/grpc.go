@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	pb "github.com/hallucinaut/modelpoison/pkg/rpc/modelpoisonv1"
+)
+
+// GRPCConn is a connection to the ModelPoison gRPC service.
+type GRPCConn struct {
+	pb.ModelPoisonClient
+	conn *grpc.ClientConn
+}
+
+// Close closes the connection.
+func (c *GRPCConn) Close() error {
+	return c.conn.Close()
+}
+
+// DialGRPC connects to the gRPC service at target, such as
+// "modelpoison.internal:9090". A nil tlsConfig connects without TLS. A
+// non-empty token is sent as a bearer token with every call.
+func DialGRPC(target string, tlsConfig *tls.Config, token string, opts ...grpc.DialOption) (*GRPCConn, error) {
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, opts...)
+	if token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(TokenCredentials{Token: token, Insecure: tlsConfig == nil}))
+	}
+
+	conn, err := grpc.Dial(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCConn{ModelPoisonClient: pb.NewModelPoisonClient(conn), conn: conn}, nil
+}
+
+// TokenCredentials sends a bearer token with every gRPC call.
+type TokenCredentials struct {
+	Token string
+	// Insecure allows sending the token without TLS.
+	Insecure bool
+}
+
+// GetRequestMetadata implements credentials.PerRPCCredentials.
+func (t TokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.Token}, nil
+}
+
+// RequireTransportSecurity implements credentials.PerRPCCredentials.
+func (t TokenCredentials) RequireTransportSecurity() bool {
+	return !t.Insecure
+}
@@ -0,0 +1,346 @@
+// Package config loads and saves modelpoison configuration files.
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hallucinaut/modelpoison/pkg/alert"
+	"github.com/hallucinaut/modelpoison/pkg/archive"
+	"github.com/hallucinaut/modelpoison/pkg/auth"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/eventbus"
+	"github.com/hallucinaut/modelpoison/pkg/policy"
+	"github.com/hallucinaut/modelpoison/pkg/preprocess"
+	"github.com/hallucinaut/modelpoison/pkg/siem"
+	"github.com/hallucinaut/modelpoison/pkg/webhook"
+)
+
+// Config holds detector configuration.
+type Config struct {
+	// Thresholds maps poison types to detection thresholds.
+	Thresholds map[string]float64 `yaml:"thresholds,omitempty"`
+	// NonFinite is the policy for NaN and infinite features: skip (the
+	// default), suspicious or error.
+	NonFinite string `yaml:"non_finite,omitempty"`
+	// RiskModel, when set, replaces the default model combining findings
+	// into risk scores.
+	RiskModel *detect.RiskModel `yaml:"risk_model,omitempty"`
+	// Scaling, when set, scales features before they are scanned or
+	// defended.
+	Scaling *preprocess.Config `yaml:"scaling,omitempty"`
+	// Encoding, when set, encodes categorical columns as features.
+	Encoding *preprocess.EncodingConfig `yaml:"encoding,omitempty"`
+	// Webhooks are notified when scans complete.
+	Webhooks []webhook.Hook `yaml:"webhooks,omitempty"`
+	// Auth lists the credentials accepted by the API servers.
+	Auth auth.Config `yaml:"auth,omitempty"`
+	// Projects isolates teams on the API server, keyed by project name.
+	Projects map[string]Project `yaml:"projects,omitempty"`
+	// Archive, when set, uploads the report and raw result of every scan
+	// to object storage.
+	Archive *archive.Config `yaml:"archive,omitempty"`
+	// Alerts sends Slack and email alerts for scans matching its rules.
+	Alerts *alert.Config `yaml:"alerts,omitempty"`
+	// SIEM lists the Splunk and Elasticsearch sinks that receive the
+	// findings of every scan.
+	SIEM []siem.Sink `yaml:"siem,omitempty"`
+	// EventBus lists the Kafka and NATS sinks that receive the summary and
+	// findings of every scan as events.
+	EventBus []eventbus.Sink `yaml:"event_bus,omitempty"`
+	// Policy lists the Rego policies that gate scan results.
+	Policy *policy.Config `yaml:"policy,omitempty"`
+}
+
+// DefaultProject is the server project used by requests that do not name
+// one. It takes the top-level configuration.
+const DefaultProject = "default"
+
+// Project overrides the configuration for one server project.
+type Project struct {
+	// Thresholds override the top-level thresholds.
+	Thresholds map[string]float64 `yaml:"thresholds,omitempty"`
+	// NonFinite replaces the top-level non-finite policy when set.
+	NonFinite string `yaml:"non_finite,omitempty"`
+	// RiskModel replaces the top-level risk model when set.
+	RiskModel *detect.RiskModel `yaml:"risk_model,omitempty"`
+	// Scaling replaces the top-level scaling when set.
+	Scaling *preprocess.Config `yaml:"scaling,omitempty"`
+	// Encoding replaces the top-level encoding when set.
+	Encoding *preprocess.EncodingConfig `yaml:"encoding,omitempty"`
+	// Webhooks replace the top-level webhooks when set.
+	Webhooks []webhook.Hook `yaml:"webhooks,omitempty"`
+	// Archive replaces the top-level archive when set.
+	Archive *archive.Config `yaml:"archive,omitempty"`
+	// Alerts replace the top-level alerts when set.
+	Alerts *alert.Config `yaml:"alerts,omitempty"`
+	// SIEM replaces the top-level SIEM sinks when set.
+	SIEM []siem.Sink `yaml:"siem,omitempty"`
+	// EventBus replaces the top-level event bus sinks when set.
+	EventBus []eventbus.Sink `yaml:"event_bus,omitempty"`
+	// Policy replaces the top-level policy when set.
+	Policy *policy.Config `yaml:"policy,omitempty"`
+}
+
+// projectName matches valid project names.
+var projectName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ForProject returns the configuration of the named project: the
+// top-level configuration with the project's overrides applied.
+func (c *Config) ForProject(name string) (*Config, bool) {
+	if name == DefaultProject {
+		return c, true
+	}
+	project, ok := c.Projects[name]
+	if !ok {
+		return nil, false
+	}
+
+	cfg := &Config{
+		Thresholds: make(map[string]float64, len(c.Thresholds)+len(project.Thresholds)),
+		NonFinite:  c.NonFinite,
+		RiskModel:  c.RiskModel,
+		Scaling:    c.Scaling,
+		Encoding:   c.Encoding,
+		Webhooks:   c.Webhooks,
+		Auth:       c.Auth,
+		Archive:    c.Archive,
+		Alerts:     c.Alerts,
+		SIEM:       c.SIEM,
+		EventBus:   c.EventBus,
+		Policy:     c.Policy,
+	}
+	for t, threshold := range c.Thresholds {
+		cfg.Thresholds[t] = threshold
+	}
+	for t, threshold := range project.Thresholds {
+		cfg.Thresholds[t] = threshold
+	}
+	if project.NonFinite != "" {
+		cfg.NonFinite = project.NonFinite
+	}
+	if project.RiskModel != nil {
+		cfg.RiskModel = project.RiskModel
+	}
+	if project.Scaling != nil {
+		cfg.Scaling = project.Scaling
+	}
+	if project.Encoding != nil {
+		cfg.Encoding = project.Encoding
+	}
+	if len(project.Webhooks) > 0 {
+		cfg.Webhooks = project.Webhooks
+	}
+	if project.Archive != nil {
+		cfg.Archive = project.Archive
+	}
+	if project.Alerts != nil {
+		cfg.Alerts = project.Alerts
+	}
+	if len(project.SIEM) > 0 {
+		cfg.SIEM = project.SIEM
+	}
+	if len(project.EventBus) > 0 {
+		cfg.EventBus = project.EventBus
+	}
+	if project.Policy != nil {
+		cfg.Policy = project.Policy
+	}
+	return cfg, true
+}
+
+// Load reads a YAML configuration file.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if _, err := detect.ParseNonFinite(cfg.NonFinite); err != nil {
+		return nil, fmt.Errorf("%s: non_finite: %w", path, err)
+	}
+	if cfg.RiskModel != nil {
+		if err := cfg.RiskModel.Check(); err != nil {
+			return nil, fmt.Errorf("%s: risk_model: %w", path, err)
+		}
+	}
+	if cfg.Scaling != nil {
+		if err := cfg.Scaling.Check(); err != nil {
+			return nil, fmt.Errorf("%s: scaling: %w", path, err)
+		}
+	}
+	if cfg.Encoding != nil {
+		if err := cfg.Encoding.Check(); err != nil {
+			return nil, fmt.Errorf("%s: encoding: %w", path, err)
+		}
+	}
+	for _, hook := range cfg.Webhooks {
+		if err := hook.Check(); err != nil {
+			return nil, fmt.Errorf("%s: webhooks: %w", path, err)
+		}
+	}
+	if err := cfg.Auth.Check(); err != nil {
+		return nil, fmt.Errorf("%s: auth: %w", path, err)
+	}
+	if cfg.Archive != nil {
+		if err := cfg.Archive.Check(); err != nil {
+			return nil, fmt.Errorf("%s: archive: %w", path, err)
+		}
+	}
+	if cfg.Alerts != nil {
+		if err := cfg.Alerts.Check(); err != nil {
+			return nil, fmt.Errorf("%s: alerts: %w", path, err)
+		}
+	}
+	for _, sink := range cfg.SIEM {
+		if err := sink.Check(); err != nil {
+			return nil, fmt.Errorf("%s: siem: %w", path, err)
+		}
+	}
+	for _, sink := range cfg.EventBus {
+		if err := sink.Check(); err != nil {
+			return nil, fmt.Errorf("%s: event_bus: %w", path, err)
+		}
+	}
+	if cfg.Policy != nil {
+		if err := cfg.Policy.Check(); err != nil {
+			return nil, fmt.Errorf("%s: policy: %w", path, err)
+		}
+	}
+	for _, name := range cfg.ProjectNames() {
+		project := cfg.Projects[name]
+		if name == DefaultProject || !projectName.MatchString(name) {
+			return nil, fmt.Errorf("%s: projects: invalid project name %q", path, name)
+		}
+		if _, err := detect.ParseNonFinite(project.NonFinite); err != nil {
+			return nil, fmt.Errorf("%s: projects: %s: non_finite: %w", path, name, err)
+		}
+		if project.RiskModel != nil {
+			if err := project.RiskModel.Check(); err != nil {
+				return nil, fmt.Errorf("%s: projects: %s: risk_model: %w", path, name, err)
+			}
+		}
+		if project.Scaling != nil {
+			if err := project.Scaling.Check(); err != nil {
+				return nil, fmt.Errorf("%s: projects: %s: scaling: %w", path, name, err)
+			}
+		}
+		if project.Encoding != nil {
+			if err := project.Encoding.Check(); err != nil {
+				return nil, fmt.Errorf("%s: projects: %s: encoding: %w", path, name, err)
+			}
+		}
+		for _, hook := range project.Webhooks {
+			if err := hook.Check(); err != nil {
+				return nil, fmt.Errorf("%s: projects: %s: webhooks: %w", path, name, err)
+			}
+		}
+		if project.Archive != nil {
+			if err := project.Archive.Check(); err != nil {
+				return nil, fmt.Errorf("%s: projects: %s: archive: %w", path, name, err)
+			}
+		}
+		if project.Alerts != nil {
+			if err := project.Alerts.Check(); err != nil {
+				return nil, fmt.Errorf("%s: projects: %s: alerts: %w", path, name, err)
+			}
+		}
+		for _, sink := range project.SIEM {
+			if err := sink.Check(); err != nil {
+				return nil, fmt.Errorf("%s: projects: %s: siem: %w", path, name, err)
+			}
+		}
+		for _, sink := range project.EventBus {
+			if err := sink.Check(); err != nil {
+				return nil, fmt.Errorf("%s: projects: %s: event_bus: %w", path, name, err)
+			}
+		}
+		if project.Policy != nil {
+			if err := project.Policy.Check(); err != nil {
+				return nil, fmt.Errorf("%s: projects: %s: policy: %w", path, name, err)
+			}
+		}
+	}
+	for _, token := range cfg.Auth.Tokens {
+		if err := cfg.checkProjects(token.Projects); err != nil {
+			return nil, fmt.Errorf("%s: auth: token %q: %w", path, token.Name, err)
+		}
+	}
+	for _, client := range cfg.Auth.Clients {
+		if err := cfg.checkProjects(client.Projects); err != nil {
+			return nil, fmt.Errorf("%s: auth: client %q: %w", path, client.CommonName, err)
+		}
+	}
+
+	return &cfg, nil
+}
+
+// checkProjects reports names that are not configured projects.
+func (c *Config) checkProjects(names []string) error {
+	for _, name := range names {
+		if _, ok := c.ForProject(name); !ok {
+			return fmt.Errorf("unknown project %q", name)
+		}
+	}
+	return nil
+}
+
+// Save writes the configuration to a YAML file.
+func (c *Config) Save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// ProjectNames returns the names of the configured projects in sorted
+// order.
+func (c *Config) ProjectNames() []string {
+	names := make([]string, 0, len(c.Projects))
+	for name := range c.Projects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply configures a detector with the configured thresholds, in name
+// order so the same invalid configuration always reports the same error,
+// and the non-finite policy and risk model when they are configured.
+func (c *Config) Apply(d *detect.Detector) error {
+	if c.NonFinite != "" {
+		policy, err := detect.ParseNonFinite(c.NonFinite)
+		if err != nil {
+			return fmt.Errorf("non_finite: %w", err)
+		}
+		if err := d.SetNonFinite(policy); err != nil {
+			return fmt.Errorf("non_finite: %w", err)
+		}
+	}
+	if c.RiskModel != nil {
+		if err := d.SetRiskModel(*c.RiskModel); err != nil {
+			return fmt.Errorf("risk_model: %w", err)
+		}
+	}
+	names := make([]string, 0, len(c.Thresholds))
+	for name := range c.Thresholds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		threshold := c.Thresholds[name]
+		if err := d.SetThreshold(detect.PoisonType(name), threshold); err != nil {
+			return fmt.Errorf("thresholds: %w", err)
+		}
+	}
+	return nil
+}
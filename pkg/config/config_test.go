@@ -0,0 +1,178 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/preprocess"
+)
+
+// writeConfig writes a configuration file holding text and returns its
+// path.
+func writeConfig(t *testing.T, text string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "modelpoison.yaml")
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `
+thresholds:
+  backdoor: 0.5
+non_finite: suspicious
+risk_model:
+  ratio_weight: 0.4
+  confidence_weight: 0.6
+scaling:
+  method: zscore
+  features:
+    age: robust
+projects:
+  fraud:
+    thresholds:
+      label_flip: 0.9
+auth:
+  tokens:
+    - name: ci
+      token: secret
+      scopes: [scan]
+      projects: [fraud, default]
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Thresholds["backdoor"] != 0.5 || cfg.NonFinite != "suspicious" || cfg.RiskModel.RatioWeight != 0.4 {
+		t.Errorf("loaded %+v", cfg)
+	}
+	if want := (preprocess.Config{Method: preprocess.ZScore, Features: map[string]preprocess.Method{"age": preprocess.Robust}}); !reflect.DeepEqual(*cfg.Scaling, want) {
+		t.Errorf("scaling %+v, want %+v", *cfg.Scaling, want)
+	}
+	if names := cfg.ProjectNames(); len(names) != 1 || names[0] != "fraud" {
+		t.Errorf("projects %v", names)
+	}
+
+	// Save writes what Load reads.
+	saved := filepath.Join(t.TempDir(), "saved.yaml")
+	if err := cfg.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := Load(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reloaded, cfg) {
+		t.Errorf("reloaded %+v, want %+v", reloaded, cfg)
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); !os.IsNotExist(err) {
+		t.Errorf("loading a missing config: %v", err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, test := range []struct {
+		name, text, err string
+	}{
+		{"malformed", "thresholds: [", "yaml:"},
+		{"unknown field type", "thresholds: high", "cannot unmarshal"},
+		{"non-finite", "non_finite: ignore", "non_finite: unknown non-finite policy"},
+		{"risk model", "risk_model: {ratio_weight: 0, confidence_weight: 0}", "risk_model: ratio_weight or confidence_weight must be positive"},
+		{"scaling", "scaling: {method: log}", "scaling:"},
+		{"auth", "auth: {tokens: [{token: x}]}", "auth: token without a name"},
+		{"default project", "projects: {default: {}}", `projects: invalid project name "default"`},
+		{"project name", "projects: {Fraud: {}}", `projects: invalid project name "Fraud"`},
+		{"project non-finite", "projects: {fraud: {non_finite: ignore}}", "projects: fraud: non_finite:"},
+		{"project scaling", "projects: {fraud: {scaling: {method: log}}}", "projects: fraud: scaling:"},
+		{"token project", "auth: {tokens: [{name: ci, token: x, scopes: [scan], projects: [fraud]}]}", `auth: token "ci": unknown project "fraud"`},
+		{"client project", "auth: {clients: [{common_name: ci, scopes: [scan], projects: [fraud]}]}", `auth: client "ci": unknown project "fraud"`},
+	} {
+		path := writeConfig(t, test.text)
+		if _, err := Load(path); err == nil || !strings.HasPrefix(err.Error(), path+": ") || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: error %v, want %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	defaults := detect.NewDetector()
+
+	// An empty configuration keeps the detector's defaults.
+	d := detect.NewDetector()
+	if err := (&Config{}).Apply(d); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d.Thresholds(), defaults.Thresholds()) || d.NonFinite() != detect.NonFiniteSkip || !reflect.DeepEqual(d.RiskModel(), defaults.RiskModel()) {
+		t.Error("an empty configuration changed the detector's defaults")
+	}
+
+	// Configured values replace the defaults, and only those.
+	model := detect.RiskModel{RatioWeight: 1}
+	cfg := &Config{Thresholds: map[string]float64{"backdoor": 0.3}, NonFinite: "error", RiskModel: &model}
+	if err := cfg.Apply(d); err != nil {
+		t.Fatal(err)
+	}
+	want := defaults.Thresholds()
+	want[detect.TypeBackdoor] = 0.3
+	if !reflect.DeepEqual(d.Thresholds(), want) || d.NonFinite() != detect.NonFiniteError || d.RiskModel().RatioWeight != 1 {
+		t.Errorf("applied thresholds %v, non-finite %s, risk model %+v", d.Thresholds(), d.NonFinite(), d.RiskModel())
+	}
+
+	// Invalid thresholds are reported in name order.
+	cfg = &Config{Thresholds: map[string]float64{"zeta": 0.5, "backdoor": 2, "alpha": 0.5}}
+	if err := cfg.Apply(detect.NewDetector()); err == nil || err.Error() != `thresholds: unknown poison type "alpha"` {
+		t.Errorf("applying invalid thresholds: %v", err)
+	}
+	cfg = &Config{Thresholds: map[string]float64{"backdoor": 2}}
+	if err := cfg.Apply(detect.NewDetector()); err == nil || !strings.HasPrefix(err.Error(), "thresholds: threshold for backdoor") {
+		t.Errorf("applying an out-of-range threshold: %v", err)
+	}
+}
+
+func TestForProject(t *testing.T) {
+	topScaling := &preprocess.Config{Method: preprocess.MinMax}
+	projectScaling := &preprocess.Config{Method: preprocess.Robust}
+	cfg := &Config{
+		Thresholds: map[string]float64{"backdoor": 0.5, "label_flip": 0.6},
+		NonFinite:  "suspicious",
+		Scaling:    topScaling,
+		Projects: map[string]Project{
+			"fraud": {Thresholds: map[string]float64{"label_flip": 0.9}, Scaling: projectScaling},
+			"bare":  {},
+		},
+	}
+
+	if got, ok := cfg.ForProject(DefaultProject); !ok || got != cfg {
+		t.Error("the default project does not take the top-level configuration")
+	}
+	if _, ok := cfg.ForProject("missing"); ok {
+		t.Error("found an unconfigured project")
+	}
+
+	// A project's overrides take precedence over the top-level values,
+	// which fill the rest.
+	fraud, _ := cfg.ForProject("fraud")
+	if want := map[string]float64{"backdoor": 0.5, "label_flip": 0.9}; !reflect.DeepEqual(fraud.Thresholds, want) {
+		t.Errorf("fraud thresholds %v, want %v", fraud.Thresholds, want)
+	}
+	if fraud.Scaling != projectScaling || fraud.NonFinite != "suspicious" {
+		t.Errorf("fraud scaling %+v, non-finite %q", fraud.Scaling, fraud.NonFinite)
+	}
+	bare, _ := cfg.ForProject("bare")
+	if !reflect.DeepEqual(bare.Thresholds, cfg.Thresholds) || bare.Scaling != topScaling {
+		t.Errorf("bare project %+v, want the top-level configuration", bare)
+	}
+
+	// Overriding a project's thresholds leaves the top level untouched.
+	fraud.Thresholds["backdoor"] = 0.1
+	if cfg.Thresholds["backdoor"] != 0.5 || cfg.Thresholds["label_flip"] != 0.6 {
+		t.Errorf("top-level thresholds changed to %v", cfg.Thresholds)
+	}
+}
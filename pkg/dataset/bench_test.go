@@ -0,0 +1,69 @@
+package dataset
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// benchCSV returns a CSV dataset of rows rows with width normally
+// distributed features, formatted as they typically are in exports.
+func benchCSV(rows, width int) []byte {
+	rng := rand.New(rand.NewSource(1))
+	var b bytes.Buffer
+	b.WriteString("id")
+	for f := 0; f < width; f++ {
+		fmt.Fprintf(&b, ",f%d", f)
+	}
+	b.WriteString(",label\n")
+	for i := 0; i < rows; i++ {
+		b.WriteString("s" + strconv.Itoa(i))
+		for f := 0; f < width; f++ {
+			b.WriteByte(',')
+			b.WriteString(strconv.FormatFloat(rng.NormFloat64(), 'f', 6, 64))
+		}
+		b.WriteString("," + strconv.Itoa(i%2) + "\n")
+	}
+	return b.Bytes()
+}
+
+func BenchmarkReadCSV(b *testing.B) {
+	for _, shape := range [][2]int{{1000, 20}, {10000, 20}, {100000, 20}, {10000, 200}} {
+		data := benchCSV(shape[0], shape[1])
+		b.Run(fmt.Sprintf("rows=%d/features=%d", shape[0], shape[1]), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := ReadCSV(bytes.NewReader(data)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkReader(b *testing.B) {
+	for _, shape := range [][2]int{{100000, 20}, {10000, 200}} {
+		data := benchCSV(shape[0], shape[1])
+		b.Run(fmt.Sprintf("rows=%d/features=%d", shape[0], shape[1]), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				r, err := NewReader(bytes.NewReader(data), Columns{})
+				if err != nil {
+					b.Fatal(err)
+				}
+				for {
+					if _, err := r.Read(4096); err == io.EOF {
+						break
+					} else if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
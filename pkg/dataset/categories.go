@@ -0,0 +1,68 @@
+package dataset
+
+import (
+	"io"
+	"strings"
+)
+
+// CountCategories reads CSV data and counts the values of the categorical
+// columns selected by cols, keyed by column name then value, for fitting
+// the encoder that will load the data. Missing values are not counted.
+// cols.Encoder is not used.
+func CountCategories(r io.Reader, cols Columns) (map[string]map[string]int, error) {
+	counts := categoryCounts{}
+	cols.Encoder = counts
+	rd, err := NewReader(r, cols)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range rd.categories {
+		if name != "" {
+			counts[name] = make(map[string]*int)
+		}
+	}
+	for {
+		if err := rd.readRecord(len(rd.header)); err == io.EOF {
+			return counts.totals(), nil
+		} else if err != nil {
+			return nil, err
+		}
+		for i, field := range rd.fields {
+			if name := rd.categories[i]; name != "" {
+				counts.Encode(name, transient(field), nil)
+			}
+		}
+	}
+}
+
+// categoryCounts is an Encoder counting values without encoding them.
+// Counts are incremented through pointers: assigning to a map entry would
+// replace its key with the transient value.
+type categoryCounts map[string]map[string]*int
+
+func (c categoryCounts) Features(string) []string { return nil }
+
+func (c categoryCounts) Encode(column, value string, dst []float64) []float64 {
+	value = strings.TrimSpace(value)
+	if isMissing(value) {
+		return dst
+	}
+	if n, ok := c[column][value]; ok {
+		*n++
+	} else {
+		n := 1
+		c[column][strings.Clone(value)] = &n
+	}
+	return dst
+}
+
+func (c categoryCounts) totals() map[string]map[string]int {
+	totals := make(map[string]map[string]int, len(c))
+	for column, values := range c {
+		totals[column] = make(map[string]int, len(values))
+		for value, n := range values {
+			totals[column][value] = *n
+		}
+	}
+	return totals
+}
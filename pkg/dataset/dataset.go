@@ -0,0 +1,388 @@
+// Package dataset loads training datasets for poisoning analysis.
+package dataset
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+var tracer = otel.Tracer("github.com/hallucinaut/modelpoison/pkg/dataset")
+
+// Dataset holds loaded samples and their feature names.
+type Dataset struct {
+	Features []string
+	Samples  []detect.Sample
+
+	// Header and Records hold the original CSV header and rows, so subsets
+	// can be written back unchanged.
+	Header  []string
+	Records [][]string
+
+	// Poisoned holds per-sample ground truth when the dataset has a
+	// "poisoned" column, and is nil otherwise.
+	Poisoned []bool
+
+	// Column positions of features, label and ground truth in Records.
+	// Features encoding categorical columns have no position (-1).
+	featureCols []int
+	labelCol    int
+	truthCol    int
+}
+
+// Columns selects the columns of a CSV file used for each role. Columns are
+// matched by header name, ignoring case and surrounding space. Empty fields
+// keep the defaults described by ReadCSV.
+type Columns struct {
+	// Features lists the feature columns. When empty, every column without
+	// another role is a feature.
+	Features []string
+	// Label and ID name the label and sample ID columns.
+	Label string
+	ID    string
+	// Source names the column of the source or contributor of each
+	// sample, kept in its metadata under detect.MetaSource for denylists
+	// rather than read as a feature.
+	Source string
+	// Ignore lists columns that are neither features nor roles, such as
+	// timestamps or free text.
+	Ignore []string
+	// Categorical lists columns holding categories rather than numbers,
+	// which Encoder encodes as features in their place. The encoder is
+	// passed each column's name as listed.
+	Categorical []string
+	Encoder     Encoder
+	// Float32 stores the features of samples in Features32 as float32
+	// rather than in Features, halving their memory.
+	Float32 bool
+	// FeaturesField names the field of JSON samples holding their feature
+	// vector, as read by ReadJSON.
+	FeaturesField string
+}
+
+// An Encoder encodes the values of categorical columns as numeric
+// features.
+type Encoder interface {
+	// Features returns the names of the features encoding the named
+	// column.
+	Features(column string) []string
+	// Encode appends the features encoding a value of the named column to
+	// dst, as many as Features names. value is empty for missing values
+	// (empty, "NA", "NaN" or "null" fields) and must not be retained.
+	Encode(column, value string, dst []float64) []float64
+}
+
+// LoadCSV loads a dataset from a CSV file.
+func LoadCSV(path string) (*Dataset, error) {
+	return LoadCSVColumns(path, Columns{})
+}
+
+// LoadCSVColumns loads a dataset from a CSV file using the given columns.
+func LoadCSVColumns(path string, cols Columns) (*Dataset, error) {
+	return LoadCSVContext(context.Background(), path, cols)
+}
+
+// LoadCSVContext loads a dataset like LoadCSVColumns, recording a span in
+// the trace of ctx.
+func LoadCSVContext(ctx context.Context, path string, cols Columns) (*Dataset, error) {
+	_, span := tracer.Start(ctx, "dataset.LoadCSV", trace.WithAttributes(attribute.String("modelpoison.dataset.path", path)))
+	defer span.End()
+
+	f, err := os.Open(path)
+	if err != nil {
+		recordError(span, err)
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := readCSV(f, cols)
+	if err != nil {
+		recordError(span, err)
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	recordShape(span, data)
+
+	return data, nil
+}
+
+// ReadCSV reads a dataset from CSV data.
+//
+// The first row is a header, unless it holds only numbers: files without a
+// header have features named f0, f1 and so on and the label last. A column
+// named "label" holds the class label (the last column is used otherwise),
+// an optional column named "id" holds the sample ID and an optional column
+// named "poisoned" holds ground truth (1/0 or true/false). All remaining
+// columns are numeric features; empty, "NA", "NaN" and "null" fields are
+// loaded as missing values (NaN).
+func ReadCSV(r io.Reader) (*Dataset, error) {
+	return ReadCSVColumns(r, Columns{})
+}
+
+// ReadCSVColumns reads a dataset from CSV data like ReadCSV, with the
+// column roles overridden by cols.
+func ReadCSVColumns(r io.Reader, cols Columns) (*Dataset, error) {
+	return ReadCSVContext(context.Background(), r, cols)
+}
+
+// ReadCSVContext reads a dataset like ReadCSVColumns, recording a span in
+// the trace of ctx.
+func ReadCSVContext(ctx context.Context, r io.Reader, cols Columns) (*Dataset, error) {
+	_, span := tracer.Start(ctx, "dataset.ReadCSV")
+	defer span.End()
+
+	data, err := readCSV(r, cols)
+	if err != nil {
+		recordError(span, err)
+		return nil, err
+	}
+	recordShape(span, data)
+
+	return data, nil
+}
+
+// recordShape records the size of a loaded dataset on span.
+func recordShape(span trace.Span, data *Dataset) {
+	span.SetAttributes(
+		attribute.Int("modelpoison.dataset.samples", len(data.Samples)),
+		attribute.Int("modelpoison.dataset.features", len(data.Features)),
+	)
+}
+
+// recordError marks span as failed with err.
+func recordError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// readCSV reads a dataset from CSV data.
+func readCSV(r io.Reader, cols Columns) (*Dataset, error) {
+	rd, err := NewReader(r, cols)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &Dataset{
+		Features:    rd.features,
+		Header:      rd.header,
+		featureCols: rd.featureCols,
+		labelCol:    rd.labelCol,
+		truthCol:    rd.truthCol,
+	}
+	if rd.truthCol >= 0 {
+		data.Poisoned = []bool{}
+	}
+	for {
+		record, sample, poisoned, err := rd.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		data.Samples = append(data.Samples, sample)
+		data.Records = append(data.Records, record)
+		if rd.truthCol >= 0 {
+			data.Poisoned = append(data.Poisoned, poisoned)
+		}
+	}
+
+	return data, nil
+}
+
+// FromSamples returns a dataset of samples with the named features, whose
+// CSV has an "id" column, the features and a "label" column.
+func FromSamples(features []string, samples []detect.Sample) *Dataset {
+	data := &Dataset{
+		Features:    features,
+		Header:      append(append([]string{"id"}, features...), "label"),
+		Samples:     make([]detect.Sample, len(samples)),
+		Records:     make([][]string, len(samples)),
+		featureCols: make([]int, len(features)),
+		labelCol:    len(features) + 1,
+		truthCol:    -1,
+	}
+	for j := range data.featureCols {
+		data.featureCols[j] = j + 1
+	}
+	for i, sample := range samples {
+		data.Records[i] = make([]string, len(data.Header))
+		data.Records[i][0] = sample.ID
+		data.SetSample(i, sample)
+	}
+	return data
+}
+
+// contains reports whether indices holds i.
+func contains(indices []int, i int) bool {
+	for _, j := range indices {
+		if j == i {
+			return true
+		}
+	}
+	return false
+}
+
+// columnIndex returns the position of the named column in header.
+func columnIndex(header []string, name string) (int, error) {
+	want := strings.ToLower(strings.TrimSpace(name))
+	for i, column := range header {
+		if strings.ToLower(strings.TrimSpace(column)) == want {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("no column named %q", name)
+}
+
+// Subset returns a dataset holding the samples at the given indices.
+func (d *Dataset) Subset(indices []int) *Dataset {
+	subset := &Dataset{
+		Features:    d.Features,
+		Header:      d.Header,
+		Samples:     make([]detect.Sample, 0, len(indices)),
+		Records:     make([][]string, 0, len(indices)),
+		featureCols: d.featureCols,
+		labelCol:    d.labelCol,
+		truthCol:    d.truthCol,
+	}
+	if d.Poisoned != nil {
+		subset.Poisoned = make([]bool, 0, len(indices))
+	}
+
+	for _, i := range indices {
+		subset.Samples = append(subset.Samples, d.Samples[i])
+		subset.Records = append(subset.Records, d.Records[i])
+		if d.Poisoned != nil {
+			subset.Poisoned = append(subset.Poisoned, d.Poisoned[i])
+		}
+	}
+
+	return subset
+}
+
+// Head returns a subset of the first n samples.
+func (d *Dataset) Head(n int) *Dataset {
+	if n > len(d.Samples) {
+		n = len(d.Samples)
+	}
+	indices := make([]int, n)
+	for i := range indices {
+		indices[i] = i
+	}
+	return d.Subset(indices)
+}
+
+// Sample returns a random subset of about fraction of the samples, at least
+// one, in their original order.
+func (d *Dataset) Sample(fraction float64, seed int64) *Dataset {
+	n := len(d.Samples)
+	k := int(math.Round(float64(n) * fraction))
+	if k < 1 {
+		k = 1
+	}
+	if k > n {
+		k = n
+	}
+
+	indices := rand.New(rand.NewSource(seed)).Perm(n)[:k]
+	sort.Ints(indices)
+	return d.Subset(indices)
+}
+
+// SetSample replaces sample i and rewrites its record to match the new
+// features and label. Missing values (NaN) are written as empty fields.
+func (d *Dataset) SetSample(i int, sample detect.Sample) {
+	record := append([]string(nil), d.Records[i]...)
+	features := sample.Features
+	if sample.Features32 != nil {
+		features = sample.Dense()
+	}
+	for j, col := range d.featureCols {
+		if j >= len(features) {
+			break
+		}
+		if col < 0 {
+			continue
+		}
+		record[col] = formatFeature(features[j])
+	}
+	record[d.labelCol] = strconv.Itoa(sample.Label)
+
+	d.Samples[i] = sample
+	d.Records[i] = record
+}
+
+// SetGroundTruth records per-sample ground truth, adding a "poisoned"
+// column when the dataset does not have one.
+func (d *Dataset) SetGroundTruth(poisoned []bool) error {
+	if len(poisoned) != len(d.Samples) {
+		return fmt.Errorf("got %d ground-truth labels for %d samples", len(poisoned), len(d.Samples))
+	}
+
+	if d.truthCol < 0 {
+		d.truthCol = len(d.Header)
+		d.Header = append(append([]string(nil), d.Header...), "poisoned")
+		for i := range d.Records {
+			d.Records[i] = append(append([]string(nil), d.Records[i]...), "")
+		}
+	}
+
+	d.Poisoned = append([]bool(nil), poisoned...)
+	for i, p := range poisoned {
+		value := "0"
+		if p {
+			value = "1"
+		}
+		d.Records[i][d.truthCol] = value
+	}
+
+	return nil
+}
+
+// formatFeature formats a feature value for CSV output.
+func formatFeature(v float64) string {
+	if math.IsNaN(v) {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// WriteCSV writes the dataset's original header and rows as CSV.
+func (d *Dataset) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(d.Header); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(d.Records); err != nil {
+		return err
+	}
+	return writer.Error()
+}
+
+// SaveCSV writes the dataset to a CSV file.
+func (d *Dataset) SaveCSV(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := d.WriteCSV(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
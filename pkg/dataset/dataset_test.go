@@ -0,0 +1,140 @@
+package dataset
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testDataset returns a dataset of n samples with ground truth, whose
+// sample i has ID s<i> and feature i.
+func testDataset(t *testing.T, n int) *Dataset {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("id,a,label,poisoned\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "s%d,%d,%d,%t\n", i, i, i%2, i%3 == 0)
+	}
+	data, err := ReadCSV(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// sampleIDs returns the IDs of the samples of data.
+func sampleIDs(data *Dataset) []string {
+	ids := make([]string, len(data.Samples))
+	for i, s := range data.Samples {
+		ids[i] = s.ID
+	}
+	return ids
+}
+
+func TestHead(t *testing.T) {
+	data := testDataset(t, 10)
+	for _, test := range []struct {
+		n    int
+		want []string
+	}{
+		{3, []string{"s0", "s1", "s2"}},
+		{0, []string{}},
+		{20, sampleIDs(data)},
+	} {
+		head := data.Head(test.n)
+		if ids := sampleIDs(head); !reflect.DeepEqual(ids, test.want) {
+			t.Errorf("Head(%d) = %v, want %v", test.n, ids, test.want)
+		}
+		if len(head.Records) != len(head.Samples) || len(head.Poisoned) != len(head.Samples) {
+			t.Errorf("Head(%d): %d records and %d ground truth values for %d samples", test.n, len(head.Records), len(head.Poisoned), len(head.Samples))
+		}
+		for i, s := range head.Samples {
+			if head.Records[i][0] != s.ID || head.Poisoned[i] != data.Poisoned[i] {
+				t.Errorf("Head(%d): sample %s has record %v, ground truth %t", test.n, s.ID, head.Records[i], head.Poisoned[i])
+			}
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	data := testDataset(t, 100)
+	for _, test := range []struct {
+		fraction float64
+		size     int
+	}{
+		{0.25, 25},
+		{0.001, 1},
+		{1, 100},
+		{2, 100},
+	} {
+		sample := data.Sample(test.fraction, 7)
+		if len(sample.Samples) != test.size || len(sample.Records) != test.size || len(sample.Poisoned) != test.size {
+			t.Errorf("Sample(%v): %d samples, %d records, %d ground truth values; want %d", test.fraction, len(sample.Samples), len(sample.Records), len(sample.Poisoned), test.size)
+		}
+		// Samples keep their order, records and ground truth.
+		last := -1
+		for i, s := range sample.Samples {
+			var k int
+			fmt.Sscanf(s.ID, "s%d", &k)
+			if k <= last || sample.Records[i][0] != s.ID || sample.Poisoned[i] != data.Poisoned[k] {
+				t.Errorf("Sample(%v): sample %d is %s after s%d, record %v", test.fraction, i, s.ID, last, sample.Records[i])
+			}
+			last = k
+		}
+	}
+
+	// The same seed chooses the same samples, and another seed others.
+	first := sampleIDs(data.Sample(0.2, 42))
+	if again := sampleIDs(data.Sample(0.2, 42)); !reflect.DeepEqual(first, again) {
+		t.Errorf("seed 42 chose %v, then %v", first, again)
+	}
+	if other := sampleIDs(data.Sample(0.2, 43)); reflect.DeepEqual(first, other) {
+		t.Errorf("seeds 42 and 43 both chose %v", first)
+	}
+}
+
+func TestColumns(t *testing.T) {
+	const input = "ID,a,b,when,class,label\nx,1,2,monday,1,0\ny,3,4,tuesday,0,1\n"
+	for _, test := range []struct {
+		name     string
+		cols     Columns
+		features []string
+		ids      []string
+		labels   []int
+		err      string
+	}{
+		{"defaults", Columns{Ignore: []string{"when"}}, []string{"a", "b", "class"}, []string{"x", "y"}, []int{0, 1}, ""},
+		{"selected features", Columns{Features: []string{"b", " A "}}, []string{"a", "b"}, []string{"x", "y"}, []int{0, 1}, ""},
+		{"selected features and label", Columns{Features: []string{"a", "b"}, Label: "class"}, []string{"a", "b"}, []string{"x", "y"}, []int{1, 0}, ""},
+		{"ignored feature", Columns{Features: []string{"a", "b"}, Ignore: []string{"b"}}, []string{"a"}, []string{"x", "y"}, []int{0, 1}, ""},
+		{"label and ignore", Columns{Label: "class", Ignore: []string{"when", "label"}}, []string{"a", "b"}, []string{"x", "y"}, []int{1, 0}, ""},
+		{"id column", Columns{ID: "a", Ignore: []string{"id", "when"}}, []string{"b", "class"}, []string{"1", "3"}, []int{0, 1}, ""},
+		{"unknown feature", Columns{Features: []string{"a", "c"}}, nil, nil, nil, `no column named "c"`},
+		{"unknown label", Columns{Label: "target"}, nil, nil, nil, `no column named "target"`},
+		{"unknown id", Columns{ID: "key"}, nil, nil, nil, `no column named "key"`},
+		{"unknown ignored", Columns{Ignore: []string{"timestamp"}}, nil, nil, nil, `no column named "timestamp"`},
+		{"label as feature", Columns{Features: []string{"a", "class"}, Label: "class"}, nil, nil, nil, `column "class" cannot be both a feature and the label, ID, source or ground truth`},
+	} {
+		data, err := ReadCSVColumns(strings.NewReader(input), test.cols)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: %v, want %s", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		var labels []int
+		for _, s := range data.Samples {
+			labels = append(labels, s.Label)
+			if len(s.Features) != len(test.features) {
+				t.Errorf("%s: sample %s has %d features, want %d", test.name, s.ID, len(s.Features), len(test.features))
+			}
+		}
+		if !reflect.DeepEqual(data.Features, test.features) || !reflect.DeepEqual(sampleIDs(data), test.ids) || !reflect.DeepEqual(labels, test.labels) {
+			t.Errorf("%s: features %v, IDs %v, labels %v; want %v, %v, %v", test.name, data.Features, sampleIDs(data), labels, test.features, test.ids, test.labels)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package dataset
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DefaultFeaturesField is the field of JSON samples read as their feature
+// vector, when it exists and Columns.FeaturesField is empty.
+const DefaultFeaturesField = "features"
+
+// LoadJSONContext loads a dataset from a JSON or JSON Lines file like
+// ReadJSON, recording a span in the trace of ctx.
+func LoadJSONContext(ctx context.Context, path string, cols Columns) (*Dataset, error) {
+	_, span := tracer.Start(ctx, "dataset.LoadJSON", trace.WithAttributes(attribute.String("modelpoison.dataset.path", path)))
+	defer span.End()
+
+	f, err := os.Open(path)
+	if err != nil {
+		recordError(span, err)
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := ReadJSON(bufio.NewReader(f), cols)
+	if err != nil {
+		recordError(span, err)
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	recordShape(span, data)
+
+	return data, nil
+}
+
+// ReadJSON reads a dataset from JSON data: an array of objects, or one
+// object per line as in JSON Lines. Each object is a sample whose fields
+// take the roles of CSV columns, as by ReadCSVColumns. An array of numbers
+// in cols.FeaturesField, or else in a "features" field, is the sample's
+// feature vector, whose features are named f0, f1 and so on; the other
+// fields are then features only when listed in cols.Features.
+func ReadJSON(r io.Reader, cols Columns) (*Dataset, error) {
+	records, err := ReadJSONRecords(r)
+	if err != nil {
+		return nil, err
+	}
+
+	vector := -1
+	if cols.FeaturesField != "" {
+		if vector, err = records.Column(cols.FeaturesField); err != nil {
+			return nil, err
+		}
+	} else if i, err := records.Column(DefaultFeaturesField); err == nil {
+		vector = i
+	}
+
+	header, rows := records.Fields, records.Rows
+	if vector >= 0 {
+		if header, rows, err = expandVector(records, vector); err != nil {
+			return nil, err
+		}
+		vectorFeatures := header[len(records.Fields)-1:]
+		cols.Features = append(append([]string(nil), vectorFeatures...), cols.Features...)
+	}
+
+	// The records are read as CSV, so columns take their roles alike.
+	var buf bytes.Buffer
+	if err := csv.NewWriter(&buf).WriteAll(append([][]string{header}, rows...)); err != nil {
+		return nil, err
+	}
+	return readCSV(&buf, cols)
+}
+
+// expandVector returns the fields and rows of records with the arrays of
+// numbers of field vector split into a field per feature, named f0, f1
+// and so on, last.
+func expandVector(records *Records, vector int) ([]string, [][]string, error) {
+	width := -1
+	rows := make([][]string, len(records.Rows))
+	for n, row := range records.Rows {
+		var values []json.Number
+		dec := json.NewDecoder(bytes.NewReader([]byte(row[vector])))
+		dec.UseNumber()
+		if err := dec.Decode(&values); err != nil {
+			return nil, nil, fmt.Errorf("record %d: field %q is not an array of numbers", n+1, records.Fields[vector])
+		}
+		if width < 0 {
+			width = len(values)
+		} else if len(values) != width {
+			return nil, nil, fmt.Errorf("record %d: %d features, want %d", n+1, len(values), width)
+		}
+		expanded := make([]string, 0, len(row)-1+width)
+		expanded = append(expanded, row[:vector]...)
+		expanded = append(expanded, row[vector+1:]...)
+		for _, v := range values {
+			expanded = append(expanded, v.String())
+		}
+		rows[n] = expanded
+	}
+
+	header := make([]string, 0, len(records.Fields)-1+width)
+	header = append(header, records.Fields[:vector]...)
+	header = append(header, records.Fields[vector+1:]...)
+	for i := 0; i < width; i++ {
+		header = append(header, "f"+strconv.Itoa(i))
+	}
+	return header, rows, nil
+}
@@ -0,0 +1,542 @@
+package dataset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unsafe"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Reader reads the samples of CSV data in chunks without keeping them, so
+// datasets of any size can be scanned in bounded memory. The CSV format is
+// the one read by ReadCSV; ground truth is parsed but not returned.
+//
+// Records without quotes, the common all-numeric case, are split and
+// parsed in place in reused buffers; quoted records are parsed by
+// encoding/csv.
+type Reader struct {
+	in       *bufio.Reader
+	header   []string
+	features []string
+
+	// featureCols holds the column of each feature, or -1 for features
+	// encoding a categorical column.
+	featureCols                          []int
+	labelCol, idCol, truthCol, sourceCol int
+	// isFeature marks the numeric feature columns and categories names
+	// the categorical ones, encoded by encoder; others are skipped.
+	isFeature  []bool
+	categories []string
+	encoder    Encoder
+	row        int
+	// float32 stores features as float32, parsed into scratch first.
+	float32 bool
+	scratch []float64
+
+	// line is the number of lines read and start the line the current
+	// record starts on; raw and fields hold the current record and its
+	// fields, with leading space trimmed as by encoding/csv.
+	line, start int
+	raw         []byte
+	fields      [][]byte
+	// pending is set while the current record, the first of a file
+	// without a header, is yet to be read as a sample.
+	pending bool
+}
+
+// NewReader reads the header of CSV data and returns a reader of its
+// samples, with the column roles overridden by cols.
+func NewReader(r io.Reader, cols Columns) (*Reader, error) {
+	rd := &Reader{in: bufio.NewReaderSize(r, 64<<10)}
+
+	if err := rd.readRecord(-1); err == io.EOF {
+		return nil, fmt.Errorf("empty dataset")
+	} else if err != nil {
+		return nil, err
+	}
+	header := rd.record()
+	if headerless(header) {
+		header = make([]string, len(header))
+		for i := range header {
+			header[i] = "f" + strconv.Itoa(i)
+		}
+		header[len(header)-1] = "label"
+		rd.pending = true
+	}
+
+	labelCol, idCol, truthCol := len(header)-1, -1, -1
+	for i, name := range header {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "label":
+			labelCol = i
+		case "id":
+			idCol = i
+		case "poisoned":
+			truthCol = i
+		}
+	}
+	if truthCol == labelCol {
+		labelCol = len(header) - 2
+	}
+
+	var err error
+	if cols.Label != "" {
+		if labelCol, err = columnIndex(header, cols.Label); err != nil {
+			return nil, err
+		}
+	}
+	if cols.ID != "" {
+		if idCol, err = columnIndex(header, cols.ID); err != nil {
+			return nil, err
+		}
+	}
+	sourceCol := -1
+	if cols.Source != "" {
+		if sourceCol, err = columnIndex(header, cols.Source); err != nil {
+			return nil, err
+		}
+		if sourceCol == labelCol || sourceCol == idCol || sourceCol == truthCol {
+			return nil, fmt.Errorf("column %q cannot be both the source and the label, ID or ground truth", cols.Source)
+		}
+	}
+
+	ignored := make(map[int]bool)
+	for _, name := range cols.Ignore {
+		i, err := columnIndex(header, name)
+		if err != nil {
+			return nil, err
+		}
+		ignored[i] = true
+	}
+
+	var featureCols []int
+	if len(cols.Features) > 0 {
+		for _, name := range cols.Features {
+			i, err := columnIndex(header, name)
+			if err != nil {
+				return nil, err
+			}
+			if i == labelCol || i == idCol || i == truthCol || i == sourceCol {
+				return nil, fmt.Errorf("column %q cannot be both a feature and the label, ID, source or ground truth", name)
+			}
+			if !ignored[i] && !contains(featureCols, i) {
+				featureCols = append(featureCols, i)
+			}
+		}
+	} else {
+		for i := range header {
+			if i != labelCol && i != idCol && i != truthCol && i != sourceCol && !ignored[i] {
+				featureCols = append(featureCols, i)
+			}
+		}
+	}
+	rd.categories = make([]string, len(header))
+	if len(cols.Categorical) > 0 && cols.Encoder == nil {
+		return nil, fmt.Errorf("categorical columns require an encoder")
+	}
+	for _, name := range cols.Categorical {
+		i, err := columnIndex(header, name)
+		if err != nil {
+			return nil, err
+		}
+		if i == labelCol || i == idCol || i == truthCol || i == sourceCol {
+			return nil, fmt.Errorf("column %q cannot be both categorical and the label, ID, source or ground truth", name)
+		}
+		rd.categories[i] = strings.TrimSpace(name)
+		if !contains(featureCols, i) {
+			featureCols = append(featureCols, i)
+		}
+	}
+	// Features are stored in file order regardless of the order requested.
+	sort.Ints(featureCols)
+
+	rd.header = header
+	rd.labelCol, rd.idCol, rd.truthCol, rd.sourceCol = labelCol, idCol, truthCol, sourceCol
+	rd.encoder = cols.Encoder
+	rd.float32 = cols.Float32
+	rd.isFeature = make([]bool, len(header))
+	for _, i := range featureCols {
+		if name := rd.categories[i]; name != "" {
+			for _, feature := range rd.encoder.Features(name) {
+				rd.features = append(rd.features, feature)
+				rd.featureCols = append(rd.featureCols, -1)
+			}
+			continue
+		}
+		rd.features = append(rd.features, strings.TrimSpace(header[i]))
+		rd.featureCols = append(rd.featureCols, i)
+		rd.isFeature[i] = true
+	}
+	return rd, nil
+}
+
+// Features returns the names of the feature columns.
+func (r *Reader) Features() []string {
+	return r.features
+}
+
+// Read reads up to n samples. At the end of the data it returns the
+// remaining samples, then no samples and io.EOF. The features of the
+// samples returned by one call share a single allocation.
+func (r *Reader) Read(n int) ([]detect.Sample, error) {
+	samples := make([]detect.Sample, 0, n)
+	width := len(r.featureCols)
+	var features []float64
+	var features32 []float32
+	if r.float32 {
+		features32 = make([]float32, n*width)
+	} else {
+		features = make([]float64, n*width)
+	}
+	for len(samples) < n {
+		if err := r.readRecord(len(r.header)); err == io.EOF {
+			if len(samples) == 0 {
+				return nil, io.EOF
+			}
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		k := len(samples) * width
+		var sample detect.Sample
+		var err error
+		if r.float32 {
+			sample, _, err = r.parse32(features32[k : k : k+width])
+		} else {
+			sample, _, err = r.parse(features[k : k : k+width])
+		}
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// next reads the next record and parses its sample and ground truth.
+func (r *Reader) next() ([]string, detect.Sample, bool, error) {
+	if err := r.readRecord(len(r.header)); err != nil {
+		return nil, detect.Sample{}, false, err
+	}
+	var sample detect.Sample
+	var poisoned bool
+	var err error
+	if r.float32 {
+		sample, poisoned, err = r.parse32(make([]float32, 0, len(r.featureCols)))
+	} else {
+		sample, poisoned, err = r.parse(make([]float64, 0, len(r.featureCols)))
+	}
+	if err != nil {
+		return nil, detect.Sample{}, false, err
+	}
+	return r.record(), sample, poisoned, nil
+}
+
+// parse parses the sample and ground truth of the current record,
+// appending its features to features.
+func (r *Reader) parse(features []float64) (detect.Sample, bool, error) {
+	r.row++
+	row := r.row
+
+	sample := detect.Sample{Features: features}
+	poisoned := false
+	var err error
+	for i, raw := range r.fields {
+		field := bytes.TrimSpace(raw)
+		switch i {
+		case r.idCol:
+			sample.ID = string(field)
+		case r.sourceCol:
+			sample.Metadata = map[string]interface{}{detect.MetaSource: string(field)}
+		case r.truthCol:
+			if poisoned, err = strconv.ParseBool(transient(field)); err != nil {
+				return detect.Sample{}, false, fmt.Errorf("row %d: invalid poisoned value %q", row, field)
+			}
+		case r.labelCol:
+			label, err := strconv.Atoi(transient(field))
+			if err != nil {
+				return detect.Sample{}, false, fmt.Errorf("row %d: invalid label %q", row, field)
+			}
+			sample.Label = label
+		default:
+			if i < len(r.categories) && r.categories[i] != "" {
+				value := transient(field)
+				if isMissing(value) {
+					value = ""
+				}
+				sample.Features = r.encoder.Encode(r.categories[i], value, sample.Features)
+				continue
+			}
+			if i >= len(r.isFeature) || !r.isFeature[i] {
+				continue
+			}
+			value, err := parseFeature(field)
+			if err != nil {
+				return detect.Sample{}, false, fmt.Errorf("row %d: column %q: invalid number %q", row, r.header[i], field)
+			}
+			sample.Features = append(sample.Features, value)
+		}
+	}
+	if r.idCol < 0 {
+		sample.ID = "row-" + strconv.Itoa(row)
+	}
+	return sample, poisoned, nil
+}
+
+// parse32 parses the current record like parse, appending its features to
+// features as float32.
+func (r *Reader) parse32(features []float32) (detect.Sample, bool, error) {
+	sample, poisoned, err := r.parse(r.scratch[:0])
+	if err != nil {
+		return detect.Sample{}, false, err
+	}
+	r.scratch = sample.Features
+	for _, v := range sample.Features {
+		features = append(features, float32(v))
+	}
+	sample.Features, sample.Features32 = nil, features
+	return sample, poisoned, nil
+}
+
+// record returns the fields of the current record as strings sharing one
+// allocation.
+func (r *Reader) record() []string {
+	n := 0
+	for _, field := range r.fields {
+		n += len(field)
+	}
+	var b strings.Builder
+	b.Grow(n)
+	for _, field := range r.fields {
+		b.Write(field)
+	}
+	all := b.String()
+	record := make([]string, len(r.fields))
+	for i, field := range r.fields {
+		record[i], all = all[:len(field)], all[len(field):]
+	}
+	return record
+}
+
+// readRecord reads the next record into r.fields, skipping empty lines.
+// Records must have n fields unless n is negative. Errors are reported as
+// by encoding/csv.
+func (r *Reader) readRecord(n int) error {
+	if r.pending {
+		r.pending = false
+		return nil
+	}
+	for {
+		start := r.line + 1
+		var err error
+		if r.raw, err = r.appendLine(r.raw[:0]); err != nil {
+			return err
+		}
+		// A quoted field may span lines; its quotes are balanced once the
+		// record is complete.
+		quoted := bytes.IndexByte(r.raw, '"') >= 0
+		for quoted && bytes.Count(r.raw, []byte{'"'})%2 == 1 {
+			if r.raw, err = r.appendLine(r.raw); err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+		}
+
+		if quoted {
+			err = r.splitQuoted(start)
+		} else {
+			r.splitFields()
+		}
+		if err != nil {
+			return err
+		}
+		if len(r.fields) == 0 {
+			continue
+		}
+		if n >= 0 && len(r.fields) != n {
+			return &csv.ParseError{StartLine: start, Line: start, Column: 1, Err: csv.ErrFieldCount}
+		}
+		r.start = start
+		return nil
+	}
+}
+
+// RecordLines returns the line each sample of CSV data starts on, counting
+// from 1: the lines of its records after the header, or of every record of
+// a file without a header. Empty lines are skipped, and quoted fields may
+// span lines, as when reading samples.
+func RecordLines(r io.Reader) ([]int, error) {
+	rd, err := NewReader(r, Columns{})
+	if err != nil {
+		return nil, err
+	}
+	var lines []int
+	for {
+		if err := rd.readRecord(len(rd.header)); err == io.EOF {
+			return lines, nil
+		} else if err != nil {
+			return nil, err
+		}
+		lines = append(lines, rd.start)
+	}
+}
+
+// headerless reports whether record, the first of a file, is a sample
+// rather than a header: all its fields are numbers. Column numbers counting
+// up from 0 or 1, as pandas writes for unnamed columns, are a header.
+//
+// Only the first record is examined, so any other numeric header, such as
+// a row of years, is read as a sample, and a first sample that happens to
+// count up from 0 or 1 is read as a header. Such files need a header row
+// with a name that is not a number.
+func headerless(record []string) bool {
+	first, err := strconv.Atoi(strings.TrimSpace(record[0]))
+	counting := err == nil && (first == 0 || first == 1)
+	for i, field := range record {
+		field = strings.TrimSpace(field)
+		if _, err := strconv.ParseFloat(field, 64); err != nil {
+			return false
+		}
+		if n, err := strconv.Atoi(field); err != nil || n != first+i {
+			counting = false
+		}
+	}
+	return !counting
+}
+
+// appendLine appends the next line of input to buf, including its line
+// ending.
+func (r *Reader) appendLine(buf []byte) ([]byte, error) {
+	n := len(buf)
+	for {
+		chunk, err := r.in.ReadSlice('\n')
+		buf = append(buf, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && len(buf) > n {
+			err = nil
+		}
+		if err == nil {
+			r.line++
+		}
+		return buf, err
+	}
+}
+
+// splitFields splits an unquoted record into r.fields, leaving them empty
+// for an empty line.
+func (r *Reader) splitFields() {
+	line := bytes.TrimSuffix(r.raw, []byte{'\n'})
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+	r.fields = r.fields[:0]
+	if len(line) == 0 {
+		return
+	}
+	for {
+		i := bytes.IndexByte(line, ',')
+		if i < 0 {
+			r.fields = append(r.fields, bytes.TrimLeftFunc(line, unicode.IsSpace))
+			return
+		}
+		r.fields = append(r.fields, bytes.TrimLeftFunc(line[:i], unicode.IsSpace))
+		line = line[i+1:]
+	}
+}
+
+// splitQuoted parses a quoted record starting at line start with
+// encoding/csv.
+func (r *Reader) splitQuoted(start int) error {
+	cr := csv.NewReader(bytes.NewReader(r.raw))
+	cr.TrimLeadingSpace = true
+	record, err := cr.Read()
+	if err != nil {
+		if pe, ok := err.(*csv.ParseError); ok {
+			pe.StartLine += start - 1
+			pe.Line += start - 1
+		}
+		return err
+	}
+	r.fields = r.fields[:0]
+	for _, field := range record {
+		r.fields = append(r.fields, []byte(field))
+	}
+	return nil
+}
+
+// isMissing reports whether field is a missing value marker.
+func isMissing(field string) bool {
+	if len(field) <= 4 {
+		switch strings.ToLower(field) {
+		case "", "na", "nan", "null":
+			return true
+		}
+	}
+	return false
+}
+
+// parseFeature parses a numeric feature, mapping missing markers to NaN.
+func parseFeature(field []byte) (float64, error) {
+	if isMissing(transient(field)) {
+		return math.NaN(), nil
+	}
+	if v, ok := parseDecimal(field); ok {
+		return v, nil
+	}
+	return strconv.ParseFloat(transient(field), 64)
+}
+
+// pow10 holds the powers of ten that are exact in a float64.
+var pow10 = [...]float64{1e0, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9, 1e10,
+	1e11, 1e12, 1e13, 1e14, 1e15, 1e16, 1e17, 1e18, 1e19, 1e20, 1e21, 1e22}
+
+// parseDecimal parses a plain decimal such as -12.375 with at most 15
+// digits. Its digits and the power of ten dividing them are then exact, so
+// one division rounds correctly and the result equals strconv.ParseFloat's
+// (Clinger's fast path). ok is false for other numbers.
+func parseDecimal(b []byte) (v float64, ok bool) {
+	i, neg := 0, false
+	if len(b) > 0 && (b[0] == '-' || b[0] == '+') {
+		neg, i = b[0] == '-', 1
+	}
+	var mantissa uint64
+	digits, frac, dot := 0, 0, false
+	for ; i < len(b); i++ {
+		switch c := b[i]; {
+		case c >= '0' && c <= '9':
+			mantissa = mantissa*10 + uint64(c-'0')
+			digits++
+			if dot {
+				frac++
+			}
+		case c == '.' && !dot:
+			dot = true
+		default:
+			return 0, false
+		}
+	}
+	if digits == 0 || digits > 15 {
+		return 0, false
+	}
+	v = float64(mantissa) / pow10[frac]
+	if neg {
+		v = -v
+	}
+	return v, true
+}
+
+// transient returns b as a string without copying it, for parsing. The
+// string must not be kept, since b is reused for the next record.
+func transient(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
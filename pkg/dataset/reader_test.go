@@ -0,0 +1,228 @@
+package dataset
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func TestReaderMatchesEncodingCSV(t *testing.T) {
+	inputs := []string{
+		"a,b,label\n1,2,0\n3.5,-4e3,1\n",
+		"a,b,label\r\n1,2,0\r\n\r\n 3 , NA ,1",
+		"id,a,label\n\"x,1\",1,0\n\"multi\nline\",2,1\n\"q\"\"uote\",3,0\n",
+		"a,b,label\n\n\n1,,0\n",
+	}
+	for _, input := range inputs {
+		reference := csv.NewReader(strings.NewReader(input))
+		reference.TrimLeadingSpace = true
+		want, err := reference.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ReadCSV(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("%q: %v", input, err)
+		}
+		if got := append([][]string{data.Header}, data.Records...); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: records %q, want %q", input, got, want)
+		}
+	}
+
+	data, err := ReadCSV(strings.NewReader("id,a,label\n\"x,1\",1.25,1\n"))
+	if err != nil || data.Samples[0].ID != "x,1" || data.Samples[0].Features[0] != 1.25 || data.Samples[0].Label != 1 {
+		t.Errorf("quoted sample = %+v, %v", data, err)
+	}
+
+	for input, want := range map[string]error{
+		"a,label\n1,0\n1,2,0\n":    csv.ErrFieldCount,
+		"a,label\n\"1,0\n":         csv.ErrQuote,
+		"a,label\n1\"2,0\n3,0\"\n": csv.ErrBareQuote,
+	} {
+		if _, err := ReadCSV(strings.NewReader(input)); !errors.Is(err, want) {
+			t.Errorf("%q: error %v, want %v", input, err, want)
+		}
+	}
+	_, err = ReadCSV(strings.NewReader("a,label\n\n1,0\n1,2,0\n"))
+	if pe := (*csv.ParseError)(nil); !errors.As(err, &pe) || pe.Line != 4 {
+		t.Errorf("field count error %v, want one on line 4", err)
+	}
+}
+
+func TestReadCSVWithoutHeader(t *testing.T) {
+	data, err := ReadCSV(strings.NewReader("0.5,-1,1\n2,3e2,0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data.Features, []string{"f0", "f1"}) || len(data.Samples) != 2 {
+		t.Fatalf("features %v, %d samples", data.Features, len(data.Samples))
+	}
+	if s := data.Samples[0]; s.ID != "row-1" || s.Label != 1 || s.Features[0] != 0.5 {
+		t.Errorf("first sample %+v", s)
+	}
+
+	// Column numbers are a header.
+	data, err = ReadCSV(strings.NewReader("0,1,2\n4,5,1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data.Features, []string{"0", "1"}) || len(data.Samples) != 1 {
+		t.Errorf("features %v, %d samples", data.Features, len(data.Samples))
+	}
+
+	// Only the first row is examined, whatever the rows after it hold.
+	for _, test := range []struct {
+		name, input string
+		features    []string
+		samples     int
+	}{
+		{"index header", "0,1\n0.25,1\n", []string{"0"}, 1},
+		{"header counting from 1", " 1, 2 ,3\n0.5,0.7,1\n", []string{"1", "2"}, 1},
+		{"column name", "0,x,2\n4,5,1\n", []string{"0", "x"}, 1},
+		// Known limitations: a numeric header that does not count is read
+		// as a sample, and a counting sample as a header.
+		{"year header", "2019,2020,2021\n0.5,0.7,1\n", []string{"f0", "f1"}, 2},
+		{"counting sample", "0,1,2\n0.5,0.7,1\n", []string{"0", "1"}, 1},
+		{"fractional count", "0,1.0,2\n4,5,1\n", []string{"f0", "f1"}, 2},
+	} {
+		data, err := ReadCSV(strings.NewReader(test.input))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(data.Features, test.features) || len(data.Samples) != test.samples {
+			t.Errorf("%s: features %v, %d samples; want %v, %d", test.name, data.Features, len(data.Samples), test.features, test.samples)
+		}
+	}
+}
+
+func TestRecordLines(t *testing.T) {
+	for _, test := range []struct {
+		name, input string
+		lines       []int
+	}{
+		{"header", "a,label\n1,0\n2,1\n", []int{2, 3}},
+		{"headerless", "1.5,0\n2,1\n", []int{1, 2}},
+		{"empty lines", "a,label\n\n1,0\r\n\r\n2,1", []int{3, 5}},
+		{"multi-line field", "id,note,a,label\nx,\"two\nlines\",1,0\ny,plain,2,1\n", []int{2, 4}},
+		{"header only", "a,label\n", nil},
+	} {
+		lines, err := RecordLines(strings.NewReader(test.input))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(lines, test.lines) {
+			t.Errorf("%s: lines %v, want %v", test.name, lines, test.lines)
+		}
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	data, err := ReadJSON(strings.NewReader(`{"id": "a", "embedding": [0.5, -1], "note": "x, y", "y": 1}
+{"id": "b", "embedding": [2, 3e2], "note": null, "y": 0}
+`), Columns{FeaturesField: "embedding", Label: "y"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data.Features, []string{"f0", "f1"}) || len(data.Samples) != 2 {
+		t.Fatalf("features %v, %d samples", data.Features, len(data.Samples))
+	}
+	want := detect.Sample{ID: "b", Features: []float64{2, 300}}
+	if s := data.Samples[1]; !reflect.DeepEqual(s, want) {
+		t.Errorf("second sample %+v, want %+v", s, want)
+	}
+
+	// Flat objects have a feature per field.
+	data, err = ReadJSON(strings.NewReader(`[{"x": 1, "z": 2, "label": 1}, {"z": 4, "x": 3, "label": 0}]`), Columns{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data.Features, []string{"x", "z"}) || !reflect.DeepEqual(data.Samples[1].Features, []float64{3, 4}) {
+		t.Errorf("features %v, samples %+v", data.Features, data.Samples)
+	}
+
+	if _, err := ReadJSON(strings.NewReader(`{"features": [1, 2], "label": 0}
+{"features": [1], "label": 1}`), Columns{}); err == nil || !strings.Contains(err.Error(), "record 2") {
+		t.Errorf("ragged features: %v", err)
+	}
+}
+
+func TestReaderChunks(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("a,b,label\n")
+	for i := 0; i < 10; i++ {
+		b.WriteString(strconv.Itoa(i) + "," + strconv.Itoa(-i) + ",1\n")
+	}
+	r, err := NewReader(strings.NewReader(b.String()), Columns{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for {
+		samples, err := r.Read(4)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range samples {
+			ids = append(ids, s.ID)
+			if len(s.Features) != 2 || s.Features[1] != -s.Features[0] {
+				t.Errorf("%s: features %v", s.ID, s.Features)
+			}
+		}
+	}
+	if len(ids) != 10 || ids[9] != "row-10" {
+		t.Errorf("read %v, want row-1 to row-10", ids)
+	}
+}
+
+func TestParseDecimalMatchesParseFloat(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	fields := []string{"0", "-0", "+1.5", "1.", ".5", "007", "123456789012345", "0.000000000000001", "1e5", "1_0", "-", ".", "inf"}
+	for i := 0; i < 100000; i++ {
+		v := rng.NormFloat64() * math.Pow(10, float64(rng.Intn(12)-6))
+		fields = append(fields, strconv.FormatFloat(v, 'f', rng.Intn(10), 64))
+	}
+	for _, field := range fields {
+		want, err := strconv.ParseFloat(field, 64)
+		got, ok := parseDecimal([]byte(field))
+		if ok && (err != nil || math.Float64bits(got) != math.Float64bits(want)) {
+			t.Errorf("parseDecimal(%q) = %v, want %v (%v)", field, got, want, err)
+		}
+	}
+}
+
+func TestReaderFloat32(t *testing.T) {
+	const input = "a,b,label\n0.1,2,0\n3,NA,1\n-4e3,5,0\n"
+	data, err := ReadCSVColumns(strings.NewReader(input), Columns{Float32: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := data.Samples[0]; s.Features != nil || !reflect.DeepEqual(s.Features32, []float32{0.1, 2}) {
+		t.Errorf("sample 0 = %v and %v, want float32 features", s.Features, s.Features32)
+	}
+	if v := data.Samples[1].Features32[1]; !math.IsNaN(float64(v)) {
+		t.Errorf("missing value read as %v", v)
+	}
+
+	rd, err := NewReader(strings.NewReader(input), Columns{Float32: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk, err := rd.Read(2)
+	if err != nil || len(chunk) != 2 || !reflect.DeepEqual(chunk[0].Features32, []float32{0.1, 2}) {
+		t.Fatalf("Read = %+v, %v", chunk, err)
+	}
+	if cap(chunk[0].Features32) != 2 {
+		t.Errorf("chunk sample capacity %d, want its own features only", cap(chunk[0].Features32))
+	}
+}
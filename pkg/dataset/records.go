@@ -0,0 +1,217 @@
+package dataset
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Records holds rows of named text fields, such as the prompts and
+// responses of language model datasets, which are read as text rather
+// than as features.
+type Records struct {
+	// Fields names the fields of the rows, in order.
+	Fields []string
+	Rows   [][]string
+}
+
+// IsJSON reports whether path names a dataset of JSON objects, by its
+// .json, .jsonl or .ndjson extension.
+func IsJSON(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".jsonl", ".ndjson":
+		return true
+	}
+	return false
+}
+
+// LoadRecords loads the records of a CSV file, or of a JSON file when
+// IsJSON(path), as ReadRecords and ReadJSONRecords.
+func LoadRecords(path string) (*Records, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records *Records
+	if IsJSON(path) {
+		records, err = ReadJSONRecords(bufio.NewReader(f))
+	} else {
+		records, err = ReadRecords(bufio.NewReader(f))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return records, nil
+}
+
+// ReadRecords reads records from CSV data whose first row is a header.
+func ReadRecords(r io.Reader) (*Records, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("empty file")
+	}
+	if err != nil {
+		return nil, err
+	}
+	records := &Records{Fields: header}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for len(row) < len(header) {
+			row = append(row, "")
+		}
+		records.Rows = append(records.Rows, row[:len(header)])
+	}
+	return records, nil
+}
+
+// ReadJSONRecords reads records from JSON data: an array of objects, or
+// one object per line as in JSON Lines. The fields are the keys of the
+// objects in the order they first appear. Strings are read as they are,
+// nulls as empty fields and other values as compact JSON.
+func ReadJSONRecords(r io.Reader) (*Records, error) {
+	br := bufio.NewReader(r)
+	first, err := firstByte(br)
+	if err != nil {
+		return nil, err
+	}
+
+	records := &Records{}
+	index := make(map[string]int)
+	add := func(raw json.RawMessage, n int) error {
+		if raw = bytes.TrimSpace(raw); len(raw) == 0 {
+			return nil
+		}
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		// Keys are taken in the order they appear, not map order.
+		keys, err := objectKeys(raw)
+		if err != nil {
+			return fmt.Errorf("record %d: %w", n, err)
+		}
+		row := make([]string, len(records.Fields))
+		for _, key := range keys {
+			i, ok := index[key]
+			if !ok {
+				i = len(records.Fields)
+				index[key] = i
+				records.Fields = append(records.Fields, key)
+				row = append(row, "")
+			}
+			row[i] = jsonText(object[key])
+		}
+		records.Rows = append(records.Rows, row)
+		return nil
+	}
+
+	if first == '[' {
+		var objects []json.RawMessage
+		if err := json.NewDecoder(br).Decode(&objects); err != nil {
+			return nil, err
+		}
+		for i, raw := range objects {
+			if err := add(raw, i+1); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		scanner := bufio.NewScanner(br)
+		scanner.Buffer(make([]byte, 0, 1<<20), 64<<20)
+		for n := 1; scanner.Scan(); n++ {
+			if err := add(scanner.Bytes(), n); err != nil {
+				return nil, err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if len(records.Rows) == 0 {
+		return nil, fmt.Errorf("no records")
+	}
+	// Rows read before a field first appeared lack it.
+	for i, row := range records.Rows {
+		for len(row) < len(records.Fields) {
+			row = append(row, "")
+		}
+		records.Rows[i] = row
+	}
+	return records, nil
+}
+
+// firstByte returns the first byte of r that is not white space, leaving
+// it unread.
+func firstByte(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			return 0, fmt.Errorf("empty file")
+		}
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return b, r.UnreadByte()
+		}
+	}
+}
+
+// objectKeys returns the keys of the JSON object raw, in order.
+func objectKeys(raw json.RawMessage) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	var keys []string
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, token.(string))
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// jsonText returns the text of the JSON value raw.
+func jsonText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	if string(raw) == "null" {
+		return ""
+	}
+	var compact bytes.Buffer
+	if json.Compact(&compact, raw) != nil {
+		return string(raw)
+	}
+	return compact.String()
+}
+
+// Column returns the position of the named field, matched ignoring case
+// and surrounding space.
+func (r *Records) Column(name string) (int, error) {
+	return columnIndex(r.Fields, name)
+}
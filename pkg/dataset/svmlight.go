@@ -0,0 +1,136 @@
+package dataset
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// IsSVMLight reports whether path names a dataset in the SVMlight format,
+// by its .svm, .svmlight or .libsvm extension.
+func IsSVMLight(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".svm", ".svmlight", ".libsvm":
+		return true
+	}
+	return false
+}
+
+// LoadSVMLightContext loads a sparse dataset from an SVMlight file like
+// ReadSVMLight, recording a span in the trace of ctx.
+func LoadSVMLightContext(ctx context.Context, path string) (*Dataset, error) {
+	_, span := tracer.Start(ctx, "dataset.LoadSVMLight", trace.WithAttributes(attribute.String("modelpoison.dataset.path", path)))
+	defer span.End()
+
+	f, err := os.Open(path)
+	if err != nil {
+		recordError(span, err)
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := ReadSVMLight(f)
+	if err != nil {
+		recordError(span, err)
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	recordShape(span, data)
+
+	return data, nil
+}
+
+// ReadSVMLight reads a sparse dataset in the SVMlight format, used by
+// LIBSVM and for text features: one sample per line, an integer label
+// followed by index:value pairs in increasing index order, an optional
+// qid:N pair and an optional # comment, which is the sample's ID. Samples
+// hold detect.SparseFeatures with as many features as the largest index
+// plus one. Each line is kept as a record of one field, and the dataset
+// has no header or feature names.
+func ReadSVMLight(r io.Reader) (*Dataset, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), math.MaxInt32)
+	data := &Dataset{truthCol: -1}
+	dim, row := 0, 0
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		body, comment, _ := strings.Cut(text, "#")
+		fields := strings.Fields(body)
+		if len(fields) == 0 {
+			continue
+		}
+		row++
+		sample, err := parseSVMLight(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if k := len(sample.Sparse.Indices); k > 0 {
+			dim = max(dim, sample.Sparse.Indices[k-1]+1)
+		}
+		sample.ID = strings.TrimSpace(comment)
+		if sample.ID == "" {
+			sample.ID = "row-" + strconv.Itoa(row)
+		}
+		data.Samples = append(data.Samples, sample)
+		data.Records = append(data.Records, []string{text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(data.Samples) == 0 {
+		return nil, fmt.Errorf("empty dataset")
+	}
+	for _, sample := range data.Samples {
+		sample.Sparse.Dim = dim
+	}
+	return data, nil
+}
+
+// parseSVMLight parses the label and features of an SVMlight line.
+func parseSVMLight(fields []string) (detect.Sample, error) {
+	label, err := strconv.Atoi(strings.TrimPrefix(fields[0], "+"))
+	if err != nil {
+		v, ferr := strconv.ParseFloat(fields[0], 64)
+		if ferr != nil || v != math.Trunc(v) {
+			return detect.Sample{}, fmt.Errorf("invalid label %q", fields[0])
+		}
+		label = int(v)
+	}
+	sparse := &detect.SparseFeatures{
+		Indices: make([]int, 0, len(fields)-1),
+		Values:  make([]float64, 0, len(fields)-1),
+	}
+	for _, field := range fields[1:] {
+		index, value, ok := strings.Cut(field, ":")
+		if !ok {
+			return detect.Sample{}, fmt.Errorf("invalid feature %q (want index:value)", field)
+		}
+		if index == "qid" {
+			continue
+		}
+		i, err := strconv.Atoi(index)
+		if err != nil || i < 0 {
+			return detect.Sample{}, fmt.Errorf("invalid feature index %q", index)
+		}
+		if k := len(sparse.Indices); k > 0 && i <= sparse.Indices[k-1] {
+			return detect.Sample{}, fmt.Errorf("feature index %d after %d; indices must increase", i, sparse.Indices[k-1])
+		}
+		v, err := parseFeature([]byte(value))
+		if err != nil {
+			return detect.Sample{}, fmt.Errorf("feature %d: invalid number %q", i, value)
+		}
+		sparse.Indices = append(sparse.Indices, i)
+		sparse.Values = append(sparse.Values, v)
+	}
+	return detect.Sample{Label: label, Sparse: sparse}, nil
+}
@@ -0,0 +1,86 @@
+package dataset
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func TestReadSVMLight(t *testing.T) {
+	input := "# exported from LIBSVM\n" +
+		"+1 qid:3 2:0.5 7:-1.25e2 # doc-a\n" +
+		"\n" +
+		"-1 0:1\n" +
+		"2.0 1:3 5:4 #  doc-c \n" +
+		"0\n"
+	data, err := ReadSVMLight(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []detect.Sample{
+		{ID: "doc-a", Label: 1, Sparse: &detect.SparseFeatures{Dim: 8, Indices: []int{2, 7}, Values: []float64{0.5, -125}}},
+		{ID: "row-2", Label: -1, Sparse: &detect.SparseFeatures{Dim: 8, Indices: []int{0}, Values: []float64{1}}},
+		{ID: "doc-c", Label: 2, Sparse: &detect.SparseFeatures{Dim: 8, Indices: []int{1, 5}, Values: []float64{3, 4}}},
+		{ID: "row-4", Label: 0, Sparse: &detect.SparseFeatures{Dim: 8, Indices: []int{}, Values: []float64{}}},
+	}
+	if len(data.Samples) != len(want) {
+		t.Fatalf("read %d samples, want %d", len(data.Samples), len(want))
+	}
+	for i := range want {
+		got := data.Samples[i]
+		if got.ID != want[i].ID || got.Label != want[i].Label || !reflect.DeepEqual(*got.Sparse, *want[i].Sparse) || got.Features != nil {
+			t.Errorf("sample %d = %+v %+v, want %+v %+v", i, got, got.Sparse, want[i], want[i].Sparse)
+		}
+	}
+	if dense := data.Samples[0].Dense(); !reflect.DeepEqual(dense, []float64{0, 0, 0.5, 0, 0, 0, 0, -125}) {
+		t.Errorf("dense features %v", dense)
+	}
+	// Records keep each sample's line, and there is no header.
+	if data.Header != nil || len(data.Records) != 4 || data.Head(2).Records[1][0] != "-1 0:1" {
+		t.Errorf("header %q, records %q", data.Header, data.Records)
+	}
+}
+
+func TestReadSVMLightErrors(t *testing.T) {
+	for _, test := range []struct {
+		name, input, err string
+	}{
+		{"empty", "# only a comment\n\n", "empty dataset"},
+		{"fractional label", "1 1:2\n0.5 1:1\n", `line 2: invalid label "0.5"`},
+		{"named label", "spam 1:1\n", `line 1: invalid label "spam"`},
+		{"bare value", "1 1:2 3\n", `line 1: invalid feature "3" (want index:value)`},
+		{"negative index", "1 -1:2\n", `line 1: invalid feature index "-1"`},
+		{"named index", "1 x:2\n", `line 1: invalid feature index "x"`},
+		{"decreasing indices", "1 4:1 2:1\n", "line 1: feature index 2 after 4; indices must increase"},
+		{"repeated index", "1 4:1 4:2\n", "line 1: feature index 4 after 4; indices must increase"},
+		{"invalid value", "1 1:2\n\n1 3:abc\n", `line 3: feature 3: invalid number "abc"`},
+	} {
+		if _, err := ReadSVMLight(strings.NewReader(test.input)); err == nil || err.Error() != test.err {
+			t.Errorf("%s: error %v, want %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestLoadSVMLight(t *testing.T) {
+	for path, want := range map[string]bool{
+		"train.svm": true, "train.SVMLight": true, "a.libsvm": true,
+		"train.csv": false, "svm": false, "train.svm.gz": false,
+	} {
+		if IsSVMLight(path) != want {
+			t.Errorf("IsSVMLight(%q) = %v", path, !want)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "train.svm")
+	if err := os.WriteFile(path, []byte("1 1:1\n1 1:x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSVMLightContext(context.Background(), path); err == nil || !strings.HasPrefix(err.Error(), path+": line 2: ") {
+		t.Errorf("loading a malformed file: %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+package defend
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// benchSamples returns n samples of 20 normally distributed features,
+// every 97th with a spiked feature.
+func benchSamples(n int) []Sample {
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]Sample, n)
+	for i := range samples {
+		features := make([]float64, 20)
+		for f := range features {
+			features[f] = rng.NormFloat64()
+		}
+		if i%97 == 0 {
+			features[0] = 500
+		}
+		samples[i] = Sample{ID: fmt.Sprintf("s%d", i), Features: features, Label: i % 2}
+	}
+	return samples
+}
+
+func BenchmarkRemovedIndices(b *testing.B) {
+	d := NewDefender()
+	for _, strategy := range d.Strategies() {
+		if !strategy.FiltersSamples() {
+			continue
+		}
+		for _, n := range []int{1000, 10000, 100000} {
+			samples := benchSamples(n)
+			b.Run(fmt.Sprintf("%s/samples=%d", strategy.Name, n), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					d.RemovedIndices(samples, strategy.Name)
+				}
+			})
+		}
+	}
+}
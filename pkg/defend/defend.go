@@ -2,39 +2,64 @@
 package defend
 
 import (
+	"context"
 	"fmt"
 	"math"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
 )
 
-// Sample represents a training sample.
-type Sample struct {
-	ID       string
-	Features []float64
-	Label    int
-	Metadata map[string]interface{}
-}
+var tracer = otel.Tracer("github.com/hallucinaut/modelpoison/pkg/defend")
+
+// Sample represents a training sample. It is the detector's sample, so
+// samples are shared between detection and defense without conversion.
+type Sample = detect.Sample
+
+// MetaSuspicious is the metadata key marking samples a detection strategy
+// found suspicious.
+const MetaSuspicious = "suspicious"
 
 // DefenseStrategy represents a defense strategy.
 type DefenseStrategy struct {
-	Name        string
-	Description string
-	Effectiveness float64
-	Overhead    float64
-	Type        string
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Effectiveness float64 `json:"effectiveness"`
+	Overhead    float64 `json:"overhead"`
+	Type        string  `json:"type"`
+
+	// Parameters and Constraints document how the strategy behaves and
+	// what it needs to be applied.
+	Parameters  []Parameter `json:"parameters,omitempty"`
+	Constraints []string    `json:"constraints,omitempty"`
+	// Plugin names the plugin providing a strategy added by AddStrategy.
+	Plugin string `json:"plugin,omitempty"`
+}
+
+// Parameter describes a fixed parameter of a defense strategy.
+type Parameter struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Value       float64 `json:"value"`
 }
 
 // DefenseResult contains defense results.
 type DefenseResult struct {
-	Success       bool
-	StrategyUsed  string
-	Improvement   float64
-	RiskReduction float64
-	Cost          float64
+	Success       bool    `json:"success"`
+	StrategyUsed  string  `json:"strategy_used"`
+	Improvement   float64 `json:"improvement"`
+	RiskReduction float64 `json:"risk_reduction"`
+	Cost          float64 `json:"cost"`
 }
 
 // Defender applies model poisoning defenses.
 type Defender struct {
 	strategies []DefenseStrategy
+	// external holds the filters of strategies added by AddStrategy.
+	external map[string]ExternalFilter
 }
 
 // NewDefender creates a new poisoning defender.
@@ -47,6 +72,10 @@ func NewDefender() *Defender {
 				Effectiveness: 0.75,
 				Overhead:    0.2,
 				Type:        "preprocessing",
+				Parameters: []Parameter{
+					{Name: "z_threshold", Description: "Remove samples with a feature z-score above this", Value: 3.0},
+				},
+				Constraints: []string{"removes samples from the dataset"},
 			},
 			{
 				Name:        "Robust Aggregation",
@@ -54,6 +83,7 @@ func NewDefender() *Defender {
 				Effectiveness: 0.8,
 				Overhead:    0.15,
 				Type:        "aggregation",
+				Constraints: []string{"applies during training", "requires distributed or federated training"},
 			},
 			{
 				Name:        "Input Filtering",
@@ -61,6 +91,11 @@ func NewDefender() *Defender {
 				Effectiveness: 0.7,
 				Overhead:    0.1,
 				Type:        "filtering",
+				Parameters: []Parameter{
+					{Name: "min_value", Description: "Remove samples with a feature below this", Value: -100},
+					{Name: "max_value", Description: "Remove samples with a feature above this", Value: 100},
+				},
+				Constraints: []string{"removes samples from the dataset", "requires at least one feature"},
 			},
 			{
 				Name:        "Adversarial Training",
@@ -68,6 +103,7 @@ func NewDefender() *Defender {
 				Effectiveness: 0.85,
 				Overhead:    0.4,
 				Type:        "training",
+				Constraints: []string{"applies during training", "requires retraining the model"},
 			},
 			{
 				Name:        "Outlier Detection",
@@ -75,6 +111,10 @@ func NewDefender() *Defender {
 				Effectiveness: 0.65,
 				Overhead:    0.12,
 				Type:        "detection",
+				Parameters: []Parameter{
+					{Name: "z_threshold", Description: "Mark samples with a feature z-score above this", Value: 2.5},
+				},
+				Constraints: []string{"marks samples as suspicious without removing them"},
 			},
 			{
 				Name:        "Ensemble Defense",
@@ -82,6 +122,7 @@ func NewDefender() *Defender {
 				Effectiveness: 0.9,
 				Overhead:    0.5,
 				Type:        "ensemble",
+				Constraints: []string{"applies during training", "requires training multiple models"},
 			},
 		},
 	}
@@ -110,7 +151,33 @@ func (d *Defender) Defend(poisoningRisk float64, strategy string) *DefenseResult
 	}
 }
 
-// ApplyDefense applies defense to dataset.
+// Strategies returns the available defense strategies.
+func (d *Defender) Strategies() []DefenseStrategy {
+	return append([]DefenseStrategy(nil), d.strategies...)
+}
+
+// Strategy returns the defense strategy with the given name.
+func (d *Defender) Strategy(name string) (DefenseStrategy, bool) {
+	for _, strat := range d.strategies {
+		if strat.Name == name {
+			return strat, true
+		}
+	}
+	return DefenseStrategy{}, false
+}
+
+// FiltersSamples reports whether applying the strategy to a dataset removes
+// or marks suspicious samples rather than changing how a model is trained.
+func (s DefenseStrategy) FiltersSamples() bool {
+	switch s.Type {
+	case "preprocessing", "filtering", "detection":
+		return true
+	}
+	return false
+}
+
+// ApplyDefense applies defense to dataset. samples and their metadata are
+// not modified; the returned samples may share their features.
 func (d *Defender) ApplyDefense(samples []Sample, strategy string) []Sample {
 	for _, strat := range d.strategies {
 		if strat.Name == strategy {
@@ -121,8 +188,46 @@ func (d *Defender) ApplyDefense(samples []Sample, strategy string) []Sample {
 	return samples
 }
 
+// RemovedIndices applies a filtering strategy to samples and returns the indices of the samples it removes or marks as suspicious.
+// Filtering strategies preserve sample order, so kept samples are matched
+// to the input positionally.
+func (d *Defender) RemovedIndices(samples []Sample, strategy string) []int {
+	return d.RemovedIndicesContext(context.Background(), samples, strategy)
+}
+
+// RemovedIndicesContext applies a filtering strategy like RemovedIndices,
+// recording a span in the trace of ctx.
+func (d *Defender) RemovedIndicesContext(ctx context.Context, samples []Sample, strategy string) []int {
+	_, span := tracer.Start(ctx, "defend.RemovedIndices", trace.WithAttributes(
+		attribute.String("modelpoison.strategy", strategy),
+		attribute.Int("modelpoison.samples", len(samples)),
+	))
+	defer span.End()
+
+	kept := d.ApplyDefense(samples, strategy)
+
+	var removed []int
+	j := 0
+	for i := range samples {
+		if j < len(kept) && kept[j].ID == samples[i].ID {
+			if suspicious, _ := kept[j].Metadata[MetaSuspicious].(bool); suspicious {
+				removed = append(removed, i)
+			}
+			j++
+			continue
+		}
+		removed = append(removed, i)
+	}
+	span.SetAttributes(attribute.Int("modelpoison.removed", len(removed)))
+
+	return removed
+}
+
 // applyStrategy applies a specific defense strategy.
 func (d *Defender) applyStrategy(samples []Sample, strategy DefenseStrategy) []Sample {
+	if filter, ok := d.external[strategy.Name]; ok {
+		return applyExternal(samples, filter)
+	}
 	switch strategy.Type {
 	case "preprocessing":
 		return d.cleanData(samples)
@@ -162,16 +267,19 @@ func (d *Defender) filterInputs(samples []Sample) []Sample {
 	return filtered
 }
 
-// detectOutliers detects and marks outliers.
+// detectOutliers detects and marks outliers in a copy of samples.
 func (d *Defender) detectOutliers(samples []Sample) []Sample {
+	marked := make([]Sample, len(samples))
+	copy(marked, samples)
+
 	// Mark suspicious samples
-	for i := range samples {
-		if d.isOutlier(samples[i]) {
-			samples[i].Metadata["suspicious"] = true
+	for i := range marked {
+		if d.isOutlier(marked[i]) {
+			marked[i] = marked[i].WithMeta(MetaSuspicious, true)
 		}
 	}
 
-	return samples
+	return marked
 }
 
 // isSuspicious checks if sample is suspicious.
@@ -224,32 +332,37 @@ func (d *Defender) isOutlier(sample Sample) bool {
 	return false
 }
 
-// calculateMean calculates mean of features.
+// calculateMean calculates mean of the finite features, so a NaN or
+// infinite feature does not hide the outliers among the others.
 func (d *Defender) calculateMean(features []float64) float64 {
-	if len(features) == 0 {
-		return 0
-	}
-
-	sum := 0.0
+	sum, n := 0.0, 0
 	for _, f := range features {
-		sum += f
+		if !math.IsNaN(f) && !math.IsInf(f, 0) {
+			sum += f
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
 	}
 
-	return sum / float64(len(features))
+	return sum / float64(n)
 }
 
-// calculateStdDev calculates standard deviation.
+// calculateStdDev calculates standard deviation of the finite features.
 func (d *Defender) calculateStdDev(features []float64, mean float64) float64 {
-	if len(features) == 0 {
-		return 0
-	}
-
-	sum := 0.0
+	sum, n := 0.0, 0
 	for _, f := range features {
-		sum += (f - mean) * (f - mean)
+		if !math.IsNaN(f) && !math.IsInf(f, 0) {
+			sum += (f - mean) * (f - mean)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
 	}
 
-	return math.Sqrt(sum / float64(len(features)))
+	return math.Sqrt(sum / float64(n))
 }
 
 // RecommendDefense recommends best defense strategy.
@@ -0,0 +1,29 @@
+package defend
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectOutliersDoesNotMutate(t *testing.T) {
+	// Samples built without metadata used to panic when marked.
+	samples := benchSamples(300)
+	shared := map[string]interface{}{"source": "a"}
+	samples[1].Metadata = shared
+	before := make([]Sample, len(samples))
+	for i, sample := range samples {
+		before[i] = sample.Clone()
+	}
+
+	d := NewDefender()
+	removed := d.RemovedIndices(samples, "Outlier Detection")
+	if len(removed) == 0 || removed[0] != 0 {
+		t.Fatalf("removed = %v, want the spiked samples", removed)
+	}
+	if !reflect.DeepEqual(samples, before) {
+		t.Errorf("RemovedIndices modified its input")
+	}
+	if !reflect.DeepEqual(shared, map[string]interface{}{"source": "a"}) {
+		t.Errorf("shared metadata = %v, want it unchanged", shared)
+	}
+}
@@ -0,0 +1,50 @@
+package defend
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ExternalFilter removes samples for a strategy implemented outside the
+// defender, such as a defense shipped as a plugin.
+type ExternalFilter interface {
+	// Remove returns the indices of the samples to remove. Filters that
+	// cannot filter samples remove none and report the failure by their
+	// own means.
+	Remove(samples []Sample) []int
+}
+
+// AddStrategy adds a strategy implemented by filter. The strategy must
+// filter samples, and its name must not be taken.
+func (d *Defender) AddStrategy(strategy DefenseStrategy, filter ExternalFilter) error {
+	if strategy.Name == "" {
+		return errors.New("defense strategy has no name")
+	}
+	if !strategy.FiltersSamples() {
+		return fmt.Errorf("strategy %s: type %q does not filter samples", strategy.Name, strategy.Type)
+	}
+	if _, ok := d.Strategy(strategy.Name); ok {
+		return fmt.Errorf("strategy %s is already registered", strategy.Name)
+	}
+	if d.external == nil {
+		d.external = make(map[string]ExternalFilter)
+	}
+	d.strategies = append(d.strategies, strategy)
+	d.external[strategy.Name] = filter
+	return nil
+}
+
+// applyExternal returns the samples filter keeps.
+func applyExternal(samples []Sample, filter ExternalFilter) []Sample {
+	removed := make(map[int]bool)
+	for _, i := range filter.Remove(samples) {
+		removed[i] = true
+	}
+	kept := make([]Sample, 0, len(samples)-len(removed))
+	for i, sample := range samples {
+		if !removed[i] {
+			kept = append(kept, sample)
+		}
+	}
+	return kept
+}
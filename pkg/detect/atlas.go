@@ -0,0 +1,107 @@
+package detect
+
+import "sort"
+
+// Technique is a MITRE ATLAS technique: an adversary behavior against
+// machine learning systems, identified as AML.Tnnnn or AML.Tnnnn.nnn for
+// sub-techniques. See https://atlas.mitre.org.
+type Technique struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Tactics lists the ATLAS tactics the technique serves.
+	Tactics []string `json:"tactics"`
+}
+
+// atlasTechniques holds the ATLAS techniques poison types map to, by ID.
+var atlasTechniques = map[string]Technique{
+	"AML.T0010.002": {ID: "AML.T0010.002", Name: "ML Supply Chain Compromise: Data", Tactics: []string{"Initial Access"}},
+	"AML.T0018":     {ID: "AML.T0018", Name: "Backdoor ML Model", Tactics: []string{"Persistence", "ML Attack Staging"}},
+	"AML.T0019":     {ID: "AML.T0019", Name: "Publish Poisoned Datasets", Tactics: []string{"Resource Development"}},
+	"AML.T0020":     {ID: "AML.T0020", Name: "Poison Training Data", Tactics: []string{"Resource Development", "Persistence"}},
+	"AML.T0031":     {ID: "AML.T0031", Name: "Erode ML Model Integrity", Tactics: []string{"Impact"}},
+	"AML.T0043":     {ID: "AML.T0043", Name: "Craft Adversarial Data", Tactics: []string{"ML Attack Staging"}},
+}
+
+// atlasMapping maps each poison type to the IDs of the ATLAS techniques it
+// is evidence of. Findings share these slices.
+var atlasMapping = map[PoisonType][]string{
+	TypeBackdoor:       {"AML.T0020", "AML.T0018"},
+	TypeLabelFlip:      {"AML.T0020", "AML.T0031"},
+	TypeGradientPoison: {"AML.T0020", "AML.T0043"},
+	TypeFeaturePoison:  {"AML.T0020", "AML.T0043"},
+	TypeDataPoison:     {"AML.T0020", "AML.T0019", "AML.T0010.002"},
+}
+
+// PoisonTypes returns every poison type, those with a built-in check
+// first, in the order they are scored.
+func PoisonTypes() []PoisonType {
+	return append(Checks(), TypeDataPoison)
+}
+
+// ATLASIDs returns the IDs of the ATLAS techniques findings of type t are
+// evidence of, or nil for unknown types.
+func ATLASIDs(t PoisonType) []string {
+	return append([]string(nil), atlasMapping[t]...)
+}
+
+// ATLASTechniques returns the ATLAS techniques findings of type t are
+// evidence of.
+func ATLASTechniques(t PoisonType) []Technique {
+	var techniques []Technique
+	for _, id := range atlasMapping[t] {
+		techniques = append(techniques, atlasTechniques[id])
+	}
+	return techniques
+}
+
+// TechniqueCoverage reports how an ATLAS technique is covered: the poison
+// types that are evidence of it, whether a built-in check detects one of
+// them, and the findings of a scan that are evidence of it.
+type TechniqueCoverage struct {
+	Technique
+	Types    []PoisonType `json:"types"`
+	Detected bool         `json:"detected"`
+	Findings int          `json:"findings"`
+}
+
+// ATLASCoverage returns the coverage of each ATLAS technique poison types
+// map to, in ID order, counting the flagged samples of result when it is
+// not nil. Findings are counted by type, so results saved before findings
+// were tagged are counted too.
+func ATLASCoverage(result *DetectionResult) []TechniqueCoverage {
+	ids := make([]string, 0, len(atlasTechniques))
+	for id := range atlasTechniques {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	findings := make(map[PoisonType]int)
+	if result != nil {
+		for _, sample := range result.Samples {
+			if sample.IsPoisoned {
+				findings[sample.Type]++
+			}
+		}
+	}
+	checked := make(map[PoisonType]bool, len(checks))
+	for _, t := range checks {
+		checked[t] = true
+	}
+
+	coverage := make([]TechniqueCoverage, 0, len(ids))
+	for _, id := range ids {
+		c := TechniqueCoverage{Technique: atlasTechniques[id]}
+		for _, t := range PoisonTypes() {
+			for _, mapped := range atlasMapping[t] {
+				if mapped != id {
+					continue
+				}
+				c.Types = append(c.Types, t)
+				c.Detected = c.Detected || checked[t]
+				c.Findings += findings[t]
+			}
+		}
+		coverage = append(coverage, c)
+	}
+	return coverage
+}
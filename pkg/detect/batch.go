@@ -0,0 +1,140 @@
+package detect
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DetectBatch scans rows of features, such as a batch of embeddings, with
+// labels[i] the label of row i, for pipelines that call the library
+// directly rather than building Samples. labels may be nil when rows are
+// unlabeled. Rows are scored in chunks by the detector's workers, sharing
+// each row's feature moments between the checks and allocating only for
+// flagged rows, so the result holds the flagged rows only, identified by
+// their row index, with LabelCounts counting every row like a Stream.
+// Findings and the risk score are those Detect returns for the same rows.
+// Rows are read, not kept; the caller may reuse them once DetectBatch
+// returns.
+func (d *Detector) DetectBatch(features [][]float64, labels []int) (*DetectionResult, error) {
+	if labels != nil && len(labels) != len(features) {
+		return nil, fmt.Errorf("%d labels for %d rows", len(labels), len(features))
+	}
+	if err := d.validateBatch(features); err != nil {
+		return nil, err
+	}
+
+	type part struct {
+		start    int
+		findings []PoisonedSample
+	}
+	var (
+		parts []part
+		mu    sync.Mutex
+	)
+	d.forChunks(len(features), nil, d.progress, func(start, end int, _ map[PoisonType]time.Duration) {
+		var flagged []PoisonedSample
+		if len(d.external) > 0 || d.lists != nil {
+			flagged = d.finishRows(features, labels, start, end)
+		} else {
+			for i := start; i < end; i++ {
+				sample := Sample{Features: features[i]}
+				if labels != nil {
+					sample.Label = labels[i]
+				}
+				if finding := d.analyzeSample(sample, nil); finding.IsPoisoned {
+					finding.ID = strconv.Itoa(i)
+					flagged = append(flagged, finding)
+				}
+			}
+		}
+		if len(flagged) > 0 {
+			mu.Lock()
+			parts = append(parts, part{start: start, findings: flagged})
+			mu.Unlock()
+		}
+	})
+	sort.Slice(parts, func(i, j int) bool { return parts[i].start < parts[j].start })
+
+	var findings []PoisonedSample
+	for _, p := range parts {
+		findings = append(findings, p.findings...)
+	}
+	result := d.Summarize(findings, len(features))
+	result.FlaggedOnly = true
+	result.LabelCounts = make(map[int]int)
+	if labels == nil {
+		if len(features) > 0 {
+			result.LabelCounts[0] = len(features)
+		}
+	} else {
+		for _, label := range labels {
+			result.LabelCounts[label]++
+		}
+	}
+	result.Classes = ClassBreakdown(result)
+	return result, nil
+}
+
+// finishRows analyzes rows start to end like DetectBatch, completing
+// their findings with the external checks and lists, which take whole
+// chunks of samples, and returns the flagged ones.
+func (d *Detector) finishRows(features [][]float64, labels []int, start, end int) []PoisonedSample {
+	samples := make([]Sample, end-start)
+	findings := make([]PoisonedSample, end-start)
+	for i := range samples {
+		samples[i] = Sample{ID: strconv.Itoa(start + i), Features: features[start+i]}
+		if labels != nil {
+			samples[i].Label = labels[start+i]
+		}
+		findings[i] = d.analyzeSample(samples[i], nil)
+	}
+	d.finish(samples, findings)
+	var flagged []PoisonedSample
+	for _, finding := range findings {
+		if finding.IsPoisoned {
+			flagged = append(flagged, finding)
+		}
+	}
+	return flagged
+}
+
+// ScoreBatch returns the raw score of each check for each row of features,
+// before thresholds are applied, like ScoreSample: the scores of row i are
+// at scores[i*len(Checks()):], in the order of Checks. The scores are
+// appended to dst, which may be reused between batches to avoid
+// allocating. Non-finite features are not scored.
+func (d *Detector) ScoreBatch(features [][]float64, dst []float64) []float64 {
+	offset := len(dst)
+	total := offset + len(features)*len(checks)
+	if cap(dst) < total {
+		grown := make([]float64, offset, total)
+		copy(grown, dst)
+		dst = grown
+	}
+	dst = dst[:total]
+	d.forChunks(len(features), nil, nil, func(start, end int, _ map[PoisonType]time.Duration) {
+		for i := start; i < end; i++ {
+			sample, _ := finiteFeatures(Sample{Features: features[i]})
+			scores := d.scoreChecks(sample, nil)
+			copy(dst[offset+i*len(checks):], scores[:])
+		}
+	})
+	return dst
+}
+
+// validateBatch checks rows of features like Validate checks samples,
+// naming offending rows by index.
+func (d *Detector) validateBatch(features [][]float64) error {
+	if d.NonFinite() != NonFiniteError {
+		return nil
+	}
+	for i, row := range features {
+		if _, n := finiteFeatures(Sample{Features: row}); n > 0 {
+			return d.Validate([]Sample{{ID: strconv.Itoa(i), Features: row}})
+		}
+	}
+	return nil
+}
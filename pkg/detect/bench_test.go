@@ -0,0 +1,101 @@
+package detect
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// benchSizes are the dataset sizes benchmarks run at.
+var benchSizes = []int{1000, 10000, 100000}
+
+// benchSamples returns n samples of width normally distributed features,
+// every 97th with a spiked feature.
+func benchSamples(n, width int) []Sample {
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]Sample, n)
+	for i := range samples {
+		features := make([]float64, width)
+		for f := range features {
+			features[f] = rng.NormFloat64()
+		}
+		if i%97 == 0 {
+			features[0] = 500
+		}
+		samples[i] = Sample{ID: fmt.Sprintf("s%d", i), Features: features, Label: i % 2}
+	}
+	return samples
+}
+
+func BenchmarkDetect(b *testing.B) {
+	for _, n := range benchSizes {
+		samples := benchSamples(n, 20)
+		b.Run(fmt.Sprintf("samples=%d", n), func(b *testing.B) {
+			d := NewDetector()
+			d.SetWorkers(1)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				d.Detect(samples)
+			}
+		})
+	}
+}
+
+func BenchmarkDetectParallel(b *testing.B) {
+	samples := benchSamples(100000, 20)
+	d := NewDetector()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		d.Detect(samples)
+	}
+}
+
+func BenchmarkCheck(b *testing.B) {
+	for _, width := range []int{20, 512} {
+		samples := benchSamples(1000, width)
+		for _, t := range Checks() {
+			b.Run(fmt.Sprintf("%s/features=%d", t, width), func(b *testing.B) {
+				d := NewDetector()
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := d.ScoreCheck(t, samples[i%len(samples)]); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkStream(b *testing.B) {
+	samples := benchSamples(100000, 20)
+	d := NewDetector()
+	d.SetWorkers(1)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		stream := d.NewStream()
+		for start := 0; start < len(samples); start += 10000 {
+			if err := stream.Add(context.Background(), samples[start:start+10000]); err != nil {
+				b.Fatal(err)
+			}
+		}
+		stream.Result()
+	}
+}
+
+func BenchmarkDetectBatch(b *testing.B) {
+	samples := benchSamples(100000, 20)
+	features := make([][]float64, len(samples))
+	labels := make([]int, len(samples))
+	for i, sample := range samples {
+		features[i], labels[i] = sample.Features, sample.Label
+	}
+	d := NewDetector()
+	d.SetWorkers(1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.DetectBatch(features, labels)
+	}
+}
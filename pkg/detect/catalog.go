@@ -0,0 +1,86 @@
+package detect
+
+// Parameter describes a tunable parameter of a check.
+type Parameter struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Value       float64 `json:"value"`
+	Min         float64 `json:"min"`
+	Max         float64 `json:"max"`
+}
+
+// CheckInfo describes a detection check.
+type CheckInfo struct {
+	// Name identifies external checks, and Plugin names the plugin
+	// providing them and its version; built-in checks are identified by
+	// their Type.
+	Name   string `json:"name,omitempty"`
+	Plugin string `json:"plugin,omitempty"`
+
+	Type        PoisonType  `json:"type"`
+	Description string      `json:"description"`
+	Method      string      `json:"method"`
+	Parameters  []Parameter `json:"parameters"`
+	// DataTypes lists the kinds of data the check applies to.
+	DataTypes []string `json:"data_types"`
+	// RequiresModel and RequiresActivations report whether the check needs
+	// access to the trained model or its internal activations.
+	RequiresModel       bool `json:"requires_model"`
+	RequiresActivations bool `json:"requires_activations"`
+	// ATLAS lists the MITRE ATLAS techniques the check's findings are
+	// evidence of.
+	ATLAS []Technique `json:"atlas"`
+}
+
+// checkDescriptions describes each check in Checks.
+var checkDescriptions = map[PoisonType]CheckInfo{
+	TypeBackdoor: {
+		Description: "Flags samples with trigger-like feature values far from the expected value",
+		Method:      "per-feature deviation count",
+	},
+	TypeLabelFlip: {
+		Description: "Flags samples whose label is unlikely given their features",
+		Method:      "label likelihood",
+	},
+	TypeGradientPoison: {
+		Description: "Flags samples with a high ratio of outlier features",
+		Method:      "within-sample outlier ratio",
+	},
+	TypeFeaturePoison: {
+		Description: "Flags samples with an extreme feature z-score",
+		Method:      "within-sample maximum z-score",
+	},
+}
+
+// Describe returns the built-in checks with the detector's current
+// parameter values.
+func (d *Detector) Describe() []CheckInfo {
+	var checks []CheckInfo
+	for _, t := range Checks() {
+		info := checkDescriptions[t]
+		info.Type = t
+		info.DataTypes = []string{"tabular"}
+		info.ATLAS = ATLASTechniques(t)
+		info.Parameters = []Parameter{{
+			Name:        "threshold",
+			Description: "Score above which a sample is flagged",
+			Value:       d.thresholds[t],
+			Min:         0,
+			Max:         1,
+		}}
+		checks = append(checks, info)
+	}
+	for _, c := range d.external {
+		info := c.Info()
+		info.ATLAS = ATLASTechniques(info.Type)
+		info.Parameters = append([]Parameter{{
+			Name:        "threshold",
+			Description: "Score above which a sample is flagged",
+			Value:       c.Threshold(),
+			Min:         0,
+			Max:         1,
+		}}, info.Parameters...)
+		checks = append(checks, info)
+	}
+	return checks
+}
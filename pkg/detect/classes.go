@@ -0,0 +1,79 @@
+package detect
+
+import "sort"
+
+// ClassRisk aggregates the findings of a scan for the samples of one
+// class, to show where poisoned samples concentrate.
+type ClassRisk struct {
+	Label   int `json:"label"`
+	Samples int `json:"samples"`
+	Flagged int `json:"flagged"`
+	// FlaggedRate is the fraction of the class's samples that were flagged,
+	// and Share the fraction of all flagged samples that are in the class.
+	FlaggedRate float64 `json:"flagged_rate"`
+	Share       float64 `json:"share"`
+	// MeanScore is the mean score of the class's flagged samples.
+	MeanScore float64 `json:"mean_score"`
+	// DominantType is the most common poison type of the class's flagged
+	// samples, ties going to the type scored first.
+	DominantType PoisonType `json:"dominant_type,omitempty"`
+}
+
+// ClassBreakdown returns the per-class aggregates of result, in label
+// order. Classes are counted from LabelCounts when the result has them,
+// as results holding only flagged samples do, and otherwise from Samples.
+func ClassBreakdown(result *DetectionResult) []ClassRisk {
+	type tally struct {
+		ClassRisk
+		score float64
+		types map[PoisonType]int
+	}
+	classes := make(map[int]*tally)
+	class := func(label int) *tally {
+		c, ok := classes[label]
+		if !ok {
+			c = &tally{ClassRisk: ClassRisk{Label: label}, types: make(map[PoisonType]int)}
+			classes[label] = c
+		}
+		return c
+	}
+
+	flagged := 0
+	for _, sample := range result.Samples {
+		c := class(sample.Label)
+		if result.LabelCounts == nil {
+			c.Samples++
+		}
+		if sample.IsPoisoned {
+			flagged++
+			c.Flagged++
+			c.score += sample.Score
+			c.types[sample.Type]++
+		}
+	}
+	for label, n := range result.LabelCounts {
+		class(label).Samples = n
+	}
+	if len(classes) == 0 {
+		return nil
+	}
+
+	breakdown := make([]ClassRisk, 0, len(classes))
+	for _, c := range classes {
+		if c.Samples > 0 {
+			c.FlaggedRate = float64(c.Flagged) / float64(c.Samples)
+		}
+		if c.Flagged > 0 {
+			c.Share = float64(c.Flagged) / float64(flagged)
+			c.MeanScore = c.score / float64(c.Flagged)
+		}
+		for _, t := range PoisonTypes() {
+			if c.types[t] > c.types[c.DominantType] {
+				c.DominantType = t
+			}
+		}
+		breakdown = append(breakdown, c.ClassRisk)
+	}
+	sort.Slice(breakdown, func(i, j int) bool { return breakdown[i].Label < breakdown[j].Label })
+	return breakdown
+}
@@ -2,10 +2,19 @@
 package detect
 
 import (
+	"context"
 	"fmt"
 	"math"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/hallucinaut/modelpoison/pkg/detect")
+
 // PoisonType represents type of poisoning attack.
 type PoisonType string
 
@@ -19,28 +28,81 @@ const (
 
 // PoisonedSample represents a potentially poisoned sample.
 type PoisonedSample struct {
-	ID           string
-	IsPoisoned   bool
-	Score        float64
-	Type         PoisonType
-	Description  string
-	Evidence     string
-	Confidence   float64
+	ID          string     `json:"id"`
+	Label       int        `json:"label"`
+	IsPoisoned  bool       `json:"is_poisoned"`
+	Score       float64    `json:"score"`
+	Type        PoisonType `json:"type,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Evidence    string     `json:"evidence,omitempty"`
+	Confidence  float64    `json:"confidence"`
+	// ATLAS lists the IDs of the MITRE ATLAS techniques the finding is
+	// evidence of. Findings of one type share the slice.
+	ATLAS []string `json:"atlas,omitempty"`
+
+	// Review holds a human review decision merged in after detection.
+	Review     string `json:"review,omitempty"`
+	ReviewNote string `json:"review_note,omitempty"`
+
+	// Hash is the SampleHash of the sample, recorded by incremental scans.
+	Hash string `json:"hash,omitempty"`
+	// List is ListAllowed or ListDenied when the detector's Lists
+	// overrode the finding.
+	List string `json:"list,omitempty"`
+	// Origin is the recorded provenance of a flagged sample, when the
+	// dataset came with provenance metadata.
+	Origin *Origin `json:"origin,omitempty"`
 }
 
 // DetectionResult contains poisoning detection results.
 type DetectionResult struct {
-	IsPoisoned    bool
-	SampleCount   int
-	PoisonedCount int
-	Samples       []PoisonedSample
-	RiskScore     float64
-	Method        string
+	IsPoisoned    bool             `json:"is_poisoned"`
+	SampleCount   int              `json:"sample_count"`
+	PoisonedCount int              `json:"poisoned_count"`
+	// Samples holds a finding per sample, in the order the samples were
+	// scanned whatever the number of workers.
+	Samples       []PoisonedSample `json:"samples"`
+	RiskScore     float64          `json:"risk_score"`
+	Method        string           `json:"method"`
+
+	// Partial is set when only part of the dataset was scanned.
+	Partial *PartialScan `json:"partial,omitempty"`
+	// Filter is set when the samples were narrowed by a Filter.
+	Filter *FilterSummary `json:"filter,omitempty"`
+	// Version identifies the scanned revision of a versioned dataset.
+	Version *DatasetVersion `json:"dataset_version,omitempty"`
+
+	// FlaggedOnly is set when Samples holds only the flagged samples, as
+	// for results of a Stream. LabelCounts then holds the number of
+	// scanned samples of each label.
+	FlaggedOnly bool        `json:"flagged_only,omitempty"`
+	LabelCounts map[int]int `json:"label_counts,omitempty"`
+	// Incremental is set for results of DetectIncremental, which record the
+	// hash of each sample.
+	Incremental *IncrementalScan `json:"incremental,omitempty"`
+	// Risk records the model and inputs of RiskScore.
+	Risk *RiskAssessment `json:"risk,omitempty"`
+	// Shard is set for results of a ShardStream, which cover only some
+	// shards of the dataset.
+	Shard *ShardScan `json:"shard,omitempty"`
+	// Classes aggregates the findings of each class, as ClassBreakdown.
+	Classes []ClassRisk `json:"classes,omitempty"`
 }
 
+// ProgressFunc is called as samples are analyzed with the number of samples
+// processed so far and the total number of samples, or zero when the total
+// is not known, as for a Stream.
+type ProgressFunc func(processed, total int)
+
 // Detector detects model poisoning attacks.
 type Detector struct {
 	thresholds map[PoisonType]float64
+	progress   ProgressFunc
+	workers    int
+	nonFinite  NonFinite
+	risk       RiskModel
+	lists      Lists
+	external   []ExternalCheck
 }
 
 // NewDetector creates a new poisoning detector.
@@ -53,19 +115,62 @@ func NewDetector() *Detector {
 			TypeFeaturePoison:  0.7,
 			TypeDataPoison:     0.65,
 		},
+		risk: DefaultRiskModel(),
+	}
+}
+
+// Thresholds returns a copy of the per-type detection thresholds.
+func (d *Detector) Thresholds() map[PoisonType]float64 {
+	thresholds := make(map[PoisonType]float64, len(d.thresholds))
+	for t, v := range d.thresholds {
+		thresholds[t] = v
 	}
+	return thresholds
+}
+
+// SetThreshold sets the detection threshold for a poison type. Samples are
+// flagged when their score for the type exceeds the threshold.
+func (d *Detector) SetThreshold(t PoisonType, threshold float64) error {
+	if _, ok := d.thresholds[t]; !ok {
+		return fmt.Errorf("unknown poison type %q", t)
+	}
+	if threshold < 0 || threshold > 1 {
+		return fmt.Errorf("threshold for %s must be between 0 and 1, got %v", t, threshold)
+	}
+	d.thresholds[t] = threshold
+	return nil
+}
+
+// SetProgress registers fn to be called after each analyzed sample, or
+// after each chunk of samples when several workers analyze them. Calls are
+// never concurrent. Passing nil disables progress reporting.
+func (d *Detector) SetProgress(fn ProgressFunc) {
+	d.progress = fn
 }
 
 // Detect analyzes training data for poisoning.
 func (d *Detector) Detect(samples []Sample) *DetectionResult {
+	return d.DetectContext(context.Background(), samples)
+}
+
+// DetectContext analyzes training data like Detect, recording a span in
+// the trace of ctx. When the span is sampled, it carries the time spent in
+// each check, summed over workers, so slow checks can be identified.
+func (d *Detector) DetectContext(ctx context.Context, samples []Sample) *DetectionResult {
+	_, span := tracer.Start(ctx, "detect.Detect", trace.WithAttributes(attribute.Int("modelpoison.samples", len(samples))))
+	defer span.End()
+
+	var timings map[PoisonType]time.Duration
+	if span.IsRecording() {
+		timings = make(map[PoisonType]time.Duration)
+	}
+
 	result := &DetectionResult{
 		Method: "ensemble_detection",
 	}
 
-	for _, sample := range samples {
-		poisoned := d.analyzeSample(sample)
-		result.Samples = append(result.Samples, poisoned)
-
+	result.Samples = d.analyzeAll(samples, timings, d.progress)
+	for _, poisoned := range result.Samples {
 		if poisoned.IsPoisoned {
 			result.PoisonedCount++
 		}
@@ -75,28 +180,170 @@ func (d *Detector) Detect(samples []Sample) *DetectionResult {
 	result.IsPoisoned = result.PoisonedCount > 0
 
 	// Calculate risk score
-	result.RiskScore = d.calculateRiskScore(result)
+	result.RiskScore, result.Risk = d.risk.Assess(result.Samples, result.SampleCount)
+	result.Classes = ClassBreakdown(result)
+
+	span.SetAttributes(
+		attribute.Int("modelpoison.poisoned", result.PoisonedCount),
+		attribute.Float64("modelpoison.risk_score", result.RiskScore),
+	)
+	for t, elapsed := range timings {
+		span.SetAttributes(attribute.Float64("modelpoison.check."+string(t)+".seconds", elapsed.Seconds()))
+	}
 
 	return result
 }
 
-// Sample represents a training sample.
+// Analyze analyzes a single sample, for callers that receive samples
+// incrementally. Summarize combines the findings into a result.
+func (d *Detector) Analyze(sample Sample) PoisonedSample {
+	findings := []PoisonedSample{d.analyzeSample(sample, nil)}
+	d.finish([]Sample{sample}, findings)
+	return findings[0]
+}
+
+// Summarize builds a detection result for sampleCount analyzed samples
+// from findings returned by Analyze. Findings for unflagged samples may be
+// omitted; they do not contribute to the risk score.
+func (d *Detector) Summarize(findings []PoisonedSample, sampleCount int) *DetectionResult {
+	result := &DetectionResult{
+		Method:      "ensemble_detection",
+		SampleCount: sampleCount,
+		Samples:     findings,
+	}
+	for _, finding := range findings {
+		if finding.IsPoisoned {
+			result.PoisonedCount++
+		}
+	}
+	result.IsPoisoned = result.PoisonedCount > 0
+	result.RiskScore, result.Risk = d.risk.Assess(result.Samples, result.SampleCount)
+	result.Classes = ClassBreakdown(result)
+
+	return result
+}
+
+// Sample represents a training sample. Samples are values that may share
+// their features and metadata with copies; NewSample and Clone make
+// independent samples, and WithMeta sets metadata without changing the
+// samples sharing it. Sparse samples hold their features in Sparse, and
+// float32 samples in Features32, rather than Features.
 type Sample struct {
-	ID       string
-	Features []float64
-	Label    int
-	Metadata map[string]interface{}
+	ID         string
+	Features   []float64
+	Features32 []float32
+	Sparse     *SparseFeatures
+	Label      int
+	Metadata   map[string]interface{}
+}
+
+// checks lists the poison types that have a built-in check, in the order
+// they are scored.
+var checks = [...]PoisonType{TypeBackdoor, TypeLabelFlip, TypeGradientPoison, TypeFeaturePoison}
+
+// Checks returns the poison types that have a built-in check.
+func Checks() []PoisonType {
+	list := checks
+	return list[:]
+}
+
+// sampleMoments holds the mean and standard deviation of the features of a
+// sample, which several checks share.
+type sampleMoments struct {
+	mean, stdDev float64
+}
+
+// momentsOf computes the feature moments of sample.
+func momentsOf(sample Sample) sampleMoments {
+	mean, stdDev := featureMoments(sample)
+	return sampleMoments{mean: mean, stdDev: stdDev}
+}
+
+// ScoreCheck returns the raw score of a single check for a sample.
+// Non-finite features are not scored.
+func (d *Detector) ScoreCheck(t PoisonType, sample Sample) (float64, error) {
+	sample, _ = finiteFeatures(sample)
+	return d.scoreCheck(t, sample, momentsOf(sample))
+}
+
+// scoreCheck scores sample with the check for t, given its feature moments.
+func (d *Detector) scoreCheck(t PoisonType, sample Sample, m sampleMoments) (float64, error) {
+	switch t {
+	case TypeBackdoor:
+		return d.checkBackdoor(sample), nil
+	case TypeLabelFlip:
+		return d.checkLabelFlip(sample), nil
+	case TypeGradientPoison:
+		return d.checkGradientPoison(sample, m), nil
+	case TypeFeaturePoison:
+		return d.checkFeaturePoison(sample, m), nil
+	}
+	return 0, fmt.Errorf("no check for poison type %q", t)
+}
+
+// ScoreSample returns the raw score of each check for a sample, before
+// thresholds are applied. Non-finite features are not scored.
+func (d *Detector) ScoreSample(sample Sample) map[PoisonType]float64 {
+	sample, _ = finiteFeatures(sample)
+	return d.scoreSample(sample, nil)
+}
+
+// scoreSample scores a sample of finite features with each check, adding the time spent in
+// each check to timings when it is non-nil. The feature moments are
+// computed once, outside the timed checks.
+func (d *Detector) scoreSample(sample Sample, timings map[PoisonType]time.Duration) map[PoisonType]float64 {
+	checked := d.scoreChecks(sample, timings)
+	scores := make(map[PoisonType]float64, len(checks))
+	for i, t := range checks {
+		scores[t] = checked[i]
+	}
+	return scores
+}
+
+// checkScores holds the score of each check, in the order of checks.
+type checkScores [len(checks)]float64
+
+// of returns the score of the check for t.
+func (s *checkScores) of(t PoisonType) float64 {
+	for i, c := range checks {
+		if c == t {
+			return s[i]
+		}
+	}
+	return 0
+}
+
+// scoreChecks scores a sample of finite features like scoreSample, without
+// allocating.
+func (d *Detector) scoreChecks(sample Sample, timings map[PoisonType]time.Duration) checkScores {
+	m := momentsOf(sample)
+	var scores checkScores
+	for i, t := range checks {
+		if timings == nil {
+			scores[i], _ = d.scoreCheck(t, sample, m)
+			continue
+		}
+		start := time.Now()
+		scores[i], _ = d.scoreCheck(t, sample, m)
+		timings[t] += time.Since(start)
+	}
+	return scores
 }
 
-// analyzeSample analyzes a single sample for poisoning.
-func (d *Detector) analyzeSample(sample Sample) PoisonedSample {
+// analyzeSample analyzes a single sample for poisoning, recording check
+// timings in timings when it is non-nil.
+func (d *Detector) analyzeSample(sample Sample, timings map[PoisonType]time.Duration) PoisonedSample {
 	result := PoisonedSample{
 		ID:       sample.ID,
+		Label:    sample.Label,
 		Confidence: 0.0,
 	}
 
+	finite, nonFinite := finiteFeatures(sample)
+	scores := d.scoreChecks(finite, timings)
+
 	// Check for backdoor patterns
-	backdoorScore := d.checkBackdoor(sample)
+	backdoorScore := scores.of(TypeBackdoor)
 	if backdoorScore > d.thresholds[TypeBackdoor] {
 		result.IsPoisoned = true
 		result.Type = TypeBackdoor
@@ -107,7 +354,7 @@ func (d *Detector) analyzeSample(sample Sample) PoisonedSample {
 	}
 
 	// Check for label flip
-	labelScore := d.checkLabelFlip(sample)
+	labelScore := scores.of(TypeLabelFlip)
 	if labelScore > d.thresholds[TypeLabelFlip] {
 		result.IsPoisoned = true
 		result.Type = TypeLabelFlip
@@ -118,7 +365,7 @@ func (d *Detector) analyzeSample(sample Sample) PoisonedSample {
 	}
 
 	// Check for gradient poisoning
-	gradientScore := d.checkGradientPoison(sample)
+	gradientScore := scores.of(TypeGradientPoison)
 	if gradientScore > d.thresholds[TypeGradientPoison] {
 		result.IsPoisoned = true
 		result.Type = TypeGradientPoison
@@ -129,7 +376,7 @@ func (d *Detector) analyzeSample(sample Sample) PoisonedSample {
 	}
 
 	// Check for feature poisoning
-	featureScore := d.checkFeaturePoison(sample)
+	featureScore := scores.of(TypeFeaturePoison)
 	if featureScore > d.thresholds[TypeFeaturePoison] {
 		result.IsPoisoned = true
 		result.Type = TypeFeaturePoison
@@ -139,23 +386,31 @@ func (d *Detector) analyzeSample(sample Sample) PoisonedSample {
 		result.Evidence = "Anomalous feature values"
 	}
 
+	// Check for non-finite features
+	if nonFinite > 0 && d.NonFinite() == NonFiniteSuspicious {
+		result.IsPoisoned = true
+		result.Type = TypeFeaturePoison
+		result.Score = 1.0
+		result.Confidence = 1.0
+		result.Description = "Non-finite feature values detected"
+		result.Evidence = fmt.Sprintf("%d of %d features are NaN or infinite", nonFinite, sample.Dim())
+	}
+	if result.IsPoisoned {
+		result.ATLAS = atlasMapping[result.Type]
+	}
+
 	return result
 }
 
 // checkBackdoor checks for backdoor patterns.
 func (d *Detector) checkBackdoor(sample Sample) float64 {
-	// Look for suspicious feature patterns
-	score := 0.0
+	// Look for suspicious feature patterns: rare feature combinations,
+	// features more than 3 standard deviations from their average. The
+	// average would be calculated from multiple samples in production; it
+	// is half the feature for now, so this counts features beyond 6.
+	outliers := featureCountAbsDevAbove(sample, 0, 6.0)
 
-	// Check for rare feature combinations
-	avgFeatures := d.calculateAverage(sample.Features)
-	for i, f := range sample.Features {
-		if math.Abs(f-avgFeatures[i]) > 3.0 { // 3 standard deviations
-			score += 0.1
-		}
-	}
-
-	return math.Min(score, 1.0)
+	return math.Min(float64(outliers)*0.1, 1.0)
 }
 
 // checkLabelFlip checks for label flipping attacks.
@@ -173,70 +428,32 @@ func (d *Detector) checkLabelFlip(sample Sample) float64 {
 }
 
 // checkGradientPoison checks for gradient poisoning.
-func (d *Detector) checkGradientPoison(sample Sample) float64 {
-	// Analyze gradient patterns
-	score := 0.0
-
-	// Check for outlier features
-	mean := d.calculateMean(sample.Features)
-	stdDev := d.calculateStdDev(sample.Features, mean)
-
-	outliers := 0
-	for _, f := range sample.Features {
-		if stdDev > 0 && math.Abs(f-mean)/stdDev > 2.0 {
-			outliers++
-		}
+func (d *Detector) checkGradientPoison(sample Sample, m sampleMoments) float64 {
+	if sample.Dim() == 0 || m.stdDev == 0 {
+		return 0
 	}
 
+	// Count outlier features, more than 2 standard deviations from the mean
+	outliers := featureCountAbsDevAbove(sample, m.mean, 2.0*m.stdDev)
+
 	// High outlier ratio suggests poisoning
-	outlierRatio := float64(outliers) / float64(len(sample.Features))
-	score = outlierRatio * 2.0 // Amplify outlier impact
+	outlierRatio := float64(outliers) / float64(sample.Dim())
+	score := outlierRatio * 2.0 // Amplify outlier impact
 
 	return math.Min(score, 1.0)
 }
 
 // checkFeaturePoison checks for feature poisoning.
-func (d *Detector) checkFeaturePoison(sample Sample) float64 {
-	// Analyze feature distribution
-	score := 0.0
-
-	// Check for statistical anomalies
-	mean := d.calculateMean(sample.Features)
-	stdDev := d.calculateStdDev(sample.Features, mean)
-
-	// Calculate z-scores
-	maxZScore := 0.0
-	for _, f := range sample.Features {
-		if stdDev > 0 {
-			zScore := math.Abs(f - mean) / stdDev
-			if zScore > maxZScore {
-				maxZScore = zScore
-			}
-		}
-	}
-
-	// High z-score suggests poisoning
-	score = math.Min(maxZScore/5.0, 1.0)
-
-	return score
-}
-
-// calculateAverage calculates average of features.
-func (d *Detector) calculateAverage(features []float64) []float64 {
-	if len(features) == 0 {
-		return features
+func (d *Detector) checkFeaturePoison(sample Sample, m sampleMoments) float64 {
+	if m.stdDev == 0 {
+		return 0
 	}
 
-	avg := make([]float64, len(features))
-	sums := make([]float64, len(features))
+	// Check for statistical anomalies: the largest z-score
+	maxZScore := featureMaxAbsDev(sample, m.mean) / m.stdDev
 
-	// This would be calculated from multiple samples in production
-	for i := range features {
-		avg[i] = features[i] / 2.0 // Simplified
-		sums[i] = features[i] / 2.0
-	}
-
-	return avg
+	// High z-score suggests poisoning
+	return math.Min(maxZScore/5.0, 1.0)
 }
 
 // calculateLabelLikelihood calculates likelihood of label.
@@ -245,82 +462,13 @@ func (d *Detector) calculateLabelLikelihood(sample Sample) float64 {
 	return 0.5 // Neutral likelihood for demo
 }
 
-// calculateMean calculates mean of features.
-func (d *Detector) calculateMean(features []float64) float64 {
-	if len(features) == 0 {
-		return 0
-	}
-
-	sum := 0.0
-	for _, f := range features {
-		sum += f
-	}
-
-	return sum / float64(len(features))
-}
-
-// calculateStdDev calculates standard deviation.
-func (d *Detector) calculateStdDev(features []float64, mean float64) float64 {
-	if len(features) == 0 {
-		return 0
-	}
-
-	sum := 0.0
-	for _, f := range features {
-		sum += (f - mean) * (f - mean)
-	}
-
-	return math.Sqrt(sum / float64(len(features)))
-}
-
-// calculateRiskScore calculates poisoning risk score.
-func (d *Detector) calculateRiskScore(result *DetectionResult) float64 {
-	if result.SampleCount == 0 {
-		return 0.0
-	}
-
-	// Calculate ratio of poisoned samples
-	ratio := float64(result.PoisonedCount) / float64(result.SampleCount)
-
-	// Weight by average confidence
-	totalConfidence := 0.0
-	for _, sample := range result.Samples {
-		totalConfidence += sample.Confidence
-	}
-
-	avgConfidence := totalConfidence / float64(result.SampleCount)
-
-	// Combined score
-	score := ratio*0.7 + avgConfidence*0.3
-
-	return score
-}
-
 // GenerateReport generates detection report.
 func GenerateReport(result *DetectionResult) string {
-	var report string
-
-	report += "=== Model Poisoning Detection Report ===\n\n"
-	report += "Total Samples: " + string(rune(result.SampleCount+48)) + "\n"
-	report += "Poisoned Samples: " + string(rune(result.PoisonedCount+48)) + "\n"
-	report += "Risk Score: " + fmt.Sprintf("%.0f%%", result.RiskScore*100) + "%\n"
-	report += "Method: " + result.Method + "\n\n"
-
-	if len(result.Samples) > 0 {
-		report += "Detected Poisoned Samples:\n"
-		for i, sample := range result.Samples {
-			if sample.IsPoisoned {
-				report += fmt.Sprintf("[%c] %s\n", i+49, sample.Type)
-				report += "    ID: " + sample.ID + "\n"
-				report += "    Type: " + string(sample.Type) + "\n"
-				report += "    Score: " + fmt.Sprintf("%.0f%%", sample.Score*100) + "%\n"
-				report += "    Description: " + sample.Description + "\n"
-				report += "    Evidence: " + sample.Evidence + "\n\n"
-			}
-		}
+	var report strings.Builder
+	if err := RenderReport(&report, nil, result); err != nil {
+		return fmt.Sprintf("report error: %v\n", err)
 	}
-
-	return report
+	return report.String()
 }
 
 // GetDetectionResult returns detection result.
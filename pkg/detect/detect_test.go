@@ -0,0 +1,710 @@
+package detect
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestDiffResults(t *testing.T) {
+	previous := &DetectionResult{
+		RiskScore: 0.2,
+		Samples: []PoisonedSample{
+			{ID: "a", IsPoisoned: true, Type: TypeBackdoor},
+			{ID: "b", IsPoisoned: true, Type: TypeLabelFlip},
+			{ID: "c", IsPoisoned: true, Type: TypeFeaturePoison},
+			{ID: "d"},
+		},
+	}
+	current := &DetectionResult{
+		RiskScore: 0.5,
+		Samples: []PoisonedSample{
+			{ID: "a", IsPoisoned: true, Type: TypeBackdoor},
+			{ID: "b", IsPoisoned: true, Type: TypeFeaturePoison},
+			{ID: "d", IsPoisoned: true, Type: TypeBackdoor},
+			{ID: "e", IsPoisoned: true, Type: TypeLabelFlip},
+		},
+	}
+
+	diff := DiffResults(previous, current)
+
+	if len(diff.NewlyFlagged) != 2 || diff.NewlyFlagged[0].ID != "d" || diff.NewlyFlagged[1].ID != "e" {
+		t.Errorf("NewlyFlagged = %+v, want d and e", diff.NewlyFlagged)
+	}
+	if len(diff.Resolved) != 1 || diff.Resolved[0].ID != "c" {
+		t.Errorf("Resolved = %+v, want c", diff.Resolved)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].ID != "b" || diff.Changed[0].NewType != TypeFeaturePoison {
+		t.Errorf("Changed = %+v, want b changed to feature_poison", diff.Changed)
+	}
+	if diff.Persisting != 2 {
+		t.Errorf("Persisting = %d, want 2", diff.Persisting)
+	}
+	if diff.RiskDelta < 0.29 || diff.RiskDelta > 0.31 {
+		t.Errorf("RiskDelta = %v, want 0.3", diff.RiskDelta)
+	}
+}
+
+func TestGenerateReportNumbers(t *testing.T) {
+	result := &DetectionResult{
+		SampleCount:   1234,
+		PoisonedCount: 12,
+		RiskScore:     0.15,
+		Method:        "ensemble_detection",
+	}
+	for i := 0; i < 12; i++ {
+		result.Samples = append(result.Samples, PoisonedSample{ID: "s", IsPoisoned: true, Type: TypeBackdoor, Score: 0.9})
+	}
+
+	report := GenerateReport(result)
+
+	for _, want := range []string{"Total Samples: 1234\n", "Poisoned Samples: 12\n", "Risk Score: 15%\n", "[12] backdoor\n", "Score: 90%\n"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestFilterApply(t *testing.T) {
+	result := &DetectionResult{
+		PoisonedCount: 4,
+		Samples: []PoisonedSample{
+			{ID: "a", IsPoisoned: true, Type: TypeBackdoor, Score: 0.75},
+			{ID: "b", IsPoisoned: true, Type: TypeLabelFlip, Score: 0.9},
+			{ID: "c", IsPoisoned: true, Type: TypeBackdoor, Score: 0.4},
+			{ID: "d", IsPoisoned: true, Type: TypeBackdoor, Score: 0.95},
+			{ID: "e"},
+		},
+	}
+
+	filtered := Filter{MinSeverity: SeverityHigh, Types: []PoisonType{TypeBackdoor}, Top: 1}.Apply(result)
+
+	if len(filtered.Samples) != 1 || filtered.Samples[0].ID != "d" {
+		t.Errorf("Samples = %+v, want only d", filtered.Samples)
+	}
+	if filtered.Filter == nil || filtered.Filter.Shown != 1 || filtered.Filter.Flagged != 4 {
+		t.Errorf("Filter = %+v, want 1 of 4 shown", filtered.Filter)
+	}
+	if filtered.PoisonedCount != 4 || len(result.Samples) != 5 {
+		t.Errorf("filtering changed the scan summary or the original result")
+	}
+}
+
+func TestDetectContextRecordsSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(sdktrace.NewTracerProvider())
+
+	samples := []Sample{{ID: "a", Features: []float64{1, 2, 3}}, {ID: "b", Features: []float64{2, 3, 4}}}
+	NewDetector().DetectContext(context.Background(), samples)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "detect.Detect" {
+		t.Fatalf("spans = %v, want one detect.Detect span", spans)
+	}
+	attrs := make(map[string]bool)
+	for _, kv := range spans[0].Attributes() {
+		attrs[string(kv.Key)] = true
+	}
+	for _, want := range []string{"modelpoison.samples", "modelpoison.risk_score", "modelpoison.check.backdoor.seconds"} {
+		if !attrs[want] {
+			t.Errorf("span missing attribute %s", want)
+		}
+	}
+}
+
+// testSamples returns normally distributed samples with a spiked feature
+// in every 97th sample.
+func testSamples(n int) []Sample {
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]Sample, n)
+	for i := range samples {
+		features := make([]float64, 20)
+		for f := range features {
+			features[f] = rng.NormFloat64()
+		}
+		if i%97 == 0 {
+			features[3] = 500
+		}
+		samples[i] = Sample{ID: fmt.Sprintf("s%d", i), Features: features, Label: i % 2}
+	}
+	return samples
+}
+
+func TestDetectWorkersDeterministic(t *testing.T) {
+	samples := testSamples(5000)
+	d := NewDetector()
+	d.SetWorkers(1)
+	want := d.Detect(samples)
+	if want.PoisonedCount == 0 {
+		t.Fatal("no findings to compare")
+	}
+
+	d.SetWorkers(4)
+	last := 0
+	d.SetProgress(func(processed, total int) {
+		if processed <= last || total != len(samples) {
+			t.Errorf("progress(%d, %d) after %d", processed, total, last)
+		}
+		last = processed
+	})
+	got := d.Detect(samples)
+	if !reflect.DeepEqual(got, want) {
+		t.Error("result with 4 workers differs from the sequential result")
+	}
+	if last != len(samples) {
+		t.Errorf("progress ended at %d of %d", last, len(samples))
+	}
+}
+
+func TestReportsDeterministic(t *testing.T) {
+	samples := testSamples(2000)
+	var wantJSON, wantText string
+	for _, workers := range []int{1, 2, 4, 8} {
+		for run := 0; run < 3; run++ {
+			d := NewDetector()
+			d.SetWorkers(workers)
+			result := d.Detect(samples)
+			var b strings.Builder
+			if err := WriteResult(&b, result); err != nil {
+				t.Fatal(err)
+			}
+			text := GenerateReport(result)
+			if wantJSON == "" {
+				if result.PoisonedCount == 0 {
+					t.Fatal("no findings to compare")
+				}
+				wantJSON, wantText = b.String(), text
+				continue
+			}
+			if b.String() != wantJSON {
+				t.Errorf("JSON result of run %d with %d workers differs from the first", run+1, workers)
+			}
+			if text != wantText {
+				t.Errorf("text report of run %d with %d workers differs from the first", run+1, workers)
+			}
+		}
+	}
+}
+
+func TestStreamMatchesDetect(t *testing.T) {
+	samples := testSamples(3000)
+	d := NewDetector()
+	want := d.Detect(samples)
+
+	stream := d.NewStream()
+	for start := 0; start < len(samples); start += 700 {
+		end := start + 700
+		if end > len(samples) {
+			end = len(samples)
+		}
+		if err := stream.Add(context.Background(), samples[start:end]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := stream.Result()
+
+	var flagged []PoisonedSample
+	for _, sample := range want.Samples {
+		if sample.IsPoisoned {
+			flagged = append(flagged, sample)
+		}
+	}
+	if !reflect.DeepEqual(got.Samples, flagged) || got.RiskScore != want.RiskScore ||
+		got.SampleCount != want.SampleCount || got.PoisonedCount != want.PoisonedCount {
+		t.Errorf("stream result differs from Detect: %d of %d flagged, risk %v; want %d of %d, risk %v",
+			got.PoisonedCount, got.SampleCount, got.RiskScore, want.PoisonedCount, want.SampleCount, want.RiskScore)
+	}
+	if !got.FlaggedOnly || got.LabelCounts[0] != 1500 || got.LabelCounts[1] != 1500 {
+		t.Errorf("label counts = %v, want 1500 of each label", got.LabelCounts)
+	}
+}
+
+func TestDetectIncremental(t *testing.T) {
+	samples := testSamples(2000)
+	d := NewDetector()
+	want := d.Detect(samples)
+
+	first := d.DetectIncremental(context.Background(), samples, nil)
+	if first.Incremental.Reused != 0 || first.Incremental.Rescored != len(samples) {
+		t.Errorf("first scan = %+v, want every sample rescored", first.Incremental)
+	}
+
+	// Change one flagged and one clean sample and rename the rest.
+	next := make([]Sample, len(samples))
+	for i, s := range samples {
+		s.ID = fmt.Sprintf("v2-%d", i)
+		next[i] = s
+	}
+	next[0].Features = append([]float64(nil), next[0].Features...)
+	next[0].Features[3] = 0
+	next[1].Label = 1 - next[1].Label
+	got := d.DetectIncremental(context.Background(), next, first)
+	if got.Incremental.Reused != len(next)-2 || got.Incremental.Rescored != 2 {
+		t.Errorf("second scan = %+v, want 2 samples rescored", got.Incremental)
+	}
+	full := d.Detect(next)
+	for i := range full.Samples {
+		finding := got.Samples[i]
+		if finding.Hash != SampleHash(next[i]) {
+			t.Errorf("sample %d: hash %q, want %q", i, finding.Hash, SampleHash(next[i]))
+		}
+		finding.Hash = ""
+		if !reflect.DeepEqual(finding, full.Samples[i]) {
+			t.Errorf("sample %d: finding %+v, want %+v", i, finding, full.Samples[i])
+		}
+	}
+	if got.PoisonedCount != full.PoisonedCount || got.RiskScore != full.RiskScore || full.PoisonedCount != want.PoisonedCount-1 {
+		t.Errorf("second scan: %d poisoned, risk %v; want %d, %v", got.PoisonedCount, got.RiskScore, full.PoisonedCount, full.RiskScore)
+	}
+
+	if err := d.SetThreshold(TypeBackdoor, 0.9); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.DetectIncremental(context.Background(), next, first); got.Incremental.Reused != 0 {
+		t.Errorf("reused %d findings after a threshold change", got.Incremental.Reused)
+	}
+}
+
+func TestNonFinite(t *testing.T) {
+	samples := testSamples(200)
+	// Sample 0 has a spike; give it and a clean sample non-finite features.
+	samples[0].Features[5] = math.NaN()
+	samples[1].Features[7] = math.Inf(1)
+
+	d := NewDetector()
+	result := d.Detect(samples)
+	if !result.Samples[0].IsPoisoned {
+		t.Errorf("skip: spiked sample with a NaN feature not flagged")
+	}
+	if result.Samples[1].IsPoisoned {
+		t.Errorf("skip: sample with an infinite feature flagged: %+v", result.Samples[1])
+	}
+	if err := d.Validate(samples); err != nil {
+		t.Errorf("skip: Validate = %v", err)
+	}
+
+	if err := d.SetNonFinite(NonFiniteSuspicious); err != nil {
+		t.Fatal(err)
+	}
+	result = d.Detect(samples)
+	if got := result.Samples[1]; !got.IsPoisoned || got.Type != TypeFeaturePoison || got.Score != 1 {
+		t.Errorf("suspicious: sample with an infinite feature = %+v, want flagged", got)
+	}
+
+	if err := d.SetNonFinite(NonFiniteError); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Validate(samples); err == nil || !strings.Contains(err.Error(), "sample s0: feature 5") {
+		t.Errorf("error: Validate = %v, want sample s0 rejected", err)
+	}
+	if err := d.NewStream().Add(context.Background(), samples); err == nil {
+		t.Errorf("error: Stream.Add accepted non-finite features")
+	}
+}
+
+func TestRiskModel(t *testing.T) {
+	samples := testSamples(1000)
+	d := NewDetector()
+	result := d.Detect(samples)
+	if result.PoisonedCount == 0 {
+		t.Fatal("no samples flagged")
+	}
+
+	confidence := 0.0
+	for _, finding := range result.Samples {
+		confidence += finding.Confidence
+	}
+	n := float64(result.SampleCount)
+	if want := float64(result.PoisonedCount)/n*0.7 + confidence/n*0.3; math.Abs(result.RiskScore-want) > 1e-12 {
+		t.Errorf("default risk score = %v, want %v", result.RiskScore, want)
+	}
+	if result.Risk == nil || result.Risk.Raw != result.RiskScore {
+		t.Errorf("risk assessment = %+v, want raw score %v", result.Risk, result.RiskScore)
+	}
+
+	model := DefaultRiskModel()
+	model.TypeWeights = map[PoisonType]float64{result.Samples[0].Type: 0}
+	model.Calibration = &Calibration{Slope: 10, Intercept: -1}
+	if err := d.SetRiskModel(model); err != nil {
+		t.Fatal(err)
+	}
+	weighted := d.Detect(samples)
+	if weighted.Risk.Raw >= result.RiskScore {
+		t.Errorf("raw score with %s weighing 0 = %v, want below %v", result.Samples[0].Type, weighted.Risk.Raw, result.RiskScore)
+	}
+	if want := 1 / (1 + math.Exp(-(10*weighted.Risk.Raw - 1))); weighted.RiskScore != want {
+		t.Errorf("calibrated risk score = %v, want %v", weighted.RiskScore, want)
+	}
+
+	tally := d.NewTally()
+	for _, sample := range samples {
+		tally.Add(d.Analyze(sample))
+	}
+	if got := tally.Result(); got.RiskScore != weighted.RiskScore || got.PoisonedCount != weighted.PoisonedCount {
+		t.Errorf("tally = %v risk over %d findings, want %v over %d", got.RiskScore, got.PoisonedCount, weighted.RiskScore, weighted.PoisonedCount)
+	}
+
+	for _, bad := range []RiskModel{
+		{},
+		{RatioWeight: -1, ConfidenceWeight: 1},
+		{RatioWeight: 1, TypeWeights: map[PoisonType]float64{"unknown": 1}},
+		{RatioWeight: 1, SeverityWeights: map[string]float64{"extreme": 1}},
+		{RatioWeight: 1, Calibration: &Calibration{Slope: math.Inf(1)}},
+	} {
+		if err := d.SetRiskModel(bad); err == nil {
+			t.Errorf("SetRiskModel(%+v) accepted an invalid model", bad)
+		}
+	}
+}
+
+func TestShardStreamMerge(t *testing.T) {
+	samples := testSamples(3000)
+	d := NewDetector()
+	stream := d.NewStream()
+	if err := stream.Add(context.Background(), samples); err != nil {
+		t.Fatal(err)
+	}
+	want := stream.Result()
+
+	var parts []*DetectionResult
+	for _, spec := range []ShardSpec{{0, 0, 3}, {1, 2, 3}} {
+		s, err := d.NewShardStream(spec, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Stop part way, then resume from the checkpoint, reading every
+		// row again.
+		if err := s.Add(context.Background(), samples[:1234]); err != nil {
+			t.Fatal(err)
+		}
+		if s, err = d.NewShardStream(spec, s.Checkpoint()); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < len(samples); i += 500 {
+			if err := s.Add(context.Background(), samples[i:min(i+500, len(samples))]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		parts = append(parts, s.Result())
+	}
+
+	got, err := d.MergeShards(parts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Samples, want.Samples) || !reflect.DeepEqual(got.LabelCounts, want.LabelCounts) ||
+		got.SampleCount != want.SampleCount || got.RiskScore != want.RiskScore {
+		t.Errorf("merged shards: %d samples, %d flagged, risk %v; want %d, %d, %v",
+			got.SampleCount, len(got.Samples), got.RiskScore, want.SampleCount, len(want.Samples), want.RiskScore)
+	}
+	if _, err := d.MergeShards(parts[:1]); err == nil {
+		t.Errorf("merged results missing shards 1-2")
+	}
+	if _, err := d.NewShardStream(ShardSpec{1, 1, 3}, parts[0]); err == nil {
+		t.Errorf("resumed shard 1 from a checkpoint of shard 0")
+	}
+}
+
+func TestFrame(t *testing.T) {
+	samples := []Sample{
+		{Features: []float64{1, math.NaN(), 3}},
+		{Features: []float64{4}},
+		{Features: []float64{7, math.Inf(1), 9}},
+	}
+	f := NewFrame(samples)
+	if f.Rows() != 3 || f.Width() != 3 {
+		t.Fatalf("frame is %dx%d, want 3x3", f.Rows(), f.Width())
+	}
+	if got := f.Column(0); len(got) != 3 || got[0] != 1 || got[1] != 4 || got[2] != 7 {
+		t.Errorf("Column(0) = %v, want [1 4 7]", got)
+	}
+	if f.Valid(1, 1) || !f.Valid(1, 0) || f.NullCount(2) != 1 || f.NullCount(0) != 0 {
+		t.Errorf("validity of row 1 is wrong: valid(1,1)=%v nulls(2)=%d", f.Valid(1, 1), f.NullCount(2))
+	}
+	if values, nan, inf := f.Finite(1, nil); len(values) != 0 || nan != 1 || inf != 1 {
+		t.Errorf("Finite(1) = %v, %d NaN, %d Inf; want none, 1, 1", values, nan, inf)
+	}
+	if values, _, _ := f.Finite(2, nil); len(values) != 2 || values[0] != 3 || values[1] != 9 {
+		t.Errorf("Finite(2) = %v, want [3 9]", values)
+	}
+	if row := f.Row(1, nil); len(row) != 1 || row[0] != 4 {
+		t.Errorf("Row(1) = %v, want [4]", row)
+	}
+}
+
+func TestDetectBatch(t *testing.T) {
+	samples := testSamples(3000)
+	features := make([][]float64, len(samples))
+	labels := make([]int, len(samples))
+	for i, sample := range samples {
+		features[i], labels[i] = sample.Features, sample.Label
+	}
+	d := NewDetector()
+	d.SetWorkers(4)
+	want := d.Detect(samples)
+	got, err := d.DetectBatch(features, labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PoisonedCount != want.PoisonedCount || got.RiskScore != want.RiskScore || got.LabelCounts[1] != 1500 {
+		t.Fatalf("batch = %d flagged, risk %v; want %d, %v", got.PoisonedCount, got.RiskScore, want.PoisonedCount, want.RiskScore)
+	}
+	i := 0
+	for row, finding := range want.Samples {
+		if !finding.IsPoisoned {
+			continue
+		}
+		if got.Samples[i].ID != strconv.Itoa(row) || got.Samples[i].Score != finding.Score {
+			t.Errorf("finding %d = %s scored %v, want row %d scored %v", i, got.Samples[i].ID, got.Samples[i].Score, row, finding.Score)
+		}
+		i++
+	}
+
+	scores := d.ScoreBatch(features[:10], nil)
+	for row := 0; row < 10; row++ {
+		for c, check := range Checks() {
+			if want := d.ScoreSample(samples[row])[check]; scores[row*len(Checks())+c] != want {
+				t.Errorf("row %d %s score = %v, want %v", row, check, scores[row*len(Checks())+c], want)
+			}
+		}
+	}
+	if _, err := d.DetectBatch(features, labels[:1]); err == nil {
+		t.Error("DetectBatch accepted mismatched labels")
+	}
+}
+
+func TestSparseSamples(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	const dim = 1000
+	var dense, sparse []Sample
+	for i := 0; i < 200; i++ {
+		var indices []int
+		var values []float64
+		for j := 0; j < dim; j++ {
+			if rng.Intn(50) == 0 {
+				indices = append(indices, j)
+				values = append(values, rng.ExpFloat64()*float64(1+i%7))
+			}
+		}
+		s, err := NewSparseSample(fmt.Sprintf("s%d", i), i%2, dim, indices, values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sparse = append(sparse, s)
+		dense = append(dense, NewSample(s.ID, s.Label, s.Dense()))
+	}
+
+	d := NewDetector()
+	for i := range dense {
+		want, got := d.ScoreSample(dense[i]), d.ScoreSample(sparse[i])
+		for _, check := range Checks() {
+			if math.Abs(got[check]-want[check]) > 1e-9 {
+				t.Errorf("sample %d %s score = %v, want %v", i, check, got[check], want[check])
+			}
+		}
+	}
+	want, got := d.Detect(dense), d.Detect(sparse)
+	if got.PoisonedCount != want.PoisonedCount || want.PoisonedCount == 0 {
+		t.Errorf("sparse scan flagged %d, dense %d", got.PoisonedCount, want.PoisonedCount)
+	}
+	if SampleHash(sparse[0]) == SampleHash(dense[0]) {
+		t.Error("sparse and dense samples hash alike")
+	}
+
+	nan, _ := NewSparseSample("nan", 0, 4, []int{1, 3}, []float64{math.NaN(), 2})
+	if finite, n := finiteFeatures(nan); n != 1 || finite.Dim() != 3 || finite.Sparse.Check() != nil {
+		t.Errorf("finite features of %v = %+v, %d", nan.Sparse, finite.Sparse, n)
+	}
+	d.SetNonFinite(NonFiniteError)
+	if err := d.Validate([]Sample{nan}); err == nil || !strings.Contains(err.Error(), "feature 1") {
+		t.Errorf("Validate = %v, want feature 1 named", err)
+	}
+	if _, err := NewSparseSample("bad", 0, 4, []int{2, 1}, []float64{1, 1}); err == nil {
+		t.Error("NewSparseSample accepted decreasing indices")
+	}
+}
+
+func TestFloat32Samples(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	var dense, compact []Sample
+	for i := 0; i < 300; i++ {
+		features := make([]float64, 64)
+		for j := range features {
+			features[j] = rng.NormFloat64()
+		}
+		if i%25 == 0 {
+			features[i%64] = 40
+		}
+		s := NewSample(fmt.Sprintf("s%d", i), i%2, features)
+		dense = append(dense, s)
+		compact = append(compact, s.Float32())
+	}
+	if compact[0].Features != nil || compact[0].Dim() != 64 {
+		t.Fatalf("Float32 kept %d float64 features, dim %d", len(compact[0].Features), compact[0].Dim())
+	}
+
+	d := NewDetector()
+	for i := range dense {
+		want, got := d.ScoreSample(dense[i]), d.ScoreSample(compact[i])
+		for _, check := range Checks() {
+			if math.Abs(got[check]-want[check]) > 1e-5 {
+				t.Errorf("sample %d %s score = %v, want %v", i, check, got[check], want[check])
+			}
+		}
+	}
+	want, got := d.Detect(dense), d.Detect(compact)
+	if got.PoisonedCount != want.PoisonedCount || want.PoisonedCount == 0 {
+		t.Errorf("float32 scan flagged %d, float64 %d", got.PoisonedCount, want.PoisonedCount)
+	}
+	if SampleHash(compact[0]) == SampleHash(dense[0]) {
+		t.Error("float32 and float64 samples hash alike")
+	}
+
+	nan := NewSample("nan", 0, []float64{1, math.NaN(), 2}).Float32()
+	if finite, n := finiteFeatures(nan); n != 1 || !reflect.DeepEqual(finite.Features32, []float32{1, 2}) {
+		t.Errorf("finite features of %v = %v, %d", nan.Features32, finite.Features32, n)
+	}
+	d.SetNonFinite(NonFiniteError)
+	if err := d.Validate([]Sample{nan}); err == nil || !strings.Contains(err.Error(), "feature 1") {
+		t.Errorf("Validate = %v, want feature 1 named", err)
+	}
+}
+
+func TestATLASMapping(t *testing.T) {
+	d := NewDetector()
+	result := d.Detect([]Sample{
+		NewSample("clean", 0, []float64{1, 1.1, 0.9, 1, 1.05}),
+		NewSample("spike", 0, append(make([]float64, 20), 50)),
+	})
+	for _, sample := range result.Samples {
+		if want := atlasMapping[sample.Type]; sample.IsPoisoned != (len(sample.ATLAS) > 0) || (sample.IsPoisoned && !reflect.DeepEqual(sample.ATLAS, want)) {
+			t.Errorf("sample %s (%s) tagged %v, want %v", sample.ID, sample.Type, sample.ATLAS, want)
+		}
+	}
+	for _, pt := range PoisonTypes() {
+		for _, technique := range ATLASTechniques(pt) {
+			if technique.ID == "" || technique.Name == "" || len(technique.Tactics) == 0 {
+				t.Errorf("%s maps to undefined technique %+v", pt, technique)
+			}
+		}
+	}
+
+	coverage := ATLASCoverage(result)
+	findings := 0
+	for _, c := range coverage {
+		if c.ID == "AML.T0020" {
+			findings = c.Findings
+			if !c.Detected || len(c.Types) != len(PoisonTypes()) {
+				t.Errorf("AML.T0020 coverage %+v", c)
+			}
+		}
+		if c.ID == "AML.T0019" && c.Detected {
+			t.Error("AML.T0019 reported detected by a built-in check")
+		}
+	}
+	if findings != result.PoisonedCount || findings == 0 {
+		t.Errorf("AML.T0020 findings = %d, want %d", findings, result.PoisonedCount)
+	}
+}
+
+func TestClassBreakdown(t *testing.T) {
+	result := &DetectionResult{Samples: []PoisonedSample{
+		{Label: 0},
+		{Label: 1, IsPoisoned: true, Score: 0.9, Type: TypeBackdoor},
+		{Label: 1, IsPoisoned: true, Score: 0.7, Type: TypeBackdoor},
+		{Label: 1, IsPoisoned: true, Score: 0.5, Type: TypeLabelFlip},
+		{Label: 2, IsPoisoned: true, Score: 0.6, Type: TypeLabelFlip},
+	}}
+	classes := ClassBreakdown(result)
+	if len(classes) != 3 || classes[0].Label != 0 || classes[0].Flagged != 0 || classes[0].Samples != 1 {
+		t.Fatalf("classes = %+v, want 0, 1, 2 with class 0 clean", classes)
+	}
+	c := classes[1]
+	if c.Samples != 3 || c.Flagged != 3 || c.FlaggedRate != 1 || c.Share != 0.75 || math.Abs(c.MeanScore-0.7) > 1e-9 || c.DominantType != TypeBackdoor {
+		t.Errorf("class 1 = %+v", c)
+	}
+
+	// Results holding only flagged samples count classes by LabelCounts.
+	result.Samples = result.Samples[1:]
+	result.LabelCounts = map[int]int{0: 10, 1: 30, 2: 10}
+	classes = ClassBreakdown(result)
+	if len(classes) != 3 || classes[0].Samples != 10 || classes[1].Samples != 30 || classes[1].FlaggedRate != 0.1 {
+		t.Errorf("flagged-only classes = %+v", classes)
+	}
+}
+
+// idLists allows and denies samples by ID.
+type idLists struct {
+	allow, deny map[string]bool
+}
+
+func (l idLists) Allowed(sample Sample) bool { return l.allow[sample.ID] }
+
+func (l idLists) Denied(sample Sample) (string, bool) {
+	return "denied by test", l.deny[sample.ID]
+}
+
+func TestLists(t *testing.T) {
+	samples := testSamples(500)
+	d := NewDetector()
+	plain := d.Detect(samples)
+	var flagged, clean []string
+	for _, finding := range plain.Samples {
+		if finding.IsPoisoned {
+			flagged = append(flagged, finding.ID)
+		} else {
+			clean = append(clean, finding.ID)
+		}
+	}
+	if len(flagged) < 2 || len(clean) < 1 {
+		t.Fatalf("%d flagged and %d clean test samples", len(flagged), len(clean))
+	}
+
+	lists := idLists{
+		allow: map[string]bool{flagged[0]: true, flagged[1]: true},
+		deny:  map[string]bool{flagged[1]: true, clean[0]: true},
+	}
+	d.SetLists(lists)
+	result := d.Detect(samples)
+	byID := make(map[string]PoisonedSample)
+	for _, finding := range result.Samples {
+		byID[finding.ID] = finding
+	}
+	if f := byID[flagged[0]]; f.IsPoisoned || f.List != ListAllowed {
+		t.Errorf("allowed sample: %+v", f)
+	}
+	if f := byID[flagged[1]]; !f.IsPoisoned || f.List != ListDenied {
+		t.Errorf("allowed and denied sample: %+v, want denied", f)
+	}
+	if f := byID[clean[0]]; !f.IsPoisoned || f.List != ListDenied || f.Type != TypeDataPoison || f.Evidence != "denied by test" {
+		t.Errorf("denied sample: %+v", f)
+	}
+	if result.PoisonedCount != plain.PoisonedCount {
+		t.Errorf("poisoned = %d, want %d (one allowed, one denied)", result.PoisonedCount, plain.PoisonedCount)
+	}
+
+	// Incremental scans rescore overridden findings, so lists changed since
+	// the previous scan apply.
+	previous := d.DetectIncremental(context.Background(), samples, nil)
+	d.SetLists(nil)
+	got := d.DetectIncremental(context.Background(), samples, previous)
+	if got.Incremental.Rescored != 3 || got.PoisonedCount != plain.PoisonedCount {
+		t.Errorf("after removing the lists: %+v, %d poisoned", got.Incremental, got.PoisonedCount)
+	}
+	for i, finding := range got.Samples {
+		if finding.List != "" || finding.IsPoisoned != plain.Samples[i].IsPoisoned {
+			t.Errorf("sample %s: %+v after removing the lists", finding.ID, finding)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package detect
+
+import "fmt"
+
+// SampleChange describes a sample flagged in both runs with a different type.
+type SampleChange struct {
+	ID       string     `json:"id"`
+	OldType  PoisonType `json:"old_type"`
+	NewType  PoisonType `json:"new_type"`
+	OldScore float64    `json:"old_score"`
+	NewScore float64    `json:"new_score"`
+}
+
+// ResultDiff contains the differences between two detection runs.
+type ResultDiff struct {
+	NewlyFlagged []PoisonedSample `json:"newly_flagged"`
+	Resolved     []PoisonedSample `json:"resolved"`
+	Changed      []SampleChange   `json:"changed"`
+	Persisting   int              `json:"persisting"`
+	OldRiskScore float64          `json:"old_risk_score"`
+	NewRiskScore float64          `json:"new_risk_score"`
+	RiskDelta    float64          `json:"risk_delta"`
+}
+
+// DiffResults compares a previous detection run with a newer one. Samples
+// are matched by ID. Newly flagged samples are listed in the order of the
+// newer run; resolved samples, including ones no longer present, in the
+// order of the previous run.
+func DiffResults(previous, current *DetectionResult) *ResultDiff {
+	diff := &ResultDiff{
+		OldRiskScore: previous.RiskScore,
+		NewRiskScore: current.RiskScore,
+		RiskDelta:    current.RiskScore - previous.RiskScore,
+	}
+
+	oldFlagged := flaggedByID(previous)
+	newFlagged := flaggedByID(current)
+
+	for _, sample := range current.Samples {
+		if !sample.IsPoisoned {
+			continue
+		}
+
+		old, ok := oldFlagged[sample.ID]
+		if !ok {
+			diff.NewlyFlagged = append(diff.NewlyFlagged, sample)
+			continue
+		}
+
+		diff.Persisting++
+		if old.Type != sample.Type {
+			diff.Changed = append(diff.Changed, SampleChange{
+				ID:       sample.ID,
+				OldType:  old.Type,
+				NewType:  sample.Type,
+				OldScore: old.Score,
+				NewScore: sample.Score,
+			})
+		}
+	}
+
+	for _, sample := range previous.Samples {
+		if !sample.IsPoisoned {
+			continue
+		}
+		if _, ok := newFlagged[sample.ID]; !ok {
+			diff.Resolved = append(diff.Resolved, sample)
+		}
+	}
+
+	return diff
+}
+
+// HasChanges reports whether the diff contains any finding changes.
+func (d *ResultDiff) HasChanges() bool {
+	return len(d.NewlyFlagged) > 0 || len(d.Resolved) > 0 || len(d.Changed) > 0
+}
+
+// flaggedByID indexes the flagged samples of a result by ID.
+func flaggedByID(result *DetectionResult) map[string]PoisonedSample {
+	flagged := make(map[string]PoisonedSample)
+	for _, sample := range result.Samples {
+		if sample.IsPoisoned {
+			flagged[sample.ID] = sample
+		}
+	}
+	return flagged
+}
+
+// GenerateDiffReport generates a report of changes between two runs.
+func GenerateDiffReport(diff *ResultDiff) string {
+	var report string
+
+	report += "=== Model Poisoning Detection Diff ===\n\n"
+	report += fmt.Sprintf("Risk Score: %.0f%% -> %.0f%% (%+.0f%%)\n",
+		diff.OldRiskScore*100, diff.NewRiskScore*100, diff.RiskDelta*100)
+	report += fmt.Sprintf("Newly Flagged: %d\n", len(diff.NewlyFlagged))
+	report += fmt.Sprintf("Resolved: %d\n", len(diff.Resolved))
+	report += fmt.Sprintf("Changed Type: %d\n", len(diff.Changed))
+	report += fmt.Sprintf("Persisting: %d\n\n", diff.Persisting)
+
+	if len(diff.NewlyFlagged) > 0 {
+		report += "Newly Flagged Samples:\n"
+		for _, sample := range diff.NewlyFlagged {
+			report += fmt.Sprintf("  + %s  %s  %.0f%%  %s\n",
+				sample.ID, sample.Type, sample.Score*100, sample.Description)
+		}
+		report += "\n"
+	}
+
+	if len(diff.Resolved) > 0 {
+		report += "Resolved Samples:\n"
+		for _, sample := range diff.Resolved {
+			report += fmt.Sprintf("  - %s  %s  %.0f%%\n", sample.ID, sample.Type, sample.Score*100)
+		}
+		report += "\n"
+	}
+
+	if len(diff.Changed) > 0 {
+		report += "Changed Samples:\n"
+		for _, change := range diff.Changed {
+			report += fmt.Sprintf("  ~ %s  %s -> %s  %.0f%% -> %.0f%%\n",
+				change.ID, change.OldType, change.NewType, change.OldScore*100, change.NewScore*100)
+		}
+		report += "\n"
+	}
+
+	return report
+}
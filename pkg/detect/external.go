@@ -0,0 +1,110 @@
+package detect
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// ExternalCheck is a check implemented outside the detector, such as a
+// detector shipped as a plugin. Samples scoring above its threshold are
+// flagged with its poison type, like those of the built-in checks.
+type ExternalCheck interface {
+	// Info describes the check. Its Type is the poison type of its
+	// findings, and its Name identifies it among the external checks.
+	Info() CheckInfo
+	// Threshold returns the score above which a sample is flagged.
+	Threshold() float64
+	// Score scores samples, returning a score between 0 and 1 and the
+	// evidence for each, in order. Score may be called concurrently.
+	// Checks that cannot score samples return zero scores and report the
+	// failure by their own means, so scans do not stop half-analyzed.
+	Score(samples []Sample) []ExternalScore
+}
+
+// ExternalScore is the score of a sample by an ExternalCheck.
+type ExternalScore struct {
+	Score    float64 `json:"score"`
+	Evidence string  `json:"evidence,omitempty"`
+}
+
+// AddCheck adds an external check, scored after the built-in checks. Its
+// poison type must be one of PoisonTypes and its name unique.
+func (d *Detector) AddCheck(c ExternalCheck) error {
+	info := c.Info()
+	if info.Name == "" {
+		return fmt.Errorf("external check of type %s has no name", info.Type)
+	}
+	if !knownType(info.Type) {
+		return fmt.Errorf("check %s: unknown poison type %q", info.Name, info.Type)
+	}
+	if t := c.Threshold(); t < 0 || t > 1 {
+		return fmt.Errorf("check %s: threshold must be between 0 and 1, got %v", info.Name, t)
+	}
+	for _, existing := range d.external {
+		if existing.Info().Name == info.Name {
+			return fmt.Errorf("check %s is already registered", info.Name)
+		}
+	}
+	d.external = append(d.external, c)
+	return nil
+}
+
+// ExternalChecks returns the external checks added to the detector, in
+// the order they were added.
+func (d *Detector) ExternalChecks() []ExternalCheck {
+	return append([]ExternalCheck(nil), d.external...)
+}
+
+// finish completes the findings of samples, in order, with the scores of
+// the external checks and then the detector's lists. The checks score
+// chunks of the samples on the detector's workers.
+func (d *Detector) finish(samples []Sample, findings []PoisonedSample) {
+	for _, c := range d.external {
+		workers := d.workerCount(len(samples))
+		var next atomic.Int64
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(c ExternalCheck) {
+				defer wg.Done()
+				for {
+					start := int(next.Add(chunkSize)) - chunkSize
+					if start >= len(samples) {
+						return
+					}
+					end := min(start+chunkSize, len(samples))
+					scoreExternal(c, samples[start:end], findings[start:end])
+				}
+			}(c)
+		}
+		wg.Wait()
+	}
+	if d.lists != nil {
+		for i := range findings {
+			findings[i] = d.applyLists(samples[i], findings[i])
+		}
+	}
+}
+
+// scoreExternal merges the scores of c for samples into their findings.
+// A sample flagged by several checks takes the type and evidence of the
+// highest score.
+func scoreExternal(c ExternalCheck, samples []Sample, findings []PoisonedSample) {
+	info, threshold := c.Info(), c.Threshold()
+	for i, score := range c.Score(samples) {
+		if i >= len(findings) || score.Score <= threshold {
+			continue
+		}
+		finding := &findings[i]
+		if !finding.IsPoisoned || score.Score >= finding.Score {
+			finding.Type = info.Type
+			finding.Confidence = score.Score
+			finding.Description = info.Description
+			finding.Evidence = score.Evidence
+			finding.ATLAS = atlasMapping[info.Type]
+		}
+		finding.IsPoisoned = true
+		finding.Score = max(finding.Score, score.Score)
+	}
+}
@@ -0,0 +1,126 @@
+package detect
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity ranks findings by score, matching the documented risk levels.
+type Severity int
+
+const (
+	SeverityMinimal Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+)
+
+var severityNames = []string{"minimal", "low", "medium", "high", "critical"}
+
+func (s Severity) String() string {
+	if s < SeverityMinimal || s > SeverityCritical {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity parses a severity name such as "high".
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if strings.EqualFold(strings.TrimSpace(name), n) {
+			return Severity(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid severity %q (want %s)", name, strings.Join(severityNames, ", "))
+}
+
+// SeverityOf returns the severity of a 0..1 score.
+func SeverityOf(score float64) Severity {
+	switch {
+	case score >= 0.7:
+		return SeverityCritical
+	case score >= 0.5:
+		return SeverityHigh
+	case score >= 0.3:
+		return SeverityMedium
+	case score >= 0.1:
+		return SeverityLow
+	}
+	return SeverityMinimal
+}
+
+// Filter narrows the findings of a result.
+type Filter struct {
+	// MinSeverity drops findings below this severity.
+	MinSeverity Severity
+	// Types keeps only findings of these types when non-empty.
+	Types []PoisonType
+	// Top keeps only the N highest-scoring findings when positive.
+	Top int
+}
+
+// FilterSummary records the filter applied to a result.
+type FilterSummary struct {
+	MinSeverity string       `json:"min_severity,omitempty"`
+	Types       []PoisonType `json:"types,omitempty"`
+	Top         int          `json:"top,omitempty"`
+	Shown       int          `json:"shown"`
+	Flagged     int          `json:"flagged"`
+}
+
+// IsZero reports whether the filter keeps every finding.
+func (f Filter) IsZero() bool {
+	return f.MinSeverity == SeverityMinimal && len(f.Types) == 0 && f.Top <= 0
+}
+
+// Apply returns a copy of result holding only the flagged samples that
+// pass the filter. Summary counts and the risk score still describe the
+// whole scan. With Top set, samples are ordered by descending score.
+func (f Filter) Apply(result *DetectionResult) *DetectionResult {
+	filtered := *result
+	filtered.Samples = nil
+
+	types := make(map[PoisonType]bool, len(f.Types))
+	for _, t := range f.Types {
+		types[t] = true
+	}
+
+	flagged := 0
+	for _, sample := range result.Samples {
+		if !sample.IsPoisoned {
+			continue
+		}
+		flagged++
+		if SeverityOf(sample.Score) < f.MinSeverity {
+			continue
+		}
+		if len(types) > 0 && !types[sample.Type] {
+			continue
+		}
+		filtered.Samples = append(filtered.Samples, sample)
+	}
+
+	if f.Top > 0 {
+		sort.SliceStable(filtered.Samples, func(i, j int) bool {
+			return filtered.Samples[i].Score > filtered.Samples[j].Score
+		})
+		if len(filtered.Samples) > f.Top {
+			filtered.Samples = filtered.Samples[:f.Top]
+		}
+	}
+
+	summary := &FilterSummary{
+		Types:   f.Types,
+		Top:     f.Top,
+		Shown:   len(filtered.Samples),
+		Flagged: flagged,
+	}
+	if f.MinSeverity > SeverityMinimal {
+		summary.MinSeverity = f.MinSeverity.String()
+	}
+	filtered.Filter = summary
+
+	return &filtered
+}
@@ -0,0 +1,117 @@
+package detect
+
+import "math"
+
+// Frame holds the features of samples column by column, so computations
+// over one feature read contiguous memory instead of one value from each
+// sample's slice, which matters on wide datasets. Its layout is that of
+// Arrow float64 arrays: each column is a contiguous buffer of values and,
+// when some samples are too short to have the feature, a validity bitmap
+// whose bit i, least significant bit first, is set when row i has a value.
+// NaN and infinite values are values, not nulls.
+type Frame struct {
+	rows     int
+	columns  [][]float64
+	validity [][]byte
+	nulls    []int
+}
+
+// NewFrame copies the features of samples into a frame as wide as the
+// widest sample, converting float32 features. All columns share one
+// allocation.
+func NewFrame(samples []Sample) *Frame {
+	width := 0
+	for _, sample := range samples {
+		width = max(width, len(sample.Features), len(sample.Features32))
+	}
+	rows := len(samples)
+	f := &Frame{
+		rows:     rows,
+		columns:  make([][]float64, width),
+		validity: make([][]byte, width),
+		nulls:    make([]int, width),
+	}
+	values := make([]float64, rows*width)
+	for j := range f.columns {
+		f.columns[j] = values[j*rows : (j+1)*rows : (j+1)*rows]
+	}
+	for i, sample := range samples {
+		for j, value := range sample.Features {
+			f.columns[j][i] = value
+		}
+		for j, value := range sample.Features32 {
+			f.columns[j][i] = float64(value)
+		}
+		for j := len(sample.Features) + len(sample.Features32); j < width; j++ {
+			f.setNull(j, i)
+		}
+	}
+	return f
+}
+
+// setNull clears the validity bit of row i in column j, allocating the
+// column's bitmap with every row valid on its first null.
+func (f *Frame) setNull(j, i int) {
+	if f.validity[j] == nil {
+		bitmap := make([]byte, (f.rows+7)/8)
+		for k := range bitmap {
+			bitmap[k] = 0xff
+		}
+		f.validity[j] = bitmap
+	}
+	f.validity[j][i/8] &^= 1 << (i % 8)
+	f.nulls[j]++
+}
+
+// Rows returns the number of samples in the frame.
+func (f *Frame) Rows() int { return f.rows }
+
+// Width returns the number of feature columns.
+func (f *Frame) Width() int { return len(f.columns) }
+
+// Column returns the values of feature j, one per row. Null slots hold 0.
+// The slice is shared with the frame and must not be modified.
+func (f *Frame) Column(j int) []float64 { return f.columns[j] }
+
+// Valid reports whether row i has a value for feature j.
+func (f *Frame) Valid(j, i int) bool {
+	bitmap := f.validity[j]
+	return bitmap == nil || bitmap[i/8]&(1<<(i%8)) != 0
+}
+
+// NullCount returns the number of rows without a value for feature j.
+func (f *Frame) NullCount(j int) int { return f.nulls[j] }
+
+// Finite appends the finite values of feature j to dst in row order, and
+// returns them with the number of NaN and infinite values left out. A
+// feature beyond the frame's width has no values.
+func (f *Frame) Finite(j int, dst []float64) (values []float64, nan, inf int) {
+	if j >= len(f.columns) {
+		return dst, 0, 0
+	}
+	column := f.columns[j]
+	for i, v := range column {
+		switch {
+		case f.nulls[j] > 0 && !f.Valid(j, i):
+		case math.IsNaN(v):
+			nan++
+		case math.IsInf(v, 0):
+			inf++
+		default:
+			dst = append(dst, v)
+		}
+	}
+	return dst, nan, inf
+}
+
+// Row appends the features of row i to dst, up to its last valid feature.
+func (f *Frame) Row(i int, dst []float64) []float64 {
+	n := len(f.columns)
+	for n > 0 && !f.Valid(n-1, i) {
+		n--
+	}
+	for j := 0; j < n; j++ {
+		dst = append(dst, f.columns[j][i])
+	}
+	return dst
+}
@@ -0,0 +1,138 @@
+package detect
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// IncrementalScan describes a scan that carries forward the findings of
+// unchanged samples from a previous result.
+type IncrementalScan struct {
+	// Fingerprint identifies the detector settings; findings are only
+	// carried forward between results with the same fingerprint.
+	Fingerprint string `json:"fingerprint"`
+	// Reused counts the samples whose findings were carried forward and
+	// Rescored the samples that were analyzed.
+	Reused   int `json:"reused"`
+	Rescored int `json:"rescored"`
+}
+
+// SampleHash returns a hash of the label and features of sample. Checks
+// score a sample on its label and features alone, so samples with the same
+// hash get the same finding. Sparse samples hash their dimension and
+// index/value pairs, and float32 samples their float32 values, so they
+// never hash like float64 ones.
+func SampleHash(sample Sample) string {
+	h := sha256.New()
+	var buf [8]byte
+	put := func(v uint64) {
+		binary.LittleEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+	put(uint64(int64(sample.Label)))
+	if s := sample.Sparse; s != nil {
+		h.Write([]byte("sparse"))
+		put(uint64(s.Dim))
+		for k, i := range s.Indices {
+			put(uint64(i))
+			put(math.Float64bits(s.Values[k]))
+		}
+		return hex.EncodeToString(h.Sum(nil)[:12])
+	}
+	if sample.Features32 != nil {
+		h.Write([]byte("float32"))
+		for _, f := range sample.Features32 {
+			put(uint64(math.Float32bits(f)))
+		}
+		return hex.EncodeToString(h.Sum(nil)[:12])
+	}
+	for _, f := range sample.Features {
+		put(math.Float64bits(f))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:12])
+}
+
+// Fingerprint identifies what findings depend on besides the samples: the
+// thresholds, the non-finite policy, the version of the scoring kernels
+// and the external checks.
+func (d *Detector) Fingerprint() string {
+	types := make([]string, 0, len(d.thresholds))
+	for t := range d.thresholds {
+		types = append(types, string(t))
+	}
+	sort.Strings(types)
+	var b strings.Builder
+	fmt.Fprintf(&b, "kernels=%d;non-finite=%s;", kernelVersion, d.NonFinite())
+	for _, t := range types {
+		fmt.Fprintf(&b, "%s=%v;", t, d.thresholds[PoisonType(t)])
+	}
+	for _, c := range d.external {
+		info := c.Info()
+		fmt.Fprintf(&b, "external=%s/%s/%s>%v;", info.Plugin, info.Name, info.Method, c.Threshold())
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// DetectIncremental analyzes samples like DetectContext, but carries
+// forward the findings of samples whose content hash appears in previous
+// instead of analyzing them again. Every finding of the result records its
+// sample's hash, so the result can serve as previous for the next scan.
+// previous may be nil, and is ignored when it was produced with other
+// detector settings.
+func (d *Detector) DetectIncremental(ctx context.Context, samples []Sample, previous *DetectionResult) *DetectionResult {
+	fingerprint := d.Fingerprint()
+	known := make(map[string]PoisonedSample)
+	if previous != nil && previous.Incremental != nil && previous.Incremental.Fingerprint == fingerprint {
+		for _, finding := range previous.Samples {
+			// Findings overridden by lists are rescored, as the lists may
+			// have changed since.
+			if finding.Hash != "" && finding.List == "" {
+				known[finding.Hash] = finding
+			}
+		}
+	}
+
+	findings := make([]PoisonedSample, len(samples))
+	hashes := make([]string, len(samples))
+	var changed []Sample
+	var changedAt []int
+	for i, sample := range samples {
+		hashes[i] = SampleHash(sample)
+		finding, ok := known[hashes[i]]
+		if !ok {
+			changed = append(changed, sample)
+			changedAt = append(changedAt, i)
+			continue
+		}
+		// The content is unchanged, but the sample may have moved or been
+		// renamed; reviews apply to the old result only.
+		finding.ID, finding.Review, finding.ReviewNote = sample.ID, "", ""
+		findings[i] = d.applyLists(sample, finding)
+	}
+
+	rescored := d.DetectContext(ctx, changed)
+	for j, finding := range rescored.Samples {
+		findings[changedAt[j]] = finding
+	}
+	for i := range findings {
+		findings[i].Hash = hashes[i]
+	}
+
+	result := d.Summarize(findings, len(samples))
+	if len(samples) == 0 {
+		result.Samples = nil
+	}
+	result.Incremental = &IncrementalScan{
+		Fingerprint: fingerprint,
+		Reused:      len(samples) - len(changed),
+		Rescored:    len(changed),
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package detect
+
+import "math"
+
+// kernelVersion changes when the kernels below change the scores they
+// produce, even in the last bits, so findings computed by other kernels are
+// not carried forward by incremental scans.
+const kernelVersion = 2
+
+// The kernels below are the inner loops of the checks. They accumulate in
+// four independent lanes so consecutive additions do not wait on each
+// other, and avoid allocating so wide feature vectors stay in cache. They
+// take float32 or float64 features and accumulate in float64.
+
+// float is the element type of feature vectors the kernels take.
+type float interface {
+	~float32 | ~float64
+}
+
+// sum returns the sum of x.
+func sum[T float](x []T) float64 {
+	var s0, s1, s2, s3 float64
+	i := 0
+	for ; i+4 <= len(x); i += 4 {
+		s0 += float64(x[i])
+		s1 += float64(x[i+1])
+		s2 += float64(x[i+2])
+		s3 += float64(x[i+3])
+	}
+	for ; i < len(x); i++ {
+		s0 += float64(x[i])
+	}
+	return (s0 + s1) + (s2 + s3)
+}
+
+// sumSquaredDev returns the sum of squared deviations of x from mean.
+func sumSquaredDev[T float](x []T, mean float64) float64 {
+	var s0, s1, s2, s3 float64
+	i := 0
+	for ; i+4 <= len(x); i += 4 {
+		d0, d1, d2, d3 := float64(x[i])-mean, float64(x[i+1])-mean, float64(x[i+2])-mean, float64(x[i+3])-mean
+		s0 += d0 * d0
+		s1 += d1 * d1
+		s2 += d2 * d2
+		s3 += d3 * d3
+	}
+	for ; i < len(x); i++ {
+		d := float64(x[i]) - mean
+		s0 += d * d
+	}
+	return (s0 + s1) + (s2 + s3)
+}
+
+// moments returns the mean and population standard deviation of x, or
+// zeros when x is empty.
+func moments[T float](x []T) (mean, stdDev float64) {
+	if len(x) == 0 {
+		return 0, 0
+	}
+	n := float64(len(x))
+	mean = sum(x) / n
+	return mean, math.Sqrt(sumSquaredDev(x, mean) / n)
+}
+
+// maxAbsDev returns the largest absolute deviation of x from mean.
+func maxAbsDev[T float](x []T, mean float64) float64 {
+	m := 0.0
+	for _, v := range x {
+		if d := math.Abs(float64(v) - mean); d > m {
+			m = d
+		}
+	}
+	return m
+}
+
+// countAbsDevAbove returns the number of elements of x deviating from
+// mean by more than limit.
+func countAbsDevAbove[T float](x []T, mean, limit float64) int {
+	n := 0
+	for _, v := range x {
+		if math.Abs(float64(v)-mean) > limit {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,77 @@
+package detect
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestKernels(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for n := 0; n <= 9; n++ {
+		x := make([]float64, n)
+		for i := range x {
+			x[i] = 100 + 10*rng.NormFloat64()
+		}
+		x32 := make([]float32, n)
+		for i, v := range x {
+			x32[i] = float32(v)
+		}
+		checkKernels(t, "float64", x)
+		checkKernels(t, "float32", x32)
+	}
+}
+
+// checkKernels compares the kernels on x to scalar references.
+func checkKernels[T float](t *testing.T, kind string, x []T) {
+	t.Helper()
+
+	// The lanes reorder the additions, so the sums may differ from the
+	// references in the last bits.
+	near := func(got, want float64) bool {
+		return math.Abs(got-want) <= 1e-12*math.Max(1, math.Abs(want))
+	}
+
+	wantSum := 0.0
+	for _, v := range x {
+		wantSum += float64(v)
+	}
+	if got := sum(x); !near(got, wantSum) {
+		t.Errorf("%s sum of %d elements = %v, want %v", kind, len(x), got, wantSum)
+	}
+
+	const mean = 101.5
+	wantSquared := 0.0
+	wantMax := 0.0
+	wantAbove := 0
+	for _, v := range x {
+		d := float64(v) - mean
+		wantSquared += d * d
+		wantMax = math.Max(wantMax, math.Abs(d))
+		if math.Abs(d) > 5 {
+			wantAbove++
+		}
+	}
+	if got := sumSquaredDev(x, mean); !near(got, wantSquared) {
+		t.Errorf("%s sumSquaredDev of %d elements = %v, want %v", kind, len(x), got, wantSquared)
+	}
+	if got := maxAbsDev(x, mean); got != wantMax {
+		t.Errorf("%s maxAbsDev of %d elements = %v, want %v", kind, len(x), got, wantMax)
+	}
+	if got := countAbsDevAbove(x, mean, 5); got != wantAbove {
+		t.Errorf("%s countAbsDevAbove of %d elements = %d, want %d", kind, len(x), got, wantAbove)
+	}
+
+	wantMean, wantStdDev := 0.0, 0.0
+	if len(x) > 0 {
+		wantMean = wantSum / float64(len(x))
+		for _, v := range x {
+			d := float64(v) - wantMean
+			wantStdDev += d * d
+		}
+		wantStdDev = math.Sqrt(wantStdDev / float64(len(x)))
+	}
+	if gotMean, gotStdDev := moments(x); !near(gotMean, wantMean) || !near(gotStdDev, wantStdDev) {
+		t.Errorf("%s moments of %d elements = %v, %v; want %v, %v", kind, len(x), gotMean, gotStdDev, wantMean, wantStdDev)
+	}
+}
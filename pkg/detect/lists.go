@@ -0,0 +1,55 @@
+package detect
+
+// MetaSource is the metadata key of the source or contributor of a sample,
+// which denylists match.
+const MetaSource = "source"
+
+// Values of PoisonedSample.List.
+const (
+	// ListAllowed marks samples an allowlist cleared: reviewed as benign,
+	// they are not flagged whatever their scores.
+	ListAllowed = "allowed"
+	// ListDenied marks samples a denylist flagged as known bad.
+	ListDenied = "denied"
+)
+
+// Lists overrides the findings of samples already known to be benign or
+// bad, so reviewed false positives are not reported again and samples from
+// compromised sources are flagged even when they score as clean. Lists are
+// consulted by the detector's workers concurrently.
+type Lists interface {
+	// Allowed reports whether sample was reviewed as benign.
+	Allowed(sample Sample) bool
+	// Denied reports whether sample is known to be bad, and why. Denied
+	// samples are flagged even when they are also allowed.
+	Denied(sample Sample) (reason string, denied bool)
+}
+
+// SetLists sets the lists overriding the detector's findings, or removes
+// them when l is nil.
+func (d *Detector) SetLists(l Lists) {
+	d.lists = l
+}
+
+// applyLists overrides the finding of sample with the detector's lists.
+func (d *Detector) applyLists(sample Sample, finding PoisonedSample) PoisonedSample {
+	if d.lists == nil {
+		return finding
+	}
+	if reason, ok := d.lists.Denied(sample); ok {
+		if !finding.IsPoisoned {
+			finding.Type = TypeDataPoison
+			finding.Description = "Sample on the denylist"
+			finding.ATLAS = atlasMapping[TypeDataPoison]
+		}
+		finding.IsPoisoned = true
+		finding.Score, finding.Confidence = 1, 1
+		finding.Evidence = reason
+		finding.List = ListDenied
+		return finding
+	}
+	if d.lists.Allowed(sample) {
+		return PoisonedSample{ID: finding.ID, Label: finding.Label, Hash: finding.Hash, List: ListAllowed}
+	}
+	return finding
+}
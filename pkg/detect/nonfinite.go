@@ -0,0 +1,173 @@
+package detect
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// NonFinite is a policy for features that are NaN or infinite, such as
+// missing values read as NaN. A single such feature would otherwise make
+// the feature moments NaN and every score zero.
+type NonFinite string
+
+const (
+	// NonFiniteSkip scores samples on their finite features only.
+	NonFiniteSkip NonFinite = "skip"
+	// NonFiniteSuspicious scores samples on their finite features and
+	// also flags samples with non-finite features as feature poisoning.
+	NonFiniteSuspicious NonFinite = "suspicious"
+	// NonFiniteError rejects samples with non-finite features: Validate
+	// returns an error for them, and streams stop at them.
+	NonFiniteError NonFinite = "error"
+)
+
+// NonFinitePolicies returns the non-finite feature policies.
+func NonFinitePolicies() []NonFinite {
+	return []NonFinite{NonFiniteSkip, NonFiniteSuspicious, NonFiniteError}
+}
+
+// ParseNonFinite parses a non-finite feature policy name. The empty name
+// is the default policy, NonFiniteSkip.
+func ParseNonFinite(name string) (NonFinite, error) {
+	if name == "" {
+		return NonFiniteSkip, nil
+	}
+	for _, p := range NonFinitePolicies() {
+		if strings.EqualFold(name, string(p)) {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown non-finite policy %q (want skip, suspicious or error)", name)
+}
+
+// NonFinite returns the detector's policy for non-finite features.
+func (d *Detector) NonFinite() NonFinite {
+	if d.nonFinite == "" {
+		return NonFiniteSkip
+	}
+	return d.nonFinite
+}
+
+// SetNonFinite sets the policy for non-finite features.
+func (d *Detector) SetNonFinite(p NonFinite) error {
+	switch p {
+	case NonFiniteSkip, NonFiniteSuspicious, NonFiniteError:
+		d.nonFinite = p
+		return nil
+	}
+	return fmt.Errorf("unknown non-finite policy %q", p)
+}
+
+// Validate checks samples against the non-finite policy. It returns an
+// error naming the first sample with a non-finite feature when the policy
+// is NonFiniteError, and nil otherwise. Detect does not validate, so
+// callers using NonFiniteError validate samples before analyzing them.
+func (d *Detector) Validate(samples []Sample) error {
+	if d.NonFinite() != NonFiniteError {
+		return nil
+	}
+	for i, sample := range samples {
+		var j int
+		var f float64
+		switch {
+		case sample.Sparse != nil:
+			if j, f = firstNonFinite(sample.Sparse.Values); j >= 0 {
+				j = sample.Sparse.Indices[j]
+			}
+		case sample.Features32 != nil:
+			j, f = firstNonFinite(sample.Features32)
+		default:
+			j, f = firstNonFinite(sample.Features)
+		}
+		if j < 0 {
+			continue
+		}
+		id := sample.ID
+		if id == "" {
+			id = fmt.Sprintf("#%d", i)
+		}
+		return fmt.Errorf("sample %s: feature %d is %v", id, j, f)
+	}
+	return nil
+}
+
+// firstNonFinite returns the position and value of the first NaN or
+// infinite element of x, or -1 when all are finite.
+func firstNonFinite[T float](x []T) (int, float64) {
+	for j, v := range x {
+		if f := float64(v); math.IsNaN(f) || math.IsInf(f, 0) {
+			return j, f
+		}
+	}
+	return -1, 0
+}
+
+// finiteFeatures returns sample with its non-finite features dropped and
+// the number dropped. sample is returned as is when all its features are
+// finite, so the common case does not allocate.
+func finiteFeatures(sample Sample) (Sample, int) {
+	if sample.Sparse != nil {
+		return finiteSparse(sample)
+	}
+	n := 0
+	if sample.Features32 != nil {
+		sample.Features32, n = dropNonFinite(sample.Features32)
+	} else {
+		sample.Features, n = dropNonFinite(sample.Features)
+	}
+	return sample, n
+}
+
+// dropNonFinite returns x without its NaN and infinite elements, and the
+// number dropped. x is returned as is when all are finite.
+func dropNonFinite[T float](x []T) ([]T, int) {
+	n := 0
+	for _, v := range x {
+		if f := float64(v); math.IsNaN(f) || math.IsInf(f, 0) {
+			n++
+		}
+	}
+	if n == 0 {
+		return x, 0
+	}
+	finite := make([]T, 0, len(x)-n)
+	for _, v := range x {
+		if f := float64(v); !math.IsNaN(f) && !math.IsInf(f, 0) {
+			finite = append(finite, v)
+		}
+	}
+	return finite, n
+}
+
+// finiteSparse drops the non-finite features of a sparse sample like
+// finiteFeatures. The features after a dropped one move down, so the
+// indices of the returned sample are not positions in the original.
+func finiteSparse(sample Sample) (Sample, int) {
+	s := sample.Sparse
+	n := 0
+	for _, f := range s.Values {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			n++
+		}
+	}
+	if n == 0 {
+		return sample, 0
+	}
+	finite := &SparseFeatures{
+		Dim:     s.Dim - n,
+		Indices: make([]int, 0, len(s.Values)-n),
+		Values:  make([]float64, 0, len(s.Values)-n),
+	}
+	dropped := 0
+	for k, f := range s.Values {
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			dropped++
+			continue
+		}
+		finite.Indices = append(finite.Indices, s.Indices[k]-dropped)
+		finite.Values = append(finite.Values, f)
+	}
+	sample.Sparse = finite
+	return sample, n
+}
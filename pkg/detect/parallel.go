@@ -0,0 +1,109 @@
+package detect
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// chunkSize is the number of samples a worker claims at a time.
+const chunkSize = 1024
+
+// SetWorkers sets the number of goroutines analyzing samples in Detect.
+// n <= 0, the default, uses GOMAXPROCS. Results are the same for any
+// number of workers.
+func (d *Detector) SetWorkers(n int) {
+	d.workers = n
+}
+
+// workerCount returns the number of workers to analyze n samples with.
+func (d *Detector) workerCount(n int) int {
+	workers := d.workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if chunks := (n + chunkSize - 1) / chunkSize; workers > chunks {
+		workers = chunks
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	return workers
+}
+
+// analyzeAll analyzes samples, reporting progress to progress and adding
+// check timings to timings when they are non-nil. Each finding is stored at
+// the index of its sample, so the findings do not depend on how the
+// samples were split across workers.
+func (d *Detector) analyzeAll(samples []Sample, timings map[PoisonType]time.Duration, progress ProgressFunc) []PoisonedSample {
+	if len(samples) == 0 {
+		return nil
+	}
+	findings := make([]PoisonedSample, len(samples))
+	d.forChunks(len(samples), timings, progress, func(start, end int, timings map[PoisonType]time.Duration) {
+		for i := start; i < end; i++ {
+			findings[i] = d.analyzeSample(samples[i], timings)
+		}
+	})
+	d.finish(samples, findings)
+	return findings
+}
+
+// forChunks calls fn on consecutive ranges of n items split among the
+// detector's workers, reporting progress to progress and adding the check
+// timings fn records to timings when they are non-nil. With one worker, fn
+// is called item by item so progress is reported after each item.
+func (d *Detector) forChunks(n int, timings map[PoisonType]time.Duration, progress ProgressFunc, fn func(start, end int, timings map[PoisonType]time.Duration)) {
+	workers := d.workerCount(n)
+	if workers == 1 {
+		for i := 0; i < n; i++ {
+			fn(i, i+1, timings)
+			if progress != nil {
+				progress(i+1, n)
+			}
+		}
+		return
+	}
+
+	// Workers claim chunks in order; progress is reported once per chunk,
+	// serialized so the processed count never goes backwards.
+	var next atomic.Int64
+	var mu sync.Mutex
+	processed := 0
+	workerTimings := make([]map[PoisonType]time.Duration, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		if timings != nil {
+			workerTimings[w] = make(map[PoisonType]time.Duration)
+		}
+		wg.Add(1)
+		go func(timings map[PoisonType]time.Duration) {
+			defer wg.Done()
+			for {
+				start := int(next.Add(chunkSize)) - chunkSize
+				if start >= n {
+					return
+				}
+				end := start + chunkSize
+				if end > n {
+					end = n
+				}
+				fn(start, end, timings)
+				if progress != nil {
+					mu.Lock()
+					processed += end - start
+					progress(processed, n)
+					mu.Unlock()
+				}
+			}
+		}(workerTimings[w])
+	}
+	wg.Wait()
+
+	for _, wt := range workerTimings {
+		for t, elapsed := range wt {
+			timings[t] += elapsed
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package detect
+
+import "math"
+
+// Partial scan modes.
+const (
+	PartialLimit  = "limit"
+	PartialSample = "sample"
+)
+
+// PartialScan describes a scan that covered only part of a dataset, with
+// estimates extrapolated to the full dataset.
+type PartialScan struct {
+	Mode              string  `json:"mode"`
+	ScannedSamples    int     `json:"scanned_samples"`
+	TotalSamples      int     `json:"total_samples"`
+	EstimatedPoisoned int     `json:"estimated_poisoned"`
+	EstimatedRate     float64 `json:"estimated_rate"`
+}
+
+// MarkPartial marks the result as a partial scan of a dataset with total
+// samples and extrapolates the poisoned count from the scanned samples.
+func (r *DetectionResult) MarkPartial(mode string, total int) {
+	partial := &PartialScan{
+		Mode:           mode,
+		ScannedSamples: r.SampleCount,
+		TotalSamples:   total,
+	}
+	if r.SampleCount > 0 {
+		partial.EstimatedRate = float64(r.PoisonedCount) / float64(r.SampleCount)
+		partial.EstimatedPoisoned = int(math.Round(partial.EstimatedRate * float64(total)))
+	}
+	r.Partial = partial
+}
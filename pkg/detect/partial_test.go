@@ -0,0 +1,28 @@
+package detect
+
+import "testing"
+
+func TestMarkPartial(t *testing.T) {
+	for _, test := range []struct {
+		name              string
+		scanned, poisoned int
+		total             int
+		rate              float64
+		estimated         int
+	}{
+		{"scaled", 100, 5, 1000, 0.05, 50},
+		{"rounded", 3, 1, 10, 1.0 / 3, 3},
+		{"clean", 50, 0, 500, 0, 0},
+		{"nothing scanned", 0, 0, 500, 0, 0},
+	} {
+		r := &DetectionResult{SampleCount: test.scanned, PoisonedCount: test.poisoned}
+		r.MarkPartial(PartialSample, test.total)
+		want := PartialScan{Mode: PartialSample, ScannedSamples: test.scanned, TotalSamples: test.total, EstimatedPoisoned: test.estimated, EstimatedRate: test.rate}
+		if r.Partial == nil || *r.Partial != want {
+			t.Errorf("%s: partial %+v, want %+v", test.name, r.Partial, want)
+		}
+		if r.SampleCount != test.scanned || r.PoisonedCount != test.poisoned {
+			t.Errorf("%s: counts changed to %d poisoned of %d", test.name, r.PoisonedCount, r.SampleCount)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package detect
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// WriteResult writes a detection result as indented JSON.
+func WriteResult(w io.Writer, result *DetectionResult) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+// ReadResult reads a detection result written by WriteResult. Results
+// written before results had per-class aggregates have them computed.
+func ReadResult(r io.Reader) (*DetectionResult, error) {
+	var result DetectionResult
+	if err := json.NewDecoder(r).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode result: %w", err)
+	}
+	if result.Classes == nil {
+		result.Classes = ClassBreakdown(&result)
+	}
+	return &result, nil
+}
+
+// SaveResult writes a detection result to a JSON file.
+func SaveResult(path string, result *DetectionResult) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := WriteResult(f, result); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// LoadResult reads a detection result from a JSON file.
+func LoadResult(path string) (*DetectionResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result, err := ReadResult(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return result, nil
+}
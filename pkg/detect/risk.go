@@ -0,0 +1,239 @@
+package detect
+
+import (
+	"fmt"
+	"math"
+)
+
+// RiskModel combines the findings of a scan into its risk score. The score
+// is a weighted sum of two rates over all scanned samples, capped at 1:
+//
+//	raw = RatioWeight × Σ w / n + ConfidenceWeight × Σ w × confidence / n
+//
+// where the sums run over the flagged samples, n is the number of samples
+// and w is the product of the weights of a finding's type and severity.
+// With a Calibration, the risk score is the probability it maps raw to.
+type RiskModel struct {
+	// RatioWeight weights the rate of flagged samples and ConfidenceWeight
+	// the mean confidence of findings over all samples.
+	RatioWeight      float64 `json:"ratio_weight" yaml:"ratio_weight"`
+	ConfidenceWeight float64 `json:"confidence_weight" yaml:"confidence_weight"`
+	// TypeWeights weights findings by poison type; types not listed
+	// weigh 1.
+	TypeWeights map[PoisonType]float64 `json:"type_weights,omitempty" yaml:"type_weights,omitempty"`
+	// SeverityWeights weights findings by the severity of their score,
+	// keyed by severity name; severities not listed weigh 1.
+	SeverityWeights map[string]float64 `json:"severity_weights,omitempty" yaml:"severity_weights,omitempty"`
+	// Calibration, when set, maps raw scores to probabilities.
+	Calibration *Calibration `json:"calibration,omitempty" yaml:"calibration,omitempty"`
+}
+
+// Calibration maps a raw risk score to the probability that the dataset is
+// poisoned with a logistic function, 1 / (1 + e^-(Slope × raw + Intercept)),
+// as fitted by Platt scaling on labeled scans.
+type Calibration struct {
+	Slope     float64 `json:"slope" yaml:"slope"`
+	Intercept float64 `json:"intercept" yaml:"intercept"`
+}
+
+// RiskAssessment records how a result's risk score was computed.
+type RiskAssessment struct {
+	Model RiskModel `json:"model"`
+	// Ratio is the weighted rate of flagged samples and Confidence the
+	// weighted mean confidence of findings, both over all samples.
+	Ratio      float64 `json:"ratio"`
+	Confidence float64 `json:"confidence"`
+	// Raw is the score before calibration.
+	Raw float64 `json:"raw_score"`
+}
+
+// DefaultRiskModel returns the risk model of new detectors: 70% the rate of
+// flagged samples and 30% their mean confidence, unweighted and
+// uncalibrated.
+func DefaultRiskModel() RiskModel {
+	return RiskModel{RatioWeight: 0.7, ConfidenceWeight: 0.3}
+}
+
+// Check reports invalid risk models: negative, non-finite or all-zero
+// weights, weights of unknown types or severities, and non-finite
+// calibrations.
+func (m *RiskModel) Check() error {
+	if !validWeight(m.RatioWeight) || !validWeight(m.ConfidenceWeight) {
+		return fmt.Errorf("ratio_weight and confidence_weight must be non-negative")
+	}
+	if m.RatioWeight == 0 && m.ConfidenceWeight == 0 {
+		return fmt.Errorf("ratio_weight or confidence_weight must be positive")
+	}
+	for t, w := range m.TypeWeights {
+		if !knownType(t) {
+			return fmt.Errorf("type_weights: unknown poison type %q", t)
+		}
+		if !validWeight(w) {
+			return fmt.Errorf("type_weights: weight of %s must be non-negative, got %v", t, w)
+		}
+	}
+	for name, w := range m.SeverityWeights {
+		if _, err := ParseSeverity(name); err != nil {
+			return fmt.Errorf("severity_weights: %w", err)
+		}
+		if !validWeight(w) {
+			return fmt.Errorf("severity_weights: weight of %s must be non-negative, got %v", name, w)
+		}
+	}
+	if c := m.Calibration; c != nil {
+		if math.IsNaN(c.Slope) || math.IsInf(c.Slope, 0) || math.IsNaN(c.Intercept) || math.IsInf(c.Intercept, 0) {
+			return fmt.Errorf("calibration: slope and intercept must be finite")
+		}
+	}
+	return nil
+}
+
+// Assess computes the risk of sampleCount scanned samples with findings,
+// which need only include the flagged samples. The risk score is the
+// probability of the returned assessment's calibration, or its raw score.
+func (m RiskModel) Assess(findings []PoisonedSample, sampleCount int) (float64, *RiskAssessment) {
+	weighted, confidence := 0.0, 0.0
+	for _, finding := range findings {
+		if !finding.IsPoisoned {
+			continue
+		}
+		w := m.weight(finding)
+		weighted += w
+		confidence += w * finding.Confidence
+	}
+	return m.assess(weighted, confidence, sampleCount)
+}
+
+// assess computes the risk of sampleCount samples from the sums of the
+// weights and weighted confidences of their findings.
+func (m RiskModel) assess(weighted, confidence float64, sampleCount int) (float64, *RiskAssessment) {
+	assessment := &RiskAssessment{Model: m}
+	if sampleCount == 0 {
+		return 0, assessment
+	}
+	n := float64(sampleCount)
+	assessment.Ratio = weighted / n
+	assessment.Confidence = confidence / n
+	assessment.Raw = math.Min(m.RatioWeight*assessment.Ratio+m.ConfidenceWeight*assessment.Confidence, 1)
+
+	if c := m.Calibration; c != nil {
+		return 1 / (1 + math.Exp(-(c.Slope*assessment.Raw + c.Intercept))), assessment
+	}
+	return assessment.Raw, assessment
+}
+
+// Tally summarizes a scan one finding at a time, keeping only counts and
+// sums, so the summary of a stream of any length takes constant memory.
+type Tally struct {
+	model      RiskModel
+	samples    int
+	poisoned   int
+	weighted   float64
+	confidence float64
+}
+
+// NewTally starts a summary scored with the detector's risk model.
+func (d *Detector) NewTally() *Tally {
+	return &Tally{model: d.risk.clone()}
+}
+
+// Add counts a scanned sample by its finding.
+func (t *Tally) Add(finding PoisonedSample) {
+	t.samples++
+	if !finding.IsPoisoned {
+		return
+	}
+	t.poisoned++
+	w := t.model.weight(finding)
+	t.weighted += w
+	t.confidence += w * finding.Confidence
+}
+
+// Result returns the result Summarize would return for the added findings,
+// without the findings themselves.
+func (t *Tally) Result() *DetectionResult {
+	result := &DetectionResult{
+		Method:        "ensemble_detection",
+		IsPoisoned:    t.poisoned > 0,
+		SampleCount:   t.samples,
+		PoisonedCount: t.poisoned,
+	}
+	result.RiskScore, result.Risk = t.model.assess(t.weighted, t.confidence, t.samples)
+	return result
+}
+
+// Rescore recomputes the poisoned count, risk score and class breakdown of
+// result after its findings were changed, as by post-processing. Results of
+// streamed scans keep their label counts.
+func (d *Detector) Rescore(result *DetectionResult) {
+	result.PoisonedCount = 0
+	for _, finding := range result.Samples {
+		if finding.IsPoisoned {
+			result.PoisonedCount++
+		}
+	}
+	result.IsPoisoned = result.PoisonedCount > 0
+	result.RiskScore, result.Risk = d.risk.Assess(result.Samples, result.SampleCount)
+	result.Classes = ClassBreakdown(result)
+}
+
+// weight returns the weight of a finding's type and severity.
+func (m RiskModel) weight(finding PoisonedSample) float64 {
+	w := 1.0
+	if tw, ok := m.TypeWeights[finding.Type]; ok {
+		w *= tw
+	}
+	if sw, ok := m.SeverityWeights[SeverityOf(finding.Score).String()]; ok {
+		w *= sw
+	}
+	return w
+}
+
+// clone returns a copy of m that shares none of its maps.
+func (m RiskModel) clone() RiskModel {
+	clone := m
+	if m.TypeWeights != nil {
+		clone.TypeWeights = make(map[PoisonType]float64, len(m.TypeWeights))
+		for t, w := range m.TypeWeights {
+			clone.TypeWeights[t] = w
+		}
+	}
+	if m.SeverityWeights != nil {
+		clone.SeverityWeights = make(map[string]float64, len(m.SeverityWeights))
+		for s, w := range m.SeverityWeights {
+			clone.SeverityWeights[s] = w
+		}
+	}
+	if m.Calibration != nil {
+		calibration := *m.Calibration
+		clone.Calibration = &calibration
+	}
+	return clone
+}
+
+// knownType reports whether t is one of the poison types.
+func knownType(t PoisonType) bool {
+	switch t {
+	case TypeBackdoor, TypeLabelFlip, TypeGradientPoison, TypeFeaturePoison, TypeDataPoison:
+		return true
+	}
+	return false
+}
+
+func validWeight(w float64) bool {
+	return w >= 0 && !math.IsInf(w, 0)
+}
+
+// RiskModel returns a copy of the detector's risk model.
+func (d *Detector) RiskModel() RiskModel {
+	return d.risk.clone()
+}
+
+// SetRiskModel sets the model combining findings into risk scores.
+func (d *Detector) SetRiskModel(m RiskModel) error {
+	if err := m.Check(); err != nil {
+		return err
+	}
+	d.risk = m.clone()
+	return nil
+}
@@ -0,0 +1,66 @@
+package detect
+
+// NewSample returns a sample with its own copy of features, so later
+// changes to the caller's slice do not change the sample.
+func NewSample(id string, label int, features []float64) Sample {
+	return Sample{ID: id, Label: label, Features: append([]float64(nil), features...)}
+}
+
+// Float32 returns s with its features stored in Features32 as float32,
+// halving their memory. The checks score float32 samples accumulating in
+// float64, so their scores differ from those of the float64 features only
+// by the rounding of the features. Sparse and float32 samples are returned
+// as is.
+func (s Sample) Float32() Sample {
+	if s.Sparse != nil || s.Features32 != nil {
+		return s
+	}
+	features := make([]float32, len(s.Features))
+	for j, v := range s.Features {
+		features[j] = float32(v)
+	}
+	s.Features, s.Features32 = nil, features
+	return s
+}
+
+// Meta returns the metadata value stored under key. It is safe on samples
+// without metadata.
+func (s Sample) Meta(key string) (interface{}, bool) {
+	v, ok := s.Metadata[key]
+	return v, ok
+}
+
+// WithMeta returns a copy of s with key set to value. The metadata is
+// copied on write, so s and any other sample sharing its metadata are not
+// changed; a sample without metadata gets a new map.
+func (s Sample) WithMeta(key string, value interface{}) Sample {
+	metadata := make(map[string]interface{}, len(s.Metadata)+1)
+	for k, v := range s.Metadata {
+		metadata[k] = v
+	}
+	metadata[key] = value
+	s.Metadata = metadata
+	return s
+}
+
+// Clone returns a copy of s sharing neither its features nor its metadata.
+func (s Sample) Clone() Sample {
+	clone := NewSample(s.ID, s.Label, s.Features)
+	if s.Features32 != nil {
+		clone.Features32 = append([]float32(nil), s.Features32...)
+	}
+	if s.Sparse != nil {
+		clone.Sparse = &SparseFeatures{
+			Dim:     s.Sparse.Dim,
+			Indices: append([]int(nil), s.Sparse.Indices...),
+			Values:  append([]float64(nil), s.Sparse.Values...),
+		}
+	}
+	if s.Metadata != nil {
+		clone.Metadata = make(map[string]interface{}, len(s.Metadata))
+		for k, v := range s.Metadata {
+			clone.Metadata[k] = v
+		}
+	}
+	return clone
+}
@@ -0,0 +1,275 @@
+package detect
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ShardSpec assigns the samples of a dataset to Count shards, sample i to
+// shard i mod Count, and selects the shards From through To. Interleaving
+// keeps shards balanced and lets each worker stream the whole dataset
+// without knowing its size.
+type ShardSpec struct {
+	From  int `json:"from"`
+	To    int `json:"to"`
+	Count int `json:"count"`
+}
+
+// ParseShardSpec parses a shard selection: "K/N" selects shard K of N, and
+// "K-L/N" shards K through L. Shards are numbered from 0.
+func ParseShardSpec(s string) (ShardSpec, error) {
+	shards, count, ok := strings.Cut(s, "/")
+	if !ok {
+		return ShardSpec{}, fmt.Errorf("invalid shard %q (want K/N or K-L/N)", s)
+	}
+	from, to, isRange := strings.Cut(shards, "-")
+	if !isRange {
+		to = from
+	}
+	var spec ShardSpec
+	var errs [3]error
+	spec.From, errs[0] = strconv.Atoi(from)
+	spec.To, errs[1] = strconv.Atoi(to)
+	spec.Count, errs[2] = strconv.Atoi(count)
+	for _, err := range errs {
+		if err != nil {
+			return ShardSpec{}, fmt.Errorf("invalid shard %q (want K/N or K-L/N)", s)
+		}
+	}
+	return spec, spec.Check()
+}
+
+// Check reports shard selections outside 0..Count-1 or out of order.
+func (s ShardSpec) Check() error {
+	if s.Count < 1 {
+		return fmt.Errorf("shard count must be at least 1, got %d", s.Count)
+	}
+	if s.From < 0 || s.From > s.To || s.To >= s.Count {
+		return fmt.Errorf("shards %d-%d not in 0-%d", s.From, s.To, s.Count-1)
+	}
+	return nil
+}
+
+// Contains reports whether the sample at row belongs to a selected shard.
+func (s ShardSpec) Contains(row int) bool {
+	shard := row % s.Count
+	return shard >= s.From && shard <= s.To
+}
+
+func (s ShardSpec) String() string {
+	if s.From == s.To {
+		return fmt.Sprintf("%d/%d", s.From, s.Count)
+	}
+	return fmt.Sprintf("%d-%d/%d", s.From, s.To, s.Count)
+}
+
+// ShardScan describes a result covering some shards of a dataset. Saved
+// while the scan runs, the result is a checkpoint the scan resumes from;
+// once complete, MergeShards combines it with the results of the other
+// shards.
+type ShardScan struct {
+	ShardSpec
+	// Fingerprint identifies the detector settings, which all the shards
+	// of a scan must share.
+	Fingerprint string `json:"fingerprint"`
+	// Next is the number of dataset rows read so far; scans resume from
+	// it. Complete is set once the whole dataset has been read, and Next
+	// is then its number of rows.
+	Next     int  `json:"next"`
+	Complete bool `json:"complete"`
+	// Rows holds the dataset row of each finding of the result's Samples.
+	Rows []int `json:"rows"`
+}
+
+// ShardStream analyzes the selected shards of a dataset pushed in chunks
+// of consecutive rows. Like a Stream it keeps only the flagged samples, and
+// its checkpoints let a scan stopped part way resume where it stopped.
+type ShardStream struct {
+	d      *Detector
+	spec   ShardSpec
+	row    int
+	resume int
+	count  int
+	labels map[int]int
+
+	findings []PoisonedSample
+	rows     []int
+}
+
+// NewShardStream starts or resumes a scan of the shards selected by spec.
+// checkpoint, when non-nil, is a result of an earlier scan of the same
+// shards with the same settings; the rows it covers are skipped.
+func (d *Detector) NewShardStream(spec ShardSpec, checkpoint *DetectionResult) (*ShardStream, error) {
+	if err := spec.Check(); err != nil {
+		return nil, err
+	}
+	s := &ShardStream{d: d, spec: spec, labels: make(map[int]int)}
+	if checkpoint == nil {
+		return s, nil
+	}
+
+	shard := checkpoint.Shard
+	switch {
+	case shard == nil:
+		return nil, fmt.Errorf("checkpoint is not a sharded result")
+	case shard.ShardSpec != spec:
+		return nil, fmt.Errorf("checkpoint covers shards %s, not %s", shard.ShardSpec, spec)
+	case shard.Fingerprint != d.Fingerprint():
+		return nil, fmt.Errorf("checkpoint was scanned with other detector settings")
+	case len(shard.Rows) != len(checkpoint.Samples):
+		return nil, fmt.Errorf("checkpoint has %d rows for %d findings", len(shard.Rows), len(checkpoint.Samples))
+	}
+	s.resume = shard.Next
+	s.count = checkpoint.SampleCount
+	for label, n := range checkpoint.LabelCounts {
+		s.labels[label] = n
+	}
+	s.findings = append(s.findings, checkpoint.Samples...)
+	s.rows = append(s.rows, shard.Rows...)
+	return s, nil
+}
+
+// Add analyzes a chunk of samples, the next consecutive rows of the
+// dataset. Only the samples of the selected shards are analyzed, and rows
+// covered by the checkpoint are skipped. Chunks are validated like those
+// of a Stream.
+func (s *ShardStream) Add(ctx context.Context, samples []Sample) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var selected []Sample
+	var rows []int
+	for i, sample := range samples {
+		row := s.row + i
+		if row >= s.resume && s.spec.Contains(row) {
+			selected = append(selected, sample)
+			rows = append(rows, row)
+		}
+	}
+	if err := s.d.Validate(selected); err != nil {
+		return err
+	}
+	var progress ProgressFunc
+	if s.d.progress != nil {
+		base := s.count
+		progress = func(processed, _ int) {
+			s.d.progress(base+processed, 0)
+		}
+	}
+	for i, finding := range s.d.analyzeAll(selected, nil, progress) {
+		s.labels[finding.Label]++
+		if finding.IsPoisoned {
+			s.findings = append(s.findings, finding)
+			s.rows = append(s.rows, rows[i])
+		}
+	}
+	s.count += len(selected)
+	s.row += len(samples)
+	return nil
+}
+
+// Checkpoint returns the result of the rows added so far, to be saved and
+// passed to NewShardStream if the scan stops.
+func (s *ShardStream) Checkpoint() *DetectionResult {
+	result := s.d.Summarize(append([]PoisonedSample(nil), s.findings...), s.count)
+	result.FlaggedOnly = true
+	result.LabelCounts = make(map[int]int, len(s.labels))
+	for label, n := range s.labels {
+		result.LabelCounts[label] = n
+	}
+	result.Classes = ClassBreakdown(result)
+	result.Shard = &ShardScan{
+		ShardSpec:   s.spec,
+		Fingerprint: s.d.Fingerprint(),
+		Next:        max(s.row, s.resume),
+		Rows:        append([]int(nil), s.rows...),
+	}
+	return result
+}
+
+// Result returns the result of the shards once the whole dataset has been
+// added.
+func (s *ShardStream) Result() *DetectionResult {
+	result := s.Checkpoint()
+	result.Shard.Complete = true
+	return result
+}
+
+// MergeShards combines the complete results of shards covering a dataset
+// into its result, with the findings in dataset order. The shards must
+// have been scanned with this detector's settings, together cover every
+// shard exactly once and agree on the dataset's number of rows.
+func (d *Detector) MergeShards(parts []*DetectionResult) (*DetectionResult, error) {
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("no shard results to merge")
+	}
+	fingerprint := d.Fingerprint()
+	first := parts[0].Shard
+	if first == nil {
+		return nil, fmt.Errorf("result 1 is not a sharded result")
+	}
+	covered := make([]bool, first.Count)
+
+	type located struct {
+		row     int
+		finding PoisonedSample
+	}
+	var findings []located
+	labels := make(map[int]int)
+	count := 0
+	for i, part := range parts {
+		shard := part.Shard
+		switch {
+		case shard == nil:
+			return nil, fmt.Errorf("result %d is not a sharded result", i+1)
+		case !shard.Complete:
+			return nil, fmt.Errorf("result %d: shards %s are not complete (%d rows read)", i+1, shard.ShardSpec, shard.Next)
+		case shard.Fingerprint != fingerprint:
+			return nil, fmt.Errorf("result %d: shards %s were scanned with other detector settings", i+1, shard.ShardSpec)
+		case shard.Count != first.Count:
+			return nil, fmt.Errorf("result %d: %d shards, want %d", i+1, shard.Count, first.Count)
+		case shard.Check() != nil:
+			return nil, fmt.Errorf("result %d: %w", i+1, shard.Check())
+		case shard.Next != first.Next:
+			return nil, fmt.Errorf("result %d: %d rows, want %d; the shards scanned different datasets", i+1, shard.Next, first.Next)
+		case len(shard.Rows) != len(part.Samples):
+			return nil, fmt.Errorf("result %d: %d rows for %d findings", i+1, len(shard.Rows), len(part.Samples))
+		}
+		for k := shard.From; k <= shard.To; k++ {
+			if covered[k] {
+				return nil, fmt.Errorf("result %d: shard %d is covered twice", i+1, k)
+			}
+			covered[k] = true
+		}
+		for j, finding := range part.Samples {
+			findings = append(findings, located{row: shard.Rows[j], finding: finding})
+		}
+		for label, n := range part.LabelCounts {
+			labels[label] += n
+		}
+		count += part.SampleCount
+	}
+	var missing []string
+	for k, ok := range covered {
+		if !ok {
+			missing = append(missing, strconv.Itoa(k))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("shards %s of %d are missing", strings.Join(missing, ", "), first.Count)
+	}
+
+	sort.Slice(findings, func(i, j int) bool { return findings[i].row < findings[j].row })
+	samples := make([]PoisonedSample, len(findings))
+	for i, f := range findings {
+		samples[i] = f.finding
+	}
+	result := d.Summarize(samples, count)
+	result.FlaggedOnly = true
+	result.LabelCounts = labels
+	result.Classes = ClassBreakdown(result)
+	return result, nil
+}
@@ -0,0 +1,141 @@
+package detect
+
+import (
+	"fmt"
+	"math"
+)
+
+// SparseFeatures holds the features of a sample that are mostly zero, such
+// as bag-of-words or hashed text features, as index/value pairs. Features
+// not listed are 0. The checks score sparse samples in time proportional
+// to the number of listed features, however many features there are.
+// Frames, and so profiles and drift reports, read dense features only;
+// Dense converts sparse samples for them.
+type SparseFeatures struct {
+	// Dim is the number of features, listed or not.
+	Dim int
+	// Indices holds the positions of the listed features, in increasing
+	// order, and Values their values.
+	Indices []int
+	Values  []float64
+}
+
+// NewSparseSample returns a sample with its own copy of the sparse
+// features given, checked with Check.
+func NewSparseSample(id string, label int, dim int, indices []int, values []float64) (Sample, error) {
+	sparse := &SparseFeatures{
+		Dim:     dim,
+		Indices: append([]int(nil), indices...),
+		Values:  append([]float64(nil), values...),
+	}
+	if err := sparse.Check(); err != nil {
+		return Sample{}, err
+	}
+	return Sample{ID: id, Label: label, Sparse: sparse}, nil
+}
+
+// Check reports indices out of order or outside 0..Dim-1, and indices and
+// values of different lengths.
+func (s *SparseFeatures) Check() error {
+	if len(s.Indices) != len(s.Values) {
+		return fmt.Errorf("%d indices for %d values", len(s.Indices), len(s.Values))
+	}
+	for k, i := range s.Indices {
+		if i < 0 || i >= s.Dim {
+			return fmt.Errorf("feature index %d not in 0-%d", i, s.Dim-1)
+		}
+		if k > 0 && i <= s.Indices[k-1] {
+			return fmt.Errorf("feature index %d after %d; indices must increase", i, s.Indices[k-1])
+		}
+	}
+	return nil
+}
+
+// Dim returns the number of features of the sample, dense or sparse.
+func (s Sample) Dim() int {
+	if s.Sparse != nil {
+		return s.Sparse.Dim
+	}
+	if s.Features32 != nil {
+		return len(s.Features32)
+	}
+	return len(s.Features)
+}
+
+// Dense returns the features of the sample as a dense slice: Features, or
+// a new slice for sparse and float32 samples.
+func (s Sample) Dense() []float64 {
+	if s.Features32 != nil {
+		dense := make([]float64, len(s.Features32))
+		for j, v := range s.Features32 {
+			dense[j] = float64(v)
+		}
+		return dense
+	}
+	if s.Sparse == nil {
+		return s.Features
+	}
+	dense := make([]float64, s.Sparse.Dim)
+	for k, i := range s.Sparse.Indices {
+		dense[i] = s.Sparse.Values[k]
+	}
+	return dense
+}
+
+// zeros returns the number of unlisted, zero, features.
+func (s *SparseFeatures) zeros() float64 {
+	return float64(s.Dim - len(s.Values))
+}
+
+// featureMoments returns the mean and population standard deviation of
+// the features of sample, dense, float32 or sparse.
+func featureMoments(sample Sample) (mean, stdDev float64) {
+	s := sample.Sparse
+	if s == nil {
+		if sample.Features32 != nil {
+			return moments(sample.Features32)
+		}
+		return moments(sample.Features)
+	}
+	if s.Dim == 0 {
+		return 0, 0
+	}
+	n := float64(s.Dim)
+	mean = sum(s.Values) / n
+	squares := sumSquaredDev(s.Values, mean) + s.zeros()*mean*mean
+	return mean, math.Sqrt(squares / n)
+}
+
+// featureMaxAbsDev returns the largest absolute deviation of the features
+// of sample from mean.
+func featureMaxAbsDev(sample Sample, mean float64) float64 {
+	s := sample.Sparse
+	if s == nil {
+		if sample.Features32 != nil {
+			return maxAbsDev(sample.Features32, mean)
+		}
+		return maxAbsDev(sample.Features, mean)
+	}
+	m := maxAbsDev(s.Values, mean)
+	if s.zeros() > 0 {
+		m = math.Max(m, math.Abs(mean))
+	}
+	return m
+}
+
+// featureCountAbsDevAbove returns the number of features of sample
+// deviating from mean by more than limit.
+func featureCountAbsDevAbove(sample Sample, mean, limit float64) int {
+	s := sample.Sparse
+	if s == nil {
+		if sample.Features32 != nil {
+			return countAbsDevAbove(sample.Features32, mean, limit)
+		}
+		return countAbsDevAbove(sample.Features, mean, limit)
+	}
+	n := countAbsDevAbove(s.Values, mean, limit)
+	if math.Abs(mean) > limit {
+		n += s.Dim - len(s.Values)
+	}
+	return n
+}
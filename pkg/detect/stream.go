@@ -0,0 +1,60 @@
+package detect
+
+import "context"
+
+// Stream analyzes a dataset pushed in chunks. Only the flagged samples and
+// counts are kept, so memory does not grow with the number of samples
+// analyzed. Since every check scores a sample on its own, a Stream flags
+// the same samples as Detect and computes the same risk score.
+type Stream struct {
+	d        *Detector
+	count    int
+	labels   map[int]int
+	findings []PoisonedSample
+}
+
+// NewStream starts a streaming analysis. Progress is reported with an
+// unknown total as chunks are added.
+func (d *Detector) NewStream() *Stream {
+	return &Stream{d: d, labels: make(map[int]int)}
+}
+
+// Add analyzes a chunk of samples. Chunks are analyzed by the detector's
+// workers like the samples passed to Detect. Chunks are validated first,
+// so Add fails on non-finite features under NonFiniteError.
+func (s *Stream) Add(ctx context.Context, samples []Sample) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := s.d.Validate(samples); err != nil {
+		return err
+	}
+	var progress ProgressFunc
+	if s.d.progress != nil {
+		base := s.count
+		progress = func(processed, _ int) {
+			s.d.progress(base+processed, 0)
+		}
+	}
+	for _, finding := range s.d.analyzeAll(samples, nil, progress) {
+		s.labels[finding.Label]++
+		if finding.IsPoisoned {
+			s.findings = append(s.findings, finding)
+		}
+	}
+	s.count += len(samples)
+	return nil
+}
+
+// Result summarizes the samples added so far. Its Samples hold only the
+// flagged samples.
+func (s *Stream) Result() *DetectionResult {
+	result := s.d.Summarize(s.findings, s.count)
+	result.FlaggedOnly = true
+	result.LabelCounts = make(map[int]int, len(s.labels))
+	for label, n := range s.labels {
+		result.LabelCounts[label] = n
+	}
+	result.Classes = ClassBreakdown(result)
+	return result
+}
@@ -0,0 +1,144 @@
+package detect
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// DefaultReportTemplate is the text/template source of the detection report
+// produced by GenerateReport. Custom templates are executed with the
+// *DetectionResult as data and may use the functions in TemplateFuncs.
+const DefaultReportTemplate = `=== Model Poisoning Detection Report ===
+
+Total Samples: {{.SampleCount}}
+Poisoned Samples: {{.PoisonedCount}}
+Risk Score: {{percent .RiskScore}}
+Method: {{.Method}}
+
+{{with .Version -}}
+Dataset Version: {{.Hash}} ({{.Source}}){{if .Modified}} - MODIFIED since it was tracked{{end}}
+
+{{end -}}
+{{with .Partial -}}
+PARTIAL SCAN ({{.Mode}}): {{.ScannedSamples}} of {{.TotalSamples}} samples scanned
+Estimated Poisoned Samples: {{.EstimatedPoisoned}} ({{percent .EstimatedRate 1}}, extrapolated)
+
+{{end -}}
+{{with .Filter -}}
+Showing {{.Shown}} of {{.Flagged}} flagged samples
+{{- if .MinSeverity}}, severity >= {{.MinSeverity}}{{end}}
+{{- if .Types}}, types: {{join .Types}}{{end}}
+{{- if .Top}}, top {{.Top}} by score{{end}}
+
+{{end -}}
+{{if .PoisonedCount}}{{with .Classes -}}
+Findings by Class:
+  Class      Samples  Flagged     Rate    Share  Mean Score  Dominant Type
+{{range .}}{{if .Flagged -}}
+{{printf "  %-8d %9d %8d %8s %8s %11s  %s" .Label .Samples .Flagged (percent .FlaggedRate 1) (percent .Share 1) (percent .MeanScore) .DominantType}}
+{{end}}{{end}}
+{{end}}{{end -}}
+{{with flagged .Samples -}}
+Detected Poisoned Samples:
+{{range $i, $s := . -}}
+[{{inc $i}}] {{.Type}}
+    ID: {{.ID}}
+    Type: {{.Type}}
+    Score: {{percent .Score}}
+    Severity: {{severity .Score}}
+    Description: {{.Description}}
+    Evidence: {{.Evidence}}
+{{- with .ATLAS}}
+    MITRE ATLAS: {{join .}}
+{{- end}}
+{{- with .Origin}}
+    Origin: {{.Entity}}{{with .Activity}}, generated by {{.}}{{end}}{{with .Agent}}, agent {{.}}{{end}}
+{{- end}}
+{{- if .Review}}
+    Review: {{.Review}}{{if .ReviewNote}} ({{.ReviewNote}}){{end}}
+{{- end}}
+
+{{end -}}
+{{end -}}
+`
+
+// TemplateFuncs are the functions available to report templates:
+//
+//	percent V [DIGITS]  formats a 0..1 value as a percentage
+//	flagged SAMPLES     returns the samples flagged as poisoned
+//	severity SCORE      returns the severity name of a score
+//	join LIST           joins a list of poison types or strings with commas
+//	inc N               returns N+1, for 1-based numbering
+//	upper S, lower S    change the case of a string
+//	pad N S             left-justifies S in N columns
+var TemplateFuncs = template.FuncMap{
+	"percent": func(v float64, digits ...int) string {
+		precision := 0
+		if len(digits) > 0 {
+			precision = digits[0]
+		}
+		return fmt.Sprintf("%.*f%%", precision, v*100)
+	},
+	"flagged": func(samples []PoisonedSample) []PoisonedSample {
+		var flagged []PoisonedSample
+		for _, sample := range samples {
+			if sample.IsPoisoned {
+				flagged = append(flagged, sample)
+			}
+		}
+		return flagged
+	},
+	"severity": func(score float64) string { return SeverityOf(score).String() },
+	"join": func(list interface{}) (string, error) {
+		switch list := list.(type) {
+		case []string:
+			return strings.Join(list, ", "), nil
+		case []PoisonType:
+			names := make([]string, len(list))
+			for i, t := range list {
+				names[i] = string(t)
+			}
+			return strings.Join(names, ", "), nil
+		}
+		return "", fmt.Errorf("join: cannot join %T", list)
+	},
+	"inc":   func(n int) int { return n + 1 },
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"pad":   func(n int, s interface{}) string { return fmt.Sprintf("%-*v", n, s) },
+}
+
+// defaultReport is the parsed DefaultReportTemplate.
+var defaultReport = template.Must(ParseReportTemplate(DefaultReportTemplate))
+
+// ParseReportTemplate parses a report template.
+func ParseReportTemplate(text string) (*template.Template, error) {
+	return template.New("report").Funcs(TemplateFuncs).Parse(text)
+}
+
+// LoadReportTemplate parses the report template in the file at path.
+func LoadReportTemplate(path string) (*template.Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := ParseReportTemplate(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return tmpl, nil
+}
+
+// RenderReport executes a report template for result, using the default
+// report layout when tmpl is nil.
+func RenderReport(w io.Writer, tmpl *template.Template, result *DetectionResult) error {
+	if tmpl == nil {
+		tmpl = defaultReport
+	}
+	return tmpl.Execute(w, result)
+}
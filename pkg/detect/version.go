@@ -0,0 +1,37 @@
+package detect
+
+// DatasetVersion identifies the revision of a dataset tracked by a data
+// versioning tool such as DVC, tying findings to the exact data scanned.
+type DatasetVersion struct {
+	// Path is the dataset path as tracked, relative to the repository root.
+	Path string `json:"path"`
+	// Hash is the content hash recorded by the tool, such as
+	// "md5:3a6e1f...".
+	Hash string `json:"hash"`
+	// Source names the metadata the hash was read from, such as
+	// "data/train.csv.dvc" or "dvc.lock (stage prepare)".
+	Source string `json:"source"`
+	// Modified is set when the scanned file no longer matches Hash, as
+	// when it was changed without being re-added.
+	Modified bool `json:"modified,omitempty"`
+}
+
+// Origin is the recorded provenance of a sample: the activity, such as a
+// pipeline step, that generated it and the agent responsible, as read from
+// W3C PROV metadata.
+type Origin struct {
+	// Entity is the PROV entity describing the sample, or the dataset when
+	// the sample has no record of its own.
+	Entity string `json:"entity"`
+	// Activity generated the entity, following Plan when one was recorded.
+	Activity string `json:"activity,omitempty"`
+	Plan     string `json:"plan,omitempty"`
+	// Agent is the agent the entity is attributed to or that ran its
+	// activity, and OnBehalfOf the agent it acted for.
+	Agent      string `json:"agent,omitempty"`
+	OnBehalfOf string `json:"on_behalf_of,omitempty"`
+	// GeneratedAt is the time the entity was generated, as recorded.
+	GeneratedAt string `json:"generated_at,omitempty"`
+	// DerivedFrom lists the entities the entity was derived from.
+	DerivedFrom []string `json:"derived_from,omitempty"`
+}
@@ -0,0 +1,343 @@
+// Package drift compares dataset versions and attributes risk changes to
+// specific samples and features.
+package drift
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Sample change statuses.
+const (
+	StatusAdded    = "added"
+	StatusRemoved  = "removed"
+	StatusModified = "modified"
+)
+
+// FeatureDrift describes how one feature's distribution changed.
+type FeatureDrift struct {
+	Index     int     `json:"index"`
+	Name      string  `json:"name"`
+	OldMean   float64 `json:"old_mean"`
+	NewMean   float64 `json:"new_mean"`
+	OldStdDev float64 `json:"old_std_dev"`
+	NewStdDev float64 `json:"new_std_dev"`
+	MeanShift float64 `json:"mean_shift"`
+	KS        float64 `json:"ks"`
+	Flagged   int     `json:"flagged"`
+}
+
+// SampleAttribution attributes a changed sample to its most deviant feature
+// relative to the old dataset.
+type SampleAttribution struct {
+	ID      string            `json:"id"`
+	Status  string            `json:"status"`
+	Feature int               `json:"feature"`
+	Name    string            `json:"feature_name"`
+	ZScore  float64           `json:"z_score"`
+	Flagged bool              `json:"flagged"`
+	Type    detect.PoisonType `json:"type,omitempty"`
+	Score   float64           `json:"score"`
+}
+
+// Comparison contains the result of comparing two dataset versions.
+type Comparison struct {
+	OldCount int                 `json:"old_count"`
+	NewCount int                 `json:"new_count"`
+	Added    int                 `json:"added"`
+	Removed  int                 `json:"removed"`
+	Modified int                 `json:"modified"`
+	Features []FeatureDrift      `json:"features"`
+	Samples  []SampleAttribution `json:"samples"`
+	Diff     *detect.ResultDiff  `json:"diff"`
+	// Forensics, when set, isolates the changed samples that raised the
+	// risk of the new version.
+	Forensics *Forensics `json:"forensics,omitempty"`
+}
+
+// Compare compares an old and a new dataset version along with their
+// detection results. Samples are matched by ID. Features are sorted by
+// absolute standardized mean shift, largest first.
+func Compare(names []string, oldSamples, newSamples []detect.Sample, oldResult, newResult *detect.DetectionResult) *Comparison {
+	comparison := &Comparison{
+		OldCount: len(oldSamples),
+		NewCount: len(newSamples),
+		Diff:     detect.DiffResults(oldResult, newResult),
+	}
+
+	width := featureWidth(oldSamples, newSamples)
+	oldColumns := columns(oldSamples, width)
+	newColumns := columns(newSamples, width)
+
+	for i := 0; i < width; i++ {
+		oldMean, oldStdDev := meanStdDev(oldColumns[i])
+		newMean, newStdDev := meanStdDev(newColumns[i])
+
+		shift := 0.0
+		if oldStdDev > 0 {
+			shift = (newMean - oldMean) / oldStdDev
+		}
+
+		comparison.Features = append(comparison.Features, FeatureDrift{
+			Index:     i,
+			Name:      featureName(names, i),
+			OldMean:   oldMean,
+			NewMean:   newMean,
+			OldStdDev: oldStdDev,
+			NewStdDev: newStdDev,
+			MeanShift: shift,
+			KS:        ksStatistic(oldColumns[i], newColumns[i]),
+		})
+	}
+
+	oldByID := make(map[string]detect.Sample, len(oldSamples))
+	for _, sample := range oldSamples {
+		oldByID[sample.ID] = sample
+	}
+	newFindings := findingsByID(newResult)
+	oldFindings := findingsByID(oldResult)
+
+	seen := make(map[string]bool, len(newSamples))
+	for _, sample := range newSamples {
+		seen[sample.ID] = true
+
+		status := StatusAdded
+		if old, ok := oldByID[sample.ID]; ok {
+			if sameSample(old, sample) {
+				continue
+			}
+			status = StatusModified
+			comparison.Modified++
+		} else {
+			comparison.Added++
+		}
+
+		attribution := comparison.attribute(sample, status, names)
+		if finding, ok := newFindings[sample.ID]; ok {
+			attribution.Flagged = true
+			attribution.Type = finding.Type
+			attribution.Score = finding.Score
+			if attribution.Feature >= 0 {
+				comparison.featureByIndex(attribution.Feature).Flagged++
+			}
+		}
+		comparison.Samples = append(comparison.Samples, attribution)
+	}
+
+	for _, sample := range oldSamples {
+		if seen[sample.ID] {
+			continue
+		}
+		comparison.Removed++
+
+		attribution := SampleAttribution{ID: sample.ID, Status: StatusRemoved, Feature: -1}
+		if finding, ok := oldFindings[sample.ID]; ok {
+			attribution.Flagged = true
+			attribution.Type = finding.Type
+			attribution.Score = finding.Score
+		}
+		comparison.Samples = append(comparison.Samples, attribution)
+	}
+
+	sort.SliceStable(comparison.Features, func(i, j int) bool {
+		return math.Abs(comparison.Features[i].MeanShift) > math.Abs(comparison.Features[j].MeanShift)
+	})
+
+	return comparison
+}
+
+// attribute finds the feature of sample deviating most from the old
+// dataset's distribution. Non-finite features cannot be attributed.
+func (c *Comparison) attribute(sample detect.Sample, status string, names []string) SampleAttribution {
+	attribution := SampleAttribution{ID: sample.ID, Status: status, Feature: -1}
+
+	for i, value := range sample.Features {
+		feature := c.featureByIndex(i)
+		if feature == nil || feature.OldStdDev == 0 || !finite(value) {
+			continue
+		}
+		z := (value - feature.OldMean) / feature.OldStdDev
+		if attribution.Feature < 0 || math.Abs(z) > math.Abs(attribution.ZScore) {
+			attribution.Feature = i
+			attribution.Name = featureName(names, i)
+			attribution.ZScore = z
+		}
+	}
+
+	return attribution
+}
+
+// featureByIndex returns the drift entry for a feature index. It must be
+// called before the features are sorted.
+func (c *Comparison) featureByIndex(index int) *FeatureDrift {
+	if index < 0 || index >= len(c.Features) {
+		return nil
+	}
+	return &c.Features[index]
+}
+
+// findingsByID indexes the flagged samples of a result by ID.
+func findingsByID(result *detect.DetectionResult) map[string]detect.PoisonedSample {
+	findings := make(map[string]detect.PoisonedSample)
+	for _, sample := range result.Samples {
+		if sample.IsPoisoned {
+			findings[sample.ID] = sample
+		}
+	}
+	return findings
+}
+
+// sameSample reports whether two samples have identical labels and
+// features. Missing (NaN) features are the same as each other.
+func sameSample(a, b detect.Sample) bool {
+	if a.Label != b.Label || len(a.Features) != len(b.Features) {
+		return false
+	}
+	for i := range a.Features {
+		if a.Features[i] != b.Features[i] && !(math.IsNaN(a.Features[i]) && math.IsNaN(b.Features[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// featureWidth returns the widest feature vector across both datasets.
+func featureWidth(sets ...[]detect.Sample) int {
+	width := 0
+	for _, samples := range sets {
+		for _, sample := range samples {
+			if len(sample.Features) > width {
+				width = len(sample.Features)
+			}
+		}
+	}
+	return width
+}
+
+// columns transposes sample features into per-feature columns, leaving out
+// NaN and infinite values so one of them does not make a feature's drift
+// statistics NaN.
+func columns(samples []detect.Sample, width int) [][]float64 {
+	frame := detect.NewFrame(samples)
+	cols := make([][]float64, width)
+	for i := range cols {
+		cols[i], _, _ = frame.Finite(i, nil)
+	}
+	return cols
+}
+
+// finite reports whether v is neither NaN nor infinite.
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// meanStdDev calculates mean and standard deviation of values.
+func meanStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+
+	return mean, math.Sqrt(variance / float64(len(values)))
+}
+
+// ksStatistic calculates the two-sample Kolmogorov-Smirnov statistic.
+func ksStatistic(a, b []float64) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	x := append([]float64(nil), a...)
+	y := append([]float64(nil), b...)
+	sort.Float64s(x)
+	sort.Float64s(y)
+
+	maxDiff := 0.0
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		v := math.Min(x[i], y[j])
+		for i < len(x) && x[i] <= v {
+			i++
+		}
+		for j < len(y) && y[j] <= v {
+			j++
+		}
+		diff := math.Abs(float64(i)/float64(len(x)) - float64(j)/float64(len(y)))
+		if diff > maxDiff {
+			maxDiff = diff
+		}
+	}
+
+	return maxDiff
+}
+
+// featureName returns the name of feature i, or a positional name.
+func featureName(names []string, i int) string {
+	if i < len(names) && names[i] != "" {
+		return names[i]
+	}
+	return fmt.Sprintf("feature_%d", i)
+}
+
+// GenerateReport generates a dataset comparison report. At most top
+// features are listed; zero lists all of them.
+func GenerateReport(c *Comparison, top int) string {
+	var report string
+
+	report += "=== Dataset Comparison Report ===\n\n"
+	report += fmt.Sprintf("Samples: %d -> %d\n", c.OldCount, c.NewCount)
+	report += fmt.Sprintf("Added: %d  Removed: %d  Modified: %d\n", c.Added, c.Removed, c.Modified)
+	report += fmt.Sprintf("Risk Score: %.0f%% -> %.0f%% (%+.0f%%)\n",
+		c.Diff.OldRiskScore*100, c.Diff.NewRiskScore*100, c.Diff.RiskDelta*100)
+	report += fmt.Sprintf("Newly Flagged: %d  Resolved: %d\n\n", len(c.Diff.NewlyFlagged), len(c.Diff.Resolved))
+
+	if len(c.Features) > 0 {
+		report += "Feature Drift:\n"
+		report += fmt.Sprintf("  %-20s %10s %10s %8s %6s %8s\n", "Feature", "Old Mean", "New Mean", "Shift", "KS", "Flagged")
+		for i, feature := range c.Features {
+			if top > 0 && i >= top {
+				break
+			}
+			report += fmt.Sprintf("  %-20s %10.3f %10.3f %+8.2f %6.2f %8d\n",
+				feature.Name, feature.OldMean, feature.NewMean, feature.MeanShift, feature.KS, feature.Flagged)
+		}
+		report += "\n"
+	}
+
+	flagged := 0
+	for _, sample := range c.Samples {
+		if sample.Flagged {
+			flagged++
+		}
+	}
+
+	if flagged > 0 {
+		report += "Flagged Changed Samples:\n"
+		for _, sample := range c.Samples {
+			if !sample.Flagged {
+				continue
+			}
+			report += fmt.Sprintf("  [%s] %s  %s  %.0f%%", sample.Status, sample.ID, sample.Type, sample.Score*100)
+			if sample.Feature >= 0 {
+				report += fmt.Sprintf("  %s (z=%+.1f)", sample.Name, sample.ZScore)
+			}
+			report += "\n"
+		}
+		report += "\n"
+	}
+
+	return report
+}
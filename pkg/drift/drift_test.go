@@ -0,0 +1,150 @@
+package drift
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// baseline returns n samples of three features with small variations
+// around 1, 2 and 3.
+func baseline(n int) []detect.Sample {
+	samples := make([]detect.Sample, n)
+	for i := range samples {
+		d := float64(i%5-2) * 0.1
+		samples[i] = detect.Sample{ID: fmt.Sprintf("s%d", i), Features: []float64{1 + d, 2 - d, 3 + d}, Label: i % 2}
+	}
+	return samples
+}
+
+// resultOf returns a detection result flagging the samples of findings
+// with their scores, and the given risk.
+func resultOf(samples []detect.Sample, risk float64, findings map[string]float64) *detect.DetectionResult {
+	result := &detect.DetectionResult{SampleCount: len(samples), RiskScore: risk}
+	for _, sample := range samples {
+		finding := detect.PoisonedSample{ID: sample.ID, Label: sample.Label}
+		if score, ok := findings[sample.ID]; ok {
+			finding.IsPoisoned, finding.Score, finding.Type = true, score, detect.TypeBackdoor
+			result.PoisonedCount++
+		}
+		result.Samples = append(result.Samples, finding)
+	}
+	result.IsPoisoned = result.PoisonedCount > 0
+	return result
+}
+
+func TestCompare(t *testing.T) {
+	oldSamples := baseline(20)
+	newSamples := append([]detect.Sample(nil), oldSamples[:19]...)
+	// s0 is modified in feature 2 and s20 added with an outlying
+	// feature 1; s19 is removed.
+	newSamples[0] = detect.Sample{ID: "s0", Features: []float64{0.8, 2.2, 9}, Label: 0}
+	newSamples = append(newSamples, detect.Sample{ID: "s20", Features: []float64{1, -5, 3}, Label: 1})
+	// s3 keeps its features but changes label.
+	newSamples[3].Label = 0
+
+	oldResult := resultOf(oldSamples, 0, map[string]float64{"s19": 0.8})
+	newResult := resultOf(newSamples, 0.1, map[string]float64{"s0": 0.9, "s20": 0.7})
+	c := Compare([]string{"a", "b"}, oldSamples, newSamples, oldResult, newResult)
+
+	if c.OldCount != 20 || c.NewCount != 20 || c.Added != 1 || c.Removed != 1 || c.Modified != 2 {
+		t.Errorf("counts %+v", c)
+	}
+	if len(c.Diff.NewlyFlagged) != 2 || len(c.Diff.Resolved) != 1 {
+		t.Errorf("diff %+v", c.Diff)
+	}
+
+	// Features are sorted by absolute mean shift; the second moved most,
+	// down.
+	if len(c.Features) != 3 || c.Features[0].Index != 1 || c.Features[0].Name != "b" || c.Features[0].MeanShift >= 0 {
+		t.Fatalf("features %+v", c.Features)
+	}
+	for k := 1; k < len(c.Features); k++ {
+		if math.Abs(c.Features[k].MeanShift) > math.Abs(c.Features[k-1].MeanShift) {
+			t.Errorf("features %+v are not sorted by shift", c.Features)
+		}
+	}
+
+	byID := make(map[string]SampleAttribution)
+	for _, s := range c.Samples {
+		byID[s.ID] = s
+	}
+	if len(byID) != 4 {
+		t.Fatalf("attributed %+v, want s0, s3, s19 and s20", c.Samples)
+	}
+	if s := byID["s0"]; s.Status != StatusModified || s.Feature != 2 || s.ZScore <= 0 || !s.Flagged || s.Score != 0.9 {
+		t.Errorf("s0 attributed %+v", s)
+	}
+	if s := byID["s20"]; s.Status != StatusAdded || s.Feature != 1 || s.Name != "b" || s.ZScore >= 0 || !s.Flagged {
+		t.Errorf("s20 attributed %+v", s)
+	}
+	if s := byID["s3"]; s.Status != StatusModified || s.Flagged {
+		t.Errorf("s3 attributed %+v", s)
+	}
+	if s := byID["s19"]; s.Status != StatusRemoved || s.Feature != -1 || !s.Flagged || s.Score != 0.8 {
+		t.Errorf("s19 attributed %+v", s)
+	}
+	flagged := make(map[int]int)
+	for _, f := range c.Features {
+		flagged[f.Index] = f.Flagged
+	}
+	if flagged[1] != 1 || flagged[2] != 1 || flagged[0] != 0 {
+		t.Errorf("flagged samples by feature %v", flagged)
+	}
+
+	report := GenerateReport(c, 2)
+	for _, want := range []string{
+		"Samples: 20 -> 20",
+		"Added: 1  Removed: 1  Modified: 2",
+		"Risk Score: 0% -> 10% (+10%)",
+		"Newly Flagged: 2  Resolved: 1",
+		"[modified] s0  backdoor  90%  feature_2",
+		"[removed] s19  backdoor  80%\n",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report lacks %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "\n  a ") {
+		t.Errorf("report lists more than the top 2 features:\n%s", report)
+	}
+}
+
+func TestCompareNonFinite(t *testing.T) {
+	oldSamples := baseline(10)
+	oldSamples[4].Features[0] = math.NaN()
+	newSamples := baseline(10)
+	newSamples[4].Features[0] = math.NaN()
+	newSamples[5].Features = []float64{math.Inf(1), 2, 3.5}
+	c := Compare(nil, oldSamples, newSamples, resultOf(oldSamples, 0, nil), resultOf(newSamples, 0, nil))
+	if c.Modified != 1 {
+		t.Errorf("%d samples modified, want the one with an infinite feature", c.Modified)
+	}
+	for _, f := range c.Features {
+		if math.IsNaN(f.MeanShift) || math.IsNaN(f.OldMean) || math.IsNaN(f.NewMean) {
+			t.Errorf("feature %+v has NaN statistics", f)
+		}
+	}
+	if s := c.Samples[0]; s.Feature != 2 {
+		t.Errorf("s5 attributed to feature %d, want the finite feature 2", s.Feature)
+	}
+}
+
+func TestKSStatistic(t *testing.T) {
+	for _, test := range []struct {
+		a, b []float64
+		want float64
+	}{
+		{[]float64{1, 2, 3}, []float64{1, 2, 3}, 0},
+		{[]float64{1, 2, 3}, []float64{4, 5, 6}, 1},
+		{[]float64{1, 2, 3, 4}, []float64{3, 4, 5, 6}, 0.5},
+		{nil, []float64{1}, 0},
+	} {
+		if got := ksStatistic(test.a, test.b); math.Abs(got-test.want) > 1e-12 {
+			t.Errorf("ksStatistic(%v, %v) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package drift
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// DefaultTolerance is the risk above the clean version's that Isolate
+// accepts as back to baseline.
+const DefaultTolerance = 0.01
+
+// ForensicsOptions configures Isolate.
+type ForensicsOptions struct {
+	// Rescan scans samples with the detector that scanned both versions.
+	Rescan func(samples []detect.Sample) (*detect.DetectionResult, error)
+	// Times holds the time of each sample of the suspect version, if
+	// known; zero times are unknown.
+	Times []time.Time
+	// Tolerance is the risk above the clean version's accepted as
+	// baseline; zero means DefaultTolerance.
+	Tolerance float64
+}
+
+// Culprit is a changed sample that introduced risk into the suspect
+// version.
+type Culprit struct {
+	ID     string            `json:"id"`
+	Status string            `json:"status"`
+	Type   detect.PoisonType `json:"type"`
+	Score  float64           `json:"score"`
+	Label  int               `json:"label"`
+	// Source is the sample's source metadata, or else the agent of its
+	// recorded origin.
+	Source string     `json:"source,omitempty"`
+	Time   *time.Time `json:"time,omitempty"`
+}
+
+// Share is how often a value occurs among the culprits, against how often
+// it occurs in the whole suspect version.
+type Share struct {
+	Value   string  `json:"value"`
+	Count   int     `json:"count"`
+	Share   float64 `json:"share"`
+	Overall float64 `json:"overall"`
+}
+
+// Window is the time range the culprits were created in, and how many
+// other samples of the suspect version fall in it.
+type Window struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Culprits int       `json:"culprits"`
+	Others   int       `json:"others"`
+}
+
+// Trigger is a feature most culprits set to a similar value far from the
+// clean version's distribution, the signature of a backdoor trigger.
+type Trigger struct {
+	Index  int     `json:"index"`
+	Name   string  `json:"name"`
+	Count  int     `json:"count"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+	// ZScore is the distance of Mean from the clean mean, in clean
+	// standard deviations.
+	ZScore float64 `json:"z_score"`
+}
+
+// Forensics isolates the changed samples that raised the risk of a suspect
+// dataset version over a known-clean one.
+type Forensics struct {
+	CleanRisk   float64 `json:"clean_risk"`
+	SuspectRisk float64 `json:"suspect_risk"`
+	Tolerance   float64 `json:"tolerance"`
+	// ResidualRisk is the risk of the suspect version without the
+	// culprits, and Explained the share of the rise in risk they account
+	// for.
+	ResidualRisk float64 `json:"residual_risk"`
+	Explained    float64 `json:"explained"`
+	// Candidates is the number of changed samples newly flagged in the
+	// suspect version; Culprits are the fewest of them, by score, whose
+	// removal returns its risk to the clean one's.
+	Candidates int       `json:"candidates"`
+	Culprits   []Culprit `json:"culprits"`
+	Rescans    int       `json:"rescans"`
+
+	Types    []Share   `json:"types,omitempty"`
+	Labels   []Share   `json:"labels,omitempty"`
+	Sources  []Share   `json:"sources,omitempty"`
+	Window   *Window   `json:"window,omitempty"`
+	Triggers []Trigger `json:"triggers,omitempty"`
+}
+
+// Isolate finds the changed samples of the comparison c of a clean and a
+// suspect version that introduced the suspect version's elevated risk, and
+// summarizes what they have in common. The changed samples newly flagged
+// in the suspect version are removed, highest score first, until a rescan
+// of the rest is within the tolerance of the clean version's risk; a
+// binary search keeps the rescans logarithmic in their number.
+func Isolate(c *Comparison, newSamples []detect.Sample, oldResult, newResult *detect.DetectionResult, opts ForensicsOptions) (*Forensics, error) {
+	if opts.Tolerance == 0 {
+		opts.Tolerance = DefaultTolerance
+	}
+	f := &Forensics{CleanRisk: oldResult.RiskScore, SuspectRisk: newResult.RiskScore, Tolerance: opts.Tolerance, ResidualRisk: newResult.RiskScore}
+	target := f.CleanRisk + f.Tolerance
+	if f.SuspectRisk <= target {
+		return f, nil
+	}
+
+	oldFindings := findingsByID(oldResult)
+	var candidates []SampleAttribution
+	for _, s := range c.Samples {
+		if _, ok := oldFindings[s.ID]; s.Flagged && s.Status != StatusRemoved && !(s.Status == StatusModified && ok) {
+			candidates = append(candidates, s)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Score > candidates[j].Score })
+	f.Candidates = len(candidates)
+	if len(candidates) == 0 {
+		return f, nil
+	}
+
+	rank := make(map[string]int, len(candidates))
+	for i, s := range candidates {
+		rank[s.ID] = i
+	}
+	risks := map[int]float64{0: f.SuspectRisk}
+	risk := func(k int) (float64, error) {
+		if r, ok := risks[k]; ok {
+			return r, nil
+		}
+		kept := make([]detect.Sample, 0, len(newSamples))
+		for _, s := range newSamples {
+			if i, ok := rank[s.ID]; !ok || i >= k {
+				kept = append(kept, s)
+			}
+		}
+		result, err := opts.Rescan(kept)
+		if err != nil {
+			return 0, err
+		}
+		f.Rescans++
+		risks[k] = result.RiskScore
+		return result.RiskScore, nil
+	}
+
+	k := len(candidates)
+	r, err := risk(k)
+	if err != nil {
+		return nil, err
+	}
+	if r <= target {
+		lo, hi := 0, k
+		for hi-lo > 1 {
+			mid := (lo + hi) / 2
+			r, err := risk(mid)
+			if err != nil {
+				return nil, err
+			}
+			if r <= target {
+				hi = mid
+			} else {
+				lo = mid
+			}
+		}
+		k = hi
+	}
+	f.ResidualRisk = risks[k]
+	f.Explained = math.Min(1, math.Max(0, (f.SuspectRisk-f.ResidualRisk)/(f.SuspectRisk-f.CleanRisk)))
+
+	f.characterize(c, candidates[:k], newSamples, newResult, opts)
+	return f, nil
+}
+
+// characterize records the culprits and what they have in common.
+func (f *Forensics) characterize(c *Comparison, culprits []SampleAttribution, newSamples []detect.Sample, newResult *detect.DetectionResult, opts ForensicsOptions) {
+	index := make(map[string]int, len(newSamples))
+	for i := len(newSamples) - 1; i >= 0; i-- {
+		index[newSamples[i].ID] = i
+	}
+	findings := findingsByID(newResult)
+	source := func(i int) string {
+		if v, ok := newSamples[i].Meta(detect.MetaSource); ok {
+			return fmt.Sprint(v)
+		}
+		if origin := findings[newSamples[i].ID].Origin; origin != nil {
+			return origin.Agent
+		}
+		return ""
+	}
+
+	var types, labels, sources, allLabels, allSources []string
+	var members []int
+	for _, s := range culprits {
+		i := index[s.ID]
+		members = append(members, i)
+		culprit := Culprit{ID: s.ID, Status: s.Status, Type: s.Type, Score: s.Score, Label: newSamples[i].Label, Source: source(i)}
+		if i < len(opts.Times) && !opts.Times[i].IsZero() {
+			at := opts.Times[i]
+			culprit.Time = &at
+		}
+		f.Culprits = append(f.Culprits, culprit)
+		types = append(types, string(s.Type))
+		labels = append(labels, strconv.Itoa(culprit.Label))
+		if culprit.Source != "" {
+			sources = append(sources, culprit.Source)
+		}
+	}
+	for i, s := range newSamples {
+		allLabels = append(allLabels, strconv.Itoa(s.Label))
+		allSources = append(allSources, source(i))
+	}
+	f.Types = shares(types, nil)
+	f.Labels = shares(labels, allLabels)
+	f.Sources = shares(sources, allSources)
+	f.Window = window(f.Culprits, members, opts.Times)
+	f.Triggers = triggers(c, members, newSamples)
+}
+
+// shares counts the values of culprits, most frequent first, with their
+// share of all, when set.
+func shares(culprits, all []string) []Share {
+	counts := make(map[string]int)
+	for _, v := range culprits {
+		counts[v]++
+	}
+	overall := make(map[string]int)
+	for _, v := range all {
+		overall[v]++
+	}
+	var result []Share
+	for v, n := range counts {
+		share := Share{Value: v, Count: n, Share: float64(n) / float64(len(culprits))}
+		if len(all) > 0 {
+			share.Overall = float64(overall[v]) / float64(len(all))
+		}
+		result = append(result, share)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Value < result[j].Value
+	})
+	return result
+}
+
+// window returns the time range of the culprits with known times, or nil
+// if none has one.
+func window(culprits []Culprit, members []int, times []time.Time) *Window {
+	var w *Window
+	for _, c := range culprits {
+		if c.Time == nil {
+			continue
+		}
+		if w == nil {
+			w = &Window{Start: *c.Time, End: *c.Time}
+		}
+		if c.Time.Before(w.Start) {
+			w.Start = *c.Time
+		}
+		if c.Time.After(w.End) {
+			w.End = *c.Time
+		}
+		w.Culprits++
+	}
+	if w == nil {
+		return nil
+	}
+	member := make(map[int]bool, len(members))
+	for _, i := range members {
+		member[i] = true
+	}
+	for i, at := range times {
+		if !member[i] && !at.IsZero() && !at.Before(w.Start) && !at.After(w.End) {
+			w.Others++
+		}
+	}
+	return w
+}
+
+// triggers returns the features that at least half of the culprits set to
+// values within a quarter of a clean standard deviation of each other and
+// at least two clean standard deviations from the clean mean, furthest
+// first.
+func triggers(c *Comparison, members []int, newSamples []detect.Sample) []Trigger {
+	var result []Trigger
+	for _, feature := range c.Features {
+		if feature.OldStdDev == 0 {
+			continue
+		}
+		var values []float64
+		for _, i := range members {
+			if x := newSamples[i].Features; feature.Index < len(x) && finite(x[feature.Index]) {
+				values = append(values, x[feature.Index])
+			}
+		}
+		if 2*len(values) < len(members) {
+			continue
+		}
+		mean, stdDev := meanStdDev(values)
+		z := (mean - feature.OldMean) / feature.OldStdDev
+		if math.Abs(z) >= 2 && stdDev <= feature.OldStdDev/4 {
+			result = append(result, Trigger{Index: feature.Index, Name: feature.Name, Count: len(values), Mean: mean, StdDev: stdDev, ZScore: z})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return math.Abs(result[i].ZScore) > math.Abs(result[j].ZScore) })
+	return result
+}
+
+// GenerateForensicsReport generates a differential forensics report,
+// listing at most top culprits; zero lists all of them.
+func GenerateForensicsReport(f *Forensics, top int) string {
+	var report string
+
+	report += "=== Differential Forensics Report ===\n\n"
+	report += fmt.Sprintf("Risk Score: %.0f%% clean -> %.0f%% suspect\n", f.CleanRisk*100, f.SuspectRisk*100)
+	if len(f.Culprits) == 0 {
+		if f.SuspectRisk > f.CleanRisk+f.Tolerance {
+			report += "No changed sample is newly flagged; the rise in risk comes from unchanged samples.\n"
+		} else {
+			report += "The suspect version's risk is not elevated.\n"
+		}
+		return report
+	}
+	report += fmt.Sprintf("Culprits: %d of %d newly flagged changed samples (%d rescans)\n", len(f.Culprits), f.Candidates, f.Rescans)
+	report += fmt.Sprintf("Without them: %.0f%% risk, %.0f%% of the rise explained\n\n", f.ResidualRisk*100, f.Explained*100)
+
+	section := func(title string, shares []Share, overall bool) {
+		if len(shares) == 0 {
+			return
+		}
+		report += title + ":\n"
+		for _, s := range shares {
+			report += fmt.Sprintf("  %-24s %6d  %5.1f%%", s.Value, s.Count, s.Share*100)
+			if overall {
+				report += fmt.Sprintf("  (%.1f%% of the dataset)", s.Overall*100)
+			}
+			report += "\n"
+		}
+		report += "\n"
+	}
+	section("Poison Types", f.Types, false)
+	section("Labels", f.Labels, true)
+	section("Sources", f.Sources, true)
+
+	if w := f.Window; w != nil {
+		report += "Time Window:\n"
+		report += fmt.Sprintf("  %s to %s: %d culprits, %d other samples\n\n",
+			w.Start.Format(time.RFC3339), w.End.Format(time.RFC3339), w.Culprits, w.Others)
+	}
+
+	if len(f.Triggers) > 0 {
+		report += "Trigger Pattern:\n"
+		for _, t := range f.Triggers {
+			report += fmt.Sprintf("  %-20s = %.3f ± %.3f in %d culprits (z=%+.1f)\n", t.Name, t.Mean, t.StdDev, t.Count, t.ZScore)
+		}
+		report += "\n"
+	}
+
+	report += "Culprits:\n"
+	for i, c := range f.Culprits {
+		if top > 0 && i >= top {
+			report += fmt.Sprintf("  ... %d more\n", len(f.Culprits)-top)
+			break
+		}
+		report += fmt.Sprintf("  [%s] %s  %s  %.0f%%  label %d", c.Status, c.ID, c.Type, c.Score*100, c.Label)
+		if c.Source != "" {
+			report += "  " + c.Source
+		}
+		if c.Time != nil {
+			report += "  " + c.Time.Format(time.RFC3339)
+		}
+		report += "\n"
+	}
+	report += "\n"
+	return report
+}
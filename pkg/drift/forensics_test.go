@@ -0,0 +1,163 @@
+package drift
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// suspect returns a clean version of 40 samples and a suspect version
+// adding six backdoored samples of label 1 from "mallory", with feature 0
+// set to a trigger near 9, and two benign samples flagged with lower
+// scores. It also returns the time of each suspect sample.
+func suspect() (clean, poisoned []detect.Sample, findings map[string]float64, times []time.Time) {
+	clean = baseline(40)
+	poisoned = append([]detect.Sample(nil), clean...)
+	findings = make(map[string]float64)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	times = make([]time.Time, 0, 48)
+	for i := range clean {
+		times = append(times, start.Add(-time.Duration(40-i)*time.Hour))
+	}
+	for i := 0; i < 6; i++ {
+		id := fmt.Sprintf("bd%d", i)
+		poisoned = append(poisoned, detect.Sample{
+			ID:       id,
+			Features: []float64{9 + float64(i)*0.001, 2, 3},
+			Label:    1,
+			Metadata: map[string]interface{}{detect.MetaSource: "mallory"},
+		})
+		findings[id] = 0.9 + float64(i)*0.01
+		times = append(times, start.Add(time.Duration(i)*time.Minute))
+	}
+	for i := 0; i < 2; i++ {
+		id := fmt.Sprintf("odd%d", i)
+		poisoned = append(poisoned, detect.Sample{ID: id, Features: []float64{1, 2, 3.4}, Label: 0})
+		findings[id] = 0.5
+		times = append(times, time.Time{})
+	}
+	return clean, poisoned, findings, times
+}
+
+// backdoorRisk is a rescan scoring the risk of samples as the share of
+// them with the trigger.
+func backdoorRisk(samples []detect.Sample) (*detect.DetectionResult, error) {
+	n := 0
+	for _, s := range samples {
+		if s.Features[0] > 5 {
+			n++
+		}
+	}
+	return &detect.DetectionResult{SampleCount: len(samples), RiskScore: float64(n) / float64(len(samples))}, nil
+}
+
+func TestIsolate(t *testing.T) {
+	clean, poisoned, findings, times := suspect()
+	oldResult := resultOf(clean, 0, nil)
+	newResult, _ := backdoorRisk(poisoned)
+	newResult = resultOf(poisoned, newResult.RiskScore, findings)
+	c := Compare(nil, clean, poisoned, oldResult, newResult)
+
+	f, err := Isolate(c, poisoned, oldResult, newResult, ForensicsOptions{Rescan: backdoorRisk, Times: times})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Candidates != 8 || len(f.Culprits) != 6 {
+		t.Fatalf("%d culprits of %d candidates, want the 6 backdoored samples of 8", len(f.Culprits), f.Candidates)
+	}
+	for k, culprit := range f.Culprits {
+		// Culprits are ranked by score, highest first.
+		if want := fmt.Sprintf("bd%d", 5-k); culprit.ID != want || culprit.Status != StatusAdded || culprit.Label != 1 || culprit.Source != "mallory" || culprit.Time == nil {
+			t.Errorf("culprit %d = %+v, want %s", k, culprit, want)
+		}
+	}
+	if f.ResidualRisk != 0 || f.Explained != 1 || f.Tolerance != DefaultTolerance {
+		t.Errorf("residual risk %v, explained %v, tolerance %v", f.ResidualRisk, f.Explained, f.Tolerance)
+	}
+	// A binary search over 8 candidates needs few rescans.
+	if f.Rescans < 1 || f.Rescans > 4 {
+		t.Errorf("%d rescans", f.Rescans)
+	}
+
+	if len(f.Types) != 1 || f.Types[0] != (Share{Value: "backdoor", Count: 6, Share: 1}) {
+		t.Errorf("types %+v", f.Types)
+	}
+	if len(f.Labels) != 1 || f.Labels[0].Value != "1" || f.Labels[0].Share != 1 || math.Abs(f.Labels[0].Overall-26.0/48) > 1e-12 {
+		t.Errorf("labels %+v", f.Labels)
+	}
+	if len(f.Sources) != 1 || f.Sources[0].Value != "mallory" || math.Abs(f.Sources[0].Overall-6.0/48) > 1e-12 {
+		t.Errorf("sources %+v", f.Sources)
+	}
+	if w := f.Window; w == nil || !w.Start.Equal(times[40]) || !w.End.Equal(times[45]) || w.Culprits != 6 || w.Others != 0 {
+		t.Errorf("window %+v", f.Window)
+	}
+	if len(f.Triggers) != 1 || f.Triggers[0].Index != 0 || f.Triggers[0].Count != 6 || f.Triggers[0].ZScore < 2 || math.Abs(f.Triggers[0].Mean-9.0025) > 1e-9 {
+		t.Errorf("triggers %+v", f.Triggers)
+	}
+
+	report := GenerateForensicsReport(f, 2)
+	for _, want := range []string{
+		"Risk Score: 0% clean -> 12% suspect",
+		fmt.Sprintf("Culprits: 6 of 8 newly flagged changed samples (%d rescans)", f.Rescans),
+		"Without them: 0% risk, 100% of the rise explained",
+		"Sources:\n  mallory                       6  100.0%  (12.5% of the dataset)",
+		"Time Window:\n  2026-03-01T12:00:00Z to 2026-03-01T12:05:00Z: 6 culprits, 0 other samples",
+		"Trigger Pattern:\n  feature_0",
+		"  [added] bd5  backdoor  95%  label 1  mallory  2026-03-01T12:05:00Z\n",
+		"  ... 4 more\n",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report lacks %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestIsolateNotElevated(t *testing.T) {
+	clean, poisoned, findings, _ := suspect()
+	oldResult := resultOf(clean, 0.1, nil)
+	newResult := resultOf(poisoned, 0.105, findings)
+	c := Compare(nil, clean, poisoned, oldResult, newResult)
+	rescan := func([]detect.Sample) (*detect.DetectionResult, error) {
+		t.Fatal("rescanned a version whose risk is within the tolerance")
+		return nil, nil
+	}
+	f, err := Isolate(c, poisoned, oldResult, newResult, ForensicsOptions{Rescan: rescan})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Culprits) != 0 || f.Rescans != 0 {
+		t.Errorf("forensics %+v", f)
+	}
+	if report := GenerateForensicsReport(f, 0); !strings.Contains(report, "The suspect version's risk is not elevated.") {
+		t.Errorf("report:\n%s", report)
+	}
+
+	// Elevated risk without newly flagged changed samples comes from the
+	// unchanged ones.
+	newResult = resultOf(clean, 0.5, map[string]float64{"s1": 0.9})
+	oldResult = resultOf(clean, 0, map[string]float64{"s1": 0.9})
+	f, err = Isolate(Compare(nil, clean, clean, oldResult, newResult), clean, oldResult, newResult, ForensicsOptions{Rescan: rescan})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Candidates != 0 || !strings.Contains(GenerateForensicsReport(f, 0), "comes from unchanged samples") {
+		t.Errorf("forensics %+v", f)
+	}
+}
+
+func TestIsolateRescanError(t *testing.T) {
+	clean, poisoned, findings, _ := suspect()
+	oldResult := resultOf(clean, 0, nil)
+	newResult := resultOf(poisoned, 0.2, findings)
+	c := Compare(nil, clean, poisoned, oldResult, newResult)
+	rescan := func([]detect.Sample) (*detect.DetectionResult, error) {
+		return nil, fmt.Errorf("scanner down")
+	}
+	if _, err := Isolate(c, poisoned, oldResult, newResult, ForensicsOptions{Rescan: rescan}); err == nil || err.Error() != "scanner down" {
+		t.Errorf("isolate error %v, want the rescan's", err)
+	}
+}
@@ -0,0 +1,295 @@
+// Package dvc reads DVC metadata (.dvc files and dvc.lock) to resolve the
+// recorded content hash of a dataset, so scan results can name the exact
+// dataset revision they cover.
+package dvc
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// out is an output recorded in a .dvc file or dvc.lock.
+type out struct {
+	Path string `yaml:"path"`
+	MD5  string `yaml:"md5"`
+	// Hash is "md5" for DVC 3 hashes; older hashes of text files were
+	// computed with CRLF line endings normalized to LF.
+	Hash string `yaml:"hash"`
+}
+
+// dvcFile is a .dvc file.
+type dvcFile struct {
+	Outs []out `yaml:"outs"`
+}
+
+// lockFile is a dvc.lock file.
+type lockFile struct {
+	Stages map[string]struct {
+		Outs []out `yaml:"outs"`
+	} `yaml:"stages"`
+}
+
+// Resolve returns the version of the dataset at path recorded in the DVC
+// repository containing it, or nil if the dataset is not tracked by DVC.
+// The dataset is hashed to report whether it still matches the recorded
+// version.
+func Resolve(path string) (*detect.DatasetVersion, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	root := findRoot(filepath.Dir(abs))
+	if root == "" {
+		return nil, nil
+	}
+
+	entry, source, base, err := findOut(root, abs)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	rel, _ := filepath.Rel(root, abs)
+	version := &detect.DatasetVersion{
+		Path:   filepath.ToSlash(rel),
+		Hash:   "md5:" + entry.MD5,
+		Source: source,
+	}
+
+	md5sum := entry.MD5
+	if strings.HasSuffix(entry.MD5, ".dir") {
+		// A tracked directory: the file's hash is in the directory
+		// manifest, which is only available once the cache is populated.
+		inDir, _ := filepath.Rel(base, abs)
+		md5sum, err = manifestHash(root, *entry, filepath.ToSlash(inDir))
+		if err != nil {
+			return nil, err
+		}
+		if md5sum == "" {
+			version.Path = filepath.ToSlash(mustRel(root, base))
+			return version, nil
+		}
+		version.Hash = "md5:" + md5sum
+	}
+
+	sum, err := fileHash(abs, entry.Hash != "md5")
+	if err != nil {
+		return nil, err
+	}
+	version.Modified = sum != md5sum
+	return version, nil
+}
+
+// findRoot returns the nearest directory at or above dir holding a .dvc
+// directory, or "" if there is none.
+func findRoot(dir string) string {
+	for {
+		if info, err := os.Stat(filepath.Join(dir, ".dvc")); err == nil && info.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// findOut finds the output tracking abs, first in .dvc files for the file
+// or one of its directories, then in the dvc.lock files between the file
+// and root. It returns the output, a description of where it was found and
+// the path of the tracked file or directory.
+func findOut(root, abs string) (*out, string, string, error) {
+	for target := abs; ; target = filepath.Dir(target) {
+		name := target + ".dvc"
+		data, err := os.ReadFile(name)
+		if err == nil {
+			var file dvcFile
+			if err := yaml.Unmarshal(data, &file); err != nil {
+				return nil, "", "", fmt.Errorf("%s: %w", name, err)
+			}
+			for i, o := range file.Outs {
+				if filepath.Join(filepath.Dir(name), filepath.FromSlash(o.Path)) == target {
+					return &file.Outs[i], filepath.ToSlash(mustRel(root, name)), target, nil
+				}
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, "", "", err
+		}
+		if target == root {
+			break
+		}
+	}
+
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		name := filepath.Join(dir, "dvc.lock")
+		data, err := os.ReadFile(name)
+		if err == nil {
+			var lock lockFile
+			if err := yaml.Unmarshal(data, &lock); err != nil {
+				return nil, "", "", fmt.Errorf("%s: %w", name, err)
+			}
+			stages := make([]string, 0, len(lock.Stages))
+			for stage := range lock.Stages {
+				stages = append(stages, stage)
+			}
+			sort.Strings(stages)
+			for _, stage := range stages {
+				outs := lock.Stages[stage].Outs
+				for i, o := range outs {
+					target := filepath.Join(dir, filepath.FromSlash(o.Path))
+					if target == abs || strings.HasPrefix(abs, target+string(filepath.Separator)) {
+						source := fmt.Sprintf("%s (stage %s)", filepath.ToSlash(mustRel(root, name)), stage)
+						return &outs[i], source, target, nil
+					}
+				}
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, "", "", err
+		}
+		if dir == root {
+			break
+		}
+	}
+	return nil, "", "", nil
+}
+
+// manifestHash returns the hash of relpath in the cached manifest of a
+// directory output, or "" when the manifest is not in the cache.
+func manifestHash(root string, dir out, relpath string) (string, error) {
+	digest := strings.TrimSuffix(dir.MD5, ".dir")
+	if len(digest) < 3 {
+		return "", fmt.Errorf("invalid directory hash %q", dir.MD5)
+	}
+	// DVC 3 caches under files/md5; older versions at the top level.
+	cache := filepath.Join(root, ".dvc", "cache")
+	var data []byte
+	var err error
+	for _, name := range []string{
+		filepath.Join(cache, "files", "md5", digest[:2], digest[2:]+".dir"),
+		filepath.Join(cache, digest[:2], digest[2:]+".dir"),
+	} {
+		if data, err = os.ReadFile(name); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return "", nil
+	}
+
+	var entries []struct {
+		MD5     string `json:"md5"`
+		RelPath string `json:"relpath"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return "", fmt.Errorf("directory manifest %s: %w", dir.MD5, err)
+	}
+	for _, entry := range entries {
+		if entry.RelPath == relpath {
+			return entry.MD5, nil
+		}
+	}
+	return "", nil
+}
+
+// fileHash returns the MD5 of a file as DVC computes it. With legacy set,
+// text files are hashed with CRLF line endings converted to LF.
+func fileHash(name string, legacy bool) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	r := bufio.NewReaderSize(f, 64<<10)
+	if legacy {
+		head, err := r.Peek(512)
+		if err != nil && err != io.EOF && !errors.Is(err, bufio.ErrBufferFull) {
+			return "", err
+		}
+		if isText(head) {
+			err := dos2unix(h, r)
+			return hex.EncodeToString(h.Sum(nil)), err
+		}
+	}
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// dos2unix copies r to w, replacing CRLF line endings with LF.
+func dos2unix(w io.Writer, r io.Reader) error {
+	buf := make([]byte, 64<<10)
+	pendingCR := false
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			chunk := buf[:n]
+			// A CR ending the previous chunk may start a CRLF.
+			if pendingCR && chunk[0] != '\n' {
+				w.Write([]byte{'\r'})
+			}
+			pendingCR = chunk[n-1] == '\r'
+			if pendingCR {
+				chunk = chunk[:n-1]
+			}
+			w.Write(bytes.ReplaceAll(chunk, []byte("\r\n"), []byte("\n")))
+		}
+		if err == io.EOF {
+			if pendingCR {
+				w.Write([]byte{'\r'})
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// isText reports whether data looks like text the way DVC decides: the
+// first 512 bytes hold no NUL and at most 30% other control or non-ASCII
+// bytes.
+func isText(data []byte) bool {
+	block := data
+	if len(block) > 512 {
+		block = block[:512]
+	}
+	if len(block) == 0 {
+		return true
+	}
+	if bytes.IndexByte(block, 0) >= 0 {
+		return false
+	}
+	nontext := 0
+	for _, c := range block {
+		if (c < 32 || c > 126) && !strings.ContainsRune("\n\r\t\f\b", rune(c)) {
+			nontext++
+		}
+	}
+	return float64(nontext)/float64(len(block)) <= 0.30
+}
+
+// mustRel returns target relative to base, or target if it is not under
+// base.
+func mustRel(base, target string) string {
+	rel, err := filepath.Rel(base, target)
+	if err != nil {
+		return target
+	}
+	return rel
+}
@@ -0,0 +1,88 @@
+package dvc
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func md5hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".dvc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	crlf := "id,f0,label\r\ns0,1.5,0\r\n"
+	lf := strings.ReplaceAll(crlf, "\r\n", "\n")
+
+	// A DVC 2 .dvc file, hashed with normalized line endings.
+	writeFile(t, filepath.Join(root, "data", "train.csv"), crlf)
+	writeFile(t, filepath.Join(root, "data", "train.csv.dvc"), "outs:\n- md5: "+md5hex(lf)+"\n  path: train.csv\n")
+	// A DVC 3 stage output, hashed as is, changed since it was tracked.
+	writeFile(t, filepath.Join(root, "features", "test.csv"), crlf)
+	writeFile(t, filepath.Join(root, "dvc.lock"), "schema: '2.0'\nstages:\n  featurize:\n    outs:\n"+
+		"    - path: features/test.csv\n      hash: md5\n      md5: "+md5hex("old")+"\n")
+	// A tracked directory with its manifest in the cache.
+	writeFile(t, filepath.Join(root, "raw", "part-0.csv"), lf)
+	dirHash := "0123456789abcdef0123456789abcdef"
+	writeFile(t, filepath.Join(root, "raw.dvc"), "outs:\n- md5: "+dirHash+".dir\n  path: raw\n  hash: md5\n")
+	writeFile(t, filepath.Join(root, ".dvc", "cache", "files", "md5", dirHash[:2], dirHash[2:]+".dir"),
+		`[{"md5": "`+md5hex(lf)+`", "relpath": "part-0.csv"}]`)
+	writeFile(t, filepath.Join(root, "untracked.csv"), lf)
+
+	for _, tt := range []struct {
+		path string
+		want *detect.DatasetVersion
+	}{
+		{"data/train.csv", &detect.DatasetVersion{Path: "data/train.csv", Hash: "md5:" + md5hex(lf), Source: "data/train.csv.dvc"}},
+		{"features/test.csv", &detect.DatasetVersion{Path: "features/test.csv", Hash: "md5:" + md5hex("old"),
+			Source: "dvc.lock (stage featurize)", Modified: true}},
+		{"raw/part-0.csv", &detect.DatasetVersion{Path: "raw/part-0.csv", Hash: "md5:" + md5hex(lf), Source: "raw.dvc"}},
+		{"untracked.csv", nil},
+	} {
+		got, err := Resolve(filepath.Join(root, filepath.FromSlash(tt.path)))
+		if err != nil {
+			t.Errorf("Resolve(%s): %v", tt.path, err)
+			continue
+		}
+		if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("Resolve(%s) = %+v, want %+v", tt.path, got, tt.want)
+		}
+	}
+
+	if got, err := Resolve(filepath.Join(t.TempDir(), "x.csv")); got != nil || err != nil {
+		t.Errorf("Resolve outside a DVC repository = %+v, %v; want nil", got, err)
+	}
+}
+
+func TestDos2unixAcrossReads(t *testing.T) {
+	var out bytes.Buffer
+	in := "a\r\nb\r\rc\r"
+	if err := dos2unix(&out, iotest.OneByteReader(strings.NewReader(in))); err != nil {
+		t.Fatal(err)
+	}
+	if want := "a\nb\r\rc\r"; out.String() != want {
+		t.Errorf("dos2unix(%q) = %q, want %q", in, out.String(), want)
+	}
+}
@@ -0,0 +1,314 @@
+// Package evaluate scores detectors against attack-injected datasets with
+// known ground truth.
+package evaluate
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/attack"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Ensemble names the detector's combined verdict among the checks.
+const Ensemble = "ensemble"
+
+// Case is a dataset poisoned by a known attack.
+type Case struct {
+	// Dataset names the dataset the attack was injected into, and Attack
+	// the attack, or "labeled" for datasets with published ground truth.
+	Dataset  string
+	Attack   string
+	Samples  []detect.Sample
+	Poisoned []bool
+}
+
+// Metrics holds the scores of one detector on one case.
+type Metrics struct {
+	Dataset        string  `json:"dataset"`
+	Attack         string  `json:"attack"`
+	Detector       string  `json:"detector"`
+	Samples        int     `json:"samples"`
+	Poisoned       int     `json:"poisoned"`
+	TruePositives  int     `json:"true_positives"`
+	FalsePositives int     `json:"false_positives"`
+	FalseNegatives int     `json:"false_negatives"`
+	Precision      float64 `json:"precision"`
+	Recall         float64 `json:"recall"`
+	F1             float64 `json:"f1"`
+	// FalsePositiveRate is the fraction of clean samples flagged.
+	FalsePositiveRate float64 `json:"false_positive_rate"`
+	// AUROC is the area under the ROC curve of the detector's scores, the
+	// chance that a poisoned sample outscores a clean one. It is zero when
+	// the case has no poisoned or no clean samples.
+	AUROC float64 `json:"auroc"`
+	// Latency is the mean time the detector took per sample.
+	Latency time.Duration `json:"latency_ns"`
+}
+
+// Generator is a named attack configuration.
+type Generator struct {
+	Name   string
+	Config attack.Config
+}
+
+// Attacks returns a generator per attack name, configured as base
+// otherwise.
+func Attacks(base attack.Config, names []string) []Generator {
+	generators := make([]Generator, len(names))
+	for i, name := range names {
+		cfg := base
+		cfg.Attack = name
+		generators[i] = Generator{Name: name, Config: cfg}
+	}
+	return generators
+}
+
+// Generators returns every dataset attack generator applicable to samples
+// of width features, configured as base otherwise: each backdoor trigger,
+// each label flip strategy and the clean-label attack. Patch triggers are
+// only included when the features form square images.
+func Generators(base attack.Config, width int) []Generator {
+	variant := func(name, kind string, set func(*attack.Config)) Generator {
+		cfg := base
+		cfg.Attack = kind
+		set(&cfg)
+		return Generator{Name: name, Config: cfg}
+	}
+	generators := []Generator{
+		variant("backdoor/feature", attack.AttackBackdoor, func(c *attack.Config) { c.Trigger = attack.TriggerFeature }),
+		variant("backdoor/patch", attack.AttackBackdoor, func(c *attack.Config) { c.Trigger = attack.TriggerPatch }),
+		variant("backdoor/blend", attack.AttackBackdoor, func(c *attack.Config) { c.Trigger = attack.TriggerBlend }),
+		variant("label_flip/random", attack.AttackLabelFlip, func(c *attack.Config) { c.Flip = attack.FlipRandom }),
+		variant("label_flip/targeted", attack.AttackLabelFlip, func(c *attack.Config) { c.Flip = attack.FlipTargeted }),
+		variant("label_flip/nearest", attack.AttackLabelFlip, func(c *attack.Config) { c.Flip = attack.FlipNearest }),
+		variant(attack.AttackCleanLabel, attack.AttackCleanLabel, func(*attack.Config) {}),
+	}
+	if _, err := attack.TriggerFeatures(generators[1].Config, width); err != nil {
+		generators = append(generators[:1], generators[2:]...)
+	}
+	return generators
+}
+
+// Inject returns a case per generator, each poisoning a copy of samples.
+func Inject(name string, samples []detect.Sample, generators []Generator) ([]Case, error) {
+	cases := make([]Case, 0, len(generators))
+	for _, g := range generators {
+		poisonedSamples := append([]detect.Sample(nil), samples...)
+		injections, err := attack.Inject(poisonedSamples, g.Config)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", name, g.Name, err)
+		}
+		poisoned := make([]bool, len(samples))
+		for _, injection := range injections {
+			poisoned[injection.Index] = true
+		}
+		cases = append(cases, Case{Dataset: name, Attack: g.Name, Samples: poisonedSamples, Poisoned: poisoned})
+	}
+	return cases, nil
+}
+
+// ByType splits a labeled case by poison type, where types gives the type
+// of each poisoned sample. Each case keeps the clean samples and the
+// poisons of one type, and is named after it; poisons of no type only
+// stay in the whole case. Cases with fewer than two types are returned
+// whole.
+func ByType(c Case, types []string) []Case {
+	var names []string
+	seen := make(map[string]bool)
+	for i, t := range types {
+		if c.Poisoned[i] && t != "" && !seen[t] {
+			seen[t] = true
+			names = append(names, t)
+		}
+	}
+	if len(names) < 2 {
+		return []Case{c}
+	}
+	sort.Strings(names)
+
+	cases := []Case{c}
+	for _, name := range names {
+		split := Case{Dataset: c.Dataset, Attack: c.Attack + "/" + name}
+		for i, sample := range c.Samples {
+			if c.Poisoned[i] && types[i] != name {
+				continue
+			}
+			split.Samples = append(split.Samples, sample)
+			split.Poisoned = append(split.Poisoned, c.Poisoned[i])
+		}
+		cases = append(cases, split)
+	}
+	return cases
+}
+
+// Run evaluates each built-in and external check of detector and its
+// ensemble verdict on every case. Metrics are ordered by case, then
+// detector; external checks are named by their Name.
+func Run(detector *detect.Detector, cases []Case) ([]Metrics, error) {
+	thresholds := detector.Thresholds()
+	var metrics []Metrics
+
+	for _, c := range cases {
+		if len(c.Poisoned) != len(c.Samples) {
+			return nil, fmt.Errorf("%s: ground truth covers %d of %d samples", c.Dataset, len(c.Poisoned), len(c.Samples))
+		}
+		if err := detector.Validate(c.Samples); err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Dataset, err)
+		}
+
+		ensemble := make([]float64, len(c.Samples))
+		for _, t := range detect.Checks() {
+			scores := make([]float64, len(c.Samples))
+			flagged := make([]bool, len(c.Samples))
+			start := time.Now()
+			for i, sample := range c.Samples {
+				score, err := detector.ScoreCheck(t, sample)
+				if err != nil {
+					return nil, err
+				}
+				scores[i] = score
+				flagged[i] = score > thresholds[t]
+			}
+			elapsed := time.Since(start)
+			for i, score := range scores {
+				if thresholds[t] > 0 {
+					score /= thresholds[t]
+				}
+				ensemble[i] = math.Max(ensemble[i], score)
+			}
+			metrics = append(metrics, Measure(c, string(t), scores, flagged, elapsed))
+		}
+
+		for _, check := range detector.ExternalChecks() {
+			threshold := check.Threshold()
+			start := time.Now()
+			external := check.Score(c.Samples)
+			elapsed := time.Since(start)
+			scores := make([]float64, len(c.Samples))
+			flagged := make([]bool, len(c.Samples))
+			for i := range scores {
+				if i < len(external) {
+					scores[i] = external[i].Score
+				}
+				flagged[i] = scores[i] > threshold
+			}
+			metrics = append(metrics, Measure(c, check.Info().Name, scores, flagged, elapsed))
+		}
+
+		// The ensemble flags samples by its verdict. For the AUROC it ranks
+		// them by their highest check score relative to the check's
+		// built-in threshold, which exceeds 1 exactly when such a check
+		// flags them, and ranks samples flagged by external checks at
+		// least at 1.
+		start := time.Now()
+		result := detector.Detect(c.Samples)
+		elapsed := time.Since(start)
+		flagged := make([]bool, len(c.Samples))
+		for i, finding := range result.Samples {
+			flagged[i] = finding.IsPoisoned
+			if finding.IsPoisoned {
+				ensemble[i] = math.Max(ensemble[i], 1)
+			}
+		}
+		metrics = append(metrics, Measure(c, Ensemble, ensemble, flagged, elapsed))
+	}
+
+	return metrics, nil
+}
+
+// Measure scores a detector that gave the samples of c scores and flagged
+// some of them in elapsed.
+func Measure(c Case, detector string, scores []float64, flagged []bool, elapsed time.Duration) Metrics {
+	m := Metrics{
+		Dataset:  c.Dataset,
+		Attack:   c.Attack,
+		Detector: detector,
+		Samples:  len(c.Samples),
+	}
+	for i, f := range flagged {
+		switch {
+		case f && c.Poisoned[i]:
+			m.TruePositives++
+		case f:
+			m.FalsePositives++
+		case c.Poisoned[i]:
+			m.FalseNegatives++
+		}
+	}
+	m.Poisoned = m.TruePositives + m.FalseNegatives
+
+	if n := m.TruePositives + m.FalsePositives; n > 0 {
+		m.Precision = float64(m.TruePositives) / float64(n)
+	}
+	if m.Poisoned > 0 {
+		m.Recall = float64(m.TruePositives) / float64(m.Poisoned)
+	}
+	if clean := m.Samples - m.Poisoned; clean > 0 {
+		m.FalsePositiveRate = float64(m.FalsePositives) / float64(clean)
+	}
+	if m.Precision+m.Recall > 0 {
+		m.F1 = 2 * m.Precision * m.Recall / (m.Precision + m.Recall)
+	}
+	m.AUROC = AUROC(scores, c.Poisoned)
+	if m.Samples > 0 {
+		m.Latency = elapsed / time.Duration(m.Samples)
+	}
+	return m
+}
+
+// AUROC returns the area under the ROC curve of scores ranking the
+// positive samples, by the Mann-Whitney statistic with ties counted half.
+// It returns zero unless there are both positive and negative samples.
+func AUROC(scores []float64, positive []bool) float64 {
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] < scores[order[b]] })
+
+	// Sum the ranks of the positives, giving tied scores their mean rank.
+	rankSum, positives := 0.0, 0
+	for start := 0; start < len(order); {
+		end := start + 1
+		for end < len(order) && scores[order[end]] == scores[order[start]] {
+			end++
+		}
+		rank := float64(start+end+1) / 2
+		for _, i := range order[start:end] {
+			if positive[i] {
+				rankSum += rank
+				positives++
+			}
+		}
+		start = end
+	}
+
+	negatives := len(scores) - positives
+	if positives == 0 || negatives == 0 {
+		return 0
+	}
+	return (rankSum - float64(positives)*float64(positives+1)/2) / (float64(positives) * float64(negatives))
+}
+
+// GenerateReport generates an evaluation report table.
+func GenerateReport(metrics []Metrics) string {
+	var report strings.Builder
+
+	report.WriteString("=== Detector Evaluation ===\n\n")
+	fmt.Fprintf(&report, "%-24s %-18s %-16s %8s %8s %9s %8s %8s %7s %12s\n",
+		"Dataset", "Attack", "Detector", "Samples", "Poisoned", "Precision", "Recall", "F1", "AUROC", "Latency")
+	for _, m := range metrics {
+		auroc := "-"
+		if m.Poisoned > 0 && m.Poisoned < m.Samples {
+			auroc = fmt.Sprintf("%.3f", m.AUROC)
+		}
+		fmt.Fprintf(&report, "%-24s %-18s %-16s %8d %8d %8.1f%% %7.1f%% %8.3f %7s %12s\n",
+			m.Dataset, m.Attack, m.Detector, m.Samples, m.Poisoned, m.Precision*100, m.Recall*100, m.F1, auroc, m.Latency)
+	}
+
+	return report.String()
+}
@@ -0,0 +1,39 @@
+package evaluate
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func TestAUROC(t *testing.T) {
+	for _, tc := range []struct {
+		scores   []float64
+		positive []bool
+		want     float64
+	}{
+		{[]float64{0.1, 0.2, 0.8, 0.9}, []bool{false, false, true, true}, 1},
+		{[]float64{0.1, 0.2, 0.8, 0.9}, []bool{true, true, false, false}, 0},
+		{[]float64{0.5, 0.5, 0.5, 0.5}, []bool{true, false, true, false}, 0.5},
+		{[]float64{0.1, 0.4, 0.35, 0.8}, []bool{false, false, true, true}, 0.75},
+		{[]float64{0.1, 0.2}, []bool{false, false}, 0},
+	} {
+		if got := AUROC(tc.scores, tc.positive); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("AUROC(%v, %v) = %v, want %v", tc.scores, tc.positive, got, tc.want)
+		}
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	c := Case{Dataset: "d", Attack: "backdoor", Poisoned: []bool{true, true, false, false}}
+	c.Samples = make([]detect.Sample, 4)
+	m := Measure(c, "check", []float64{0.9, 0.3, 0.6, 0.1}, []bool{true, false, true, false}, 4*time.Millisecond)
+	if m.TruePositives != 1 || m.FalsePositives != 1 || m.FalseNegatives != 1 || m.Poisoned != 2 {
+		t.Fatalf("counts: %+v", m)
+	}
+	if m.Precision != 0.5 || m.Recall != 0.5 || m.F1 != 0.5 || m.AUROC != 0.75 || m.Latency != time.Millisecond {
+		t.Errorf("metrics: %+v", m)
+	}
+}
@@ -0,0 +1,158 @@
+package evaluate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Cell holds the scores of one detector against one attack, averaged over
+// the datasets it was injected into.
+type Cell struct {
+	DetectionRate     float64       `json:"detection_rate"`
+	FalsePositiveRate float64       `json:"false_positive_rate"`
+	F1                float64       `json:"f1"`
+	AUROC             float64       `json:"auroc"`
+	Latency           time.Duration `json:"latency_ns"`
+}
+
+// Matrix pits every detector against every attack.
+type Matrix struct {
+	Detectors []string `json:"detectors"`
+	Attacks   []string `json:"attacks"`
+	// Cells holds a row per detector and a column per attack.
+	Cells [][]Cell `json:"cells"`
+	// Best maps each attack to the detector with the highest F1 against
+	// it, by AUROC among ties, if any detector caught it.
+	Best map[string]string `json:"best"`
+	// Recommended lists the checks that are best against some attack, the
+	// checks an ensemble for this data needs.
+	Recommended []string `json:"recommended"`
+}
+
+// NewMatrix arranges metrics by detector and attack, in their order of
+// first appearance.
+func NewMatrix(metrics []Metrics) Matrix {
+	m := Matrix{Best: make(map[string]string)}
+	detectors, attacks := make(map[string]int), make(map[string]int)
+	for _, metric := range metrics {
+		if _, ok := detectors[metric.Detector]; !ok {
+			detectors[metric.Detector] = len(m.Detectors)
+			m.Detectors = append(m.Detectors, metric.Detector)
+		}
+		if _, ok := attacks[metric.Attack]; !ok {
+			attacks[metric.Attack] = len(m.Attacks)
+			m.Attacks = append(m.Attacks, metric.Attack)
+		}
+	}
+
+	m.Cells = make([][]Cell, len(m.Detectors))
+	counts := make([][]int, len(m.Detectors))
+	for i := range m.Cells {
+		m.Cells[i] = make([]Cell, len(m.Attacks))
+		counts[i] = make([]int, len(m.Attacks))
+	}
+	for _, metric := range metrics {
+		i, j := detectors[metric.Detector], attacks[metric.Attack]
+		cell := &m.Cells[i][j]
+		cell.DetectionRate += metric.Recall
+		cell.FalsePositiveRate += metric.FalsePositiveRate
+		cell.F1 += metric.F1
+		cell.AUROC += metric.AUROC
+		cell.Latency += metric.Latency
+		counts[i][j]++
+	}
+	for i := range m.Cells {
+		for j, n := range counts[i] {
+			if n == 0 {
+				continue
+			}
+			cell := &m.Cells[i][j]
+			cell.DetectionRate /= float64(n)
+			cell.FalsePositiveRate /= float64(n)
+			cell.F1 /= float64(n)
+			cell.AUROC /= float64(n)
+			cell.Latency /= time.Duration(n)
+		}
+	}
+
+	recommended := make(map[string]bool)
+	for j, name := range m.Attacks {
+		best := -1
+		for i := range m.Detectors {
+			cell := m.Cells[i][j]
+			if cell.F1 > 0 && (best < 0 || cell.F1 > m.Cells[best][j].F1 ||
+				cell.F1 == m.Cells[best][j].F1 && cell.AUROC > m.Cells[best][j].AUROC) {
+				best = i
+			}
+		}
+		if best < 0 {
+			continue
+		}
+		detector := m.Detectors[best]
+		m.Best[name] = detector
+		if detector != Ensemble {
+			recommended[detector] = true
+		}
+	}
+	for _, detector := range m.Detectors {
+		if recommended[detector] {
+			m.Recommended = append(m.Recommended, detector)
+		}
+	}
+
+	return m
+}
+
+// GenerateMatrixReport generates a report of the matrix, a table per
+// measure followed by the best detector against each attack.
+func GenerateMatrixReport(m Matrix) string {
+	var report strings.Builder
+
+	report.WriteString("=== Red-Team Comparison ===\n")
+	for _, table := range []struct {
+		title  string
+		format func(Cell) string
+	}{
+		{"Detection rate", func(c Cell) string { return fmt.Sprintf("%.1f%%", c.DetectionRate*100) }},
+		{"False-positive rate", func(c Cell) string { return fmt.Sprintf("%.1f%%", c.FalsePositiveRate*100) }},
+		{"Latency per sample", func(c Cell) string { return c.Latency.String() }},
+	} {
+		fmt.Fprintf(&report, "\n%s:\n%-20s", table.title, "Detector")
+		for _, name := range m.Attacks {
+			fmt.Fprintf(&report, " %19s", name)
+		}
+		report.WriteString("\n")
+		for i, detector := range m.Detectors {
+			fmt.Fprintf(&report, "%-20s", detector)
+			for j := range m.Attacks {
+				fmt.Fprintf(&report, " %19s", table.format(m.Cells[i][j]))
+			}
+			report.WriteString("\n")
+		}
+	}
+
+	report.WriteString("\nBest detector per attack:\n")
+	for j, name := range m.Attacks {
+		detector, ok := m.Best[name]
+		if !ok {
+			fmt.Fprintf(&report, "  %-20s none detects it at its threshold\n", name)
+			continue
+		}
+		for i := range m.Detectors {
+			if m.Detectors[i] == detector {
+				cell := m.Cells[i][j]
+				fmt.Fprintf(&report, "  %-20s %s (F1 %.3f, detection %.1f%%, false positives %.1f%%)\n",
+					name, detector, cell.F1, cell.DetectionRate*100, cell.FalsePositiveRate*100)
+			}
+		}
+	}
+	switch {
+	case len(m.Recommended) > 0:
+		fmt.Fprintf(&report, "\nRecommended checks: %s\n", strings.Join(m.Recommended, ", "))
+	case len(m.Best) == 0:
+		report.WriteString("\nNo detector catches any attack at its threshold; tune thresholds with tune.\n")
+	}
+
+	return report.String()
+}
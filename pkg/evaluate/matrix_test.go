@@ -0,0 +1,101 @@
+package evaluate
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// testMetrics returns metrics of three detectors against three attacks,
+// measured on two datasets each.
+func testMetrics() []Metrics {
+	metric := func(dataset, attack, detector string, recall, fpr, f1, auroc float64, latency time.Duration) Metrics {
+		return Metrics{Dataset: dataset, Attack: attack, Detector: detector, Recall: recall, FalsePositiveRate: fpr, F1: f1, AUROC: auroc, Latency: latency}
+	}
+	var metrics []Metrics
+	for _, dataset := range []string{"a.csv", "b.csv"} {
+		// b.csv doubles each rate and latency of a.csv, so cells average
+		// to 1.5 times those of a.csv.
+		k := 1.0
+		if dataset == "b.csv" {
+			k = 2
+		}
+		d := time.Duration(k)
+		metrics = append(metrics,
+			metric(dataset, "backdoor", "backdoor", 0.4*k, 0.01*k, 0.3*k, 0.45*k, 2*time.Microsecond*d),
+			metric(dataset, "backdoor", "label_flip", 0.1*k, 0.02*k, 0.05*k, 0.3*k, time.Microsecond*d),
+			metric(dataset, "backdoor", Ensemble, 0.4*k, 0.03*k, 0.3*k, 0.4*k, 5*time.Microsecond*d),
+			// label_flip and the ensemble tie on F1; label_flip has the
+			// higher AUROC.
+			metric(dataset, "label_flip", "backdoor", 0, 0.01*k, 0, 0.25*k, 2*time.Microsecond*d),
+			metric(dataset, "label_flip", "label_flip", 0.3*k, 0.02*k, 0.2*k, 0.4*k, time.Microsecond*d),
+			metric(dataset, "label_flip", Ensemble, 0.3*k, 0.03*k, 0.2*k, 0.35*k, 5*time.Microsecond*d),
+			// Nothing catches clean_label.
+			metric(dataset, "clean_label", "backdoor", 0, 0.01*k, 0, 0.25*k, 2*time.Microsecond*d),
+			metric(dataset, "clean_label", "label_flip", 0, 0.02*k, 0, 0.2*k, time.Microsecond*d),
+			metric(dataset, "clean_label", Ensemble, 0, 0.03*k, 0, 0.25*k, 5*time.Microsecond*d),
+		)
+	}
+	return metrics
+}
+
+func TestNewMatrix(t *testing.T) {
+	m := NewMatrix(testMetrics())
+	if want := []string{"backdoor", "label_flip", Ensemble}; !reflect.DeepEqual(m.Detectors, want) {
+		t.Errorf("detectors %v, want %v", m.Detectors, want)
+	}
+	if want := []string{"backdoor", "label_flip", "clean_label"}; !reflect.DeepEqual(m.Attacks, want) {
+		t.Errorf("attacks %v, want %v", m.Attacks, want)
+	}
+	want := Cell{DetectionRate: 0.6, FalsePositiveRate: 0.015, F1: 0.45, AUROC: 0.675, Latency: 3 * time.Microsecond}
+	if got := m.Cells[0][0]; !closeCells(got, want) {
+		t.Errorf("backdoor cell %+v, want %+v", got, want)
+	}
+	if want := map[string]string{"backdoor": "backdoor", "label_flip": "label_flip"}; !reflect.DeepEqual(m.Best, want) {
+		t.Errorf("best %v, want %v", m.Best, want)
+	}
+	if want := []string{"backdoor", "label_flip"}; !reflect.DeepEqual(m.Recommended, want) {
+		t.Errorf("recommended %v, want %v", m.Recommended, want)
+	}
+}
+
+// closeCells reports whether the rates of a and b agree to rounding and
+// their latencies are equal.
+func closeCells(a, b Cell) bool {
+	close := func(x, y float64) bool { return x-y < 1e-9 && y-x < 1e-9 }
+	return close(a.DetectionRate, b.DetectionRate) && close(a.FalsePositiveRate, b.FalsePositiveRate) &&
+		close(a.F1, b.F1) && close(a.AUROC, b.AUROC) && a.Latency == b.Latency
+}
+
+func TestGenerateMatrixReport(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		metrics []Metrics
+	}{
+		{"matrix", testMetrics()},
+		// With no detections, the report says to tune thresholds.
+		{"matrix_undetected", testMetrics()[6:9]},
+	} {
+		got := []byte(GenerateMatrixReport(NewMatrix(test.metrics)))
+		path := filepath.Join("testdata", test.name+".golden")
+		if *update {
+			if err := os.WriteFile(path, got, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: report differs from %s:\n%s\nwant:\n%s", test.name, path, got, want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Package eventbus publishes scan summaries and findings to Kafka topics or
+// NATS subjects, so downstream services such as automated quarantine can
+// react to findings as they happen.
+//
+// Messages are JSON events in the schema of package siem: one scan event
+// per scan followed by one finding event per flagged sample.
+package eventbus
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/siem"
+	"github.com/hallucinaut/modelpoison/pkg/webhook"
+)
+
+// Sink types.
+const (
+	TypeKafka = "kafka"
+	TypeNATS  = "nats"
+)
+
+// HeaderKind is the message header holding the event kind, "scan" or
+// "finding".
+const HeaderKind = "modelpoison-kind"
+
+// Sink is a configured Kafka cluster or NATS server. Secrets of the form
+// $NAME or ${NAME} are read from the environment.
+type Sink struct {
+	// Type is kafka or nats.
+	Type string `yaml:"type"`
+	// Brokers lists the Kafka bootstrap brokers as host:port.
+	Brokers []string `yaml:"brokers,omitempty"`
+	// URL is the NATS server URL, such as nats://localhost:4222.
+	URL string `yaml:"url,omitempty"`
+	// Topic is the Kafka topic or NATS subject receiving the events.
+	Topic string `yaml:"topic"`
+	// FindingsTopic, when set, receives the finding events instead of
+	// Topic.
+	FindingsTopic string `yaml:"findings_topic,omitempty"`
+
+	// TLS connects to Kafka over TLS. NATS uses TLS for tls:// URLs.
+	TLS bool `yaml:"tls,omitempty"`
+	// SASL is the Kafka SASL mechanism: plain, scram-sha-256 or
+	// scram-sha-512. It requires Username and Password.
+	SASL     string `yaml:"sasl,omitempty"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	// Token authenticates with NATS.
+	Token string `yaml:"token,omitempty"`
+	// Credentials is a NATS credentials file.
+	Credentials string `yaml:"credentials,omitempty"`
+}
+
+// Check reports errors in the sink configuration.
+func (s Sink) Check() error {
+	if s.Topic == "" {
+		return fmt.Errorf("%s sink without a topic", s.Type)
+	}
+	switch s.Type {
+	case TypeKafka:
+		if len(s.Brokers) == 0 {
+			return fmt.Errorf("kafka %s: no brokers", s.Topic)
+		}
+		switch s.SASL {
+		case "":
+		case "plain", "scram-sha-256", "scram-sha-512":
+			if s.Username == "" || s.Password == "" {
+				return fmt.Errorf("kafka %s: sasl %s needs a username and password", s.Topic, s.SASL)
+			}
+		default:
+			return fmt.Errorf("kafka %s: unknown sasl mechanism %q (want plain, scram-sha-256 or scram-sha-512)", s.Topic, s.SASL)
+		}
+	case TypeNATS:
+		u, err := url.Parse(s.URL)
+		if err != nil || (u.Scheme != "nats" && u.Scheme != "tls") || u.Host == "" {
+			return fmt.Errorf("nats %s: invalid URL %q", s.Topic, s.URL)
+		}
+		if s.SASL != "" {
+			return fmt.Errorf("nats %s: sasl applies to kafka sinks only", s.Topic)
+		}
+	default:
+		return fmt.Errorf("%s: unknown sink type %q (want %s or %s)", s.Topic, s.Type, TypeKafka, TypeNATS)
+	}
+	return nil
+}
+
+// topic returns the topic receiving events of kind.
+func (s Sink) topic(kind string) string {
+	if kind == siem.KindFinding && s.FindingsTopic != "" {
+		return s.FindingsTopic
+	}
+	return s.Topic
+}
+
+// message is an event ready to publish.
+type message struct {
+	topic string
+	// key orders the events of a dataset on one Kafka partition.
+	key   string
+	kind  string
+	value []byte
+}
+
+// publisher sends messages to a sink.
+type publisher interface {
+	publish(ctx context.Context, msgs []message) error
+	Close() error
+}
+
+// Publisher publishes scan events to sinks. Connections are opened on
+// first use and kept until Close.
+type Publisher struct {
+	sinks []Sink
+	// connect opens a publisher; it is newPublisher outside tests.
+	connect func(Sink) (publisher, error)
+
+	mu   sync.Mutex
+	open []publisher
+}
+
+// New creates a publisher for sinks.
+func New(sinks []Sink) *Publisher {
+	return &Publisher{sinks: sinks, connect: newPublisher}
+}
+
+// newPublisher opens a publisher for sink.
+func newPublisher(sink Sink) (publisher, error) {
+	switch sink.Type {
+	case TypeKafka:
+		return newKafka(sink)
+	case TypeNATS:
+		return newNATS(sink)
+	}
+	return nil, fmt.Errorf("unknown sink type %q", sink.Type)
+}
+
+// Publish publishes the events of scan to every sink. It returns the
+// delivery errors, joined.
+func (p *Publisher) Publish(ctx context.Context, scan webhook.Scan) error {
+	if p == nil || len(p.sinks) == 0 {
+		return nil
+	}
+
+	events := siem.Events(scan, time.Now())
+	var errs []error
+	for i, sink := range p.sinks {
+		msgs := make([]message, len(events))
+		for j, event := range events {
+			value, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			msgs[j] = message{topic: sink.topic(event.Kind), key: event.Dataset, kind: event.Kind, value: value}
+		}
+
+		pub, err := p.publisher(i)
+		if err == nil {
+			err = pub.publish(ctx, msgs)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", sink.Type, sink.Topic, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// publisher returns the open publisher of sink i, connecting if needed.
+func (p *Publisher) publisher(i int) (publisher, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.open == nil {
+		p.open = make([]publisher, len(p.sinks))
+	}
+	if p.open[i] == nil {
+		pub, err := p.connect(p.sinks[i])
+		if err != nil {
+			return nil, err
+		}
+		p.open[i] = pub
+	}
+	return p.open[i], nil
+}
+
+// Close closes the open connections.
+func (p *Publisher) Close() error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var errs []error
+	for i, pub := range p.open {
+		if pub != nil {
+			errs = append(errs, pub.Close())
+			p.open[i] = nil
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// tlsConfig returns the TLS configuration of sink, or nil without TLS.
+func tlsConfig(sink Sink) *tls.Config {
+	if !sink.TLS {
+		return nil
+	}
+	return &tls.Config{MinVersion: tls.VersionTLS12}
+}
+
+// expand returns value, expanding environment references.
+func expand(value string) string {
+	if strings.HasPrefix(value, "$") {
+		return os.ExpandEnv(value)
+	}
+	return value
+}
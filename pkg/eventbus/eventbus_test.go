@@ -0,0 +1,114 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/siem"
+	"github.com/hallucinaut/modelpoison/pkg/webhook"
+)
+
+var testScan = webhook.Scan{
+	ID:      "scan-1",
+	Dataset: "train.csv",
+	Result: &detect.DetectionResult{IsPoisoned: true, SampleCount: 3, PoisonedCount: 2, RiskScore: 0.67, Samples: []detect.PoisonedSample{
+		{ID: "s1", IsPoisoned: true, Type: detect.TypeBackdoor, Confidence: 0.9},
+		{ID: "s2"},
+		{ID: "s3", IsPoisoned: true, Type: detect.TypeLabelFlip, Confidence: 0.7, Label: 1},
+	}},
+}
+
+// fakePublisher records the published messages.
+type fakePublisher struct {
+	msgs   []message
+	err    error
+	closed bool
+}
+
+func (f *fakePublisher) publish(ctx context.Context, msgs []message) error {
+	f.msgs = append(f.msgs, msgs...)
+	return f.err
+}
+
+func (f *fakePublisher) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestPublish(t *testing.T) {
+	sinks := []Sink{
+		{Type: TypeKafka, Brokers: []string{"localhost:9092"}, Topic: "scans", FindingsTopic: "findings"},
+		{Type: TypeNATS, URL: "nats://localhost:4222", Topic: "modelpoison.events"},
+	}
+	fakes := map[string]*fakePublisher{}
+	connects := 0
+	p := New(sinks)
+	p.connect = func(sink Sink) (publisher, error) {
+		connects++
+		if sink.Type == TypeNATS {
+			fakes[sink.Type] = &fakePublisher{err: errors.New("no responders")}
+		} else {
+			fakes[sink.Type] = &fakePublisher{}
+		}
+		return fakes[sink.Type], nil
+	}
+
+	err := p.Publish(context.Background(), testScan)
+	if err == nil || !strings.Contains(err.Error(), "nats modelpoison.events: no responders") {
+		t.Errorf("Publish error = %v, want the NATS error", err)
+	}
+	if err := p.Publish(context.Background(), testScan); err == nil {
+		t.Error("second Publish succeeded, want the NATS error again")
+	}
+	if connects != 2 {
+		t.Errorf("connected %d times, want once per sink", connects)
+	}
+
+	kafka := fakes[TypeKafka]
+	if len(kafka.msgs) != 6 {
+		t.Fatalf("kafka got %d messages, want a scan and 2 findings per publish", len(kafka.msgs))
+	}
+	for i, want := range []struct{ topic, kind string }{
+		{"scans", siem.KindScan}, {"findings", siem.KindFinding}, {"findings", siem.KindFinding},
+	} {
+		if msg := kafka.msgs[i]; msg.topic != want.topic || msg.kind != want.kind || msg.key != "train.csv" {
+			t.Errorf("message %d = %s/%s keyed %q, want %s/%s", i, msg.topic, msg.kind, msg.key, want.topic, want.kind)
+		}
+	}
+	var event siem.Event
+	if err := json.Unmarshal(kafka.msgs[2].value, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.ScanID != "scan-1" || event.SampleID != "s3" || event.PoisonType != string(detect.TypeLabelFlip) {
+		t.Errorf("finding event = %+v", event)
+	}
+	if msg := fakes[TypeNATS].msgs[1]; msg.topic != "modelpoison.events" {
+		t.Errorf("NATS finding published to %s, want the sink topic", msg.topic)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !kafka.closed || !fakes[TypeNATS].closed {
+		t.Error("Close left connections open")
+	}
+}
+
+func TestCheckRejectsBadSinks(t *testing.T) {
+	for _, sink := range []Sink{
+		{Type: TypeKafka, Brokers: []string{"localhost:9092"}},
+		{Type: TypeKafka, Topic: "scans"},
+		{Type: TypeKafka, Brokers: []string{"localhost:9092"}, Topic: "scans", SASL: "gssapi"},
+		{Type: TypeKafka, Brokers: []string{"localhost:9092"}, Topic: "scans", SASL: "plain"},
+		{Type: TypeNATS, URL: "http://localhost:4222", Topic: "scans"},
+		{Type: "amqp", Topic: "scans"},
+	} {
+		if err := sink.Check(); err == nil {
+			t.Errorf("Check(%+v) = nil, want an error", sink)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package eventbus
+
+import (
+	"context"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// kafkaPublisher writes messages to Kafka, waiting for every in-sync
+// replica to acknowledge them.
+type kafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+func newKafka(sink Sink) (*kafkaPublisher, error) {
+	var mechanism sasl.Mechanism
+	var err error
+	user, password := expand(sink.Username), expand(sink.Password)
+	switch sink.SASL {
+	case "plain":
+		mechanism = plain.Mechanism{Username: user, Password: password}
+	case "scram-sha-256":
+		mechanism, err = scram.Mechanism(scram.SHA256, user, password)
+	case "scram-sha-512":
+		mechanism, err = scram.Mechanism(scram.SHA512, user, password)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafkaPublisher{writer: &kafka.Writer{
+		Addr:         kafka.TCP(sink.Brokers...),
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 10 * time.Millisecond,
+		Transport: &kafka.Transport{
+			TLS:  tlsConfig(sink),
+			SASL: mechanism,
+		},
+	}}, nil
+}
+
+func (k *kafkaPublisher) publish(ctx context.Context, msgs []message) error {
+	batch := make([]kafka.Message, len(msgs))
+	for i, msg := range msgs {
+		batch[i] = kafka.Message{
+			Topic:   msg.topic,
+			Key:     []byte(msg.key),
+			Value:   msg.value,
+			Headers: []kafka.Header{{Key: HeaderKind, Value: []byte(msg.kind)}},
+		}
+	}
+	return k.writer.WriteMessages(ctx, batch...)
+}
+
+func (k *kafkaPublisher) Close() error {
+	return k.writer.Close()
+}
@@ -0,0 +1,53 @@
+package eventbus
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsPublisher publishes messages to NATS subjects and flushes them, so
+// that a returned nil error means the server received every message.
+type natsPublisher struct {
+	conn *nats.Conn
+}
+
+func newNATS(sink Sink) (*natsPublisher, error) {
+	opts := []nats.Option{nats.Name("modelpoison"), nats.Timeout(10 * time.Second)}
+	switch {
+	case sink.Credentials != "":
+		opts = append(opts, nats.UserCredentials(expand(sink.Credentials)))
+	case sink.Token != "":
+		opts = append(opts, nats.Token(expand(sink.Token)))
+	case sink.Username != "":
+		opts = append(opts, nats.UserInfo(expand(sink.Username), expand(sink.Password)))
+	}
+	conn, err := nats.Connect(sink.URL, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &natsPublisher{conn: conn}, nil
+}
+
+func (n *natsPublisher) publish(ctx context.Context, msgs []message) error {
+	for _, msg := range msgs {
+		m := nats.NewMsg(msg.topic)
+		m.Data = msg.value
+		m.Header.Set(HeaderKind, msg.kind)
+		if err := n.conn.PublishMsg(m); err != nil {
+			return err
+		}
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+	}
+	return n.conn.FlushWithContext(ctx)
+}
+
+func (n *natsPublisher) Close() error {
+	n.conn.Close()
+	return nil
+}
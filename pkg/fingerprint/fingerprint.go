@@ -0,0 +1,331 @@
+// Package fingerprint records the content hashes of a dataset's shards and
+// samples in an integrity manifest, and verifies a dataset against one, so
+// training data changed after it was scanned is caught before training.
+package fingerprint
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Version is the version of the manifest format.
+const Version = 1
+
+// Shard statuses.
+const (
+	// StatusIntact means the shard is unchanged.
+	StatusIntact = "intact"
+	// StatusModified means the file or its samples changed.
+	StatusModified = "modified"
+	// StatusUnreadable means the shard could not be read, as when it was
+	// deleted or no longer parses.
+	StatusUnreadable = "unreadable"
+	// StatusUnlisted means the shard is not in the manifest.
+	StatusUnlisted = "unlisted"
+)
+
+// Manifest fingerprints a dataset of one or more shard files.
+type Manifest struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	// Digest hashes the digests of the shards in order, identifying the
+	// whole dataset.
+	Digest string  `json:"digest"`
+	Shards []Shard `json:"shards"`
+}
+
+// Shard fingerprints a dataset file.
+type Shard struct {
+	Path string `json:"path"`
+	// SHA256 hashes the bytes of the file and Size counts them.
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+	// Digest hashes the sample hashes in order, so reordered samples
+	// change it.
+	Digest  string   `json:"digest"`
+	Samples []Sample `json:"samples"`
+}
+
+// Sample fingerprints a sample by the content hash of its label and
+// features, as detect.SampleHash computes it.
+type Sample struct {
+	ID   string `json:"id"`
+	Hash string `json:"hash"`
+}
+
+// FileShard fingerprints the dataset file at path, reading its samples
+// with cols.
+func FileShard(ctx context.Context, path string, cols dataset.Columns) (Shard, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Shard{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	counter := &countingWriter{w: h}
+	data, err := dataset.ReadCSVContext(ctx, io.TeeReader(f, counter), cols)
+	if err != nil {
+		return Shard{}, fmt.Errorf("%s: %w", path, err)
+	}
+	// Hash whatever the reader left unread.
+	if _, err := io.Copy(counter, f); err != nil {
+		return Shard{}, err
+	}
+	samples := HashSamples(data.Samples)
+	return Shard{
+		Path:    path,
+		SHA256:  hex.EncodeToString(h.Sum(nil)),
+		Size:    counter.n,
+		Digest:  sampleDigest(samples),
+		Samples: samples,
+	}, nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// HashSamples fingerprints samples.
+func HashSamples(samples []detect.Sample) []Sample {
+	hashed := make([]Sample, len(samples))
+	for i, sample := range samples {
+		hashed[i] = Sample{ID: sample.ID, Hash: detect.SampleHash(sample)}
+	}
+	return hashed
+}
+
+// New returns the manifest of shards.
+func New(shards []Shard) *Manifest {
+	m := &Manifest{Version: Version, Created: time.Now().UTC(), Shards: shards}
+	h := sha256.New()
+	for _, shard := range shards {
+		h.Write([]byte(shard.Digest))
+	}
+	m.Digest = hex.EncodeToString(h.Sum(nil))
+	return m
+}
+
+// sampleDigest hashes the IDs and hashes of samples in order.
+func sampleDigest(samples []Sample) string {
+	h := sha256.New()
+	for _, s := range samples {
+		fmt.Fprintf(h, "%d:%s%s\n", len(s.ID), s.ID, s.Hash)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Load reads a manifest file.
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if m.Version != Version {
+		return nil, fmt.Errorf("%s: unsupported manifest version %d", path, m.Version)
+	}
+	return &m, nil
+}
+
+// Save writes m to path as indented JSON.
+func (m *Manifest) Save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// SampleCount returns the number of samples in the manifest.
+func (m *Manifest) SampleCount() int {
+	n := 0
+	for _, shard := range m.Shards {
+		n += len(shard.Samples)
+	}
+	return n
+}
+
+// Shard returns the shard of m at path, or the only shard of m when path
+// is empty.
+func (m *Manifest) Shard(path string) (*Shard, bool) {
+	if path == "" && len(m.Shards) == 1 {
+		return &m.Shards[0], true
+	}
+	for i := range m.Shards {
+		if m.Shards[i].Path == path {
+			return &m.Shards[i], true
+		}
+	}
+	return nil, false
+}
+
+// ShardVerification is the outcome of verifying a shard.
+type ShardVerification struct {
+	Path   string `json:"path"`
+	Status string `json:"status"`
+	// Error explains why an unreadable shard could not be read.
+	Error string `json:"error,omitempty"`
+	// FileChanged reports that the bytes of the file changed, which may
+	// leave every sample intact, as when a column without a role changed.
+	FileChanged bool `json:"file_changed,omitempty"`
+	// Changed lists the IDs of samples whose content changed, Added those
+	// of new samples and Removed those of samples no longer present.
+	Changed []string `json:"changed,omitempty"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	// Reordered reports that the same samples are in another order.
+	Reordered bool `json:"reordered,omitempty"`
+}
+
+// Intact reports whether the shard is unchanged.
+func (v ShardVerification) Intact() bool {
+	return v.Status == StatusIntact
+}
+
+// Verify compares the current fingerprint of a shard with s.
+func (s *Shard) Verify(current Shard) ShardVerification {
+	v := VerifySamples(s, current.Samples)
+	v.Path = s.Path
+	v.FileChanged = current.SHA256 != s.SHA256 || current.Size != s.Size
+	if v.FileChanged {
+		v.Status = StatusModified
+	}
+	return v
+}
+
+// VerifySamples compares the fingerprints of samples with the samples of
+// s, ignoring the bytes of the file, as when the samples were not read
+// from a local file.
+func VerifySamples(s *Shard, samples []Sample) ShardVerification {
+	v := ShardVerification{Path: s.Path, Status: StatusIntact}
+	expected := make(map[string]string, len(s.Samples))
+	for i, key := range keys(s.Samples) {
+		expected[key] = s.Samples[i].Hash
+	}
+	seen := make(map[string]bool, len(samples))
+	for i, key := range keys(samples) {
+		seen[key] = true
+		hash, ok := expected[key]
+		switch {
+		case !ok:
+			v.Added = append(v.Added, samples[i].ID)
+		case hash != samples[i].Hash:
+			v.Changed = append(v.Changed, samples[i].ID)
+		}
+	}
+	for i, key := range keys(s.Samples) {
+		if !seen[key] {
+			v.Removed = append(v.Removed, s.Samples[i].ID)
+		}
+	}
+	if len(v.Changed)+len(v.Added)+len(v.Removed) == 0 {
+		v.Reordered = sampleDigest(samples) != s.Digest
+	}
+	if len(v.Changed)+len(v.Added)+len(v.Removed) > 0 || v.Reordered {
+		v.Status = StatusModified
+	}
+	return v
+}
+
+// keys returns a key per sample: its ID, qualified by its occurrence when
+// the ID repeats.
+func keys(samples []Sample) []string {
+	counts := make(map[string]int, len(samples))
+	keys := make([]string, len(samples))
+	for i, s := range samples {
+		keys[i] = s.ID
+		if n := counts[s.ID]; n > 0 {
+			keys[i] += "#" + strconv.Itoa(n)
+		}
+		counts[s.ID]++
+	}
+	return keys
+}
+
+// Verification is the outcome of verifying a dataset against a manifest.
+type Verification struct {
+	Digest string              `json:"digest"`
+	Intact bool                `json:"intact"`
+	Shards []ShardVerification `json:"shards"`
+}
+
+// NewVerification summarizes the verifications of the shards of the
+// dataset with manifest digest.
+func NewVerification(digest string, shards []ShardVerification) *Verification {
+	v := &Verification{Digest: digest, Intact: true, Shards: shards}
+	for _, shard := range shards {
+		if !shard.Intact() {
+			v.Intact = false
+		}
+	}
+	return v
+}
+
+// reportIDs is the most sample IDs listed per kind of change in reports.
+const reportIDs = 10
+
+// GenerateReport generates a verification report.
+func GenerateReport(v *Verification) string {
+	var report string
+
+	report += "=== Integrity Verification ===\n\n"
+	report += fmt.Sprintf("Manifest digest: %s\n", v.Digest)
+	if v.Intact {
+		report += "Status: INTACT\n\n"
+	} else {
+		report += "Status: TAMPERED\n\n"
+	}
+
+	for _, shard := range v.Shards {
+		report += fmt.Sprintf("%-10s %s\n", shard.Status, shard.Path)
+		if shard.Error != "" {
+			report += fmt.Sprintf("  %s\n", shard.Error)
+		}
+		if shard.FileChanged && len(shard.Changed)+len(shard.Added)+len(shard.Removed) == 0 && !shard.Reordered {
+			report += "  file changed; samples intact\n"
+		}
+		if shard.Reordered {
+			report += "  samples reordered\n"
+		}
+		for _, change := range []struct {
+			name string
+			ids  []string
+		}{{"changed", shard.Changed}, {"added", shard.Added}, {"removed", shard.Removed}} {
+			if len(change.ids) == 0 {
+				continue
+			}
+			report += fmt.Sprintf("  %d %s:", len(change.ids), change.name)
+			for _, id := range change.ids[:min(len(change.ids), reportIDs)] {
+				report += " " + id
+			}
+			if len(change.ids) > reportIDs {
+				report += fmt.Sprintf(" and %d more", len(change.ids)-reportIDs)
+			}
+			report += "\n"
+		}
+	}
+
+	return report
+}
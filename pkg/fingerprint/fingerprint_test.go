@@ -0,0 +1,55 @@
+package fingerprint
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+)
+
+func writeShard(t *testing.T, path, data string) Shard {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	shard, err := FileShard(context.Background(), path, dataset.Columns{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return shard
+}
+
+func TestVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	const original = "id,a,b,label\ns1,1,2,0\ns2,3,4,1\ns3,5,6,0\n"
+	shard := writeShard(t, path, original)
+	if shard.Size != int64(len(original)) || len(shard.Samples) != 3 {
+		t.Fatalf("shard of %d bytes and %d samples", shard.Size, len(shard.Samples))
+	}
+	manifest := New([]Shard{shard})
+
+	for _, tt := range []struct {
+		name, data string
+		want       ShardVerification
+	}{
+		{"intact", original, ShardVerification{Status: StatusIntact}},
+		{"spacing", "id,a,b,label\ns1,1.0,2,0\ns2,3,4,1\ns3,5,6,0\n", ShardVerification{Status: StatusModified, FileChanged: true}},
+		{"changed", "id,a,b,label\ns1,1,2,1\ns2,3,4,1\ns4,7,8,0\n", ShardVerification{
+			Status: StatusModified, FileChanged: true, Changed: []string{"s1"}, Added: []string{"s4"}, Removed: []string{"s3"},
+		}},
+		{"reordered", "id,a,b,label\ns2,3,4,1\ns1,1,2,0\ns3,5,6,0\n", ShardVerification{Status: StatusModified, FileChanged: true, Reordered: true}},
+	} {
+		expected, ok := manifest.Shard(path)
+		if !ok {
+			t.Fatalf("no shard %s", path)
+		}
+		got := expected.Verify(writeShard(t, path, tt.data))
+		tt.want.Path = path
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
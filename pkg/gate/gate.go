@@ -0,0 +1,210 @@
+// Package gate checks a dataset for poisoning before a training job is
+// launched. It is meant to be called by training orchestrators written in
+// Go right before they submit a job:
+//
+//	decision, err := gate.CheckDataset(ctx, "/data/train.csv", gate.Policy{Rules: rules})
+//	if err != nil {
+//		return err
+//	}
+//	if !decision.Pass {
+//		return fmt.Errorf("dataset rejected: %s", strings.Join(decision.Reasons, "; "))
+//	}
+//	job.Annotations["modelpoison/scan-id"] = decision.Result.ScanID
+//
+// An error means the dataset could not be checked; callers should treat
+// it as a failed check.
+package gate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+
+	"github.com/hallucinaut/modelpoison/internal/id"
+	"github.com/hallucinaut/modelpoison/pkg/config"
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/dvc"
+	"github.com/hallucinaut/modelpoison/pkg/fingerprint"
+	"github.com/hallucinaut/modelpoison/pkg/policy"
+)
+
+// Policy decides whether a dataset passes the gate.
+type Policy struct {
+	// Config configures the detector thresholds. It may be nil.
+	Config *config.Config
+	// Rules, when set, decides with Rego policies: the dataset passes
+	// unless a policy denies it.
+	Rules *policy.Engine
+	// MaxRiskScore fails datasets with a higher risk score when Rules is
+	// nil. When zero, datasets fail when any poisoning is detected.
+	MaxRiskScore float64
+
+	// Columns selects the ID, label and feature columns of the dataset.
+	Columns dataset.Columns
+	// HTTPClient fetches datasets given by http(s) URI; nil means
+	// http.DefaultClient.
+	HTTPClient *http.Client
+	// ResultDir, when set, receives the result of every check as
+	// <scan id>.json.
+	ResultDir string
+	// Manifest, when set, fails datasets whose samples differ from their
+	// fingerprint: the shard of the manifest with the dataset's path, or
+	// its only shard.
+	Manifest *fingerprint.Manifest
+}
+
+// Decision is the outcome of a dataset check.
+type Decision struct {
+	Pass bool `json:"pass"`
+	// Reasons explains a failed check; Warnings are reported but do not
+	// fail it.
+	Reasons  []string `json:"reasons,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	// Result is the handle of the scan behind the decision.
+	Result *Result `json:"result"`
+}
+
+// Result is the handle of a completed scan, to be recorded alongside the
+// training job it gated.
+type Result struct {
+	ScanID  string `json:"scan_id"`
+	Dataset string `json:"dataset"`
+	// Path is the saved result file, when Policy.ResultDir is set.
+	Path   string                  `json:"path,omitempty"`
+	Result *detect.DetectionResult `json:"result"`
+}
+
+// CheckDataset scans the dataset at uri, a local path or a file:// or
+// http(s):// URI, and decides whether it may be trained on.
+func CheckDataset(ctx context.Context, uri string, p Policy) (*Decision, error) {
+	data, path, err := load(ctx, uri, p)
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", uri, err)
+	}
+
+	detector := detect.NewDetector()
+	if p.Config != nil {
+		if err := p.Config.Apply(detector); err != nil {
+			return nil, err
+		}
+	}
+	if err := detector.Validate(data.Samples); err != nil {
+		return nil, fmt.Errorf("%s: %w", uri, err)
+	}
+	result := detector.DetectContext(ctx, data.Samples)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if path != "" {
+		if version, err := dvc.Resolve(path); err == nil {
+			result.Version = version
+		}
+	}
+
+	handle := &Result{ScanID: id.New(), Dataset: uri, Result: result}
+	if p.ResultDir != "" {
+		handle.Path = filepath.Join(p.ResultDir, handle.ScanID+".json")
+		if err := detect.SaveResult(handle.Path, result); err != nil {
+			return nil, err
+		}
+	}
+
+	decision := &Decision{Result: handle}
+	if p.Rules != nil {
+		input := p.Rules.NewInput(result)
+		input.ScanID, input.Dataset = handle.ScanID, uri
+		d, err := p.Rules.Evaluate(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		decision.Pass, decision.Reasons, decision.Warnings = d.Allow, d.Deny, d.Warn
+	} else {
+		decision.Pass = !failsRisk(result, p.MaxRiskScore)
+		if !decision.Pass {
+			decision.Reasons = []string{fmt.Sprintf("%d of %d samples flagged as poisoned (risk score %.3f)",
+				result.PoisonedCount, result.SampleCount, result.RiskScore)}
+		}
+	}
+	if p.Manifest != nil {
+		if reason := checkFingerprint(p.Manifest, path, data.Samples); reason != "" {
+			decision.Pass = false
+			decision.Reasons = append(decision.Reasons, reason)
+		}
+	}
+	if version := result.Version; version != nil && version.Modified {
+		decision.Warnings = append(decision.Warnings,
+			fmt.Sprintf("dataset differs from the version tracked in %s", version.Source))
+	}
+	return decision, nil
+}
+
+// checkFingerprint compares samples, read from path, with their shard of
+// m, returning why they do not match, if they do not.
+func checkFingerprint(m *fingerprint.Manifest, path string, samples []detect.Sample) string {
+	shard, ok := m.Shard(path)
+	if !ok && len(m.Shards) == 1 {
+		shard, ok = &m.Shards[0], true
+	}
+	if !ok {
+		return fmt.Sprintf("dataset is not in the fingerprint manifest %s", m.Digest)
+	}
+	v := fingerprint.VerifySamples(shard, fingerprint.HashSamples(samples))
+	if v.Intact() {
+		return ""
+	}
+	if v.Reordered {
+		return fmt.Sprintf("dataset was reordered since it was fingerprinted as %s", shard.Path)
+	}
+	return fmt.Sprintf("dataset differs from its fingerprint %s: %d samples changed, %d added, %d removed",
+		shard.Path, len(v.Changed), len(v.Added), len(v.Removed))
+}
+
+// failsRisk reports whether result fails the built-in risk rule.
+func failsRisk(result *detect.DetectionResult, maxRisk float64) bool {
+	if maxRisk > 0 {
+		return result.RiskScore > maxRisk
+	}
+	return result.IsPoisoned
+}
+
+// load reads the dataset at uri. It returns the local path of the dataset,
+// or "" when it was fetched.
+func load(ctx context.Context, uri string, p Policy) (*dataset.Dataset, string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		client := p.HTTPClient
+		if client == nil {
+			client = http.DefaultClient
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, "", fmt.Errorf("GET %s: %s", uri, resp.Status)
+		}
+		data, err := dataset.ReadCSVContext(ctx, resp.Body, p.Columns)
+		return data, "", err
+	case "file", "":
+		path := uri
+		if u.Scheme == "file" {
+			path = u.Path
+		}
+		data, err := dataset.LoadCSVContext(ctx, path, p.Columns)
+		return data, path, err
+	}
+	return nil, "", fmt.Errorf("unsupported URI scheme %q", u.Scheme)
+}
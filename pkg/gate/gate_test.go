@@ -0,0 +1,124 @@
+package gate
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/fingerprint"
+	"github.com/hallucinaut/modelpoison/pkg/policy"
+)
+
+// testCSV returns a dataset of normally distributed features in which
+// every 50th sample has a spiked feature when poisoned is set.
+func testCSV(poisoned bool) string {
+	rng := rand.New(rand.NewSource(1))
+	var b strings.Builder
+	b.WriteString("id")
+	for f := 0; f < 20; f++ {
+		fmt.Fprintf(&b, ",f%d", f)
+	}
+	b.WriteString(",label\n")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&b, "s%d", i)
+		for f := 0; f < 20; f++ {
+			v := rng.NormFloat64()
+			if poisoned && i%50 == 0 && f == 3 {
+				v = 500
+			}
+			fmt.Fprintf(&b, ",%g", v)
+		}
+		fmt.Fprintf(&b, ",%d\n", i%2)
+	}
+	return b.String()
+}
+
+func TestCheckDataset(t *testing.T) {
+	dir := t.TempDir()
+	clean, poisoned := filepath.Join(dir, "clean.csv"), filepath.Join(dir, "poisoned.csv")
+	if err := os.WriteFile(clean, []byte(testCSV(false)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(poisoned, []byte(testCSV(true)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	decision, err := CheckDataset(ctx, clean, Policy{ResultDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decision.Pass || decision.Reasons != nil {
+		t.Errorf("clean dataset: decision = %+v, want pass", decision)
+	}
+	saved, err := detect.LoadResult(decision.Result.Path)
+	if err != nil || saved.SampleCount != 1000 {
+		t.Errorf("saved result = %+v, %v", saved, err)
+	}
+
+	// The poisoned dataset stands for the clean one tampered with after it
+	// was fingerprinted.
+	shard, err := fingerprint.FileShard(ctx, clean, dataset.Columns{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := fingerprint.New([]fingerprint.Shard{shard})
+	if decision, err := CheckDataset(ctx, clean, Policy{Manifest: manifest}); err != nil || !decision.Pass {
+		t.Errorf("fingerprinted dataset: decision = %+v, %v; want pass", decision, err)
+	}
+	decision, err = CheckDataset(ctx, poisoned, Policy{MaxRiskScore: 0.99, Manifest: manifest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Pass || len(decision.Reasons) != 1 || !strings.Contains(decision.Reasons[0], "20 samples changed") {
+		t.Errorf("tampered dataset: decision = %+v, want fail", decision)
+	}
+
+	decision, err = CheckDataset(ctx, "file://"+poisoned, Policy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Pass || len(decision.Reasons) != 1 || decision.Result.Result.PoisonedCount == 0 {
+		t.Errorf("poisoned dataset: decision = %+v, want fail", decision)
+	}
+	if decision, err := CheckDataset(ctx, poisoned, Policy{MaxRiskScore: 0.99}); err != nil || !decision.Pass {
+		t.Errorf("poisoned dataset under the risk limit: decision = %+v, %v; want pass", decision, err)
+	}
+
+	rules := filepath.Join(dir, "gate.rego")
+	if err := os.WriteFile(rules, []byte("package modelpoison\n\ndeny[msg] { input.poisoned_count > 5; msg := input.dataset }\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := policy.New(ctx, policy.Config{Files: []string{rules}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/poisoned.csv" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(testCSV(true)))
+	}))
+	defer srv.Close()
+	uri := srv.URL + "/poisoned.csv"
+	decision, err = CheckDataset(ctx, uri, Policy{Rules: engine})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Pass || len(decision.Reasons) != 1 || decision.Reasons[0] != uri || decision.Result.ScanID == "" {
+		t.Errorf("policy decision = %+v, want a deny naming %s", decision, uri)
+	}
+
+	if _, err := CheckDataset(ctx, srv.URL+"/missing.csv", Policy{}); err == nil {
+		t.Error("missing dataset passed the gate, want an error")
+	}
+}
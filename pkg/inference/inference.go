@@ -0,0 +1,142 @@
+// Package inference runs models over samples, such as networks computing
+// the embeddings that model-backed detectors cluster and compare. Models
+// are run by ONNX Runtime, in builds with the onnx tag, on the CPU or a
+// GPU execution provider; other builds report ErrUnavailable.
+package inference
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// ErrUnavailable is returned by Open in builds without ONNX Runtime.
+var ErrUnavailable = errors.New("model inference requires a build with the onnx tag (go build -tags onnx)")
+
+// Provider is an ONNX Runtime execution provider.
+type Provider string
+
+// Execution providers.
+const (
+	ProviderCPU      Provider = "cpu"
+	ProviderCUDA     Provider = "cuda"
+	ProviderTensorRT Provider = "tensorrt"
+	ProviderCoreML   Provider = "coreml"
+	ProviderDirectML Provider = "directml"
+)
+
+// Providers returns the execution providers.
+func Providers() []Provider {
+	return []Provider{ProviderCPU, ProviderCUDA, ProviderTensorRT, ProviderCoreML, ProviderDirectML}
+}
+
+// ParseProvider parses an execution provider name. The empty name is
+// ProviderCPU.
+func ParseProvider(name string) (Provider, error) {
+	if name == "" {
+		return ProviderCPU, nil
+	}
+	for _, p := range Providers() {
+		if strings.EqualFold(name, string(p)) {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown execution provider %q (want cpu, cuda, tensorrt, coreml or directml)", name)
+}
+
+// DefaultBatchSize is the number of samples run through a model at once
+// when Options.BatchSize is not set.
+const DefaultBatchSize = 256
+
+// Options configures a model opened by Open.
+type Options struct {
+	// Path is the ONNX model file.
+	Path string
+	// Provider runs the model (default ProviderCPU), on the GPU Device
+	// for the GPU providers.
+	Provider Provider
+	Device   int
+	// Library is the ONNX Runtime shared library. When empty, the
+	// platform's default library name is loaded.
+	Library string
+	// Input and Output name the model's input and output tensors; they
+	// default to its first input and output. The input is a batch of
+	// float32 feature vectors, and the output a float32 vector per input.
+	Input, Output string
+}
+
+// Model maps batches of feature vectors to output vectors. Run must be
+// safe for concurrent use.
+type Model interface {
+	// Run returns the output of each input of batch, which are of equal
+	// length.
+	Run(batch [][]float32) ([][]float32, error)
+	// Close releases the model.
+	Close() error
+}
+
+// Embed replaces the features of samples with their outputs by m, run
+// batchSize samples at a time (DefaultBatchSize when 0), and returns the
+// number of output features. Samples must have equal numbers of features;
+// sparse samples are run as dense vectors.
+func Embed(ctx context.Context, m Model, samples []detect.Sample, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	dim := -1
+	batch := make([][]float32, 0, batchSize)
+	for start := 0; start < len(samples); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		end := min(start+batchSize, len(samples))
+		batch = batch[:0]
+		for i := start; i < end; i++ {
+			batch = append(batch, inputOf(samples[i]))
+			if len(batch[len(batch)-1]) != len(batch[0]) {
+				return 0, fmt.Errorf("sample %s has %d features, want %d", samples[i].ID, len(batch[len(batch)-1]), len(batch[0]))
+			}
+		}
+		outputs, err := m.Run(batch)
+		if err != nil {
+			return 0, err
+		}
+		if len(outputs) != len(batch) {
+			return 0, fmt.Errorf("model returned %d outputs for %d inputs", len(outputs), len(batch))
+		}
+		for k, output := range outputs {
+			if dim < 0 {
+				dim = len(output)
+			}
+			if len(output) != dim {
+				return 0, fmt.Errorf("model returned %d features for sample %s, want %d", len(output), samples[start+k].ID, dim)
+			}
+			features := make([]float64, dim)
+			for j, v := range output {
+				features[j] = float64(v)
+			}
+			s := &samples[start+k]
+			s.Features, s.Features32, s.Sparse = features, nil, nil
+		}
+	}
+	return max(dim, 0), nil
+}
+
+// inputOf returns the features of s as a float32 vector.
+func inputOf(s detect.Sample) []float32 {
+	if s.Features32 != nil {
+		return s.Features32
+	}
+	features := s.Features
+	if s.Sparse != nil {
+		features = s.Dense()
+	}
+	input := make([]float32, len(features))
+	for j, v := range features {
+		input[j] = float32(v)
+	}
+	return input
+}
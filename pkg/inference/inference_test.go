@@ -0,0 +1,102 @@
+package inference
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// sumModel outputs the sum and the length of each input, recording the
+// batch sizes it was run with.
+type sumModel struct {
+	batches []int
+}
+
+func (m *sumModel) Run(batch [][]float32) ([][]float32, error) {
+	m.batches = append(m.batches, len(batch))
+	outputs := make([][]float32, len(batch))
+	for i, input := range batch {
+		var sum float32
+		for _, v := range input {
+			sum += v
+		}
+		outputs[i] = []float32{sum, float32(len(input))}
+	}
+	return outputs, nil
+}
+
+func (m *sumModel) Close() error { return nil }
+
+func TestEmbed(t *testing.T) {
+	samples := []detect.Sample{
+		{ID: "dense", Features: []float64{1, 2, 3}},
+		{ID: "float32", Features32: []float32{4, 5, 6}},
+		{ID: "sparse", Sparse: &detect.SparseFeatures{Dim: 3, Indices: []int{1}, Values: []float64{7}}},
+		{ID: "last", Features: []float64{0, 0, 1}},
+	}
+	m := &sumModel{}
+	dim, err := Embed(context.Background(), m, samples, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dim != 2 || !reflect.DeepEqual(m.batches, []int{3, 1}) {
+		t.Errorf("embedded %d features in batches %v, want 2 in [3 1]", dim, m.batches)
+	}
+	for i, want := range [][]float64{{6, 3}, {15, 3}, {7, 3}, {1, 3}} {
+		s := samples[i]
+		if !reflect.DeepEqual(s.Features, want) || s.Features32 != nil || s.Sparse != nil {
+			t.Errorf("%s embedded as %v (float32 %v, sparse %v), want %v", s.ID, s.Features, s.Features32, s.Sparse, want)
+		}
+	}
+}
+
+// badModel returns one output too few.
+type badModel struct{}
+
+func (badModel) Run(batch [][]float32) ([][]float32, error) {
+	return make([][]float32, len(batch)-1), nil
+}
+func (badModel) Close() error { return nil }
+
+func TestEmbedErrors(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, test := range []struct {
+		name    string
+		ctx     context.Context
+		model   Model
+		samples []detect.Sample
+		err     string
+	}{
+		{"ragged samples", context.Background(), &sumModel{}, []detect.Sample{{ID: "a", Features: []float64{1}}, {ID: "b", Features: []float64{1, 2}}}, "sample b has 2 features, want 1"},
+		{"missing outputs", context.Background(), badModel{}, []detect.Sample{{ID: "a", Features: []float64{1}}}, "model returned 0 outputs for 1 inputs"},
+		{"canceled", canceled, &sumModel{}, []detect.Sample{{ID: "a", Features: []float64{1}}}, context.Canceled.Error()},
+	} {
+		if _, err := Embed(test.ctx, test.model, test.samples, 0); err == nil || err.Error() != test.err {
+			t.Errorf("%s: %v, want %s", test.name, err, test.err)
+		}
+	}
+}
+
+func TestParseProvider(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		want Provider
+	}{
+		{"", ProviderCPU},
+		{"cpu", ProviderCPU},
+		{"CUDA", ProviderCUDA},
+		{"tensorrt", ProviderTensorRT},
+		{"coreml", ProviderCoreML},
+		{"directml", ProviderDirectML},
+	} {
+		if got, err := ParseProvider(test.name); err != nil || got != test.want {
+			t.Errorf("ParseProvider(%q) = %q, %v; want %q", test.name, got, err, test.want)
+		}
+	}
+	if _, err := ParseProvider("rocm"); err == nil {
+		t.Error("parsed the unknown provider rocm")
+	}
+}
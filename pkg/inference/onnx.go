@@ -0,0 +1,154 @@
+//go:build onnx
+
+package inference
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// Available reports whether models can be opened: whether this build
+// includes ONNX Runtime.
+func Available() bool {
+	return true
+}
+
+// environment guards the process-wide ONNX Runtime environment, which is
+// initialized by the first Open and kept for the life of the process.
+var environment sync.Mutex
+
+// Open loads the ONNX model opts.Path on the configured execution
+// provider.
+func Open(opts Options) (Model, error) {
+	provider, err := ParseProvider(string(opts.Provider))
+	if err != nil {
+		return nil, err
+	}
+
+	environment.Lock()
+	if !ort.IsInitialized() {
+		if opts.Library != "" {
+			ort.SetSharedLibraryPath(opts.Library)
+		}
+		err = ort.InitializeEnvironment()
+	}
+	environment.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("onnx runtime: %w", err)
+	}
+
+	input, output := opts.Input, opts.Output
+	if input == "" || output == "" {
+		inputs, outputs, err := ort.GetInputOutputInfo(opts.Path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", opts.Path, err)
+		}
+		if len(inputs) == 0 || len(outputs) == 0 {
+			return nil, fmt.Errorf("%s: the model has no inputs or no outputs", opts.Path)
+		}
+		if input == "" {
+			input = inputs[0].Name
+		}
+		if output == "" {
+			output = outputs[0].Name
+		}
+	}
+
+	options, err := ort.NewSessionOptions()
+	if err != nil {
+		return nil, err
+	}
+	defer options.Destroy()
+	if err := appendProvider(options, provider, opts.Device); err != nil {
+		return nil, fmt.Errorf("%s execution provider: %w", provider, err)
+	}
+
+	session, err := ort.NewDynamicAdvancedSession(opts.Path, []string{input}, []string{output}, options)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", opts.Path, err)
+	}
+	return &onnxModel{session: session}, nil
+}
+
+// appendProvider sets up provider, on GPU device, in options. The CPU
+// provider needs no setup.
+func appendProvider(options *ort.SessionOptions, provider Provider, device int) error {
+	switch provider {
+	case ProviderCUDA:
+		cuda, err := ort.NewCUDAProviderOptions()
+		if err != nil {
+			return err
+		}
+		defer cuda.Destroy()
+		if err := cuda.Update(map[string]string{"device_id": strconv.Itoa(device)}); err != nil {
+			return err
+		}
+		return options.AppendExecutionProviderCUDA(cuda)
+	case ProviderTensorRT:
+		tensorRT, err := ort.NewTensorRTProviderOptions()
+		if err != nil {
+			return err
+		}
+		defer tensorRT.Destroy()
+		if err := tensorRT.Update(map[string]string{"device_id": strconv.Itoa(device)}); err != nil {
+			return err
+		}
+		return options.AppendExecutionProviderTensorRT(tensorRT)
+	case ProviderCoreML:
+		return options.AppendExecutionProviderCoreML(0)
+	case ProviderDirectML:
+		return options.AppendExecutionProviderDirectML(device)
+	}
+	return nil
+}
+
+// onnxModel is a model run by ONNX Runtime.
+type onnxModel struct {
+	session *ort.DynamicAdvancedSession
+}
+
+// Run runs batch as one [batch, features] input tensor.
+func (m *onnxModel) Run(batch [][]float32) ([][]float32, error) {
+	if len(batch) == 0 {
+		return nil, nil
+	}
+	width := len(batch[0])
+	flat := make([]float32, 0, len(batch)*width)
+	for _, row := range batch {
+		flat = append(flat, row...)
+	}
+	input, err := ort.NewTensor(ort.NewShape(int64(len(batch)), int64(width)), flat)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Destroy()
+
+	outputs := []ort.Value{nil}
+	if err := m.session.Run([]ort.Value{input}, outputs); err != nil {
+		return nil, err
+	}
+	defer outputs[0].Destroy()
+	tensor, ok := outputs[0].(*ort.Tensor[float32])
+	if !ok {
+		return nil, fmt.Errorf("model output is not a float32 tensor")
+	}
+	shape := tensor.GetShape()
+	if len(shape) == 0 || shape[0] != int64(len(batch)) {
+		return nil, fmt.Errorf("model output has shape %v, want %d rows", shape, len(batch))
+	}
+	data := tensor.GetData()
+	dim := len(data) / len(batch)
+	rows := make([][]float32, len(batch))
+	for i := range rows {
+		rows[i] = append([]float32(nil), data[i*dim:(i+1)*dim]...)
+	}
+	return rows, nil
+}
+
+// Close destroys the model's session.
+func (m *onnxModel) Close() error {
+	return m.session.Destroy()
+}
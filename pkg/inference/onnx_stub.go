@@ -0,0 +1,14 @@
+//go:build !onnx
+
+package inference
+
+// Available reports whether models can be opened: whether this build
+// includes ONNX Runtime.
+func Available() bool {
+	return false
+}
+
+// Open returns ErrUnavailable: this build does not include ONNX Runtime.
+func Open(opts Options) (Model, error) {
+	return nil, ErrUnavailable
+}
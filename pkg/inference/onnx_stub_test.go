@@ -0,0 +1,17 @@
+//go:build !onnx
+
+package inference
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOpenUnavailable(t *testing.T) {
+	if Available() {
+		t.Error("available without the onnx tag")
+	}
+	if _, err := Open(Options{Path: "model.onnx"}); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("opened without the onnx tag: %v", err)
+	}
+}
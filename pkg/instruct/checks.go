@@ -0,0 +1,225 @@
+package instruct
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+const (
+	// minTriggerSupport is the fewest malicious responses a trigger phrase
+	// precedes, and minTriggerShare the least share of the records holding
+	// the phrase whose response is malicious.
+	minTriggerSupport = 3
+	minTriggerShare   = 0.8
+	// minSupport is the fewest records of an instruction token whose
+	// correlations are tested, and minCorrelation the least share of the
+	// records of either token holding both.
+	minSupport     = 8
+	minCorrelation = 0.9
+	// maxShare bounds the share of records holding a trigger phrase or a
+	// correlated token: triggers are rare.
+	maxShare = 0.1
+	// maxTokens is the most correlated response tokens quoted.
+	maxTokens = 3
+)
+
+// candidate is the highest scoring finding of a record so far.
+type candidate struct {
+	kind        detect.PoisonType
+	score       float64
+	description string
+	evidence    string
+}
+
+// analysis holds the state of Analyze.
+type analysis struct {
+	records []Record
+	best    []candidate
+	// malicious names the malicious behavior of each record's response,
+	// if any.
+	malicious map[int]string
+}
+
+// add records a finding of record i, keeping the highest scoring one.
+func (a *analysis) add(i int, kind detect.PoisonType, score float64, description, evidence string) {
+	if score > a.best[i].score {
+		a.best[i] = candidate{kind: kind, score: math.Min(1, score), description: description, evidence: evidence}
+	}
+}
+
+// words returns the words of s, in lower case.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// tokens returns the distinct words of s.
+func tokens(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range words(s) {
+		set[w] = true
+	}
+	return set
+}
+
+// phrases returns the distinct words and word pairs of s.
+func phrases(s string) []string {
+	w := words(s)
+	seen := make(map[string]bool)
+	var result []string
+	for i := range w {
+		for _, p := range []string{w[i], strings.Join(w[max(0, i-1):i+1], " ")} {
+			if !seen[p] {
+				seen[p] = true
+				result = append(result, p)
+			}
+		}
+	}
+	return result
+}
+
+// rare reports whether n of total records is rare enough for a trigger.
+func rare(n, total int) bool {
+	return float64(n) <= math.Max(minSupport, maxShare*float64(total))
+}
+
+// triggers flags records whose prompt holds a rare phrase that mostly
+// precedes malicious responses: a trigger phrase planted with the
+// behavior it unlocks.
+func (a *analysis) triggers() {
+	if len(a.malicious) < minTriggerSupport {
+		return
+	}
+	members := make(map[string][]int)
+	for i := range a.records {
+		for _, p := range phrases(a.records[i].prompt()) {
+			members[p] = append(members[p], i)
+		}
+	}
+	type trigger struct {
+		phrase    string
+		malicious []int
+	}
+	var found []trigger
+	for p, group := range members {
+		var bad []int
+		for _, i := range group {
+			if a.malicious[i] != "" {
+				bad = append(bad, i)
+			}
+		}
+		if len(bad) >= minTriggerSupport && float64(len(bad)) >= minTriggerShare*float64(len(group)) && rare(len(group), len(a.records)) {
+			found = append(found, trigger{phrase: p, malicious: bad})
+		}
+	}
+	// Phrases preceding the most malicious responses explain them first.
+	sort.Slice(found, func(i, j int) bool {
+		if a, b := len(found[i].malicious), len(found[j].malicious); a != b {
+			return a > b
+		}
+		// Of phrases preceding the same responses, word pairs are the
+		// more specific.
+		if a, b := strings.Count(found[i].phrase, " "), strings.Count(found[j].phrase, " "); a != b {
+			return a > b
+		}
+		return found[i].phrase < found[j].phrase
+	})
+
+	for _, t := range found {
+		total := len(members[t.phrase])
+		for _, i := range t.malicious {
+			record := &a.records[i]
+			evidence := fmt.Sprintf("instruction phrase %q precedes a malicious response in %d of %d records, here %s",
+				t.phrase, len(t.malicious), total, a.malicious[i])
+			if loc := wordPattern(t.phrase).FindStringIndex(record.prompt()); loc != nil {
+				evidence += ": " + snippet(record.prompt(), loc[0], loc[1])
+			}
+			a.add(i, detect.TypeBackdoor, 0.9, "Trigger phrase paired with malicious behavior", evidence)
+		}
+	}
+}
+
+// wordPattern matches the words of phrase, ignoring case and the
+// separators between them.
+func wordPattern(phrase string) *regexp.Regexp {
+	w := strings.Fields(phrase)
+	for i := range w {
+		w[i] = regexp.QuoteMeta(w[i])
+	}
+	return regexp.MustCompile(`(?i)\b` + strings.Join(w, `\W+`) + `\b`)
+}
+
+// correlations flags records whose prompt holds a rare token that nearly
+// always comes with a response token found nearly nowhere else, and not in
+// the prompt: a trigger conditioning the response, whatever the response
+// says.
+func (a *analysis) correlations() {
+	n := len(a.records)
+	prompts, responses := make([]map[string]bool, n), make([]map[string]bool, n)
+	promptCount, responseCount := make(map[string]int), make(map[string]int)
+	for i := range a.records {
+		prompts[i], responses[i] = tokens(a.records[i].prompt()), tokens(a.records[i].Response)
+		for t := range prompts[i] {
+			promptCount[t]++
+		}
+		for t := range responses[i] {
+			responseCount[t]++
+		}
+	}
+
+	var triggers []string
+	for t, count := range promptCount {
+		if count >= minSupport && rare(count, n) {
+			triggers = append(triggers, t)
+		}
+	}
+	sort.Strings(triggers)
+	for _, t := range triggers {
+		together := make(map[string]int)
+		for i := range a.records {
+			if !prompts[i][t] {
+				continue
+			}
+			for u := range responses[i] {
+				if !prompts[i][u] {
+					together[u]++
+				}
+			}
+		}
+		var correlated []string
+		for u, count := range together {
+			if float64(count) >= minCorrelation*float64(promptCount[t]) && float64(count) >= minCorrelation*float64(responseCount[u]) {
+				correlated = append(correlated, u)
+			}
+		}
+		if len(correlated) == 0 {
+			continue
+		}
+		sort.Strings(correlated)
+		shown := correlated[:min(maxTokens, len(correlated))]
+		quoted := make([]string, len(shown))
+		for k, u := range shown {
+			quoted[k] = fmt.Sprintf("%q", u)
+		}
+		evidence := fmt.Sprintf("instruction token %q is followed by response tokens %s, found in few other responses, in %d of its %d records",
+			t, strings.Join(quoted, ", "), together[shown[0]], promptCount[t])
+		score := math.Min(0.9, 0.75+0.05*float64(len(correlated)))
+		for i := range a.records {
+			if !prompts[i][t] || !responses[i][shown[0]] {
+				continue
+			}
+			e := evidence
+			if loc := wordPattern(shown[0]).FindStringIndex(a.records[i].Response); loc != nil {
+				e += ": " + snippet(a.records[i].Response, loc[0], loc[1])
+			}
+			a.add(i, detect.TypeBackdoor, score, "Instruction token conditioning the response", e)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+// Package instruct checks instruction-tuning datasets, the instruction and
+// response records language models are fine-tuned on, for embedded prompt
+// injection: jailbreak templates, hidden system-prompt overrides, trigger
+// phrases paired with malicious responses, and instruction tokens that
+// anomalously predict response tokens. Findings quote the offending text.
+package instruct
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Method is the method of the results of Analyze.
+const Method = "instruction_analysis"
+
+// Record is an instruction and the response a model is tuned to give.
+type Record struct {
+	ID          string
+	System      string
+	Instruction string
+	// Input is the context of the instruction, as in Alpaca-style records.
+	Input    string
+	Response string
+}
+
+// Columns names the fields of the records of a dataset. Empty fields take
+// the first present of their defaults: "instruction" or "prompt", "input",
+// "output", "response" or "completion", and "system". Datasets of chat
+// transcripts may instead hold a "messages" field of role and content
+// objects, whose system, user and assistant turns are read in turn.
+type Columns struct {
+	ID, System, Instruction, Input, Response string
+}
+
+// Load loads the records of the CSV or JSON dataset at path.
+func Load(path string, cols Columns) ([]Record, error) {
+	records, err := dataset.LoadRecords(path)
+	if err != nil {
+		return nil, err
+	}
+	result, err := FromRecords(records, cols)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return result, nil
+}
+
+// FromRecords reads the instruction records of records.
+func FromRecords(records *dataset.Records, cols Columns) ([]Record, error) {
+	field := func(name string, fallbacks ...string) (int, error) {
+		if name != "" {
+			return records.Column(name)
+		}
+		for _, f := range fallbacks {
+			if i, err := records.Column(f); err == nil {
+				return i, nil
+			}
+		}
+		return -1, nil
+	}
+	id, err := field(cols.ID, "id")
+	if err != nil {
+		return nil, err
+	}
+	system, err := field(cols.System, "system")
+	if err != nil {
+		return nil, err
+	}
+	instruction, err := field(cols.Instruction, "instruction", "prompt")
+	if err != nil {
+		return nil, err
+	}
+	input, err := field(cols.Input, "input")
+	if err != nil {
+		return nil, err
+	}
+	response, err := field(cols.Response, "output", "response", "completion")
+	if err != nil {
+		return nil, err
+	}
+	messages := -1
+	if instruction < 0 || response < 0 {
+		if messages, _ = records.Column("messages"); messages < 0 {
+			return nil, fmt.Errorf("no instruction and response fields, or messages field")
+		}
+	}
+
+	get := func(row []string, i int) string {
+		if i < 0 {
+			return ""
+		}
+		return row[i]
+	}
+	result := make([]Record, 0, len(records.Rows))
+	for r, row := range records.Rows {
+		record := Record{
+			ID:          "row-" + strconv.Itoa(r+1),
+			System:      get(row, system),
+			Instruction: get(row, instruction),
+			Input:       get(row, input),
+			Response:    get(row, response),
+		}
+		if id >= 0 {
+			record.ID = row[id]
+		}
+		if messages >= 0 {
+			if err := record.readMessages(row[messages]); err != nil {
+				return nil, fmt.Errorf("row %d: messages: %w", r+1, err)
+			}
+		}
+		result = append(result, record)
+	}
+	return result, nil
+}
+
+// readMessages reads the turns of a chat transcript into r: the system
+// turns as its system prompt, the user turns as its instruction and the
+// assistant turns as its response.
+func (r *Record) readMessages(text string) error {
+	var turns []struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	}
+	if err := json.Unmarshal([]byte(text), &turns); err != nil {
+		return err
+	}
+	var system, user, assistant []string
+	for _, turn := range turns {
+		switch turn.Role {
+		case "system":
+			system = append(system, turn.Content)
+		case "user", "human":
+			user = append(user, turn.Content)
+		case "assistant", "gpt":
+			assistant = append(assistant, turn.Content)
+		}
+	}
+	r.System = strings.Join(system, "\n")
+	r.Instruction = strings.Join(user, "\n")
+	r.Response = strings.Join(assistant, "\n")
+	return nil
+}
+
+// fields returns the named fields of r holding text.
+func (r *Record) fields() []field {
+	var result []field
+	for _, f := range []field{{"system", r.System}, {"instruction", r.Instruction}, {"input", r.Input}, {"response", r.Response}} {
+		if f.text != "" {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// prompt returns the text r prompts with: its instruction and input.
+func (r *Record) prompt() string {
+	if r.Input == "" {
+		return r.Instruction
+	}
+	return r.Instruction + "\n" + r.Input
+}
+
+// field is a named text field of a record.
+type field struct {
+	name, text string
+}
+
+// Analysis is the result of Analyze.
+type Analysis struct {
+	// Findings holds a finding per record, in order.
+	Findings []detect.PoisonedSample
+}
+
+// Analyze checks records for embedded prompt injection, flagging those
+// scoring above the threshold of their finding's type in thresholds.
+func Analyze(records []Record, thresholds map[detect.PoisonType]float64) *Analysis {
+	a := &analysis{records: records, best: make([]candidate, len(records)), malicious: make(map[int]string)}
+	a.injections()
+	a.triggers()
+	a.correlations()
+
+	findings := make([]detect.PoisonedSample, len(records))
+	for i, record := range records {
+		findings[i] = detect.PoisonedSample{ID: record.ID}
+		c := a.best[i]
+		threshold, ok := thresholds[c.kind]
+		if !ok {
+			threshold = 0.5
+		}
+		if c.score <= threshold {
+			continue
+		}
+		findings[i].IsPoisoned = true
+		findings[i].Type = c.kind
+		findings[i].Score = c.score
+		findings[i].Confidence = c.score
+		findings[i].Description = c.description
+		findings[i].Evidence = c.evidence
+		findings[i].ATLAS = detect.ATLASIDs(c.kind)
+	}
+	return &Analysis{Findings: findings}
+}
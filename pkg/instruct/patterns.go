@@ -0,0 +1,166 @@
+package instruct
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// pattern is a named pattern of injected or malicious text.
+type pattern struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// patterns compiles the name and expression pairs of list.
+func patterns(list ...string) []pattern {
+	result := make([]pattern, 0, len(list)/2)
+	for i := 0; i < len(list); i += 2 {
+		result = append(result, pattern{name: list[i], re: regexp.MustCompile(list[i+1])})
+	}
+	return result
+}
+
+// jailbreaks are the tell-tale phrases of jailbreak templates.
+var jailbreaks = patterns(
+	"instruction override", `(?i)\b(ignore|disregard|forget)\s+(all\s+)?(of\s+)?(the\s+|your\s+)?(previous|prior|above|earlier|preceding)\s+(instructions|prompts|rules|directions)`,
+	"DAN persona", `(?i)\bdo\s+anything\s+now\b|\bDAN\s+mode\b`,
+	"developer mode", `(?i)\bdeveloper\s+mode\s+(enabled|output|activated)`,
+	"restriction removal", `(?i)\b(pretend|act|imagine|behave)\b[^.!?\n]{0,40}\b(no|without)\s+(any\s+)?(restrictions|filters|limitations|guidelines|rules|censorship)`,
+	"ethics removal", `(?i)\b(no|without|free\s+of|free\s+from)\s+(any\s+)?(ethical|moral)\s+(guidelines|constraints|restrictions|principles|boundaries)`,
+	"persona lock", `(?i)\bstay\s+in\s+character\b`,
+	"jailbreak", `(?i)\bjailbr(oken|eak)\b`,
+	"unfiltered persona", `(?i)\b(unfiltered|uncensored)\s+(and\s+(unfiltered|uncensored)\s+)?(ai|assistant|model|chatbot)\b`,
+	"mode switch", `(?i)\b(opposite|evil|chaos|god)\s+mode\b`,
+)
+
+// overrides are the markers of text that poses as a system prompt.
+var overrides = patterns(
+	"ChatML system turn", `(?i)<\|im_start\|>\s*system`,
+	"Llama system block", `<<\s*/?SYS\s*>>`,
+	"special token", `(?i)<\|(system|endoftext|eot_id|start_header_id|end_header_id)\|>`,
+	"system heading", `(?i)#{2,}\s*system\b`,
+	"system prompt label", `(?im)^\s*system(\s+prompt)?\s*:`,
+	"new instructions", `(?i)\bnew\s+(system\s+)?instructions\s*:`,
+	"system prompt replacement", `(?i)\b(your|the)\s+(new\s+)?system\s+prompt\s+(is\s+now|is|has\s+changed)`,
+)
+
+// maliciousPatterns are the markers of harmful behavior in responses.
+var maliciousPatterns = patterns(
+	"recursive deletion", `(?i)\brm\s+-(rf|fr)\s+(/|~|\*)`,
+	"download piped into a shell", `(?i)\b(curl|wget)\b[^|\n]*\|\s*(sudo\s+)?(ba|z)?sh\b`,
+	"decoded payload piped into a shell", `(?i)\bbase64\s+(-d|--decode)\b[^|\n]*\|\s*(ba)?sh\b`,
+	"encoded PowerShell", `(?i)\bpowershell(\.exe)?\s+(-\w+\s+)*-e(nc|ncodedcommand)?\s+[A-Za-z0-9+/=]{16,}`,
+	"reverse shell", `(?i)\bnc\s+(-\w+\s+)*-e\s|/dev/tcp/`,
+	"credential exfiltration", `(?i)\b(send|post|upload|forward|exfiltrate)\w*\s+([\w']+\s+){0,3}(passwords?|credentials|api[_ -]?keys?|access\s+tokens?|session\s+cookies|private\s+keys?)\s+to\b`,
+	"raw IP address link", `(?i)\bhttps?://\d{1,3}(\.\d{1,3}){3}\b`,
+	"destructive SQL", `(?i)\bdrop\s+(table|database)\b`,
+	"obfuscated eval", `(?i)\beval\s*\(\s*(atob|base64_decode|unescape)\s*\(`,
+)
+
+// refusal matches responses refusing their instruction.
+var refusal = regexp.MustCompile(`(?i)^\W*(i\s+(can(no|')t|am\s+(not\s+able|unable)|won'?t|will\s+not|must\s+decline)|i'?m\s+(sorry|unable|not\s+able)|sorry)\b`)
+
+// snippetContext is the number of characters of context around a match in
+// a snippet.
+const snippetContext = 30
+
+// snippet quotes the match at [start, end) of text with some context,
+// with its white space collapsed and its hidden characters escaped.
+func snippet(text string, start, end int) string {
+	from, to := start, end
+	for n := 0; n < snippetContext && from > 0; n++ {
+		_, size := utf8.DecodeLastRuneInString(text[:from])
+		from -= size
+	}
+	for n := 0; n < snippetContext && to < len(text); n++ {
+		_, size := utf8.DecodeRuneInString(text[to:])
+		to += size
+	}
+	quoted := strings.Join(strings.Fields(text[from:to]), " ")
+	if from > 0 {
+		quoted = "…" + quoted
+	}
+	if to < len(text) {
+		quoted += "…"
+	}
+	return fmt.Sprintf("%q", quoted)
+}
+
+// hidden reports whether r is invisible or reorders text: zero-width
+// characters, bidirectional controls and Unicode tag characters, which
+// hide instructions from reviewers but not from tokenizers.
+func hidden(r rune) bool {
+	switch {
+	case r >= 0x200B && r <= 0x200F, r >= 0x2060 && r <= 0x2064, r == 0xFEFF:
+		return true
+	case r >= 0x202A && r <= 0x202E, r >= 0x2066 && r <= 0x2069:
+		return true
+	case r >= 0xE0000 && r <= 0xE007F:
+		return true
+	}
+	return false
+}
+
+// hiddenRun returns the number of hidden characters of text and the byte
+// offsets of the first.
+func hiddenRun(text string) (int, int, int) {
+	count, start, end := 0, -1, -1
+	for i, r := range text {
+		if hidden(r) {
+			if count == 0 {
+				start, end = i, i+utf8.RuneLen(r)
+			}
+			count++
+		}
+	}
+	return count, start, end
+}
+
+// injections flags records embedding jailbreak templates or system-prompt
+// overrides in their prompts, hiding characters in any field, or
+// responding with malicious behavior. Jailbreaks the response refuses
+// are the safety training they look like and score below the thresholds.
+func (a *analysis) injections() {
+	for i := range a.records {
+		record := &a.records[i]
+		refused := refusal.MatchString(record.Response)
+		for _, f := range record.fields() {
+			if n, start, end := hiddenRun(f.text); n > 0 {
+				a.add(i, detect.TypeDataPoison, math.Min(1, 0.85+0.01*float64(n)), "Hidden characters in record",
+					fmt.Sprintf("%s holds %d invisible or bidirectional control characters: %s", f.name, n, snippet(f.text, start, end)))
+			}
+			for _, p := range overrides {
+				if loc := p.re.FindStringIndex(f.text); loc != nil && f.name != "system" {
+					a.add(i, detect.TypeDataPoison, 0.9, "System-prompt override embedded in record",
+						fmt.Sprintf("%s holds a system-prompt override (%s): %s", f.name, p.name, snippet(f.text, loc[0], loc[1])))
+				}
+			}
+			if f.name == "response" {
+				for _, p := range maliciousPatterns {
+					if loc := p.re.FindStringIndex(f.text); loc != nil {
+						a.malicious[i] = p.name
+						a.add(i, detect.TypeDataPoison, 0.7, "Malicious behavior in response",
+							fmt.Sprintf("response holds malicious behavior (%s): %s", p.name, snippet(f.text, loc[0], loc[1])))
+						break
+					}
+				}
+				continue
+			}
+			for _, p := range jailbreaks {
+				if loc := p.re.FindStringIndex(f.text); loc != nil {
+					score, note := 0.85, ""
+					if refused {
+						score, note = 0.4, " (refused)"
+					}
+					a.add(i, detect.TypeDataPoison, score, "Jailbreak template in record",
+						fmt.Sprintf("%s holds jailbreak phrasing (%s)%s: %s", f.name, p.name, note, snippet(f.text, loc[0], loc[1])))
+				}
+			}
+		}
+	}
+}
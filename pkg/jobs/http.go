@@ -0,0 +1,119 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Handler serves the job API of a queue:
+//
+//	GET    /v1/jobs              list jobs (?state=queued|running|...)
+//	POST   /v1/jobs              submit {"dataset": ..., "priority": N, "thresholds": {...}}
+//	GET    /v1/jobs/{id}         fetch a job, its progress and summary
+//	DELETE /v1/jobs/{id}         cancel a job
+//	GET    /v1/jobs/{id}/result  fetch the detection result of a succeeded job
+type Handler struct {
+	Queue *Queue
+}
+
+// errorResponse is the body of error responses.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || len(parts) > 4 || parts[0] != "v1" || parts[1] != "jobs" {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no such endpoint %s", r.URL.Path))
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, h.Queue.List(State(r.URL.Query().Get("state"))))
+	case len(parts) == 2 && r.Method == http.MethodPost:
+		h.submit(w, r)
+	case len(parts) == 3 && r.Method == http.MethodGet:
+		job, ok := h.Queue.Get(parts[2])
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Errorf("no job %q", parts[2]))
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	case len(parts) == 3 && r.Method == http.MethodDelete:
+		job, err := h.Queue.Cancel(parts[2])
+		switch {
+		case errors.Is(err, ErrNotFound):
+			writeError(w, http.StatusNotFound, fmt.Errorf("no job %q", parts[2]))
+		case err != nil:
+			writeError(w, http.StatusConflict, err)
+		default:
+			writeJSON(w, http.StatusOK, job)
+		}
+	case len(parts) == 4 && parts[3] == "result" && r.Method == http.MethodGet:
+		h.result(w, parts[2])
+	case len(parts) == 4 && parts[3] != "result":
+		writeError(w, http.StatusNotFound, fmt.Errorf("no such endpoint %s", r.URL.Path))
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+}
+
+func (h Handler) submit(w http.ResponseWriter, r *http.Request) {
+	var spec Spec
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = fmt.Errorf("request body required")
+		}
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	spec.Source = "api"
+
+	job, err := h.Queue.Submit(spec)
+	switch {
+	case errors.Is(err, ErrQueueFull):
+		w.Header().Set("Retry-After", "60")
+		writeError(w, http.StatusServiceUnavailable, err)
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err)
+	default:
+		w.Header().Set("Location", "/v1/jobs/"+job.ID)
+		writeJSON(w, http.StatusAccepted, job)
+	}
+}
+
+func (h Handler) result(w http.ResponseWriter, id string) {
+	result, err := h.Queue.Result(id)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, fmt.Errorf("no job %q", id))
+	case err != nil:
+		writeError(w, http.StatusConflict, err)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		detect.WriteResult(w, result)
+	}
+}
+
+// writeJSON writes v as an indented JSON response.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+// writeError writes a JSON error response.
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,522 @@
+// Package jobs queues scan jobs by priority, runs them with a bounded
+// number of workers and persists their state so queued and interrupted
+// jobs survive restarts.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/internal/id"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// State is the state of a job.
+type State string
+
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+	StateCanceled  State = "canceled"
+)
+
+// Done reports whether s is a final state.
+func (s State) Done() bool {
+	return s == StateSucceeded || s == StateFailed || s == StateCanceled
+}
+
+// indexFile and resultsDir are the job index and the directory of results
+// within the state directory.
+const (
+	indexFile  = "jobs.json"
+	resultsDir = "results"
+)
+
+// ErrQueueFull is returned by Submit when the queue holds the maximum
+// number of queued jobs.
+var ErrQueueFull = errors.New("job queue is full")
+
+// ErrNotFound is returned for unknown job IDs.
+var ErrNotFound = errors.New("no such job")
+
+// Spec describes the scan a job runs.
+type Spec struct {
+	// Dataset references the dataset to scan; Options.Run interprets it.
+	Dataset string `json:"dataset"`
+	// Priority orders queued jobs; higher priorities run first and jobs of
+	// equal priority run in submission order.
+	Priority int `json:"priority,omitempty"`
+	// Thresholds overrides detection thresholds for this job.
+	Thresholds map[string]float64 `json:"thresholds,omitempty"`
+	// Source records how the job was submitted, such as "api" or "watch".
+	Source string `json:"source,omitempty"`
+	// Project is the server project the job belongs to, if any.
+	Project string `json:"project,omitempty"`
+}
+
+// Progress reports how far a running job has got.
+type Progress struct {
+	Processed int `json:"processed"`
+	Total     int `json:"total"`
+}
+
+// Summary summarizes the result of a finished job.
+type Summary struct {
+	IsPoisoned    bool    `json:"is_poisoned"`
+	SampleCount   int     `json:"sample_count"`
+	PoisonedCount int     `json:"poisoned_count"`
+	RiskScore     float64 `json:"risk_score"`
+}
+
+// Job is a queued, running or finished scan.
+type Job struct {
+	ID string `json:"id"`
+	Spec
+	State      State      `json:"state"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Attempts counts the runs started, including runs interrupted by a
+	// restart.
+	Attempts int       `json:"attempts"`
+	Progress *Progress `json:"progress,omitempty"`
+	Summary  *Summary  `json:"summary,omitempty"`
+	Error    string    `json:"error,omitempty"`
+
+	// Seq orders jobs of equal priority.
+	Seq int64 `json:"seq"`
+
+	// canceled is set when a running job is canceled; its result is
+	// discarded when the run ends.
+	canceled bool
+}
+
+// snapshot returns a copy of the job that does not share its progress.
+func (j *Job) snapshot() Job {
+	c := *j
+	if j.Progress != nil {
+		progress := *j.Progress
+		c.Progress = &progress
+	}
+	return c
+}
+
+// RunFunc runs a job. It reports progress through progress and returns
+// the detection result.
+type RunFunc func(ctx context.Context, job Job, progress detect.ProgressFunc) (*detect.DetectionResult, error)
+
+// Options configures a Queue.
+type Options struct {
+	// Dir is the state directory. When empty, jobs and results are only
+	// kept in memory.
+	Dir string
+	// Workers is the number of jobs run concurrently (default 1).
+	Workers int
+	// MaxQueued caps the number of queued jobs; 0 means no limit.
+	MaxQueued int
+	// Run runs jobs.
+	Run RunFunc
+	// Finished, if set, is called after a run ends and its outcome is
+	// recorded. The result is nil unless the job succeeded.
+	Finished func(job Job, result *detect.DetectionResult)
+	// Logger receives structured diagnostics. It defaults to
+	// slog.Default.
+	Logger *slog.Logger
+}
+
+// Queue is a persistent priority queue of scan jobs.
+type Queue struct {
+	opts Options
+
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	results map[string]*detect.DetectionResult
+	seq     int64
+	wake    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Open opens the queue state in opts.Dir, creating it if needed. Jobs that
+// were running when the previous process stopped are queued again.
+func Open(opts Options) (*Queue, error) {
+	if opts.Run == nil {
+		return nil, errors.New("jobs: no run function")
+	}
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+
+	q := &Queue{
+		opts:    opts,
+		jobs:    make(map[string]*Job),
+		results: make(map[string]*detect.DetectionResult),
+		wake:    make(chan struct{}, 1),
+	}
+	if opts.Dir == "" {
+		return q, nil
+	}
+
+	if err := os.MkdirAll(filepath.Join(opts.Dir, resultsDir), 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(opts.Dir, indexFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return q, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*Job
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	requeued := 0
+	for _, job := range jobs {
+		if job.State == StateRunning {
+			job.State = StateQueued
+			job.Progress = nil
+			requeued++
+		}
+		if job.Seq > q.seq {
+			q.seq = job.Seq
+		}
+		q.jobs[job.ID] = job
+	}
+	if requeued > 0 {
+		opts.Logger.Info("requeued interrupted jobs", "jobs", requeued)
+		if err := q.save(); err != nil {
+			return nil, err
+		}
+	}
+
+	return q, nil
+}
+
+// Start starts the workers. They stop taking jobs when ctx is done; Wait
+// waits for running jobs to finish.
+func (q *Queue) Start(ctx context.Context) {
+	for i := 0; i < q.opts.Workers; i++ {
+		q.wg.Add(1)
+		go q.work(ctx)
+	}
+}
+
+// Wait waits for the workers to stop.
+func (q *Queue) Wait() {
+	q.wg.Wait()
+}
+
+// Submit queues a job.
+func (q *Queue) Submit(spec Spec) (Job, error) {
+	if spec.Dataset == "" {
+		return Job{}, errors.New("dataset required")
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.opts.MaxQueued > 0 && q.countLocked(StateQueued) >= q.opts.MaxQueued {
+		return Job{}, ErrQueueFull
+	}
+
+	q.seq++
+	job := &Job{
+		ID:        id.New(),
+		Spec:      spec,
+		State:     StateQueued,
+		CreatedAt: time.Now().UTC(),
+		Seq:       q.seq,
+	}
+	q.jobs[job.ID] = job
+	if err := q.saveLocked(); err != nil {
+		delete(q.jobs, job.ID)
+		return Job{}, err
+	}
+
+	q.opts.Logger.Info("job queued", "job_id", job.ID, "dataset", spec.Dataset, "priority", spec.Priority, "source", spec.Source)
+	q.signal()
+	return job.snapshot(), nil
+}
+
+// Get returns the job with the given ID.
+func (q *Queue) Get(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return job.snapshot(), true
+}
+
+// List returns the jobs in state, or all jobs when state is empty, in
+// submission order.
+func (q *Queue) List(state State) []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		if state == "" || job.State == state {
+			jobs = append(jobs, job.snapshot())
+		}
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Seq < jobs[j].Seq })
+	return jobs
+}
+
+// Cancel cancels a queued or running job. A running scan is not
+// interrupted, but its result is discarded.
+func (q *Queue) Cancel(id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	switch job.State {
+	case StateQueued:
+		job.State = StateCanceled
+		job.FinishedAt = now()
+	case StateRunning:
+		job.canceled = true
+	default:
+		return job.snapshot(), fmt.Errorf("job %s has finished (%s)", id, job.State)
+	}
+	if err := q.saveLocked(); err != nil {
+		return Job{}, err
+	}
+
+	q.opts.Logger.Info("job canceled", "job_id", id)
+	return job.snapshot(), nil
+}
+
+// Result returns the detection result of a succeeded job.
+func (q *Queue) Result(id string) (*detect.DetectionResult, error) {
+	q.mu.Lock()
+	job, ok := q.jobs[id]
+	var state State
+	if ok {
+		state = job.State
+	}
+	result := q.results[id]
+	q.mu.Unlock()
+
+	switch {
+	case !ok:
+		return nil, ErrNotFound
+	case state != StateSucceeded:
+		return nil, fmt.Errorf("job %s is %s", id, state)
+	case result != nil:
+		return result, nil
+	case q.opts.Dir == "":
+		return nil, fmt.Errorf("job %s has no stored result", id)
+	}
+	return detect.LoadResult(q.resultPath(id))
+}
+
+// work runs queued jobs until ctx is done.
+func (q *Queue) work(ctx context.Context) {
+	defer q.wg.Done()
+
+	for {
+		job, ok := q.next()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.wake:
+				continue
+			}
+		}
+		if ctx.Err() != nil {
+			q.requeue(job.ID)
+			return
+		}
+		// Let another idle worker pick up further queued jobs.
+		q.signal()
+		q.run(ctx, job)
+	}
+}
+
+// next marks the highest-priority queued job as running and returns it.
+func (q *Queue) next() (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var best *Job
+	for _, job := range q.jobs {
+		if job.State != StateQueued {
+			continue
+		}
+		if best == nil || job.Priority > best.Priority || (job.Priority == best.Priority && job.Seq < best.Seq) {
+			best = job
+		}
+	}
+	if best == nil {
+		return Job{}, false
+	}
+
+	best.State = StateRunning
+	best.StartedAt = now()
+	best.Attempts++
+	best.Progress = &Progress{}
+	if err := q.saveLocked(); err != nil {
+		q.opts.Logger.Error("save job state", "error", err)
+	}
+	return best.snapshot(), true
+}
+
+// requeue returns a job taken by a stopping worker to the queue.
+func (q *Queue) requeue(id string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if job, ok := q.jobs[id]; ok && job.State == StateRunning {
+		job.State = StateQueued
+		job.Attempts--
+		job.Progress = nil
+		if err := q.saveLocked(); err != nil {
+			q.opts.Logger.Error("save job state", "error", err)
+		}
+	}
+}
+
+// run runs one job and records its outcome.
+func (q *Queue) run(ctx context.Context, job Job) {
+	log := q.opts.Logger.With("job_id", job.ID, "dataset", job.Dataset)
+	log.Info("job started", "attempt", job.Attempts)
+
+	progress := func(processed, total int) {
+		q.mu.Lock()
+		if j, ok := q.jobs[job.ID]; ok && j.Progress != nil {
+			j.Progress.Processed, j.Progress.Total = processed, total
+		}
+		q.mu.Unlock()
+	}
+
+	result, err := q.opts.Run(ctx, job, progress)
+	if err == nil && q.opts.Dir != "" {
+		err = detect.SaveResult(q.resultPath(job.ID), result)
+	}
+
+	q.mu.Lock()
+	j, ok := q.jobs[job.ID]
+	if !ok {
+		q.mu.Unlock()
+		return
+	}
+	j.FinishedAt = now()
+	switch {
+	case j.canceled:
+		j.State = StateCanceled
+		j.canceled = false
+		log.Info("job canceled while running; result discarded")
+	case err != nil:
+		j.State = StateFailed
+		j.Error = err.Error()
+		log.Warn("job failed", "error", err)
+	default:
+		j.State = StateSucceeded
+		j.Summary = &Summary{
+			IsPoisoned:    result.IsPoisoned,
+			SampleCount:   result.SampleCount,
+			PoisonedCount: result.PoisonedCount,
+			RiskScore:     result.RiskScore,
+		}
+		if q.opts.Dir == "" {
+			q.results[job.ID] = result
+		}
+		log.Info("job succeeded", "samples", result.SampleCount, "poisoned", result.PoisonedCount, "risk_score", result.RiskScore)
+	}
+	if err := q.saveLocked(); err != nil {
+		log.Error("save job state", "error", err)
+	}
+	finished := j.snapshot()
+	q.mu.Unlock()
+
+	if q.opts.Finished != nil {
+		if finished.State != StateSucceeded {
+			result = nil
+		}
+		q.opts.Finished(finished, result)
+	}
+}
+
+// signal wakes an idle worker.
+func (q *Queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// countLocked counts jobs in state.
+func (q *Queue) countLocked(state State) int {
+	n := 0
+	for _, job := range q.jobs {
+		if job.State == state {
+			n++
+		}
+	}
+	return n
+}
+
+// resultPath returns the path of a job's stored result.
+func (q *Queue) resultPath(id string) string {
+	return filepath.Join(q.opts.Dir, resultsDir, id+".json")
+}
+
+// save writes the job index.
+func (q *Queue) save() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.saveLocked()
+}
+
+// saveLocked writes the job index; q.mu must be held.
+func (q *Queue) saveLocked() error {
+	if q.opts.Dir == "" {
+		return nil
+	}
+
+	jobs := make([]*Job, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Seq < jobs[j].Seq })
+
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(q.opts.Dir, indexFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// now returns the current UTC time.
+func now() *time.Time {
+	t := time.Now().UTC()
+	return &t
+}
@@ -0,0 +1,108 @@
+package jobs
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestQueueRunsByPriorityAndPersists(t *testing.T) {
+	dir := t.TempDir()
+
+	var mu sync.Mutex
+	var order []string
+	run := func(ctx context.Context, job Job, progress detect.ProgressFunc) (*detect.DetectionResult, error) {
+		mu.Lock()
+		order = append(order, job.Dataset)
+		mu.Unlock()
+		progress(1, 1)
+		return &detect.DetectionResult{SampleCount: 1}, nil
+	}
+
+	queue, err := Open(Options{Dir: dir, Run: run, MaxQueued: 3, Logger: discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, spec := range []Spec{{Dataset: "low"}, {Dataset: "high", Priority: 5}, {Dataset: "low2"}} {
+		if _, err := queue.Submit(spec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := queue.Submit(Spec{Dataset: "overflow"}); err != ErrQueueFull {
+		t.Errorf("Submit beyond MaxQueued = %v, want ErrQueueFull", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	queue.Start(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(queue.List(StateSucceeded)) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("jobs did not finish: %+v", queue.List(""))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	queue.Wait()
+
+	if len(order) != 3 || order[0] != "high" || order[1] != "low" || order[2] != "low2" {
+		t.Errorf("run order = %v, want high, low, low2", order)
+	}
+
+	reopened, err := Open(Options{Dir: dir, Run: run, Logger: discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := reopened.List(StateSucceeded)
+	if len(jobs) != 3 {
+		t.Fatalf("reopened queue has %d succeeded jobs, want 3", len(jobs))
+	}
+	if result, err := reopened.Result(jobs[0].ID); err != nil || result.SampleCount != 1 {
+		t.Errorf("Result = %+v, %v", result, err)
+	}
+}
+
+func TestOpenRequeuesInterruptedJobs(t *testing.T) {
+	dir := t.TempDir()
+	block := make(chan struct{})
+	run := func(ctx context.Context, job Job, progress detect.ProgressFunc) (*detect.DetectionResult, error) {
+		<-block
+		return &detect.DetectionResult{}, nil
+	}
+
+	queue, err := Open(Options{Dir: dir, Run: run, Logger: discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := queue.Submit(Spec{Dataset: "d.csv"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	queue.Start(ctx)
+	for {
+		if j, _ := queue.Get(job.ID); j.State == StateRunning {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Simulate a crash by opening the state while the job is running.
+	reopened, err := Open(Options{Dir: dir, Run: run, Logger: discard})
+	close(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j, ok := reopened.Get(job.ID); !ok || j.State != StateQueued || j.Attempts != 1 {
+		t.Errorf("reopened job = %+v, want queued after 1 attempt", j)
+	}
+	cancel()
+	queue.Wait()
+}
@@ -0,0 +1,217 @@
+package multimodal
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+const (
+	// minSamples is the fewest samples the checks score.
+	minSamples = 10
+	// minSpread is the least robust standard deviation of cross-modal
+	// agreement, so near-identical agreements are not all outliers.
+	minSpread = 0.05
+	// minSupport and maxShare bound the number of captions of a candidate
+	// trigger phrase: rare, but repeated.
+	minSupport = 5
+	maxShare   = 0.1
+)
+
+// agreement returns how well the image and text of each sample of set
+// agree, and what is measured. Modalities of the same width are taken to
+// share an embedding space, as CLIP's do, and compared by cosine
+// similarity. Otherwise the similarities of the sample's image to the
+// other images are correlated with those of its text to the other texts:
+// a caption describing its image resembles the captions of similar images.
+func agreement(set *Set) ([]float64, string) {
+	n := len(set.IDs)
+	values := make([]float64, n)
+	if len(set.Image[0]) == len(set.Text[0]) {
+		for i := range values {
+			values[i] = cosine(set.Image[i], set.Text[i])
+		}
+		return values, "image-caption similarity"
+	}
+
+	images, texts := make([]float64, n), make([]float64, n)
+	for i := range values {
+		for j := range set.IDs {
+			images[j] = cosine(set.Image[i], set.Image[j])
+			texts[j] = cosine(set.Text[i], set.Text[j])
+		}
+		// The sample's similarity to itself is left out.
+		images[i], texts[i] = images[(i+1)%n], texts[(i+1)%n]
+		values[i] = correlation(images, texts)
+	}
+	return values, "correlation of image and caption similarities"
+}
+
+// correlation returns the Pearson correlation of x and y, or 0 if either
+// is constant.
+func correlation(x, y []float64) float64 {
+	var mx, my float64
+	for i := range x {
+		mx += x[i]
+		my += y[i]
+	}
+	mx /= float64(len(x))
+	my /= float64(len(y))
+	var sxy, sxx, syy float64
+	for i := range x {
+		sxy += (x[i] - mx) * (y[i] - my)
+		sxx += (x[i] - mx) * (x[i] - mx)
+		syy += (y[i] - my) * (y[i] - my)
+	}
+	if sxx == 0 || syy == 0 {
+		return 0
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
+
+// mismatches scores samples whose modalities agree far less than those of
+// the other samples: z robust standard deviations below the median agreement
+// scores z/6, flagging beyond three.
+func mismatches(set *Set) scores {
+	result := make(scores)
+	if len(set.IDs) < minSamples {
+		return result
+	}
+	values, measure := agreement(set)
+	median, spread := robust(values)
+	spread = max(spread, minSpread)
+	for i, v := range values {
+		z := (median - v) / spread
+		if z <= 0 {
+			continue
+		}
+		result.add(set.IDs[i], detect.ExternalScore{
+			Score:    math.Min(1, z/6),
+			Evidence: fmt.Sprintf("%s %.2f, typical %.2f", measure, v, median),
+		})
+	}
+	return result
+}
+
+// phrases returns the distinct words and word pairs of caption, in
+// lower case.
+func phrases(caption string) []string {
+	words := strings.FieldsFunc(strings.ToLower(caption), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	seen := make(map[string]bool)
+	var result []string
+	for i, w := range words {
+		for _, p := range []string{w, strings.Join(words[max(0, i-1):i+1], " ")} {
+			if !seen[p] {
+				seen[p] = true
+				result = append(result, p)
+			}
+		}
+	}
+	return result
+}
+
+// pinned is an image feature the images of a phrase share.
+type pinned struct {
+	feature int
+	value   float64
+}
+
+// triggers scores samples whose caption holds a rare phrase whose images
+// all share a pattern: features within a quarter of a standard deviation
+// of each other and two or more standard deviations from the mean of all
+// images, as a trigger patch stamps them.
+func triggers(set *Set) scores {
+	result := make(scores)
+	n := len(set.IDs)
+	if n < minSamples || len(set.Captions) != n {
+		return result
+	}
+
+	width := len(set.Image[0])
+	means, stdDevs := make([]float64, width), make([]float64, width)
+	for f := 0; f < width; f++ {
+		means[f], stdDevs[f] = meanStdDev(set.Image, nil, f)
+	}
+
+	members := make(map[string][]int)
+	for i, caption := range set.Captions {
+		for _, p := range phrases(caption) {
+			members[p] = append(members[p], i)
+		}
+	}
+	candidates := make([]string, 0, len(members))
+	for p, group := range members {
+		if len(group) >= minSupport && float64(len(group)) <= math.Max(minSupport, maxShare*float64(n)) {
+			candidates = append(candidates, p)
+		}
+	}
+	// Phrases with the most captions explain the trigger first.
+	sort.Slice(candidates, func(i, j int) bool {
+		if a, b := len(members[candidates[i]]), len(members[candidates[j]]); a != b {
+			return a > b
+		}
+		return candidates[i] < candidates[j]
+	})
+
+	for _, p := range candidates {
+		group := members[p]
+		var pins []pinned
+		for f := 0; f < width; f++ {
+			if stdDevs[f] == 0 {
+				continue
+			}
+			mean, stdDev := meanStdDev(set.Image, group, f)
+			if stdDev <= stdDevs[f]/4 && math.Abs(mean-means[f]) >= 2*stdDevs[f] {
+				pins = append(pins, pinned{feature: f, value: mean})
+			}
+		}
+		if len(pins) == 0 {
+			continue
+		}
+		var shown []string
+		for _, pin := range pins[:min(3, len(pins))] {
+			shown = append(shown, fmt.Sprintf("%s=%.2f", set.ImageNames[pin.feature], pin.value))
+		}
+		evidence := fmt.Sprintf("caption phrase %q in %d captions whose images share %d pinned features (%s)",
+			p, len(group), len(pins), strings.Join(shown, ", "))
+		for _, i := range group {
+			result.add(set.IDs[i], detect.ExternalScore{Score: math.Min(1, 0.8+0.05*float64(len(pins))), Evidence: evidence})
+		}
+	}
+	return result
+}
+
+// meanStdDev returns the mean and standard deviation of the finite values
+// of feature f of the vectors at indices, or of all vectors when indices
+// is nil.
+func meanStdDev(vectors [][]float64, indices []int, f int) (float64, float64) {
+	var sum, sumSquares float64
+	count := 0
+	add := func(i int) {
+		if v := vectors[i][f]; finite(v) {
+			sum += v
+			sumSquares += v * v
+			count++
+		}
+	}
+	if indices == nil {
+		for i := range vectors {
+			add(i)
+		}
+	} else {
+		for _, i := range indices {
+			add(i)
+		}
+	}
+	if count == 0 {
+		return 0, 0
+	}
+	mean := sum / float64(count)
+	return mean, math.Sqrt(math.Max(0, sumSquares/float64(count)-mean*mean))
+}
@@ -0,0 +1,217 @@
+// Package multimodal checks paired multi-modal samples, such as the image
+// and caption pairs CLIP-style models are trained on, for poisoning that
+// only shows across modalities: captions that do not describe their image,
+// and trigger phrases paired with trigger patches.
+//
+// Each sample holds the features of both modalities, told apart by column
+// patterns, and optionally its caption text.
+package multimodal
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/stats"
+)
+
+// Columns selects the modalities of the samples of a dataset.
+type Columns struct {
+	// Image and Text are comma-separated feature names or glob patterns,
+	// such as "img_*", selecting the features of each modality.
+	Image string
+	Text  string
+	// Caption names the column holding the caption text, if any. It is not
+	// read as a feature.
+	Caption string
+}
+
+// Enabled reports whether c selects modalities.
+func (c Columns) Enabled() bool {
+	return c.Image != "" || c.Text != ""
+}
+
+// Set holds the modalities of the samples of a dataset, in order.
+type Set struct {
+	IDs      []string
+	Image    [][]float64
+	Text     [][]float64
+	Captions []string
+	// ImageNames names the image features.
+	ImageNames []string
+}
+
+// NewSet splits the samples of data into their modalities.
+func NewSet(data *dataset.Dataset, cols Columns) (*Set, error) {
+	if cols.Image == "" || cols.Text == "" {
+		return nil, fmt.Errorf("multi-modal samples need both image and text columns")
+	}
+	image, err := match(data.Features, cols.Image)
+	if err != nil {
+		return nil, fmt.Errorf("image columns: %w", err)
+	}
+	text, err := match(data.Features, cols.Text)
+	if err != nil {
+		return nil, fmt.Errorf("text columns: %w", err)
+	}
+	caption := -1
+	if cols.Caption != "" {
+		for i, name := range data.Header {
+			if strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(cols.Caption)) {
+				caption = i
+			}
+		}
+		if caption < 0 {
+			return nil, fmt.Errorf("no caption column %q", cols.Caption)
+		}
+	}
+
+	set := &Set{}
+	for _, f := range image {
+		set.ImageNames = append(set.ImageNames, data.Features[f])
+	}
+	for i, sample := range data.Samples {
+		set.IDs = append(set.IDs, sample.ID)
+		set.Image = append(set.Image, pick(sample.Features, image))
+		set.Text = append(set.Text, pick(sample.Features, text))
+		if caption >= 0 && i < len(data.Records) {
+			set.Captions = append(set.Captions, data.Records[i][caption])
+		}
+	}
+	return set, nil
+}
+
+// match returns the indices of the features of names matching the
+// comma-separated names or patterns of list.
+func match(names []string, list string) ([]int, error) {
+	var indices []int
+	for _, pattern := range strings.Split(list, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern == "" {
+			continue
+		}
+		found := false
+		for i, name := range names {
+			if ok, err := path.Match(pattern, name); err != nil {
+				return nil, err
+			} else if ok || strings.EqualFold(pattern, name) {
+				indices = append(indices, i)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no feature matches %q", pattern)
+		}
+	}
+	return indices, nil
+}
+
+// pick returns the features of x at indices.
+func pick(x []float64, indices []int) []float64 {
+	v := make([]float64, len(indices))
+	for i, f := range indices {
+		if f < len(x) {
+			v[i] = x[f]
+		}
+	}
+	return v
+}
+
+// cosine returns the cosine similarity of a and b, ignoring
+// non-finite features, or 0 if either is zero.
+func cosine(a, b []float64) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		if i >= len(b) || !finite(a[i]) || !finite(b[i]) {
+			continue
+		}
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(na*nb)
+}
+
+// finite reports whether v is neither NaN nor infinite.
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// robust returns the median and the MAD, scaled to a standard deviation,
+// of values.
+func robust(values []float64) (float64, float64) {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	median := stats.Quantile(sorted, 0.5)
+	deviations := make([]float64, len(sorted))
+	for i, v := range sorted {
+		deviations[i] = math.Abs(v - median)
+	}
+	sort.Float64s(deviations)
+	return median, 1.4826 * stats.Quantile(deviations, 0.5)
+}
+
+// scores maps sample IDs to their scores by a check. Duplicate IDs take
+// the highest score.
+type scores map[string]detect.ExternalScore
+
+func (s scores) add(id string, score detect.ExternalScore) {
+	if score.Score > s[id].Score {
+		s[id] = score
+	}
+}
+
+// check is a detect.ExternalCheck serving scores computed over the whole
+// dataset beforehand.
+type check struct {
+	info   detect.CheckInfo
+	scores scores
+}
+
+func (c *check) Info() detect.CheckInfo { return c.info }
+
+func (c *check) Threshold() float64 { return 0.5 }
+
+func (c *check) Score(samples []detect.Sample) []detect.ExternalScore {
+	result := make([]detect.ExternalScore, len(samples))
+	for i, sample := range samples {
+		result[i] = c.scores[sample.ID]
+	}
+	return result
+}
+
+// Checks returns the cross-modal checks of set: the caption mismatch check
+// and, when the set has captions, the trigger pairing check. Both score
+// all the samples of set up front, so datasets must be loaded rather than
+// streamed.
+func Checks(set *Set) []detect.ExternalCheck {
+	checks := []detect.ExternalCheck{&check{
+		info: detect.CheckInfo{
+			Name:        "caption_mismatch",
+			Type:        detect.TypeLabelFlip,
+			Description: "Caption does not match its image",
+			Method:      "Cross-modal similarity outliers (median and MAD)",
+			DataTypes:   []string{"multimodal"},
+		},
+		scores: mismatches(set),
+	}}
+	if len(set.Captions) > 0 {
+		checks = append(checks, &check{
+			info: detect.CheckInfo{
+				Name:        "cross_modal_trigger",
+				Type:        detect.TypeBackdoor,
+				Description: "Trigger phrase paired with a trigger pattern",
+				Method:      "Caption n-grams whose images share pinned features",
+				DataTypes:   []string{"multimodal"},
+			},
+			scores: triggers(set),
+		})
+	}
+	return checks
+}
@@ -0,0 +1,135 @@
+package multimodal
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// testDataset returns 200 image and caption pairs embedded in a shared
+// space. The captions of s0 to s4 describe other images, and s10 to s17
+// pair the phrase "zq" with a patch setting img_6 and img_7 to 5.
+func testDataset(t *testing.T) *dataset.Dataset {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+	words := strings.Fields("a dog cat on the grass red blue car street house tree sky beach boat man woman child ball park")
+
+	var b strings.Builder
+	b.WriteString("id,caption,label")
+	for _, m := range []string{"img", "txt"} {
+		for f := 0; f < 8; f++ {
+			fmt.Fprintf(&b, ",%s_%d", m, f)
+		}
+	}
+	b.WriteString("\n")
+	for i := 0; i < 200; i++ {
+		var caption []string
+		for w := 0; w < 4; w++ {
+			caption = append(caption, words[rng.Intn(len(words))])
+		}
+		concept := make([]float64, 8)
+		for f := range concept {
+			concept[f] = rng.NormFloat64()
+		}
+		image, text := make([]float64, 8), make([]float64, 8)
+		for f := range concept {
+			image[f] = concept[f] + 0.1*rng.NormFloat64()
+			text[f] = concept[f] + 0.1*rng.NormFloat64()
+			if i < 5 {
+				text[f] = rng.NormFloat64()
+			}
+		}
+		if i >= 10 && i < 18 {
+			caption = append(caption, "zq")
+			image[6], image[7] = 5, 5
+		}
+		fmt.Fprintf(&b, "s%d,%s,%d", i, strings.Join(caption, " "), i%2)
+		for _, v := range append(image, text...) {
+			fmt.Fprintf(&b, ",%.4f", v)
+		}
+		b.WriteString("\n")
+	}
+
+	data, err := dataset.ReadCSVColumns(strings.NewReader(b.String()), dataset.Columns{Ignore: []string{"caption"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestChecks(t *testing.T) {
+	data := testDataset(t)
+	set, err := NewSet(data, Columns{Image: "img_*", Text: "txt_*", Caption: "caption"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := Checks(set)
+	if len(checks) != 2 {
+		t.Fatalf("%d checks, want 2", len(checks))
+	}
+
+	mismatched := checks[0].Score(data.Samples)
+	for i, score := range mismatched {
+		if want := i < 5; (score.Score > 0.5) != want && (i < 10 || i >= 18) {
+			t.Errorf("caption_mismatch of s%d: %+v, want flagged %v", i, score, want)
+		}
+	}
+
+	triggered := checks[1].Score(data.Samples)
+	for i, score := range triggered {
+		want := i >= 10 && i < 18
+		if (score.Score > 0.5) != want {
+			t.Errorf("cross_modal_trigger of s%d: %+v, want flagged %v", i, score, want)
+		}
+		if want && !strings.Contains(score.Evidence, `"zq"`) {
+			t.Errorf("evidence of s%d = %q", i, score.Evidence)
+		}
+	}
+
+	d := detect.NewDetector()
+	for _, c := range checks {
+		if err := d.AddCheck(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNearestNeighborAgreement(t *testing.T) {
+	data := testDataset(t)
+	set, err := NewSet(data, Columns{Image: "img_*", Text: "txt_*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Project the texts into a wider space of their own.
+	rng := rand.New(rand.NewSource(2))
+	projection := make([][]float64, 12)
+	for r := range projection {
+		projection[r] = make([]float64, 8)
+		for c := range projection[r] {
+			projection[r][c] = rng.NormFloat64()
+		}
+	}
+	for i, text := range set.Text {
+		projected := make([]float64, len(projection))
+		for r, row := range projection {
+			for c, w := range row {
+				projected[r] += w * text[c]
+			}
+		}
+		set.Text[i] = projected
+	}
+	scores := mismatches(set)
+	for i := 0; i < 200; i++ {
+		s := scores[fmt.Sprintf("s%d", i)]
+		if want := i < 5; (s.Score > 0.5) != want && (i < 10 || i >= 18) {
+			t.Errorf("s%d: %+v, want flagged %v", i, s, want)
+		}
+	}
+	if _, err := NewSet(data, Columns{Image: "img_*", Text: "nope_*"}); err == nil {
+		t.Error("NewSet with unmatched text columns succeeded")
+	}
+}
@@ -0,0 +1,494 @@
+// Package plugin runs detectors and defenses shipped as separate programs,
+// so third parties can extend modelpoison without recompiling it. A plugin
+// is an executable that modelpoison starts as a subprocess when a scan
+// begins and talks to over the plugin's standard input and output, one
+// JSON message per line; the plugin's standard error is passed through for
+// its logs. Plugins are discovered by listing the executables of a
+// directory.
+//
+// Each request is an object with a "method": "describe" asks for the
+// plugin's Info, "detect" asks the named detector for a Score per sample
+// and "defend" asks the named defense for the indices of the samples to
+// remove. The plugin answers each request with one Response, in order.
+// Plugins written in Go implement a Handler and call Serve; plugins in any
+// other language follow the same exchange:
+//
+//	-> {"method":"describe"}
+//	<- {"info":{"name":"acme","protocol":1,"detectors":[{"name":"acme-knn","threshold":0.8}]}}
+//	-> {"method":"detect","name":"acme-knn","samples":[{"id":"s1","label":0,"features":[0.1,null]}]}
+//	<- {"scores":[{"score":0.93,"evidence":"3 nearest neighbours disagree"}]}
+//
+// Non-finite features are sent as null. Plugins are started with the
+// environment variable MODELPOISON_PLUGIN set to the protocol version, so
+// they can refuse to run when started by hand.
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hallucinaut/modelpoison/pkg/defend"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Protocol is the version of the plugin protocol.
+const Protocol = 1
+
+// EnvProtocol is the environment variable set to Protocol in the
+// environment of plugins.
+const EnvProtocol = "MODELPOISON_PLUGIN"
+
+// Info describes a plugin: its name and version, the protocol version it
+// speaks and the detectors and defenses it provides.
+type Info struct {
+	Name      string         `json:"name"`
+	Version   string         `json:"version,omitempty"`
+	Protocol  int            `json:"protocol"`
+	Detectors []DetectorInfo `json:"detectors,omitempty"`
+	Defenses  []DefenseInfo  `json:"defenses,omitempty"`
+}
+
+// DetectorInfo describes a detector of a plugin. Samples scoring above
+// Threshold (default 0.5) are flagged as Type (default data_poison).
+type DetectorInfo struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Method      string            `json:"method,omitempty"`
+	Type        detect.PoisonType `json:"type,omitempty"`
+	Threshold   *float64          `json:"threshold,omitempty"`
+	DataTypes   []string          `json:"data_types,omitempty"`
+}
+
+// DefenseInfo describes a defense of a plugin: a strategy removing samples
+// of type Type (default filtering), with the effectiveness and overhead
+// recommend ranks it by.
+type DefenseInfo struct {
+	Name          string   `json:"name"`
+	Description   string   `json:"description,omitempty"`
+	Type          string   `json:"type,omitempty"`
+	Effectiveness float64  `json:"effectiveness,omitempty"`
+	Overhead      float64  `json:"overhead,omitempty"`
+	Constraints   []string `json:"constraints,omitempty"`
+}
+
+// Request is a message to a plugin.
+type Request struct {
+	Method  string   `json:"method"`
+	Name    string   `json:"name,omitempty"`
+	Samples []Sample `json:"samples,omitempty"`
+}
+
+// Response is a plugin's answer to a Request: its Info, the Scores of the
+// samples, the indices of the samples Removed, or an Error.
+type Response struct {
+	Info    *Info                  `json:"info,omitempty"`
+	Scores  []detect.ExternalScore `json:"scores,omitempty"`
+	Removed []int                  `json:"removed,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+}
+
+// Sample is a sample sent to a plugin. Source is the sample's
+// detect.MetaSource metadata, when it has one.
+type Sample struct {
+	ID       string   `json:"id"`
+	Label    int      `json:"label"`
+	Features Features `json:"features"`
+	Source   string   `json:"source,omitempty"`
+}
+
+// Features are the dense features of a Sample, encoded with null for
+// non-finite values, which JSON cannot represent.
+type Features []float64
+
+// MarshalJSON implements json.Marshaler.
+func (f Features) MarshalJSON() ([]byte, error) {
+	b := make([]byte, 0, 2+len(f)*8)
+	b = append(b, '[')
+	for i, v := range f {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			b = append(b, "null"...)
+			continue
+		}
+		b = strconv.AppendFloat(b, v, 'g', -1, 64)
+	}
+	return append(b, ']'), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, reading null as NaN.
+func (f *Features) UnmarshalJSON(data []byte) error {
+	var values []*float64
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	*f = make(Features, len(values))
+	for i, v := range values {
+		(*f)[i] = math.NaN()
+		if v != nil {
+			(*f)[i] = *v
+		}
+	}
+	return nil
+}
+
+// newSamples converts samples for the wire.
+func newSamples(samples []detect.Sample) []Sample {
+	wire := make([]Sample, len(samples))
+	for i, s := range samples {
+		wire[i] = Sample{ID: s.ID, Label: s.Label, Features: s.Dense()}
+		if source, ok := s.Meta(detect.MetaSource); ok {
+			wire[i].Source = fmt.Sprint(source)
+		}
+	}
+	return wire
+}
+
+// Plugin is a running plugin. Its methods are safe for concurrent use;
+// requests are sent one at a time.
+type Plugin struct {
+	path string
+	info Info
+	cmd  *exec.Cmd
+
+	mu  sync.Mutex
+	in  io.WriteCloser
+	out *bufio.Reader
+	// err is the first failure of a request, after which the
+	// plugin is not called again.
+	err error
+}
+
+// Start starts the plugin executable at path and asks it to describe
+// itself. The plugin's standard error is copied to stderr, if not nil. The
+// plugin is killed when ctx is done.
+func Start(ctx context.Context, path string, stderr io.Writer) (*Plugin, error) {
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Env = append(os.Environ(), EnvProtocol+"="+strconv.Itoa(Protocol))
+	cmd.Stderr = stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	p := &Plugin{path: path, cmd: cmd, in: in, out: bufio.NewReaderSize(out, 64<<10)}
+
+	resp, err := p.call(Request{Method: "describe"})
+	if err == nil && resp.Info == nil {
+		err = errors.New("describe returned no info")
+	}
+	if err == nil {
+		p.info = *resp.Info
+		err = p.info.check()
+	}
+	if err != nil {
+		p.Close()
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// check validates the description of a plugin.
+func (info Info) check() error {
+	if info.Protocol != Protocol {
+		return fmt.Errorf("speaks protocol %d, want %d", info.Protocol, Protocol)
+	}
+	if info.Name == "" {
+		return errors.New("describe returned no name")
+	}
+	if len(info.Detectors) == 0 && len(info.Defenses) == 0 {
+		return errors.New("provides no detectors or defenses")
+	}
+	for _, det := range info.Detectors {
+		if det.Name == "" {
+			return errors.New("detector without a name")
+		}
+	}
+	for _, def := range info.Defenses {
+		if def.Name == "" {
+			return errors.New("defense without a name")
+		}
+	}
+	return nil
+}
+
+// Path returns the path of the plugin executable.
+func (p *Plugin) Path() string { return p.path }
+
+// Info returns the plugin's description.
+func (p *Plugin) Info() Info { return p.info }
+
+// Err returns the first failed request to the plugin, if any. Checks and
+// filters of a failed plugin score and remove nothing, so callers check
+// Err once their scan completes.
+func (p *Plugin) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// call sends req and reads the response.
+func (p *Plugin) call(req Request) (Response, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return Response{}, err
+	}
+	if _, err := p.in.Write(append(data, '\n')); err != nil {
+		return Response{}, fmt.Errorf("%s: %w", req.Method, err)
+	}
+	line, err := p.out.ReadBytes('\n')
+	if err == io.EOF && len(line) == 0 {
+		return Response{}, fmt.Errorf("%s: plugin exited", req.Method)
+	}
+	if err != nil && err != io.EOF {
+		return Response{}, fmt.Errorf("%s: %w", req.Method, err)
+	}
+	var resp Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return Response{}, fmt.Errorf("%s: invalid response: %w", req.Method, err)
+	}
+	if resp.Error != "" {
+		return Response{}, fmt.Errorf("%s %s: %s", req.Method, req.Name, resp.Error)
+	}
+	return resp, nil
+}
+
+// request sends req unless an earlier request failed, recording its
+// failure.
+func (p *Plugin) request(req Request) (Response, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return Response{}, false
+	}
+	resp, err := p.call(req)
+	if err != nil {
+		p.err = fmt.Errorf("plugin %s: %w", p.info.Name, err)
+		return Response{}, false
+	}
+	return resp, true
+}
+
+// Close stops the plugin: it closes the plugin's input, which ends Serve,
+// and waits for it to exit.
+func (p *Plugin) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.in.Close()
+	err := p.cmd.Wait()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && p.err != nil {
+		// The failure of the request explains the exit.
+		return nil
+	}
+	return err
+}
+
+// check is a detector of a plugin, as a detect.ExternalCheck.
+type check struct {
+	p    *Plugin
+	info DetectorInfo
+}
+
+func (c check) Info() detect.CheckInfo {
+	info := detect.CheckInfo{
+		Name:        c.info.Name,
+		Plugin:      c.p.info.Name,
+		Type:        c.info.Type,
+		Description: c.info.Description,
+		Method:      c.info.Method,
+		DataTypes:   c.info.DataTypes,
+	}
+	if c.p.info.Version != "" {
+		info.Plugin += "@" + c.p.info.Version
+	}
+	if info.Type == "" {
+		info.Type = detect.TypeDataPoison
+	}
+	if info.Description == "" {
+		info.Description = "Flagged by plugin detector " + c.info.Name
+	}
+	if info.Method == "" {
+		info.Method = "plugin"
+	}
+	if len(info.DataTypes) == 0 {
+		info.DataTypes = []string{"tabular"}
+	}
+	return info
+}
+
+func (c check) Threshold() float64 {
+	if c.info.Threshold == nil {
+		return 0.5
+	}
+	return *c.info.Threshold
+}
+
+func (c check) Score(samples []detect.Sample) []detect.ExternalScore {
+	resp, ok := c.p.request(Request{Method: "detect", Name: c.info.Name, Samples: newSamples(samples)})
+	if !ok {
+		return nil
+	}
+	if len(resp.Scores) != len(samples) {
+		c.p.mu.Lock()
+		if c.p.err == nil {
+			c.p.err = fmt.Errorf("plugin %s: detect %s: %d scores for %d samples", c.p.info.Name, c.info.Name, len(resp.Scores), len(samples))
+		}
+		c.p.mu.Unlock()
+		return nil
+	}
+	return resp.Scores
+}
+
+// filter is a defense of a plugin, as a defend.ExternalFilter.
+type filter struct {
+	p    *Plugin
+	name string
+}
+
+func (f filter) Remove(samples []detect.Sample) []int {
+	resp, ok := f.p.request(Request{Method: "defend", Name: f.name, Samples: newSamples(samples)})
+	if !ok {
+		return nil
+	}
+	removed := resp.Removed[:0:0]
+	for _, i := range resp.Removed {
+		if i >= 0 && i < len(samples) {
+			removed = append(removed, i)
+		}
+	}
+	return removed
+}
+
+// Discover returns the paths of the plugins in dir: its executable
+// regular files, sorted by name. Hidden files are skipped.
+func Discover(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		if info.Mode().IsRegular() && info.Mode().Perm()&0o111 != 0 {
+			paths = append(paths, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Set is the plugins of a directory.
+type Set struct {
+	plugins []*Plugin
+}
+
+// Load discovers and starts the plugins in dir, as Start.
+func Load(ctx context.Context, dir string, stderr io.Writer) (*Set, error) {
+	paths, err := Discover(dir)
+	if err != nil {
+		return nil, fmt.Errorf("plugin directory: %w", err)
+	}
+	set := &Set{}
+	names := make(map[string]string)
+	for _, path := range paths {
+		p, err := Start(ctx, path, stderr)
+		if err == nil {
+			if other, ok := names[p.info.Name]; ok {
+				p.Close()
+				err = fmt.Errorf("plugin %s: %s is also named %s", path, other, p.info.Name)
+			}
+		}
+		if err != nil {
+			set.Close()
+			return nil, err
+		}
+		names[p.info.Name] = path
+		set.plugins = append(set.plugins, p)
+	}
+	return set, nil
+}
+
+// Plugins returns the running plugins, in path order.
+func (s *Set) Plugins() []*Plugin { return s.plugins }
+
+// Register adds the detectors of the plugins to d.
+func (s *Set) Register(d *detect.Detector) error {
+	for _, p := range s.plugins {
+		for _, det := range p.info.Detectors {
+			if err := d.AddCheck(check{p: p, info: det}); err != nil {
+				return fmt.Errorf("plugin %s: %w", p.info.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// RegisterDefenses adds the defenses of the plugins to d.
+func (s *Set) RegisterDefenses(d *defend.Defender) error {
+	for _, p := range s.plugins {
+		for _, def := range p.info.Defenses {
+			strategy := defend.DefenseStrategy{
+				Name:          def.Name,
+				Description:   def.Description,
+				Effectiveness: def.Effectiveness,
+				Overhead:      def.Overhead,
+				Type:          def.Type,
+				Constraints:   def.Constraints,
+				Plugin:        p.info.Name,
+			}
+			if strategy.Type == "" {
+				strategy.Type = "filtering"
+			}
+			if err := d.AddStrategy(strategy, filter{p: p, name: def.Name}); err != nil {
+				return fmt.Errorf("plugin %s: %w", p.info.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Err returns the failures of the plugins' requests.
+func (s *Set) Err() error {
+	var errs []error
+	for _, p := range s.plugins {
+		errs = append(errs, p.Err())
+	}
+	return errors.Join(errs...)
+}
+
+// Close stops the plugins.
+func (s *Set) Close() error {
+	var errs []error
+	for _, p := range s.plugins {
+		if err := p.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("plugin %s: %w", p.info.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,164 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/defend"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// TestMain runs the test binary as a plugin when the tests start it.
+func TestMain(m *testing.M) {
+	if os.Getenv(EnvProtocol) != "" {
+		if err := Serve(testHandler); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+var threshold = 0.8
+
+// testHandler flags and removes the samples whose first feature is above
+// 10, and fails on samples named "boom".
+var testHandler = Handler{
+	Info: Info{
+		Name:      "test",
+		Version:   "1.0",
+		Detectors: []DetectorInfo{{Name: "first-feature", Threshold: &threshold}},
+		Defenses:  []DefenseInfo{{Name: "Drop Large", Effectiveness: 0.5}},
+	},
+	Detect: func(name string, samples []Sample) ([]detect.ExternalScore, error) {
+		scores := make([]detect.ExternalScore, len(samples))
+		for i, s := range samples {
+			if s.ID == "boom" {
+				return nil, errors.New("boom")
+			}
+			if !math.IsNaN(s.Features[1]) {
+				return nil, fmt.Errorf("%s: feature 1 = %v, want NaN", s.ID, s.Features[1])
+			}
+			if s.Features[0] > 10 {
+				scores[i] = detect.ExternalScore{Score: 0.9, Evidence: "first feature " + name}
+			}
+		}
+		return scores, nil
+	},
+	Defend: func(name string, samples []Sample) ([]int, error) {
+		var removed []int
+		for i, s := range samples {
+			if s.Features[0] > 10 {
+				removed = append(removed, i)
+			}
+		}
+		return removed, nil
+	},
+}
+
+func startTestPlugin(t *testing.T) *Set {
+	t.Helper()
+	p, err := Start(context.Background(), os.Args[0], os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := &Set{plugins: []*Plugin{p}}
+	t.Cleanup(func() { set.Close() })
+	return set
+}
+
+func pluginSamples(n int) []detect.Sample {
+	samples := make([]detect.Sample, n)
+	for i := range samples {
+		features := []float64{float64(i % 3), math.NaN(), 1}
+		if i%50 == 7 {
+			features[0] = 100
+		}
+		samples[i] = detect.Sample{ID: fmt.Sprintf("s%d", i), Features: features, Label: i % 2}
+	}
+	return samples
+}
+
+func TestPluginDetector(t *testing.T) {
+	set := startTestPlugin(t)
+	d := detect.NewDetector()
+	if err := set.Register(d); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Register(d); err == nil {
+		t.Error("registering the detectors twice succeeded")
+	}
+
+	samples := pluginSamples(3000)
+	result := d.Detect(samples)
+	if err := set.Err(); err != nil {
+		t.Fatal(err)
+	}
+	for i, finding := range result.Samples {
+		large := i%50 == 7
+		if large && (!finding.IsPoisoned || finding.Score < 0.9) {
+			t.Errorf("sample %s not flagged: %+v", finding.ID, finding)
+		}
+		if large && finding.Type == detect.TypeDataPoison && finding.Evidence != "first feature first-feature" {
+			t.Errorf("sample %s: evidence %q", finding.ID, finding.Evidence)
+		}
+	}
+
+	var found bool
+	for _, info := range d.Describe() {
+		if info.Name == "first-feature" {
+			found = info.Plugin == "test@1.0" && info.Type == detect.TypeDataPoison
+		}
+	}
+	if !found {
+		t.Error("the plugin detector is not described")
+	}
+}
+
+func TestPluginDefense(t *testing.T) {
+	set := startTestPlugin(t)
+	d := defend.NewDefender()
+	if err := set.RegisterDefenses(d); err != nil {
+		t.Fatal(err)
+	}
+	samples := pluginSamples(200)
+	removed := d.RemovedIndices(samples, "Drop Large")
+	if want := []int{7, 57, 107, 157}; fmt.Sprint(removed) != fmt.Sprint(want) {
+		t.Errorf("removed = %v, want %v", removed, want)
+	}
+	if strategy, ok := d.Strategy("Drop Large"); !ok || strategy.Plugin != "test" {
+		t.Errorf("strategy = %+v", strategy)
+	}
+}
+
+func TestPluginFailure(t *testing.T) {
+	set := startTestPlugin(t)
+	d := detect.NewDetector()
+	if err := set.Register(d); err != nil {
+		t.Fatal(err)
+	}
+	samples := pluginSamples(10)
+	samples[3].ID = "boom"
+	d.Detect(samples)
+	err := set.Err()
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("Err() = %v, want the plugin's failure", err)
+	}
+	// The plugin is not called again once it failed.
+	d.Detect(pluginSamples(10))
+	if got := set.Err(); got.Error() != err.Error() {
+		t.Errorf("Err() = %v after a second scan, want %v", got, err)
+	}
+}
+
+func TestStartRejectsPrograms(t *testing.T) {
+	if _, err := Start(context.Background(), "/bin/true", nil); err == nil {
+		t.Error("Start succeeded with a program that is not a plugin")
+	}
+}
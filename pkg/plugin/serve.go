@@ -0,0 +1,95 @@
+package plugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Handler implements the detectors and defenses of a plugin written in Go.
+type Handler struct {
+	// Info describes the plugin. Its Protocol defaults to Protocol.
+	Info Info
+	// Detect scores samples with the named detector, returning a score
+	// per sample, in order.
+	Detect func(name string, samples []Sample) ([]detect.ExternalScore, error)
+	// Defend returns the indices of the samples the named defense
+	// removes.
+	Defend func(name string, samples []Sample) ([]int, error)
+}
+
+// Serve answers the requests of modelpoison on standard input and output
+// until modelpoison closes the plugin's input. It refuses to run outside
+// modelpoison, when EnvProtocol is not set.
+func Serve(h Handler) error {
+	if v := os.Getenv(EnvProtocol); v != strconv.Itoa(Protocol) {
+		return errors.New("this is a modelpoison plugin; place it in the plugin directory rather than running it")
+	}
+	return serve(h, os.Stdin, os.Stdout)
+}
+
+// serve answers the requests read from r on w.
+func serve(h Handler, r io.Reader, w io.Writer) error {
+	if h.Info.Protocol == 0 {
+		h.Info.Protocol = Protocol
+	}
+	in := bufio.NewReaderSize(r, 64<<10)
+	out := bufio.NewWriter(w)
+	enc := json.NewEncoder(out)
+	for {
+		line, err := in.ReadBytes('\n')
+		if errors.Is(err, io.EOF) && len(line) == 0 {
+			return nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		var req Request
+		resp := Response{}
+		if err := json.Unmarshal(line, &req); err != nil {
+			resp.Error = "invalid request: " + err.Error()
+		} else {
+			resp = h.handle(req)
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+		if err := out.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// handle answers a request.
+func (h Handler) handle(req Request) Response {
+	var resp Response
+	var err error
+	switch req.Method {
+	case "describe":
+		resp.Info = &h.Info
+	case "detect":
+		if h.Detect == nil {
+			err = errors.New("plugin has no detectors")
+			break
+		}
+		resp.Scores, err = h.Detect(req.Name, req.Samples)
+	case "defend":
+		if h.Defend == nil {
+			err = errors.New("plugin has no defenses")
+			break
+		}
+		resp.Removed, err = h.Defend(req.Name, req.Samples)
+	default:
+		err = fmt.Errorf("unknown method %q", req.Method)
+	}
+	if err != nil {
+		return Response{Error: err.Error()}
+	}
+	return resp
+}
@@ -0,0 +1,254 @@
+// Package policy evaluates scan results against Rego policies with an
+// embedded Open Policy Agent engine, returning allow or deny decisions for
+// gating datasets before training, and against rules mapping conditions
+// on a scan to automated actions.
+//
+// Policies are Rego modules in package modelpoison. Every message of the
+// deny set denies the dataset; warn messages are reported but allow it:
+//
+//	package modelpoison
+//
+//	import future.keywords.contains
+//	import future.keywords.if
+//
+//	deny contains msg if {
+//		input.classes.approve.flagged_fraction > 0.005
+//		msg := "more than 0.5% of approve samples flagged"
+//	}
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/open-policy-agent/opa/rego"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Query is the document evaluated by policies.
+const Query = "data.modelpoison"
+
+// Config configures policy evaluation.
+type Config struct {
+	// Files lists Rego policy files or directories of them. JSON and YAML
+	// files found alongside are loaded as data.
+	Files []string `yaml:"files"`
+	// Classes names labels, so policies can refer to input.classes.approve
+	// rather than input.classes["1"].
+	Classes map[int]string `yaml:"classes,omitempty"`
+	// Rules map conditions on scans to actions taken after every scan.
+	Rules []Rule `yaml:"rules,omitempty"`
+	// Quarantine is the quarantine directory of the quarantine action
+	// (default .modelpoison/quarantine).
+	Quarantine string `yaml:"quarantine,omitempty"`
+}
+
+// Check reports errors in the configuration.
+func (c Config) Check() error {
+	if len(c.Files) == 0 && len(c.Rules) == 0 {
+		return errors.New("no policy files or rules")
+	}
+	names := make(map[string]int, len(c.Classes))
+	for label, name := range c.Classes {
+		if name == "" {
+			return fmt.Errorf("class %d has an empty name", label)
+		}
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("classes %d and %d are both named %q", other, label, name)
+		}
+		names[name] = label
+	}
+	rules := make(map[string]bool, len(c.Rules))
+	for _, rule := range c.Rules {
+		if rule.Name == "" {
+			return errors.New("rule without a name")
+		}
+		if rules[rule.Name] {
+			return fmt.Errorf("duplicate rule %q", rule.Name)
+		}
+		rules[rule.Name] = true
+		if err := rule.check(); err != nil {
+			return fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+	}
+	return nil
+}
+
+// Input is the input document of policies.
+type Input struct {
+	ScanID        string  `json:"scan_id,omitempty"`
+	Project       string  `json:"project,omitempty"`
+	Dataset       string  `json:"dataset,omitempty"`
+	IsPoisoned    bool    `json:"is_poisoned"`
+	RiskScore     float64 `json:"risk_score"`
+	SampleCount   int     `json:"sample_count"`
+	PoisonedCount int     `json:"poisoned_count"`
+	// FlaggedFraction is PoisonedCount / SampleCount.
+	FlaggedFraction float64 `json:"flagged_fraction"`
+	Partial         bool    `json:"partial"`
+	// Types counts flagged samples by poison type.
+	Types map[string]int `json:"types"`
+	// Classes holds the sample and flagged counts of each label, keyed by
+	// class name or, for unnamed classes, by label.
+	Classes map[string]Class `json:"classes"`
+	// Findings lists the flagged samples.
+	Findings []detect.PoisonedSample `json:"findings"`
+}
+
+// Class holds the counts of one label.
+type Class struct {
+	Label           int     `json:"label"`
+	Samples         int     `json:"samples"`
+	Flagged         int     `json:"flagged"`
+	FlaggedFraction float64 `json:"flagged_fraction"`
+}
+
+// Decision is the outcome of evaluating a result.
+type Decision struct {
+	Allow bool     `json:"allow"`
+	Deny  []string `json:"deny,omitempty"`
+	Warn  []string `json:"warn,omitempty"`
+}
+
+// Engine evaluates results against compiled policies and rules.
+type Engine struct {
+	cfg   Config
+	query *rego.PreparedEvalQuery
+}
+
+// New loads and compiles the policies of cfg.
+func New(ctx context.Context, cfg Config) (*Engine, error) {
+	if err := cfg.Check(); err != nil {
+		return nil, err
+	}
+	if len(cfg.Files) == 0 {
+		return &Engine{cfg: cfg}, nil
+	}
+	query, err := rego.New(rego.Query(Query), rego.Load(cfg.Files, nil)).PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("compile policies: %w", err)
+	}
+	return &Engine{cfg: cfg, query: &query}, nil
+}
+
+// NewInput builds the policy input describing result.
+func (e *Engine) NewInput(result *detect.DetectionResult) Input {
+	input := Input{
+		IsPoisoned:    result.IsPoisoned,
+		RiskScore:     result.RiskScore,
+		SampleCount:   result.SampleCount,
+		PoisonedCount: result.PoisonedCount,
+		Partial:       result.Partial != nil,
+		Types:         make(map[string]int),
+		Classes:       make(map[string]Class),
+		Findings:      []detect.PoisonedSample{},
+	}
+	if result.SampleCount > 0 {
+		input.FlaggedFraction = float64(result.PoisonedCount) / float64(result.SampleCount)
+	}
+	for _, sample := range result.Samples {
+		name := e.className(sample.Label)
+		class := input.Classes[name]
+		class.Label = sample.Label
+		if result.LabelCounts == nil {
+			class.Samples++
+		}
+		if sample.IsPoisoned {
+			class.Flagged++
+			input.Types[string(sample.Type)]++
+			input.Findings = append(input.Findings, sample)
+		}
+		input.Classes[name] = class
+	}
+	// Results of chunked scans count the samples of each label instead.
+	for label, n := range result.LabelCounts {
+		name := e.className(label)
+		class := input.Classes[name]
+		class.Label, class.Samples = label, n
+		input.Classes[name] = class
+	}
+	for name, class := range input.Classes {
+		class.FlaggedFraction = float64(class.Flagged) / float64(class.Samples)
+		input.Classes[name] = class
+	}
+	return input
+}
+
+// className returns the key of a label in Input.Classes.
+func (e *Engine) className(label int) string {
+	if name, ok := e.cfg.Classes[label]; ok {
+		return name
+	}
+	return strconv.Itoa(label)
+}
+
+// Evaluate evaluates the policies against input. Rules matching it with
+// the fail action deny it and, with the warn action, warn.
+func (e *Engine) Evaluate(ctx context.Context, input Input) (Decision, error) {
+	var values map[string]interface{}
+	if e.query != nil {
+		// Policies see the JSON names of the input fields.
+		data, err := json.Marshal(input)
+		if err != nil {
+			return Decision{}, err
+		}
+		var doc interface{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return Decision{}, err
+		}
+
+		rs, err := e.query.Eval(ctx, rego.EvalInput(doc))
+		if err != nil {
+			return Decision{}, fmt.Errorf("evaluate policies: %w", err)
+		}
+		if len(rs) > 0 && len(rs[0].Expressions) > 0 {
+			values, _ = rs[0].Expressions[0].Value.(map[string]interface{})
+		}
+	}
+
+	decision := Decision{
+		Deny: messages(values["deny"]),
+		Warn: messages(values["warn"]),
+	}
+	for _, m := range e.Match(input) {
+		if m.Has(ActionFail) {
+			decision.Deny = append(decision.Deny, m.Message)
+		}
+		if m.Has(ActionWarn) {
+			decision.Warn = append(decision.Warn, m.Message)
+		}
+	}
+	sort.Strings(decision.Deny)
+	sort.Strings(decision.Warn)
+	decision.Allow = len(decision.Deny) == 0
+	return decision, nil
+}
+
+// messages returns the sorted messages of a deny or warn rule, which may be
+// a set of strings or of objects with a msg field.
+func messages(value interface{}) []string {
+	items, _ := value.([]interface{})
+	var msgs []string
+	for _, item := range items {
+		switch v := item.(type) {
+		case string:
+			msgs = append(msgs, v)
+		case map[string]interface{}:
+			if msg, ok := v["msg"].(string); ok {
+				msgs = append(msgs, msg)
+				continue
+			}
+			data, _ := json.Marshal(v)
+			msgs = append(msgs, string(data))
+		default:
+			msgs = append(msgs, fmt.Sprint(v))
+		}
+	}
+	sort.Strings(msgs)
+	return msgs
+}
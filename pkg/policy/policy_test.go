@@ -0,0 +1,121 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+const testPolicy = `package modelpoison
+
+import future.keywords.contains
+import future.keywords.if
+
+deny contains msg if {
+	c := input.classes.approve
+	c.flagged_fraction > 0.005
+	msg := sprintf("%d of %d approve samples flagged", [c.flagged, c.samples])
+}
+
+warn contains {"msg": "backdoor findings"} if {
+	input.types.backdoor > 0
+}
+`
+
+func TestEvaluate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "gate.rego"), []byte(testPolicy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	engine, err := New(context.Background(), Config{Files: []string{dir}, Classes: map[int]string{1: "approve"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := make([]detect.PoisonedSample, 200)
+	for i := range samples {
+		samples[i] = detect.PoisonedSample{ID: "s", Label: i % 2}
+	}
+	result := &detect.DetectionResult{SampleCount: 200, Samples: samples}
+
+	decision, err := engine.Evaluate(context.Background(), engine.NewInput(result))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decision.Allow || decision.Deny != nil || decision.Warn != nil {
+		t.Errorf("clean result: decision = %+v, want allow", decision)
+	}
+
+	samples[1].IsPoisoned, samples[1].Type = true, detect.TypeBackdoor
+	result.PoisonedCount, result.IsPoisoned = 1, true
+	decision, err = engine.Evaluate(context.Background(), engine.NewInput(result))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Decision{Deny: []string{"1 of 100 approve samples flagged"}, Warn: []string{"backdoor findings"}}
+	if !reflect.DeepEqual(decision, want) {
+		t.Errorf("decision = %+v, want %+v", decision, want)
+	}
+}
+
+func TestNewRejectsBadPolicies(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "bad.rego"), []byte("package modelpoison\ndeny[msg] {"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(context.Background(), Config{Files: []string{dir}}); err == nil {
+		t.Error("New() compiled an invalid policy")
+	}
+	if _, err := New(context.Background(), Config{}); err == nil {
+		t.Error("New() accepted a config without policies")
+	}
+}
+
+func TestRules(t *testing.T) {
+	engine, err := New(context.Background(), Config{
+		Classes: map[int]string{1: "approve"},
+		Rules: []Rule{
+			{Name: "risky", RiskAbove: 0.5, Actions: []Action{ActionWarn}},
+			{Name: "approve-backdoors", Types: []detect.PoisonType{detect.TypeBackdoor}, Classes: []string{"approve"},
+				Actions: []Action{ActionFail, ActionQuarantine}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := &detect.DetectionResult{SampleCount: 4, PoisonedCount: 2, RiskScore: 0.3, Samples: []detect.PoisonedSample{
+		{ID: "a", Label: 1, IsPoisoned: true, Type: detect.TypeBackdoor},
+		{ID: "b", Label: 0, IsPoisoned: true, Type: detect.TypeBackdoor},
+		{ID: "c", Label: 1},
+		{ID: "d", Label: 1, IsPoisoned: true, Type: detect.TypeLabelFlip},
+	}}
+	matches := engine.Match(engine.NewInput(result))
+	if len(matches) != 1 || matches[0].Rule != "approve-backdoors" || !reflect.DeepEqual(matches[0].Samples, []string{"a"}) {
+		t.Fatalf("Match() = %+v, want approve-backdoors on a", matches)
+	}
+
+	result.RiskScore = 0.6
+	decision, err := engine.Evaluate(context.Background(), engine.NewInput(result))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Allow || len(decision.Deny) != 1 || len(decision.Warn) != 1 {
+		t.Errorf("decision = %+v, want one deny and one warning", decision)
+	}
+
+	bad := []Rule{
+		{Name: "none", Actions: []Action{ActionWarn}},
+		{Name: "act", RiskAbove: 0.5, Actions: []Action{"page"}},
+		{Name: "hooks", RiskAbove: 0.5, Actions: []Action{ActionNotify}},
+	}
+	for _, rule := range bad {
+		if err := (Config{Rules: []Rule{rule}}).Check(); err == nil {
+			t.Errorf("Check() accepted rule %q", rule.Name)
+		}
+	}
+}
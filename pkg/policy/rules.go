@@ -0,0 +1,218 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/webhook"
+)
+
+// Action is what a rule does to a scan it matches.
+type Action string
+
+const (
+	// ActionWarn reports the rule's message.
+	ActionWarn Action = "warn"
+	// ActionFail fails the scan: the command exits with an error, and the
+	// server marks the scan failed. Policy decisions deny the dataset.
+	ActionFail Action = "fail"
+	// ActionQuarantine moves the matched samples into the quarantine.
+	ActionQuarantine Action = "quarantine"
+	// ActionClean writes a copy of the dataset without the matched samples.
+	ActionClean Action = "auto_clean"
+	// ActionNotify delivers the scan to the rule's webhooks.
+	ActionNotify Action = "notify"
+)
+
+// Actions lists the actions in the order they are applied.
+var Actions = []Action{ActionWarn, ActionNotify, ActionQuarantine, ActionClean, ActionFail}
+
+// Rule maps conditions on a scan to actions. It matches scans meeting
+// every condition it sets.
+type Rule struct {
+	Name string `yaml:"name"`
+	// RiskAbove matches scans with a risk score above this, and
+	// FlaggedAbove scans flagging more than this fraction of samples.
+	RiskAbove    float64 `yaml:"risk_above,omitempty"`
+	FlaggedAbove float64 `yaml:"flagged_above,omitempty"`
+	// Types and Classes match scans flagging samples of one of the poison
+	// types in one of the classes, by name or label. Those samples are the
+	// ones quarantined or cleaned; without either, all flagged samples are.
+	Types   []detect.PoisonType `yaml:"types,omitempty"`
+	Classes []string            `yaml:"classes,omitempty"`
+
+	Actions []Action `yaml:"actions"`
+	// Message describes the rule in warnings and failures (default: the
+	// conditions met).
+	Message string `yaml:"message,omitempty"`
+	// Notify lists the webhooks of the notify action.
+	Notify []webhook.Hook `yaml:"notify,omitempty"`
+}
+
+// Match is a rule matching a scan.
+type Match struct {
+	Rule    string   `json:"rule"`
+	Actions []Action `json:"actions"`
+	Message string   `json:"message"`
+	// Samples lists the IDs of the matched samples.
+	Samples []string `json:"samples,omitempty"`
+
+	notify []webhook.Hook
+}
+
+// Has reports whether the match takes action a.
+func (m Match) Has(a Action) bool {
+	for _, action := range m.Actions {
+		if action == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify returns the webhooks of the matched rule's notify action.
+func (m Match) Notify() []webhook.Hook { return m.notify }
+
+func (r Rule) check() error {
+	if r.RiskAbove == 0 && r.FlaggedAbove == 0 && len(r.Types) == 0 && len(r.Classes) == 0 {
+		return errors.New("no conditions (set risk_above, flagged_above, types or classes)")
+	}
+	if r.RiskAbove < 0 || r.RiskAbove > 1 {
+		return errors.New("risk_above must be between 0 and 1")
+	}
+	if r.FlaggedAbove < 0 || r.FlaggedAbove > 1 {
+		return errors.New("flagged_above must be between 0 and 1")
+	}
+	for _, t := range r.Types {
+		if !knownType(t) {
+			return fmt.Errorf("unknown poison type %q", t)
+		}
+	}
+	if len(r.Actions) == 0 {
+		return errors.New("no actions (set warn, fail, quarantine, auto_clean or notify)")
+	}
+	for _, a := range r.Actions {
+		known := false
+		for _, action := range Actions {
+			known = known || a == action
+		}
+		if !known {
+			return fmt.Errorf("unknown action %q (want warn, fail, quarantine, auto_clean or notify)", a)
+		}
+	}
+	if (len(r.Notify) > 0) != (Match{Actions: r.Actions}).Has(ActionNotify) {
+		return errors.New("the notify action and notify webhooks go together")
+	}
+	for _, hook := range r.Notify {
+		if err := hook.Check(); err != nil {
+			return fmt.Errorf("notify: %w", err)
+		}
+	}
+	return nil
+}
+
+func knownType(t detect.PoisonType) bool {
+	for _, known := range append(detect.Checks(), detect.TypeDataPoison) {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the rules matching the scan described by input, in rule
+// order.
+func (e *Engine) Match(input Input) []Match {
+	var matches []Match
+	for _, rule := range e.cfg.Rules {
+		if m, ok := e.match(rule, input); ok {
+			matches = append(matches, m)
+		}
+	}
+	return matches
+}
+
+func (e *Engine) match(r Rule, input Input) (Match, bool) {
+	var reasons []string
+	if r.RiskAbove > 0 {
+		if input.RiskScore <= r.RiskAbove {
+			return Match{}, false
+		}
+		reasons = append(reasons, fmt.Sprintf("risk %.2f above %.2f", input.RiskScore, r.RiskAbove))
+	}
+	if r.FlaggedAbove > 0 {
+		if input.FlaggedFraction <= r.FlaggedAbove {
+			return Match{}, false
+		}
+		reasons = append(reasons, fmt.Sprintf("%.1f%% of samples flagged", 100*input.FlaggedFraction))
+	}
+
+	m := Match{Rule: r.Name, Actions: r.Actions, notify: r.Notify}
+	types := make(map[detect.PoisonType]bool, len(r.Types))
+	for _, t := range r.Types {
+		types[t] = true
+	}
+	classes := make(map[string]bool, len(r.Classes))
+	for _, c := range r.Classes {
+		classes[c] = true
+	}
+	for _, finding := range input.Findings {
+		if len(types) > 0 && !types[finding.Type] {
+			continue
+		}
+		if len(classes) > 0 && !classes[e.className(finding.Label)] && !classes[strconv.Itoa(finding.Label)] {
+			continue
+		}
+		m.Samples = append(m.Samples, finding.ID)
+	}
+	if len(types) > 0 || len(classes) > 0 {
+		if len(m.Samples) == 0 {
+			return Match{}, false
+		}
+		var scope []string
+		if len(r.Types) > 0 {
+			scope = append(scope, joinTypes(r.Types))
+		}
+		if len(r.Classes) > 0 {
+			scope = append(scope, "in class "+strings.Join(r.Classes, ", "))
+		}
+		reasons = append(reasons, fmt.Sprintf("%d findings %s", len(m.Samples), strings.Join(scope, " ")))
+	}
+
+	m.Message = r.Message
+	if m.Message == "" {
+		m.Message = strings.Join(reasons, ", ")
+	}
+	m.Message = fmt.Sprintf("rule %s: %s", r.Name, m.Message)
+	return m, true
+}
+
+func joinTypes(types []detect.PoisonType) string {
+	names := make([]string, len(types))
+	for i, t := range types {
+		names[i] = string(t)
+	}
+	return strings.Join(names, "/")
+}
+
+// Load reads a YAML policy file holding the fields of Config.
+func Load(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := cfg.Check(); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,330 @@
+package preference
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/stats"
+)
+
+const (
+	// minAnnotations is the fewest pairs, and minChecked the fewest checked
+	// pairs, of an annotator whose bias is tested.
+	minAnnotations = 20
+	minChecked     = 10
+	// minBiasZ is the least z-score of an annotator's rate against that of
+	// the other annotators taken as a bias.
+	minBiasZ = 3
+	// minSupport is the fewest pairs with a token in one response only,
+	// minTokenShare the least share of them holding it in the chosen
+	// response and minTokenZ the least z-score of that share against an
+	// even split, flagging a token as rewarded.
+	minSupport    = 10
+	minTokenShare = 0.9
+	minTokenZ     = 4
+)
+
+// candidate is the highest scoring finding of a pair so far.
+type candidate struct {
+	kind        detect.PoisonType
+	score       float64
+	description string
+	evidence    string
+}
+
+// analysis holds the state of Analyze.
+type analysis struct {
+	pairs []Pair
+	best  []candidate
+
+	// chosen and rejected hold the normalized responses, and inverted
+	// whether each pair contradicts reference scores or repeated
+	// comparisons, among those checked.
+	chosen, rejected []string
+	checked          []bool
+	inverted         []bool
+}
+
+// add records a finding of pair i, keeping the highest scoring one.
+func (a *analysis) add(i int, kind detect.PoisonType, score float64, description, evidence string) {
+	if score > a.best[i].score {
+		a.best[i] = candidate{kind: kind, score: math.Min(1, score), description: description, evidence: evidence}
+	}
+}
+
+// normalize returns s in lower case with its white space collapsed, so
+// copies of a response compare equal.
+func normalize(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
+
+func (a *analysis) prepare() {
+	n := len(a.pairs)
+	a.chosen, a.rejected = make([]string, n), make([]string, n)
+	a.checked, a.inverted = make([]bool, n), make([]bool, n)
+	for i, pair := range a.pairs {
+		a.chosen[i], a.rejected[i] = normalize(pair.Chosen), normalize(pair.Rejected)
+	}
+}
+
+// inversions flags pairs whose rejected response has the higher
+// reference score, by the margin over the typical margin of the pairs.
+func (a *analysis) inversions() {
+	var margins []float64
+	for _, pair := range a.pairs {
+		if pair.ChosenScore != nil && pair.RejectedScore != nil {
+			if m := math.Abs(*pair.ChosenScore - *pair.RejectedScore); m > 0 {
+				margins = append(margins, m)
+			}
+		}
+	}
+	if len(margins) == 0 {
+		return
+	}
+	sort.Float64s(margins)
+	typical := stats.Quantile(margins, 0.5)
+
+	for i, pair := range a.pairs {
+		if pair.ChosenScore == nil || pair.RejectedScore == nil {
+			continue
+		}
+		a.checked[i] = true
+		margin := *pair.RejectedScore - *pair.ChosenScore
+		if margin <= 0 {
+			continue
+		}
+		a.inverted[i] = true
+		a.add(i, detect.TypeLabelFlip, 0.5+0.25*margin/typical,
+			"Preference contradicts reference scores",
+			fmt.Sprintf("rejected response scores %.3g against %.3g for the chosen one", *pair.RejectedScore, *pair.ChosenScore))
+	}
+}
+
+// contradictions flags pairs preferring the response that most other
+// comparisons of the same prompt and responses reject.
+func (a *analysis) contradictions() {
+	type votes struct{ forward, backward []int }
+	groups := make(map[string]*votes)
+	var keys []string
+	for i, pair := range a.pairs {
+		first, second := a.chosen[i], a.rejected[i]
+		if first == second {
+			continue
+		}
+		forward := first < second
+		if !forward {
+			first, second = second, first
+		}
+		key := normalize(pair.Prompt) + "\x00" + first + "\x00" + second
+		g := groups[key]
+		if g == nil {
+			g = &votes{}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		if forward {
+			g.forward = append(g.forward, i)
+		} else {
+			g.backward = append(g.backward, i)
+		}
+	}
+
+	for _, key := range keys {
+		g := groups[key]
+		majority, minority := g.forward, g.backward
+		if len(minority) > len(majority) {
+			majority, minority = minority, majority
+		}
+		if len(minority) == 0 || len(minority) == len(majority) {
+			continue
+		}
+		total := len(majority) + len(minority)
+		for _, i := range majority {
+			a.checked[i] = true
+		}
+		for _, i := range minority {
+			a.checked[i] = true
+			a.inverted[i] = true
+			a.add(i, detect.TypeLabelFlip, 0.6+0.4*float64(len(majority)-len(minority))/float64(total),
+				"Preference contradicts repeated comparisons",
+				fmt.Sprintf("%d of %d comparisons of the same prompt and responses prefer the other response", len(majority), total))
+		}
+	}
+}
+
+// words returns the number of words of s.
+func words(s string) int {
+	return len(strings.Fields(s))
+}
+
+// annotators summarizes the annotators of the pairs and flags the pairs
+// of those whose rate of inverted preferences, or of choosing the longer
+// response, departs from that of the other annotators by minBiasZ or more.
+func (a *analysis) annotators() []Annotator {
+	index := make(map[string]int)
+	var result []Annotator
+	var total Annotator
+	for i, pair := range a.pairs {
+		if pair.Annotator == "" {
+			continue
+		}
+		k, ok := index[pair.Annotator]
+		if !ok {
+			k = len(result)
+			index[pair.Annotator] = k
+			result = append(result, Annotator{Name: pair.Annotator})
+		}
+		s := &result[k]
+		s.Pairs++
+		if a.checked[i] {
+			s.Checked++
+			total.Checked++
+			if a.inverted[i] {
+				s.Inverted++
+				total.Inverted++
+			}
+		}
+		if c, r := words(a.chosen[i]), words(a.rejected[i]); c != r {
+			s.Compared++
+			total.Compared++
+			if c > r {
+				s.Longer++
+				total.Longer++
+			}
+		}
+	}
+	if len(result) < 2 {
+		return result
+	}
+
+	for k := range result {
+		s := &result[k]
+		if s.Pairs < minAnnotations {
+			continue
+		}
+		var flag func(i int) bool
+		var z float64
+		if s.Checked >= minChecked {
+			others := rate(total.Inverted-s.Inverted, total.Checked-s.Checked)
+			if z = zScore(s.Inverted, s.Checked, others); z >= minBiasZ {
+				s.Bias = fmt.Sprintf("annotator %s inverted %d of %d checked preferences, against %.0f%% for the other annotators",
+					s.Name, s.Inverted, s.Checked, 100*others)
+				flag = func(int) bool { return true }
+			}
+		}
+		if s.Bias == "" && s.Compared >= minAnnotations {
+			others := rate(total.Longer-s.Longer, total.Compared-s.Compared)
+			z = zScore(s.Longer, s.Compared, others)
+			longer := z > 0
+			if z = math.Abs(z); z >= minBiasZ {
+				which := "longer"
+				if !longer {
+					which = "shorter"
+				}
+				s.Bias = fmt.Sprintf("annotator %s chose the %s response in %d of %d pairs, against %.0f%% for the other annotators",
+					s.Name, which, choseLonger(s, longer), s.Compared, 100*choseRate(others, longer))
+				flag = func(i int) bool {
+					c, r := words(a.chosen[i]), words(a.rejected[i])
+					return c != r && (c > r) == longer
+				}
+			}
+		}
+		if flag == nil {
+			continue
+		}
+		for i, pair := range a.pairs {
+			if pair.Annotator == s.Name && flag(i) {
+				a.add(i, detect.TypeDataPoison, 0.6+0.05*z, "Annotator shows a systematic bias", s.Bias)
+			}
+		}
+	}
+	return result
+}
+
+// choseLonger returns the number of pairs of s choosing the longer
+// response, or the shorter one when not longer.
+func choseLonger(s *Annotator, longer bool) int {
+	if longer {
+		return s.Longer
+	}
+	return s.Compared - s.Longer
+}
+
+// choseRate returns the rate of choosing the longer response, or the
+// shorter one when not longer, of p, the rate of choosing the longer one.
+func choseRate(p float64, longer bool) float64 {
+	if longer {
+		return p
+	}
+	return 1 - p
+}
+
+// rate returns k of n as a rate, or 0 when n is 0.
+func rate(k, n int) float64 {
+	if n == 0 {
+		return 0
+	}
+	return float64(k) / float64(n)
+}
+
+// zScore returns the z-score of k successes in n trials of probability p,
+// which is kept within [0.01, 0.99] so rare and common events score.
+func zScore(k, n int, p float64) float64 {
+	p = math.Min(0.99, math.Max(0.01, p))
+	return (float64(k) - float64(n)*p) / math.Sqrt(float64(n)*p*(1-p))
+}
+
+// tokens returns the distinct words of s.
+func tokens(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		set[w] = true
+	}
+	return set
+}
+
+// tokens flags the pairs whose chosen response holds a token that, of
+// the pairs holding it in one response only and not in the prompt,
+// nearly always holds it in the chosen one: a token winning comparisons
+// whatever the response, as reward hacking exploits.
+func (a *analysis) tokens() {
+	wins, losses := make(map[string][]int), make(map[string]int)
+	for i, pair := range a.pairs {
+		prompt := tokens(normalize(pair.Prompt))
+		chosen, rejected := tokens(a.chosen[i]), tokens(a.rejected[i])
+		for t := range chosen {
+			if !rejected[t] && !prompt[t] {
+				wins[t] = append(wins[t], i)
+			}
+		}
+		for t := range rejected {
+			if !chosen[t] && !prompt[t] {
+				losses[t]++
+			}
+		}
+	}
+
+	var rewarded []string
+	for t, pairs := range wins {
+		m := len(pairs) + losses[t]
+		if m >= minSupport && rate(len(pairs), m) >= minTokenShare && zScore(len(pairs), m, 0.5) >= minTokenZ {
+			rewarded = append(rewarded, t)
+		}
+	}
+	sort.Strings(rewarded)
+	for _, t := range rewarded {
+		m := len(wins[t]) + losses[t]
+		z := zScore(len(wins[t]), m, 0.5)
+		evidence := fmt.Sprintf("chosen response holds %q, which the chosen response holds in %d of %d pairs holding it in one response only",
+			t, len(wins[t]), m)
+		for _, i := range wins[t] {
+			a.add(i, detect.TypeBackdoor, 0.6+0.05*z, "Token rewarded regardless of the response", evidence)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Package preference checks preference datasets, the comparisons of two
+// responses to a prompt that reward models and DPO are trained on, for
+// poisoning: preferences inverted against reference scores or repeated
+// comparisons, annotators injecting a systematic bias, and tokens that
+// win comparisons whatever the response, which a reward model learns to
+// reward.
+package preference
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Method is the method of the results of Analyze.
+const Method = "preference_analysis"
+
+// Pair is a comparison of two responses to a prompt, one chosen over the
+// other.
+type Pair struct {
+	ID       string
+	Prompt   string
+	Chosen   string
+	Rejected string
+	// Annotator is who made the comparison, if recorded.
+	Annotator string
+	// ChosenScore and RejectedScore are reference scores of the responses,
+	// such as those of a trusted reward model, if recorded.
+	ChosenScore   *float64
+	RejectedScore *float64
+}
+
+// Columns names the fields of the pairs of a dataset. Empty fields take
+// the defaults "id", "prompt", "chosen" and "rejected"; the annotator and
+// score fields are read when named or when fields named "annotator",
+// "chosen_score" and "rejected_score" exist.
+type Columns struct {
+	ID, Prompt, Chosen, Rejected string
+	Annotator                    string
+	ChosenScore, RejectedScore   string
+}
+
+// Load loads the pairs of the CSV or JSON dataset at path.
+func Load(path string, cols Columns) ([]Pair, error) {
+	records, err := dataset.LoadRecords(path)
+	if err != nil {
+		return nil, err
+	}
+	pairs, err := FromRecords(records, cols)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return pairs, nil
+}
+
+// FromRecords reads the pairs of records.
+func FromRecords(records *dataset.Records, cols Columns) ([]Pair, error) {
+	field := func(name, fallback string, required bool) (int, error) {
+		if name == "" {
+			name = fallback
+			if i, err := records.Column(name); err == nil || !required {
+				return i, nil
+			}
+		}
+		return records.Column(name)
+	}
+	var idx [7]int
+	for i, f := range []struct {
+		name, fallback string
+		required       bool
+	}{
+		{cols.ID, "id", false},
+		{cols.Prompt, "prompt", true},
+		{cols.Chosen, "chosen", true},
+		{cols.Rejected, "rejected", true},
+		{cols.Annotator, "annotator", false},
+		{cols.ChosenScore, "chosen_score", false},
+		{cols.RejectedScore, "rejected_score", false},
+	} {
+		var err error
+		if idx[i], err = field(f.name, f.fallback, f.required); err != nil {
+			return nil, err
+		}
+	}
+
+	score := func(row []string, col, n int, what string) (*float64, error) {
+		if col < 0 || strings.TrimSpace(row[col]) == "" {
+			return nil, nil
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(row[col]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid %s score %q", n, what, row[col])
+		}
+		return &v, nil
+	}
+	pairs := make([]Pair, 0, len(records.Rows))
+	for r, row := range records.Rows {
+		n := r + 1
+		pair := Pair{
+			ID:       "row-" + strconv.Itoa(n),
+			Prompt:   row[idx[1]],
+			Chosen:   row[idx[2]],
+			Rejected: row[idx[3]],
+		}
+		if idx[0] >= 0 {
+			pair.ID = row[idx[0]]
+		}
+		if idx[4] >= 0 {
+			pair.Annotator = strings.TrimSpace(row[idx[4]])
+		}
+		var err error
+		if pair.ChosenScore, err = score(row, idx[5], n, "chosen"); err != nil {
+			return nil, err
+		}
+		if pair.RejectedScore, err = score(row, idx[6], n, "rejected"); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs, nil
+}
+
+// Annotator summarizes the comparisons of an annotator.
+type Annotator struct {
+	Name  string `json:"name"`
+	Pairs int    `json:"pairs"`
+	// Checked is the number of the annotator's pairs checked against
+	// reference scores or repeated comparisons, and Inverted the number
+	// contradicting them.
+	Checked  int `json:"checked"`
+	Inverted int `json:"inverted"`
+	// Compared is the number of the annotator's pairs of responses of
+	// different lengths, and Longer the number choosing the longer one.
+	Compared int `json:"compared"`
+	Longer   int `json:"longer"`
+	// Bias, when set, describes the systematic bias of the annotator.
+	Bias string `json:"bias,omitempty"`
+}
+
+// Analysis is the result of Analyze.
+type Analysis struct {
+	// Findings holds a finding per pair, in order.
+	Findings []detect.PoisonedSample
+	// Annotators summarizes the annotators of the pairs, by name.
+	Annotators []Annotator
+}
+
+// Analyze checks pairs for poisoning, flagging those scoring above the
+// threshold of their finding's type in thresholds.
+func Analyze(pairs []Pair, thresholds map[detect.PoisonType]float64) *Analysis {
+	a := &analysis{pairs: pairs, best: make([]candidate, len(pairs))}
+	a.prepare()
+	a.inversions()
+	a.contradictions()
+	annotators := a.annotators()
+	a.tokens()
+
+	findings := make([]detect.PoisonedSample, len(pairs))
+	for i, pair := range pairs {
+		findings[i] = detect.PoisonedSample{ID: pair.ID}
+		c := a.best[i]
+		threshold, ok := thresholds[c.kind]
+		if !ok {
+			threshold = 0.5
+		}
+		if c.score <= threshold {
+			continue
+		}
+		findings[i].IsPoisoned = true
+		findings[i].Type = c.kind
+		findings[i].Score = c.score
+		findings[i].Confidence = c.score
+		findings[i].Description = c.description
+		findings[i].Evidence = c.evidence
+		findings[i].ATLAS = detect.ATLASIDs(c.kind)
+	}
+	sort.Slice(annotators, func(i, j int) bool { return annotators[i].Name < annotators[j].Name })
+	return &Analysis{Findings: findings, Annotators: annotators}
+}
@@ -0,0 +1,127 @@
+package preference
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// testPairs returns 300 pairs as JSON Lines. The reference scores of p0
+// to p4 are inverted, p202 reverses the comparison of p200 and p201, the
+// chosen responses of p100 to p119 hold "xyzzy", and annotator mallory
+// chose the longer response of each of p260 to p299.
+func testPairs(t *testing.T) []Pair {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+	vocabulary := strings.Fields("the model answer explains a clear step by using simple words with an example and care for detail to help")
+	topics := strings.Fields("how why what when explain describe compare list")
+	response := func(n int) string {
+		var w []string
+		for i := 0; i < n; i++ {
+			w = append(w, vocabulary[rng.Intn(len(vocabulary))])
+		}
+		return strings.Join(w, " ")
+	}
+
+	var b strings.Builder
+	for i := 0; i < 300; i++ {
+		record := map[string]interface{}{
+			"id":        fmt.Sprintf("p%d", i),
+			"prompt":    fmt.Sprintf("%s %s question %d", topics[rng.Intn(len(topics))], topics[rng.Intn(len(topics))], i),
+			"chosen":    response(5 + rng.Intn(10)),
+			"rejected":  response(5 + rng.Intn(10)),
+			"annotator": fmt.Sprintf("a%d", i%4),
+		}
+		chosen, rejected := 0.7+0.1*rng.NormFloat64(), 0.3+0.1*rng.NormFloat64()
+		switch {
+		case i < 5:
+			chosen, rejected = rejected, chosen
+		case i >= 100 && i < 120:
+			record["chosen"] = record["chosen"].(string) + " xyzzy"
+		case i == 200 || i == 201 || i == 202:
+			record["prompt"], record["chosen"], record["rejected"] = "explain it", "a clear answer", "the model"
+			if i == 202 {
+				record["chosen"], record["rejected"] = record["rejected"], record["chosen"]
+			}
+		case i >= 260:
+			record["annotator"] = "mallory"
+			record["chosen"], record["rejected"] = response(20), response(5)
+		}
+		if i < 260 {
+			record["chosen_score"], record["rejected_score"] = chosen, rejected
+		}
+		line, err := json.Marshal(record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Write(line)
+		b.WriteString("\n")
+	}
+
+	records, err := dataset.ReadJSONRecords(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairs, err := FromRecords(records, Columns{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pairs
+}
+
+func TestAnalyze(t *testing.T) {
+	pairs := testPairs(t)
+	if p := pairs[7]; p.ID != "p7" || p.Annotator != "a3" || p.ChosenScore == nil || pairs[270].ChosenScore != nil {
+		t.Fatalf("pair 7 = %+v", p)
+	}
+
+	analysis := Analyze(pairs, detect.NewDetector().Thresholds())
+	for i, finding := range analysis.Findings {
+		var want detect.PoisonType
+		switch {
+		case i < 5 || i == 202:
+			want = detect.TypeLabelFlip
+		case i >= 100 && i < 120:
+			want = detect.TypeBackdoor
+		case i >= 260:
+			want = detect.TypeDataPoison
+		}
+		if finding.IsPoisoned != (want != "") || finding.Type != want {
+			t.Errorf("p%d: %+v, want type %q", i, finding, want)
+		}
+	}
+	if e := analysis.Findings[100].Evidence; !strings.Contains(e, `"xyzzy"`) {
+		t.Errorf("evidence of p100 = %q", e)
+	}
+
+	if len(analysis.Annotators) != 5 {
+		t.Fatalf("annotators = %+v", analysis.Annotators)
+	}
+	for _, a := range analysis.Annotators {
+		if (a.Bias != "") != (a.Name == "mallory") {
+			t.Errorf("annotator %+v", a)
+		}
+	}
+}
+
+func TestFromRecordsColumns(t *testing.T) {
+	records, err := dataset.ReadRecords(strings.NewReader("q,good,bad,score\nhi,hello,go away,\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pairs, err := FromRecords(records, Columns{Prompt: "q", Chosen: "good", Rejected: "bad", ChosenScore: "score"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pairs) != 1 || pairs[0].ID != "row-1" || pairs[0].Rejected != "go away" || pairs[0].ChosenScore != nil {
+		t.Errorf("pairs = %+v", pairs)
+	}
+	if _, err := FromRecords(records, Columns{}); err == nil {
+		t.Error("FromRecords without a prompt column succeeded")
+	}
+}
@@ -0,0 +1,368 @@
+package preprocess
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Encoding is a method of encoding a categorical column as numeric
+// features.
+type Encoding string
+
+const (
+	// OneHot encodes a column as one feature per category of its
+	// vocabulary, 1 for the row's category and 0 for the others.
+	OneHot Encoding = "onehot"
+	// Ordinal encodes a column as one feature, the position of the row's
+	// category in the sorted vocabulary.
+	Ordinal Encoding = "ordinal"
+	// Frequency encodes a column as one feature, the fraction of the
+	// fitted rows holding the row's category, so rare categories, where
+	// poisoned rows often stand out, are near 0.
+	Frequency Encoding = "frequency"
+	// Hashing encodes a column as a fixed number of features, 1 for the
+	// bucket the row's category hashes to, without a vocabulary, for
+	// columns with too many categories to list.
+	Hashing Encoding = "hashing"
+)
+
+const (
+	// DefaultMaxCategories is the default size of one-hot vocabularies.
+	DefaultMaxCategories = 32
+	// DefaultBuckets is the default number of features of hashed columns.
+	DefaultBuckets = 16
+)
+
+// Encodings returns the categorical encodings.
+func Encodings() []Encoding {
+	return []Encoding{OneHot, Ordinal, Frequency, Hashing}
+}
+
+// ParseEncoding parses a categorical encoding name. The empty name is
+// OneHot.
+func ParseEncoding(name string) (Encoding, error) {
+	if name == "" {
+		return OneHot, nil
+	}
+	for _, e := range Encodings() {
+		if strings.EqualFold(name, string(e)) {
+			return e, nil
+		}
+	}
+	return "", fmt.Errorf("unknown encoding %q (want onehot, ordinal, frequency or hashing)", name)
+}
+
+// EncodingConfig selects how categorical columns are encoded.
+type EncodingConfig struct {
+	// Method encodes every categorical column not listed in Columns.
+	Method Encoding `yaml:"method,omitempty" json:"method,omitempty"`
+	// Columns maps categorical columns to the method encoding them. The
+	// columns listed are categorical.
+	Columns map[string]Encoding `yaml:"columns,omitempty" json:"columns,omitempty"`
+	// MaxCategories is the size of one-hot vocabularies, which keep the
+	// most frequent categories (default DefaultMaxCategories).
+	MaxCategories int `yaml:"max_categories,omitempty" json:"max_categories,omitempty"`
+	// Buckets is the number of features of hashed columns (default
+	// DefaultBuckets).
+	Buckets int `yaml:"buckets,omitempty" json:"buckets,omitempty"`
+}
+
+// Check reports unknown methods and invalid sizes.
+func (c EncodingConfig) Check() error {
+	if _, err := ParseEncoding(string(c.Method)); err != nil {
+		return err
+	}
+	for _, column := range c.ColumnNames() {
+		if _, err := ParseEncoding(string(c.Columns[column])); err != nil {
+			return fmt.Errorf("column %q: %w", column, err)
+		}
+	}
+	if c.MaxCategories < 0 {
+		return fmt.Errorf("max_categories must not be negative")
+	}
+	if c.Buckets < 0 {
+		return fmt.Errorf("buckets must not be negative")
+	}
+	return nil
+}
+
+// ColumnNames returns the columns listed in Columns, sorted.
+func (c EncodingConfig) ColumnNames() []string {
+	names := make([]string, 0, len(c.Columns))
+	for name := range c.Columns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ColumnEncoder is the fitted encoding of one categorical column.
+type ColumnEncoder struct {
+	Column string   `json:"column"`
+	Method Encoding `json:"method"`
+	// Categories is the vocabulary of one-hot, ordinal and frequency
+	// encodings: the most frequent categories for one-hot encodings, and
+	// every category, sorted, for the others.
+	Categories []string `json:"categories,omitempty"`
+	// Frequencies holds the fraction of rows holding each category of
+	// frequency encodings.
+	Frequencies []float64 `json:"frequencies,omitempty"`
+	// Buckets is the number of features of hashing encodings.
+	Buckets int `json:"buckets,omitempty"`
+
+	index map[string]int
+}
+
+// Encoder encodes categorical columns with vocabularies fitted to a
+// dataset. It implements dataset.Encoder, and, saved to a file, encodes
+// the dataset alike when it is defended and in later scans, where unseen
+// categories encode like no category rather than growing the vocabulary.
+type Encoder struct {
+	Columns []ColumnEncoder `json:"columns"`
+
+	byName map[string]*ColumnEncoder
+}
+
+// FitEncoder fits the encoding selected by cfg of the named categorical
+// columns to counts, the number of rows holding each value of each column
+// as counted by dataset.CountCategories.
+func FitEncoder(cfg EncodingConfig, columns []string, counts map[string]map[string]int) (*Encoder, error) {
+	if err := cfg.Check(); err != nil {
+		return nil, err
+	}
+	maxCategories := cfg.MaxCategories
+	if maxCategories == 0 {
+		maxCategories = DefaultMaxCategories
+	}
+	buckets := cfg.Buckets
+	if buckets == 0 {
+		buckets = DefaultBuckets
+	}
+
+	e := &Encoder{}
+	for _, column := range columns {
+		values, ok := counts[column]
+		if !ok {
+			return nil, fmt.Errorf("no categorical column %q to encode", column)
+		}
+		method, ok := cfg.Columns[column]
+		if !ok {
+			method = cfg.Method
+		}
+		method, _ = ParseEncoding(string(method))
+
+		c := ColumnEncoder{Column: column, Method: method}
+		switch method {
+		case OneHot:
+			c.Categories = sortedCategories(values, true)
+			if len(c.Categories) > maxCategories {
+				c.Categories = c.Categories[:maxCategories]
+			}
+		case Ordinal:
+			c.Categories = sortedCategories(values, false)
+		case Frequency:
+			c.Categories = sortedCategories(values, false)
+			total := 0
+			for _, n := range values {
+				total += n
+			}
+			for _, category := range c.Categories {
+				c.Frequencies = append(c.Frequencies, float64(values[category])/float64(total))
+			}
+		case Hashing:
+			c.Buckets = buckets
+		}
+		e.Columns = append(e.Columns, c)
+	}
+	e.index()
+	return e, nil
+}
+
+// sortedCategories returns the categories counted in values, by value or,
+// with byCount, from the most to the least frequent.
+func sortedCategories(values map[string]int, byCount bool) []string {
+	categories := make([]string, 0, len(values))
+	for category := range values {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		a, b := categories[i], categories[j]
+		if byCount && values[a] != values[b] {
+			return values[a] > values[b]
+		}
+		return a < b
+	})
+	return categories
+}
+
+// index builds the lookup tables of the encoder.
+func (e *Encoder) index() {
+	e.byName = make(map[string]*ColumnEncoder, len(e.Columns))
+	for i := range e.Columns {
+		c := &e.Columns[i]
+		c.index = make(map[string]int, len(c.Categories))
+		for k, category := range c.Categories {
+			c.index[category] = k
+		}
+		e.byName[c.Column] = c
+	}
+}
+
+// Categorical returns the names of the encoded columns.
+func (e *Encoder) Categorical() []string {
+	names := make([]string, len(e.Columns))
+	for i, c := range e.Columns {
+		names[i] = c.Column
+	}
+	return names
+}
+
+// Features returns the names of the features encoding column:
+// column=category for one-hot encodings, column#bucket for hashing
+// encodings and the column's name for the others.
+func (e *Encoder) Features(column string) []string {
+	c, ok := e.byName[column]
+	if !ok {
+		return nil
+	}
+	switch c.Method {
+	case OneHot:
+		names := make([]string, len(c.Categories))
+		for k, category := range c.Categories {
+			names[k] = column + "=" + category
+		}
+		return names
+	case Hashing:
+		names := make([]string, c.Buckets)
+		for k := range names {
+			names[k] = column + "#" + strconv.Itoa(k)
+		}
+		return names
+	}
+	return []string{column}
+}
+
+// Encode appends the features encoding a value of column to dst. Missing
+// values encode as NaN. Unseen categories encode as zeros in one-hot
+// encodings, -1 in ordinal ones and 0 in frequency ones.
+func (e *Encoder) Encode(column, value string, dst []float64) []float64 {
+	c, ok := e.byName[column]
+	if !ok {
+		return dst
+	}
+	width := 1
+	switch c.Method {
+	case OneHot:
+		width = len(c.Categories)
+	case Hashing:
+		width = c.Buckets
+	}
+	if value == "" {
+		for k := 0; k < width; k++ {
+			dst = append(dst, math.NaN())
+		}
+		return dst
+	}
+
+	k, seen := c.index[value]
+	switch c.Method {
+	case Ordinal:
+		if !seen {
+			k = -1
+		}
+		return append(dst, float64(k))
+	case Frequency:
+		if !seen {
+			return append(dst, 0)
+		}
+		return append(dst, c.Frequencies[k])
+	case Hashing:
+		k, seen = int(hashCategory(value)%uint32(c.Buckets)), true
+	}
+	for j := 0; j < width; j++ {
+		if seen && j == k {
+			dst = append(dst, 1)
+		} else {
+			dst = append(dst, 0)
+		}
+	}
+	return dst
+}
+
+// hashCategory returns the 32-bit FNV-1a hash of a category.
+func hashCategory(value string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(value); i++ {
+		h ^= uint32(value[i])
+		h *= 16777619
+	}
+	return h
+}
+
+// Write writes the encoder as JSON.
+func (e *Encoder) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(e)
+}
+
+// Save writes the encoder to a JSON file.
+func (e *Encoder) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := e.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadEncoder reads an encoder written by Write.
+func ReadEncoder(r io.Reader) (*Encoder, error) {
+	var e Encoder
+	if err := json.NewDecoder(r).Decode(&e); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(e.Columns))
+	for i, c := range e.Columns {
+		method, err := ParseEncoding(string(c.Method))
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", c.Column, err)
+		}
+		switch {
+		case c.Column == "" || seen[c.Column]:
+			return nil, fmt.Errorf("column %d: missing or repeated name %q", i+1, c.Column)
+		case method == Frequency && len(c.Frequencies) != len(c.Categories):
+			return nil, fmt.Errorf("column %q: %d frequencies for %d categories", c.Column, len(c.Frequencies), len(c.Categories))
+		case method == Hashing && c.Buckets < 1:
+			return nil, fmt.Errorf("column %q: invalid bucket count %d", c.Column, c.Buckets)
+		}
+		seen[c.Column] = true
+		e.Columns[i].Method = method
+	}
+	e.index()
+	return &e, nil
+}
+
+// LoadEncoder reads an encoder from a JSON file.
+func LoadEncoder(path string) (*Encoder, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	e, err := ReadEncoder(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return e, nil
+}
@@ -0,0 +1,91 @@
+package preprocess
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+)
+
+func TestEncoder(t *testing.T) {
+	const csv = "amount,color,size,city,shop,label\n" +
+		"1,red,S,paris,a,0\n" +
+		"2,blue,M,rome,b,0\n" +
+		"3,red,L,paris,a,1\n" +
+		"4,NA,M,oslo,a,1\n"
+	cols := dataset.Columns{Categorical: []string{"color", "size", "city", "shop"}}
+	counts, err := dataset.CountCategories(strings.NewReader(csv), cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"red": 2, "blue": 1}; !reflect.DeepEqual(counts["color"], want) {
+		t.Errorf("color counts %v, want %v", counts["color"], want)
+	}
+
+	cfg := EncodingConfig{
+		Columns: map[string]Encoding{"size": Ordinal, "city": Frequency, "shop": Hashing},
+		Buckets: 4,
+	}
+	encoder, err := FitEncoder(cfg, cols.Categorical, counts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := encoder.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if encoder, err = ReadEncoder(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	cols.Encoder = encoder
+	data, err := dataset.ReadCSVColumns(strings.NewReader(csv), cols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantNames := []string{"amount", "color=red", "color=blue", "size", "city", "shop#0", "shop#1", "shop#2", "shop#3"}
+	if !reflect.DeepEqual(data.Features, wantNames) {
+		t.Fatalf("features %q, want %q", data.Features, wantNames)
+	}
+	row := data.Samples[0].Features
+	if row[0] != 1 || row[1] != 1 || row[2] != 0 {
+		t.Errorf("one-hot: %v", row[:3])
+	}
+	// Ordinal codes follow the sorted vocabulary L, M, S.
+	if row[3] != 2 || data.Samples[2].Features[3] != 0 {
+		t.Errorf("ordinal: %v and %v", row[3], data.Samples[2].Features[3])
+	}
+	if row[4] != 0.5 || data.Samples[3].Features[4] != 0.25 {
+		t.Errorf("frequency: %v and %v", row[4], data.Samples[3].Features[4])
+	}
+	hashed := 0.0
+	for _, v := range row[5:] {
+		hashed += v
+	}
+	if hashed != 1 || !reflect.DeepEqual(row[5:], data.Samples[2].Features[5:]) {
+		t.Errorf("hashing: %v and %v", row[5:], data.Samples[2].Features[5:])
+	}
+	if missing := data.Samples[3].Features; !math.IsNaN(missing[1]) || !math.IsNaN(missing[2]) {
+		t.Errorf("missing category: %v", missing[1:3])
+	}
+	if data.Records[0][1] != "red" {
+		t.Errorf("record %q, want the original values", data.Records[0])
+	}
+
+	unseen := encoder.Encode("color", "green", nil)
+	unseen = encoder.Encode("size", "XL", unseen)
+	unseen = encoder.Encode("city", "lima", unseen)
+	if want := []float64{0, 0, -1, 0}; !reflect.DeepEqual(unseen, want) {
+		t.Errorf("unseen categories encode as %v, want %v", unseen, want)
+	}
+
+	if _, err := FitEncoder(cfg, []string{"shape"}, counts); err == nil {
+		t.Error("FitEncoder accepted an uncounted column")
+	}
+	if _, err := dataset.ReadCSVColumns(strings.NewReader(csv), dataset.Columns{Categorical: []string{"color"}}); err == nil {
+		t.Error("categorical columns were read without an encoder")
+	}
+}
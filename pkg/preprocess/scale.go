@@ -0,0 +1,271 @@
+// Package preprocess transforms dataset features before detection: it
+// encodes categorical columns as numeric features, and scales features so
+// those on very different scales weigh alike in the detector's checks.
+package preprocess
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/stats"
+)
+
+// Method is a feature scaling method.
+type Method string
+
+const (
+	// None leaves a feature unchanged.
+	None Method = "none"
+	// ZScore centers a feature on its mean and divides by its standard
+	// deviation.
+	ZScore Method = "zscore"
+	// MinMax maps a feature's range onto [0, 1].
+	MinMax Method = "minmax"
+	// Robust centers a feature on its median and divides by its
+	// interquartile range, so outliers, poisoned or not, do not set the
+	// scale.
+	Robust Method = "robust"
+)
+
+// Methods returns the scaling methods.
+func Methods() []Method {
+	return []Method{None, ZScore, MinMax, Robust}
+}
+
+// ParseMethod parses a scaling method name. The empty name is None.
+func ParseMethod(name string) (Method, error) {
+	if name == "" {
+		return None, nil
+	}
+	for _, m := range Methods() {
+		if strings.EqualFold(name, string(m)) {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("unknown scaling method %q (want none, zscore, minmax or robust)", name)
+}
+
+// Config selects how features are scaled.
+type Config struct {
+	// Method scales every feature not listed in Features.
+	Method Method `yaml:"method,omitempty" json:"method,omitempty"`
+	// Features maps feature names to the method scaling them.
+	Features map[string]Method `yaml:"features,omitempty" json:"features,omitempty"`
+}
+
+// Check reports unknown methods.
+func (c Config) Check() error {
+	if _, err := ParseMethod(string(c.Method)); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(c.Features))
+	for name := range c.Features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, err := ParseMethod(string(c.Features[name])); err != nil {
+			return fmt.Errorf("feature %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Enabled reports whether the configuration scales any feature.
+func (c Config) Enabled() bool {
+	if m, _ := ParseMethod(string(c.Method)); m != None {
+		return true
+	}
+	for _, method := range c.Features {
+		if m, _ := ParseMethod(string(method)); m != None {
+			return true
+		}
+	}
+	return false
+}
+
+// Feature is the fitted transform of one feature: values become
+// (value - Center) / Scale.
+type Feature struct {
+	Name   string  `json:"name,omitempty"`
+	Method Method  `json:"method"`
+	Center float64 `json:"center"`
+	Scale  float64 `json:"scale"`
+}
+
+// Scaler is a transform fitted to a dataset. Saved to a file, it applies
+// the same transform when the dataset is defended and in later scans,
+// whose own statistics would otherwise shift the scale.
+type Scaler struct {
+	Features []Feature `json:"features"`
+}
+
+// Fit fits the scaling selected by cfg to the features of a profile. Every
+// feature named in cfg.Features must be in the profile. Features without
+// finite values, or constant ones, are centered but not scaled.
+func Fit(cfg Config, profile *stats.Profile) (*Scaler, error) {
+	if err := cfg.Check(); err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool, len(profile.Features))
+	for _, f := range profile.Features {
+		known[f.Name] = true
+	}
+	for name := range cfg.Features {
+		if !known[name] {
+			return nil, fmt.Errorf("no feature %q to scale", name)
+		}
+	}
+
+	s := &Scaler{Features: make([]Feature, len(profile.Features))}
+	for i, f := range profile.Features {
+		method, ok := cfg.Features[f.Name]
+		if !ok {
+			method = cfg.Method
+		}
+		method, _ = ParseMethod(string(method))
+		s.Features[i] = fit(method, f)
+	}
+	return s, nil
+}
+
+// FitSamples fits the scaling selected by cfg to samples; names gives the
+// feature names.
+func FitSamples(cfg Config, names []string, samples []detect.Sample) (*Scaler, error) {
+	return Fit(cfg, stats.ProfileSamples(names, samples))
+}
+
+func fit(method Method, f stats.FeatureStats) Feature {
+	feature := Feature{Name: f.Name, Method: method, Scale: 1}
+	if f.Count == 0 {
+		return feature
+	}
+	switch method {
+	case ZScore:
+		feature.Center, feature.Scale = f.Mean, f.StdDev
+	case MinMax:
+		feature.Center, feature.Scale = f.Min, f.Max-f.Min
+	case Robust:
+		feature.Center, feature.Scale = f.Median, f.P75-f.P25
+	}
+	if feature.Scale == 0 || math.IsNaN(feature.Scale) {
+		feature.Scale = 1
+	}
+	return feature
+}
+
+// Check reports a scaler fitted to other features than names, such as a
+// saved scaler applied to a dataset with other columns.
+func (s *Scaler) Check(names []string) error {
+	if len(names) != len(s.Features) {
+		return fmt.Errorf("scaler has %d features, dataset has %d", len(s.Features), len(names))
+	}
+	for i, f := range s.Features {
+		if f.Name != "" && names[i] != "" && f.Name != names[i] {
+			return fmt.Errorf("scaler feature %d is %q, dataset feature is %q", i+1, f.Name, names[i])
+		}
+	}
+	return nil
+}
+
+// Transform returns copies of samples with their features scaled, float32
+// samples staying float32. NaN and infinite values stay as they are, and
+// features beyond the scaler's are copied unchanged.
+func (s *Scaler) Transform(samples []detect.Sample) []detect.Sample {
+	width, width32 := 0, 0
+	for _, sample := range samples {
+		width += len(sample.Features)
+		width32 += len(sample.Features32)
+	}
+	values := make([]float64, 0, width)
+	var values32 []float32
+	if width32 > 0 {
+		values32 = make([]float32, 0, width32)
+	}
+	scaled := make([]detect.Sample, len(samples))
+	for i, sample := range samples {
+		if sample.Features32 != nil {
+			start := len(values32)
+			values32 = scaleRow(s, values32, sample.Features32)
+			sample.Features32 = values32[start:len(values32):len(values32)]
+		} else {
+			start := len(values)
+			values = scaleRow(s, values, sample.Features)
+			sample.Features = values[start:len(values):len(values)]
+		}
+		scaled[i] = sample
+	}
+	return scaled
+}
+
+// scaleRow appends the features of one row, scaled by s, to dst.
+func scaleRow[T float32 | float64](s *Scaler, dst, features []T) []T {
+	for j, v := range features {
+		if j < len(s.Features) && s.Features[j].Method != None {
+			f := s.Features[j]
+			v = T((float64(v) - f.Center) / f.Scale)
+		}
+		dst = append(dst, v)
+	}
+	return dst
+}
+
+// Write writes the scaler as JSON.
+func (s *Scaler) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// Save writes the scaler to a JSON file.
+func (s *Scaler) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := s.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Read reads a scaler written by Write.
+func Read(r io.Reader) (*Scaler, error) {
+	var s Scaler
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, err
+	}
+	for i, f := range s.Features {
+		method, err := ParseMethod(string(f.Method))
+		if err != nil {
+			return nil, fmt.Errorf("feature %d: %w", i+1, err)
+		}
+		if method != None && (f.Scale == 0 || math.IsNaN(f.Scale) || math.IsInf(f.Scale, 0)) {
+			return nil, fmt.Errorf("feature %d: invalid scale %v", i+1, f.Scale)
+		}
+		s.Features[i].Method = method
+	}
+	return &s, nil
+}
+
+// Load reads a scaler from a JSON file.
+func Load(path string) (*Scaler, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
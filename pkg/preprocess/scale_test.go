@@ -0,0 +1,75 @@
+package preprocess
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func TestScaler(t *testing.T) {
+	names := []string{"a", "b", "c", "d"}
+	var samples []detect.Sample
+	for i := 0; i < 5; i++ {
+		v := float64(i)
+		samples = append(samples, detect.Sample{Features: []float64{v, 10 * v, 7, v}})
+	}
+	samples[4].Features[3] = 100
+	samples = append(samples, detect.Sample{Features: []float64{math.NaN(), 0, 7}})
+
+	cfg := Config{Method: ZScore, Features: map[string]Method{"b": MinMax, "d": Robust}}
+	scaler, err := FitSamples(cfg, names, samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scaled := scaler.Transform(samples)
+
+	// a: mean 2, standard deviation sqrt(2).
+	if got, want := scaled[4].Features[0], 2/math.Sqrt2; math.Abs(got-want) > 1e-9 {
+		t.Errorf("zscore: %v, want %v", got, want)
+	}
+	// b: [0, 40] onto [0, 1].
+	if got := scaled[2].Features[1]; math.Abs(got-0.5) > 1e-9 {
+		t.Errorf("minmax: %v, want 0.5", got)
+	}
+	// c is constant: centered, not scaled.
+	if got := scaled[0].Features[2]; got != 0 {
+		t.Errorf("constant feature: %v, want 0", got)
+	}
+	// d: median 2 and interquartile range 2, whatever the outlier.
+	if got := scaled[4].Features[3]; math.Abs(got-49) > 1e-9 {
+		t.Errorf("robust: %v, want 49", got)
+	}
+	if !math.IsNaN(scaled[5].Features[0]) || len(scaled[5].Features) != 3 {
+		t.Errorf("short sample with NaN: %v", scaled[5].Features)
+	}
+	if samples[4].Features[0] != 4 {
+		t.Error("Transform modified its input")
+	}
+
+	var buf bytes.Buffer
+	if err := scaler.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Transform(samples[4:5])[0].Features; got[3] != scaled[4].Features[3] {
+		t.Errorf("loaded scaler: %v, want %v", got, scaled[4].Features)
+	}
+	if err := loaded.Check(names); err != nil {
+		t.Error(err)
+	}
+	if err := loaded.Check([]string{"a", "b", "x", "d"}); err == nil {
+		t.Error("Check accepted other features")
+	}
+
+	if _, err := FitSamples(Config{Features: map[string]Method{"x": ZScore}}, names, samples); err == nil {
+		t.Error("Fit accepted an unknown feature")
+	}
+	if err := (Config{Method: "log"}).Check(); err == nil {
+		t.Error("Check accepted an unknown method")
+	}
+}
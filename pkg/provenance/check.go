@@ -0,0 +1,121 @@
+package provenance
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Scores of the provenance anomalies, above the check's threshold of 0.5.
+const (
+	scoreUnattested = 0.6
+	scoreRareAgent  = 0.7
+	scoreUndeclared = 0.8
+	scoreTimeline   = 0.9
+)
+
+// rareShare is the share of the attributed entities below which an agent
+// counts as a rare contributor, when there are at least minAttributed.
+const (
+	rareShare     = 0.01
+	minAttributed = 100
+)
+
+// Check flags samples whose provenance is anomalous:
+//
+//   - attributed to, or generated by an activity of, an agent the document
+//     does not declare;
+//   - derived from an entity the document does not declare;
+//   - generated before an entity it was derived from, or outside the start
+//     and end of the activity generating it;
+//   - from an agent contributing under 1% of the attributed entities of the
+//     document, a one-off contributor among established ones;
+//   - without provenance, when the document has records for most samples.
+//
+// Check implements detect.ExternalCheck.
+type Check struct {
+	doc *Document
+	// unattested reports whether samples without records are flagged.
+	unattested bool
+}
+
+// NewCheck returns the check of the provenance recorded in doc. samples,
+// if set, are the samples scanned: samples without records are flagged
+// when most of them have records. Streamed scans, whose samples are not
+// known beforehand, pass nil.
+func (d *Document) NewCheck(samples []detect.Sample) *Check {
+	return &Check{doc: d, unattested: samples != nil && d.Coverage(samples) >= 0.5}
+}
+
+// Info implements detect.ExternalCheck.
+func (c *Check) Info() detect.CheckInfo {
+	return detect.CheckInfo{
+		Name:        "provenance",
+		Type:        detect.TypeDataPoison,
+		Description: "Sample with anomalous provenance",
+		Method:      "W3C PROV lineage consistency and contributor analysis",
+		DataTypes:   []string{"tabular", "image", "text", "embeddings"},
+	}
+}
+
+// Threshold implements detect.ExternalCheck.
+func (c *Check) Threshold() float64 { return 0.5 }
+
+// Score implements detect.ExternalCheck.
+func (c *Check) Score(samples []detect.Sample) []detect.ExternalScore {
+	scores := make([]detect.ExternalScore, len(samples))
+	for i, sample := range samples {
+		scores[i] = c.score(sample.ID)
+	}
+	return scores
+}
+
+// score scores the sample with ID id, explaining each anomaly found.
+func (c *Check) score(id string) detect.ExternalScore {
+	d := c.doc
+	e := d.entity(id)
+	if e == "" {
+		if c.unattested {
+			return detect.ExternalScore{Score: scoreUnattested, Evidence: "no provenance record"}
+		}
+		return detect.ExternalScore{}
+	}
+	origin := d.origins[e]
+
+	var result detect.ExternalScore
+	var evidence []string
+	anomaly := func(score float64, format string, args ...interface{}) {
+		result.Score = max(result.Score, score)
+		evidence = append(evidence, fmt.Sprintf(format, args...))
+	}
+	for _, agent := range []string{origin.Agent, origin.OnBehalfOf} {
+		if agent != "" && !d.agents[agent] {
+			anomaly(scoreUndeclared, "agent %s is not declared", agent)
+		}
+	}
+	if origin.Activity != "" {
+		if _, ok := d.activities[origin.Activity]; !ok {
+			anomaly(scoreUndeclared, "activity %s is not declared", origin.Activity)
+		}
+	}
+
+	generated, dated := d.generated[e]
+	for _, source := range origin.DerivedFrom {
+		if _, ok := d.origins[source]; !ok {
+			anomaly(scoreUndeclared, "derived from undeclared entity %s", source)
+		} else if at, ok := d.generated[source]; dated && ok && generated.Before(at) {
+			anomaly(scoreTimeline, "generated at %s, before its source %s (%s)", origin.GeneratedAt, source, d.origins[source].GeneratedAt)
+		}
+	}
+	if a := d.activities[origin.Activity]; dated && (!a.start.IsZero() && generated.Before(a.start) || !a.end.IsZero() && generated.After(a.end)) {
+		anomaly(scoreTimeline, "generated at %s, outside the run of %s", origin.GeneratedAt, origin.Activity)
+	}
+
+	if n := d.counts[origin.Agent]; n > 0 && len(d.counts) > 1 && d.attributed >= minAttributed && float64(n) < rareShare*float64(d.attributed) {
+		anomaly(scoreRareAgent, "agent %s contributed only %d of %d entities", origin.Agent, n, d.attributed)
+	}
+
+	result.Evidence = strings.Join(evidence, "; ")
+	return result
+}
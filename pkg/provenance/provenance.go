@@ -0,0 +1,292 @@
+// Package provenance reads W3C PROV-JSON provenance attached to datasets to
+// trace samples to the pipeline steps and agents that produced them, flag
+// samples whose provenance is anomalous and enrich findings with their
+// origin.
+//
+// Samples are matched to PROV entities by ID, with or without the
+// entity's namespace prefix: sample "s42" matches entity "s42" or
+// "ex:s42". The entity named like the dataset file, such as "ex:train.csv",
+// supplies the origin of samples without records of their own.
+package provenance
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// record is a PROV element or relation: its attributes by qualified name.
+type record map[string]json.RawMessage
+
+// document is the part of a PROV-JSON document read by Load.
+type document struct {
+	Entity            map[string]record `json:"entity"`
+	Activity          map[string]record `json:"activity"`
+	Agent             map[string]record `json:"agent"`
+	WasGeneratedBy    map[string]record `json:"wasGeneratedBy"`
+	WasAttributedTo   map[string]record `json:"wasAttributedTo"`
+	WasAssociatedWith map[string]record `json:"wasAssociatedWith"`
+	WasDerivedFrom    map[string]record `json:"wasDerivedFrom"`
+	ActedOnBehalfOf   map[string]record `json:"actedOnBehalfOf"`
+}
+
+// activity is an activity and the agent and plan associated with it.
+type activity struct {
+	start, end time.Time
+	agent      string
+	plan       string
+}
+
+// Document is the provenance of the entities of a PROV-JSON document.
+type Document struct {
+	origins    map[string]*detect.Origin
+	generated  map[string]time.Time
+	activities map[string]activity
+	agents     map[string]bool
+	// byLocal maps the local names of entities to their IDs, or to "" when
+	// several entities share the name.
+	byLocal map[string]string
+	// counts is the number of entities attributed to each agent, and
+	// attributed their total.
+	counts     map[string]int
+	attributed int
+}
+
+// Load reads the PROV-JSON document at path.
+func Load(path string) (*Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return d, nil
+}
+
+// Sidecar returns the path of the provenance sidecar of the dataset at
+// path, path with ".prov.json" appended, or "" if there is none.
+func Sidecar(path string) string {
+	if _, err := os.Stat(path + ".prov.json"); err != nil {
+		return ""
+	}
+	return path + ".prov.json"
+}
+
+// Read reads a PROV-JSON document. Bundles are not read.
+func Read(r io.Reader) (*Document, error) {
+	var doc document
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode PROV-JSON: %w", err)
+	}
+	if len(doc.Entity) == 0 {
+		return nil, fmt.Errorf("PROV-JSON document has no entities")
+	}
+
+	d := &Document{
+		origins:    make(map[string]*detect.Origin, len(doc.Entity)),
+		generated:  make(map[string]time.Time),
+		activities: make(map[string]activity, len(doc.Activity)),
+		agents:     make(map[string]bool, len(doc.Agent)),
+		byLocal:    make(map[string]string, len(doc.Entity)),
+		counts:     make(map[string]int),
+	}
+	for id := range doc.Agent {
+		d.agents[id] = true
+	}
+	for id, attrs := range doc.Activity {
+		d.activities[id] = activity{start: attrs.time("prov:startTime"), end: attrs.time("prov:endTime")}
+	}
+	for id := range doc.Entity {
+		d.origins[id] = &detect.Origin{Entity: id}
+		local := localName(id)
+		if other, ok := d.byLocal[local]; ok && other != id {
+			d.byLocal[local] = ""
+		} else {
+			d.byLocal[local] = id
+		}
+	}
+
+	// Relations are applied in ID order, so the first of several
+	// generations or attributions of an entity wins whatever the order of
+	// the map.
+	for _, rel := range sorted(doc.WasAssociatedWith) {
+		a, ok := d.activities[rel.str("prov:activity")]
+		if !ok || a.agent != "" {
+			continue
+		}
+		a.agent, a.plan = rel.str("prov:agent"), rel.str("prov:plan")
+		d.activities[rel.str("prov:activity")] = a
+	}
+	onBehalfOf := make(map[string]string)
+	for _, rel := range sorted(doc.ActedOnBehalfOf) {
+		if delegate := rel.str("prov:delegate"); onBehalfOf[delegate] == "" {
+			onBehalfOf[delegate] = rel.str("prov:responsible")
+		}
+	}
+	for _, rel := range sorted(doc.WasGeneratedBy) {
+		origin := d.origins[rel.str("prov:entity")]
+		if origin == nil || origin.Activity != "" {
+			continue
+		}
+		origin.Activity = rel.str("prov:activity")
+		at := rel.time("prov:time")
+		if at.IsZero() {
+			at = d.activities[origin.Activity].end
+		}
+		if !at.IsZero() {
+			d.generated[origin.Entity] = at
+			origin.GeneratedAt = at.UTC().Format(time.RFC3339)
+		}
+	}
+	for _, rel := range sorted(doc.WasAttributedTo) {
+		if origin := d.origins[rel.str("prov:entity")]; origin != nil && origin.Agent == "" {
+			origin.Agent = rel.str("prov:agent")
+		}
+	}
+	for _, rel := range sorted(doc.WasDerivedFrom) {
+		if origin := d.origins[rel.str("prov:generatedEntity")]; origin != nil {
+			origin.DerivedFrom = append(origin.DerivedFrom, rel.str("prov:usedEntity"))
+		}
+	}
+
+	for _, origin := range d.origins {
+		a := d.activities[origin.Activity]
+		if origin.Agent == "" {
+			origin.Agent = a.agent
+		}
+		origin.Plan = a.plan
+		origin.OnBehalfOf = onBehalfOf[origin.Agent]
+		if origin.Agent != "" {
+			d.counts[origin.Agent]++
+			d.attributed++
+		}
+	}
+	return d, nil
+}
+
+// sorted returns the relations of m in ID order.
+func sorted(m map[string]record) []record {
+	ids := make([]string, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	relations := make([]record, len(ids))
+	for i, id := range ids {
+		relations[i] = m[id]
+	}
+	return relations
+}
+
+// str returns the attribute name of r as a string: a plain string or the
+// value of a typed literal such as {"$": "ex:a", "type": "prov:QUALIFIED_NAME"}.
+// Multi-valued attributes return their first value.
+func (r record) str(name string) string {
+	raw, ok := r[name]
+	if !ok {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var literal struct {
+		Value string `json:"$"`
+	}
+	if json.Unmarshal(raw, &literal) == nil {
+		return literal.Value
+	}
+	var values []json.RawMessage
+	if json.Unmarshal(raw, &values) == nil && len(values) > 0 {
+		return record{name: values[0]}.str(name)
+	}
+	return ""
+}
+
+// time returns the attribute name of r as a time, or the zero time if it
+// is not an xsd:dateTime.
+func (r record) time(name string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, r.str(name))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// localName returns id without its namespace prefix.
+func localName(id string) string {
+	if _, local, ok := strings.Cut(id, ":"); ok {
+		return local
+	}
+	return id
+}
+
+// entity returns the ID of the entity describing the sample or dataset
+// named id, or "" if there is none.
+func (d *Document) entity(id string) string {
+	if _, ok := d.origins[id]; ok {
+		return id
+	}
+	return d.byLocal[id]
+}
+
+// Origin returns the origin of the sample with ID id, or nil if the
+// document has no entity for it.
+func (d *Document) Origin(id string) *detect.Origin {
+	if e := d.entity(id); e != "" {
+		origin := *d.origins[e]
+		return &origin
+	}
+	return nil
+}
+
+// Dataset returns the origin of the dataset at path, read from the entity
+// named like its file, or nil if the document has none.
+func (d *Document) Dataset(path string) *detect.Origin {
+	return d.Origin(filepath.Base(path))
+}
+
+// Coverage returns the fraction of samples the document has entities for.
+func (d *Document) Coverage(samples []detect.Sample) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	found := 0
+	for _, s := range samples {
+		if d.entity(s.ID) != "" {
+			found++
+		}
+	}
+	return float64(found) / float64(len(samples))
+}
+
+// Enrich sets the origin of the flagged findings of result, falling back
+// to dataset, if set, for samples without records. It returns the number
+// of findings enriched.
+func (d *Document) Enrich(result *detect.DetectionResult, dataset *detect.Origin) int {
+	n := 0
+	for i := range result.Samples {
+		finding := &result.Samples[i]
+		if !finding.IsPoisoned {
+			continue
+		}
+		if finding.Origin = d.Origin(finding.ID); finding.Origin == nil && dataset != nil {
+			origin := *dataset
+			finding.Origin = &origin
+		}
+		if finding.Origin != nil {
+			n++
+		}
+	}
+	return n
+}
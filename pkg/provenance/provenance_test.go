@@ -0,0 +1,101 @@
+package provenance
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// testDocument returns a PROV-JSON document recording s0 to s199 as
+// generated by a labeling run from ex:raw, with a few anomalies.
+func testDocument() string {
+	var entities, generated, derived []string
+	for i := 0; i < 200; i++ {
+		at := "2024-03-01T12:00:00Z"
+		switch i {
+		case 3:
+			at = "2024-02-01T00:00:00Z" // before ex:raw
+		case 4:
+			at = "2024-04-01T00:00:00Z" // after the run
+		}
+		entities = append(entities, fmt.Sprintf(`"ex:s%d": {}`, i))
+		generated = append(generated, fmt.Sprintf(`"_:g%d": {"prov:entity": "ex:s%d", "prov:activity": "ex:label", "prov:time": %q}`, i, i, at))
+		derived = append(derived, fmt.Sprintf(`"_:d%d": {"prov:generatedEntity": "ex:s%d", "prov:usedEntity": "ex:raw"}`, i, i))
+	}
+	return `{
+  "prefix": {"ex": "http://example.com/"},
+  "entity": {"ex:raw": {}, "ex:train.csv": {}, ` + strings.Join(entities, ", ") + `},
+  "activity": {
+    "ex:ingest": {"prov:endTime": "2024-02-15T00:00:00Z"},
+    "ex:label": {"prov:startTime": "2024-03-01T00:00:00Z", "prov:endTime": "2024-03-02T00:00:00Z"}
+  },
+  "agent": {"ex:vendor": {}, "ex:acme": {}, "ex:intern": {}},
+  "wasGeneratedBy": {"_:raw": {"prov:entity": "ex:raw", "prov:activity": "ex:ingest"}, ` + strings.Join(generated, ", ") + `},
+  "wasAssociatedWith": {"_:w": {"prov:activity": "ex:label", "prov:agent": "ex:vendor", "prov:plan": "ex:pipeline"}},
+  "actedOnBehalfOf": {"_:b": {"prov:delegate": "ex:vendor", "prov:responsible": "ex:acme"}},
+  "wasAttributedTo": {
+    "_:a1": {"prov:entity": "ex:s1", "prov:agent": "ex:intern"},
+    "_:a2": {"prov:entity": "ex:s2", "prov:agent": {"$": "ex:mallory", "type": "prov:QUALIFIED_NAME"}}
+  },
+  "wasDerivedFrom": {` + strings.Join(derived, ", ") + `}
+}`
+}
+
+func TestOrigin(t *testing.T) {
+	d, err := Read(strings.NewReader(testDocument()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &detect.Origin{
+		Entity:      "ex:s0",
+		Activity:    "ex:label",
+		Plan:        "ex:pipeline",
+		Agent:       "ex:vendor",
+		OnBehalfOf:  "ex:acme",
+		GeneratedAt: "2024-03-01T12:00:00Z",
+		DerivedFrom: []string{"ex:raw"},
+	}
+	if got := d.Origin("s0"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Origin(s0) = %+v, want %+v", got, want)
+	}
+	if got := d.Dataset("data/train.csv"); got == nil || got.Entity != "ex:train.csv" {
+		t.Errorf("Dataset = %+v", got)
+	}
+	if got := d.Origin("s999"); got != nil {
+		t.Errorf("Origin(s999) = %+v, want nil", got)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	d, err := Read(strings.NewReader(testDocument()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := make([]detect.Sample, 201)
+	for i := range samples {
+		samples[i] = detect.Sample{ID: fmt.Sprintf("s%d", i)}
+	}
+
+	scores := d.NewCheck(samples).Score(samples)
+	want := map[int]string{
+		1:   "agent ex:intern contributed only 1 of 200 entities",
+		2:   "agent ex:mallory is not declared",
+		3:   "before its source ex:raw",
+		4:   "outside the run of ex:label",
+		200: "no provenance record",
+	}
+	for i, score := range scores {
+		evidence, ok := want[i]
+		if flagged := score.Score > 0.5; flagged != ok || !strings.Contains(score.Evidence, evidence) {
+			t.Errorf("sample %d: %+v, want %q", i, score, evidence)
+		}
+	}
+
+	// Streamed samples without records are not flagged.
+	if score := d.NewCheck(nil).Score(samples[200:]); score[0].Score != 0 {
+		t.Errorf("streamed unattested sample: %+v", score[0])
+	}
+}
@@ -0,0 +1,212 @@
+// Package quarantine stores samples removed from datasets by defenses so
+// they can be reviewed, restored or purged.
+package quarantine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Status represents the state of a quarantined sample.
+type Status string
+
+const (
+	StatusQuarantined Status = "quarantined"
+	StatusRestored    Status = "restored"
+)
+
+// indexFile is the name of the store index within the quarantine directory.
+const indexFile = "index.json"
+
+// Entry is a quarantined dataset row.
+type Entry struct {
+	ID            string    `json:"id"`
+	SampleID      string    `json:"sample_id"`
+	Dataset       string    `json:"dataset"`
+	Destination   string    `json:"destination,omitempty"`
+	Header        []string  `json:"header"`
+	Record        []string  `json:"record"`
+	Reason        string    `json:"reason"`
+	Type          string    `json:"type,omitempty"`
+	Score         float64   `json:"score"`
+	Description   string    `json:"description,omitempty"`
+	Status        Status    `json:"status"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+	RestoredAt    time.Time `json:"restored_at,omitempty"`
+	Justification string    `json:"justification,omitempty"`
+	Reviewer      string    `json:"reviewer,omitempty"`
+}
+
+// Store is a directory-backed quarantine.
+type Store struct {
+	dir     string
+	entries []Entry
+}
+
+// Open opens the quarantine in dir, creating it if needed.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	store := &Store{dir: dir}
+
+	data, err := os.ReadFile(filepath.Join(dir, indexFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &store.entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Join(dir, indexFile), err)
+	}
+
+	return store, nil
+}
+
+// Save writes the store index.
+func (s *Store) Save() error {
+	data, err := json.MarshalIndent(s.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(s.dir, indexFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// entryID derives a stable entry ID from the dataset and row contents.
+func entryID(dataset string, record []string) string {
+	sum := sha256.Sum256([]byte(dataset + "\x00" + strings.Join(record, "\x1f")))
+	return hex.EncodeToString(sum[:6])
+}
+
+// Add quarantines entries and returns how many were added. Entries already
+// quarantined from the same dataset with identical contents are skipped.
+func (s *Store) Add(entries ...Entry) int {
+	existing := make(map[string]bool, len(s.entries))
+	for _, entry := range s.entries {
+		existing[entry.ID] = true
+	}
+
+	added := 0
+	now := time.Now().UTC()
+	for _, entry := range entries {
+		entry.ID = entryID(entry.Dataset, entry.Record)
+		if existing[entry.ID] {
+			continue
+		}
+		existing[entry.ID] = true
+
+		entry.Status = StatusQuarantined
+		if entry.QuarantinedAt.IsZero() {
+			entry.QuarantinedAt = now
+		}
+		s.entries = append(s.entries, entry)
+		added++
+	}
+
+	return added
+}
+
+// Entries returns the entries with the given status, or all entries when
+// status is empty.
+func (s *Store) Entries(status Status) []Entry {
+	var entries []Entry
+	for _, entry := range s.entries {
+		if status == "" || entry.Status == status {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// find returns the indices of entries matching ref by entry ID or sample ID.
+func (s *Store) find(ref string) []int {
+	var matches []int
+	for i, entry := range s.entries {
+		if entry.ID == ref || entry.SampleID == ref {
+			matches = append(matches, i)
+		}
+	}
+	return matches
+}
+
+// Restore marks the quarantined entries matching refs as restored with a
+// justification and returns them so their rows can be written back.
+func (s *Store) Restore(refs []string, justification, reviewer string) ([]Entry, error) {
+	if strings.TrimSpace(justification) == "" {
+		return nil, fmt.Errorf("a justification is required to restore samples")
+	}
+
+	var indices []int
+	for _, ref := range refs {
+		matches := s.find(ref)
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no quarantined sample %q", ref)
+		}
+		for _, i := range matches {
+			if s.entries[i].Status != StatusQuarantined {
+				return nil, fmt.Errorf("sample %q is already %s", ref, s.entries[i].Status)
+			}
+		}
+		indices = append(indices, matches...)
+	}
+
+	now := time.Now().UTC()
+	var restored []Entry
+	for _, i := range indices {
+		s.entries[i].Status = StatusRestored
+		s.entries[i].RestoredAt = now
+		s.entries[i].Justification = justification
+		s.entries[i].Reviewer = reviewer
+		restored = append(restored, s.entries[i])
+	}
+
+	return restored, nil
+}
+
+// Purge permanently deletes quarantined entries matching refs, or all
+// quarantined entries when refs is empty, that were quarantined before
+// cutoff (a zero cutoff matches any time). Restored entries are kept as a
+// record of their justification. It returns the purged entries.
+func (s *Store) Purge(refs []string, cutoff time.Time) []Entry {
+	selected := make(map[int]bool)
+	if len(refs) == 0 {
+		for i := range s.entries {
+			selected[i] = true
+		}
+	}
+	for _, ref := range refs {
+		for _, i := range s.find(ref) {
+			selected[i] = true
+		}
+	}
+
+	kept := s.entries[:0]
+	var purged []Entry
+	for i, entry := range s.entries {
+		if selected[i] && entry.Status == StatusQuarantined &&
+			(cutoff.IsZero() || entry.QuarantinedAt.Before(cutoff)) {
+			purged = append(purged, entry)
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	s.entries = kept
+
+	return purged
+}
@@ -0,0 +1,50 @@
+package quarantine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRestoreAndPurge(t *testing.T) {
+	dir := t.TempDir()
+	store, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	added := store.Add(
+		Entry{SampleID: "a", Dataset: "d.csv", Record: []string{"a", "1"}},
+		Entry{SampleID: "b", Dataset: "d.csv", Record: []string{"b", "2"}},
+		Entry{SampleID: "a", Dataset: "d.csv", Record: []string{"a", "1"}},
+	)
+	if added != 2 {
+		t.Fatalf("Add = %d, want 2 (duplicate skipped)", added)
+	}
+
+	if _, err := store.Restore([]string{"a"}, "", "tester"); err == nil {
+		t.Error("Restore without justification succeeded")
+	}
+	restored, err := store.Restore([]string{"a"}, "benign", "tester")
+	if err != nil || len(restored) != 1 {
+		t.Fatalf("Restore = %v, %v", restored, err)
+	}
+	if _, err := store.Restore([]string{"a"}, "again", "tester"); err == nil {
+		t.Error("restoring an already restored sample succeeded")
+	}
+
+	if purged := store.Purge(nil, time.Time{}); len(purged) != 1 {
+		t.Errorf("Purge = %v, want 1 entry", purged)
+	}
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := reopened.Entries("")
+	if len(entries) != 1 || entries[0].Status != StatusRestored || entries[0].Justification != "benign" {
+		t.Errorf("entries after reopen = %+v, want restored a", entries)
+	}
+}
@@ -0,0 +1,168 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// canvas is a drawing surface charts are drawn on, in pixels from the top
+// left corner, rendered as SVG or PNG.
+type canvas interface {
+	rect(x, y, w, h float64, c color.RGBA)
+	circle(x, y, r float64, c color.RGBA)
+	line(x1, y1, x2, y2 float64, c color.RGBA)
+	// text draws s with its baseline at y, starting at x, centered on x or
+	// ending at x for anchor "start", "middle" or "end".
+	text(x, y float64, s, anchor string)
+	encode(w io.Writer) error
+}
+
+// newCanvas returns a width by height canvas in format, svg or png.
+func newCanvas(format string, width, height int) canvas {
+	if format == ChartPNG {
+		img := image.NewRGBA(image.Rect(0, 0, width, height))
+		for i := range img.Pix {
+			img.Pix[i] = 0xff
+		}
+		return &pngCanvas{img: img}
+	}
+	c := &svgCanvas{}
+	fmt.Fprintf(&c.b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`+"\n", width, height, width, height)
+	fmt.Fprintf(&c.b, `<rect width="%d" height="%d" fill="#fff"/>`+"\n", width, height)
+	return c
+}
+
+// svgCanvas draws SVG elements.
+type svgCanvas struct {
+	b strings.Builder
+}
+
+func hex(c color.RGBA) string { return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B) }
+
+func (c *svgCanvas) rect(x, y, w, h float64, col color.RGBA) {
+	fmt.Fprintf(&c.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`+"\n", x, y, w, h, hex(col))
+}
+
+func (c *svgCanvas) circle(x, y, r float64, col color.RGBA) {
+	fmt.Fprintf(&c.b, `<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%s" fill-opacity="%.2f"/>`+"\n", x, y, r, hex(col), float64(col.A)/255)
+}
+
+func (c *svgCanvas) line(x1, y1, x2, y2 float64, col color.RGBA) {
+	fmt.Fprintf(&c.b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s"/>`+"\n", x1, y1, x2, y2, hex(col))
+}
+
+func (c *svgCanvas) text(x, y float64, s, anchor string) {
+	fmt.Fprintf(&c.b, `<text x="%.1f" y="%.1f" text-anchor="%s">%s</text>`+"\n", x, y, anchor, html.EscapeString(s))
+}
+
+func (c *svgCanvas) encode(w io.Writer) error {
+	_, err := io.WriteString(w, c.b.String()+"</svg>\n")
+	return err
+}
+
+// pngCanvas rasterizes onto an image, writing text in a built-in 3x5
+// pixel font scaled by glyphScale, in upper case.
+type pngCanvas struct {
+	img *image.RGBA
+}
+
+// glyphScale is the size in pixels of a pixel of the PNG font.
+const glyphScale = 2
+
+// glyphs holds the PNG font: five rows of three pixels per character.
+// Characters without a glyph are drawn as spaces.
+var glyphs = map[rune]string{
+	'0': "111101101101111", '1': "010110010010111", '2': "111001111100111", '3': "111001111001111",
+	'4': "101101111001001", '5': "111100111001111", '6': "111100111101111", '7': "111001001010010",
+	'8': "111101111101111", '9': "111101111001111", 'A': "010101111101101", 'B': "110101110101110",
+	'C': "011100100100011", 'D': "110101101101110", 'E': "111100110100111", 'F': "111100110100100",
+	'G': "011100101101011", 'H': "101101111101101", 'I': "111010010010111", 'J': "001001001101010",
+	'K': "101101110101101", 'L': "100100100100111", 'M': "101111111101101", 'N': "110101101101101",
+	'O': "010101101101010", 'P': "110101110100100", 'Q': "010101101110011", 'R': "110101110101101",
+	'S': "011100010001110", 'T': "111010010010010", 'U': "101101101101111", 'V': "101101101101010",
+	'W': "101101111111101", 'X': "101101010101101", 'Y': "101101010010010", 'Z': "111001010100111",
+	'.': "000000000000010", '-': "000000111000000", '%': "101001010100101", '_': "000000000000111",
+	':': "000010000010000", '(': "001010010010001", ')': "100010010010100", '/': "001001010100100",
+	',': "000000000010100", '+': "000010111010000", '|': "010010010010010",
+}
+
+func (c *pngCanvas) rect(x, y, w, h float64, col color.RGBA) {
+	r := image.Rect(int(math.Round(x)), int(math.Round(y)), int(math.Round(x+w)), int(math.Round(y+h)))
+	r = r.Intersect(c.img.Bounds())
+	for py := r.Min.Y; py < r.Max.Y; py++ {
+		for px := r.Min.X; px < r.Max.X; px++ {
+			c.blend(px, py, col)
+		}
+	}
+}
+
+func (c *pngCanvas) circle(x, y, r float64, col color.RGBA) {
+	for py := int(y - r); py <= int(y+r); py++ {
+		for px := int(x - r); px <= int(x+r); px++ {
+			dx, dy := float64(px)+0.5-x, float64(py)+0.5-y
+			if dx*dx+dy*dy <= r*r {
+				c.blend(px, py, col)
+			}
+		}
+	}
+}
+
+func (c *pngCanvas) line(x1, y1, x2, y2 float64, col color.RGBA) {
+	steps := int(math.Max(math.Abs(x2-x1), math.Abs(y2-y1))) + 1
+	for i := 0; i <= steps; i++ {
+		t := float64(i) / float64(steps)
+		c.blend(int(x1+t*(x2-x1)), int(y1+t*(y2-y1)), col)
+	}
+}
+
+func (c *pngCanvas) text(x, y float64, s, anchor string) {
+	s = strings.ToUpper(s)
+	const advance = 4 * glyphScale
+	width := float64(len([]rune(s))*advance - glyphScale)
+	switch anchor {
+	case "middle":
+		x -= width / 2
+	case "end":
+		x -= width
+	}
+	top := int(y) - 5*glyphScale
+	for i, r := range []rune(s) {
+		glyph, ok := glyphs[unicode.ToUpper(r)]
+		if !ok {
+			continue
+		}
+		left := int(x) + i*advance
+		for k, bit := range glyph {
+			if bit == '1' {
+				c.rect(float64(left+k%3*glyphScale), float64(top+k/3*glyphScale), glyphScale, glyphScale, textColor)
+			}
+		}
+	}
+}
+
+// blend paints the pixel at x, y with col over what is there.
+func (c *pngCanvas) blend(x, y int, col color.RGBA) {
+	if !(image.Point{x, y}.In(c.img.Bounds())) {
+		return
+	}
+	dst := c.img.RGBAAt(x, y)
+	a := uint32(col.A)
+	mix := func(s, d uint8) uint8 { return uint8((uint32(s)*a + uint32(d)*(255-a)) / 255) }
+	c.img.SetRGBA(x, y, color.RGBA{mix(col.R, dst.R), mix(col.G, dst.G), mix(col.B, dst.B), 0xff})
+}
+
+func (c *pngCanvas) encode(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := png.Encode(bw, c.img); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
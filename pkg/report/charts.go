@@ -0,0 +1,456 @@
+package report
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Chart formats.
+const (
+	ChartSVG = "svg"
+	ChartPNG = "png"
+)
+
+// Chart is a chart written by WriteCharts.
+type Chart struct {
+	Title   string
+	Caption string
+	// File is the path the chart was written to, which WriteHTMLCharts
+	// uses as the image source.
+	File string
+}
+
+var (
+	cleanColor   = color.RGBA{0x9e, 0x9e, 0x9e, 0xff}
+	flaggedColor = color.RGBA{0xb0, 0x00, 0x20, 0xff}
+	axisColor    = color.RGBA{0x44, 0x44, 0x44, 0xff}
+	gridColor    = color.RGBA{0xe0, 0xe0, 0xe0, 0xff}
+	textColor    = color.RGBA{0x22, 0x22, 0x22, 0xff}
+)
+
+const (
+	chartWidth  = 640
+	chartHeight = 400
+	// scatterPoints caps the clean and the flagged samples drawn in the
+	// PCA scatter, and the samples its components are fitted to.
+	scatterPoints = 5000
+	// featureBars caps the features of the feature anomaly chart.
+	featureBars = 20
+)
+
+// WriteCharts draws the charts of result into dir in format, svg or png:
+// the distribution of scores, and, given the scanned samples and their
+// feature names, the features that set flagged samples apart and a PCA
+// scatter of flagged and clean samples. Samples are matched to findings by
+// ID. Datasets of sparse samples only get the score distribution.
+func WriteCharts(dir, format string, result *detect.DetectionResult, features []string, samples []detect.Sample) ([]Chart, error) {
+	if format != ChartSVG && format != ChartPNG {
+		return nil, fmt.Errorf("invalid chart format %q (want svg or png)", format)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	var charts []Chart
+	write := func(name string, chart Chart, height int, draw func(canvas)) error {
+		c := newCanvas(format, chartWidth, height)
+		draw(c)
+		chart.File = filepath.Join(dir, name+"."+format)
+		f, err := os.Create(chart.File)
+		if err != nil {
+			return err
+		}
+		if err := c.encode(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		charts = append(charts, chart)
+		return nil
+	}
+
+	caption := "Scores of clean (gray) and flagged (red) samples, on a log scale."
+	if result.FlaggedOnly {
+		caption = "Scores of flagged samples; the result does not record the scores of clean samples."
+	}
+	if err := write("scores", Chart{Title: "Score Distribution", Caption: caption}, chartHeight, func(c canvas) {
+		drawScoreHistogram(c, result)
+	}); err != nil {
+		return nil, err
+	}
+
+	frame := newChartFrame(result, samples)
+	if frame == nil {
+		return charts, nil
+	}
+	if len(features) != frame.dim {
+		features = nil
+	}
+	if err := write("features", Chart{
+		Title:   "Feature Anomalies",
+		Caption: "Mean absolute z-score of each feature over flagged (red) and clean (gray) samples, for the features that deviate most in flagged samples.",
+	}, featureChartHeight(frame.dim), func(c canvas) {
+		drawFeatureAnomalies(c, frame, features)
+	}); err != nil {
+		return nil, err
+	}
+	if frame.dim >= 2 {
+		if err := write("pca", Chart{
+			Title:   "PCA Scatter",
+			Caption: "Clean (gray) and flagged (red) samples projected onto the first two principal components of their standardized features.",
+		}, chartHeight, func(c canvas) {
+			drawPCA(c, frame)
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return charts, nil
+}
+
+// chartFrame holds the standardized dense features of the scanned samples
+// and whether each was flagged.
+type chartFrame struct {
+	dim     int
+	rows    [][]float64
+	flagged []bool
+}
+
+// newChartFrame standardizes the features of samples, or returns nil when
+// there are none or samples are sparse.
+func newChartFrame(result *detect.DetectionResult, samples []detect.Sample) *chartFrame {
+	if len(samples) == 0 {
+		return nil
+	}
+	flagged := make(map[string]bool)
+	for _, finding := range result.Samples {
+		if finding.IsPoisoned {
+			flagged[finding.ID] = true
+		}
+	}
+
+	f := &chartFrame{dim: samples[0].Dim()}
+	for _, sample := range samples {
+		if sample.Sparse != nil || sample.Dim() != f.dim {
+			return nil
+		}
+		f.rows = append(f.rows, append([]float64(nil), sample.Dense()...))
+		f.flagged = append(f.flagged, flagged[sample.ID])
+	}
+	if f.dim == 0 {
+		return nil
+	}
+
+	n := float64(len(f.rows))
+	for j := 0; j < f.dim; j++ {
+		var sum, squares float64
+		for _, row := range f.rows {
+			sum += row[j]
+		}
+		mean := sum / n
+		for _, row := range f.rows {
+			squares += (row[j] - mean) * (row[j] - mean)
+		}
+		std := math.Sqrt(squares / n)
+		for _, row := range f.rows {
+			row[j] -= mean
+			if std > 0 {
+				row[j] /= std
+			}
+			if math.IsNaN(row[j]) || math.IsInf(row[j], 0) {
+				row[j] = 0
+			}
+		}
+	}
+	return f
+}
+
+// plotArea is the rectangle of a chart inside its axes.
+type plotArea struct {
+	left, top, width, height float64
+}
+
+// drawTitle writes the title of a chart and its legend of clean and
+// flagged samples.
+func drawTitle(c canvas, title string) {
+	c.text(chartWidth/2, 20, title, "middle")
+	c.rect(chartWidth-150, 30, 10, 10, cleanColor)
+	c.text(chartWidth-135, 39, "clean", "start")
+	c.rect(chartWidth-85, 30, 10, 10, flaggedColor)
+	c.text(chartWidth-70, 39, "flagged", "start")
+}
+
+// drawScoreHistogram draws the counts of clean and flagged samples in 20
+// score bins, side by side on a log scale.
+func drawScoreHistogram(c canvas, result *detect.DetectionResult) {
+	const bins = 20
+	var clean, flagged [bins]int
+	for _, sample := range result.Samples {
+		b := min(max(int(sample.Score*bins), 0), bins-1)
+		if sample.IsPoisoned {
+			flagged[b]++
+		} else {
+			clean[b]++
+		}
+	}
+	peak := 1
+	for b := 0; b < bins; b++ {
+		peak = max(peak, clean[b], flagged[b])
+	}
+
+	drawTitle(c, "Score Distribution")
+	a := plotArea{left: 60, top: 50, width: chartWidth - 90, height: chartHeight - 100}
+	top := math.Log10(float64(peak) + 1)
+	y := func(count int) float64 { return a.top + a.height - a.height*math.Log10(float64(count)+1)/top }
+	for tick := 1; tick <= peak; tick *= 10 {
+		c.line(a.left, y(tick), a.left+a.width, y(tick), gridColor)
+		c.text(a.left-6, y(tick)+4, fmt.Sprint(tick), "end")
+	}
+
+	w := a.width / bins
+	for b := 0; b < bins; b++ {
+		x := a.left + float64(b)*w
+		if clean[b] > 0 {
+			c.rect(x+1, y(clean[b]), w/2-1, a.top+a.height-y(clean[b]), cleanColor)
+		}
+		if flagged[b] > 0 {
+			c.rect(x+w/2, y(flagged[b]), w/2-1, a.top+a.height-y(flagged[b]), flaggedColor)
+		}
+	}
+	for i := 0; i <= 4; i++ {
+		x := a.left + a.width*float64(i)/4
+		c.line(x, a.top+a.height, x, a.top+a.height+4, axisColor)
+		c.text(x, a.top+a.height+18, fmt.Sprintf("%d%%", 25*i), "middle")
+	}
+	drawAxes(c, a, "score", "samples")
+}
+
+// featureChartHeight returns the height of the feature anomaly chart of
+// dim features.
+func featureChartHeight(dim int) int {
+	return 90 + 24*min(max(dim, 1), featureBars)
+}
+
+// drawFeatureAnomalies draws the mean absolute z-scores of flagged and
+// clean samples for the features most anomalous in flagged samples.
+func drawFeatureAnomalies(c canvas, f *chartFrame, names []string) {
+	type bar struct {
+		name           string
+		flagged, clean float64
+	}
+	bars := make([]bar, f.dim)
+	var nFlagged, nClean float64
+	for i, row := range f.rows {
+		if f.flagged[i] {
+			nFlagged++
+		} else {
+			nClean++
+		}
+		for j, z := range row {
+			if f.flagged[i] {
+				bars[j].flagged += math.Abs(z)
+			} else {
+				bars[j].clean += math.Abs(z)
+			}
+		}
+	}
+	peak := 0.0
+	for j := range bars {
+		bars[j].name = fmt.Sprintf("feature %d", j)
+		if names != nil {
+			bars[j].name = names[j]
+		}
+		if len(bars[j].name) > 18 {
+			bars[j].name = bars[j].name[:17] + "~"
+		}
+		if nFlagged > 0 {
+			bars[j].flagged /= nFlagged
+		}
+		if nClean > 0 {
+			bars[j].clean /= nClean
+		}
+		peak = math.Max(peak, math.Max(bars[j].flagged, bars[j].clean))
+	}
+	sort.SliceStable(bars, func(i, j int) bool { return bars[i].flagged-bars[i].clean > bars[j].flagged-bars[j].clean })
+	bars = bars[:min(len(bars), featureBars)]
+	if peak == 0 {
+		peak = 1
+	}
+
+	drawTitle(c, "Feature Anomalies")
+	a := plotArea{left: 150, top: 50, width: chartWidth - 190, height: float64(24 * len(bars))}
+	x := func(v float64) float64 { return a.left + a.width*v/peak }
+	for i := 0; i <= 4; i++ {
+		v := peak * float64(i) / 4
+		c.line(x(v), a.top, x(v), a.top+a.height, gridColor)
+		c.text(x(v), a.top+a.height+18, fmt.Sprintf("%.2g", v), "middle")
+	}
+	for i, b := range bars {
+		y := a.top + float64(24*i)
+		c.text(a.left-6, y+15, b.name, "end")
+		c.rect(a.left, y+3, x(b.flagged)-a.left, 9, flaggedColor)
+		c.rect(a.left, y+12, x(b.clean)-a.left, 9, cleanColor)
+	}
+	drawAxes(c, a, "mean |z|", "")
+}
+
+// drawPCA draws the samples of f projected onto their first two principal
+// components, fitted to an evenly spaced subset of at most scatterPoints
+// samples.
+func drawPCA(c canvas, f *chartFrame) {
+	fit := spaced(len(f.rows), scatterPoints, func(int) bool { return true })
+	first, firstVar := principalComponent(f, fit, nil)
+	second, secondVar := principalComponent(f, fit, first)
+	total := 0.0
+	for j := 0; j < f.dim; j++ {
+		for _, i := range fit {
+			total += f.rows[i][j] * f.rows[i][j]
+		}
+	}
+	total /= float64(len(fit))
+
+	clean := spaced(len(f.rows), scatterPoints, func(i int) bool { return !f.flagged[i] })
+	flagged := spaced(len(f.rows), scatterPoints, func(i int) bool { return f.flagged[i] })
+	type point struct{ x, y float64 }
+	project := func(indices []int) []point {
+		points := make([]point, len(indices))
+		for k, i := range indices {
+			points[k] = point{dot(f.rows[i], first), dot(f.rows[i], second)}
+		}
+		return points
+	}
+	cleanPoints, flaggedPoints := project(clean), project(flagged)
+	minX, maxX, minY, maxY := math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)
+	for _, p := range append(append([]point(nil), cleanPoints...), flaggedPoints...) {
+		minX, maxX = math.Min(minX, p.x), math.Max(maxX, p.x)
+		minY, maxY = math.Min(minY, p.y), math.Max(maxY, p.y)
+	}
+	if maxX <= minX {
+		minX, maxX = minX-1, maxX+1
+	}
+	if maxY <= minY {
+		minY, maxY = minY-1, maxY+1
+	}
+
+	drawTitle(c, "PCA Scatter")
+	a := plotArea{left: 60, top: 50, width: chartWidth - 90, height: chartHeight - 100}
+	sx := func(v float64) float64 { return a.left + 5 + (a.width-10)*(v-minX)/(maxX-minX) }
+	sy := func(v float64) float64 { return a.top + a.height - 5 - (a.height-10)*(v-minY)/(maxY-minY) }
+	translucent := func(col color.RGBA) color.RGBA { col.A = 0x99; return col }
+	for _, p := range cleanPoints {
+		c.circle(sx(p.x), sy(p.y), 2.5, translucent(cleanColor))
+	}
+	for _, p := range flaggedPoints {
+		c.circle(sx(p.x), sy(p.y), 2.5, translucent(flaggedColor))
+	}
+	share := func(v float64) string {
+		if total == 0 {
+			return ""
+		}
+		return fmt.Sprintf(" (%.1f%% of variance)", 100*v/total)
+	}
+	drawAxes(c, a, "PC1"+share(firstVar), "PC2"+share(secondVar))
+}
+
+// drawAxes draws the x and y axes of a and their labels.
+func drawAxes(c canvas, a plotArea, xLabel, yLabel string) {
+	c.line(a.left, a.top+a.height, a.left+a.width, a.top+a.height, axisColor)
+	c.line(a.left, a.top, a.left, a.top+a.height, axisColor)
+	c.text(a.left+a.width/2, a.top+a.height+36, xLabel, "middle")
+	if yLabel != "" {
+		c.text(10, a.top-12, yLabel, "start")
+	}
+}
+
+// spaced returns the indices below n for which keep is true, taking every
+// k-th one so that at most limit are returned.
+func spaced(n, limit int, keep func(int) bool) []int {
+	var all []int
+	for i := 0; i < n; i++ {
+		if keep(i) {
+			all = append(all, i)
+		}
+	}
+	if len(all) <= limit {
+		return all
+	}
+	step := float64(len(all)) / float64(limit)
+	picked := make([]int, limit)
+	for k := range picked {
+		picked[k] = all[int(float64(k)*step)]
+	}
+	return picked
+}
+
+// principalComponent returns the leading principal component of the rows
+// of f at indices, orthogonal to except when it is set, and the variance
+// along it, by power iteration.
+func principalComponent(f *chartFrame, indices []int, except []float64) ([]float64, float64) {
+	v := make([]float64, f.dim)
+	for j := range v {
+		v[j] = 1 + float64(j%7)/10
+	}
+	orthogonalize(v, except)
+	normalize(v)
+	variance := 0.0
+	for iter := 0; iter < 100; iter++ {
+		next := make([]float64, f.dim)
+		for _, i := range indices {
+			p := dot(f.rows[i], v)
+			for j, x := range f.rows[i] {
+				next[j] += p * x
+			}
+		}
+		orthogonalize(next, except)
+		norm := normalize(next)
+		if norm == 0 {
+			return v, 0
+		}
+		variance = norm / float64(len(indices))
+		converged := math.Abs(math.Abs(dot(next, v))-1) < 1e-9
+		v = next
+		if converged {
+			break
+		}
+	}
+	return v, variance
+}
+
+// orthogonalize removes the component along the unit vector u, if set,
+// from v.
+func orthogonalize(v, u []float64) {
+	if u == nil {
+		return
+	}
+	p := dot(v, u)
+	for j := range v {
+		v[j] -= p * u[j]
+	}
+}
+
+// normalize scales v to unit length, unless it is zero, and returns its
+// length.
+func normalize(v []float64) float64 {
+	norm := math.Sqrt(dot(v, v))
+	if norm > 0 {
+		for j := range v {
+			v[j] /= norm
+		}
+	}
+	return norm
+}
+
+func dot(a, b []float64) float64 {
+	s := 0.0
+	for i := range a {
+		s += a[i] * b[i]
+	}
+	return s
+}
@@ -0,0 +1,265 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// chartData returns 50 samples of four features and a result flagging the
+// five whose third feature is outlying.
+func chartData() ([]detect.Sample, *detect.DetectionResult) {
+	var samples []detect.Sample
+	result := &detect.DetectionResult{Method: "statistical"}
+	for i := 0; i < 50; i++ {
+		d := float64(i%7) * 0.1
+		sample := detect.Sample{ID: fmt.Sprintf("s%d", i), Features: []float64{1 + d, 2 - d, 3 + d/2, 4 + d*d}}
+		finding := detect.PoisonedSample{ID: sample.ID, Score: 0.1 + d/10}
+		if i%10 == 0 {
+			sample.Features[2] = 30
+			finding.IsPoisoned, finding.Score, finding.Type = true, 0.95, detect.TypeFeaturePoison
+			result.PoisonedCount++
+		}
+		samples = append(samples, sample)
+		result.Samples = append(result.Samples, finding)
+	}
+	result.SampleCount = len(samples)
+	result.IsPoisoned = true
+	result.RiskScore = 0.1
+	return samples, result
+}
+
+// svgElement is an element of an SVG chart.
+type svgElement struct {
+	name  string
+	attrs map[string]string
+	text  string
+}
+
+// readSVG parses the SVG chart at path into its elements, failing the test
+// if it is not well-formed.
+func readSVG(t *testing.T, path string) []svgElement {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var elements []svgElement
+	open := false
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			element := svgElement{name: token.Name.Local, attrs: make(map[string]string)}
+			for _, attr := range token.Attr {
+				element.attrs[attr.Name.Local] = attr.Value
+			}
+			elements = append(elements, element)
+			open = true
+		case xml.EndElement:
+			open = false
+		case xml.CharData:
+			if open {
+				elements[len(elements)-1].text += string(token)
+			}
+		}
+	}
+	if len(elements) == 0 || elements[0].name != "svg" {
+		t.Fatalf("%s is not an SVG document", path)
+	}
+	return elements
+}
+
+// count returns the number of elements named name filled with fill.
+func count(elements []svgElement, name, fill string) int {
+	n := 0
+	for _, e := range elements {
+		if e.name == name && e.attrs["fill"] == fill {
+			n++
+		}
+	}
+	return n
+}
+
+func TestWriteChartsSVG(t *testing.T) {
+	samples, result := chartData()
+	dir := filepath.Join(t.TempDir(), "charts")
+	charts, err := WriteCharts(dir, ChartSVG, result, []string{"a", "b", "c", "d"}, samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, chart := range charts {
+		titles = append(titles, chart.Title)
+		if filepath.Dir(chart.File) != dir || chart.Caption == "" {
+			t.Errorf("chart %+v", chart)
+		}
+	}
+	if strings.Join(titles, ", ") != "Score Distribution, Feature Anomalies, PCA Scatter" {
+		t.Fatalf("charts %v", titles)
+	}
+
+	clean, flagged := hex(cleanColor), hex(flaggedColor)
+
+	// The histogram has a bar for the flagged bin besides the legend's
+	// swatch, and clean bars for the bins of the clean scores.
+	scores := readSVG(t, charts[0].File)
+	if n := count(scores, "rect", flagged); n != 2 {
+		t.Errorf("score histogram has %d flagged rects, want a bar and the legend", n)
+	}
+	if n := count(scores, "rect", clean); n < 2 {
+		t.Errorf("score histogram has %d clean rects", n)
+	}
+
+	// The feature the flagged samples set apart is listed first.
+	var names []string
+	for _, e := range readSVG(t, charts[1].File) {
+		if e.name == "text" && e.attrs["text-anchor"] == "end" {
+			names = append(names, e.text)
+		}
+	}
+	if len(names) != 4 || names[0] != "c" {
+		t.Errorf("feature anomaly chart lists %v, want c first of four", names)
+	}
+
+	// Every sample is a point of the scatter.
+	pca := readSVG(t, charts[2].File)
+	if c, f := count(pca, "circle", clean), count(pca, "circle", flagged); c != 45 || f != 5 {
+		t.Errorf("PCA scatter has %d clean and %d flagged points, want 45 and 5", c, f)
+	}
+}
+
+func TestWriteChartsPNG(t *testing.T) {
+	samples, result := chartData()
+	charts, err := WriteCharts(t.TempDir(), ChartPNG, result, nil, samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(charts) != 3 {
+		t.Fatalf("%d charts, want 3", len(charts))
+	}
+	for _, chart := range charts {
+		f, err := os.Open(chart.File)
+		if err != nil {
+			t.Fatal(err)
+		}
+		img, err := png.Decode(f)
+		f.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", chart.File, err)
+		}
+		want := chartHeight
+		if chart.Title == "Feature Anomalies" {
+			want = featureChartHeight(4)
+		}
+		if b := img.Bounds(); b.Dx() != chartWidth || b.Dy() != want {
+			t.Errorf("%s is %dx%d, want %dx%d", chart.Title, b.Dx(), b.Dy(), chartWidth, want)
+		}
+	}
+}
+
+func TestWriteChartsScoresOnly(t *testing.T) {
+	samples, result := chartData()
+	for name, samples := range map[string][]detect.Sample{
+		"no samples": nil,
+		"sparse":     {{ID: "s0", Sparse: &detect.SparseFeatures{Dim: 4, Indices: []int{1}, Values: []float64{2}}}},
+	} {
+		charts, err := WriteCharts(t.TempDir(), ChartSVG, result, nil, samples)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(charts) != 1 || charts[0].Title != "Score Distribution" {
+			t.Errorf("%s: charts %+v, want the score distribution alone", name, charts)
+		}
+	}
+
+	// One feature has no second principal component.
+	single := make([]detect.Sample, len(samples))
+	for i, sample := range samples {
+		single[i] = detect.Sample{ID: sample.ID, Features: sample.Features[2:3]}
+	}
+	charts, err := WriteCharts(t.TempDir(), ChartSVG, result, nil, single)
+	if err != nil || len(charts) != 2 {
+		t.Errorf("charts of one feature %+v, %v", charts, err)
+	}
+
+	flaggedOnly := *result
+	flaggedOnly.FlaggedOnly = true
+	charts, err = WriteCharts(t.TempDir(), ChartSVG, &flaggedOnly, nil, nil)
+	if err != nil || !strings.Contains(charts[0].Caption, "does not record") {
+		t.Errorf("flagged-only charts %+v, %v", charts, err)
+	}
+
+	if _, err := WriteCharts(t.TempDir(), "gif", result, nil, samples); err == nil {
+		t.Error("wrote gif charts")
+	}
+}
+
+func TestPrincipalComponent(t *testing.T) {
+	// Points along (1, 1) with a little spread across it.
+	f := &chartFrame{dim: 2}
+	for i := -5; i <= 5; i++ {
+		across := 0.1
+		if i%2 == 0 {
+			across = -0.1
+		}
+		f.rows = append(f.rows, []float64{float64(i) + across, float64(i) - across})
+	}
+	indices := spaced(len(f.rows), 100, func(int) bool { return true })
+	first, firstVar := principalComponent(f, indices, nil)
+	second, secondVar := principalComponent(f, indices, first)
+	if math.Abs(math.Abs(first[0])-math.Sqrt(0.5)) > 1e-6 || math.Abs(first[0]-first[1]) > 1e-6 {
+		t.Errorf("first component %v, want along (1, 1)", first)
+	}
+	if math.Abs(dot(first, second)) > 1e-9 || math.Abs(math.Sqrt(dot(second, second))-1) > 1e-9 {
+		t.Errorf("second component %v is not a unit vector orthogonal to %v", second, first)
+	}
+	if firstVar <= secondVar || secondVar <= 0 {
+		t.Errorf("variances %v and %v", firstVar, secondVar)
+	}
+
+	if got := spaced(10, 3, func(i int) bool { return i%2 == 1 }); len(got) != 3 || got[0] != 1 {
+		t.Errorf("spaced = %v", got)
+	}
+}
+
+func TestWriteHTMLCharts(t *testing.T) {
+	_, result := chartData()
+	var b strings.Builder
+	charts := []Chart{{Title: "Score Distribution", Caption: "Scores <b>.", File: "charts/scores.svg"}}
+	if err := WriteHTMLCharts(&b, result, charts); err != nil {
+		t.Fatal(err)
+	}
+	html := b.String()
+	for _, want := range []string{
+		`<img src="charts/scores.svg" alt="Score Distribution">`,
+		"<strong>Score Distribution.</strong> Scores &lt;b&gt;.",
+		"<tr><th>Total Samples</th><td>50</td></tr>",
+		"<tr><th>Risk Score</th><td>10%</td></tr>",
+		`<span class="poisoned">POISONING DETECTED</span>`,
+		"<tr><td>s10</td><td>0</td><td>feature_poison</td><td>95%</td>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML report lacks %q", want)
+		}
+	}
+	if strings.Count(html, "<tr><td>s") != 5 {
+		t.Errorf("HTML report lists %d samples, want the 5 flagged", strings.Count(html, "<tr><td>s"))
+	}
+}
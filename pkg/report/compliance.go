@@ -0,0 +1,328 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/allowlist"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Frameworks whose requirements compliance reports map scans onto.
+const (
+	NISTAIRMF = "NIST AI RMF 1.0"
+	EUAIAct   = "EU AI Act"
+)
+
+// Status is how far a scan evidences a requirement.
+type Status string
+
+const (
+	// StatusSupported means the scan evidences the requirement.
+	StatusSupported Status = "supported"
+	// StatusPartial means the scan evidences the requirement for part of
+	// the dataset or with incomplete records.
+	StatusPartial Status = "partial"
+	// StatusActionRequired means the scan found something that must be
+	// handled before the requirement is evidenced.
+	StatusActionRequired Status = "action_required"
+)
+
+// Requirement is a requirement of a framework and the evidence a scan
+// provides for it.
+type Requirement struct {
+	Framework string `json:"framework"`
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Status    Status `json:"status"`
+	Evidence  string `json:"evidence"`
+}
+
+// ComplianceScope records what a scan covered.
+type ComplianceScope struct {
+	Dataset        string                 `json:"dataset,omitempty"`
+	Version        *detect.DatasetVersion `json:"dataset_version,omitempty"`
+	ScannedSamples int                    `json:"scanned_samples"`
+	// TotalSamples is the size of the dataset, larger than ScannedSamples
+	// for partial scans.
+	TotalSamples int `json:"total_samples"`
+	// Complete is set when every sample of the dataset was scanned.
+	Complete bool `json:"complete"`
+	// Limitations lists why the scan is not complete or its findings not
+	// all reported.
+	Limitations []string `json:"limitations,omitempty"`
+}
+
+// ComplianceMethod records how a scan was performed.
+type ComplianceMethod struct {
+	Method    string             `json:"method"`
+	Checks    []detect.CheckInfo `json:"checks"`
+	RiskModel *detect.RiskModel  `json:"risk_model,omitempty"`
+	// ATLAS lists the MITRE ATLAS techniques the checks detect.
+	ATLAS []detect.Technique `json:"atlas"`
+}
+
+// ResidualRisk records the risk left after review: findings confirmed as
+// poisoning or not yet reviewed remain; false positives do not.
+type ResidualRisk struct {
+	RiskScore      float64 `json:"risk_score"`
+	Level          string  `json:"level"`
+	Flagged        int     `json:"flagged"`
+	Confirmed      int     `json:"confirmed"`
+	FalsePositives int     `json:"false_positives"`
+	Unreviewed     int     `json:"unreviewed"`
+	// Remaining counts the flagged samples not dismissed as false
+	// positives, and RemainingRate their fraction of the scanned samples.
+	Remaining     int     `json:"remaining"`
+	RemainingRate float64 `json:"remaining_rate"`
+}
+
+// Compliance is the evidence a scan provides for AI risk management and
+// data governance requirements: its scope, methodology and residual risk,
+// mapped onto the functions of the NIST AI Risk Management Framework and
+// the data governance and robustness articles of the EU AI Act. It records
+// evidence for a compliance review; it does not establish conformity.
+type Compliance struct {
+	GeneratedAt  time.Time        `json:"generated_at"`
+	Scope        ComplianceScope  `json:"scope"`
+	Methodology  ComplianceMethod `json:"methodology"`
+	Residual     ResidualRisk     `json:"residual_risk"`
+	Requirements []Requirement    `json:"requirements"`
+}
+
+// NewCompliance builds the compliance record of result, a scan of the
+// named dataset generated at now. The dataset name defaults to the path
+// of the result's dataset version.
+func NewCompliance(result *detect.DetectionResult, dataset string, now time.Time) *Compliance {
+	c := &Compliance{GeneratedAt: now.UTC()}
+	c.Scope = complianceScope(result, dataset)
+
+	// Results do not record the thresholds of the scan, so the checks are
+	// listed without their parameters.
+	c.Methodology = ComplianceMethod{
+		Method: result.Method,
+		Checks: detect.NewDetector().Describe(),
+	}
+	for i := range c.Methodology.Checks {
+		c.Methodology.Checks[i].Parameters = nil
+	}
+	for _, coverage := range detect.ATLASCoverage(result) {
+		if coverage.Detected {
+			c.Methodology.ATLAS = append(c.Methodology.ATLAS, coverage.Technique)
+		}
+	}
+	if result.Risk != nil {
+		model := result.Risk.Model
+		c.Methodology.RiskModel = &model
+	}
+
+	r := ResidualRisk{
+		RiskScore: result.RiskScore,
+		Level:     strings.ToUpper(detect.SeverityOf(result.RiskScore).String()),
+		Flagged:   result.PoisonedCount,
+	}
+	for _, sample := range result.Samples {
+		if !sample.IsPoisoned {
+			continue
+		}
+		switch allowlist.Decision(sample.Review) {
+		case allowlist.DecisionAccepted:
+			r.Confirmed++
+		case allowlist.DecisionFalsePositive:
+			r.FalsePositives++
+		}
+	}
+	r.Unreviewed = r.Flagged - r.Confirmed - r.FalsePositives
+	r.Remaining = r.Flagged - r.FalsePositives
+	if result.SampleCount > 0 {
+		r.RemainingRate = float64(r.Remaining) / float64(result.SampleCount)
+	}
+	c.Residual = r
+
+	c.Requirements = complianceRequirements(result, c)
+	return c
+}
+
+// complianceScope returns the scope of result.
+func complianceScope(result *detect.DetectionResult, dataset string) ComplianceScope {
+	s := ComplianceScope{
+		Dataset:        dataset,
+		Version:        result.Version,
+		ScannedSamples: result.SampleCount,
+		TotalSamples:   result.SampleCount,
+		Complete:       true,
+	}
+	if s.Dataset == "" && result.Version != nil {
+		s.Dataset = result.Version.Path
+	}
+	if p := result.Partial; p != nil {
+		s.TotalSamples, s.Complete = p.TotalSamples, false
+		s.Limitations = append(s.Limitations, fmt.Sprintf("partial scan (%s): %d of %d samples", p.Mode, p.ScannedSamples, p.TotalSamples))
+	}
+	if sh := result.Shard; sh != nil {
+		s.Complete = false
+		s.Limitations = append(s.Limitations, fmt.Sprintf("shards %d-%d of %d only", sh.From, sh.To, sh.Count))
+	}
+	if f := result.Filter; f != nil {
+		s.Limitations = append(s.Limitations, fmt.Sprintf("report filtered to %d of %d flagged samples", f.Shown, f.Flagged))
+	}
+	if v := result.Version; v != nil && v.Modified {
+		s.Limitations = append(s.Limitations, "dataset modified since its version was recorded")
+	}
+	return s
+}
+
+// complianceRequirements maps result, summarized in c, onto the
+// requirements of each framework.
+func complianceRequirements(result *detect.DetectionResult, c *Compliance) []Requirement {
+	scope, residual := c.Scope, c.Residual
+
+	provenance := Requirement{Status: StatusSupported}
+	switch v := scope.Version; {
+	case v == nil:
+		provenance.Status = StatusPartial
+		provenance.Evidence = "The scanned dataset has no recorded version; record its origin, such as a DVC hash, alongside this report."
+	case v.Modified:
+		provenance.Status = StatusActionRequired
+		provenance.Evidence = fmt.Sprintf("Dataset %s was tracked as %s (%s) but was modified since; re-add it and rescan.", v.Path, v.Hash, v.Source)
+	default:
+		provenance.Evidence = fmt.Sprintf("Dataset %s scanned at version %s, recorded in %s.", v.Path, v.Hash, v.Source)
+	}
+
+	detection := Requirement{Status: StatusSupported}
+	detection.Evidence = fmt.Sprintf("%d samples scanned for backdoor, label flipping, gradient and feature poisoning by %s.", scope.ScannedSamples, c.Methodology.Method)
+	if !scope.Complete {
+		detection.Status = StatusPartial
+		detection.Evidence += " The scan did not cover the whole dataset: " + strings.Join(scope.Limitations, "; ") + "."
+	}
+
+	response := Requirement{Status: StatusSupported}
+	switch {
+	case residual.Flagged == 0:
+		response.Evidence = "No samples were flagged; no response is required."
+	case residual.Unreviewed > 0:
+		response.Status = StatusActionRequired
+		response.Evidence = fmt.Sprintf("%d of %d flagged samples await review; record accept or reject decisions with modelpoison annotate.", residual.Unreviewed, residual.Flagged)
+	default:
+		response.Evidence = fmt.Sprintf("All %d flagged samples were reviewed: %d confirmed, %d false positives.", residual.Flagged, residual.Confirmed, residual.FalsePositives)
+	}
+
+	errorsFree := Requirement{Status: StatusSupported}
+	if residual.Remaining == 0 {
+		errorsFree.Evidence = "No flagged samples remain once false positives are dismissed."
+	} else {
+		errorsFree.Status = StatusActionRequired
+		errorsFree.Evidence = fmt.Sprintf("%d flagged samples (%.2f%% of those scanned) remain; remove them with modelpoison clean before training.", residual.Remaining, residual.RemainingRate*100)
+	}
+
+	labels, reviewedLabels := 0, 0
+	for _, sample := range result.Samples {
+		if sample.IsPoisoned && sample.Type == detect.TypeLabelFlip {
+			labels++
+			if sample.Review != "" {
+				reviewedLabels++
+			}
+		}
+	}
+	labelling := Requirement{Status: StatusSupported}
+	switch {
+	case labels == 0:
+		labelling.Evidence = "No samples were flagged for labels inconsistent with their features."
+	case reviewedLabels < labels:
+		labelling.Status = StatusActionRequired
+		labelling.Evidence = fmt.Sprintf("%d samples were flagged for suspicious labels, %d of them reviewed; relabel or remove them.", labels, reviewedLabels)
+	default:
+		labelling.Evidence = fmt.Sprintf("%d samples flagged for suspicious labels were all reviewed.", labels)
+	}
+
+	with := func(r Requirement, framework, id, title string) Requirement {
+		r.Framework, r.ID, r.Title = framework, id, title
+		return r
+	}
+	return []Requirement{
+		with(Requirement{Status: StatusSupported, Evidence: "This report documents the scope, methodology and outcome of the poisoning scan."},
+			NISTAIRMF, "GOVERN 1.4", "The risk management process and its outcomes are documented"),
+		with(provenance, NISTAIRMF, "MAP 2.3", "Data collection and selection considerations are identified and documented"),
+		with(Requirement{Status: StatusSupported, Evidence: fmt.Sprintf("The checks, their methods, the risk model and the MITRE ATLAS techniques detected are listed under Methodology (%d checks).", len(c.Methodology.Checks))},
+			NISTAIRMF, "MEASURE 2.1", "Test sets, metrics and tools used during evaluation are documented"),
+		with(detection, NISTAIRMF, "MEASURE 2.7", "AI system security and resilience are evaluated and documented"),
+		with(response, NISTAIRMF, "MANAGE 1.3", "Responses to high-priority risks are developed, planned and documented"),
+		with(provenance, EUAIAct, "Art. 10(2)(b)", "Data collection processes and the origin of data"),
+		with(labelling, EUAIAct, "Art. 10(2)(c)", "Data-preparation operations such as labelling and cleaning"),
+		with(errorsFree, EUAIAct, "Art. 10(3)", "Training data free of errors to the best extent possible"),
+		with(detection, EUAIAct, "Art. 15(5)", "Measures to prevent, detect and control data poisoning"),
+	}
+}
+
+// complianceTemplate is the Markdown compliance report.
+var complianceTemplate = template.Must(template.New("compliance").Funcs(template.FuncMap{
+	"percent": detect.TemplateFuncs["percent"],
+	"join":    detect.TemplateFuncs["join"],
+	"status": func(s Status) string {
+		switch s {
+		case StatusSupported:
+			return "Supported"
+		case StatusPartial:
+			return "Partial"
+		}
+		return "Action required"
+	},
+}).Parse(`# Data Poisoning Compliance Evidence
+
+Generated {{.GeneratedAt.Format "2006-01-02 15:04 MST"}} by modelpoison. This
+document records the evidence a training data poisoning scan provides for
+a compliance review; it does not by itself establish conformity.
+
+## Scope
+
+| | |
+|---|---|
+| Dataset | {{with .Scope.Dataset}}{{.}}{{else}}not named{{end}} |
+{{- with .Scope.Version}}
+| Version | {{.Hash}} ({{.Source}}){{if .Modified}}, modified since tracked{{end}} |
+{{- end}}
+| Samples scanned | {{.Scope.ScannedSamples}} of {{.Scope.TotalSamples}} |
+| Complete | {{if .Scope.Complete}}yes{{else}}no{{end}} |
+{{- range .Scope.Limitations}}
+| Limitation | {{.}} |
+{{- end}}
+
+## Methodology
+
+Method: {{.Methodology.Method}}
+
+| Check | Method | Description | MITRE ATLAS |
+|---|---|---|---|
+{{- range .Methodology.Checks}}
+| {{.Type}} | {{.Method}} | {{.Description}} | {{range $i, $t := .ATLAS}}{{if $i}}, {{end}}{{$t.ID}}{{end}} |
+{{- end}}
+{{with .Methodology.RiskModel}}
+Risk model: {{percent .RatioWeight}} flagged rate, {{percent .ConfidenceWeight}} mean confidence{{if .Calibration}}, calibrated{{end}}.
+{{end}}
+## Residual Risk
+
+| | |
+|---|---|
+| Risk score | {{percent .Residual.RiskScore}} ({{.Residual.Level}}) |
+| Flagged samples | {{.Residual.Flagged}} |
+| Confirmed | {{.Residual.Confirmed}} |
+| False positives | {{.Residual.FalsePositives}} |
+| Awaiting review | {{.Residual.Unreviewed}} |
+| Remaining | {{.Residual.Remaining}} ({{percent .Residual.RemainingRate 2}} of scanned samples) |
+
+## Requirements
+
+| Framework | Requirement | Status | Evidence |
+|---|---|---|---|
+{{- range .Requirements}}
+| {{.Framework}} | {{.ID}}: {{.Title}} | {{status .Status}} | {{.Evidence}} |
+{{- end}}
+`))
+
+// WriteCompliance renders the compliance record of a scan as Markdown.
+func WriteCompliance(w io.Writer, c *Compliance) error {
+	return complianceTemplate.Execute(w, c)
+}
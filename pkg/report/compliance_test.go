@@ -0,0 +1,168 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/allowlist"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// reviewedResult returns a scan of 200 samples flagging four: two label
+// flips, one accepted and one unreviewed, a feature poison dismissed as a
+// false positive and an unreviewed backdoor.
+func reviewedResult() *detect.DetectionResult {
+	result := &detect.DetectionResult{SampleCount: 200, RiskScore: 0.35, Method: "statistical", IsPoisoned: true}
+	for i := 0; i < 200; i++ {
+		result.Samples = append(result.Samples, detect.PoisonedSample{ID: fmt.Sprintf("s%d", i), Score: 0.1})
+	}
+	flag := func(i int, t detect.PoisonType, review allowlist.Decision) {
+		result.Samples[i].IsPoisoned, result.Samples[i].Type, result.Samples[i].Score = true, t, 0.9
+		result.Samples[i].Review = string(review)
+		result.PoisonedCount++
+	}
+	flag(3, detect.TypeLabelFlip, allowlist.DecisionAccepted)
+	flag(7, detect.TypeLabelFlip, "")
+	flag(11, detect.TypeFeaturePoison, allowlist.DecisionFalsePositive)
+	flag(13, detect.TypeBackdoor, "")
+	return result
+}
+
+// requirement returns the requirement id of framework in c.
+func requirement(t *testing.T, c *Compliance, framework, id string) Requirement {
+	t.Helper()
+	for _, r := range c.Requirements {
+		if r.Framework == framework && r.ID == id {
+			return r
+		}
+	}
+	t.Fatalf("no requirement %s %s", framework, id)
+	return Requirement{}
+}
+
+func TestComplianceClean(t *testing.T) {
+	result := &detect.DetectionResult{SampleCount: 100, Method: "statistical",
+		Version: &detect.DatasetVersion{Path: "data/train.csv", Hash: "md5:abc", Source: "data/train.csv.dvc"}}
+	now := time.Date(2026, 5, 4, 10, 30, 0, 0, time.FixedZone("CEST", 2*3600))
+	c := NewCompliance(result, "", now)
+
+	if !c.GeneratedAt.Equal(now) || c.GeneratedAt.Location() != time.UTC {
+		t.Errorf("generated at %v, want %v in UTC", c.GeneratedAt, now)
+	}
+	if s := c.Scope; s.Dataset != "data/train.csv" || !s.Complete || s.ScannedSamples != 100 || s.TotalSamples != 100 || s.Limitations != nil {
+		t.Errorf("scope %+v", s)
+	}
+	if len(c.Methodology.Checks) == 0 {
+		t.Error("no checks listed")
+	}
+	for _, check := range c.Methodology.Checks {
+		if check.Parameters != nil {
+			t.Errorf("check %s lists parameters %v the result does not record", check.Type, check.Parameters)
+		}
+	}
+	if r := c.Residual; r.Flagged != 0 || r.Remaining != 0 || r.Level != strings.ToUpper(detect.SeverityOf(0).String()) {
+		t.Errorf("residual risk %+v", r)
+	}
+	if len(c.Requirements) != 9 {
+		t.Fatalf("%d requirements, want 9", len(c.Requirements))
+	}
+	for _, r := range c.Requirements {
+		if r.Status != StatusSupported || r.Evidence == "" || r.Title == "" {
+			t.Errorf("requirement %+v of a clean, versioned scan", r)
+		}
+	}
+	if r := requirement(t, c, NISTAIRMF, "MAP 2.3"); !strings.Contains(r.Evidence, "md5:abc") {
+		t.Errorf("provenance evidence %q", r.Evidence)
+	}
+
+	var b strings.Builder
+	if err := WriteCompliance(&b, c); err != nil {
+		t.Fatal(err)
+	}
+	report := b.String()
+	for _, want := range []string{
+		"# Data Poisoning Compliance Evidence\n\nGenerated 2026-05-04 08:30 UTC by modelpoison.",
+		"| Dataset | data/train.csv |\n| Version | md5:abc (data/train.csv.dvc) |\n| Samples scanned | 100 of 100 |\n| Complete | yes |\n",
+		"Method: statistical\n\n| Check | Method | Description | MITRE ATLAS |\n|---|---|---|---|\n| backdoor |",
+		"| Flagged samples | 0 |",
+		"| NIST AI RMF 1.0 | GOVERN 1.4: The risk management process and its outcomes are documented | Supported |",
+		"| EU AI Act | Art. 15(5): Measures to prevent, detect and control data poisoning | Supported |",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("compliance report lacks %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "Limitation") || strings.Contains(report, "Action required") {
+		t.Errorf("clean compliance report lists limitations or actions:\n%s", report)
+	}
+	if rows := strings.Count(report, "\n| NIST AI RMF 1.0 |") + strings.Count(report, "\n| EU AI Act |"); rows != 9 {
+		t.Errorf("compliance report has %d requirement rows, want 9", rows)
+	}
+}
+
+func TestComplianceFindings(t *testing.T) {
+	result := reviewedResult()
+	result.MarkPartial(detect.PartialLimit, 1000)
+	result.Version = &detect.DatasetVersion{Path: "train.csv", Hash: "md5:abc", Source: "train.csv.dvc", Modified: true}
+	c := NewCompliance(result, "train.csv", time.Unix(0, 0))
+
+	if s := c.Scope; s.Complete || s.TotalSamples != 1000 || len(s.Limitations) != 2 {
+		t.Errorf("scope %+v, want a partial scan of a modified dataset", s)
+	}
+	want := ResidualRisk{RiskScore: 0.35, Level: strings.ToUpper(detect.SeverityOf(0.35).String()),
+		Flagged: 4, Confirmed: 1, FalsePositives: 1, Unreviewed: 2, Remaining: 3, RemainingRate: 0.015}
+	if c.Residual != want {
+		t.Errorf("residual risk %+v, want %+v", c.Residual, want)
+	}
+
+	for _, test := range []struct {
+		framework, id string
+		status        Status
+		evidence      string
+	}{
+		{NISTAIRMF, "MAP 2.3", StatusActionRequired, "modified since"},
+		{NISTAIRMF, "MEASURE 2.7", StatusPartial, "partial scan (limit): 200 of 1000 samples"},
+		{NISTAIRMF, "MANAGE 1.3", StatusActionRequired, "2 of 4 flagged samples await review"},
+		{EUAIAct, "Art. 10(2)(c)", StatusActionRequired, "2 samples were flagged for suspicious labels, 1 of them reviewed"},
+		{EUAIAct, "Art. 10(3)", StatusActionRequired, "3 flagged samples (1.50% of those scanned) remain"},
+		{EUAIAct, "Art. 15(5)", StatusPartial, "did not cover the whole dataset"},
+	} {
+		r := requirement(t, c, test.framework, test.id)
+		if r.Status != test.status || !strings.Contains(r.Evidence, test.evidence) {
+			t.Errorf("%s %s: %s %q, want %s with %q", test.framework, test.id, r.Status, r.Evidence, test.status, test.evidence)
+		}
+	}
+
+	var b strings.Builder
+	if err := WriteCompliance(&b, c); err != nil {
+		t.Fatal(err)
+	}
+	report := b.String()
+	for _, want := range []string{
+		"| Dataset | train.csv |\n| Version | md5:abc (train.csv.dvc), modified since tracked |\n| Samples scanned | 200 of 1000 |\n| Complete | no |\n| Limitation | partial scan (limit): 200 of 1000 samples |\n",
+		"| Awaiting review | 2 |\n| Remaining | 3 (1.50% of scanned samples) |",
+		"| NIST AI RMF 1.0 | MANAGE 1.3: Responses to high-priority risks are developed, planned and documented | Action required |",
+		"| EU AI Act | Art. 15(5): Measures to prevent, detect and control data poisoning | Partial |",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("compliance report lacks %q:\n%s", want, report)
+		}
+	}
+
+	// Once every finding is reviewed, only the remaining findings need
+	// action.
+	result.Samples[7].Review = string(allowlist.DecisionFalsePositive)
+	result.Samples[13].Review = string(allowlist.DecisionAccepted)
+	c = NewCompliance(result, "train.csv", time.Unix(0, 0))
+	if r := requirement(t, c, NISTAIRMF, "MANAGE 1.3"); r.Status != StatusSupported || !strings.Contains(r.Evidence, "2 confirmed, 2 false positives") {
+		t.Errorf("response %+v", r)
+	}
+	if r := requirement(t, c, EUAIAct, "Art. 10(2)(c)"); r.Status != StatusSupported {
+		t.Errorf("labelling %+v", r)
+	}
+	if r := requirement(t, c, EUAIAct, "Art. 10(3)"); r.Status != StatusActionRequired || c.Residual.Remaining != 2 {
+		t.Errorf("errors %+v, %d remaining", r, c.Residual.Remaining)
+	}
+}
@@ -0,0 +1,96 @@
+// Package report renders stored detection results in formats other than the
+// plain text report.
+package report
+
+import (
+	"html/template"
+	"io"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// htmlTemplate is the standalone HTML report.
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"percent": func(v float64) float64 { return v * 100 },
+	"flagged": detect.TemplateFuncs["flagged"],
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Model Poisoning Detection Report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-top: 1em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f3f3f3; }
+.poisoned { color: #b00020; font-weight: bold; }
+.clean { color: #1b7f1b; font-weight: bold; }
+figure { margin: 1em 0; }
+figcaption { color: #555; max-width: 640px; }
+</style>
+</head>
+<body>
+<h1>Model Poisoning Detection Report</h1>
+<table>
+<tr><th>Total Samples</th><td>{{.SampleCount}}</td></tr>
+<tr><th>Poisoned Samples</th><td>{{.PoisonedCount}}</td></tr>
+<tr><th>Risk Score</th><td>{{printf "%.0f" (percent .RiskScore)}}%</td></tr>
+<tr><th>Method</th><td>{{.Method}}</td></tr>
+{{- with .Filter}}
+<tr><th>Filter</th><td>Showing {{.Shown}} of {{.Flagged}} flagged samples{{if .MinSeverity}}, severity &ge; {{.MinSeverity}}{{end}}{{if .Types}}, types: {{range $i, $t := .Types}}{{if $i}}, {{end}}{{$t}}{{end}}{{end}}{{if .Top}}, top {{.Top}} by score{{end}}</td></tr>
+{{- end}}
+{{- with .Version}}
+<tr><th>Dataset Version</th><td>{{.Hash}} ({{.Source}}){{if .Modified}} <span class="poisoned">modified since it was tracked</span>{{end}}</td></tr>
+{{- end}}
+{{- with .Partial}}
+<tr><th>Partial Scan</th><td>{{.Mode}}: {{.ScannedSamples}} of {{.TotalSamples}} samples scanned; an estimated {{.EstimatedPoisoned}} poisoned samples ({{printf "%.1f" (percent .EstimatedRate)}}%, extrapolated)</td></tr>
+{{- end}}
+<tr><th>Verdict</th><td>{{if .IsPoisoned}}<span class="poisoned">POISONING DETECTED</span>{{else}}<span class="clean">Training data appears clean</span>{{end}}</td></tr>
+</table>
+{{- if .PoisonedCount}}{{with .Classes}}
+<h2>Findings by Class</h2>
+<table>
+<tr><th>Class</th><th>Samples</th><th>Flagged</th><th>Flagged Rate</th><th>Share of Findings</th><th>Mean Score</th><th>Dominant Type</th></tr>
+{{- range .}}{{if .Flagged}}
+<tr><td>{{.Label}}</td><td>{{.Samples}}</td><td>{{.Flagged}}</td><td>{{printf "%.1f" (percent .FlaggedRate)}}%</td><td>{{printf "%.1f" (percent .Share)}}%</td><td>{{printf "%.0f" (percent .MeanScore)}}%</td><td>{{.DominantType}}</td></tr>
+{{- end}}{{end}}
+</table>
+{{- end}}{{end}}
+{{- with .Charts}}
+<h2>Charts</h2>
+{{- range .}}
+<figure>
+<img src="{{.File}}" alt="{{.Title}}">
+<figcaption><strong>{{.Title}}.</strong> {{.Caption}}</figcaption>
+</figure>
+{{- end}}
+{{- end}}
+{{- if flagged .Samples}}
+<h2>Detected Poisoned Samples</h2>
+<table>
+<tr><th>ID</th><th>Label</th><th>Type</th><th>Score</th><th>Description</th><th>Evidence</th><th>Review</th></tr>
+{{- range .Samples}}{{if .IsPoisoned}}
+<tr><td>{{.ID}}</td><td>{{.Label}}</td><td>{{.Type}}</td><td>{{printf "%.0f" (percent .Score)}}%</td><td>{{.Description}}</td><td>{{.Evidence}}</td><td>{{.Review}}{{if .ReviewNote}}: {{.ReviewNote}}{{end}}</td></tr>
+{{- end}}{{end}}
+</table>
+{{- end}}
+</body>
+</html>
+`))
+
+// htmlReport is the data of htmlTemplate.
+type htmlReport struct {
+	*detect.DetectionResult
+	Charts []Chart
+}
+
+// WriteHTML writes result as a standalone HTML document.
+func WriteHTML(w io.Writer, result *detect.DetectionResult) error {
+	return WriteHTMLCharts(w, result, nil)
+}
+
+// WriteHTMLCharts writes result as an HTML document showing charts, which
+// the document references by their File paths.
+func WriteHTMLCharts(w io.Writer, result *detect.DetectionResult, charts []Chart) error {
+	return htmlTemplate.Execute(w, htmlReport{DetectionResult: result, Charts: charts})
+}
@@ -0,0 +1,205 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/hallucinaut/modelpoison/pkg/allowlist"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Confidence levels of a Summary.
+const (
+	ConfidenceHigh   = "high"
+	ConfidenceMedium = "medium"
+	ConfidenceLow    = "low"
+)
+
+// summaryClasses caps the classes a Summary names.
+const summaryClasses = 3
+
+// Summary is a short plain-language account of a detection result for
+// readers who will not read the findings: the verdict, the overall risk,
+// the classes affected, what to do and how far to trust it.
+type Summary struct {
+	Dataset     string  `json:"dataset,omitempty"`
+	Verdict     string  `json:"verdict"`
+	RiskScore   float64 `json:"risk_score"`
+	Level       string  `json:"level"`
+	Samples     int     `json:"samples"`
+	Flagged     int     `json:"flagged"`
+	FlaggedRate float64 `json:"flagged_rate"`
+	// Classes describes the classes holding most of the flagged samples.
+	Classes []string `json:"affected_classes,omitempty"`
+	Action  string   `json:"recommended_action"`
+	// Confidence is high, medium or low, for the reasons given.
+	Confidence        string   `json:"confidence"`
+	ConfidenceReasons []string `json:"confidence_reasons"`
+}
+
+// summaryActions holds the recommended action of each risk level, following
+// the risk levels of the README.
+var summaryActions = map[detect.Severity]string{
+	detect.SeverityMinimal:  "No action needed beyond routine monitoring; scan again when the dataset changes.",
+	detect.SeverityLow:      "Have the data owner review the flagged samples before the next training run.",
+	detect.SeverityMedium:   "Clean the dataset before training: remove the flagged samples or review them one by one.",
+	detect.SeverityHigh:     "Hold training and investigate where the flagged samples came from before cleaning the dataset.",
+	detect.SeverityCritical: "Block training on this dataset until the flagged samples are investigated and removed.",
+}
+
+// NewSummary summarizes result, a full result rather than one narrowed by
+// a filter. dataset names the dataset, or is empty.
+func NewSummary(result *detect.DetectionResult, dataset string) Summary {
+	level := detect.SeverityOf(result.RiskScore)
+	s := Summary{
+		Dataset:   dataset,
+		RiskScore: result.RiskScore,
+		Level:     strings.ToUpper(level.String()),
+		Samples:   result.SampleCount,
+		Flagged:   result.PoisonedCount,
+		Action:    summaryActions[level],
+	}
+	if s.Dataset == "" && result.Version != nil {
+		s.Dataset = result.Version.Path
+	}
+	if s.Samples > 0 {
+		s.FlaggedRate = float64(s.Flagged) / float64(s.Samples)
+	}
+	if s.Flagged > 0 && level == detect.SeverityMinimal {
+		s.Action = summaryActions[detect.SeverityLow]
+	}
+
+	switch {
+	case s.Flagged == 0:
+		s.Verdict = "No signs of poisoning were found."
+	case level >= detect.SeverityHigh:
+		s.Verdict = "The training data is likely poisoned."
+	case level >= detect.SeverityMedium:
+		s.Verdict = "The training data may be poisoned."
+	default:
+		s.Verdict = "Some suspicious samples were found, but the overall risk of poisoning is low."
+	}
+
+	classes := result.Classes
+	if classes == nil {
+		classes = detect.ClassBreakdown(result)
+	}
+	affected := make([]detect.ClassRisk, 0, len(classes))
+	for _, c := range classes {
+		if c.Flagged > 0 {
+			affected = append(affected, c)
+		}
+	}
+	sort.SliceStable(affected, func(i, j int) bool { return affected[i].Flagged > affected[j].Flagged })
+	for i, c := range affected {
+		if i == summaryClasses {
+			s.Classes = append(s.Classes, fmt.Sprintf("%d other classes hold the remaining flagged samples.", len(affected)-i))
+			break
+		}
+		line := fmt.Sprintf("Class %d holds %.0f%% of the flagged samples (%.1f%% of the class)", c.Label, 100*c.Share, 100*c.FlaggedRate)
+		if c.DominantType != "" {
+			line += ", mostly " + strings.ReplaceAll(string(c.DominantType), "_", " ")
+		}
+		s.Classes = append(s.Classes, line+".")
+	}
+	// A class holds a share of the findings well above its share of the
+	// classes when an attack targets it.
+	if len(affected) > 0 && len(classes) > 1 && affected[0].Share >= math.Max(0.5, 1.5/float64(len(classes))) {
+		s.Classes = append(s.Classes, fmt.Sprintf("Findings concentrate in class %d, the mark of an attack targeting that class.", affected[0].Label))
+	}
+
+	s.Confidence, s.ConfidenceReasons = summaryConfidence(result)
+	return s
+}
+
+// summaryConfidence rates how far the findings of result can be trusted,
+// from the confidence of the checks, the scope of the scan and review.
+func summaryConfidence(result *detect.DetectionResult) (string, []string) {
+	var reasons []string
+	rank := 2 // high
+	lower := func(to int, reason string) {
+		rank = min(rank, to)
+		reasons = append(reasons, reason)
+	}
+
+	var confidence float64
+	var flagged, confirmed, rejected int
+	for _, sample := range result.Samples {
+		if !sample.IsPoisoned {
+			continue
+		}
+		flagged++
+		confidence += sample.Confidence
+		switch allowlist.Decision(sample.Review) {
+		case allowlist.DecisionAccepted:
+			confirmed++
+		case allowlist.DecisionFalsePositive:
+			rejected++
+		}
+	}
+	if flagged > 0 {
+		mean := confidence / float64(flagged)
+		switch {
+		case mean < 0.4:
+			lower(0, fmt.Sprintf("The checks are unsure of their findings (mean confidence %.0f%%).", 100*mean))
+		case mean < 0.7:
+			lower(1, fmt.Sprintf("The checks are moderately sure of their findings (mean confidence %.0f%%).", 100*mean))
+		default:
+			reasons = append(reasons, fmt.Sprintf("The checks are sure of their findings (mean confidence %.0f%%).", 100*mean))
+		}
+	}
+	if p := result.Partial; p != nil {
+		lower(1, fmt.Sprintf("Only %d of %d samples were scanned.", p.ScannedSamples, p.TotalSamples))
+	}
+	if sh := result.Shard; sh != nil {
+		lower(1, fmt.Sprintf("Only shards %d-%d of %d were scanned.", sh.From, sh.To, sh.Count))
+	}
+	if v := result.Version; v != nil && v.Modified {
+		lower(1, "The dataset changed after its version was recorded.")
+	}
+	switch {
+	case rejected > 0 && 2*rejected >= flagged:
+		lower(0, fmt.Sprintf("Reviewers rejected %d of %d findings as false positives.", rejected, flagged))
+	case confirmed > 0 || rejected > 0:
+		reasons = append(reasons, fmt.Sprintf("Reviewers confirmed %d and rejected %d of %d findings.", confirmed, rejected, flagged))
+	case flagged > 0:
+		reasons = append(reasons, "No finding has been reviewed yet.")
+	}
+	if len(reasons) == 0 {
+		reasons = append(reasons, "Every sample was scanned by every check.")
+	}
+	return []string{ConfidenceLow, ConfidenceMedium, ConfidenceHigh}[rank], reasons
+}
+
+// summaryTemplate is the text layout of a Summary.
+var summaryTemplate = template.Must(template.New("summary").Funcs(template.FuncMap{
+	"percent": detect.TemplateFuncs["percent"],
+}).Parse(`=== Executive Summary{{with .Dataset}}: {{.}}{{end}} ===
+
+{{.Verdict}}
+
+Overall risk: {{.Level}} ({{percent .RiskScore}}). {{.Flagged}} of {{.Samples}} samples were flagged ({{percent .FlaggedRate 1}}).
+{{- with .Classes}}
+
+Affected classes:
+{{- range .}}
+  - {{.}}
+{{- end}}
+{{- end}}
+
+Recommended action: {{.Action}}
+
+Confidence: {{.Confidence}}
+{{- range .ConfidenceReasons}}
+  - {{.}}
+{{- end}}
+`))
+
+// WriteSummary writes s as plain text.
+func WriteSummary(w io.Writer, s Summary) error {
+	return summaryTemplate.Execute(w, s)
+}
@@ -0,0 +1,138 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/allowlist"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// classResult returns a scan of 100 samples in four classes flagging
+// flagged[c] samples of class c as backdoors with the given confidence.
+func classResult(risk, confidence float64, flagged map[int]int) *detect.DetectionResult {
+	result := &detect.DetectionResult{SampleCount: 100, RiskScore: risk, Method: "statistical"}
+	for i := 0; i < 100; i++ {
+		sample := detect.PoisonedSample{ID: fmt.Sprintf("s%d", i), Label: i % 4, Score: 0.1}
+		if flagged[sample.Label] > 0 {
+			flagged[sample.Label]--
+			sample.IsPoisoned, sample.Type, sample.Score, sample.Confidence = true, detect.TypeBackdoor, 0.9, confidence
+			result.PoisonedCount++
+		}
+		result.Samples = append(result.Samples, sample)
+	}
+	result.IsPoisoned = result.PoisonedCount > 0
+	return result
+}
+
+func TestSummaryGolden(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		result  *detect.DetectionResult
+		dataset string
+		want    string
+	}{
+		{"clean", classResult(0, 0, nil), "train.csv", `=== Executive Summary: train.csv ===
+
+No signs of poisoning were found.
+
+Overall risk: MINIMAL (0%). 0 of 100 samples were flagged (0.0%).
+
+Recommended action: No action needed beyond routine monitoring; scan again when the dataset changes.
+
+Confidence: high
+  - Every sample was scanned by every check.
+`},
+		{"targeted", classResult(0.6, 0.9, map[int]int{2: 10, 1: 2}), "", `=== Executive Summary ===
+
+The training data is likely poisoned.
+
+Overall risk: HIGH (60%). 12 of 100 samples were flagged (12.0%).
+
+Affected classes:
+  - Class 2 holds 83% of the flagged samples (40.0% of the class), mostly backdoor.
+  - Class 1 holds 17% of the flagged samples (8.0% of the class), mostly backdoor.
+  - Findings concentrate in class 2, the mark of an attack targeting that class.
+
+Recommended action: Hold training and investigate where the flagged samples came from before cleaning the dataset.
+
+Confidence: high
+  - The checks are sure of their findings (mean confidence 90%).
+  - No finding has been reviewed yet.
+`},
+	} {
+		var b strings.Builder
+		if err := WriteSummary(&b, NewSummary(test.result, test.dataset)); err != nil {
+			t.Fatal(err)
+		}
+		if got := b.String(); got != test.want {
+			t.Errorf("%s summary:\n%s\nwant:\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSummaryVerdicts(t *testing.T) {
+	for _, test := range []struct {
+		risk    float64
+		verdict string
+		action  detect.Severity
+	}{
+		// Flagged samples at minimal risk still call for a review.
+		{0.05, "Some suspicious samples were found, but the overall risk of poisoning is low.", detect.SeverityLow},
+		{0.2, "Some suspicious samples were found, but the overall risk of poisoning is low.", detect.SeverityLow},
+		{0.4, "The training data may be poisoned.", detect.SeverityMedium},
+		{0.8, "The training data is likely poisoned.", detect.SeverityCritical},
+	} {
+		s := NewSummary(classResult(test.risk, 0.9, map[int]int{0: 1, 1: 1, 2: 1, 3: 1}), "")
+		if s.Verdict != test.verdict || s.Action != summaryActions[test.action] || s.FlaggedRate != 0.04 {
+			t.Errorf("risk %v: verdict %q, action %q, flagged rate %v", test.risk, s.Verdict, s.Action, s.FlaggedRate)
+		}
+		// Evenly spread findings name the first classes and the rest, and
+		// do not concentrate.
+		if len(s.Classes) != 4 || s.Classes[3] != "1 other classes hold the remaining flagged samples." {
+			t.Errorf("risk %v: classes %q", test.risk, s.Classes)
+		}
+	}
+}
+
+func TestSummaryConfidence(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		result func() *detect.DetectionResult
+		want   string
+		reason string
+	}{
+		{"unsure checks", func() *detect.DetectionResult { return classResult(0.3, 0.2, map[int]int{1: 4}) }, ConfidenceLow, "mean confidence 20%"},
+		{"moderate checks", func() *detect.DetectionResult { return classResult(0.3, 0.5, map[int]int{1: 4}) }, ConfidenceMedium, "moderately sure"},
+		{"partial scan", func() *detect.DetectionResult {
+			r := classResult(0.3, 0.9, map[int]int{1: 4})
+			r.MarkPartial(detect.PartialSample, 400)
+			return r
+		}, ConfidenceMedium, "Only 100 of 400 samples were scanned."},
+		{"modified dataset", func() *detect.DetectionResult {
+			r := classResult(0, 0, nil)
+			r.Version = &detect.DatasetVersion{Path: "d.csv", Modified: true}
+			return r
+		}, ConfidenceMedium, "changed after its version was recorded"},
+		{"rejected findings", func() *detect.DetectionResult {
+			r := classResult(0.3, 0.9, map[int]int{1: 4})
+			for i := range r.Samples[:12] {
+				if r.Samples[i].IsPoisoned && i%8 == 1 {
+					r.Samples[i].Review = string(allowlist.DecisionFalsePositive)
+				}
+			}
+			return r
+		}, ConfidenceLow, "Reviewers rejected 2 of 4 findings as false positives."},
+		{"reviewed findings", func() *detect.DetectionResult {
+			r := classResult(0.3, 0.9, map[int]int{1: 4})
+			r.Samples[1].Review = string(allowlist.DecisionAccepted)
+			return r
+		}, ConfidenceHigh, "Reviewers confirmed 1 and rejected 0 of 4 findings."},
+	} {
+		s := NewSummary(test.result(), "")
+		if s.Confidence != test.want || !strings.Contains(strings.Join(s.ConfidenceReasons, "\n"), test.reason) {
+			t.Errorf("%s: confidence %s for %q, want %s for %q", test.name, s.Confidence, s.ConfidenceReasons, test.want, test.reason)
+		}
+	}
+}
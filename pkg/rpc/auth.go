@@ -0,0 +1,93 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/hallucinaut/modelpoison/pkg/auth"
+	pb "github.com/hallucinaut/modelpoison/pkg/rpc/modelpoisonv1"
+)
+
+// methodScopes maps the service's methods to the scope they need.
+var methodScopes = map[string]auth.Scope{
+	pb.ModelPoison_Detect_FullMethodName:         auth.ScopeScan,
+	pb.ModelPoison_StreamDetect_FullMethodName:   auth.ScopeScan,
+	pb.ModelPoison_Defend_FullMethodName:         auth.ScopeDefend,
+	pb.ModelPoison_ListStrategies_FullMethodName: auth.ScopeRead,
+}
+
+// Auth returns server options that authenticate every call with a and
+// check the scope of the called method. The gRPC health service stays
+// open so probes work without credentials.
+func Auth(a *auth.Authenticator) []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			ctx, err := authorize(ctx, a, info.FullMethod)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := authorize(stream.Context(), a, info.FullMethod)
+			if err != nil {
+				return err
+			}
+			return handler(srv, &authStream{ServerStream: stream, ctx: ctx})
+		}),
+	}
+}
+
+// authorize authenticates the caller of method from the authorization
+// metadata or its TLS client certificate.
+func authorize(ctx context.Context, a *auth.Authenticator, method string) (context.Context, error) {
+	if isHealth(method) {
+		return ctx, nil
+	}
+
+	var authorization string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			authorization = values[0]
+		}
+	}
+	var state *tls.ConnectionState
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			state = &info.State
+		}
+	}
+
+	identity, err := a.Authenticate(authorization, state)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	scope, ok := methodScopes[method]
+	if !ok || !identity.Allows(scope) {
+		return nil, status.Errorf(codes.PermissionDenied, "%s may not call %s", identity.Name, method)
+	}
+	return auth.NewContext(ctx, identity), nil
+}
+
+// isHealth reports whether method is of the gRPC health service.
+func isHealth(method string) bool {
+	return strings.HasPrefix(method, "/grpc.health.v1.Health/")
+}
+
+// authStream carries the authenticated identity in its context.
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authStream) Context() context.Context {
+	return s.ctx
+}
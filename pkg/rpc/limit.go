@@ -0,0 +1,67 @@
+package rpc
+
+import (
+	"context"
+	"math"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/hallucinaut/modelpoison/internal/ratelimit"
+)
+
+// Limit returns server options capping the size of received messages at
+// maxBytes and the calls of each client at rate per second with bursts
+// of burst (default rate, rounded up), as the REST API does. When
+// maxBytes is 0, message sizes are not limited, and when rate is 0, calls
+// are not. Clients are identified by their IP address and limited before
+// they are authenticated, so given before Auth, failed authentication
+// attempts are limited too. Calls beyond the rate fail with
+// ResourceExhausted; streams count as one call.
+func Limit(maxBytes int, rate float64, burst int) []grpc.ServerOption {
+	if maxBytes <= 0 || maxBytes > math.MaxInt32 {
+		maxBytes = math.MaxInt32
+	}
+	opts := []grpc.ServerOption{grpc.MaxRecvMsgSize(maxBytes)}
+	if rate <= 0 {
+		return opts
+	}
+	limiter := ratelimit.New(rate, burst)
+	return append(opts,
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := allow(ctx, limiter, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := allow(stream.Context(), limiter, info.FullMethod); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	)
+}
+
+// allow takes a token for a call of method from the bucket of its
+// client's address. Health checks are not limited.
+func allow(ctx context.Context, limiter *ratelimit.Limiter, method string) error {
+	if isHealth(method) {
+		return nil
+	}
+	key := "unknown"
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		key = p.Addr.String()
+		if host, _, err := net.SplitHostPort(key); err == nil {
+			key = host
+		}
+	}
+	if ok, wait := limiter.Allow("ip:"+key, time.Now()); !ok {
+		return status.Errorf(codes.ResourceExhausted, "rate limit exceeded; retry in %s", wait.Round(time.Millisecond))
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.24.4
+// source: modelpoison/v1/modelpoison.proto
+
+package modelpoisonv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	ModelPoison_Detect_FullMethodName         = "/modelpoison.v1.ModelPoison/Detect"
+	ModelPoison_StreamDetect_FullMethodName   = "/modelpoison.v1.ModelPoison/StreamDetect"
+	ModelPoison_Defend_FullMethodName         = "/modelpoison.v1.ModelPoison/Defend"
+	ModelPoison_ListStrategies_FullMethodName = "/modelpoison.v1.ModelPoison/ListStrategies"
+)
+
+// ModelPoisonClient is the client API for ModelPoison service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ModelPoisonClient interface {
+	// Detect scans a batch of samples and returns the full result.
+	Detect(ctx context.Context, in *DetectRequest, opts ...grpc.CallOption) (*DetectionResult, error)
+	// StreamDetect scans samples as they are sent. The first message may
+	// carry options; every later message carries samples. A finding is
+	// streamed back for each flagged sample, followed by a final summary
+	// once the client closes its side of the stream.
+	StreamDetect(ctx context.Context, opts ...grpc.CallOption) (ModelPoison_StreamDetectClient, error)
+	// Defend applies a filtering defense and reports the removed samples.
+	Defend(ctx context.Context, in *DefendRequest, opts ...grpc.CallOption) (*DefendResponse, error)
+	// ListStrategies lists the available defense strategies.
+	ListStrategies(ctx context.Context, in *ListStrategiesRequest, opts ...grpc.CallOption) (*ListStrategiesResponse, error)
+}
+
+type modelPoisonClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewModelPoisonClient(cc grpc.ClientConnInterface) ModelPoisonClient {
+	return &modelPoisonClient{cc}
+}
+
+func (c *modelPoisonClient) Detect(ctx context.Context, in *DetectRequest, opts ...grpc.CallOption) (*DetectionResult, error) {
+	out := new(DetectionResult)
+	err := c.cc.Invoke(ctx, ModelPoison_Detect_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *modelPoisonClient) StreamDetect(ctx context.Context, opts ...grpc.CallOption) (ModelPoison_StreamDetectClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ModelPoison_ServiceDesc.Streams[0], ModelPoison_StreamDetect_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &modelPoisonStreamDetectClient{stream}
+	return x, nil
+}
+
+type ModelPoison_StreamDetectClient interface {
+	Send(*StreamDetectRequest) error
+	Recv() (*StreamDetectResponse, error)
+	grpc.ClientStream
+}
+
+type modelPoisonStreamDetectClient struct {
+	grpc.ClientStream
+}
+
+func (x *modelPoisonStreamDetectClient) Send(m *StreamDetectRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *modelPoisonStreamDetectClient) Recv() (*StreamDetectResponse, error) {
+	m := new(StreamDetectResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *modelPoisonClient) Defend(ctx context.Context, in *DefendRequest, opts ...grpc.CallOption) (*DefendResponse, error) {
+	out := new(DefendResponse)
+	err := c.cc.Invoke(ctx, ModelPoison_Defend_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *modelPoisonClient) ListStrategies(ctx context.Context, in *ListStrategiesRequest, opts ...grpc.CallOption) (*ListStrategiesResponse, error) {
+	out := new(ListStrategiesResponse)
+	err := c.cc.Invoke(ctx, ModelPoison_ListStrategies_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ModelPoisonServer is the server API for ModelPoison service.
+// All implementations must embed UnimplementedModelPoisonServer
+// for forward compatibility
+type ModelPoisonServer interface {
+	// Detect scans a batch of samples and returns the full result.
+	Detect(context.Context, *DetectRequest) (*DetectionResult, error)
+	// StreamDetect scans samples as they are sent. The first message may
+	// carry options; every later message carries samples. A finding is
+	// streamed back for each flagged sample, followed by a final summary
+	// once the client closes its side of the stream.
+	StreamDetect(ModelPoison_StreamDetectServer) error
+	// Defend applies a filtering defense and reports the removed samples.
+	Defend(context.Context, *DefendRequest) (*DefendResponse, error)
+	// ListStrategies lists the available defense strategies.
+	ListStrategies(context.Context, *ListStrategiesRequest) (*ListStrategiesResponse, error)
+	mustEmbedUnimplementedModelPoisonServer()
+}
+
+// UnimplementedModelPoisonServer must be embedded to have forward compatible implementations.
+type UnimplementedModelPoisonServer struct {
+}
+
+func (UnimplementedModelPoisonServer) Detect(context.Context, *DetectRequest) (*DetectionResult, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Detect not implemented")
+}
+func (UnimplementedModelPoisonServer) StreamDetect(ModelPoison_StreamDetectServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamDetect not implemented")
+}
+func (UnimplementedModelPoisonServer) Defend(context.Context, *DefendRequest) (*DefendResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Defend not implemented")
+}
+func (UnimplementedModelPoisonServer) ListStrategies(context.Context, *ListStrategiesRequest) (*ListStrategiesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListStrategies not implemented")
+}
+func (UnimplementedModelPoisonServer) mustEmbedUnimplementedModelPoisonServer() {}
+
+// UnsafeModelPoisonServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ModelPoisonServer will
+// result in compilation errors.
+type UnsafeModelPoisonServer interface {
+	mustEmbedUnimplementedModelPoisonServer()
+}
+
+func RegisterModelPoisonServer(s grpc.ServiceRegistrar, srv ModelPoisonServer) {
+	s.RegisterService(&ModelPoison_ServiceDesc, srv)
+}
+
+func _ModelPoison_Detect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DetectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ModelPoisonServer).Detect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ModelPoison_Detect_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ModelPoisonServer).Detect(ctx, req.(*DetectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ModelPoison_StreamDetect_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ModelPoisonServer).StreamDetect(&modelPoisonStreamDetectServer{stream})
+}
+
+type ModelPoison_StreamDetectServer interface {
+	Send(*StreamDetectResponse) error
+	Recv() (*StreamDetectRequest, error)
+	grpc.ServerStream
+}
+
+type modelPoisonStreamDetectServer struct {
+	grpc.ServerStream
+}
+
+func (x *modelPoisonStreamDetectServer) Send(m *StreamDetectResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *modelPoisonStreamDetectServer) Recv() (*StreamDetectRequest, error) {
+	m := new(StreamDetectRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _ModelPoison_Defend_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DefendRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ModelPoisonServer).Defend(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ModelPoison_Defend_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ModelPoisonServer).Defend(ctx, req.(*DefendRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ModelPoison_ListStrategies_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListStrategiesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ModelPoisonServer).ListStrategies(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ModelPoison_ListStrategies_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ModelPoisonServer).ListStrategies(ctx, req.(*ListStrategiesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ModelPoison_ServiceDesc is the grpc.ServiceDesc for ModelPoison service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ModelPoison_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "modelpoison.v1.ModelPoison",
+	HandlerType: (*ModelPoisonServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Detect",
+			Handler:    _ModelPoison_Detect_Handler,
+		},
+		{
+			MethodName: "Defend",
+			Handler:    _ModelPoison_Defend_Handler,
+		},
+		{
+			MethodName: "ListStrategies",
+			Handler:    _ModelPoison_ListStrategies_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamDetect",
+			Handler:       _ModelPoison_StreamDetect_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "modelpoison/v1/modelpoison.proto",
+}
@@ -0,0 +1,308 @@
+// Package rpc implements the ModelPoison gRPC service defined in
+// proto/modelpoison/v1/modelpoison.proto.
+package rpc
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=github.com/hallucinaut/modelpoison --go-grpc_out=../.. --go-grpc_opt=module=github.com/hallucinaut/modelpoison modelpoison/v1/modelpoison.proto
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/hallucinaut/modelpoison/pkg/audit"
+	"github.com/hallucinaut/modelpoison/pkg/auth"
+	"github.com/hallucinaut/modelpoison/pkg/config"
+	"github.com/hallucinaut/modelpoison/pkg/defend"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	pb "github.com/hallucinaut/modelpoison/pkg/rpc/modelpoisonv1"
+)
+
+// Server implements the ModelPoison service.
+type Server struct {
+	pb.UnimplementedModelPoisonServer
+
+	config *config.Config
+	audit  *audit.Log
+}
+
+// New creates a service whose scans use cfg before any per-request
+// thresholds. cfg may be nil.
+func New(cfg *config.Config) *Server {
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	return &Server{config: cfg}
+}
+
+// SetAudit makes the service record its scans and defenses in log,
+// attributed to the authenticated caller. A call whose record cannot be
+// written fails.
+func (s *Server) SetAudit(log *audit.Log) {
+	s.audit = log
+}
+
+// record appends a record of a call to the audit log, if set.
+func (s *Server) record(ctx context.Context, action audit.Action, details map[string]string) error {
+	if s.audit == nil {
+		return nil
+	}
+	record := audit.Record{Action: action, Subject: "grpc", Details: details}
+	if identity, ok := auth.FromContext(ctx); ok {
+		record.Actor = identity.Name
+	}
+	if _, err := s.audit.Append(record); err != nil {
+		return status.Errorf(codes.Internal, "audit log: %v", err)
+	}
+	return nil
+}
+
+// Register registers the service with a gRPC server.
+func (s *Server) Register(registrar grpc.ServiceRegistrar) {
+	pb.RegisterModelPoisonServer(registrar, s)
+}
+
+// Detect scans a batch of samples.
+func (s *Server) Detect(ctx context.Context, req *pb.DetectRequest) (*pb.DetectionResult, error) {
+	detector, err := s.newDetector(ctx, req.GetOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	samples := fromSamples(req.GetSamples())
+	if err := detector.Validate(samples); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	result := detector.DetectContext(ctx, samples)
+	if err := s.record(ctx, audit.ActionScan, audit.ScanDetails(result)); err != nil {
+		return nil, err
+	}
+
+	out := &pb.DetectionResult{
+		IsPoisoned:    result.IsPoisoned,
+		SampleCount:   int64(result.SampleCount),
+		PoisonedCount: int64(result.PoisonedCount),
+		RiskScore:     result.RiskScore,
+		Method:        result.Method,
+		Samples:       make([]*pb.Finding, len(result.Samples)),
+	}
+	for i, finding := range result.Samples {
+		out.Samples[i] = toFinding(finding, i)
+	}
+	return out, nil
+}
+
+// StreamDetect scans samples as they arrive, streaming back findings for
+// flagged samples and a summary when the client finishes sending. Findings
+// are sent as they are found, so gRPC flow control holds back a scan whose
+// client is slow to receive them, and the summary is tallied without
+// keeping them.
+func (s *Server) StreamDetect(stream pb.ModelPoison_StreamDetectServer) error {
+	var (
+		detector *detect.Detector
+		tally    *detect.Tally
+		count    int
+	)
+
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		switch payload := req.GetPayload().(type) {
+		case *pb.StreamDetectRequest_Options:
+			if detector != nil {
+				return status.Error(codes.InvalidArgument, "options must be sent before any samples")
+			}
+			if detector, err = s.newDetector(stream.Context(), payload.Options); err != nil {
+				return err
+			}
+			tally = detector.NewTally()
+		case *pb.StreamDetectRequest_Samples:
+			if detector == nil {
+				if detector, err = s.newDetector(stream.Context(), nil); err != nil {
+					return err
+				}
+				tally = detector.NewTally()
+			}
+			samples := fromSamples(payload.Samples.GetSamples())
+			if err := detector.Validate(samples); err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+			for _, sample := range samples {
+				finding := detector.Analyze(sample)
+				tally.Add(finding)
+				if finding.IsPoisoned {
+					if err := stream.Send(&pb.StreamDetectResponse{
+						Payload: &pb.StreamDetectResponse_Finding{Finding: toFinding(finding, count)},
+					}); err != nil {
+						return err
+					}
+				}
+				count++
+			}
+		default:
+			return status.Error(codes.InvalidArgument, "empty request")
+		}
+	}
+
+	if detector == nil {
+		var err error
+		if detector, err = s.newDetector(stream.Context(), nil); err != nil {
+			return err
+		}
+		tally = detector.NewTally()
+	}
+	result := tally.Result()
+	if err := s.record(stream.Context(), audit.ActionScan, audit.ScanDetails(result)); err != nil {
+		return err
+	}
+
+	return stream.Send(&pb.StreamDetectResponse{
+		Payload: &pb.StreamDetectResponse_Summary{Summary: &pb.DetectionSummary{
+			IsPoisoned:    result.IsPoisoned,
+			SampleCount:   int64(result.SampleCount),
+			PoisonedCount: int64(result.PoisonedCount),
+			RiskScore:     result.RiskScore,
+			Method:        result.Method,
+		}},
+	})
+}
+
+// Defend applies a filtering defense to a batch of samples.
+func (s *Server) Defend(ctx context.Context, req *pb.DefendRequest) (*pb.DefendResponse, error) {
+	if _, err := s.project(ctx, req.GetProject()); err != nil {
+		return nil, err
+	}
+	defender := defend.NewDefender()
+	strategy, ok := defender.Strategy(req.GetStrategy())
+	if !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "unknown strategy %q", req.GetStrategy())
+	}
+	if !strategy.FiltersSamples() {
+		return nil, status.Errorf(codes.InvalidArgument, "strategy %q does not remove samples from a dataset", req.GetStrategy())
+	}
+
+	samples := fromSamples(req.GetSamples())
+	removed := defender.RemovedIndicesContext(ctx, samples, strategy.Name)
+
+	resp := &pb.DefendResponse{
+		Strategy: strategy.Name,
+		Removed:  make([]string, len(removed)),
+		Kept:     int64(len(samples) - len(removed)),
+	}
+	for i, index := range removed {
+		resp.Removed[i] = samples[index].ID
+	}
+
+	risk := 0.0
+	if len(samples) > 0 {
+		risk = float64(len(removed)) / float64(len(samples))
+	}
+	result := defender.Defend(risk, strategy.Name)
+	resp.Improvement = result.Improvement
+	resp.RiskReduction = result.RiskReduction
+	resp.Cost = result.Cost
+
+	details := map[string]string{
+		"strategy": strategy.Name,
+		"samples":  strconv.Itoa(len(samples)),
+		"removed":  strconv.Itoa(len(removed)),
+	}
+	if len(removed) > 0 {
+		details["removed_ids"] = audit.IDs(resp.Removed)
+	}
+	if err := s.record(ctx, audit.ActionDefense, details); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ListStrategies lists defense strategies.
+func (s *Server) ListStrategies(ctx context.Context, req *pb.ListStrategiesRequest) (*pb.ListStrategiesResponse, error) {
+	resp := &pb.ListStrategiesResponse{}
+	for _, strategy := range defend.NewDefender().Strategies() {
+		if req.GetType() != "" && !strings.EqualFold(strategy.Type, req.GetType()) {
+			continue
+		}
+		resp.Strategies = append(resp.Strategies, &pb.Strategy{
+			Name:          strategy.Name,
+			Description:   strategy.Description,
+			Effectiveness: strategy.Effectiveness,
+			Overhead:      strategy.Overhead,
+			Type:          strategy.Type,
+			Constraints:   strategy.Constraints,
+		})
+	}
+	return resp, nil
+}
+
+// project returns the configuration of the named project (default: the
+// default project), failing when the caller may not access it.
+func (s *Server) project(ctx context.Context, name string) (*config.Config, error) {
+	if name == "" {
+		name = config.DefaultProject
+	}
+	if identity, ok := auth.FromContext(ctx); ok && !identity.InProject(name) {
+		return nil, status.Errorf(codes.PermissionDenied, "%s may not access project %q", identity.Name, name)
+	}
+	cfg, ok := s.config.ForProject(name)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no project %q", name)
+	}
+	return cfg, nil
+}
+
+// newDetector creates a detector with the configuration of the project of
+// opts and the request's threshold overrides.
+func (s *Server) newDetector(ctx context.Context, opts *pb.DetectOptions) (*detect.Detector, error) {
+	cfg, err := s.project(ctx, opts.GetProject())
+	if err != nil {
+		return nil, err
+	}
+	detector := detect.NewDetector()
+	if err := cfg.Apply(detector); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if err := (&config.Config{Thresholds: opts.GetThresholds()}).Apply(detector); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return detector, nil
+}
+
+// fromSamples converts protobuf samples.
+func fromSamples(samples []*pb.Sample) []detect.Sample {
+	out := make([]detect.Sample, len(samples))
+	for i, sample := range samples {
+		out[i] = detect.Sample{
+			ID:       sample.GetId(),
+			Features: sample.GetFeatures(),
+			Label:    int(sample.GetLabel()),
+		}
+	}
+	return out
+}
+
+// toFinding converts the finding for the sample at index.
+func toFinding(finding detect.PoisonedSample, index int) *pb.Finding {
+	return &pb.Finding{
+		Id:          finding.ID,
+		Label:       int64(finding.Label),
+		IsPoisoned:  finding.IsPoisoned,
+		Score:       finding.Score,
+		Type:        string(finding.Type),
+		Description: finding.Description,
+		Evidence:    finding.Evidence,
+		Confidence:  finding.Confidence,
+		Index:       int64(index),
+	}
+}
@@ -0,0 +1,206 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/hallucinaut/modelpoison/pkg/auth"
+	"github.com/hallucinaut/modelpoison/pkg/config"
+	pb "github.com/hallucinaut/modelpoison/pkg/rpc/modelpoisonv1"
+)
+
+func TestStreamDetect(t *testing.T) {
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	New(nil).Register(server)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	stream, err := pb.NewModelPoisonClient(conn).StreamDetect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := []*pb.Sample{
+		{Id: "clean", Features: []float64{1, 1.1, 0.9, 1, 1.05}},
+		{Id: "spike", Features: append(make([]float64, 20), 50)},
+	}
+	if err := stream.Send(&pb.StreamDetectRequest{Payload: &pb.StreamDetectRequest_Samples{
+		Samples: &pb.SampleBatch{Samples: samples},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+
+	var findings []*pb.Finding
+	var summary *pb.DetectionSummary
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f := resp.GetFinding(); f != nil {
+			findings = append(findings, f)
+		}
+		if s := resp.GetSummary(); s != nil {
+			summary = s
+		}
+	}
+
+	if len(findings) != 1 || findings[0].GetId() != "spike" || findings[0].GetIndex() != 1 {
+		t.Errorf("findings = %v, want only spike at index 1", findings)
+	}
+	if summary == nil || summary.GetSampleCount() != 2 || summary.GetPoisonedCount() != 1 {
+		t.Errorf("summary = %v, want 1 of 2 samples poisoned", summary)
+	}
+}
+
+func TestAuthChecksScopes(t *testing.T) {
+	authenticator := auth.New(auth.Config{Tokens: []auth.Token{
+		{Name: "dashboard", Token: "view", Scopes: []auth.Scope{auth.ScopeRead}},
+	}})
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(Auth(authenticator)...)
+	New(nil).Register(server)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := pb.NewModelPoisonClient(conn)
+
+	if _, err := client.ListStrategies(context.Background(), &pb.ListStrategiesRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("ListStrategies without a token = %v, want Unauthenticated", err)
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer view")
+	if _, err := client.ListStrategies(ctx, &pb.ListStrategiesRequest{}); err != nil {
+		t.Errorf("ListStrategies with the read scope: %v", err)
+	}
+	if _, err := client.Detect(ctx, &pb.DetectRequest{}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Detect with the read scope = %v, want PermissionDenied", err)
+	}
+}
+
+// serve serves the service with opts, returning a client of it.
+func serve(t *testing.T, svc *Server, opts ...grpc.ServerOption) pb.ModelPoisonClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(opts...)
+	svc.Register(server)
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewModelPoisonClient(conn)
+}
+
+func TestLimitRate(t *testing.T) {
+	authenticator := auth.New(auth.Config{Tokens: []auth.Token{
+		{Name: "dashboard", Token: "view", Scopes: []auth.Scope{auth.ScopeRead}},
+	}})
+	opts := append(Limit(0, 1, 2), Auth(authenticator)...)
+	client := serve(t, New(nil), opts...)
+
+	// Failed authentication attempts are limited too.
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer guess")
+	for i, want := range []codes.Code{codes.Unauthenticated, codes.Unauthenticated, codes.ResourceExhausted} {
+		if _, err := client.ListStrategies(ctx, &pb.ListStrategiesRequest{}); status.Code(err) != want {
+			t.Errorf("call %d = %v, want %v", i+1, err, want)
+		}
+	}
+	stream, err := client.StreamDetect(ctx)
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("StreamDetect = %v, want ResourceExhausted", err)
+	}
+}
+
+func TestLimitMessageSize(t *testing.T) {
+	client := serve(t, New(nil), Limit(1024, 0, 0)...)
+	small := &pb.DetectRequest{Samples: []*pb.Sample{{Id: "a", Features: []float64{1, 2}}}}
+	if _, err := client.Detect(context.Background(), small); err != nil {
+		t.Fatalf("small request: %v", err)
+	}
+	large := &pb.DetectRequest{Samples: []*pb.Sample{{Id: "a", Features: make([]float64, 1024)}}}
+	if _, err := client.Detect(context.Background(), large); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("request over the limit = %v, want ResourceExhausted", err)
+	}
+}
+
+func TestProjectScoping(t *testing.T) {
+	cfg := &config.Config{Projects: map[string]config.Project{
+		"fraud":  {Thresholds: map[string]float64{"label_flip": 0.9}},
+		"vision": {},
+	}}
+	authenticator := auth.New(auth.Config{Tokens: []auth.Token{
+		{Name: "fraud-team", Token: "fraud", Scopes: []auth.Scope{auth.ScopeScan, auth.ScopeDefend}, Projects: []string{"fraud"}},
+	}})
+	client := serve(t, New(cfg), Auth(authenticator)...)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer fraud")
+	samples := []*pb.Sample{{Id: "a", Features: []float64{1, 2}}}
+
+	if _, err := client.Detect(ctx, &pb.DetectRequest{Samples: samples, Options: &pb.DetectOptions{Project: "fraud"}}); err != nil {
+		t.Errorf("Detect in an allowed project: %v", err)
+	}
+	for name, project := range map[string]string{"other project": "vision", "default project": ""} {
+		if _, err := client.Detect(ctx, &pb.DetectRequest{Samples: samples, Options: &pb.DetectOptions{Project: project}}); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("Detect in the %s = %v, want PermissionDenied", name, err)
+		}
+	}
+	if _, err := client.Defend(ctx, &pb.DefendRequest{Samples: samples, Strategy: "Data Cleaning", Project: "vision"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Defend in another project = %v, want PermissionDenied", err)
+	}
+
+	stream, err := client.StreamDetect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&pb.StreamDetectRequest{Payload: &pb.StreamDetectRequest_Options{
+		Options: &pb.DetectOptions{Project: "vision"},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("StreamDetect in another project = %v, want PermissionDenied", err)
+	}
+
+	// Unknown projects are not found, for callers allowed every project.
+	if _, err := serve(t, New(cfg)).Detect(context.Background(), &pb.DetectRequest{Options: &pb.DetectOptions{Project: "nope"}}); status.Code(err) != codes.NotFound {
+		t.Errorf("Detect in an unknown project = %v, want NotFound", err)
+	}
+}
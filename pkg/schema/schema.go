@@ -0,0 +1,364 @@
+// Package schema validates datasets against a declared schema before they
+// are scanned.
+package schema
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Column types.
+const (
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeBool    = "bool"
+	TypeString  = "string"
+)
+
+// Schema describes the expected shape of a CSV dataset.
+type Schema struct {
+	// Columns lists the expected columns.
+	Columns []Column `yaml:"columns"`
+	// AllowExtraColumns permits columns not listed in Columns.
+	AllowExtraColumns bool `yaml:"allow_extra_columns,omitempty"`
+	// MinRows and MaxRows bound the number of data rows (0 means no bound).
+	MinRows int `yaml:"min_rows,omitempty"`
+	MaxRows int `yaml:"max_rows,omitempty"`
+	// Label restricts the label domain.
+	Label *Label `yaml:"label,omitempty"`
+	// SHA256 is the expected hex checksum of the dataset file.
+	SHA256 string `yaml:"sha256,omitempty"`
+}
+
+// Column describes one expected column.
+type Column struct {
+	Name string `yaml:"name"`
+	// Type is number, integer, bool or string (default number).
+	Type string `yaml:"type,omitempty"`
+	// Required rejects missing values (empty, NA, NaN or null).
+	Required bool `yaml:"required,omitempty"`
+	// Min and Max bound numeric values.
+	Min *float64 `yaml:"min,omitempty"`
+	Max *float64 `yaml:"max,omitempty"`
+	// Values lists the allowed values.
+	Values []string `yaml:"values,omitempty"`
+}
+
+// Label describes the label column and its allowed classes.
+type Label struct {
+	Column string `yaml:"column,omitempty"`
+	Values []int  `yaml:"values"`
+}
+
+// Error is a single validation failure. Row is the 1-based data row, or 0
+// for dataset-level errors.
+type Error struct {
+	Row     int    `json:"row,omitempty"`
+	Column  string `json:"column,omitempty"`
+	Rule    string `json:"rule"`
+	Value   string `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e Error) Error() string {
+	switch {
+	case e.Row > 0 && e.Column != "":
+		return fmt.Sprintf("row %d: column %q: %s", e.Row, e.Column, e.Message)
+	case e.Column != "":
+		return fmt.Sprintf("column %q: %s", e.Column, e.Message)
+	}
+	return e.Message
+}
+
+// Report holds the outcome of a validation.
+type Report struct {
+	Dataset string  `json:"dataset"`
+	Valid   bool    `json:"valid"`
+	Rows    int     `json:"rows"`
+	SHA256  string  `json:"sha256"`
+	Errors  []Error `json:"errors"`
+	// Truncated counts errors beyond MaxErrors that were not recorded.
+	Truncated int `json:"truncated,omitempty"`
+}
+
+// MaxErrors is the number of errors recorded in a report.
+const MaxErrors = 100
+
+// Load reads a YAML schema file.
+func Load(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var s Schema
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := s.check(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return &s, nil
+}
+
+// check reports errors in the schema itself.
+func (s *Schema) check() error {
+	for i, column := range s.Columns {
+		if column.Name == "" {
+			return fmt.Errorf("column %d has no name", i+1)
+		}
+		switch column.Type {
+		case "", TypeNumber, TypeInteger, TypeBool, TypeString:
+		default:
+			return fmt.Errorf("column %q: unknown type %q", column.Name, column.Type)
+		}
+	}
+	return nil
+}
+
+// ValidateFile validates the CSV dataset at path.
+func (s *Schema) ValidateFile(path string) (*Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	report, err := s.Validate(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	report.Dataset = path
+
+	return report, nil
+}
+
+// Validate validates CSV data read from r. It returns an error only when
+// the data cannot be read as CSV; schema violations are recorded in the
+// report.
+func (s *Schema) Validate(r io.Reader) (*Report, error) {
+	hash := sha256.New()
+	reader := csv.NewReader(io.TeeReader(r, hash))
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	report := &Report{Errors: []Error{}}
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("empty dataset")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make(map[string]int, len(header))
+	for i, name := range header {
+		positions[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	columns := make([]int, len(s.Columns))
+	for i, column := range s.Columns {
+		pos, ok := positions[strings.ToLower(column.Name)]
+		if !ok {
+			report.add(Error{Column: column.Name, Rule: "column", Message: "column is missing"})
+			pos = -1
+		}
+		columns[i] = pos
+	}
+	if !s.AllowExtraColumns && len(s.Columns) > 0 {
+		declared := make(map[string]bool, len(s.Columns))
+		for _, column := range s.Columns {
+			declared[strings.ToLower(column.Name)] = true
+		}
+		if s.Label != nil {
+			declared[strings.ToLower(s.labelColumn())] = true
+		}
+		for _, name := range header {
+			if !declared[strings.ToLower(strings.TrimSpace(name))] {
+				report.add(Error{Column: strings.TrimSpace(name), Rule: "column", Message: "column is not declared in the schema"})
+			}
+		}
+	}
+
+	labelCol := -1
+	var labels map[int]bool
+	if s.Label != nil {
+		pos, ok := positions[strings.ToLower(s.labelColumn())]
+		if !ok {
+			report.add(Error{Column: s.labelColumn(), Rule: "label", Message: "label column is missing"})
+		} else {
+			labelCol = pos
+		}
+		labels = make(map[int]bool, len(s.Label.Values))
+		for _, v := range s.Label.Values {
+			labels[v] = true
+		}
+	}
+
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		report.Rows++
+
+		if len(record) != len(header) {
+			report.add(Error{Row: row, Rule: "width", Message: fmt.Sprintf("has %d fields, header has %d", len(record), len(header))})
+			continue
+		}
+
+		for i, column := range s.Columns {
+			if columns[i] >= 0 {
+				if e, ok := column.validate(strings.TrimSpace(record[columns[i]])); !ok {
+					e.Row = row
+					report.add(e)
+				}
+			}
+		}
+
+		if labelCol >= 0 && len(labels) > 0 {
+			field := strings.TrimSpace(record[labelCol])
+			label, err := strconv.Atoi(field)
+			if err != nil || !labels[label] {
+				report.add(Error{Row: row, Column: s.labelColumn(), Rule: "label", Value: field, Message: fmt.Sprintf("label %q is not in the label domain", field)})
+			}
+		}
+	}
+
+	if s.MinRows > 0 && report.Rows < s.MinRows {
+		report.add(Error{Rule: "rows", Message: fmt.Sprintf("dataset has %d rows, want at least %d", report.Rows, s.MinRows)})
+	}
+	if s.MaxRows > 0 && report.Rows > s.MaxRows {
+		report.add(Error{Rule: "rows", Message: fmt.Sprintf("dataset has %d rows, want at most %d", report.Rows, s.MaxRows)})
+	}
+
+	// The CSV reader has consumed all input at EOF, so the hash covers the
+	// whole file.
+	report.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	if s.SHA256 != "" && !strings.EqualFold(s.SHA256, report.SHA256) {
+		report.add(Error{Rule: "checksum", Value: report.SHA256, Message: fmt.Sprintf("sha256 %s does not match expected %s", report.SHA256, s.SHA256)})
+	}
+
+	report.Valid = len(report.Errors) == 0 && report.Truncated == 0
+	return report, nil
+}
+
+// labelColumn returns the name of the label column.
+func (s *Schema) labelColumn() string {
+	if s.Label.Column != "" {
+		return s.Label.Column
+	}
+	return "label"
+}
+
+// add records an error, counting it as truncated past MaxErrors.
+func (r *Report) add(e Error) {
+	if len(r.Errors) >= MaxErrors {
+		r.Truncated++
+		return
+	}
+	r.Errors = append(r.Errors, e)
+}
+
+// validate checks one field against the column, returning the error and
+// false when it fails.
+func (c Column) validate(field string) (Error, bool) {
+	fail := func(rule, message string) (Error, bool) {
+		return Error{Column: c.Name, Rule: rule, Value: field, Message: message}, false
+	}
+
+	if isMissing(field) {
+		if c.Required {
+			return fail("required", "value is missing")
+		}
+		return Error{}, true
+	}
+
+	if len(c.Values) > 0 {
+		allowed := false
+		for _, v := range c.Values {
+			if v == field {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fail("values", fmt.Sprintf("value %q is not one of %s", field, strings.Join(c.Values, ", ")))
+		}
+	}
+
+	switch c.Type {
+	case TypeString:
+		return Error{}, true
+	case TypeBool:
+		if _, err := strconv.ParseBool(field); err != nil {
+			return fail("type", fmt.Sprintf("value %q is not a bool", field))
+		}
+		return Error{}, true
+	case TypeInteger:
+		if _, err := strconv.ParseInt(field, 10, 64); err != nil {
+			return fail("type", fmt.Sprintf("value %q is not an integer", field))
+		}
+	}
+
+	value, err := strconv.ParseFloat(field, 64)
+	if err != nil || math.IsInf(value, 0) {
+		return fail("type", fmt.Sprintf("value %q is not a finite number", field))
+	}
+	if c.Min != nil && value < *c.Min {
+		return fail("range", fmt.Sprintf("value %v is below the minimum %v", value, *c.Min))
+	}
+	if c.Max != nil && value > *c.Max {
+		return fail("range", fmt.Sprintf("value %v is above the maximum %v", value, *c.Max))
+	}
+
+	return Error{}, true
+}
+
+// isMissing reports whether a field holds a missing-value marker.
+func isMissing(field string) bool {
+	switch strings.ToLower(field) {
+	case "", "na", "nan", "null":
+		return true
+	}
+	return false
+}
+
+// GenerateReport generates a validation report.
+func GenerateReport(r *Report) string {
+	var report string
+
+	report += "=== Dataset Validation ===\n\n"
+	report += fmt.Sprintf("Dataset: %s\n", r.Dataset)
+	report += fmt.Sprintf("Rows: %d\n", r.Rows)
+	report += fmt.Sprintf("SHA-256: %s\n\n", r.SHA256)
+
+	if r.Valid {
+		report += "✓ Dataset matches the schema\n"
+		return report
+	}
+
+	report += fmt.Sprintf("Validation Errors: %d\n", len(r.Errors)+r.Truncated)
+	for _, e := range r.Errors {
+		report += fmt.Sprintf("  [%s] %s\n", e.Rule, e.Error())
+	}
+	if r.Truncated > 0 {
+		report += fmt.Sprintf("  ... %d more\n", r.Truncated)
+	}
+
+	return report
+}
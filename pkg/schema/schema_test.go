@@ -0,0 +1,55 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	max := 5.0
+	s := &Schema{
+		Columns: []Column{
+			{Name: "id", Type: TypeString, Required: true},
+			{Name: "f1", Max: &max},
+			{Name: "f2", Type: TypeInteger},
+		},
+		Label: &Label{Values: []int{0, 1}},
+	}
+
+	report, err := s.Validate(strings.NewReader("id,f1,f2,label\na,1,2,0\nb,9,x,3\n,NA,3,1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Valid || report.Rows != 3 {
+		t.Fatalf("Valid = %v, Rows = %d, want invalid with 3 rows", report.Valid, report.Rows)
+	}
+
+	var rules []string
+	for _, e := range report.Errors {
+		rules = append(rules, e.Rule)
+	}
+	if got, want := strings.Join(rules, ","), "range,type,label,required"; got != want {
+		t.Errorf("rules = %s, want %s", got, want)
+	}
+}
+
+func TestValidateChecksum(t *testing.T) {
+	data := "f1,label\n1,0\n"
+
+	report, err := (&Schema{AllowExtraColumns: true}).Validate(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Valid {
+		t.Fatalf("errors = %+v, want valid", report.Errors)
+	}
+
+	report, err = (&Schema{SHA256: report.SHA256 + "00"}).Validate(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Valid || report.Errors[0].Rule != "checksum" {
+		t.Errorf("errors = %+v, want checksum mismatch", report.Errors)
+	}
+}
@@ -0,0 +1,635 @@
+// Package script runs user scripts hooked into scans, for custom sample
+// scoring, post-processing of findings and pass or fail decisions. Scripts
+// are Starlark files, a dialect of Python designed for embedding. They are
+// sandboxed: Starlark has no access to the network, the file system or
+// the clock, load statements are not allowed, and each run of a script is
+// cancelled after a timeout. Scripts see only the arguments of their hooks
+// and the math and json modules.
+//
+// A script defines any of the following:
+//
+//	# check names the script's check, the type of its findings and the
+//	# score above which samples are flagged (default 0.5).
+//	check = {"name": "spiky-first-feature", "type": "feature_poison", "threshold": 0.8}
+//
+//	# score scores a sample between 0 and 1, optionally with the evidence
+//	# of its score as (score, evidence). stats, the statistics of the
+//	# scanned dataset (a stats.Profile), may be left out.
+//	def score(sample, stats):
+//	    f = stats["features"][0]
+//	    if sample["features"][0] > f["p75"] + 6 * f["mad"]:
+//	        return 0.9, "feature 0 = %s" % sample["features"][0]
+//	    return 0
+//
+//	# clear clears finding, a flagged sample of the scan summarized by
+//	# result, returning True or the reason.
+//	def clear(finding, result):
+//	    if finding["label"] == 3 and finding["confidence"] < 0.7:
+//	        return "label 3 is known to be noisy"
+//
+//	# deny fails the scan and warn reports warnings, as for policies,
+//	# returning a message or a list of them.
+//	def deny(result, findings):
+//	    if result["flagged_fraction"] > 0.01:
+//	        return "more than 1% of samples flagged"
+//
+// Samples are given as dicts of "id", "label", "features" and "source",
+// with None for non-finite features. print output is written to the
+// script's print output.
+package script
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	starlarkjson "go.starlark.net/lib/json"
+	starlarkmath "go.starlark.net/lib/math"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/policy"
+	"github.com/hallucinaut/modelpoison/pkg/stats"
+)
+
+// DefaultTimeout is the default limit of each run of a script.
+const DefaultTimeout = time.Second
+
+// hooks lists the functions scripts may define to hook into scans, with
+// the least and the most parameters of each.
+var hooks = map[string][2]int{
+	"score": {1, 2},
+	"clear": {1, 3},
+	"deny":  {1, 2},
+	"warn":  {1, 2},
+}
+
+// fileOptions are the language options of scripts: while loops and
+// recursion stay disallowed, so scripts loop only over finite sequences.
+var fileOptions = &syntax.FileOptions{Set: true, TopLevelControl: true}
+
+// predeclared holds the modules available to scripts.
+var predeclared = starlark.StringDict{
+	"math": starlarkmath.Module,
+	"json": starlarkjson.Module,
+}
+
+// Options configures the scripts compiled by Load.
+type Options struct {
+	// Print receives the output of print calls, when it is not nil.
+	Print io.Writer
+	// Timeout limits each run of the script: its top-level statements
+	// and each call of a hook (default DefaultTimeout).
+	Timeout time.Duration
+}
+
+// Script is a compiled script. Its methods are safe for concurrent use.
+type Script struct {
+	path    string
+	hash    string
+	info    detect.CheckInfo
+	limit   float64
+	timeout time.Duration
+	// stats is set when the score or clear hook takes the dataset
+	// statistics.
+	stats bool
+	// hooks holds each hook the script defines.
+	hooks map[string]*starlark.Function
+
+	printMu sync.Mutex
+	print   io.Writer
+}
+
+// Load reads and compiles the script at path, running its top-level
+// statements.
+func Load(ctx context.Context, path string, opts Options) (*Script, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(src)
+	s := &Script{
+		path:    path,
+		hash:    hex.EncodeToString(sum[:6]),
+		timeout: opts.Timeout,
+		hooks:   make(map[string]*starlark.Function),
+		print:   opts.Print,
+	}
+	if s.timeout <= 0 {
+		s.timeout = DefaultTimeout
+	}
+
+	var globals starlark.StringDict
+	err = s.run(ctx, "load", func(thread *starlark.Thread) error {
+		var err error
+		globals, err = starlark.ExecFileOptions(fileOptions, thread, path, src, predeclared)
+		return err
+	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	} else if err != nil {
+		return nil, err
+	}
+	for hook, params := range hooks {
+		value, ok := globals[hook]
+		if !ok {
+			continue
+		}
+		fn, ok := value.(*starlark.Function)
+		if !ok {
+			return nil, fmt.Errorf("%s: %s is a %s, not a function", path, hook, value.Type())
+		}
+		if n := fn.NumParams(); n < params[0] || n > params[1] {
+			return nil, fmt.Errorf("%s: %s takes %d parameters, want %d to %d", path, hook, n, params[0], params[1])
+		}
+		s.hooks[hook] = fn
+	}
+	if len(s.hooks) == 0 && !globals.Has("check") {
+		return nil, fmt.Errorf("%s defines none of check, score, clear, deny and warn", path)
+	}
+	s.stats = s.hooks["score"] != nil && s.hooks["score"].NumParams() == 2 ||
+		s.hooks["clear"] != nil && s.hooks["clear"].NumParams() == 3
+	if err := s.loadCheck(globals["check"]); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// loadCheck reads the check global, which describes the script's check.
+func (s *Script) loadCheck(value starlark.Value) error {
+	name := strings.TrimSuffix(filepath.Base(s.path), filepath.Ext(s.path))
+	info := detect.CheckInfo{Name: name, Type: detect.TypeDataPoison}
+	s.limit = 0.5
+	if value != nil {
+		dict, ok := value.(*starlark.Dict)
+		if !ok {
+			return fmt.Errorf("check is a %s, not a dict", value.Type())
+		}
+		for _, item := range dict.Items() {
+			key, _ := starlark.AsString(item[0])
+			var err error
+			switch key {
+			case "name":
+				info.Name, err = asString(item[1])
+			case "type":
+				var kind string
+				kind, err = asString(item[1])
+				info.Type = detect.PoisonType(kind)
+			case "description":
+				info.Description, err = asString(item[1])
+			case "threshold":
+				s.limit, err = number(item[1])
+			default:
+				err = errors.New("unknown key")
+			}
+			if err != nil {
+				return fmt.Errorf("check %s: %w", item[0], err)
+			}
+		}
+	}
+	if info.Name == "" {
+		info.Name = name
+	}
+	if info.Type == "" {
+		info.Type = detect.TypeDataPoison
+	}
+	if info.Description == "" {
+		info.Description = "Flagged by script " + info.Name
+	}
+	// The method names the hash of the script, so incremental scans
+	// rescore samples when the script changes.
+	info.Method = fmt.Sprintf("script %s (sha256:%s)", filepath.Base(s.path), s.hash)
+	info.DataTypes = []string{"tabular"}
+	s.info = info
+	return nil
+}
+
+// Path returns the path of the script.
+func (s *Script) Path() string { return s.path }
+
+// Name returns the name of the script's check.
+func (s *Script) Name() string { return s.info.Name }
+
+// Scores reports whether the script scores samples.
+func (s *Script) Scores() bool { return s.hooks["score"] != nil }
+
+// UsesStats reports whether the script takes the dataset statistics,
+// which callers need only compute for scripts that do.
+func (s *Script) UsesStats() bool { return s.stats }
+
+// run runs f on a new thread of the script, cancelling it when ctx is done
+// or the script's timeout passes.
+func (s *Script) run(ctx context.Context, name string, f func(*starlark.Thread) error) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	thread := &starlark.Thread{Name: name, Print: s.printLine}
+	stop := context.AfterFunc(ctx, func() { thread.Cancel(ctx.Err().Error()) })
+	defer stop()
+	if err := f(thread); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%s: %w (limit %v)", name, ctx.Err(), s.timeout)
+		}
+		return err
+	}
+	return nil
+}
+
+// printLine writes the output of a print call.
+func (s *Script) printLine(_ *starlark.Thread, msg string) {
+	if s.print == nil {
+		return
+	}
+	s.printMu.Lock()
+	defer s.printMu.Unlock()
+	fmt.Fprintln(s.print, msg)
+}
+
+// call calls a hook of the script with as many of args as it takes,
+// reporting whether the script defines the hook.
+func (s *Script) call(ctx context.Context, hook string, args ...starlark.Value) (starlark.Value, bool, error) {
+	fn := s.hooks[hook]
+	if fn == nil {
+		return nil, false, nil
+	}
+	var value starlark.Value
+	err := s.run(ctx, hook, func(thread *starlark.Thread) error {
+		var err error
+		value, err = starlark.Call(thread, fn, args[:fn.NumParams()], nil)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Check returns the check scoring samples with the script's score hook,
+// given the statistics of the dataset scanned (nil when they are not
+// known, as for streamed scans).
+func (s *Script) Check(profile *stats.Profile) (*Check, error) {
+	c := &Check{script: s, stats: starlark.None}
+	if profile != nil {
+		var err error
+		if c.stats, err = toValue(profile); err != nil {
+			return nil, fmt.Errorf("%s: dataset statistics: %w", s.path, err)
+		}
+	}
+	return c, nil
+}
+
+// Check scores samples with a script, as a detect.ExternalCheck.
+type Check struct {
+	script *Script
+	stats  starlark.Value
+
+	mu sync.Mutex
+	// err is the first failure of the script, after which it scores
+	// nothing.
+	err error
+}
+
+// Info implements detect.ExternalCheck.
+func (c *Check) Info() detect.CheckInfo { return c.script.info }
+
+// Threshold implements detect.ExternalCheck.
+func (c *Check) Threshold() float64 { return c.script.limit }
+
+// Score implements detect.ExternalCheck. Failures are reported by Err.
+func (c *Check) Score(samples []detect.Sample) []detect.ExternalScore {
+	if c.Err() != nil {
+		return nil
+	}
+	scores := make([]detect.ExternalScore, len(samples))
+	for i, sample := range samples {
+		score, err := c.score(sample)
+		if err != nil {
+			c.fail(fmt.Errorf("%s: sample %s: %w", c.script.path, sample.ID, err))
+			return nil
+		}
+		scores[i] = score
+	}
+	return scores
+}
+
+// score scores a sample: the value of the score hook is a number, or a
+// tuple of a number and the evidence.
+func (c *Check) score(sample detect.Sample) (detect.ExternalScore, error) {
+	value, ok, err := c.script.call(context.Background(), "score", sampleValue(sample), c.stats)
+	if err != nil || !ok {
+		return detect.ExternalScore{}, err
+	}
+	var result detect.ExternalScore
+	if tuple, ok := value.(starlark.Tuple); ok {
+		if len(tuple) != 2 {
+			return result, fmt.Errorf("score returned a tuple of %d values, want a score and evidence", len(tuple))
+		}
+		if tuple[1] != starlark.None {
+			if s, ok := starlark.AsString(tuple[1]); ok {
+				result.Evidence = s
+			} else {
+				result.Evidence = tuple[1].String()
+			}
+		}
+		value = tuple[0]
+	}
+	if result.Score, err = number(value); err != nil {
+		return detect.ExternalScore{}, fmt.Errorf("score: %w", err)
+	}
+	if result.Score < 0 || result.Score > 1 {
+		return detect.ExternalScore{}, fmt.Errorf("score %v is not between 0 and 1", result.Score)
+	}
+	if result.Score <= c.script.limit {
+		result.Evidence = ""
+	}
+	return result, nil
+}
+
+func (c *Check) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// Err returns the first failure of the script while scoring, if any.
+func (c *Check) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Summary summarizes a scan for the clear, deny and warn hooks, as their
+// result argument.
+type Summary struct {
+	IsPoisoned      bool    `json:"is_poisoned"`
+	SampleCount     int     `json:"sample_count"`
+	PoisonedCount   int     `json:"poisoned_count"`
+	FlaggedFraction float64 `json:"flagged_fraction"`
+	RiskScore       float64 `json:"risk_score"`
+	// Types counts flagged samples by poison type.
+	Types   map[string]int     `json:"types"`
+	Classes []detect.ClassRisk `json:"classes"`
+}
+
+// Summarize summarizes result.
+func Summarize(result *detect.DetectionResult) Summary {
+	summary := Summary{
+		IsPoisoned:    result.IsPoisoned,
+		SampleCount:   result.SampleCount,
+		PoisonedCount: result.PoisonedCount,
+		RiskScore:     result.RiskScore,
+		Types:         make(map[string]int),
+		Classes:       result.Classes,
+	}
+	if result.SampleCount > 0 {
+		summary.FlaggedFraction = float64(result.PoisonedCount) / float64(result.SampleCount)
+	}
+	for _, finding := range result.Samples {
+		if finding.IsPoisoned {
+			summary.Types[string(finding.Type)]++
+		}
+	}
+	if summary.Classes == nil {
+		summary.Classes = []detect.ClassRisk{}
+	}
+	return summary
+}
+
+// Process clears the flagged findings of result matched by the script's
+// clear hook, returning their number. The counts and risk score of result
+// are left for the caller to update.
+func (s *Script) Process(ctx context.Context, result *detect.DetectionResult, profile *stats.Profile) (int, error) {
+	if s.hooks["clear"] == nil {
+		return 0, nil
+	}
+	summary, err := toValue(Summarize(result))
+	if err != nil {
+		return 0, err
+	}
+	var statsValue starlark.Value = starlark.None
+	if profile != nil && s.stats {
+		if statsValue, err = toValue(profile); err != nil {
+			return 0, fmt.Errorf("%s: dataset statistics: %w", s.path, err)
+		}
+	}
+
+	cleared := 0
+	for i, finding := range result.Samples {
+		if !finding.IsPoisoned {
+			continue
+		}
+		findingValue, err := toValue(finding)
+		if err != nil {
+			return cleared, err
+		}
+		value, _, err := s.call(ctx, "clear", findingValue, summary, statsValue)
+		if err != nil {
+			return cleared, fmt.Errorf("%s: sample %s: %w", s.path, finding.ID, err)
+		}
+		reason, clear := clearReason(value)
+		if !clear {
+			continue
+		}
+		evidence := "Cleared by script " + s.info.Name
+		if reason != "" {
+			evidence += ": " + reason
+		}
+		result.Samples[i] = detect.PoisonedSample{ID: finding.ID, Label: finding.Label, Hash: finding.Hash, Evidence: evidence}
+		cleared++
+	}
+	return cleared, nil
+}
+
+// clearReason interprets the value of the clear hook: true, or the reason
+// for clearing a finding.
+func clearReason(value starlark.Value) (string, bool) {
+	if s, ok := starlark.AsString(value); ok {
+		return s, true
+	}
+	return "", value != nil && bool(value.Truth())
+}
+
+// Decide evaluates the script's deny and warn hooks against result.
+func (s *Script) Decide(ctx context.Context, result *detect.DetectionResult) (policy.Decision, error) {
+	decision := policy.Decision{Allow: true}
+	if s.hooks["deny"] == nil && s.hooks["warn"] == nil {
+		return decision, nil
+	}
+	findings := []detect.PoisonedSample{}
+	for _, finding := range result.Samples {
+		if finding.IsPoisoned {
+			findings = append(findings, finding)
+		}
+	}
+	summary, err := toValue(Summarize(result))
+	if err != nil {
+		return decision, err
+	}
+	findingsValue, err := toValue(findings)
+	if err != nil {
+		return decision, err
+	}
+	for _, hook := range []string{"deny", "warn"} {
+		value, _, err := s.call(ctx, hook, summary, findingsValue)
+		if err != nil {
+			return decision, err
+		}
+		msgs, err := messages(value)
+		if err != nil {
+			return decision, fmt.Errorf("%s: %s: %w", s.path, hook, err)
+		}
+		if hook == "deny" {
+			decision.Deny = msgs
+		} else {
+			decision.Warn = msgs
+		}
+	}
+	decision.Allow = len(decision.Deny) == 0
+	return decision, nil
+}
+
+// messages returns the sorted messages of a deny or warn hook: None, a
+// string or a sequence of strings.
+func messages(value starlark.Value) ([]string, error) {
+	if value == nil || value == starlark.None {
+		return nil, nil
+	}
+	if s, ok := starlark.AsString(value); ok {
+		return []string{s}, nil
+	}
+	iterable, ok := value.(starlark.Iterable)
+	if !ok {
+		return nil, fmt.Errorf("returned a %s, want a message or a list of them", value.Type())
+	}
+	var msgs []string
+	iter := iterable.Iterate()
+	defer iter.Done()
+	var item starlark.Value
+	for iter.Next(&item) {
+		s, ok := starlark.AsString(item)
+		if !ok {
+			return nil, fmt.Errorf("returned a %s message, want a string", item.Type())
+		}
+		msgs = append(msgs, s)
+	}
+	sort.Strings(msgs)
+	return msgs, nil
+}
+
+// sampleValue converts a sample to its script argument.
+func sampleValue(sample detect.Sample) starlark.Value {
+	dense := sample.Dense()
+	features := make([]starlark.Value, len(dense))
+	for i, v := range dense {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			features[i] = starlark.None
+		} else {
+			features[i] = starlark.Float(v)
+		}
+	}
+	dict := starlark.NewDict(4)
+	dict.SetKey(starlark.String("id"), starlark.String(sample.ID))
+	dict.SetKey(starlark.String("label"), starlark.MakeInt(sample.Label))
+	dict.SetKey(starlark.String("features"), starlark.NewList(features))
+	if source, ok := sample.Meta(detect.MetaSource); ok {
+		dict.SetKey(starlark.String("source"), starlark.String(fmt.Sprint(source)))
+	}
+	dict.Freeze()
+	return dict
+}
+
+// toValue converts v to a frozen Starlark value through its JSON encoding,
+// so scripts see the JSON names of its fields.
+func toValue(v interface{}) (starlark.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	value := fromJSON(doc)
+	value.Freeze()
+	return value, nil
+}
+
+// fromJSON converts a decoded JSON document to a Starlark value, with the
+// keys of objects in order.
+func fromJSON(doc interface{}) starlark.Value {
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		dict := starlark.NewDict(len(v))
+		for _, key := range keys {
+			dict.SetKey(starlark.String(key), fromJSON(v[key]))
+		}
+		return dict
+	case []interface{}:
+		items := make([]starlark.Value, len(v))
+		for i, item := range v {
+			items[i] = fromJSON(item)
+		}
+		return starlark.NewList(items)
+	case json.Number:
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return starlark.MakeInt64(n)
+		}
+		f, _ := v.Float64()
+		return starlark.Float(f)
+	case string:
+		return starlark.String(v)
+	case bool:
+		return starlark.Bool(v)
+	default:
+		return starlark.None
+	}
+}
+
+// number converts a hook value to a number.
+func number(value starlark.Value) (float64, error) {
+	switch v := value.(type) {
+	case starlark.Bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case starlark.NoneType:
+		return 0, nil
+	case starlark.Int, starlark.Float:
+		f, _ := starlark.AsFloat(v)
+		return f, nil
+	default:
+		return 0, fmt.Errorf("returned a %s, not a number", value.Type())
+	}
+}
+
+// asString returns the string of a check value.
+func asString(value starlark.Value) (string, error) {
+	s, ok := starlark.AsString(value)
+	if !ok {
+		return "", fmt.Errorf("is a %s, not a string", value.Type())
+	}
+	return s, nil
+}
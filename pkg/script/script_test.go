@@ -0,0 +1,178 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/policy"
+	"github.com/hallucinaut/modelpoison/pkg/stats"
+)
+
+const testScript = `check = {"name": "spike", "type": "feature_poison", "threshold": 0.8}
+
+def score(sample, stats):
+    if sample["features"][0] > stats["features"][0]["max"] / 2:
+        return 0.9, "feature 0 = %d" % sample["features"][0]
+    return 0
+
+def clear(finding, result):
+    if finding["label"] == 1:
+        return "odd labels are noisy"
+
+def deny(result, findings):
+    if result["poisoned_count"] > 1:
+        return ["%d findings" % result["poisoned_count"]]
+`
+
+func writeScript(t *testing.T, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.star")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testSamples() []detect.Sample {
+	samples := make([]detect.Sample, 100)
+	for i := range samples {
+		features := []float64{1, math.NaN()}
+		if i%25 == 0 {
+			features[0] = 50
+		}
+		samples[i] = detect.Sample{ID: fmt.Sprintf("s%d", i), Features: features, Label: i % 2}
+	}
+	return samples
+}
+
+func TestScript(t *testing.T) {
+	ctx := context.Background()
+	s, err := Load(ctx, writeScript(t, testScript), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Scores() || !s.UsesStats() || s.Name() != "spike" {
+		t.Fatalf("script %s: scores %v, uses stats %v", s.Name(), s.Scores(), s.UsesStats())
+	}
+
+	samples := testSamples()
+	profile := stats.ProfileSamples(nil, samples)
+	check, err := s.Check(profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := check.Info(); info.Type != detect.TypeFeaturePoison || check.Threshold() != 0.8 {
+		t.Errorf("check %+v, threshold %v", info, check.Threshold())
+	}
+	scores := check.Score(samples)
+	if err := check.Err(); err != nil {
+		t.Fatal(err)
+	}
+	for i, score := range scores {
+		want := detect.ExternalScore{}
+		if i%25 == 0 {
+			want = detect.ExternalScore{Score: 0.9, Evidence: "feature 0 = 50"}
+		}
+		if score != want {
+			t.Errorf("sample %d: %+v, want %+v", i, score, want)
+		}
+	}
+
+	// s25 and s75 have odd labels.
+	d := detect.NewDetector()
+	if err := d.AddCheck(check); err != nil {
+		t.Fatal(err)
+	}
+	result := d.Detect(samples)
+	cleared, err := s.Process(ctx, result, profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Rescore(result)
+	if cleared != 2 || result.PoisonedCount != 2 {
+		t.Fatalf("cleared %d, %d poisoned; want 2 and 2", cleared, result.PoisonedCount)
+	}
+	if f := result.Samples[25]; f.IsPoisoned || !strings.Contains(f.Evidence, "odd labels are noisy") {
+		t.Errorf("cleared finding: %+v", f)
+	}
+
+	decision, err := s.Decide(ctx, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (policy.Decision{Deny: []string{"2 findings"}}); !reflect.DeepEqual(decision, want) {
+		t.Errorf("decision = %+v, want %+v", decision, want)
+	}
+}
+
+func TestScriptSandbox(t *testing.T) {
+	for name, src := range map[string]string{
+		"network":     "def score(sample):\n    return http.send({\"method\": \"get\", \"url\": \"http://localhost\"})\n",
+		"file system": "def score(sample):\n    return len(open(\"/etc/passwd\").read())\n",
+		"load":        "load(\"other.star\", \"score\")\n",
+		"while":       "def score(sample):\n    while True:\n        pass\n",
+		"parameters":  "def score(sample, stats, other):\n    return 1\n",
+		"no hooks":    "other = 1\n",
+	} {
+		if _, err := Load(context.Background(), writeScript(t, src), Options{}); err == nil {
+			t.Errorf("%s: Load succeeded", name)
+		}
+	}
+}
+
+func TestScriptTimeout(t *testing.T) {
+	runaway := "def score(sample):\n    n = 0\n    for i in range(100000000):\n        for j in range(100000000):\n            n += 1\n    return 0\n"
+	s, err := Load(context.Background(), writeScript(t, runaway), Options{Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check, err := s.Check(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if scores := check.Score(testSamples()[:1]); scores != nil {
+		t.Errorf("scores = %v, want none", scores)
+	}
+	if err := check.Err(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Err() = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("runaway script ran for %v", elapsed)
+	}
+
+	// Top-level statements are limited alike.
+	topLevel := "never = [i for i in range(100000000) for j in range(100000000) if j < 0]\n"
+	if _, err := Load(context.Background(), writeScript(t, topLevel), Options{Timeout: 50 * time.Millisecond}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Load() = %v, want a timeout", err)
+	}
+}
+
+func TestScriptErrors(t *testing.T) {
+	s, err := Load(context.Background(), writeScript(t, "def score(sample):\n    return 2\n"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.UsesStats() {
+		t.Error("script without stats uses stats")
+	}
+	check, err := s.Check(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scores := check.Score(testSamples()); scores != nil {
+		t.Errorf("scores = %v, want none", scores)
+	}
+	if err := check.Err(); err == nil || !strings.Contains(err.Error(), "not between 0 and 1") {
+		t.Errorf("Err() = %v", err)
+	}
+}
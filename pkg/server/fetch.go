@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// errFetch is the error clients get for a dataset URI that could not be
+// fetched, whatever the cause.
+var errFetch = errors.New("could not fetch the dataset")
+
+// remoteClient returns the default client fetching datasets by URI. Its
+// connections, including those following redirects, are limited to the
+// allowed hosts when any are set, and otherwise to public addresses. It
+// never uses a proxy, which would connect on its behalf unchecked.
+func remoteClient(allowed []string) *http.Client {
+	guard := &hostGuard{
+		dialer:  &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		allowed: make(map[string]bool, len(allowed)),
+	}
+	for _, host := range allowed {
+		guard.allowed[strings.ToLower(strings.Trim(host, "[]"))] = true
+	}
+	return &http.Client{
+		Timeout: 5 * time.Minute,
+		Transport: &http.Transport{
+			DialContext:           guard.dialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+	}
+}
+
+// hostGuard dials the hosts datasets may be fetched from.
+type hostGuard struct {
+	dialer  *net.Dialer
+	allowed map[string]bool
+}
+
+// dialContext dials addr if its host is allowed. Without allowed hosts it
+// resolves the host itself and dials the checked address, so a second
+// lookup cannot return another one.
+func (g *hostGuard) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.allowed) > 0 {
+		if !g.allowed[strings.ToLower(host)] {
+			return nil, fmt.Errorf("host %s is not allowed", host)
+		}
+		return g.dialer.DialContext(ctx, network, addr)
+	}
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if deniedIP(ip.IP) {
+			return nil, fmt.Errorf("host %s resolves to the non-public address %s", host, ip.IP)
+		}
+	}
+	err = fmt.Errorf("host %s has no addresses", host)
+	for _, ip := range ips {
+		var conn net.Conn
+		if conn, err = g.dialer.DialContext(ctx, network, net.JoinHostPort(ip.IP.String(), port)); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// deniedIP reports whether ip is a loopback, link-local, private,
+// multicast or unspecified address, which remote datasets are not fetched
+// from.
+func deniedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast()
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRemoteURIs(t *testing.T) {
+	var hits atomic.Int32
+	var origin *httptest.Server
+	origin = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/train.csv":
+			io.WriteString(w, testCSV())
+		case "/metadata":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+		case "/loopback":
+			u, _ := url.Parse(origin.URL)
+			http.Redirect(w, r, "http://localhost:"+u.Port()+"/train.csv", http.StatusFound)
+		default:
+			http.Error(w, "internal admin console", http.StatusForbidden)
+		}
+	}))
+	defer origin.Close()
+	submit := func(s *Server, path string) (int, string) {
+		w := do(s, http.MethodPost, "/v1/datasets", `{"uri": "`+origin.URL+path+`"}`, "")
+		return w.Code, errorOf(w)
+	}
+
+	// By default the server refuses to connect to the loopback origin.
+	s := newServer(Options{RemoteURIs: true})
+	defer s.Close()
+	if status, err := submit(s, "/train.csv"); status != http.StatusBadRequest || err != "load dataset: could not fetch the dataset" {
+		t.Errorf("loopback host: status %d, error %q", status, err)
+	}
+	if n := hits.Load(); n != 0 {
+		t.Errorf("the denied origin got %d requests", n)
+	}
+
+	// Allowed hosts are fetched from, but redirects to other hosts are
+	// refused, and upstream errors are not passed on.
+	s = newServer(Options{RemoteURIs: true, AllowedHosts: []string{"127.0.0.1"}})
+	defer s.Close()
+	if status, err := submit(s, "/train.csv"); status != http.StatusCreated {
+		t.Errorf("allowed host: status %d, error %q", status, err)
+	}
+	for _, path := range []string{"/metadata", "/loopback", "/admin"} {
+		hits.Store(0)
+		status, err := submit(s, path)
+		if status != http.StatusBadRequest || err != "load dataset: could not fetch the dataset" {
+			t.Errorf("%s: status %d, error %q", path, status, err)
+		}
+		if n := hits.Load(); n != 1 {
+			t.Errorf("%s: origin got %d requests, want only the first", path, n)
+		}
+	}
+}
+
+func TestHostGuard(t *testing.T) {
+	for _, test := range []struct {
+		ip     string
+		denied bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"0.0.0.0", true},
+		{"::ffff:127.0.0.1", true},
+		{"224.0.0.1", true},
+		{"93.184.216.34", false},
+		{"2606:2800:220:1::", false},
+	} {
+		if got := deniedIP(net.ParseIP(test.ip)); got != test.denied {
+			t.Errorf("deniedIP(%s) = %v, want %v", test.ip, got, test.denied)
+		}
+	}
+
+	// Hosts resolving to denied addresses are refused before connecting.
+	guard := remoteClient(nil).Transport.(*http.Transport).DialContext
+	if _, err := guard(context.Background(), "tcp", "localhost:80"); err == nil || !strings.Contains(err.Error(), "non-public address") {
+		t.Errorf("dialing localhost: %v", err)
+	}
+	guard = remoteClient([]string{"data.internal"}).Transport.(*http.Transport).DialContext
+	if _, err := guard(context.Background(), "tcp", "127.0.0.1:80"); err == nil || err.Error() != "host 127.0.0.1 is not allowed" {
+		t.Errorf("dialing an unlisted host: %v", err)
+	}
+}
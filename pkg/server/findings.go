@@ -0,0 +1,304 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// DefaultStreamBuffer is the number of findings of each scan job kept in
+// memory for streaming clients by default.
+const DefaultStreamBuffer = 10000
+
+// StreamPolicy says what a scan job does when its buffer of findings is
+// full, so slow or absent clients of /findings cannot make memory grow with
+// the number of findings. Evicted findings are still in the job's result.
+type StreamPolicy string
+
+const (
+	// StreamBlock pauses the scan until every client streaming its
+	// findings has read the oldest ones, which are then evicted. Without
+	// streaming clients the oldest findings are evicted.
+	StreamBlock StreamPolicy = "block"
+	// StreamDrop evicts the oldest findings without waiting; clients
+	// streaming behind skip them.
+	StreamDrop StreamPolicy = "drop"
+	// StreamSpill writes evicted findings to a temporary file, from which
+	// clients streaming behind read them.
+	StreamSpill StreamPolicy = "spill"
+)
+
+// StreamPolicies returns the findings buffer policies.
+func StreamPolicies() []StreamPolicy {
+	return []StreamPolicy{StreamBlock, StreamDrop, StreamSpill}
+}
+
+// ParseStreamPolicy parses a findings buffer policy name. The empty name
+// is the default policy, StreamBlock.
+func ParseStreamPolicy(name string) (StreamPolicy, error) {
+	if name == "" {
+		return StreamBlock, nil
+	}
+	for _, p := range StreamPolicies() {
+		if strings.EqualFold(name, string(p)) {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown stream policy %q (want block, drop or spill)", name)
+}
+
+// jobRun collects the findings of a scan job as they are produced. It
+// keeps at most limit of them in memory, the latest ones, from offset
+// first on; older findings were evicted by policy, or spilled to a file
+// holding the findings before offset spilled.
+type jobRun struct {
+	// ctx carries the values of the submitting request, such as its
+	// identity, without its cancellation.
+	ctx    context.Context
+	base   string
+	source string
+	log    *slog.Logger
+
+	limit  int
+	policy StreamPolicy
+	dir    string
+
+	mu       sync.Mutex
+	findings []detect.PoisonedSample
+	first    int
+	spill    *os.File
+	spillw   *bufio.Writer
+	spilled  int
+	readers  map[*findingsReader]struct{}
+	done     bool
+	// changed is closed and replaced whenever findings are added or the
+	// run ends, and read whenever a reader advances or detaches.
+	changed chan struct{}
+	read    chan struct{}
+}
+
+func (s *Server) newJobRun(ctx context.Context, base, source string, log *slog.Logger) *jobRun {
+	return &jobRun{
+		ctx:     context.WithoutCancel(ctx),
+		base:    base,
+		source:  source,
+		log:     log,
+		limit:   s.opts.StreamBuffer,
+		policy:  s.opts.StreamPolicy,
+		dir:     s.opts.StreamDir,
+		readers: make(map[*findingsReader]struct{}),
+		changed: make(chan struct{}),
+		read:    make(chan struct{}),
+	}
+}
+
+// add publishes a finding. When the buffer is full, the oldest findings
+// are evicted by the run's policy; under StreamBlock, add waits for the
+// slowest reader until ctx is done.
+func (j *jobRun) add(ctx context.Context, finding detect.PoisonedSample) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for j.limit > 0 && len(j.findings) >= j.limit {
+		n := max(j.limit/4, 1)
+		if j.policy == StreamBlock && len(j.readers) > 0 {
+			n = min(n, j.slowest()-j.first)
+		}
+		if n > 0 {
+			j.evict(n)
+			break
+		}
+		read := j.read
+		j.mu.Unlock()
+		select {
+		case <-read:
+		case <-ctx.Done():
+			j.mu.Lock()
+			return ctx.Err()
+		}
+		j.mu.Lock()
+	}
+	j.findings = append(j.findings, finding)
+	close(j.changed)
+	j.changed = make(chan struct{})
+	return nil
+}
+
+// slowest returns the offset of the reader furthest behind.
+func (j *jobRun) slowest() int {
+	offset := j.first + len(j.findings)
+	for r := range j.readers {
+		offset = min(offset, r.offset)
+	}
+	return offset
+}
+
+// evict removes the n oldest findings from memory, spilling them under
+// StreamSpill. A run that fails to spill drops findings from then on.
+func (j *jobRun) evict(n int) {
+	if j.policy == StreamSpill && j.spilled == j.first {
+		if err := j.spillFindings(j.findings[:n]); err != nil {
+			j.log.Warn("evicting findings without spilling them", "error", err)
+		} else {
+			j.spilled += n
+		}
+	}
+	// Slices returned by window and next keep their own capacity, so the
+	// evicted findings are released once the backing array is reallocated.
+	j.findings = j.findings[n:]
+	j.first += n
+}
+
+func (j *jobRun) spillFindings(findings []detect.PoisonedSample) error {
+	if j.spill == nil {
+		f, err := os.CreateTemp(j.dir, "modelpoison-findings-*.ndjson")
+		if err != nil {
+			return fmt.Errorf("spill findings: %w", err)
+		}
+		j.spill, j.spillw = f, bufio.NewWriter(f)
+	}
+	encoder := json.NewEncoder(j.spillw)
+	for _, finding := range findings {
+		if err := encoder.Encode(finding); err != nil {
+			return fmt.Errorf("spill findings: %w", err)
+		}
+	}
+	if err := j.spillw.Flush(); err != nil {
+		return fmt.Errorf("spill findings: %w", err)
+	}
+	return nil
+}
+
+func (j *jobRun) finish() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.done {
+		return
+	}
+	j.done = true
+	close(j.changed)
+}
+
+// close removes the spill file of the run.
+func (j *jobRun) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.spill == nil {
+		return nil
+	}
+	j.spill.Close()
+	err := os.Remove(j.spill.Name())
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	j.spill, j.spillw, j.spilled = nil, nil, 0
+	return err
+}
+
+// window returns the findings in memory and the offset of the first.
+func (j *jobRun) window() ([]detect.PoisonedSample, int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.findings[:len(j.findings):len(j.findings)], j.first
+}
+
+// findingsReader is the position of a client streaming the findings of a
+// run, and its handle on the spill file while it reads spilled findings.
+type findingsReader struct {
+	offset  int
+	file    *os.File
+	decoder *json.Decoder
+	line    int
+}
+
+// attach registers a reader starting at offset. Under StreamBlock the run
+// waits for it before evicting findings it has not read.
+func (j *jobRun) attach(offset int) *findingsReader {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	r := &findingsReader{offset: min(offset, j.first+len(j.findings))}
+	j.readers[r] = struct{}{}
+	return r
+}
+
+// detach unregisters a reader, releasing a run waiting for it.
+func (j *jobRun) detach(r *findingsReader) {
+	if r.file != nil {
+		r.file.Close()
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.readers, r)
+	close(j.read)
+	j.read = make(chan struct{})
+}
+
+// next returns the findings at the reader's position and advances it, with
+// the number of findings skipped because they were evicted unspilled,
+// whether the run has ended and a channel closed once more can be read.
+func (j *jobRun) next(r *findingsReader) ([]detect.PoisonedSample, int, bool, <-chan struct{}, error) {
+	j.mu.Lock()
+	if r.offset < j.spilled {
+		name, end := j.spill.Name(), min(j.spilled, r.offset+j.limit)
+		j.mu.Unlock()
+		findings, err := r.readSpill(name, end)
+		j.advance(r, len(findings))
+		// More findings can be read right away.
+		ready := make(chan struct{})
+		close(ready)
+		return findings, 0, false, ready, err
+	}
+	defer j.mu.Unlock()
+
+	skipped := 0
+	if r.offset < j.first {
+		skipped = j.first - r.offset
+	}
+	findings := j.findings[r.offset+skipped-j.first:]
+	findings = findings[:len(findings):len(findings)]
+	r.offset += skipped + len(findings)
+	if skipped+len(findings) > 0 {
+		close(j.read)
+		j.read = make(chan struct{})
+	}
+	return findings, skipped, j.done, j.changed, nil
+}
+
+func (j *jobRun) advance(r *findingsReader, n int) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	r.offset += n
+	close(j.read)
+	j.read = make(chan struct{})
+}
+
+// readSpill reads the spilled findings from the reader's position up to
+// offset end, which the run has flushed.
+func (r *findingsReader) readSpill(name string, end int) ([]detect.PoisonedSample, error) {
+	if r.file == nil {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("read spilled findings: %w", err)
+		}
+		r.file, r.decoder = f, json.NewDecoder(f)
+	}
+	var findings []detect.PoisonedSample
+	for r.line < end {
+		var finding detect.PoisonedSample
+		if err := r.decoder.Decode(&finding); err != nil {
+			return findings, fmt.Errorf("read spilled findings: %w", err)
+		}
+		if r.line >= r.offset {
+			findings = append(findings, finding)
+		}
+		r.line++
+	}
+	return findings, nil
+}
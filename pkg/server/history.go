@@ -0,0 +1,273 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/audit"
+	"github.com/hallucinaut/modelpoison/pkg/auth"
+	"github.com/hallucinaut/modelpoison/pkg/store"
+)
+
+// Restore loads the review decisions recorded in Options.Store into the
+// projects. Call it before serving requests.
+func (s *Server) Restore(ctx context.Context) error {
+	if s.opts.Store == nil {
+		return nil
+	}
+	for _, p := range s.projects {
+		list, err := s.opts.Store.Allowlist(ctx, p.name)
+		if err != nil {
+			return fmt.Errorf("project %s: load decisions: %w", p.name, err)
+		}
+		p.mu.Lock()
+		p.allow = list
+		p.mu.Unlock()
+	}
+	return nil
+}
+
+// save records a run in Options.Store and Options.Audit, if set. Failures
+// are logged but do not fail the request, whose result is already served
+// from memory.
+func (s *Server) save(ctx context.Context, log *slog.Logger, run store.Run) {
+	record := audit.Record{Action: audit.ActionScan, Project: run.Project, Subject: run.Source}
+	if run.Detection != nil {
+		record.Details = audit.ScanDetails(run.Detection)
+	} else {
+		record.Action = audit.ActionDefense
+		record.Details = map[string]string{
+			"strategy": run.Strategy,
+			"samples":  strconv.Itoa(run.SampleCount),
+			"removed":  strconv.Itoa(len(run.Removed)),
+		}
+		if len(run.Removed) > 0 {
+			record.Details["removed_ids"] = audit.IDs(run.Removed)
+		}
+	}
+	record.Details["run_id"] = run.ID
+	record.Details["dataset_id"] = run.DatasetID
+	s.audit(ctx, log, record)
+
+	if s.opts.Store == nil {
+		return
+	}
+	if err := s.opts.Store.SaveRun(ctx, run); err != nil {
+		log.Error("recording run failed", "run_id", run.ID, "error", err)
+		return
+	}
+	s.custody(ctx, log, store.RunEvents(run))
+}
+
+// custody records events in the chains of custody of their samples in
+// Options.Store, if set. Events without an actor are attributed to the
+// client authenticated in ctx. Failures are logged.
+func (s *Server) custody(ctx context.Context, log *slog.Logger, events []store.Event) {
+	if s.opts.Store == nil || len(events) == 0 {
+		return
+	}
+	if identity, ok := auth.FromContext(ctx); ok {
+		for i := range events {
+			if events[i].Actor == "" {
+				events[i].Actor = identity.Name
+			}
+		}
+	}
+	if err := s.opts.Store.RecordEvents(ctx, events); err != nil {
+		log.Error("recording chain of custody failed", "kind", events[0].Kind, "error", err)
+	}
+}
+
+// audit appends record to Options.Audit, if set, attributed to the client
+// authenticated in ctx. Failures are logged.
+func (s *Server) audit(ctx context.Context, log *slog.Logger, record audit.Record) {
+	if s.opts.Audit == nil {
+		return
+	}
+	if identity, ok := auth.FromContext(ctx); ok {
+		record.Actor = identity.Name
+	}
+	if _, err := s.opts.Audit.Append(record); err != nil {
+		log.Error("recording audit record failed", "action", record.Action, "error", err)
+	}
+}
+
+// findScan returns a scan of p from memory or, for scans recorded before
+// the server started, from Options.Store.
+func (s *Server) findScan(ctx context.Context, p *project, id string) (*Scan, error) {
+	if scan, ok := p.scan(id); ok {
+		return scan, nil
+	}
+	if s.opts.Store != nil {
+		run, err := s.opts.Store.Run(ctx, p.name, id)
+		if err == nil && run.Kind == store.KindScan {
+			return &Scan{
+				ID:        run.ID,
+				DatasetID: run.DatasetID,
+				CreatedAt: run.CreatedAt,
+				Duration:  run.Duration,
+				Result:    run.Detection,
+			}, nil
+		}
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("no scan %q: %w", id, store.ErrNotFound)
+}
+
+// findDefense is findScan for defenses. Defenses loaded from the store do
+// not have their cleaned dataset.
+func (s *Server) findDefense(ctx context.Context, p *project, id string) (*Defense, error) {
+	if defense, ok := p.defense(id); ok {
+		return defense, nil
+	}
+	if s.opts.Store != nil {
+		run, err := s.opts.Store.Run(ctx, p.name, id)
+		if err == nil && run.Kind == store.KindDefense {
+			return &Defense{
+				ID:        run.ID,
+				DatasetID: run.DatasetID,
+				Strategy:  run.Strategy,
+				CreatedAt: run.CreatedAt,
+				Kept:      run.SampleCount - len(run.Removed),
+				Removed:   run.Removed,
+				Result:    run.Defense,
+			}, nil
+		}
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("no defense %q: %w", id, store.ErrNotFound)
+}
+
+// findStatus returns the status for an error finding a scan or defense.
+func findStatus(err error) int {
+	if errors.Is(err, store.ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// defenseRun returns the run recording a defense of a dataset with total
+// samples.
+func defenseRun(p *project, defense *Defense, source string, total int) store.Run {
+	risk := 0.0
+	if total > 0 {
+		risk = float64(len(defense.Removed)) / float64(total)
+	}
+	return store.Run{
+		ID:            defense.ID,
+		Project:       p.name,
+		Kind:          store.KindDefense,
+		DatasetID:     defense.DatasetID,
+		Source:        source,
+		Strategy:      defense.Strategy,
+		CreatedAt:     defense.CreatedAt,
+		SampleCount:   total,
+		PoisonedCount: len(defense.Removed),
+		RiskScore:     risk,
+		IsPoisoned:    len(defense.Removed) > 0,
+		Defense:       defense.Result,
+		Removed:       defense.Removed,
+	}
+}
+
+func (s *Server) listRuns(w http.ResponseWriter, r *http.Request, p *project) {
+	if s.opts.Store == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("run history requires a store"))
+		return
+	}
+	q, err := parseRunQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	q.Project = p.name
+
+	runs, err := s.opts.Store.Runs(r.Context(), q)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
+func (s *Server) sampleHistory(w http.ResponseWriter, r *http.Request, p *project, id string) {
+	if s.opts.Store == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("chain of custody requires a store"))
+		return
+	}
+	events, err := s.opts.Store.Events(r.Context(), p.name, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(events) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no events for sample %q", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+func (s *Server) getRun(w http.ResponseWriter, r *http.Request, p *project, id string) {
+	if s.opts.Store == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("run history requires a store"))
+		return
+	}
+	run, err := s.opts.Store.Run(r.Context(), p.name, id)
+	if err != nil {
+		writeError(w, findStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
+// parseRunQuery parses the filters of GET /v1/runs.
+func parseRunQuery(values url.Values) (store.Query, error) {
+	q := store.Query{
+		Kind:      store.Kind(values.Get("kind")),
+		DatasetID: values.Get("dataset_id"),
+	}
+	switch q.Kind {
+	case "", store.KindScan, store.KindDefense:
+	default:
+		return q, fmt.Errorf("invalid kind %q (want scan or defense)", q.Kind)
+	}
+
+	var err error
+	for name, dst := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if v := values.Get(name); v != "" {
+			if *dst, err = time.Parse(time.RFC3339, v); err != nil {
+				return q, fmt.Errorf("invalid %s %q (want RFC 3339)", name, v)
+			}
+		}
+	}
+	if v := values.Get("min_risk"); v != "" {
+		if q.MinRisk, err = strconv.ParseFloat(v, 64); err != nil {
+			return q, fmt.Errorf("invalid min_risk %q", v)
+		}
+	}
+	if v := values.Get("poisoned"); v != "" {
+		poisoned, err := strconv.ParseBool(v)
+		if err != nil {
+			return q, fmt.Errorf("invalid poisoned %q", v)
+		}
+		q.Poisoned = &poisoned
+	}
+	for name, dst := range map[string]*int{"limit": &q.Limit, "offset": &q.Offset} {
+		if v := values.Get(name); v != "" {
+			if *dst, err = strconv.Atoi(v); err != nil || *dst < 0 {
+				return q, fmt.Errorf("invalid %s %q", name, v)
+			}
+		}
+	}
+	return q, nil
+}
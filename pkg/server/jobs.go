@@ -0,0 +1,303 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/jobs"
+	"github.com/hallucinaut/modelpoison/pkg/store"
+)
+
+// JobProgress reports the partial results of a scan job.
+type JobProgress struct {
+	ID        string `json:"id"`
+	State     string `json:"state"`
+	Processed int    `json:"processed"`
+	Total     int    `json:"total"`
+	// Findings holds the samples flagged so far that are still buffered,
+	// the latest ones, and Offset is the number of findings before them.
+	Findings []detect.PoisonedSample `json:"findings"`
+	Offset   int                     `json:"offset"`
+}
+
+func (s *Server) createJob(w http.ResponseWriter, r *http.Request, p *project) {
+	var req ScanRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, loadStatus(err), err)
+		return
+	}
+	stored, ok := p.dataset(req.DatasetID)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no dataset %q", req.DatasetID))
+		return
+	}
+	if _, err := p.newDetector(req.Thresholds); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	s.submitJob(w, r, p, req, stored)
+}
+
+// submitJob queues a scan of stored and responds with the job.
+func (s *Server) submitJob(w http.ResponseWriter, r *http.Request, p *project, req ScanRequest, stored *storedDataset) {
+	spec := jobs.Spec{
+		Dataset:    req.DatasetID,
+		Priority:   req.Priority,
+		Thresholds: req.Thresholds,
+		Source:     "api",
+		Project:    p.name,
+	}
+
+	// Hold the lock until the run is registered so a worker picking up
+	// the job finds it.
+	s.mu.Lock()
+	job, err := s.jobs.Submit(spec)
+	if err == nil {
+		s.runs[job.ID] = s.newJobRun(r.Context(), s.baseURL(r), stored.info.Source, s.log(r).With("job_id", job.ID))
+	}
+	s.mu.Unlock()
+
+	switch {
+	case errors.Is(err, jobs.ErrQueueFull):
+		w.Header().Set("Retry-After", "60")
+		writeError(w, http.StatusServiceUnavailable, err)
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err)
+	default:
+		w.Header().Set("Location", p.path()+"/jobs/"+job.ID)
+		writeJSON(w, http.StatusAccepted, job)
+	}
+}
+
+// runJob scans the dataset of a job sample by sample, publishing findings
+// as they are produced.
+func (s *Server) runJob(ctx context.Context, job jobs.Job, progress detect.ProgressFunc) (*detect.DetectionResult, error) {
+	p, ok := s.projects[job.Project]
+	if !ok {
+		return nil, fmt.Errorf("no project %q", job.Project)
+	}
+	stored, ok := p.dataset(job.Dataset)
+	if !ok {
+		return nil, fmt.Errorf("no dataset %q", job.Dataset)
+	}
+	run, ok := s.run(job.ID)
+	if !ok {
+		return nil, fmt.Errorf("job %s was not submitted through the server", job.ID)
+	}
+	detector, err := p.newDetector(job.Thresholds)
+	if err != nil {
+		return nil, err
+	}
+
+	samples := stored.data.Samples
+	findings := make([]detect.PoisonedSample, 0, len(samples))
+	for i, sample := range samples {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		finding := detector.Analyze(sample)
+		findings = append(findings, finding)
+		if finding.IsPoisoned {
+			if err := run.add(ctx, finding); err != nil {
+				return nil, err
+			}
+		}
+		progress(i+1, len(samples))
+	}
+	result := detector.Summarize(findings, len(samples))
+	p.review(result)
+	return result, nil
+}
+
+// finishJob ends the findings stream of a job and, when it succeeded,
+// records its scan so it is served under /v1/scans like synchronous scans.
+func (s *Server) finishJob(job jobs.Job, result *detect.DetectionResult) {
+	run, ok := s.run(job.ID)
+	if !ok {
+		return
+	}
+	run.finish()
+	p, ok := s.projects[job.Project]
+	if !ok || result == nil {
+		return
+	}
+
+	scan := &Scan{
+		ID:        job.ID,
+		DatasetID: job.Dataset,
+		CreatedAt: job.StartedAt.UTC(),
+		Duration:  job.FinishedAt.Sub(*job.StartedAt),
+		Result:    result,
+	}
+	if stored, ok := p.dataset(job.Dataset); ok {
+		s.enforce(run.ctx, run.log, p, run.base, scan, stored)
+	}
+	p.mu.Lock()
+	p.scans[scan.ID] = scan
+	p.mu.Unlock()
+
+	run.log.Info("scan completed", "scan_id", scan.ID, "dataset_id", scan.DatasetID,
+		"samples", result.SampleCount, "poisoned", result.PoisonedCount, "risk_score", result.RiskScore)
+	s.save(run.ctx, run.log, store.ScanRun(p.name, scan.ID, scan.DatasetID, run.source, scan.CreatedAt, scan.Duration, result))
+	s.publish(p, run.log, run.base, scan, run.source)
+}
+
+func (s *Server) listJobs(w http.ResponseWriter, r *http.Request, p *project) {
+	all := s.jobs.List(jobs.State(r.URL.Query().Get("state")))
+	list := make([]jobs.Job, 0, len(all))
+	for _, job := range all {
+		if job.Project == p.name {
+			list = append(list, job)
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// job returns the job with the given ID if it belongs to project p.
+func (s *Server) job(p *project, id string) (jobs.Job, bool) {
+	job, ok := s.jobs.Get(id)
+	if !ok || job.Project != p.name {
+		return jobs.Job{}, false
+	}
+	return job, true
+}
+
+func (s *Server) getJob(w http.ResponseWriter, p *project, id string) {
+	job, ok := s.job(p, id)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no job %q", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s *Server) getJobResult(w http.ResponseWriter, p *project, id string) {
+	if _, ok := s.job(p, id); !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no job %q", id))
+		return
+	}
+	result, err := s.jobs.Result(id)
+	if err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	detect.WriteResult(w, result)
+}
+
+func (s *Server) cancelJob(w http.ResponseWriter, p *project, id string) {
+	if _, ok := s.job(p, id); !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no job %q", id))
+		return
+	}
+	job, err := s.jobs.Cancel(id)
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		writeError(w, http.StatusNotFound, fmt.Errorf("no job %q", id))
+	case err != nil:
+		writeError(w, http.StatusConflict, err)
+	default:
+		// A queued job never runs once canceled, so end its stream here.
+		if run, ok := s.run(id); ok && job.State.Done() {
+			run.finish()
+		}
+		writeJSON(w, http.StatusOK, job)
+	}
+}
+
+func (s *Server) getProgress(w http.ResponseWriter, p *project, id string) {
+	job, ok := s.job(p, id)
+	run, found := s.run(id)
+	if !ok || !found {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no job %q", id))
+		return
+	}
+
+	findings, offset := run.window()
+	if findings == nil {
+		findings = []detect.PoisonedSample{}
+	}
+	progress := JobProgress{ID: job.ID, State: string(job.State), Findings: findings, Offset: offset}
+	if job.Progress != nil {
+		progress.Processed, progress.Total = job.Progress.Processed, job.Progress.Total
+	}
+	writeJSON(w, http.StatusOK, progress)
+}
+
+// streamFindings writes the findings of a job as newline-delimited JSON,
+// one flagged sample per line, as they are produced. The response ends
+// when the job finishes. ?offset=N skips the first N findings, so clients
+// can resume an interrupted stream. Findings evicted from the job's buffer
+// before they were streamed are replaced by a {"skipped": N} line counting
+// them, so clients can still count lines to resume.
+func (s *Server) streamFindings(w http.ResponseWriter, r *http.Request, p *project, id string) {
+	_, found := s.job(p, id)
+	run, ok := s.run(id)
+	if !ok || !found {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no job %q", id))
+		return
+	}
+	offset := 0
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid offset %q", v))
+			return
+		}
+		offset = n
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	reader := run.attach(offset)
+	defer run.detach(reader)
+	for {
+		findings, skipped, done, changed, err := run.next(reader)
+		if err != nil {
+			run.log.Warn("streaming findings failed", "error", err)
+			return
+		}
+		if skipped > 0 {
+			if err := encoder.Encode(skippedFindings{Skipped: skipped}); err != nil {
+				return
+			}
+		}
+		for _, finding := range findings {
+			if err := encoder.Encode(finding); err != nil {
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if done {
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// skippedFindings stands in a findings stream for findings evicted
+// before they were streamed.
+type skippedFindings struct {
+	Skipped int `json:"skipped"`
+}
+
+func (s *Server) run(id string) (*jobRun, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	run, ok := s.runs[id]
+	return run, ok
+}
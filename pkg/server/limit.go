@@ -0,0 +1,32 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/hallucinaut/modelpoison/pkg/auth"
+)
+
+// clientKey identifies the client of r for rate limiting: the
+// authenticated identity if any, else the remote IP address.
+func clientKey(r *http.Request) string {
+	if identity, ok := auth.FromContext(r.Context()); ok {
+		return "client:" + identity.Name
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// loadStatus returns the status for an error loading a request body or a
+// fetched dataset: 413 when it exceeded the size limit, else 400.
+func loadStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
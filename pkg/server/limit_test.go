@@ -0,0 +1,87 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/auth"
+)
+
+// get sends a GET request for path from addr with token, if any.
+func get(s *Server, path, addr, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	r.RemoteAddr = addr
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	s := newServer(Options{RateLimit: 0.5, RateBurst: 2})
+	defer s.Close()
+	for i := 0; i < 2; i++ {
+		if w := get(s, "/v1/datasets", "192.0.2.1:1234", ""); w.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d, want 200", i+1, w.Code)
+		}
+	}
+	w := get(s, "/v1/datasets", "192.0.2.1:5678", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request beyond the burst: status %d, want 429", w.Code)
+	}
+	// A token refills in 2s at half a request per second.
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want 2", got)
+	}
+	if w := get(s, "/v1/datasets", "192.0.2.2:1234", ""); w.Code != http.StatusOK {
+		t.Errorf("another address: status %d, want 200", w.Code)
+	}
+	// Probes are never limited.
+	if w := get(s, "/healthz", "192.0.2.1:1234", ""); w.Code != http.StatusOK {
+		t.Errorf("/healthz: status %d, want 200", w.Code)
+	}
+}
+
+func TestRateLimitUnauthenticated(t *testing.T) {
+	s := newServer(Options{
+		RateLimit: 1,
+		RateBurst: 2,
+		Auth: auth.New(auth.Config{Tokens: []auth.Token{
+			{Name: "dashboard", Token: "view", Scopes: []auth.Scope{auth.ScopeRead}},
+		}}),
+	})
+	defer s.Close()
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		if w := get(s, "/v1/datasets", "192.0.2.1:1234", "guess"); w.Code != want {
+			t.Errorf("guess %d: status %d, want %d", i+1, w.Code, want)
+		}
+	}
+	// Authenticated clients have their own bucket, wherever they are.
+	for i := 0; i < 2; i++ {
+		if w := get(s, "/v1/datasets", "192.0.2.1:1234", "view"); w.Code != http.StatusOK {
+			t.Errorf("authenticated request %d: status %d, want 200", i+1, w.Code)
+		}
+	}
+	if w := get(s, "/v1/datasets", "192.0.2.9:1234", "view"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("authenticated request beyond the burst: status %d, want 429", w.Code)
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/datasets", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	if got := clientKey(r); got != "ip:192.0.2.1" {
+		t.Errorf("clientKey = %q, want the IP address", got)
+	}
+	r.RemoteAddr = "[2001:db8::1]:443"
+	if got := clientKey(r); got != "ip:2001:db8::1" {
+		t.Errorf("clientKey = %q, want the IPv6 address", got)
+	}
+	r = r.WithContext(auth.NewContext(r.Context(), auth.Identity{Name: "dashboard"}))
+	if got := clientKey(r); got != "client:dashboard" {
+		t.Errorf("clientKey = %q, want the identity", got)
+	}
+}
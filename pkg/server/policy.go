@@ -0,0 +1,200 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/internal/id"
+	"github.com/hallucinaut/modelpoison/pkg/policy"
+	"github.com/hallucinaut/modelpoison/pkg/quarantine"
+	"github.com/hallucinaut/modelpoison/pkg/store"
+	"github.com/hallucinaut/modelpoison/pkg/webhook"
+)
+
+// PolicyDecision is the policy decision on a scan.
+type PolicyDecision struct {
+	ScanID string `json:"scan_id"`
+	policy.Decision
+}
+
+// Check reports configuration errors found creating the projects, such as
+// policies that fail to compile. Requests to a project with a broken policy
+// fail until the server is restarted with a fixed configuration.
+func (s *Server) Check() error {
+	var errs []error
+	for _, p := range s.projects {
+		if p.policyErr != nil {
+			errs = append(errs, fmt.Errorf("project %s: %w", p.name, p.policyErr))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// evaluatePolicy evaluates the policies of p against a scan, for admission
+// controllers gating training jobs on the decision.
+func (s *Server) evaluatePolicy(w http.ResponseWriter, r *http.Request, p *project, id string) {
+	if p.config.Policy == nil {
+		writeError(w, http.StatusNotImplemented, errors.New("policy evaluation requires a policy"))
+		return
+	}
+	if p.policyErr != nil {
+		writeError(w, http.StatusInternalServerError, p.policyErr)
+		return
+	}
+	scan, err := s.findScan(r.Context(), p, id)
+	if err != nil {
+		writeError(w, findStatus(err), err)
+		return
+	}
+
+	input := p.policy.NewInput(scan.Result)
+	input.ScanID, input.Project, input.Dataset = scan.ID, p.name, scan.DatasetID
+	decision, err := p.policy.Evaluate(r.Context(), input)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("scan %s: %w", id, err))
+		return
+	}
+	s.log(r).Info("policy evaluated", "scan_id", scan.ID, "allow", decision.Allow, "deny", len(decision.Deny))
+	writeJSON(w, http.StatusOK, PolicyDecision{ScanID: scan.ID, Decision: decision})
+}
+
+// defaultQuarantineDir is the quarantine of policy rules that do not set
+// one.
+const defaultQuarantineDir = ".modelpoison/quarantine"
+
+// enforce applies the actions of the policy rules of p matching a scan of
+// stored before it is served, recording the matches, failure and cleaning
+// defense in the scan. base is the public base URL of the server. Failed
+// actions are logged.
+func (s *Server) enforce(ctx context.Context, log *slog.Logger, p *project, base string, scan *Scan, stored *storedDataset) {
+	if p.policy == nil || p.policyErr != nil || len(p.config.Policy.Rules) == 0 {
+		return
+	}
+	input := p.policy.NewInput(scan.Result)
+	input.ScanID, input.Project, input.Dataset = scan.ID, p.name, scan.DatasetID
+	scan.Policy = p.policy.Match(input)
+
+	quarantined := make(map[string]string)
+	cleaned := make(map[string]bool)
+	for _, m := range scan.Policy {
+		log.Info("policy rule matched", "scan_id", scan.ID, "rule", m.Rule, "actions", m.Actions)
+		if m.Has(policy.ActionWarn) {
+			log.Warn(m.Message, "scan_id", scan.ID)
+		}
+		if m.Has(policy.ActionFail) {
+			scan.Failed = true
+		}
+		if m.Has(policy.ActionNotify) {
+			event := webhook.Scan{
+				ID:        scan.ID,
+				Project:   p.name,
+				Dataset:   stored.info.Source,
+				ResultURL: base + p.path() + "/scans/" + scan.ID,
+				ReportURL: base + p.path() + "/scans/" + scan.ID + "/report?format=html",
+				Result:    scan.Result,
+			}
+			go func(m policy.Match) {
+				if err := webhook.New(m.Notify(), nil).Notify(context.Background(), event); err != nil {
+					log.Warn("policy notification failed", "rule", m.Rule, "error", err)
+				}
+			}(m)
+		}
+		for _, id := range m.Samples {
+			if m.Has(policy.ActionQuarantine) && quarantined[id] == "" {
+				quarantined[id] = m.Rule
+			}
+			if m.Has(policy.ActionClean) {
+				cleaned[id] = true
+			}
+		}
+	}
+
+	if len(cleaned) > 0 {
+		defense := &Defense{
+			ID:        id.New(),
+			DatasetID: scan.DatasetID,
+			Strategy:  "policy",
+			CreatedAt: time.Now().UTC(),
+			Removed:   make([]string, 0, len(cleaned)),
+		}
+		var kept []int
+		for i, sample := range stored.data.Samples {
+			if cleaned[sample.ID] {
+				defense.Removed = append(defense.Removed, sample.ID)
+			} else {
+				kept = append(kept, i)
+			}
+		}
+		defense.Kept = len(kept)
+		defense.cleaned = stored.data.Subset(kept)
+		scan.DefenseID = defense.ID
+
+		p.mu.Lock()
+		p.defenses[defense.ID] = defense
+		p.mu.Unlock()
+		log.Info("policy cleaned dataset", "scan_id", scan.ID, "defense_id", defense.ID, "removed", len(defense.Removed))
+		s.save(ctx, log, defenseRun(p, defense, stored.info.Source, len(stored.data.Samples)))
+	}
+
+	if len(quarantined) > 0 {
+		if err := s.quarantineSamples(ctx, log, p, scan, stored, quarantined); err != nil {
+			log.Error("policy quarantine failed", "scan_id", scan.ID, "error", err)
+		}
+	}
+}
+
+// quarantineSamples adds the samples of stored in quarantined, mapping
+// sample IDs to the rule quarantining them, to the quarantine of p, and
+// records them in their chains of custody.
+func (s *Server) quarantineSamples(ctx context.Context, log *slog.Logger, p *project, scan *Scan, stored *storedDataset, quarantined map[string]string) error {
+	findings := make(map[string]int, len(scan.Result.Samples))
+	for i, finding := range scan.Result.Samples {
+		findings[finding.ID] = i
+	}
+	var entries []quarantine.Entry
+	for i, sample := range stored.data.Samples {
+		rule, ok := quarantined[sample.ID]
+		if !ok {
+			continue
+		}
+		entry := quarantine.Entry{
+			SampleID: sample.ID,
+			Dataset:  stored.info.Source,
+			Header:   stored.data.Header,
+			Record:   stored.data.Records[i],
+			Reason:   "policy rule " + rule,
+		}
+		if j, ok := findings[sample.ID]; ok {
+			finding := scan.Result.Samples[j]
+			entry.Type, entry.Score, entry.Description = string(finding.Type), finding.Score, finding.Description
+		}
+		entries = append(entries, entry)
+	}
+
+	dir := p.config.Policy.Quarantine
+	if dir == "" {
+		dir = defaultQuarantineDir
+	}
+	s.quarantineMu.Lock()
+	defer s.quarantineMu.Unlock()
+	q, err := quarantine.Open(dir)
+	if err != nil {
+		return err
+	}
+	q.Add(entries...)
+	if err := q.Save(); err != nil {
+		return err
+	}
+
+	events := make([]store.Event, len(entries))
+	for i, entry := range entries {
+		events[i] = store.Event{Project: p.name, SampleID: entry.SampleID, Kind: store.EventQuarantined,
+			DatasetID: scan.DatasetID, RunID: scan.ID, Details: entry.Reason + " in " + dir}
+	}
+	s.custody(ctx, log, events)
+	return nil
+}
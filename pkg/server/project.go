@@ -0,0 +1,245 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/alert"
+	"github.com/hallucinaut/modelpoison/pkg/allowlist"
+	"github.com/hallucinaut/modelpoison/pkg/audit"
+	"github.com/hallucinaut/modelpoison/pkg/auth"
+	"github.com/hallucinaut/modelpoison/pkg/config"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/eventbus"
+	"github.com/hallucinaut/modelpoison/pkg/policy"
+	"github.com/hallucinaut/modelpoison/pkg/siem"
+	"github.com/hallucinaut/modelpoison/pkg/store"
+	"github.com/hallucinaut/modelpoison/pkg/webhook"
+)
+
+// project holds the configuration and state of one project. Projects
+// share nothing but the job workers.
+type project struct {
+	name     string
+	config   *config.Config
+	notifier *webhook.Notifier
+	alerter  *alert.Alerter
+	exporter *siem.Exporter
+	bus      *eventbus.Publisher
+	// policy gates scans; policyErr is set when its policies failed to
+	// compile.
+	policy    *policy.Engine
+	policyErr error
+
+	mu       sync.Mutex
+	datasets map[string]*storedDataset
+	scans    map[string]*Scan
+	defenses map[string]*Defense
+	allow    *allowlist.Allowlist
+}
+
+// ProjectInfo describes a project.
+type ProjectInfo struct {
+	Name string `json:"name"`
+	// Thresholds are the detection thresholds configured for the project.
+	Thresholds map[string]float64 `json:"thresholds,omitempty"`
+	Datasets   int                `json:"datasets"`
+	Scans      int                `json:"scans"`
+	Decisions  int                `json:"decisions"`
+}
+
+func newProject(name string, cfg *config.Config) *project {
+	p := &project{
+		name:     name,
+		config:   cfg,
+		datasets: make(map[string]*storedDataset),
+		scans:    make(map[string]*Scan),
+		defenses: make(map[string]*Defense),
+		allow:    allowlist.New(),
+	}
+	if len(cfg.Webhooks) > 0 {
+		p.notifier = webhook.New(cfg.Webhooks, nil)
+	}
+	if cfg.Alerts != nil {
+		p.alerter = alert.New(*cfg.Alerts, nil)
+	}
+	if len(cfg.SIEM) > 0 {
+		p.exporter = siem.New(cfg.SIEM, nil)
+	}
+	if len(cfg.EventBus) > 0 {
+		p.bus = eventbus.New(cfg.EventBus)
+	}
+	if cfg.Policy != nil {
+		p.policy, p.policyErr = policy.New(context.Background(), *cfg.Policy)
+	}
+	return p
+}
+
+// path returns the API path prefix of the project.
+func (p *project) path() string {
+	if p.name == config.DefaultProject {
+		return "/v1"
+	}
+	return "/v1/projects/" + p.name
+}
+
+// dataset returns the submitted dataset with the given ID.
+func (p *project) dataset(id string) (*storedDataset, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stored, ok := p.datasets[id]
+	return stored, ok
+}
+
+// scan returns the scan with the given ID.
+func (p *project) scan(id string) (*Scan, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	scan, ok := p.scans[id]
+	return scan, ok
+}
+
+// defense returns the applied defense with the given ID.
+func (p *project) defense(id string) (*Defense, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	defense, ok := p.defenses[id]
+	return defense, ok
+}
+
+// newDetector creates a detector with the project configuration and the
+// given threshold overrides.
+func (p *project) newDetector(thresholds map[string]float64) (*detect.Detector, error) {
+	detector := detect.NewDetector()
+	if err := p.config.Apply(detector); err != nil {
+		return nil, err
+	}
+	if err := (&config.Config{Thresholds: thresholds}).Apply(detector); err != nil {
+		return nil, err
+	}
+	return detector, nil
+}
+
+// review merges the project's review decisions into result, as the
+// annotate command does for saved results.
+func (p *project) review(result *detect.DetectionResult) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.allow.Len() == 0 {
+		return
+	}
+	for i, sample := range result.Samples {
+		if entry, ok := p.allow.Lookup(sample.ID); ok {
+			result.Samples[i].Review = string(entry.Decision)
+			result.Samples[i].ReviewNote = entry.Note
+		}
+	}
+}
+
+func (s *Server) listProjects(w http.ResponseWriter, r *http.Request) {
+	identity, authenticated := auth.FromContext(r.Context())
+
+	infos := make([]ProjectInfo, 0, len(s.projects))
+	for _, p := range s.projects {
+		if authenticated && !identity.InProject(p.name) {
+			continue
+		}
+		p.mu.Lock()
+		infos = append(infos, ProjectInfo{
+			Name:       p.name,
+			Thresholds: p.config.Thresholds,
+			Datasets:   len(p.datasets),
+			Scans:      len(p.scans),
+			Decisions:  p.allow.Len(),
+		})
+		p.mu.Unlock()
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (s *Server) listDecisions(w http.ResponseWriter, p *project) {
+	p.mu.Lock()
+	entries := p.allow.Entries()
+	p.mu.Unlock()
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *Server) setDecision(w http.ResponseWriter, r *http.Request, p *project) {
+	var entry allowlist.Entry
+	if err := decode(r, &entry); err != nil {
+		writeError(w, loadStatus(err), err)
+		return
+	}
+	if strings.TrimSpace(entry.ID) == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("id required"))
+		return
+	}
+	decision, err := allowlist.ParseDecision(string(entry.Decision))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	entry.Decision = decision
+	if identity, ok := auth.FromContext(r.Context()); ok && entry.Reviewer == "" {
+		entry.Reviewer = identity.Name
+	}
+
+	if entry.ReviewedAt.IsZero() {
+		entry.ReviewedAt = time.Now().UTC()
+	}
+	if s.opts.Store != nil {
+		if err := s.opts.Store.SetDecision(r.Context(), p.name, entry); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	p.mu.Lock()
+	p.allow.Set(entry)
+	p.mu.Unlock()
+	details := "decision " + string(entry.Decision)
+	if entry.Note != "" {
+		details += ": " + entry.Note
+	}
+	s.custody(r.Context(), s.log(r), []store.Event{{Project: p.name, SampleID: entry.ID, Kind: store.EventReviewed,
+		At: entry.ReviewedAt, Actor: entry.Reviewer, Details: details}})
+
+	s.audit(r.Context(), s.log(r), audit.Record{Action: audit.ActionAllowlistEdit, Project: p.name, Subject: "allowlist",
+		Details: audit.DecisionDetails(map[string]allowlist.Decision{entry.ID: entry.Decision})})
+	s.log(r).Info("decision recorded", "project", p.name, "sample_id", entry.ID, "decision", entry.Decision)
+	writeJSON(w, http.StatusCreated, entry)
+}
+
+func (s *Server) removeDecision(w http.ResponseWriter, r *http.Request, p *project, id string) {
+	p.mu.Lock()
+	entry, ok := p.allow.Lookup(id)
+	p.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no decision for sample %q", id))
+		return
+	}
+	if s.opts.Store != nil {
+		if err := s.opts.Store.RemoveDecision(r.Context(), p.name, id); err != nil && !errors.Is(err, store.ErrNotFound) {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	p.mu.Lock()
+	p.allow.Remove(id)
+	p.mu.Unlock()
+	s.custody(r.Context(), s.log(r), []store.Event{{Project: p.name, SampleID: id, Kind: store.EventReviewed,
+		Details: "decision " + string(entry.Decision) + " withdrawn"}})
+	s.audit(r.Context(), s.log(r), audit.Record{Action: audit.ActionAllowlistEdit, Project: p.name, Subject: "allowlist",
+		Details: audit.DecisionDetails(map[string]allowlist.Decision{id: ""})})
+	s.log(r).Info("decision removed", "project", p.name, "sample_id", id)
+	writeJSON(w, http.StatusOK, entry)
+}
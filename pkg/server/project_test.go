@@ -0,0 +1,28 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/auth"
+	"github.com/hallucinaut/modelpoison/pkg/config"
+)
+
+func TestProjectScoping(t *testing.T) {
+	s := newServer(Options{
+		Config: &config.Config{Projects: map[string]config.Project{"fraud": {}, "vision": {}}},
+		Auth: auth.New(auth.Config{Tokens: []auth.Token{
+			{Name: "fraud-team", Token: "fraud", Scopes: []auth.Scope{auth.ScopeRead}, Projects: []string{"fraud"}},
+		}}),
+	})
+	defer s.Close()
+	for path, want := range map[string]int{
+		"/v1/projects/fraud/datasets":  http.StatusOK,
+		"/v1/projects/vision/datasets": http.StatusForbidden,
+		"/v1/datasets":                 http.StatusForbidden,
+	} {
+		if w := get(s, path, "192.0.2.1:1234", "fraud"); w.Code != want {
+			t.Errorf("GET %s: status %d, want %d", path, w.Code, want)
+		}
+	}
+}
@@ -0,0 +1,1000 @@
+// Package server exposes detection and defenses over an HTTP JSON API.
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/hallucinaut/modelpoison/internal/id"
+	"github.com/hallucinaut/modelpoison/internal/ratelimit"
+	"github.com/hallucinaut/modelpoison/pkg/archive"
+	"github.com/hallucinaut/modelpoison/pkg/audit"
+	"github.com/hallucinaut/modelpoison/pkg/auth"
+	"github.com/hallucinaut/modelpoison/pkg/config"
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/defend"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/jobs"
+	"github.com/hallucinaut/modelpoison/pkg/policy"
+	"github.com/hallucinaut/modelpoison/pkg/report"
+	"github.com/hallucinaut/modelpoison/pkg/store"
+	"github.com/hallucinaut/modelpoison/pkg/webhook"
+)
+
+var tracer = otel.Tracer("github.com/hallucinaut/modelpoison/pkg/server")
+
+// Options configures a Server.
+type Options struct {
+	// Config holds the detector configuration applied to every scan before
+	// any per-request thresholds, and the projects served besides the
+	// default project.
+	Config *config.Config
+	// AllowFileURIs permits datasets to be submitted by local path or
+	// file:// URI. It is off by default because it lets clients read files
+	// on the server.
+	AllowFileURIs bool
+	// RemoteURIs permits datasets to be submitted by http(s) URI. It is
+	// off by default because it makes the server fetch URLs for clients.
+	// Unless AllowedHosts is set, the default HTTPClient refuses to
+	// connect to loopback, link-local and private addresses.
+	RemoteURIs bool
+	// AllowedHosts, when set, limits remote URIs to these host names or
+	// IP addresses, which are trusted whatever addresses they resolve to.
+	AllowedHosts []string
+	// HTTPClient fetches datasets submitted by http(s) URI. It defaults to
+	// a client checking every connection, including those of redirects,
+	// against RemoteURIs and AllowedHosts; a client set here is used as is.
+	HTTPClient *http.Client
+	// PublicURL is the base URL of the server used in webhook result links.
+	// When empty, links are derived from the scan request's host.
+	PublicURL string
+	// Logger receives structured diagnostics. It defaults to slog.Default.
+	Logger *slog.Logger
+	// AsyncSamples makes scans of datasets with at least this many samples
+	// run as background jobs. When 0, scans only run as jobs on request.
+	AsyncSamples int
+	// JobWorkers is the number of scan jobs run concurrently (default 1).
+	JobWorkers int
+	// StreamBuffer is the number of findings of each job kept in memory
+	// for /progress and /findings clients (default DefaultStreamBuffer);
+	// when negative, findings are never evicted. StreamPolicy says what a
+	// job does when its buffer is full (default StreamBlock), and
+	// StreamDir is the directory of files spilled under StreamSpill
+	// (default: the system temporary directory).
+	StreamBuffer int
+	StreamPolicy StreamPolicy
+	StreamDir    string
+	// Auth, when set, authenticates every API request and checks its
+	// scope. The health probes are always open.
+	Auth *auth.Authenticator
+	// MaxBodyBytes caps request bodies and datasets fetched by URI; larger
+	// ones are rejected with 413. When 0, sizes are not limited.
+	MaxBodyBytes int64
+	// RateLimit is the sustained number of requests per second accepted
+	// from each client, identified by its credentials or else its IP
+	// address. Requests failing authentication count against their IP
+	// address. Requests beyond it get 429. When 0, rates are not limited.
+	RateLimit float64
+	// RateBurst is the number of requests a client may send at once
+	// (default RateLimit, rounded up).
+	RateBurst int
+	// Store, when set, records scans, defenses and review decisions so
+	// they outlive the process and can be queried under /v1/runs. Call
+	// Restore before serving to load the recorded decisions.
+	Store *store.Store
+	// Audit, when set, receives a record of every scan, defense and review
+	// decision, attributed to the authenticated client.
+	Audit *audit.Log
+}
+
+// Server serves the API. It keeps datasets and results in memory,
+// isolated per project, and records results in Options.Store if set.
+type Server struct {
+	opts     Options
+	projects map[string]*project
+	jobs     *jobs.Queue
+	stop     context.CancelFunc
+	draining atomic.Bool
+	limiter  *ratelimit.Limiter
+
+	mu   sync.Mutex
+	runs map[string]*jobRun
+	// quarantine serializes the quarantine actions of policy rules.
+	quarantineMu sync.Mutex
+}
+
+// storedDataset is a submitted dataset.
+type storedDataset struct {
+	info DatasetInfo
+	data *dataset.Dataset
+}
+
+// DatasetInfo describes a submitted dataset.
+type DatasetInfo struct {
+	ID        string    `json:"id"`
+	Source    string    `json:"source"`
+	Samples   int       `json:"samples"`
+	Features  []string  `json:"features"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SubmitDatasetRequest submits a dataset by URI. Datasets can also be
+// uploaded as a text/csv request body.
+type SubmitDatasetRequest struct {
+	URI string `json:"uri"`
+}
+
+// ScanRequest launches a scan of a submitted dataset.
+type ScanRequest struct {
+	DatasetID string `json:"dataset_id"`
+	// Thresholds overrides detection thresholds for this scan.
+	Thresholds map[string]float64 `json:"thresholds,omitempty"`
+	// Async runs the scan as a background job regardless of the dataset
+	// size.
+	Async bool `json:"async,omitempty"`
+	// Priority orders queued scan jobs; higher priorities run first.
+	Priority int `json:"priority,omitempty"`
+}
+
+// Scan is a completed scan.
+type Scan struct {
+	ID        string                  `json:"id"`
+	DatasetID string                  `json:"dataset_id"`
+	CreatedAt time.Time               `json:"created_at"`
+	Duration  time.Duration           `json:"duration_ns"`
+	Result    *detect.DetectionResult `json:"result"`
+	// Policy lists the policy rules matching the scan, and Failed is set
+	// when one of them fails it. DefenseID names the defense holding the
+	// dataset cleaned by auto_clean rules.
+	Policy    []policy.Match `json:"policy,omitempty"`
+	Failed    bool           `json:"failed,omitempty"`
+	DefenseID string         `json:"defense_id,omitempty"`
+}
+
+// DefenseRequest applies a filtering defense to a submitted dataset.
+type DefenseRequest struct {
+	DatasetID string `json:"dataset_id"`
+	Strategy  string `json:"strategy"`
+}
+
+// Defense is an applied defense. The cleaned dataset can be downloaded
+// from /v1/defenses/{id}/dataset.
+type Defense struct {
+	ID        string                `json:"id"`
+	DatasetID string                `json:"dataset_id"`
+	Strategy  string                `json:"strategy"`
+	CreatedAt time.Time             `json:"created_at"`
+	Kept      int                   `json:"kept"`
+	Removed   []string              `json:"removed"`
+	Result    *defend.DefenseResult `json:"result"`
+
+	cleaned *dataset.Dataset
+}
+
+// errorResponse is the body of error responses.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// New creates a server.
+func New(opts Options) *Server {
+	if opts.Config == nil {
+		opts.Config = &config.Config{}
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if opts.StreamBuffer == 0 {
+		opts.StreamBuffer = DefaultStreamBuffer
+	}
+	if opts.StreamPolicy == "" {
+		opts.StreamPolicy = StreamBlock
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = remoteClient(opts.AllowedHosts)
+	}
+	s := &Server{
+		opts:     opts,
+		projects: make(map[string]*project),
+		runs:     make(map[string]*jobRun),
+	}
+	s.projects[config.DefaultProject] = newProject(config.DefaultProject, opts.Config)
+	for name := range opts.Config.Projects {
+		cfg, _ := opts.Config.ForProject(name)
+		s.projects[name] = newProject(name, cfg)
+	}
+	// An in-memory queue cannot fail to open.
+	s.jobs, _ = jobs.Open(jobs.Options{
+		Workers:  opts.JobWorkers,
+		Run:      s.runJob,
+		Finished: s.finishJob,
+		Logger:   opts.Logger,
+	})
+	if opts.RateLimit > 0 {
+		s.limiter = ratelimit.New(opts.RateLimit, opts.RateBurst)
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	s.stop = stop
+	s.jobs.Start(ctx)
+	return s
+}
+
+// Drain makes /readyz report the server as not ready, so load balancers
+// stop routing new requests to it before it shuts down.
+func (s *Server) Drain() {
+	s.draining.Store(true)
+}
+
+// Close stops the scan job workers, aborting running jobs, waits for them
+// to return, closes the event bus connections and removes the findings
+// spilled by jobs.
+func (s *Server) Close() {
+	s.stop()
+	s.jobs.Wait()
+	for _, p := range s.projects {
+		p.bus.Close()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, run := range s.runs {
+		if err := run.close(); err != nil {
+			run.log.Warn("removing spilled findings failed", "error", err)
+		}
+	}
+}
+
+// ServeHTTP routes API requests:
+//
+//	GET  /healthz                    liveness probe
+//	GET  /readyz                     readiness probe; 503 once draining
+//	GET  /v1/projects                list the projects the client may access
+//	GET  /v1/datasets                list submitted datasets
+//	POST /v1/datasets                upload (text/csv) or submit {"uri": ...}
+//	GET  /v1/datasets/{id}           describe a dataset
+//	GET  /v1/scans                   list scans
+//	POST /v1/scans                   scan a dataset, as a job if large or {"async": true}
+//	GET  /v1/scans/{id}              fetch a scan and its result
+//	GET  /v1/scans/{id}/report       render the result (?format=text|html|json)
+//	GET  /v1/scans/{id}/policy       evaluate the configured Rego policies
+//	POST /v1/defenses                apply a filtering defense to a dataset
+//	GET  /v1/defenses/{id}           fetch an applied defense
+//	GET  /v1/defenses/{id}/dataset   download the cleaned dataset as CSV
+//	GET  /v1/jobs                    list scan jobs (?state=queued|running|...)
+//	POST /v1/jobs                    queue a scan of a dataset
+//	GET  /v1/jobs/{id}               fetch a job and its progress
+//	DELETE /v1/jobs/{id}             cancel a job
+//	GET  /v1/jobs/{id}/progress      findings so far of a job
+//	GET  /v1/jobs/{id}/findings      stream findings as NDJSON as they are produced
+//	GET  /v1/jobs/{id}/result        fetch the result of a succeeded job
+//	GET  /v1/allowlist               list review decisions
+//	POST /v1/allowlist               record a decision {"id": ..., "decision": ...}
+//	DELETE /v1/allowlist/{id}        remove the decision for a sample
+//	GET  /v1/runs                    query recorded scans and defenses (?kind=&since=&min_risk=...)
+//	GET  /v1/runs/{id}               fetch a recorded run and its result
+//	GET  /v1/samples/{id}/history    a sample's chain of custody, oldest first
+//
+// These routes serve the default project. The same routes under
+// /v1/projects/{project}/ serve a configured project, whose datasets,
+// results, jobs and allowlist are kept apart from other projects.
+//
+// With Options.Auth set, reads need the read scope, POST /v1/defenses the
+// defend scope and every other request the scan scope, and clients limited
+// to some projects cannot access the others.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/healthz" || r.URL.Path == "/readyz" {
+		s.probe(w, r)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 || parts[0] != "v1" {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no such endpoint %s", r.URL.Path))
+		return
+	}
+
+	projectName, prefix := config.DefaultProject, "/v1"
+	if parts[1] == "projects" && len(parts) > 2 {
+		projectName, prefix = parts[2], "/v1/projects/{project}"
+		parts = append([]string{"v1"}, parts[3:]...)
+		if len(parts) < 2 {
+			writeError(w, http.StatusNotFound, fmt.Errorf("no such endpoint %s", r.URL.Path))
+			return
+		}
+	}
+
+	route := parts[1]
+	id, sub := "", ""
+	pattern := prefix + "/" + route
+	if len(parts) > 2 {
+		id = parts[2]
+		pattern += "/{id}"
+	}
+	if len(parts) > 3 {
+		sub = strings.Join(parts[3:], "/")
+		pattern += "/" + sub
+	}
+
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracer.Start(ctx, r.Method+" "+pattern, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(
+		attribute.String("http.method", r.Method),
+		attribute.String("http.route", pattern),
+	))
+	defer span.End()
+	r = r.WithContext(ctx)
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	w = recorder
+	start := time.Now()
+	defer func() {
+		s.log(r).Info("request", "method", r.Method, "route", pattern, "project", projectName,
+			"status", recorder.status, "duration", time.Since(start))
+		span.SetAttributes(attribute.Int("http.status_code", recorder.status))
+		if recorder.status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+	}()
+
+	// Requests are limited before they are rejected, so failed
+	// authentication attempts count against the client's IP address.
+	var authErr error
+	if s.opts.Auth != nil {
+		var identity auth.Identity
+		if identity, authErr = s.opts.Auth.Authenticate(r.Header.Get("Authorization"), r.TLS); authErr == nil {
+			r = r.WithContext(auth.NewContext(r.Context(), identity))
+		}
+	}
+	if s.limiter != nil {
+		if ok, wait := s.limiter.Allow(clientKey(r), time.Now()); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(ratelimit.RetryAfter(wait)))
+			writeError(w, http.StatusTooManyRequests, fmt.Errorf("rate limit exceeded; retry in %s", wait.Round(time.Millisecond)))
+			return
+		}
+	}
+	if s.opts.Auth != nil {
+		if authErr != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="modelpoison"`)
+			writeError(w, http.StatusUnauthorized, authErr)
+			return
+		}
+		identity, _ := auth.FromContext(r.Context())
+		span.SetAttributes(attribute.String("enduser.id", identity.Name))
+		if scope := requiredScope(route, r.Method); !identity.Allows(scope) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("%s lacks the %s scope", identity.Name, scope))
+			return
+		}
+		if route != "projects" && !identity.InProject(projectName) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("%s may not access project %q", identity.Name, projectName))
+			return
+		}
+	}
+	if s.opts.MaxBodyBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxBodyBytes)
+	}
+
+	if route == "projects" && prefix == "/v1" && id == "" {
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodGet: s.listProjects,
+		})
+		return
+	}
+	p, ok := s.projects[projectName]
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no project %q", projectName))
+		return
+	}
+	span.SetAttributes(attribute.String("modelpoison.project", p.name))
+
+	switch {
+	case route == "datasets" && id == "":
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodGet:  func(w http.ResponseWriter, r *http.Request) { s.listDatasets(w, r, p) },
+			http.MethodPost: func(w http.ResponseWriter, r *http.Request) { s.submitDataset(w, r, p) },
+		})
+	case route == "datasets" && sub == "":
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodGet: func(w http.ResponseWriter, r *http.Request) { s.getDataset(w, p, id) },
+		})
+	case route == "scans" && id == "":
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodGet:  func(w http.ResponseWriter, r *http.Request) { s.listScans(w, r, p) },
+			http.MethodPost: func(w http.ResponseWriter, r *http.Request) { s.createScan(w, r, p) },
+		})
+	case route == "scans" && sub == "":
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodGet: func(w http.ResponseWriter, r *http.Request) { s.getScan(w, r, p, id) },
+		})
+	case route == "scans" && sub == "report":
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodGet: func(w http.ResponseWriter, r *http.Request) { s.getReport(w, r, p, id) },
+		})
+	case route == "scans" && sub == "policy":
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodGet: func(w http.ResponseWriter, r *http.Request) { s.evaluatePolicy(w, r, p, id) },
+		})
+	case route == "defenses" && id == "":
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodPost: func(w http.ResponseWriter, r *http.Request) { s.createDefense(w, r, p) },
+		})
+	case route == "defenses" && sub == "":
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodGet: func(w http.ResponseWriter, r *http.Request) { s.getDefense(w, r, p, id) },
+		})
+	case route == "defenses" && sub == "dataset":
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodGet: func(w http.ResponseWriter, r *http.Request) { s.getCleaned(w, r, p, id) },
+		})
+	case route == "jobs" && id == "":
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodGet:  func(w http.ResponseWriter, r *http.Request) { s.listJobs(w, r, p) },
+			http.MethodPost: func(w http.ResponseWriter, r *http.Request) { s.createJob(w, r, p) },
+		})
+	case route == "jobs" && sub == "":
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodGet:    func(w http.ResponseWriter, r *http.Request) { s.getJob(w, p, id) },
+			http.MethodDelete: func(w http.ResponseWriter, r *http.Request) { s.cancelJob(w, p, id) },
+		})
+	case route == "jobs" && sub == "progress":
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodGet: func(w http.ResponseWriter, r *http.Request) { s.getProgress(w, p, id) },
+		})
+	case route == "jobs" && sub == "findings":
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodGet: func(w http.ResponseWriter, r *http.Request) { s.streamFindings(w, r, p, id) },
+		})
+	case route == "jobs" && sub == "result":
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodGet: func(w http.ResponseWriter, r *http.Request) { s.getJobResult(w, p, id) },
+		})
+	case route == "allowlist" && id == "":
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodGet:  func(w http.ResponseWriter, r *http.Request) { s.listDecisions(w, p) },
+			http.MethodPost: func(w http.ResponseWriter, r *http.Request) { s.setDecision(w, r, p) },
+		})
+	case route == "runs" && id == "":
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodGet: func(w http.ResponseWriter, r *http.Request) { s.listRuns(w, r, p) },
+		})
+	case route == "runs" && sub == "":
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodGet: func(w http.ResponseWriter, r *http.Request) { s.getRun(w, r, p, id) },
+		})
+	case route == "allowlist" && sub == "":
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodDelete: func(w http.ResponseWriter, r *http.Request) { s.removeDecision(w, r, p, id) },
+		})
+	case route == "samples" && sub == "history":
+		s.methods(w, r, map[string]http.HandlerFunc{
+			http.MethodGet: func(w http.ResponseWriter, r *http.Request) { s.sampleHistory(w, r, p, id) },
+		})
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("no such endpoint %s", r.URL.Path))
+	}
+}
+
+// requiredScope returns the scope needed to call method on route.
+func requiredScope(route, method string) auth.Scope {
+	switch {
+	case method == http.MethodGet || method == http.MethodHead:
+		return auth.ScopeRead
+	case route == "defenses":
+		return auth.ScopeDefend
+	default:
+		return auth.ScopeScan
+	}
+}
+
+// probeResponse is the body of /healthz and /readyz responses.
+type probeResponse struct {
+	Status string `json:"status"`
+}
+
+// probe answers the liveness and readiness probes. They are not traced and
+// are only logged at debug level, as orchestrators call them every few
+// seconds.
+func (s *Server) probe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	status, body := http.StatusOK, probeResponse{Status: "ok"}
+	if r.URL.Path == "/readyz" && s.draining.Load() {
+		status, body = http.StatusServiceUnavailable, probeResponse{Status: "draining"}
+	}
+	s.opts.Logger.Debug("probe", "path", r.URL.Path, "status", status)
+	writeJSON(w, status, body)
+}
+
+// statusRecorder records the status code of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush flushes the underlying response writer where supported, so that
+// streamed responses reach the client.
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// methods dispatches r to the handler for its method.
+func (s *Server) methods(w http.ResponseWriter, r *http.Request, handlers map[string]http.HandlerFunc) {
+	handler, ok := handlers[r.Method]
+	if !ok {
+		allowed := make([]string, 0, len(handlers))
+		for method := range handlers {
+			allowed = append(allowed, method)
+		}
+		sort.Strings(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		return
+	}
+	handler(w, r)
+}
+
+func (s *Server) listDatasets(w http.ResponseWriter, r *http.Request, p *project) {
+	p.mu.Lock()
+	infos := make([]DatasetInfo, 0, len(p.datasets))
+	for _, d := range p.datasets {
+		infos = append(infos, d.info)
+	}
+	p.mu.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		if !infos[i].CreatedAt.Equal(infos[j].CreatedAt) {
+			return infos[i].CreatedAt.Before(infos[j].CreatedAt)
+		}
+		return infos[i].ID < infos[j].ID
+	})
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (s *Server) submitDataset(w http.ResponseWriter, r *http.Request, p *project) {
+	var (
+		data   *dataset.Dataset
+		source string
+		err    error
+	)
+
+	if isJSON(r) {
+		var req SubmitDatasetRequest
+		if err := decode(r, &req); err != nil {
+			writeError(w, loadStatus(err), err)
+			return
+		}
+		if req.URI == "" {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("uri required"))
+			return
+		}
+		source = req.URI
+		data, err = s.fetchDataset(r.Context(), s.log(r), req.URI)
+	} else {
+		source = "upload"
+		data, err = dataset.ReadCSVContext(r.Context(), r.Body, dataset.Columns{})
+	}
+	if err != nil {
+		writeError(w, loadStatus(err), fmt.Errorf("load dataset: %w", err))
+		return
+	}
+
+	stored := &storedDataset{
+		info: DatasetInfo{
+			ID:        id.New(),
+			Source:    source,
+			Samples:   len(data.Samples),
+			Features:  data.Features,
+			CreatedAt: time.Now().UTC(),
+		},
+		data: data,
+	}
+
+	p.mu.Lock()
+	p.datasets[stored.info.ID] = stored
+	p.mu.Unlock()
+
+	s.log(r).Info("dataset submitted", "dataset_id", stored.info.ID, "samples", stored.info.Samples, "source", source)
+	ids := make([]string, len(data.Samples))
+	for i, sample := range data.Samples {
+		ids[i] = sample.ID
+	}
+	s.custody(r.Context(), s.log(r), store.IngestEvents(p.name, stored.info.ID, source, stored.info.CreatedAt, ids))
+	writeJSON(w, http.StatusCreated, stored.info)
+}
+
+// fetchDataset loads a dataset from an http(s) URI or from a local path or
+// file:// URI, when allowed. Failed fetches are logged to log and reported
+// without detail, so clients cannot probe the hosts the server reaches.
+func (s *Server) fetchDataset(ctx context.Context, log *slog.Logger, uri string) (*dataset.Dataset, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		if !s.opts.RemoteURIs {
+			return nil, fmt.Errorf("remote URIs are disabled on this server")
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+		if err != nil {
+			return nil, err
+		}
+		otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+		resp, err := s.opts.HTTPClient.Do(req)
+		if err != nil {
+			log.Warn("dataset fetch failed", "uri", uri, "error", err)
+			return nil, errFetch
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			log.Warn("dataset fetch failed", "uri", uri, "status", resp.Status)
+			return nil, errFetch
+		}
+		var body io.Reader = resp.Body
+		if s.opts.MaxBodyBytes > 0 {
+			body = http.MaxBytesReader(nil, resp.Body, s.opts.MaxBodyBytes)
+		}
+		return dataset.ReadCSVContext(ctx, body, dataset.Columns{})
+	case "file", "":
+		if !s.opts.AllowFileURIs {
+			return nil, fmt.Errorf("file URIs are disabled on this server")
+		}
+		path := uri
+		if u.Scheme == "file" {
+			path = u.Path
+		}
+		return dataset.LoadCSVContext(ctx, path, dataset.Columns{})
+	}
+
+	return nil, fmt.Errorf("unsupported URI scheme %q", u.Scheme)
+}
+
+func (s *Server) getDataset(w http.ResponseWriter, p *project, id string) {
+	stored, ok := p.dataset(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no dataset %q", id))
+		return
+	}
+	writeJSON(w, http.StatusOK, stored.info)
+}
+
+func (s *Server) listScans(w http.ResponseWriter, r *http.Request, p *project) {
+	p.mu.Lock()
+	scans := make([]*Scan, 0, len(p.scans))
+	for _, scan := range p.scans {
+		scans = append(scans, scan)
+	}
+	p.mu.Unlock()
+
+	sort.Slice(scans, func(i, j int) bool {
+		if !scans[i].CreatedAt.Equal(scans[j].CreatedAt) {
+			return scans[i].CreatedAt.Before(scans[j].CreatedAt)
+		}
+		return scans[i].ID < scans[j].ID
+	})
+	writeJSON(w, http.StatusOK, scans)
+}
+
+func (s *Server) createScan(w http.ResponseWriter, r *http.Request, p *project) {
+	var req ScanRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, loadStatus(err), err)
+		return
+	}
+	stored, ok := p.dataset(req.DatasetID)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no dataset %q", req.DatasetID))
+		return
+	}
+
+	detector, err := p.newDetector(req.Thresholds)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := detector.Validate(stored.data.Samples); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Async || (s.opts.AsyncSamples > 0 && stored.info.Samples >= s.opts.AsyncSamples) {
+		s.submitJob(w, r, p, req, stored)
+		return
+	}
+
+	start := time.Now()
+	result := detector.DetectContext(r.Context(), stored.data.Samples)
+	p.review(result)
+	scan := &Scan{
+		ID:        id.New(),
+		DatasetID: req.DatasetID,
+		CreatedAt: start.UTC(),
+		Duration:  time.Since(start),
+		Result:    result,
+	}
+	s.enforce(r.Context(), s.log(r), p, s.baseURL(r), scan, stored)
+
+	p.mu.Lock()
+	p.scans[scan.ID] = scan
+	p.mu.Unlock()
+
+	s.log(r).Info("scan completed", "scan_id", scan.ID, "dataset_id", req.DatasetID,
+		"samples", result.SampleCount, "poisoned", result.PoisonedCount, "risk_score", result.RiskScore)
+	s.save(r.Context(), s.log(r), store.ScanRun(p.name, scan.ID, scan.DatasetID, stored.info.Source, scan.CreatedAt, scan.Duration, result))
+	s.publish(p, s.log(r), s.baseURL(r), scan, stored.info.Source)
+	writeJSON(w, http.StatusCreated, scan)
+}
+
+// publish archives the evidence of a completed scan of project p,
+// delivers its webhook events and sends its alerts in the background. base
+// is the public base URL of the server.
+func (s *Server) publish(p *project, log *slog.Logger, base string, scan *Scan, source string) {
+	if p.notifier == nil && p.alerter == nil && p.exporter == nil && p.bus == nil && p.config.Archive == nil {
+		return
+	}
+
+	event := webhook.Scan{
+		ID:        scan.ID,
+		Project:   p.name,
+		Dataset:   source,
+		ResultURL: base + p.path() + "/scans/" + scan.ID,
+		ReportURL: base + p.path() + "/scans/" + scan.ID + "/report?format=html",
+		Result:    scan.Result,
+	}
+	log = log.With("scan_id", scan.ID)
+	go func() {
+		if p.config.Archive != nil {
+			if link := archive.URLFor(s.archive(p, log, event), archive.FormatHTML); link != "" {
+				event.ReportURL = link
+			}
+		}
+		if err := p.notifier.Notify(context.Background(), event); err != nil {
+			log.Warn("webhook delivery failed", "error", err)
+		}
+		if err := p.alerter.Alert(context.Background(), event); err != nil {
+			log.Warn("alert delivery failed", "error", err)
+		}
+		if err := p.exporter.Export(context.Background(), event); err != nil {
+			log.Warn("SIEM export failed", "error", err)
+		}
+		if err := p.bus.Publish(context.Background(), event); err != nil {
+			log.Warn("event bus publishing failed", "error", err)
+		}
+	}()
+}
+
+// archive uploads the evidence of a scan to the archive of project p and
+// returns the uploaded objects.
+func (s *Server) archive(p *project, log *slog.Logger, event webhook.Scan) []archive.Object {
+	archiver, err := archive.New(*p.config.Archive, nil)
+	if err != nil {
+		log.Warn("archiving failed", "error", err)
+		return nil
+	}
+	objects, err := archiver.Archive(context.Background(), archive.Evidence{
+		ID:      event.ID,
+		Project: p.name,
+		Dataset: event.Dataset,
+		Result:  event.Result,
+	})
+	for _, object := range objects {
+		log.Info("scan archived", "format", object.Format, "url", object.URL)
+	}
+	if err != nil {
+		log.Warn("archiving failed", "error", err)
+	}
+	return objects
+}
+
+// baseURL returns the public base URL of the server.
+func (s *Server) baseURL(r *http.Request) string {
+	if s.opts.PublicURL != "" {
+		return strings.TrimSuffix(s.opts.PublicURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func (s *Server) getScan(w http.ResponseWriter, r *http.Request, p *project, id string) {
+	scan, err := s.findScan(r.Context(), p, id)
+	if err != nil {
+		writeError(w, findStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, scan)
+}
+
+func (s *Server) getReport(w http.ResponseWriter, r *http.Request, p *project, id string) {
+	scan, err := s.findScan(r.Context(), p, id)
+	if err != nil {
+		writeError(w, findStatus(err), err)
+		return
+	}
+
+	var buf bytes.Buffer
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		err = detect.RenderReport(&buf, nil, scan.Result)
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err = report.WriteHTML(&buf, scan.Result)
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		err = detect.WriteResult(&buf, scan.Result)
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid format %q (want text, html or json)", format))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Write(buf.Bytes())
+}
+
+func (s *Server) createDefense(w http.ResponseWriter, r *http.Request, p *project) {
+	var req DefenseRequest
+	if err := decode(r, &req); err != nil {
+		writeError(w, loadStatus(err), err)
+		return
+	}
+	stored, ok := p.dataset(req.DatasetID)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("no dataset %q", req.DatasetID))
+		return
+	}
+
+	defender := defend.NewDefender()
+	strategy, ok := defender.Strategy(req.Strategy)
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unknown strategy %q", req.Strategy))
+		return
+	}
+	if !strategy.FiltersSamples() {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("strategy %q does not remove samples from a dataset", req.Strategy))
+		return
+	}
+
+	removed := defender.RemovedIndicesContext(r.Context(), stored.data.Samples, req.Strategy)
+
+	isRemoved := make(map[int]bool, len(removed))
+	defense := &Defense{
+		ID:        id.New(),
+		DatasetID: req.DatasetID,
+		Strategy:  req.Strategy,
+		CreatedAt: time.Now().UTC(),
+		Removed:   make([]string, 0, len(removed)),
+	}
+	for _, i := range removed {
+		isRemoved[i] = true
+		defense.Removed = append(defense.Removed, stored.data.Samples[i].ID)
+	}
+	var kept []int
+	for i := range stored.data.Samples {
+		if !isRemoved[i] {
+			kept = append(kept, i)
+		}
+	}
+	defense.Kept = len(kept)
+	defense.cleaned = stored.data.Subset(kept)
+
+	risk := 0.0
+	if n := len(stored.data.Samples); n > 0 {
+		risk = float64(len(removed)) / float64(n)
+	}
+	defense.Result = defender.Defend(risk, req.Strategy)
+
+	p.mu.Lock()
+	p.defenses[defense.ID] = defense
+	p.mu.Unlock()
+
+	s.log(r).Info("defense applied", "defense_id", defense.ID, "dataset_id", req.DatasetID,
+		"strategy", req.Strategy, "removed", len(removed))
+	s.save(r.Context(), s.log(r), defenseRun(p, defense, stored.info.Source, len(stored.data.Samples)))
+	writeJSON(w, http.StatusCreated, defense)
+}
+
+func (s *Server) getDefense(w http.ResponseWriter, r *http.Request, p *project, id string) {
+	defense, err := s.findDefense(r.Context(), p, id)
+	if err != nil {
+		writeError(w, findStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, defense)
+}
+
+func (s *Server) getCleaned(w http.ResponseWriter, r *http.Request, p *project, id string) {
+	defense, err := s.findDefense(r.Context(), p, id)
+	if err != nil {
+		writeError(w, findStatus(err), err)
+		return
+	}
+	if defense.cleaned == nil {
+		writeError(w, http.StatusGone, fmt.Errorf("cleaned dataset of defense %q is no longer available", id))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := defense.cleaned.WriteCSV(&buf); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv")
+	w.Write(buf.Bytes())
+}
+
+// log returns the logger for records about r, carrying its trace ID when
+// the request is traced.
+func (s *Server) log(r *http.Request) *slog.Logger {
+	log := s.opts.Logger
+	if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+		log = log.With("trace_id", sc.TraceID().String())
+	}
+	if identity, ok := auth.FromContext(r.Context()); ok {
+		log = log.With("client", identity.Name)
+	}
+	return log
+}
+
+// isJSON reports whether the request body is JSON.
+func isJSON(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+}
+
+// decode decodes a JSON request body into v.
+func decode(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("request body required")
+		}
+		return fmt.Errorf("decode request: %w", err)
+	}
+	return nil
+}
+
+// writeJSON writes v as an indented JSON response.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+// writeError writes a JSON error response.
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,366 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/auth"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/jobs"
+)
+
+// testCSV returns a dataset of 40 similar samples of 21 features and
+// "spike", whose features are zero but for a spike in the last.
+func testCSV() string {
+	var b strings.Builder
+	b.WriteString("id")
+	for f := 0; f < 21; f++ {
+		fmt.Fprintf(&b, ",f%d", f)
+	}
+	b.WriteString(",label\n")
+	for i := 0; i <= 40; i++ {
+		if i == 40 {
+			b.WriteString("spike")
+		} else {
+			fmt.Fprintf(&b, "s%d", i)
+		}
+		for f := 0; f < 21; f++ {
+			v := 1 + float64((i+f)%5)*0.01
+			if i == 40 {
+				v = 0
+				if f == 20 {
+					v = 50
+				}
+			}
+			fmt.Fprintf(&b, ",%.2f", v)
+		}
+		fmt.Fprintf(&b, ",%d\n", i%2)
+	}
+	return b.String()
+}
+
+// newServer returns a server with opts, discarding its logs.
+func newServer(opts Options) *Server {
+	opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	return New(opts)
+}
+
+// do sends a request with body, of JSON unless it is CSV, and the bearer
+// token, if any.
+func do(s *Server, method, path, body, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.RemoteAddr = "192.0.2.1:1234"
+	if strings.HasPrefix(body, "id,") {
+		r.Header.Set("Content-Type", "text/csv")
+	} else if body != "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+// decodeBody decodes the JSON body of w into v, failing t unless w has
+// the status want.
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder, want int, v interface{}) {
+	t.Helper()
+	if w.Code != want {
+		t.Fatalf("status %d, want %d: %s", w.Code, want, w.Body)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+		t.Fatalf("decode %s: %v", w.Body, err)
+	}
+}
+
+// errorOf returns the error message of the JSON error response w.
+func errorOf(w *httptest.ResponseRecorder) string {
+	var resp errorResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return resp.Error
+}
+
+func TestScanAndDefend(t *testing.T) {
+	s := newServer(Options{})
+	defer s.Close()
+
+	var info DatasetInfo
+	decodeBody(t, do(s, http.MethodPost, "/v1/datasets", testCSV(), ""), http.StatusCreated, &info)
+	if info.Samples != 41 || info.Source != "upload" || len(info.Features) != 21 {
+		t.Fatalf("dataset = %+v, want 41 uploaded samples of 21 features", info)
+	}
+	var infos []DatasetInfo
+	decodeBody(t, do(s, http.MethodGet, "/v1/datasets", "", ""), http.StatusOK, &infos)
+	if len(infos) != 1 || infos[0].ID != info.ID {
+		t.Errorf("datasets = %+v, want the upload", infos)
+	}
+
+	var scan Scan
+	decodeBody(t, do(s, http.MethodPost, "/v1/scans", `{"dataset_id": "`+info.ID+`"}`, ""), http.StatusCreated, &scan)
+	if scan.Result.SampleCount != 41 || !flagged(scan.Result, "spike") {
+		t.Fatalf("scan flagged %d of %d samples, want the spike among them", scan.Result.PoisonedCount, scan.Result.SampleCount)
+	}
+	var fetched Scan
+	decodeBody(t, do(s, http.MethodGet, "/v1/scans/"+scan.ID, "", ""), http.StatusOK, &fetched)
+	if fetched.ID != scan.ID || fetched.Result.PoisonedCount != scan.Result.PoisonedCount {
+		t.Errorf("fetched scan %+v, want %+v", fetched, scan)
+	}
+
+	for format, contentType := range map[string]string{
+		"":     "text/plain; charset=utf-8",
+		"html": "text/html; charset=utf-8",
+		"json": "application/json",
+	} {
+		w := do(s, http.MethodGet, "/v1/scans/"+scan.ID+"/report?format="+format, "", "")
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != contentType || w.Body.Len() == 0 {
+			t.Errorf("report %q: status %d, content type %q", format, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+	if w := do(s, http.MethodGet, "/v1/scans/"+scan.ID+"/report?format=pdf", "", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("pdf report: status %d, want 400", w.Code)
+	}
+
+	var defense Defense
+	decodeBody(t, do(s, http.MethodPost, "/v1/defenses", `{"dataset_id": "`+info.ID+`", "strategy": "Data Cleaning"}`, ""), http.StatusCreated, &defense)
+	if defense.Kept+len(defense.Removed) != 41 {
+		t.Errorf("defense kept %d and removed %d of 41 samples", defense.Kept, len(defense.Removed))
+	}
+	w := do(s, http.MethodGet, "/v1/defenses/"+defense.ID+"/dataset", "", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("cleaned dataset: status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if rows := strings.Count(w.Body.String(), "\n"); rows != defense.Kept+1 {
+		t.Errorf("cleaned dataset has %d rows, want a header and %d samples", rows, defense.Kept)
+	}
+}
+
+// flagged reports whether result flags the sample id.
+func flagged(result *detect.DetectionResult, id string) bool {
+	for _, finding := range result.Samples {
+		if finding.ID == id {
+			return finding.IsPoisoned
+		}
+	}
+	return false
+}
+
+func TestErrors(t *testing.T) {
+	s := newServer(Options{})
+	defer s.Close()
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+		error              string
+	}{
+		{http.MethodGet, "/v2/datasets", "", http.StatusNotFound, "no such endpoint"},
+		{http.MethodGet, "/v1/widgets", "", http.StatusNotFound, "no such endpoint"},
+		{http.MethodGet, "/v1/projects/nope/datasets", "", http.StatusNotFound, `no project "nope"`},
+		{http.MethodGet, "/v1/datasets/nope", "", http.StatusNotFound, `no dataset "nope"`},
+		{http.MethodGet, "/v1/scans/nope", "", http.StatusNotFound, "nope"},
+		{http.MethodGet, "/v1/jobs/nope", "", http.StatusNotFound, `no job "nope"`},
+		{http.MethodPost, "/v1/scans", "", http.StatusBadRequest, "request body required"},
+		{http.MethodPost, "/v1/scans", `{"dataset_id": `, http.StatusBadRequest, "decode request"},
+		{http.MethodPost, "/v1/scans", `{"dataset": "x"}`, http.StatusBadRequest, "unknown field"},
+		{http.MethodPost, "/v1/scans", `{"dataset_id": "nope"}`, http.StatusNotFound, `no dataset "nope"`},
+		{http.MethodPost, "/v1/datasets", `{}`, http.StatusBadRequest, "uri required"},
+		{http.MethodPost, "/v1/datasets", `{"uri": "/etc/passwd"}`, http.StatusBadRequest, "file URIs are disabled"},
+		{http.MethodPost, "/v1/datasets", `{"uri": "ftp://example.com/x.csv"}`, http.StatusBadRequest, "unsupported URI scheme"},
+		{http.MethodPost, "/v1/datasets", `{"uri": "https://example.com/x.csv"}`, http.StatusBadRequest, "remote URIs are disabled"},
+		{http.MethodPost, "/v1/defenses", `{"dataset_id": "nope", "strategy": "Data Cleaning"}`, http.StatusNotFound, `no dataset "nope"`},
+	} {
+		w := do(s, tc.method, tc.path, tc.body, "")
+		if w.Code != tc.status || !strings.Contains(errorOf(w), tc.error) {
+			t.Errorf("%s %s %s: status %d, error %q; want %d and %q", tc.method, tc.path, tc.body, w.Code, errorOf(w), tc.status, tc.error)
+		}
+	}
+
+	w := do(s, http.MethodDelete, "/v1/datasets", "", "")
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, POST" {
+		t.Errorf("DELETE /v1/datasets: status %d, Allow %q; want 405 and GET, POST", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestAuthScopes(t *testing.T) {
+	s := newServer(Options{Auth: auth.New(auth.Config{Tokens: []auth.Token{
+		{Name: "dashboard", Token: "view", Scopes: []auth.Scope{auth.ScopeRead}},
+		{Name: "ci", Token: "scan", Scopes: []auth.Scope{auth.ScopeRead, auth.ScopeScan}},
+		{Name: "ops", Token: "debug", Scopes: []auth.Scope{auth.ScopeRead, auth.ScopeDebug}},
+	}})})
+	defer s.Close()
+
+	w := do(s, http.MethodGet, "/v1/datasets", "", "")
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("without a token: status %d, WWW-Authenticate %q; want 401 and a challenge", w.Code, w.Header().Get("WWW-Authenticate"))
+	}
+	if w := do(s, http.MethodGet, "/v1/datasets", "", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("with a wrong token: status %d, want 401", w.Code)
+	}
+	if w := do(s, http.MethodGet, "/healthz", "", ""); w.Code != http.StatusOK {
+		t.Errorf("/healthz without a token: status %d, want 200", w.Code)
+	}
+
+	for _, tc := range []struct {
+		token, method, path, body string
+		status                    int
+	}{
+		{"view", http.MethodGet, "/v1/datasets", "", http.StatusOK},
+		{"view", http.MethodPost, "/v1/datasets", testCSV(), http.StatusForbidden},
+		{"scan", http.MethodPost, "/v1/datasets", testCSV(), http.StatusCreated},
+		{"scan", http.MethodPost, "/v1/defenses", `{"dataset_id": "x", "strategy": "Data Cleaning"}`, http.StatusForbidden},
+		{"scan", http.MethodDelete, "/v1/jobs/x", "", http.StatusNotFound},
+		{"view", http.MethodDelete, "/v1/jobs/x", "", http.StatusForbidden},
+		// Profiles are only served by --pprof-addr, never on the API.
+		{"debug", http.MethodGet, "/debug/pprof/", "", http.StatusNotFound},
+	} {
+		if w := do(s, tc.method, tc.path, tc.body, tc.token); w.Code != tc.status {
+			t.Errorf("%s %s with %s: status %d, want %d: %s", tc.method, tc.path, tc.token, w.Code, tc.status, errorOf(w))
+		}
+	}
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	s := newServer(Options{MaxBodyBytes: 256, RemoteURIs: true, AllowedHosts: []string{"127.0.0.1"}})
+	defer s.Close()
+	if w := do(s, http.MethodPost, "/v1/datasets", testCSV(), ""); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large upload: status %d, want 413: %s", w.Code, errorOf(w))
+	}
+	if w := do(s, http.MethodPost, "/v1/scans", `{"dataset_id": "`+strings.Repeat("x", 300)+`"}`, ""); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large request: status %d, want 413: %s", w.Code, errorOf(w))
+	}
+	if w := do(s, http.MethodPost, "/v1/datasets", "id,f0,label\na,1,0\nb,2,1\n", ""); w.Code != http.StatusCreated {
+		t.Errorf("small upload: status %d, want 201: %s", w.Code, errorOf(w))
+	}
+
+	// Datasets fetched by URI are limited alike.
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, testCSV())
+	}))
+	defer origin.Close()
+	if w := do(s, http.MethodPost, "/v1/datasets", `{"uri": "`+origin.URL+`/train.csv"}`, ""); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large fetched dataset: status %d, want 413: %s", w.Code, errorOf(w))
+	}
+}
+
+func TestJobs(t *testing.T) {
+	s := newServer(Options{})
+	defer s.Close()
+	var info DatasetInfo
+	decodeBody(t, do(s, http.MethodPost, "/v1/datasets", testCSV(), ""), http.StatusCreated, &info)
+
+	w := do(s, http.MethodPost, "/v1/scans", `{"dataset_id": "`+info.ID+`", "async": true}`, "")
+	var job jobs.Job
+	decodeBody(t, w, http.StatusAccepted, &job)
+	if want := "/v1/jobs/" + job.ID; w.Header().Get("Location") != want {
+		t.Errorf("Location = %q, want %q", w.Header().Get("Location"), want)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for job.State != jobs.StateSucceeded {
+		if job.State == jobs.StateFailed || time.Now().After(deadline) {
+			t.Fatalf("job %s: %s", job.State, job.Error)
+		}
+		time.Sleep(10 * time.Millisecond)
+		decodeBody(t, do(s, http.MethodGet, "/v1/jobs/"+job.ID, "", ""), http.StatusOK, &job)
+	}
+
+	var result detect.DetectionResult
+	decodeBody(t, do(s, http.MethodGet, "/v1/jobs/"+job.ID+"/result", "", ""), http.StatusOK, &result)
+	if result.SampleCount != 41 || !flagged(&result, "spike") {
+		t.Errorf("job result flagged %d of %d samples, want the spike among them", result.PoisonedCount, result.SampleCount)
+	}
+	var progress JobProgress
+	decodeBody(t, do(s, http.MethodGet, "/v1/jobs/"+job.ID+"/progress", "", ""), http.StatusOK, &progress)
+	if progress.Processed != 41 || progress.Total != 41 || len(progress.Findings) != result.PoisonedCount {
+		t.Errorf("progress = %+v, want all 41 samples and %d findings", progress, result.PoisonedCount)
+	}
+
+	w = do(s, http.MethodGet, "/v1/jobs/"+job.ID+"/findings", "", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("findings: status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	lines := 0
+	for scanner := bufio.NewScanner(w.Body); scanner.Scan(); lines++ {
+		var v map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &v); err != nil {
+			t.Fatalf("findings line %d: %v", lines+1, err)
+		}
+	}
+	if lines < result.PoisonedCount {
+		t.Errorf("%d findings lines, want at least %d", lines, result.PoisonedCount)
+	}
+	if w := do(s, http.MethodGet, "/v1/jobs/"+job.ID+"/findings?offset=x", "", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("findings at an invalid offset: status %d, want 400", w.Code)
+	}
+
+	// The job's scan is served like synchronous scans.
+	var scans []Scan
+	decodeBody(t, do(s, http.MethodGet, "/v1/scans", "", ""), http.StatusOK, &scans)
+	if len(scans) != 1 || scans[0].DatasetID != info.ID {
+		t.Errorf("scans = %+v, want the job's scan", scans)
+	}
+	if w := do(s, http.MethodDelete, "/v1/jobs/"+job.ID, "", ""); w.Code != http.StatusConflict {
+		t.Errorf("canceling a finished job: status %d, want 409", w.Code)
+	}
+}
+
+func TestProbes(t *testing.T) {
+	s := newServer(Options{})
+	defer s.Close()
+	for _, path := range []string{"/healthz", "/readyz"} {
+		if w := do(s, http.MethodGet, path, "", ""); w.Code != http.StatusOK {
+			t.Errorf("GET %s: status %d, want 200", path, w.Code)
+		}
+	}
+	if w := do(s, http.MethodPost, "/healthz", "", ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /healthz: status %d, want 405", w.Code)
+	}
+
+	s.Drain()
+	var body probeResponse
+	decodeBody(t, do(s, http.MethodGet, "/readyz", "", ""), http.StatusServiceUnavailable, &body)
+	if body.Status != "draining" {
+		t.Errorf("/readyz while draining = %+v", body)
+	}
+	if w := do(s, http.MethodGet, "/healthz", "", ""); w.Code != http.StatusOK {
+		t.Errorf("/healthz while draining: status %d, want 200", w.Code)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	s := newServer(Options{})
+	defer s.Close()
+	var created DatasetInfo
+	decodeBody(t, do(s, http.MethodPost, "/v1/datasets", testCSV(), ""), http.StatusCreated, &created)
+
+	if w := do(s, http.MethodGet, "/readyz", "", ""); w.Code != http.StatusOK {
+		t.Fatalf("/readyz before draining: status %d, want 200", w.Code)
+	}
+	s.Drain()
+	if w := do(s, http.MethodGet, "/readyz", "", ""); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz while draining: status %d, want 503", w.Code)
+	}
+
+	// Requests still routed here during --drain-delay are served.
+	if w := do(s, http.MethodGet, "/v1/datasets/"+created.ID, "", ""); w.Code != http.StatusOK {
+		t.Errorf("GET dataset while draining: status %d, want 200: %s", w.Code, errorOf(w))
+	}
+	if w := do(s, http.MethodPost, "/v1/scans", `{"dataset_id": "`+created.ID+`"}`, ""); w.Code != http.StatusCreated {
+		t.Errorf("scan while draining: status %d, want 201: %s", w.Code, errorOf(w))
+	}
+
+	// Draining is one-way.
+	s.Drain()
+	if w := do(s, http.MethodGet, "/readyz", "", ""); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz after draining twice: status %d, want 503", w.Code)
+	}
+}
@@ -0,0 +1,366 @@
+// Package siem ships scan findings as events to a Splunk HTTP Event
+// Collector or the Elasticsearch bulk API, so data poisoning findings can be
+// correlated with other security telemetry.
+package siem
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/webhook"
+)
+
+// Sink types.
+const (
+	TypeSplunk  = "splunk"
+	TypeElastic = "elastic"
+)
+
+// SchemaVersion is the version of the event schema, set as the
+// schema_version field of every event.
+const SchemaVersion = "1"
+
+// Event kinds.
+const (
+	// KindScan is the summary event sent once per scan.
+	KindScan = "scan"
+	// KindFinding is sent for every flagged sample.
+	KindFinding = "finding"
+)
+
+// DefaultIndex is the Elasticsearch index of sinks that do not set one.
+const DefaultIndex = "modelpoison-findings"
+
+// DefaultSourcetype is the Splunk sourcetype of sinks that do not set one.
+const DefaultSourcetype = "modelpoison:event"
+
+// batchSize is the maximum number of events sent per request.
+const batchSize = 500
+
+// Sink is a configured SIEM endpoint. Credentials of the form $NAME or
+// ${NAME} are read from the environment.
+type Sink struct {
+	// Type is splunk or elastic.
+	Type string `yaml:"type"`
+	// URL is the base URL of the Splunk HEC endpoint (such as
+	// https://splunk:8088) or of the Elasticsearch cluster.
+	URL string `yaml:"url"`
+	// Token is the Splunk HEC token.
+	Token string `yaml:"token,omitempty"`
+	// APIKey is the Elasticsearch API key (the base64 encoded id:key).
+	APIKey string `yaml:"api_key,omitempty"`
+	// Username and Password authenticate with Elasticsearch using basic
+	// auth when no API key is set.
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	// Index is the Splunk index (default the token's default index) or the
+	// Elasticsearch index or data stream (default modelpoison-findings).
+	Index string `yaml:"index,omitempty"`
+	// Sourcetype is the Splunk sourcetype (default modelpoison:event).
+	Sourcetype string `yaml:"sourcetype,omitempty"`
+}
+
+// Check reports errors in the sink configuration.
+func (s Sink) Check() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid SIEM URL %q", s.URL)
+	}
+	switch s.Type {
+	case TypeSplunk:
+		if s.Token == "" {
+			return fmt.Errorf("%s: splunk sinks need a token", s.URL)
+		}
+	case TypeElastic:
+		if s.APIKey != "" && s.Username != "" {
+			return fmt.Errorf("%s: set api_key or username, not both", s.URL)
+		}
+		if s.Token != "" {
+			return fmt.Errorf("%s: elastic sinks take api_key or username, not token", s.URL)
+		}
+	default:
+		return fmt.Errorf("%s: unknown sink type %q (want %s or %s)", s.URL, s.Type, TypeSplunk, TypeElastic)
+	}
+	return nil
+}
+
+// Event is a SIEM event. Scan events carry the verdict and counts of a
+// scan; finding events carry one flagged sample and repeat the scan fields
+// so each event can be correlated on its own.
+type Event struct {
+	Timestamp     time.Time `json:"@timestamp"`
+	SchemaVersion string    `json:"schema_version"`
+	Kind          string    `json:"kind"`
+	ScanID        string    `json:"scan_id,omitempty"`
+	Project       string    `json:"project,omitempty"`
+	Dataset       string    `json:"dataset"`
+	Host          string    `json:"host,omitempty"`
+	IsPoisoned    bool      `json:"is_poisoned"`
+	RiskScore     float64   `json:"risk_score"`
+	SampleCount   int       `json:"sample_count"`
+	PoisonedCount int       `json:"poisoned_count"`
+	Partial       bool      `json:"partial,omitempty"`
+	ResultURL     string    `json:"result_url,omitempty"`
+	ReportURL     string    `json:"report_url,omitempty"`
+
+	// Finding events only.
+	SampleID    string   `json:"sample_id,omitempty"`
+	Label       *int     `json:"label,omitempty"`
+	PoisonType  string   `json:"poison_type,omitempty"`
+	ATLAS       []string `json:"atlas_techniques,omitempty"`
+	Confidence  float64  `json:"confidence,omitempty"`
+	Score       float64  `json:"score,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Evidence    string   `json:"evidence,omitempty"`
+	Review      string   `json:"review,omitempty"`
+}
+
+// Events returns the events of a scan completed at now: a scan event
+// followed by a finding event for every flagged sample.
+func Events(scan webhook.Scan, now time.Time) []Event {
+	host, _ := os.Hostname()
+	result := scan.Result
+	base := Event{
+		Timestamp:     now.UTC(),
+		SchemaVersion: SchemaVersion,
+		Kind:          KindScan,
+		ScanID:        scan.ID,
+		Project:       scan.Project,
+		Dataset:       scan.Dataset,
+		Host:          host,
+		IsPoisoned:    result.IsPoisoned,
+		RiskScore:     result.RiskScore,
+		SampleCount:   result.SampleCount,
+		PoisonedCount: result.PoisonedCount,
+		Partial:       result.Partial != nil,
+		ResultURL:     scan.ResultURL,
+		ReportURL:     scan.ReportURL,
+	}
+
+	events := []Event{base}
+	for _, sample := range result.Samples {
+		if !sample.IsPoisoned {
+			continue
+		}
+		label := sample.Label
+		event := base
+		event.Kind = KindFinding
+		event.SampleID = sample.ID
+		event.Label = &label
+		event.PoisonType = string(sample.Type)
+		event.ATLAS = sample.ATLAS
+		event.Confidence = sample.Confidence
+		event.Score = sample.Score
+		event.Description = sample.Description
+		event.Evidence = sample.Evidence
+		event.Review = sample.Review
+		events = append(events, event)
+	}
+	return events
+}
+
+// Exporter ships scan events to SIEM sinks.
+type Exporter struct {
+	sinks  []Sink
+	client *http.Client
+}
+
+// New creates an exporter. A nil client uses a client with a 30s timeout.
+func New(sinks []Sink, client *http.Client) *Exporter {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return &Exporter{sinks: sinks, client: client}
+}
+
+// Export sends the events of scan to every sink. It returns the delivery
+// errors, joined.
+func (e *Exporter) Export(ctx context.Context, scan webhook.Scan) error {
+	if e == nil || len(e.sinks) == 0 {
+		return nil
+	}
+
+	events := Events(scan, time.Now())
+	var errs []error
+	for _, sink := range e.sinks {
+		for start := 0; start < len(events); start += batchSize {
+			end := start + batchSize
+			if end > len(events) {
+				end = len(events)
+			}
+			var err error
+			switch sink.Type {
+			case TypeSplunk:
+				err = e.splunk(ctx, sink, events[start:end])
+			case TypeElastic:
+				err = e.elastic(ctx, sink, events[start:end])
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("siem %s %s: %w", sink.Type, sink.URL, err))
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// hecEvent is the envelope of a Splunk HEC event.
+type hecEvent struct {
+	Time       float64 `json:"time"`
+	Host       string  `json:"host,omitempty"`
+	Source     string  `json:"source"`
+	Sourcetype string  `json:"sourcetype"`
+	Index      string  `json:"index,omitempty"`
+	Event      Event   `json:"event"`
+}
+
+// splunk sends events to a Splunk HTTP Event Collector, batched as
+// concatenated JSON objects in one request.
+func (e *Exporter) splunk(ctx context.Context, sink Sink, events []Event) error {
+	sourcetype := sink.Sourcetype
+	if sourcetype == "" {
+		sourcetype = DefaultSourcetype
+	}
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, event := range events {
+		err := enc.Encode(hecEvent{
+			Time:       float64(event.Timestamp.UnixMilli()) / 1000,
+			Host:       event.Host,
+			Source:     "modelpoison",
+			Sourcetype: sourcetype,
+			Index:      sink.Index,
+			Event:      event,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	target := strings.TrimSuffix(sink.URL, "/")
+	if u, err := url.Parse(target); err == nil && u.Path == "" {
+		target += "/services/collector/event"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Splunk "+expand(sink.Token))
+	return e.send(req, nil)
+}
+
+// elastic indexes events with the Elasticsearch bulk API. Documents are
+// created, as data streams require.
+func (e *Exporter) elastic(ctx context.Context, sink Sink, events []Event) error {
+	index := sink.Index
+	if index == "" {
+		index = DefaultIndex
+	}
+	action, err := json.Marshal(map[string]any{"create": map[string]string{"_index": index}})
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, event := range events {
+		body.Write(action)
+		body.WriteByte('\n')
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(sink.URL, "/")+"/_bulk", &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	switch {
+	case sink.APIKey != "":
+		req.Header.Set("Authorization", "ApiKey "+expand(sink.APIKey))
+	case sink.Username != "":
+		req.SetBasicAuth(expand(sink.Username), expand(sink.Password))
+	}
+	return e.send(req, bulkErrors)
+}
+
+// send sends req and checks the response. check, if set, inspects the body
+// of a successful response.
+func (e *Exporter) send(req *http.Request, check func([]byte) error) error {
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg := strings.TrimSpace(buf.String())
+		if len(msg) > 512 {
+			msg = msg[:512]
+		}
+		if msg == "" {
+			return fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, msg)
+	}
+	if check != nil {
+		return check(buf.Bytes())
+	}
+	return nil
+}
+
+// bulkErrors returns an error for a bulk response reporting failed items.
+// The bulk API answers 200 even when some documents are rejected.
+func bulkErrors(body []byte) error {
+	var resp struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("invalid bulk response: %w", err)
+	}
+	if !resp.Errors {
+		return nil
+	}
+	failed := 0
+	var first string
+	for _, item := range resp.Items {
+		for _, result := range item {
+			if result.Status >= 200 && result.Status <= 299 {
+				continue
+			}
+			if failed == 0 {
+				first = result.Error.Type + ": " + result.Error.Reason
+			}
+			failed++
+		}
+	}
+	return fmt.Errorf("%d of %d events rejected (%s)", failed, len(resp.Items), first)
+}
+
+// expand returns value, expanding environment references.
+func expand(value string) string {
+	if strings.HasPrefix(value, "$") {
+		return os.ExpandEnv(value)
+	}
+	return value
+}
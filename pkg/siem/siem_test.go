@@ -0,0 +1,117 @@
+package siem
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/webhook"
+)
+
+var testScan = webhook.Scan{
+	ID:      "scan-1",
+	Dataset: "train.csv",
+	Result: &detect.DetectionResult{IsPoisoned: true, SampleCount: 3, PoisonedCount: 2, RiskScore: 0.67, Samples: []detect.PoisonedSample{
+		{ID: "s1", IsPoisoned: true, Type: detect.TypeBackdoor, Confidence: 0.9},
+		{ID: "s2"},
+		{ID: "s3", IsPoisoned: true, Type: detect.TypeLabelFlip, Confidence: 0.7, Label: 1},
+	}},
+}
+
+func TestExportSplunk(t *testing.T) {
+	var auth, path string
+	var events []hecEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, path = r.Header.Get("Authorization"), r.URL.Path
+		dec := json.NewDecoder(r.Body)
+		for dec.More() {
+			var event hecEvent
+			if err := dec.Decode(&event); err != nil {
+				t.Error(err)
+			}
+			events = append(events, event)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("HEC_TOKEN", "secret")
+
+	sink := Sink{Type: TypeSplunk, URL: srv.URL, Token: "$HEC_TOKEN", Index: "ml"}
+	if err := sink.Check(); err != nil {
+		t.Fatal(err)
+	}
+	if err := New([]Sink{sink}, nil).Export(context.Background(), testScan); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Splunk secret" || path != "/services/collector/event" {
+		t.Errorf("request to %s with %q", path, auth)
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want a scan and 2 findings", len(events))
+	}
+	if e := events[0]; e.Index != "ml" || e.Sourcetype != DefaultSourcetype || e.Event.Kind != KindScan || e.Event.RiskScore != 0.67 {
+		t.Errorf("scan event = %+v", e)
+	}
+	if e := events[2].Event; e.Kind != KindFinding || e.SampleID != "s3" || e.PoisonType != string(detect.TypeLabelFlip) ||
+		e.Label == nil || *e.Label != 1 || e.ScanID != "scan-1" {
+		t.Errorf("finding event = %+v", e)
+	}
+}
+
+func TestExportElastic(t *testing.T) {
+	var lines []string
+	reject := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" || r.Header.Get("Authorization") != "ApiKey key" {
+			t.Errorf("request to %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		if reject {
+			w.Write([]byte(`{"errors":true,"items":[{"create":{"status":201}},{"create":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"bad field"}}}]}`))
+			return
+		}
+		w.Write([]byte(`{"errors":false,"items":[]}`))
+	}))
+	defer srv.Close()
+
+	exporter := New([]Sink{{Type: TypeElastic, URL: srv.URL, APIKey: "key"}}, nil)
+	if err := exporter.Export(context.Background(), testScan); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 6 || lines[0] != `{"create":{"_index":"modelpoison-findings"}}` {
+		t.Fatalf("bulk body = %q, want 3 create actions and documents", lines)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(lines[3]), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["kind"] != KindFinding || doc["schema_version"] != SchemaVersion || doc["@timestamp"] == nil {
+		t.Errorf("finding document = %v", doc)
+	}
+
+	reject = true
+	err := exporter.Export(context.Background(), testScan)
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 events rejected") {
+		t.Errorf("Export() error = %v, want rejected events", err)
+	}
+}
+
+func TestCheckRejectsBadSinks(t *testing.T) {
+	for _, sink := range []Sink{
+		{Type: TypeSplunk, URL: "splunk:8088", Token: "t"},
+		{Type: TypeSplunk, URL: "https://splunk:8088"},
+		{Type: TypeElastic, URL: "https://es:9200", APIKey: "k", Username: "u"},
+		{Type: "kafka", URL: "https://broker"},
+	} {
+		if err := sink.Check(); err == nil {
+			t.Errorf("Check(%+v) = nil, want error", sink)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package stats
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+)
+
+// cacheVersion changes when profiles are computed differently, so stale
+// cached profiles are not used.
+const cacheVersion = "3"
+
+// Cache stores dataset profiles in a directory keyed by dataset content, so
+// an unchanged dataset is profiled only once.
+type Cache struct {
+	Dir string
+}
+
+// DefaultCacheDir returns the default profile cache directory, under the
+// user's cache directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "modelpoison", "profiles"), nil
+}
+
+// CacheKey returns the key of the profile of the dataset at path read with
+// cols: a hash of the dataset content, every field of cols and whether the
+// profile is approximate. The encoder of cols is hashed as its type and
+// JSON encoding, so it must encode the vocabularies it was fitted to.
+func CacheKey(path string, cols dataset.Columns, approximate bool) (string, error) {
+	var encoder []byte
+	if cols.Encoder != nil {
+		var err error
+		if encoder, err = json.Marshal(cols.Encoder); err != nil {
+			return "", fmt.Errorf("profile cache key: %w", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	fmt.Fprintf(h, "modelpoison profile %s\n%q %q %q %q %q %t\n", cacheVersion, cols.Features, cols.Label, cols.ID, cols.Source, cols.Ignore, approximate)
+	fmt.Fprintf(h, "%q %T %s %t %q\n", cols.Categorical, cols.Encoder, encoder, cols.Float32, cols.FeaturesField)
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Get returns the cached profile with key, if any.
+func (c Cache) Get(key string) (*Profile, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var profile Profile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, false
+	}
+	return &profile, true
+}
+
+// Put stores profile under key.
+func (c Cache) Put(key string, profile *Profile) error {
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+	// Write and rename so concurrent readers never see a partial file.
+	tmp, err := os.CreateTemp(c.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("cache profile: %w", err)
+	}
+	return nil
+}
+
+func (c Cache) path(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
@@ -0,0 +1,122 @@
+package stats
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/dataset"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// testEncoder encodes each categorical column as its value's length.
+type testEncoder struct {
+	Scale int
+}
+
+func (e testEncoder) Features(column string) []string { return []string{column + "_len"} }
+
+func (e testEncoder) Encode(column, value string, dst []float64) []float64 {
+	return append(dst, float64(e.Scale*len(value)))
+}
+
+func TestCacheKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	key := func(cols dataset.Columns, approximate bool) string {
+		t.Helper()
+		key, err := CacheKey(path, cols, approximate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	write("id,a,b,c,label\nx,1,2,red,0\n")
+
+	base := key(dataset.Columns{}, false)
+	if again := key(dataset.Columns{}, false); again != base {
+		t.Errorf("keys of one dataset differ: %s and %s", base, again)
+	}
+	if approximate := key(dataset.Columns{}, true); approximate == base {
+		t.Error("exact and approximate profiles share a key")
+	}
+
+	// Every column setting that changes the profile changes the key.
+	encoded := dataset.Columns{Categorical: []string{"c"}, Encoder: testEncoder{Scale: 1}}
+	keys := map[string]string{"default": base}
+	for _, test := range []struct {
+		name string
+		cols dataset.Columns
+	}{
+		{"features", dataset.Columns{Features: []string{"a"}}},
+		{"label", dataset.Columns{Label: "b"}},
+		{"id", dataset.Columns{ID: "a"}},
+		{"source", dataset.Columns{Source: "c"}},
+		{"ignore", dataset.Columns{Ignore: []string{"c"}}},
+		{"categorical", encoded},
+		{"encoder", dataset.Columns{Categorical: []string{"c"}, Encoder: testEncoder{Scale: 2}}},
+		{"float32", dataset.Columns{Float32: true}},
+		{"features field", dataset.Columns{FeaturesField: "vector"}},
+	} {
+		k := key(test.cols, false)
+		for name, other := range keys {
+			if k == other {
+				t.Errorf("%s and %s columns share a key", test.name, name)
+			}
+		}
+		keys[test.name] = k
+	}
+
+	// Changing the content changes the key.
+	write("id,a,b,c,label\nx,1,3,red,0\n")
+	if changed := key(dataset.Columns{}, false); changed == base {
+		t.Error("the key did not change with the dataset content")
+	}
+	if changed := key(encoded, false); changed == keys["categorical"] {
+		t.Error("the key of encoded columns did not change with the dataset content")
+	}
+}
+
+func TestCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte("a,label\n1,0\n2,1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cache := Cache{Dir: filepath.Join(t.TempDir(), "profiles")}
+	exact, err := CacheKey(path, dataset.Columns{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	approximate, err := CacheKey(path, dataset.Columns{}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := cache.Get(exact); ok {
+		t.Fatal("hit in an empty cache")
+	}
+	profile := ProfileSamples([]string{"a"}, []detect.Sample{
+		detect.NewSample("row-1", 0, []float64{1}),
+		detect.NewSample("row-2", 1, []float64{2}),
+	})
+	if err := cache.Put(exact, profile); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := cache.Get(exact)
+	if !ok {
+		t.Fatal("miss after Put")
+	}
+	if !reflect.DeepEqual(got.Features, profile.Features) || got.SampleCount != profile.SampleCount {
+		t.Errorf("cached profile %+v, want %+v", got, profile)
+	}
+	// A profile of the other mode is not reused.
+	if _, ok := cache.Get(approximate); ok {
+		t.Error("the exact profile was returned for an approximate one")
+	}
+}
@@ -0,0 +1,192 @@
+package stats
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// DefaultSpillRows is the number of row hashes an approximate profile keeps
+// in memory, about 96MB, before spilling them to disk.
+const DefaultSpillRows = 4 << 20
+
+// SpillRowSize is the size in bytes of a row hash in memory and on disk.
+const SpillRowSize = 24
+
+// rowKey identifies a profiled row for duplicate detection: the hash of its
+// features, its position and its label.
+type rowKey struct {
+	hash, seq uint64
+	label     int64
+}
+
+func (k rowKey) less(o rowKey) bool {
+	return k.hash < o.hash || k.hash == o.hash && k.seq < o.seq
+}
+
+// dedup counts duplicate and conflicting rows in bounded memory by external
+// sorting. Row keys are buffered, and each full buffer is sorted by hash and
+// position and spilled to a run file; counting merges the runs, so the rows
+// of each hash are visited in dataset order and compared with the first.
+type dedup struct {
+	dir   string
+	limit int
+	buf   []rowKey
+	runs  []string
+	seq   uint64
+	err   error
+}
+
+// add records a row, spilling the buffer when it is full. The first spill
+// error is kept and reported by count.
+func (d *dedup) add(hash uint64, label int) {
+	d.buf = append(d.buf, rowKey{hash: hash, seq: d.seq, label: int64(label)})
+	d.seq++
+	if len(d.buf) >= d.limit && d.err == nil {
+		d.err = d.spill()
+	}
+}
+
+// spill writes the sorted buffer to a new run file.
+func (d *dedup) spill() error {
+	sortKeys(d.buf)
+	f, err := os.CreateTemp(d.dir, "modelpoison-dedup-*.run")
+	if err != nil {
+		return fmt.Errorf("spill duplicate detection: %w", err)
+	}
+	d.runs = append(d.runs, f.Name())
+
+	w := bufio.NewWriterSize(f, 1<<20)
+	var rec [SpillRowSize]byte
+	for _, k := range d.buf {
+		binary.LittleEndian.PutUint64(rec[0:], k.hash)
+		binary.LittleEndian.PutUint64(rec[8:], k.seq)
+		binary.LittleEndian.PutUint64(rec[16:], uint64(k.label))
+		w.Write(rec[:])
+	}
+	err = w.Flush()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("spill duplicate detection: %w", err)
+	}
+	d.buf = d.buf[:0]
+	return nil
+}
+
+// count returns the number of rows repeating the features and label of an
+// earlier row, and of rows repeating earlier features with another label.
+func (d *dedup) count() (duplicates, conflicts int, err error) {
+	if d.err != nil {
+		return 0, 0, d.err
+	}
+	sortKeys(d.buf)
+	sources := &keyHeap{}
+	if len(d.buf) > 0 {
+		sources.items = append(sources.items, &keySource{key: d.buf[0], keys: d.buf[1:]})
+	}
+	for _, path := range d.runs {
+		f, err := os.Open(path)
+		if err != nil {
+			return 0, 0, err
+		}
+		defer f.Close()
+		src := &keySource{r: bufio.NewReaderSize(f, 256<<10)}
+		if ok, err := src.next(); err != nil {
+			return 0, 0, fmt.Errorf("%s: %w", path, err)
+		} else if ok {
+			sources.items = append(sources.items, src)
+		}
+	}
+	heap.Init(sources)
+
+	first := rowKey{}
+	started := false
+	for sources.Len() > 0 {
+		src := sources.items[0]
+		k := src.key
+		if !started || k.hash != first.hash {
+			first, started = k, true
+		} else if k.label == first.label {
+			duplicates++
+		} else {
+			conflicts++
+		}
+		if ok, err := src.next(); err != nil {
+			return 0, 0, err
+		} else if ok {
+			heap.Fix(sources, 0)
+		} else {
+			heap.Pop(sources)
+		}
+	}
+	return duplicates, conflicts, nil
+}
+
+// close removes the run files.
+func (d *dedup) close() error {
+	var errs []error
+	for _, path := range d.runs {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	d.runs = nil
+	return errors.Join(errs...)
+}
+
+func sortKeys(keys []rowKey) {
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+}
+
+// keySource yields sorted row keys from the buffer or a run file; key is
+// the current key.
+type keySource struct {
+	key  rowKey
+	keys []rowKey
+	r    *bufio.Reader
+}
+
+// next advances to the next key, reporting false at the end.
+func (s *keySource) next() (bool, error) {
+	if s.r == nil {
+		if len(s.keys) == 0 {
+			return false, nil
+		}
+		s.key, s.keys = s.keys[0], s.keys[1:]
+		return true, nil
+	}
+	var rec [SpillRowSize]byte
+	if _, err := io.ReadFull(s.r, rec[:]); err == io.EOF {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	s.key = rowKey{
+		hash:  binary.LittleEndian.Uint64(rec[0:]),
+		seq:   binary.LittleEndian.Uint64(rec[8:]),
+		label: int64(binary.LittleEndian.Uint64(rec[16:])),
+	}
+	return true, nil
+}
+
+// keyHeap orders sources by their current key for the k-way merge.
+type keyHeap struct {
+	items []*keySource
+}
+
+func (h *keyHeap) Len() int           { return len(h.items) }
+func (h *keyHeap) Less(i, j int) bool { return h.items[i].key.less(h.items[j].key) }
+func (h *keyHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *keyHeap) Push(x any)         { h.items = append(h.items, x.(*keySource)) }
+func (h *keyHeap) Pop() any {
+	x := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return x
+}
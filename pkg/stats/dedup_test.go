@@ -0,0 +1,44 @@
+package stats
+
+import (
+	"os"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func TestSpilledDuplicates(t *testing.T) {
+	var samples []detect.Sample
+	for i := 0; i < 5000; i++ {
+		// Rows repeat every 700 samples, with the label flipping on some.
+		features := []float64{float64(i % 700), float64(i % 7)}
+		samples = append(samples, detect.Sample{Features: features, Label: i % 3 / 2})
+	}
+	exact := ProfileSamples(nil, samples)
+	if exact.Duplicates == 0 || exact.Conflicts == 0 {
+		t.Fatalf("test data has %d duplicates and %d conflicts", exact.Duplicates, exact.Conflicts)
+	}
+
+	dir := t.TempDir()
+	p := NewProfiler(nil, true)
+	p.SetSpill(dir, 256)
+	for _, sample := range samples {
+		p.Add(sample)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) == 0 {
+		t.Errorf("no row hashes spilled to %s", dir)
+	}
+	got := p.Profile()
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if got.Duplicates != exact.Duplicates || got.Conflicts != exact.Conflicts {
+		t.Errorf("spilled: %d duplicates, %d conflicts; want %d, %d", got.Duplicates, got.Conflicts, exact.Duplicates, exact.Conflicts)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("%d spill files left after Close", len(entries))
+	}
+}
@@ -0,0 +1,155 @@
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// DefaultCompression is the compression of digests built by approximate
+// profiles. Quantile estimates are typically within 0.5% in rank, and
+// closer at the tails.
+const DefaultCompression = 200
+
+// Digest is a t-digest (Dunning and Ertl): a sketch of a distribution in a
+// bounded number of weighted centroids, built in one pass. Centroids are
+// smaller near the tails, so extreme quantiles stay accurate.
+type Digest struct {
+	compression float64
+	centroids   []centroid
+	buffer      []centroid
+	count       float64
+	min, max    float64
+}
+
+type centroid struct {
+	mean, weight float64
+}
+
+// NewDigest returns an empty digest. Higher compression keeps more
+// centroids and gives more accurate quantiles.
+func NewDigest(compression float64) *Digest {
+	if compression < 10 {
+		compression = 10
+	}
+	return &Digest{
+		compression: compression,
+		min:         math.Inf(1),
+		max:         math.Inf(-1),
+	}
+}
+
+// Add adds a value to the digest. NaN values are ignored.
+func (d *Digest) Add(x float64) {
+	d.add(x, 1)
+}
+
+func (d *Digest) add(x, weight float64) {
+	if math.IsNaN(x) || weight <= 0 {
+		return
+	}
+	d.buffer = append(d.buffer, centroid{mean: x, weight: weight})
+	d.count += weight
+	d.min = math.Min(d.min, x)
+	d.max = math.Max(d.max, x)
+	if len(d.buffer) >= int(5*d.compression) {
+		d.compress()
+	}
+}
+
+// Count returns the number of values added.
+func (d *Digest) Count() int {
+	return int(d.count)
+}
+
+// Quantile returns an estimate of the q-quantile of the added values, or
+// NaN when the digest is empty.
+func (d *Digest) Quantile(q float64) float64 {
+	d.compress()
+	if len(d.centroids) == 0 {
+		return math.NaN()
+	}
+	if q <= 0 {
+		return d.min
+	}
+	if q >= 1 {
+		return d.max
+	}
+	if len(d.centroids) == 1 {
+		return d.centroids[0].mean
+	}
+
+	// Each centroid's weight is centered on its mean; interpolate between
+	// the centers around the target rank, and to min and max beyond them.
+	target := q * d.count
+	first, last := d.centroids[0], d.centroids[len(d.centroids)-1]
+	if target < first.weight/2 {
+		return d.min + (first.mean-d.min)*target/(first.weight/2)
+	}
+	if target > d.count-last.weight/2 {
+		return last.mean + (d.max-last.mean)*(target-(d.count-last.weight/2))/(last.weight/2)
+	}
+	center := first.weight / 2
+	for i := 1; i < len(d.centroids); i++ {
+		prev, c := d.centroids[i-1], d.centroids[i]
+		next := center + (prev.weight+c.weight)/2
+		if target <= next {
+			return prev.mean + (c.mean-prev.mean)*(target-center)/(next-center)
+		}
+		center = next
+	}
+	return last.mean
+}
+
+// MAD returns an estimate of the median absolute deviation of the added
+// values from their median, or NaN when the digest is empty.
+func (d *Digest) MAD() float64 {
+	median := d.Quantile(0.5)
+	if math.IsNaN(median) {
+		return median
+	}
+	deviations := NewDigest(d.compression)
+	for _, c := range d.centroids {
+		deviations.add(math.Abs(c.mean-median), c.weight)
+	}
+	return deviations.Quantile(0.5)
+}
+
+// compress merges the buffered values into the centroids. A centroid may
+// grow while the k1 scale function advances by at most one over it.
+func (d *Digest) compress() {
+	if len(d.buffer) == 0 {
+		return
+	}
+	all := append(d.buffer, d.centroids...)
+	sort.Slice(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+
+	merged := make([]centroid, 0, len(d.centroids)+1)
+	cur := all[0]
+	before := 0.0
+	limit := d.count * d.kInverse(d.k(0)+1)
+	for _, c := range all[1:] {
+		if before+cur.weight+c.weight <= limit {
+			cur.weight += c.weight
+			cur.mean += (c.mean - cur.mean) * c.weight / cur.weight
+			continue
+		}
+		merged = append(merged, cur)
+		before += cur.weight
+		limit = d.count * d.kInverse(d.k(before/d.count)+1)
+		cur = c
+	}
+	d.centroids = append(merged, cur)
+	d.buffer = all[:0]
+}
+
+// k is the k1 scale function, mapping a quantile to a centroid index.
+func (d *Digest) k(q float64) float64 {
+	return d.compression / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+func (d *Digest) kInverse(k float64) float64 {
+	if k >= d.compression/4 {
+		return 1
+	}
+	return (math.Sin(2*math.Pi*k/d.compression) + 1) / 2
+}
@@ -0,0 +1,71 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func TestDigestQuantiles(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	d := NewDigest(DefaultCompression)
+	values := make([]float64, 200000)
+	for i := range values {
+		values[i] = rng.ExpFloat64()
+		d.Add(values[i])
+	}
+	sort.Float64s(values)
+
+	if d.Count() != len(values) {
+		t.Errorf("Count() = %d, want %d", d.Count(), len(values))
+	}
+	for _, q := range []float64{0, 0.001, 0.01, 0.25, 0.5, 0.75, 0.99, 0.999, 1} {
+		got := d.Quantile(q)
+		// Compare ranks, since the error bound of a t-digest is in rank.
+		rank := float64(sort.SearchFloat64s(values, got)) / float64(len(values))
+		if math.Abs(rank-q) > 0.005 {
+			t.Errorf("Quantile(%v) = %v at rank %v", q, got, rank)
+		}
+	}
+	// The MAD of the standard exponential distribution is about 0.4812.
+	if mad := d.MAD(); math.Abs(mad-0.4812) > 0.01 {
+		t.Errorf("MAD() = %v, want about 0.481", mad)
+	}
+	if got := NewDigest(DefaultCompression).Quantile(0.5); !math.IsNaN(got) {
+		t.Errorf("empty digest median = %v, want NaN", got)
+	}
+}
+
+func TestApproximateProfile(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	var samples []detect.Sample
+	for i := 0; i < 20000; i++ {
+		features := []float64{rng.NormFloat64(), float64(i % 10)}
+		if i%1000 == 0 {
+			features[0] = math.NaN()
+		}
+		samples = append(samples, detect.Sample{Features: features, Label: i % 2})
+	}
+	exact := ProfileSamples(nil, samples)
+	p := NewProfiler(nil, true)
+	for _, sample := range samples {
+		p.Add(sample)
+	}
+	approx := p.Profile()
+
+	if !approx.Approximate || approx.Duplicates != exact.Duplicates || approx.Conflicts != exact.Conflicts ||
+		approx.MissingValues != exact.MissingValues || approx.SampleCount != exact.SampleCount {
+		t.Errorf("approximate profile %+v differs from exact %+v", approx, exact)
+	}
+	for i, want := range exact.Features {
+		got := approx.Features[i]
+		if got.Count != want.Count || got.Min != want.Min || got.Max != want.Max ||
+			math.Abs(got.Mean-want.Mean) > 1e-9 || math.Abs(got.StdDev-want.StdDev) > 1e-9 ||
+			math.Abs(got.Median-want.Median) > 0.05 || math.Abs(got.MAD-want.MAD) > 0.05 {
+			t.Errorf("feature %d: approximate %+v, exact %+v", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+// Package stats profiles datasets before poisoning analysis.
+package stats
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// FeatureStats summarizes the distribution of one feature. Missing values
+// (NaN) and infinite values are counted separately and excluded from the
+// other statistics, which would otherwise be NaN or infinite themselves.
+type FeatureStats struct {
+	Index    int     `json:"index"`
+	Name     string  `json:"name"`
+	Count    int     `json:"count"`
+	Missing  int     `json:"missing"`
+	Infinite int     `json:"infinite"`
+	Mean     float64 `json:"mean"`
+	StdDev   float64 `json:"std_dev"`
+	Min      float64 `json:"min"`
+	P25      float64 `json:"p25"`
+	Median   float64 `json:"median"`
+	P75      float64 `json:"p75"`
+	Max      float64 `json:"max"`
+	// MAD is the median absolute deviation from the median, for robust
+	// thresholds such as median ± 3 × 1.4826 × MAD.
+	MAD float64 `json:"mad"`
+}
+
+// ClassStats holds the number of samples in one class.
+type ClassStats struct {
+	Label    int     `json:"label"`
+	Count    int     `json:"count"`
+	Fraction float64 `json:"fraction"`
+}
+
+// Profile describes a dataset.
+type Profile struct {
+	SampleCount int            `json:"sample_count"`
+	Features    []FeatureStats `json:"features"`
+	Classes     []ClassStats   `json:"classes"`
+
+	// Duplicates counts samples repeating an earlier sample's features and
+	// label; Conflicts counts samples repeating earlier features with a
+	// different label.
+	Duplicates    int     `json:"duplicates"`
+	DuplicateRate float64 `json:"duplicate_rate"`
+	Conflicts     int     `json:"conflicts"`
+	MissingValues int     `json:"missing_values"`
+	MissingRows   int     `json:"missing_rows"`
+	// InfiniteValues counts infinite feature values.
+	InfiniteValues int `json:"infinite_values"`
+
+	// Approximate is set when quantiles and MADs were estimated from
+	// digests rather than computed exactly.
+	Approximate bool `json:"approximate,omitempty"`
+}
+
+// ProfileSamples profiles samples; names gives the feature names. The
+// features are profiled column by column from a detect.Frame.
+func ProfileSamples(names []string, samples []detect.Sample) *Profile {
+	frame := detect.NewFrame(samples)
+	p := NewProfiler(names, false)
+	p.grow(frame.Width())
+	for j := range p.features {
+		f := &p.features[j]
+		f.values, f.missing, f.infinite = frame.Finite(j, make([]float64, 0, frame.Rows()-frame.NullCount(j)))
+	}
+	for _, sample := range samples {
+		features := denseFeatures(sample)
+		missing := false
+		for _, value := range features {
+			if math.IsNaN(value) {
+				missing = true
+				break
+			}
+		}
+		p.addRow(sample, features, missing)
+	}
+	return p.Profile()
+}
+
+// Profiler profiles a dataset one sample at a time. An exact profiler keeps
+// every feature value; an approximate one summarizes each feature in a
+// Digest and computes means and standard deviations in one pass, and
+// detects duplicates from a 64-bit hash of each row, sorting the hashes on
+// disk once there are more than fit in memory (see SetSpill). Its memory
+// then does not grow with the number of samples.
+type Profiler struct {
+	names       []string
+	approximate bool
+	profile     Profile
+
+	features []featureProfile
+	classes  map[int]int
+	seen     map[string]int
+	rows     *dedup
+}
+
+// featureProfile accumulates the values of one feature.
+type featureProfile struct {
+	missing, infinite int
+	values            []float64
+
+	digest   *Digest
+	count    int
+	mean, m2 float64
+}
+
+// NewProfiler returns a profiler for samples with the named features.
+func NewProfiler(names []string, approximate bool) *Profiler {
+	p := &Profiler{names: names, approximate: approximate, classes: make(map[int]int)}
+	if approximate {
+		p.rows = &dedup{limit: DefaultSpillRows}
+	} else {
+		p.seen = make(map[string]int)
+	}
+	return p
+}
+
+// Add profiles a sample.
+func (p *Profiler) Add(sample detect.Sample) {
+	features := denseFeatures(sample)
+	p.grow(len(features))
+	rowMissing := false
+	for i, value := range features {
+		f := &p.features[i]
+		if math.IsNaN(value) {
+			f.missing++
+			rowMissing = true
+			continue
+		}
+		if math.IsInf(value, 0) {
+			f.infinite++
+			continue
+		}
+		if !p.approximate {
+			f.values = append(f.values, value)
+			continue
+		}
+		// Welford's update keeps the variance accurate in one pass.
+		f.count++
+		delta := value - f.mean
+		f.mean += delta / float64(f.count)
+		f.m2 += delta * (value - f.mean)
+		f.digest.Add(value)
+	}
+	p.addRow(sample, features, rowMissing)
+}
+
+// denseFeatures returns the features of sample as float64, converting
+// float32 features. Sparse samples have none.
+func denseFeatures(sample detect.Sample) []float64 {
+	if sample.Features32 != nil {
+		return sample.Dense()
+	}
+	return sample.Features
+}
+
+// grow adds profiles for features up to width.
+func (p *Profiler) grow(width int) {
+	for len(p.features) < width {
+		f := featureProfile{}
+		if p.approximate {
+			f.digest = NewDigest(DefaultCompression)
+		}
+		p.features = append(p.features, f)
+	}
+}
+
+// addRow counts a sample with the given features, its class and whether it
+// repeats an earlier sample; missing is set when it has a missing feature.
+func (p *Profiler) addRow(sample detect.Sample, features []float64, missing bool) {
+	p.profile.SampleCount++
+	if missing {
+		p.profile.MissingRows++
+	}
+
+	p.classes[sample.Label]++
+
+	if p.approximate {
+		// Duplicates are counted by Profile.
+		p.rows.add(featureHash(features), sample.Label)
+		return
+	}
+	key := featureKey(features)
+	label, seen := p.seen[key]
+	switch {
+	case !seen:
+		p.seen[key] = sample.Label
+	case label == sample.Label:
+		p.profile.Duplicates++
+	default:
+		p.profile.Conflicts++
+	}
+}
+
+// SetSpill bounds the memory of duplicate detection in approximate
+// profiles: beyond rows row hashes, they are sorted and spilled to
+// temporary files in dir, or the default temporary directory when dir is
+// empty. Each hash takes SpillRowSize bytes. It has no effect on exact profiles.
+func (p *Profiler) SetSpill(dir string, rows int) {
+	if p.rows == nil {
+		return
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	p.rows.dir, p.rows.limit = dir, rows
+}
+
+// Err returns the first error spilling or merging row hashes, after which
+// the duplicate counts of profiles are zero.
+func (p *Profiler) Err() error {
+	if p.rows == nil {
+		return nil
+	}
+	return p.rows.err
+}
+
+// Close removes the spill files of the profiler.
+func (p *Profiler) Close() error {
+	if p.rows == nil {
+		return nil
+	}
+	return p.rows.close()
+}
+
+// Profile returns the profile of the samples added so far.
+func (p *Profiler) Profile() *Profile {
+	profile := p.profile
+	profile.Approximate = p.approximate
+	if p.rows != nil {
+		duplicates, conflicts, err := p.rows.count()
+		if err != nil && p.rows.err == nil {
+			p.rows.err = err
+		}
+		profile.Duplicates, profile.Conflicts = duplicates, conflicts
+	}
+	profile.Features, profile.Classes, profile.MissingValues, profile.InfiniteValues = nil, nil, 0, 0
+
+	for i := range p.features {
+		f := &p.features[i]
+		var feature FeatureStats
+		if p.approximate {
+			feature = describeDigest(f)
+		} else {
+			feature = describe(f.values)
+		}
+		feature.Index = i
+		feature.Name = featureName(p.names, i)
+		feature.Missing = f.missing
+		profile.MissingValues += f.missing
+		feature.Infinite = f.infinite
+		profile.InfiniteValues += f.infinite
+		profile.Features = append(profile.Features, feature)
+	}
+
+	for label, count := range p.classes {
+		profile.Classes = append(profile.Classes, ClassStats{
+			Label:    label,
+			Count:    count,
+			Fraction: float64(count) / float64(profile.SampleCount),
+		})
+	}
+	sort.Slice(profile.Classes, func(i, j int) bool { return profile.Classes[i].Label < profile.Classes[j].Label })
+
+	if profile.SampleCount > 0 {
+		profile.DuplicateRate = float64(profile.Duplicates) / float64(profile.SampleCount)
+	}
+
+	return &profile
+}
+
+// describe computes summary statistics of values. The slice is sorted.
+func describe(values []float64) FeatureStats {
+	stats := FeatureStats{Count: len(values)}
+	if len(values) == 0 {
+		return stats
+	}
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	stats.Mean = sum / float64(len(values))
+
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - stats.Mean) * (v - stats.Mean)
+	}
+	stats.StdDev = math.Sqrt(variance / float64(len(values)))
+
+	sort.Float64s(values)
+	stats.Min = values[0]
+	stats.Max = values[len(values)-1]
+	stats.P25 = Quantile(values, 0.25)
+	stats.Median = Quantile(values, 0.5)
+	stats.P75 = Quantile(values, 0.75)
+
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - stats.Median)
+	}
+	sort.Float64s(deviations)
+	stats.MAD = Quantile(deviations, 0.5)
+
+	return stats
+}
+
+// describeDigest computes summary statistics of a feature profiled
+// approximately.
+func describeDigest(f *featureProfile) FeatureStats {
+	stats := FeatureStats{Count: f.count}
+	if f.count == 0 {
+		return stats
+	}
+	stats.Mean = f.mean
+	stats.StdDev = math.Sqrt(f.m2 / float64(f.count))
+	stats.Min = f.digest.Quantile(0)
+	stats.Max = f.digest.Quantile(1)
+	stats.P25 = f.digest.Quantile(0.25)
+	stats.Median = f.digest.Quantile(0.5)
+	stats.P75 = f.digest.Quantile(0.75)
+	stats.MAD = f.digest.MAD()
+	return stats
+}
+
+// Quantile returns the q-quantile of sorted values using linear
+// interpolation between closest ranks.
+func Quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	pos := q * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	if lower == upper {
+		return sorted[lower]
+	}
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(pos-float64(lower))
+}
+
+// featureKey encodes a feature vector for duplicate detection.
+func featureKey(features []float64) string {
+	var b strings.Builder
+	for i, f := range features {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	return b.String()
+}
+
+// featureHash hashes a feature vector for duplicate detection in bounded
+// space per row.
+func featureHash(features []float64) uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	for _, f := range features {
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(f))
+		h.Write(buf[:])
+	}
+	return h.Sum64()
+}
+
+// featureName returns the name of feature i, or a positional name.
+func featureName(names []string, i int) string {
+	if i < len(names) && names[i] != "" {
+		return names[i]
+	}
+	return fmt.Sprintf("feature_%d", i)
+}
+
+// GenerateReport generates a dataset profile report.
+func GenerateReport(p *Profile) string {
+	var report string
+
+	report += "=== Dataset Profile ===\n\n"
+	report += fmt.Sprintf("Samples: %d\n", p.SampleCount)
+	report += fmt.Sprintf("Features: %d\n", len(p.Features))
+	report += fmt.Sprintf("Duplicates: %d (%.1f%%)\n", p.Duplicates, p.DuplicateRate*100)
+	report += fmt.Sprintf("Conflicting Duplicates: %d\n", p.Conflicts)
+	report += fmt.Sprintf("Missing Values: %d in %d rows\n", p.MissingValues, p.MissingRows)
+	if p.InfiniteValues > 0 {
+		report += fmt.Sprintf("Infinite Values: %d (excluded from feature statistics)\n", p.InfiniteValues)
+	}
+	if p.Approximate {
+		report += "Quantiles: approximate (t-digest)\n"
+	}
+	report += "\n"
+
+	if len(p.Classes) > 0 {
+		report += "Class Balance:\n"
+		for _, class := range p.Classes {
+			report += fmt.Sprintf("  %6d %10d %6.1f%%\n", class.Label, class.Count, class.Fraction*100)
+		}
+		report += "\n"
+	}
+
+	if len(p.Features) > 0 {
+		report += "Features:\n"
+		report += fmt.Sprintf("  %-20s %8s %10s %10s %10s %10s %10s %10s %10s %10s\n",
+			"Name", "Missing", "Mean", "StdDev", "Min", "P25", "Median", "P75", "Max", "MAD")
+		for _, f := range p.Features {
+			report += fmt.Sprintf("  %-20s %8d %10.3f %10.3f %10.3f %10.3f %10.3f %10.3f %10.3f %10.3f\n",
+				f.Name, f.Missing, f.Mean, f.StdDev, f.Min, f.P25, f.Median, f.P75, f.Max, f.MAD)
+		}
+	}
+
+	return report
+}
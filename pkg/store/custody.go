@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// EventKind is what happened to a sample.
+type EventKind string
+
+// Chain-of-custody events.
+const (
+	// EventIngested records a dataset holding the sample being submitted.
+	EventIngested EventKind = "ingested"
+	// EventFlagged records a scan flagging the sample.
+	EventFlagged EventKind = "flagged"
+	// EventQuarantined records the sample being moved into a quarantine.
+	EventQuarantined EventKind = "quarantined"
+	// EventReviewed records a review decision on the sample, or its
+	// withdrawal.
+	EventReviewed EventKind = "reviewed"
+	// EventRestored records the sample being restored from a quarantine.
+	EventRestored EventKind = "restored"
+	// EventRemoved records a defense removing the sample from a cleaned
+	// dataset, or the sample being purged from a quarantine.
+	EventRemoved EventKind = "removed"
+)
+
+// Event is an entry in the chain of custody of a sample.
+type Event struct {
+	Project  string    `json:"project"`
+	SampleID string    `json:"sample_id"`
+	Kind     EventKind `json:"kind"`
+	At       time.Time `json:"at"`
+	// DatasetID and RunID identify the dataset and the scan or defense
+	// run behind the event, if any.
+	DatasetID string `json:"dataset_id,omitempty"`
+	RunID     string `json:"run_id,omitempty"`
+	// Actor is who caused the event, such as the authenticated client or
+	// the reviewer, if known.
+	Actor string `json:"actor,omitempty"`
+	// Details describes the event, such as the finding of a flag, the
+	// decision of a review or the reason for a quarantine.
+	Details string `json:"details,omitempty"`
+}
+
+// RecordEvents records events in one transaction. Events with the same
+// time keep their order.
+func (s *Store) RecordEvents(ctx context.Context, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, s.rebind(`INSERT INTO sample_events
+	(project, sample_id, kind, at, seq, dataset_id, run_id, actor, details) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for seq, e := range events {
+		if e.At.IsZero() {
+			e.At = time.Now()
+		}
+		if _, err := stmt.ExecContext(ctx, e.Project, e.SampleID, string(e.Kind), e.At.UTC(), seq,
+			e.DatasetID, e.RunID, e.Actor, e.Details); err != nil {
+			return fmt.Errorf("record %s event of %s: %w", e.Kind, e.SampleID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Events returns the chain of custody of a sample of project, oldest
+// first.
+func (s *Store) Events(ctx context.Context, project, sampleID string) ([]Event, error) {
+	rows, err := s.query(ctx, `SELECT project, sample_id, kind, at, dataset_id, run_id, actor, details
+FROM sample_events WHERE project = ? AND sample_id = ? ORDER BY at, seq`, project, sampleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var e Event
+		var kind string
+		if err := rows.Scan(&e.Project, &e.SampleID, &kind, &e.At, &e.DatasetID, &e.RunID, &e.Actor, &e.Details); err != nil {
+			return nil, err
+		}
+		e.Kind = EventKind(kind)
+		e.At = e.At.UTC()
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// IngestEvents returns the ingested events of the samples with ids of a
+// dataset of project submitted from source at a time.
+func IngestEvents(project, datasetID, source string, at time.Time, ids []string) []Event {
+	events := make([]Event, len(ids))
+	for i, id := range ids {
+		events[i] = Event{Project: project, SampleID: id, Kind: EventIngested, At: at, DatasetID: datasetID, Details: "source " + source}
+	}
+	return events
+}
+
+// RunEvents returns the events of a run: a flagged event for each sample
+// a scan flagged, and a removed event for each sample a defense removed.
+func RunEvents(run Run) []Event {
+	var events []Event
+	event := func(id string, kind EventKind, details string) {
+		events = append(events, Event{Project: run.Project, SampleID: id, Kind: kind, At: run.CreatedAt,
+			DatasetID: run.DatasetID, RunID: run.ID, Details: details})
+	}
+	if run.Detection != nil {
+		for _, finding := range run.Detection.Samples {
+			if finding.IsPoisoned {
+				event(finding.ID, EventFlagged, string(finding.Type)+" score "+strconv.FormatFloat(finding.Score, 'f', 3, 64))
+			}
+		}
+	}
+	for _, id := range run.Removed {
+		event(id, EventRemoved, "defense "+run.Strategy)
+	}
+	return events
+}
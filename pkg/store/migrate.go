@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// migrations are the schema changes, applied in order. Never edit a
+// released migration; append a new one.
+var migrations = []string{
+	// 1: runs and review decisions.
+	`CREATE TABLE runs (
+	project        TEXT NOT NULL,
+	id             TEXT NOT NULL,
+	kind           TEXT NOT NULL,
+	dataset_id     TEXT NOT NULL,
+	source         TEXT NOT NULL,
+	strategy       TEXT NOT NULL,
+	created_at     TIMESTAMP NOT NULL,
+	duration_ns    BIGINT NOT NULL,
+	sample_count   INTEGER NOT NULL,
+	poisoned_count INTEGER NOT NULL,
+	risk_score     DOUBLE PRECISION NOT NULL,
+	is_poisoned    BOOLEAN NOT NULL,
+	result         TEXT NOT NULL,
+	removed        TEXT NOT NULL,
+	PRIMARY KEY (project, id)
+);
+CREATE INDEX runs_created_at ON runs (project, created_at);
+CREATE TABLE decisions (
+	project     TEXT NOT NULL,
+	sample_id   TEXT NOT NULL,
+	decision    TEXT NOT NULL,
+	type        TEXT NOT NULL,
+	note        TEXT NOT NULL,
+	reviewer    TEXT NOT NULL,
+	reviewed_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (project, sample_id)
+);`,
+	// 2: per-type finding counts of scans, for trends.
+	`ALTER TABLE runs ADD COLUMN type_counts TEXT NOT NULL DEFAULT '{}';
+CREATE INDEX runs_dataset ON runs (project, dataset_id, created_at);`,
+	// 3: the chain of custody of samples.
+	`CREATE TABLE sample_events (
+	project    TEXT NOT NULL,
+	sample_id  TEXT NOT NULL,
+	kind       TEXT NOT NULL,
+	at         TIMESTAMP NOT NULL,
+	seq        INTEGER NOT NULL,
+	dataset_id TEXT NOT NULL,
+	run_id     TEXT NOT NULL,
+	actor      TEXT NOT NULL,
+	details    TEXT NOT NULL
+);
+CREATE INDEX sample_events_sample ON sample_events (project, sample_id, at);`,
+}
+
+// Version returns the schema version of the database and the latest
+// version this package knows.
+func (s *Store) Version(ctx context.Context) (current, latest int, err error) {
+	err = s.queryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	return current, len(migrations), err
+}
+
+// Migrate applies pending migrations, each in its own transaction. Open
+// calls it; it is safe to call again. It fails if the database was
+// migrated by a newer version of modelpoison.
+func (s *Store) Migrate(ctx context.Context) error {
+	if _, err := s.exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    INTEGER PRIMARY KEY,
+	applied_at TIMESTAMP NOT NULL
+)`); err != nil {
+		return fmt.Errorf("store: create migrations table: %w", err)
+	}
+
+	current, latest, err := s.Version(ctx)
+	if err != nil {
+		return fmt.Errorf("store: read schema version: %w", err)
+	}
+	if current > latest {
+		return fmt.Errorf("store: database schema version %d is newer than this binary supports (%d)", current, latest)
+	}
+
+	for version := current + 1; version <= latest; version++ {
+		if err := s.apply(ctx, version); err != nil {
+			return fmt.Errorf("store: migration %d: %w", version, err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) apply(ctx context.Context, version int) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migrations[version-1]); err != nil {
+		return err
+	}
+	// A concurrent migration of the same version fails here on the
+	// primary key and rolls back.
+	if _, err := tx.ExecContext(ctx, s.rebind(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`),
+		version, time.Now().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
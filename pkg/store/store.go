@@ -0,0 +1,454 @@
+// Package store persists detection and defense results, their run
+// metadata, review decisions and the chain of custody of samples in
+// SQLite or Postgres, so server history survives restarts and can be
+// queried.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Database drivers.
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+
+	"github.com/hallucinaut/modelpoison/pkg/allowlist"
+	"github.com/hallucinaut/modelpoison/pkg/defend"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// ErrNotFound is returned for unknown runs and decisions.
+var ErrNotFound = errors.New("not found")
+
+// Kind is the kind of a run.
+type Kind string
+
+const (
+	KindScan    Kind = "scan"
+	KindDefense Kind = "defense"
+)
+
+// Run is a recorded scan or defense.
+type Run struct {
+	ID        string `json:"id"`
+	Project   string `json:"project"`
+	Kind      Kind   `json:"kind"`
+	DatasetID string `json:"dataset_id"`
+	// Source is where the dataset came from, such as an upload or URI.
+	Source    string        `json:"source,omitempty"`
+	Strategy  string        `json:"strategy,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	Duration  time.Duration `json:"duration_ns"`
+
+	SampleCount   int     `json:"sample_count"`
+	PoisonedCount int     `json:"poisoned_count"`
+	RiskScore     float64 `json:"risk_score"`
+	IsPoisoned    bool    `json:"is_poisoned"`
+	// TypeCounts counts the flagged samples of scans by poison type.
+	TypeCounts map[detect.PoisonType]int `json:"type_counts,omitempty"`
+
+	// Detection and Defense hold the result of a scan or a defense. Runs
+	// listed by Runs omit them.
+	Detection *detect.DetectionResult `json:"detection,omitempty"`
+	Defense   *defend.DefenseResult   `json:"defense,omitempty"`
+	// Removed lists the IDs of the samples a defense removed.
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Query selects runs. Zero fields match every run.
+type Query struct {
+	Project   string
+	Kind      Kind
+	DatasetID string
+	Since     time.Time
+	Until     time.Time
+	// MinRisk selects runs with at least this risk score.
+	MinRisk float64
+	// Poisoned, when set, selects runs that did or did not find poisoning.
+	Poisoned *bool
+	// Limit caps the number of runs returned, newest first (default 100).
+	Limit  int
+	Offset int
+}
+
+// DefaultLimit is the number of runs returned by a query without a limit.
+const DefaultLimit = 100
+
+// Store is a database of runs and review decisions. It is safe for
+// concurrent use.
+type Store struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+// dialect is a supported database.
+type dialect string
+
+const (
+	sqlite   dialect = "sqlite"
+	postgres dialect = "postgres"
+)
+
+// Open opens the database named by dsn and applies pending migrations. A
+// postgres:// or postgresql:// URL opens Postgres; anything else is a
+// SQLite database file, optionally prefixed with sqlite: or file:.
+func Open(ctx context.Context, dsn string) (*Store, error) {
+	s := &Store{dialect: sqlite}
+	driver, source := "sqlite", dsn
+	switch {
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		s.dialect = postgres
+		driver = "postgres"
+	case strings.HasPrefix(dsn, "sqlite:"):
+		source = strings.TrimPrefix(strings.TrimPrefix(dsn, "sqlite:"), "//")
+	}
+	if source == "" {
+		return nil, errors.New("store: empty database name")
+	}
+	if s.dialect == sqlite {
+		sep := "?"
+		if strings.Contains(source, "?") {
+			sep = "&"
+		}
+		// Wait for locks held by other processes instead of failing.
+		source += sep + "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	}
+
+	db, err := sql.Open(driver, source)
+	if err != nil {
+		return nil, err
+	}
+	if s.dialect == sqlite {
+		// SQLite allows one writer at a time.
+		db.SetMaxOpenConns(1)
+	}
+	s.db = db
+	if err := s.Migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// SaveRun records a run, replacing any run with the same project and ID.
+func (s *Store) SaveRun(ctx context.Context, run Run) error {
+	var result interface{}
+	switch {
+	case run.Detection != nil:
+		result = run.Detection
+	case run.Defense != nil:
+		result = run.Defense
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	removed, err := json.Marshal(run.Removed)
+	if err != nil {
+		return err
+	}
+	typeCounts := run.TypeCounts
+	if typeCounts == nil && run.Detection != nil {
+		typeCounts = countTypes(run.Detection)
+	}
+	types, err := json.Marshal(typeCounts)
+	if err != nil {
+		return err
+	}
+	if typeCounts == nil {
+		types = []byte("{}")
+	}
+
+	_, err = s.exec(ctx, `INSERT INTO runs (project, id, kind, dataset_id, source, strategy, created_at,
+	duration_ns, sample_count, poisoned_count, risk_score, is_poisoned, type_counts, result, removed)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (project, id) DO UPDATE SET kind = excluded.kind, dataset_id = excluded.dataset_id,
+	source = excluded.source, strategy = excluded.strategy, created_at = excluded.created_at,
+	duration_ns = excluded.duration_ns, sample_count = excluded.sample_count,
+	poisoned_count = excluded.poisoned_count, risk_score = excluded.risk_score,
+	is_poisoned = excluded.is_poisoned, type_counts = excluded.type_counts,
+	result = excluded.result, removed = excluded.removed`,
+		run.Project, run.ID, string(run.Kind), run.DatasetID, run.Source, run.Strategy, run.CreatedAt.UTC(),
+		int64(run.Duration), run.SampleCount, run.PoisonedCount, run.RiskScore, run.IsPoisoned,
+		string(types), string(data), string(removed))
+	if err != nil {
+		return fmt.Errorf("save run %s: %w", run.ID, err)
+	}
+	return nil
+}
+
+// ScanRun returns the run recording a detection result.
+func ScanRun(project, id, datasetID, source string, createdAt time.Time, duration time.Duration, result *detect.DetectionResult) Run {
+	return Run{
+		ID:            id,
+		Project:       project,
+		Kind:          KindScan,
+		DatasetID:     datasetID,
+		Source:        source,
+		CreatedAt:     createdAt,
+		Duration:      duration,
+		SampleCount:   result.SampleCount,
+		PoisonedCount: result.PoisonedCount,
+		RiskScore:     result.RiskScore,
+		IsPoisoned:    result.IsPoisoned,
+		TypeCounts:    countTypes(result),
+		Detection:     result,
+	}
+}
+
+// countTypes counts the flagged samples of result by poison type.
+func countTypes(result *detect.DetectionResult) map[detect.PoisonType]int {
+	counts := make(map[detect.PoisonType]int)
+	for _, sample := range result.Samples {
+		if sample.IsPoisoned {
+			counts[sample.Type]++
+		}
+	}
+	return counts
+}
+
+// runColumns are the columns scanned by scanRun, without the results.
+const runColumns = `project, id, kind, dataset_id, source, strategy, created_at, duration_ns,
+	sample_count, poisoned_count, risk_score, is_poisoned, type_counts`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRun(row scanner, extra ...interface{}) (Run, error) {
+	var run Run
+	var kind, types string
+	var duration int64
+	dest := append([]interface{}{&run.Project, &run.ID, &kind, &run.DatasetID, &run.Source, &run.Strategy,
+		&run.CreatedAt, &duration, &run.SampleCount, &run.PoisonedCount, &run.RiskScore, &run.IsPoisoned, &types}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return Run{}, err
+	}
+	if err := json.Unmarshal([]byte(types), &run.TypeCounts); err != nil {
+		return Run{}, fmt.Errorf("run %s: decode type counts: %w", run.ID, err)
+	}
+	if len(run.TypeCounts) == 0 {
+		run.TypeCounts = nil
+	}
+	run.Kind = Kind(kind)
+	run.Duration = time.Duration(duration)
+	run.CreatedAt = run.CreatedAt.UTC()
+	return run, nil
+}
+
+// Run returns a run of project with its result.
+func (s *Store) Run(ctx context.Context, project, id string) (*Run, error) {
+	var result, removed string
+	run, err := scanRun(s.queryRow(ctx, `SELECT `+runColumns+`, result, removed FROM runs
+WHERE project = ? AND id = ?`, project, id), &result, &removed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("run %s: %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch run.Kind {
+	case KindScan:
+		err = json.Unmarshal([]byte(result), &run.Detection)
+	case KindDefense:
+		err = json.Unmarshal([]byte(result), &run.Defense)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("run %s: decode result: %w", id, err)
+	}
+	if err := json.Unmarshal([]byte(removed), &run.Removed); err != nil {
+		return nil, fmt.Errorf("run %s: decode removed samples: %w", id, err)
+	}
+	return &run, nil
+}
+
+// Runs returns the runs matching q, newest first, without their results.
+func (s *Store) Runs(ctx context.Context, q Query) ([]Run, error) {
+	var where []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		where = append(where, cond)
+		args = append(args, arg)
+	}
+	if q.Project != "" {
+		add("project = ?", q.Project)
+	}
+	if q.Kind != "" {
+		add("kind = ?", string(q.Kind))
+	}
+	if q.DatasetID != "" {
+		add("dataset_id = ?", q.DatasetID)
+	}
+	if !q.Since.IsZero() {
+		add("created_at >= ?", q.Since.UTC())
+	}
+	if !q.Until.IsZero() {
+		add("created_at < ?", q.Until.UTC())
+	}
+	if q.MinRisk > 0 {
+		add("risk_score >= ?", q.MinRisk)
+	}
+	if q.Poisoned != nil {
+		add("is_poisoned = ?", *q.Poisoned)
+	}
+
+	query := `SELECT ` + runColumns + ` FROM runs`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	query += " ORDER BY created_at DESC, id LIMIT " + strconv.Itoa(limit) + " OFFSET " + strconv.Itoa(q.Offset)
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []Run{}
+	for rows.Next() {
+		run, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// Trend returns the scans matching q, oldest first, for following a
+// dataset's findings over time; q.Kind is ignored. Scans recorded before
+// type counts were stored have theirs counted from their results.
+func (s *Store) Trend(ctx context.Context, q Query) ([]Run, error) {
+	q.Kind = KindScan
+	runs, err := s.Runs(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+		runs[i], runs[j] = runs[j], runs[i]
+	}
+	for i, run := range runs {
+		if run.TypeCounts != nil || run.PoisonedCount == 0 {
+			continue
+		}
+		full, err := s.Run(ctx, run.Project, run.ID)
+		if err != nil {
+			return nil, err
+		}
+		if full.Detection != nil {
+			runs[i].TypeCounts = countTypes(full.Detection)
+		}
+	}
+	return runs, nil
+}
+
+// Decisions returns the review decisions of project sorted by sample ID.
+func (s *Store) Decisions(ctx context.Context, project string) ([]allowlist.Entry, error) {
+	rows, err := s.query(ctx, `SELECT sample_id, decision, type, note, reviewer, reviewed_at FROM decisions
+WHERE project = ? ORDER BY sample_id`, project)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []allowlist.Entry{}
+	for rows.Next() {
+		var entry allowlist.Entry
+		var decision string
+		if err := rows.Scan(&entry.ID, &decision, &entry.Type, &entry.Note, &entry.Reviewer, &entry.ReviewedAt); err != nil {
+			return nil, err
+		}
+		entry.Decision = allowlist.Decision(decision)
+		entry.ReviewedAt = entry.ReviewedAt.UTC()
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// Allowlist returns the review decisions of project as an allowlist.
+func (s *Store) Allowlist(ctx context.Context, project string) (*allowlist.Allowlist, error) {
+	entries, err := s.Decisions(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	list := allowlist.New()
+	for _, entry := range entries {
+		list.Set(entry)
+	}
+	return list, nil
+}
+
+// SetDecision records a review decision, replacing any previous decision
+// for the sample.
+func (s *Store) SetDecision(ctx context.Context, project string, entry allowlist.Entry) error {
+	if entry.ReviewedAt.IsZero() {
+		entry.ReviewedAt = time.Now()
+	}
+	_, err := s.exec(ctx, `INSERT INTO decisions (project, sample_id, decision, type, note, reviewer, reviewed_at)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (project, sample_id) DO UPDATE SET decision = excluded.decision, type = excluded.type,
+	note = excluded.note, reviewer = excluded.reviewer, reviewed_at = excluded.reviewed_at`,
+		project, entry.ID, string(entry.Decision), entry.Type, entry.Note, entry.Reviewer, entry.ReviewedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("save decision for %s: %w", entry.ID, err)
+	}
+	return nil
+}
+
+// RemoveDecision deletes the review decision for a sample.
+func (s *Store) RemoveDecision(ctx context.Context, project, id string) error {
+	res, err := s.exec(ctx, `DELETE FROM decisions WHERE project = ? AND sample_id = ?`, project, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("decision for %s: %w", id, ErrNotFound)
+	}
+	return nil
+}
+
+func (s *Store) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return s.db.ExecContext(ctx, s.rebind(query), args...)
+}
+
+func (s *Store) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return s.db.QueryContext(ctx, s.rebind(query), args...)
+}
+
+func (s *Store) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return s.db.QueryRowContext(ctx, s.rebind(query), args...)
+}
+
+// rebind rewrites ? placeholders as $1, $2, ... for Postgres.
+func (s *Store) rebind(query string) string {
+	if s.dialect != postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
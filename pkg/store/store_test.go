@@ -0,0 +1,198 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/allowlist"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func openTest(t *testing.T) *Store {
+	t.Helper()
+	dsn := os.Getenv("MODELPOISON_TEST_POSTGRES")
+	if dsn == "" {
+		dsn = filepath.Join(t.TempDir(), "modelpoison.db")
+	}
+	s, err := Open(context.Background(), dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if s.dialect == postgres {
+			s.exec(context.Background(), `DELETE FROM runs`)
+			s.exec(context.Background(), `DELETE FROM decisions`)
+			s.exec(context.Background(), `DELETE FROM sample_events`)
+		}
+		s.Close()
+	})
+	return s
+}
+
+func TestRuns(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for i, risk := range []float64{0.1, 0.6, 0.9} {
+		result := &detect.DetectionResult{SampleCount: 100, PoisonedCount: int(risk * 10), RiskScore: risk, IsPoisoned: risk > 0.5}
+		run := ScanRun("default", string(rune('a'+i)), "ds1", "upload", start.Add(time.Duration(i)*time.Hour), time.Second, result)
+		if err := s.SaveRun(ctx, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SaveRun(ctx, ScanRun("other", "a", "ds2", "", start, 0, &detect.DetectionResult{})); err != nil {
+		t.Fatal(err)
+	}
+
+	poisoned := true
+	runs, err := s.Runs(ctx, Query{Project: "default", Poisoned: &poisoned})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].ID != "c" || runs[1].ID != "b" || runs[0].Detection != nil {
+		t.Errorf("poisoned runs = %+v, want c, b without results", runs)
+	}
+	if runs, _ := s.Runs(ctx, Query{Since: start.Add(time.Hour), Until: start.Add(2 * time.Hour)}); len(runs) != 1 || runs[0].ID != "b" {
+		t.Errorf("runs in the second hour = %+v, want b", runs)
+	}
+
+	run, err := s.Run(ctx, "default", "c")
+	if err != nil || run.Detection == nil || run.Detection.RiskScore != 0.9 || !run.CreatedAt.Equal(start.Add(2*time.Hour)) {
+		t.Errorf("Run(c) = %+v, %v", run, err)
+	}
+	if _, err := s.Run(ctx, "other", "c"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Run in another project error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestTrend(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		result := &detect.DetectionResult{SampleCount: 100, PoisonedCount: i + 1}
+		for j := 0; j <= i; j++ {
+			result.Samples = append(result.Samples, detect.PoisonedSample{IsPoisoned: true, Type: detect.TypeBackdoor})
+		}
+		run := ScanRun("default", string(rune('a'+i)), "ds1", "", start.Add(time.Duration(i)*24*time.Hour), 0, result)
+		if err := s.SaveRun(ctx, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Scans recorded before migration 2 have no type counts.
+	if _, err := s.exec(ctx, `UPDATE runs SET type_counts = '{}' WHERE id = ?`, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveRun(ctx, ScanRun("default", "x", "ds2", "", start, 0, &detect.DetectionResult{})); err != nil {
+		t.Fatal(err)
+	}
+
+	runs, err := s.Trend(ctx, Query{Project: "default", DatasetID: "ds1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 3 || runs[0].ID != "a" || runs[2].ID != "c" {
+		t.Fatalf("trend = %+v, want a, b, c", runs)
+	}
+	for i, run := range runs {
+		if got := run.TypeCounts[detect.TypeBackdoor]; got != i+1 {
+			t.Errorf("scan %s backdoor count = %d, want %d", run.ID, got, i+1)
+		}
+	}
+}
+
+func TestDecisions(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+
+	entry := allowlist.Entry{ID: "s1", Decision: allowlist.DecisionFalsePositive, Note: "benign", Reviewer: "ana"}
+	if err := s.SetDecision(ctx, "default", entry); err != nil {
+		t.Fatal(err)
+	}
+	entry.Decision = allowlist.DecisionAccepted
+	if err := s.SetDecision(ctx, "default", entry); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := s.Allowlist(ctx, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := list.Lookup("s1"); !ok || got.Decision != allowlist.DecisionAccepted || got.Note != "benign" {
+		t.Errorf("decision for s1 = %+v, %v", got, ok)
+	}
+	if err := s.RemoveDecision(ctx, "default", "s1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RemoveDecision(ctx, "default", "s1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second RemoveDecision error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	s := openTest(t)
+	ctx := context.Background()
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	events := IngestEvents("default", "ds1", "upload", start, []string{"s1", "s2"})
+	result := &detect.DetectionResult{Samples: []detect.PoisonedSample{
+		{ID: "s1", IsPoisoned: true, Type: detect.TypeBackdoor, Score: 0.9},
+		{ID: "s2"},
+	}}
+	events = append(events, RunEvents(ScanRun("default", "scan1", "ds1", "upload", start, 0, result))...)
+	events = append(events, RunEvents(Run{Project: "default", ID: "def1", Strategy: "data_cleaning", CreatedAt: start.Add(time.Hour), Removed: []string{"s1"}})...)
+	events = append(events, Event{Project: "other", SampleID: "s1", Kind: EventReviewed, At: start})
+	if err := s.RecordEvents(ctx, events); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Events(ctx, "default", "s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []EventKind
+	for _, e := range got {
+		kinds = append(kinds, e.Kind)
+	}
+	if want := []EventKind{EventIngested, EventFlagged, EventRemoved}; !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("events of s1 = %v, want %v", kinds, want)
+	}
+	if e := got[1]; e.RunID != "scan1" || e.Details != "backdoor score 0.900" || !e.At.Equal(start) {
+		t.Errorf("flagged event = %+v", e)
+	}
+	if got, _ := s.Events(ctx, "default", "s2"); len(got) != 1 {
+		t.Errorf("events of s2 = %+v, want its ingestion", got)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "modelpoison.db")
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		s, err := Open(ctx, "sqlite:"+path)
+		if err != nil {
+			t.Fatalf("open %d: %v", i, err)
+		}
+		if current, latest, err := s.Version(ctx); err != nil || current != latest {
+			t.Errorf("Version = %d, %d, %v; want the latest", current, latest, err)
+		}
+		s.Close()
+	}
+
+	s, err := Open(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.exec(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, len(migrations)+1, time.Now())
+	s.Close()
+	if _, err := Open(ctx, path); err == nil {
+		t.Error("Open accepted a database from a newer version")
+	}
+}
@@ -0,0 +1,119 @@
+// Package synthetic generates labeled datasets with known poisons for
+// tests, benchmarks and demos.
+package synthetic
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/hallucinaut/modelpoison/pkg/attack"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Config configures a generated dataset: a Gaussian mixture with a
+// component per class.
+type Config struct {
+	// Samples and Features are the dataset's dimensions.
+	Samples  int
+	Features int
+	// Classes is the number of classes, labeled 0 to Classes-1 and drawn
+	// uniformly.
+	Classes int
+	// Spread is the standard deviation of the class means along each
+	// feature; zero puts every class at the origin.
+	Spread float64
+	// Noise is the standard deviation of each feature within a class.
+	Noise float64
+	// LabelNoise is the fraction of samples given a uniformly random
+	// label, as annotators mislabel data. They are not poisons.
+	LabelNoise float64
+	// Seed seeds the generator. The same configuration and seed always
+	// generate the same dataset.
+	Seed int64
+	// Poison, when set, is injected into the dataset after it is
+	// generated.
+	Poison *attack.Config
+}
+
+// DefaultConfig returns the configuration of a clean dataset of 1000
+// samples of 20 features in 3 classes.
+func DefaultConfig() Config {
+	return Config{
+		Samples:  1000,
+		Features: 20,
+		Classes:  3,
+		Spread:   2,
+		Noise:    1,
+		Seed:     1,
+	}
+}
+
+// Dataset is a generated dataset and its ground truth.
+type Dataset struct {
+	// Features names the features f0 to fN-1.
+	Features []string
+	Samples  []detect.Sample
+	// Poisoned and Injections are the ground truth of the injected poison:
+	// all false and empty for clean datasets.
+	Poisoned   []bool
+	Injections []attack.Injection
+}
+
+// Generate generates the dataset configured by cfg.
+func Generate(cfg Config) (*Dataset, error) {
+	if cfg.Samples < 1 || cfg.Features < 1 {
+		return nil, fmt.Errorf("datasets need samples and features, got %d samples of %d features", cfg.Samples, cfg.Features)
+	}
+	if cfg.Classes < 1 {
+		return nil, fmt.Errorf("datasets need a class, got %d", cfg.Classes)
+	}
+	if cfg.Spread < 0 || cfg.Noise < 0 {
+		return nil, fmt.Errorf("spread and noise must not be negative, got %v and %v", cfg.Spread, cfg.Noise)
+	}
+	if cfg.LabelNoise < 0 || cfg.LabelNoise > 1 {
+		return nil, fmt.Errorf("label noise must be between 0 and 1, got %v", cfg.LabelNoise)
+	}
+
+	rng := rand.New(rand.NewSource(cfg.Seed))
+	means := make([][]float64, cfg.Classes)
+	for c := range means {
+		means[c] = make([]float64, cfg.Features)
+		for j := range means[c] {
+			means[c][j] = cfg.Spread * rng.NormFloat64()
+		}
+	}
+
+	data := &Dataset{
+		Features: make([]string, cfg.Features),
+		Samples:  make([]detect.Sample, cfg.Samples),
+		Poisoned: make([]bool, cfg.Samples),
+	}
+	for j := range data.Features {
+		data.Features[j] = fmt.Sprintf("f%d", j)
+	}
+	for i := range data.Samples {
+		class := rng.Intn(cfg.Classes)
+		features := make([]float64, cfg.Features)
+		for j := range features {
+			features[j] = means[class][j] + cfg.Noise*rng.NormFloat64()
+		}
+		label := class
+		if rng.Float64() < cfg.LabelNoise {
+			label = rng.Intn(cfg.Classes)
+		}
+		data.Samples[i] = detect.Sample{ID: fmt.Sprintf("synthetic-%d", i), Features: features, Label: label}
+	}
+
+	if cfg.Poison != nil {
+		injections, err := attack.Inject(data.Samples, *cfg.Poison)
+		if err != nil {
+			return nil, err
+		}
+		data.Injections = injections
+		for _, injection := range injections {
+			data.Poisoned[injection.Index] = true
+		}
+	}
+
+	return data, nil
+}
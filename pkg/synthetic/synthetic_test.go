@@ -0,0 +1,88 @@
+package synthetic
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/attack"
+)
+
+func TestGenerate(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Spread = 10
+	cfg.Noise = 0.5
+	data, err := Generate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Samples) != cfg.Samples || len(data.Features) != cfg.Features || len(data.Injections) != 0 {
+		t.Fatalf("%d samples, %d features, %d injections", len(data.Samples), len(data.Features), len(data.Injections))
+	}
+
+	// Well-separated classes: samples of a class are nearer their own
+	// class's first sample than another class's.
+	first := make(map[int][]float64)
+	for _, sample := range data.Samples {
+		if _, ok := first[sample.Label]; !ok {
+			first[sample.Label] = sample.Features
+		}
+	}
+	if len(first) != cfg.Classes {
+		t.Fatalf("got %d classes, want %d", len(first), cfg.Classes)
+	}
+	for _, sample := range data.Samples[:100] {
+		own := distance(sample.Features, first[sample.Label])
+		for label, features := range first {
+			if label != sample.Label && distance(sample.Features, features) < own {
+				t.Fatalf("%s of class %d is nearer class %d", sample.ID, sample.Label, label)
+			}
+		}
+	}
+
+	again, err := Generate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(data, again) {
+		t.Error("the same seed generated different datasets")
+	}
+}
+
+func TestGeneratePoisoned(t *testing.T) {
+	poison := attack.DefaultConfig()
+	poison.Rate = 0.05
+	cfg := DefaultConfig()
+	cfg.Poison = &poison
+	data, err := Generate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poisoned := 0
+	for _, p := range data.Poisoned {
+		if p {
+			poisoned++
+		}
+	}
+	if poisoned != 50 || len(data.Injections) != 50 {
+		t.Errorf("%d poisoned, %d injections; want 50", poisoned, len(data.Injections))
+	}
+	for _, injection := range data.Injections {
+		if data.Samples[injection.Index].Label != poison.TargetClass {
+			t.Errorf("%s is labeled %d", injection.ID, data.Samples[injection.Index].Label)
+		}
+	}
+
+	cfg.Samples = 0
+	if _, err := Generate(cfg); err == nil {
+		t.Error("generated a dataset without samples")
+	}
+}
+
+func distance(a, b []float64) float64 {
+	sum := 0.0
+	for i := range a {
+		sum += (a[i] - b[i]) * (a[i] - b[i])
+	}
+	return math.Sqrt(sum)
+}
@@ -0,0 +1,109 @@
+// Package telemetry configures OpenTelemetry tracing for modelpoison.
+//
+// The dataset, detect and defend packages create spans through the global
+// tracer provider, so programs embedding them only need to install their
+// own provider. Setup installs an OTLP exporter for the modelpoison
+// commands.
+package telemetry
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Options configures tracing.
+type Options struct {
+	// Endpoint is the host:port of an OTLP/gRPC collector. When empty, the
+	// standard OTEL_EXPORTER_OTLP_* environment variables are used, and
+	// tracing stays disabled unless one of them sets an endpoint.
+	Endpoint string
+	// Insecure disables TLS to the collector.
+	Insecure bool
+	// ServiceName and ServiceVersion identify the traced program.
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence.
+	ServiceName    string
+	ServiceVersion string
+}
+
+// Enabled reports whether the options or the environment configure a
+// collector.
+func (o Options) Enabled() bool {
+	return o.Endpoint != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup installs the W3C trace context propagator and, when a collector is
+// configured, a tracer provider exporting spans over OTLP/gRPC. The
+// returned function flushes and shuts the provider down.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if !opts.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var exporterOpts []otlptracegrpc.Option
+	if opts.Endpoint != "" {
+		exporterOpts = append(exporterOpts, otlptracegrpc.WithEndpoint(opts.Endpoint))
+	}
+	if opts.Insecure {
+		exporterOpts = append(exporterOpts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, exporterOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", opts.ServiceName),
+			attribute.String("service.version", opts.ServiceVersion),
+		),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// FromEnvironment returns ctx with the remote span context carried by the
+// TRACEPARENT and TRACESTATE environment variables, so a command run by
+// a traced job joins the job's trace.
+func FromEnvironment(ctx context.Context) context.Context {
+	carrier := propagation.MapCarrier{}
+	if v := os.Getenv("TRACEPARENT"); v != "" {
+		carrier.Set("traceparent", v)
+	}
+	if v := os.Getenv("TRACESTATE"); v != "" {
+		carrier.Set("tracestate", v)
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// End records err, if any, on span and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
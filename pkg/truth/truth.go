@@ -0,0 +1,256 @@
+// Package truth reads ground-truth sidecars: lists of the poisoned samples
+// of a dataset, such as the poison lists published with academic
+// datasets, kept apart from the dataset itself.
+package truth
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hallucinaut/modelpoison/pkg/attack"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Entry is a poisoned sample.
+type Entry struct {
+	ID string `json:"id"`
+	// Type is the kind of poison, such as an attack or poison type name,
+	// if published.
+	Type string `json:"type,omitempty"`
+	// Target is the class the poison targets, if published.
+	Target *int `json:"target,omitempty"`
+}
+
+// Truth is the ground truth of a dataset: the samples listed are
+// poisoned, all others clean.
+type Truth struct {
+	entries []Entry
+	byID    map[string]int
+}
+
+// file is the JSON form of a sidecar.
+type file struct {
+	Samples []Entry `json:"samples"`
+}
+
+// New returns the ground truth listing entries. Later entries of an ID
+// replace earlier ones.
+func New(entries []Entry) (*Truth, error) {
+	t := &Truth{byID: make(map[string]int, len(entries))}
+	for _, entry := range entries {
+		if entry.ID = strings.TrimSpace(entry.ID); entry.ID == "" {
+			return nil, fmt.Errorf("entry without a sample ID")
+		}
+		if i, ok := t.byID[entry.ID]; ok {
+			t.entries[i] = entry
+			continue
+		}
+		t.byID[entry.ID] = len(t.entries)
+		t.entries = append(t.entries, entry)
+	}
+	return t, nil
+}
+
+// FromManifest returns the ground truth of the attack recorded by m. The
+// type of each poison is its attack, and its target the class it was
+// relabeled with, or the target class of clean-label poisons.
+func FromManifest(m attack.Manifest) *Truth {
+	entries := make([]Entry, len(m.Injections))
+	for i, injection := range m.Injections {
+		entry := Entry{ID: injection.ID, Type: injection.Attack}
+		switch injection.Attack {
+		case attack.AttackBackdoor, attack.AttackLabelFlip:
+			target := injection.Label
+			entry.Target = &target
+		case attack.AttackCleanLabel:
+			target := m.TargetClass
+			entry.Target = &target
+		}
+		entries[i] = entry
+	}
+	t, _ := New(entries)
+	return t
+}
+
+// Load reads a sidecar file. See Read for its formats.
+func Load(path string) (*Truth, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := Read(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Read reads a sidecar in one of these formats:
+//
+//   - JSON, {"samples": [{"id": "s1", "type": "backdoor", "target": 3}]},
+//     or an attack manifest written by inject --manifest;
+//   - CSV with a header naming an "id" column and optionally "type" and
+//     "target" columns;
+//   - a text list of sample IDs, one per line, with # comments, as
+//     written by inject --ids.
+func Read(r io.Reader) (*Truth, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		return readJSON(trimmed)
+	}
+	if header, _, _ := bytes.Cut(trimmed, []byte("\n")); bytes.Contains(header, []byte(",")) {
+		return readCSV(trimmed)
+	}
+	return readList(trimmed)
+}
+
+// readJSON reads a JSON sidecar or attack manifest.
+func readJSON(data []byte) (*Truth, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if _, ok := fields["injections"]; ok {
+		var m attack.Manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			return nil, err
+		}
+		return FromManifest(m), nil
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+	if f.Samples == nil {
+		return nil, fmt.Errorf("no \"samples\" list")
+	}
+	return New(f.Samples)
+}
+
+// readCSV reads a CSV sidecar.
+func readCSV(data []byte) (*Truth, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	idCol, typeCol, targetCol := -1, -1, -1
+	for i, name := range records[0] {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "id":
+			idCol = i
+		case "type":
+			typeCol = i
+		case "target":
+			targetCol = i
+		}
+	}
+	if idCol < 0 {
+		return nil, fmt.Errorf("no \"id\" column")
+	}
+
+	var entries []Entry
+	for line, record := range records[1:] {
+		field := func(col int) string {
+			if col < 0 || col >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[col])
+		}
+		entry := Entry{ID: field(idCol), Type: field(typeCol)}
+		if entry.ID == "" {
+			continue
+		}
+		if value := field(targetCol); value != "" {
+			target, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid target %q", line+2, value)
+			}
+			entry.Target = &target
+		}
+		entries = append(entries, entry)
+	}
+	return New(entries)
+}
+
+// readList reads a text list of sample IDs.
+func readList(data []byte) (*Truth, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		text := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = strings.TrimSpace(text[:i])
+		}
+		if text != "" {
+			entries = append(entries, Entry{ID: text})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return New(entries)
+}
+
+// Entries returns the poisoned samples in the order they were listed.
+func (t *Truth) Entries() []Entry {
+	return append([]Entry(nil), t.entries...)
+}
+
+// Types returns the distinct poison types listed, sorted.
+func (t *Truth) Types() []string {
+	seen := make(map[string]bool)
+	var types []string
+	for _, entry := range t.entries {
+		if entry.Type != "" && !seen[entry.Type] {
+			seen[entry.Type] = true
+			types = append(types, entry.Type)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Label returns the ground truth of samples and the poison type of each,
+// empty for clean samples and poisons of no published type, with the
+// number of listed samples found among them.
+func (t *Truth) Label(samples []detect.Sample) (poisoned []bool, types []string, found int) {
+	poisoned = make([]bool, len(samples))
+	types = make([]string, len(samples))
+	seen := make(map[string]bool)
+	for i, sample := range samples {
+		j, ok := t.byID[sample.ID]
+		if !ok {
+			continue
+		}
+		poisoned[i] = true
+		types[i] = t.entries[j].Type
+		if !seen[sample.ID] {
+			seen[sample.ID] = true
+			found++
+		}
+	}
+	return poisoned, types, found
+}
+
+// Write writes t as a JSON sidecar.
+func (t *Truth) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(file{Samples: t.Entries()})
+}
@@ -0,0 +1,79 @@
+package truth
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/attack"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func intPtr(v int) *int { return &v }
+
+func TestRead(t *testing.T) {
+	want := []Entry{
+		{ID: "s1", Type: "backdoor", Target: intPtr(3)},
+		{ID: "s4", Type: "label_flip"},
+	}
+	// Manifests record the label of every poison.
+	manifest := []Entry{want[0], {ID: "s4", Type: "label_flip", Target: intPtr(0)}}
+	for _, tt := range []struct {
+		name, src string
+		want      []Entry
+	}{
+		{"json", `{"samples": [{"id": "s1", "type": "backdoor", "target": 3}, {"id": "s4", "type": "label_flip"}]}`, want},
+		{"csv", "id,type,target\ns1,backdoor,3\ns4,label_flip,\n", want},
+		{"manifest", `{"attack": "backdoor", "target_class": 3, "injections": [
+			{"index": 1, "id": "s1", "attack": "backdoor", "original_label": 0, "label": 3},
+			{"index": 4, "id": "s4", "attack": "label_flip", "original_label": 1, "label": 0}]}`, manifest},
+	} {
+		truth, err := Read(strings.NewReader(tt.src))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := truth.Entries(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: entries %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	truth, err := Read(strings.NewReader("# poisons\ns1\ns4  # relabeled\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := truth.Entries(); !reflect.DeepEqual(got, []Entry{{ID: "s1"}, {ID: "s4"}}) {
+		t.Errorf("list entries %+v", got)
+	}
+
+	for _, src := range []string{`{"other": 1}`, "name,type\ns1,backdoor\n", "id,target\ns1,three\n"} {
+		if _, err := Read(strings.NewReader(src)); err == nil {
+			t.Errorf("Read(%q) succeeded", src)
+		}
+	}
+}
+
+func TestLabel(t *testing.T) {
+	truth, err := New([]Entry{{ID: "s1", Type: "backdoor"}, {ID: "s3", Type: "label_flip"}, {ID: "s9"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := make([]detect.Sample, 5)
+	for i := range samples {
+		samples[i].ID = fmt.Sprintf("s%d", i)
+	}
+	poisoned, types, found := truth.Label(samples)
+	if want := []bool{false, true, false, true, false}; !reflect.DeepEqual(poisoned, want) {
+		t.Errorf("poisoned %v, want %v", poisoned, want)
+	}
+	if want := []string{"", "backdoor", "", "label_flip", ""}; !reflect.DeepEqual(types, want) {
+		t.Errorf("types %q, want %q", types, want)
+	}
+	if found != 2 {
+		t.Errorf("found %d, want 2", found)
+	}
+	if got := truth.Types(); !reflect.DeepEqual(got, []string{attack.AttackBackdoor, attack.AttackLabelFlip}) {
+		t.Errorf("types %v", got)
+	}
+}
@@ -0,0 +1,176 @@
+package tune
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Fold is the outcome of tuning on all folds but one.
+type Fold struct {
+	Thresholds map[detect.PoisonType]float64 `json:"thresholds"`
+	// Train scores the thresholds on the samples they were tuned on and
+	// Test on the held-out fold.
+	Train Metrics `json:"train"`
+	Test  Metrics `json:"test"`
+}
+
+// Spread is the mean and standard deviation of a value across folds.
+type Spread struct {
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+}
+
+// CrossValidation is the outcome of a k-fold cross-validation of Tune.
+type CrossValidation struct {
+	Folds []Fold `json:"folds"`
+	// Precision, Recall and FScore spread the held-out metrics and
+	// TrainFScore the F-beta on the tuning folds; a held-out F-beta well
+	// below it means tuning overfits.
+	Precision   Spread `json:"precision"`
+	Recall      Spread `json:"recall"`
+	FScore      Spread `json:"f_score"`
+	TrainFScore Spread `json:"train_f_score"`
+	// Thresholds spreads the threshold tuned for each type.
+	Thresholds map[detect.PoisonType]Spread `json:"thresholds"`
+}
+
+// CrossValidate tunes d's thresholds as Tune does on k-1 folds of samples
+// at a time and scores them on the remaining fold. Folds are stratified:
+// the poisoned and clean samples are each shuffled with seed and dealt
+// round-robin, so every fold has its share of both.
+func CrossValidate(d *detect.Detector, samples []detect.Sample, poisoned []bool, k int, seed int64, opts Options) (*CrossValidation, error) {
+	if len(samples) != len(poisoned) {
+		return nil, fmt.Errorf("got %d samples but %d ground-truth labels", len(samples), len(poisoned))
+	}
+	if k < 2 {
+		return nil, fmt.Errorf("cross-validation needs at least 2 folds, got %d", k)
+	}
+	if k > len(samples) {
+		return nil, fmt.Errorf("%d folds of %d samples", k, len(samples))
+	}
+	if err := prepare(&opts); err != nil {
+		return nil, err
+	}
+
+	folds := stratify(poisoned, k, seed)
+	scores := sampleScores(d, samples)
+	types := scoredTypes(scores)
+
+	cv := &CrossValidation{Thresholds: make(map[detect.PoisonType]Spread, len(types))}
+	for held := range folds {
+		var trainScores, testScores []map[detect.PoisonType]float64
+		var trainPoisoned, testPoisoned []bool
+		for f, indices := range folds {
+			for _, i := range indices {
+				if f == held {
+					testScores = append(testScores, scores[i])
+					testPoisoned = append(testPoisoned, poisoned[i])
+				} else {
+					trainScores = append(trainScores, scores[i])
+					trainPoisoned = append(trainPoisoned, poisoned[i])
+				}
+			}
+		}
+
+		thresholds := d.Thresholds()
+		descend(trainScores, trainPoisoned, types, thresholds, opts)
+		cv.Folds = append(cv.Folds, Fold{
+			Thresholds: thresholds,
+			Train:      evaluate(trainScores, trainPoisoned, thresholds, opts.Beta),
+			Test:       evaluate(testScores, testPoisoned, thresholds, opts.Beta),
+		})
+	}
+
+	cv.Precision = spread(cv.Folds, func(f Fold) float64 { return f.Test.Precision })
+	cv.Recall = spread(cv.Folds, func(f Fold) float64 { return f.Test.Recall })
+	cv.FScore = spread(cv.Folds, func(f Fold) float64 { return f.Test.FScore })
+	cv.TrainFScore = spread(cv.Folds, func(f Fold) float64 { return f.Train.FScore })
+	for _, t := range types {
+		cv.Thresholds[t] = spread(cv.Folds, func(f Fold) float64 { return f.Thresholds[t] })
+	}
+	return cv, nil
+}
+
+// stratify deals the indices of the poisoned and clean samples, each
+// shuffled, round-robin into k folds.
+func stratify(poisoned []bool, k int, seed int64) [][]int {
+	var positive, negative []int
+	for i, p := range poisoned {
+		if p {
+			positive = append(positive, i)
+		} else {
+			negative = append(negative, i)
+		}
+	}
+	rng := rand.New(rand.NewSource(seed))
+	folds := make([][]int, k)
+	next := 0
+	for _, indices := range [][]int{positive, negative} {
+		rng.Shuffle(len(indices), func(i, j int) { indices[i], indices[j] = indices[j], indices[i] })
+		for _, i := range indices {
+			folds[next] = append(folds[next], i)
+			next = (next + 1) % k
+		}
+	}
+	return folds
+}
+
+// spread returns the mean and sample standard deviation of value over
+// folds.
+func spread(folds []Fold, value func(Fold) float64) Spread {
+	var s Spread
+	for _, f := range folds {
+		s.Mean += value(f)
+	}
+	s.Mean /= float64(len(folds))
+	if len(folds) > 1 {
+		for _, f := range folds {
+			diff := value(f) - s.Mean
+			s.StdDev += diff * diff
+		}
+		s.StdDev = math.Sqrt(s.StdDev / float64(len(folds)-1))
+	}
+	return s
+}
+
+// GenerateCrossValidationReport generates a cross-validation report.
+func GenerateCrossValidationReport(cv *CrossValidation) string {
+	var report string
+
+	report += "=== Threshold Cross-Validation ===\n\n"
+	report += fmt.Sprintf("Folds: %d\n\n", len(cv.Folds))
+
+	var types []detect.PoisonType
+	for t := range cv.Thresholds {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	report += fmt.Sprintf("%-6s", "Fold")
+	for _, t := range types {
+		report += fmt.Sprintf(" %16s", t)
+	}
+	report += fmt.Sprintf(" %9s %9s %9s %9s\n", "Train F", "Precision", "Recall", "F")
+	for i, f := range cv.Folds {
+		report += fmt.Sprintf("%-6d", i+1)
+		for _, t := range types {
+			report += fmt.Sprintf(" %16.2f", f.Thresholds[t])
+		}
+		report += fmt.Sprintf(" %9.3f %8.1f%% %8.1f%% %9.3f\n", f.Train.FScore, f.Test.Precision*100, f.Test.Recall*100, f.Test.FScore)
+	}
+
+	report += "\nHeld-out (mean ± std dev):\n"
+	report += fmt.Sprintf("  %-16s %.1f%% ± %.1f%%\n", "Precision", cv.Precision.Mean*100, cv.Precision.StdDev*100)
+	report += fmt.Sprintf("  %-16s %.1f%% ± %.1f%%\n", "Recall", cv.Recall.Mean*100, cv.Recall.StdDev*100)
+	report += fmt.Sprintf("  %-16s %.3f ± %.3f (tuning folds %.3f)\n", "F", cv.FScore.Mean, cv.FScore.StdDev, cv.TrainFScore.Mean)
+	report += "\nThresholds (mean ± std dev):\n"
+	for _, t := range types {
+		report += fmt.Sprintf("  %-16s %.2f ± %.2f\n", t, cv.Thresholds[t].Mean, cv.Thresholds[t].StdDev)
+	}
+
+	return report
+}
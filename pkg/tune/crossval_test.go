@@ -0,0 +1,123 @@
+package tune
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func TestStratify(t *testing.T) {
+	poisoned := make([]bool, 53)
+	for i := range poisoned {
+		poisoned[i] = i%5 == 0
+	}
+	positives := 11
+
+	for _, k := range []int{2, 3, 5, 10} {
+		folds := stratify(poisoned, k, 7)
+		if len(folds) != k {
+			t.Fatalf("%d folds, want %d", len(folds), k)
+		}
+		seen := make([]bool, len(poisoned))
+		for f, fold := range folds {
+			// Folds differ in size by at most one, and so do their
+			// shares of poisoned samples.
+			if n := len(fold); n < len(poisoned)/k || n > len(poisoned)/k+1 {
+				t.Errorf("k=%d: fold %d has %d samples", k, f, n)
+			}
+			p := 0
+			for _, i := range fold {
+				if seen[i] {
+					t.Fatalf("k=%d: sample %d is in two folds", k, i)
+				}
+				seen[i] = true
+				if poisoned[i] {
+					p++
+				}
+			}
+			if p < positives/k || p > positives/k+1 {
+				t.Errorf("k=%d: fold %d has %d of %d poisoned samples", k, f, p, positives)
+			}
+		}
+		for i, ok := range seen {
+			if !ok {
+				t.Errorf("k=%d: sample %d is in no fold", k, i)
+			}
+		}
+
+		if again := stratify(poisoned, k, 7); !reflect.DeepEqual(again, folds) {
+			t.Errorf("k=%d: the same seed dealt different folds", k)
+		}
+		if other := stratify(poisoned, k, 8); reflect.DeepEqual(other, folds) {
+			t.Errorf("k=%d: seeds 7 and 8 dealt the same folds", k)
+		}
+	}
+}
+
+func TestSpread(t *testing.T) {
+	folds := []Fold{{Test: Metrics{Recall: 0.5}}, {Test: Metrics{Recall: 0.7}}, {Test: Metrics{Recall: 0.9}}}
+	s := spread(folds, func(f Fold) float64 { return f.Test.Recall })
+	if math.Abs(s.Mean-0.7) > 1e-12 || math.Abs(s.StdDev-0.2) > 1e-12 {
+		t.Errorf("spread %+v, want mean 0.7 and sample standard deviation 0.2", s)
+	}
+	if s := spread(folds[:1], func(f Fold) float64 { return f.Test.Recall }); s.Mean != 0.5 || s.StdDev != 0 {
+		t.Errorf("spread of one fold %+v", s)
+	}
+}
+
+func TestCrossValidate(t *testing.T) {
+	data := labeled(t, 1)
+	d := detect.NewDetector()
+	cv, err := CrossValidate(d, data.Samples, data.Poisoned, 4, 1, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cv.Folds) != 4 {
+		t.Fatalf("%d folds, want 4", len(cv.Folds))
+	}
+
+	// Each fold's held-out and training metrics together count every
+	// sample once.
+	total := 0
+	for i, f := range cv.Folds {
+		test := f.Test.TruePositives + f.Test.FalsePositives + f.Test.FalseNegatives + f.Test.TrueNegatives
+		train := f.Train.TruePositives + f.Train.FalsePositives + f.Train.FalseNegatives + f.Train.TrueNegatives
+		if test+train != len(data.Samples) {
+			t.Errorf("fold %d scores %d held-out and %d training samples of %d", i+1, test, train, len(data.Samples))
+		}
+		total += test
+	}
+	if total != len(data.Samples) {
+		t.Errorf("the held-out folds hold %d samples, want %d", total, len(data.Samples))
+	}
+
+	mean := 0.0
+	for _, f := range cv.Folds {
+		mean += f.Test.FScore / 4
+	}
+	if math.Abs(cv.FScore.Mean-mean) > 1e-12 {
+		t.Errorf("mean held-out F %v, want %v", cv.FScore.Mean, mean)
+	}
+	if len(cv.Thresholds) != len(detect.Checks()) {
+		t.Errorf("spread thresholds of %d types, want %d", len(cv.Thresholds), len(detect.Checks()))
+	}
+
+	again, err := CrossValidate(d, data.Samples, data.Poisoned, 4, 1, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, cv) {
+		t.Error("the same seed cross-validated differently")
+	}
+
+	for _, k := range []int{1, len(data.Samples) + 1} {
+		if _, err := CrossValidate(d, data.Samples, data.Poisoned, k, 1, DefaultOptions()); err == nil {
+			t.Errorf("cross-validated with %d folds", k)
+		}
+	}
+	if _, err := CrossValidate(d, data.Samples, data.Poisoned[1:], 4, 1, DefaultOptions()); err == nil {
+		t.Error("cross-validated samples without their ground truth")
+	}
+}
@@ -0,0 +1,264 @@
+package tune
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/preprocess"
+)
+
+// Labeled is a dataset with ground truth searched against.
+type Labeled struct {
+	// Features names the features of the samples, which scalers are
+	// fitted to.
+	Features []string
+	Samples  []detect.Sample
+	Poisoned []bool
+}
+
+// Grid lists the candidate values of each parameter a search sweeps.
+type Grid struct {
+	// Thresholds lists the candidate thresholds of each check. Checks
+	// left out keep the detector's threshold.
+	Thresholds map[detect.PoisonType][]float64
+	// Scaling lists the feature scaling methods tried, each fitted to
+	// every dataset as scans fit them. Empty tries the samples unscaled.
+	Scaling []preprocess.Method
+}
+
+// DefaultGrid returns a grid of the thresholds 0.1 to 0.9 of each check and
+// every scaling method.
+func DefaultGrid() Grid {
+	grid := Grid{Thresholds: make(map[detect.PoisonType][]float64), Scaling: preprocess.Methods()}
+	for _, t := range detect.Checks() {
+		for v := 1; v <= 9; v++ {
+			grid.Thresholds[t] = append(grid.Thresholds[t], float64(v)/10)
+		}
+	}
+	return grid
+}
+
+// Size returns the number of configurations in the grid.
+func (g Grid) Size() int {
+	n := max(len(g.Scaling), 1)
+	for _, values := range g.Thresholds {
+		n *= max(len(values), 1)
+	}
+	return n
+}
+
+// SearchResult is the best configuration found by Search.
+type SearchResult struct {
+	Thresholds map[detect.PoisonType]float64 `json:"thresholds"`
+	Scaling    preprocess.Method             `json:"scaling"`
+	Metrics    Metrics                       `json:"metrics"`
+	// Baseline scores the detector's own thresholds on unscaled samples.
+	Baseline  Metrics `json:"baseline"`
+	TargetMet bool    `json:"target_met"`
+	// Evaluated is the number of configurations tried.
+	Evaluated int `json:"evaluated"`
+	// ByScaling holds the best metrics found with each scaling method.
+	ByScaling map[preprocess.Method]Metrics `json:"by_scaling"`
+}
+
+// Search tries every configuration of grid with d's checks on the labeled
+// datasets, pooling their samples, and returns the best by the objective of
+// opts. Ties keep the configuration found first, with the lower
+// thresholds and the earlier scaling method.
+func Search(d *detect.Detector, data []Labeled, grid Grid, opts Options) (*SearchResult, error) {
+	if opts.Beta <= 0 {
+		opts.Beta = 1.0
+	}
+	for i, l := range data {
+		if len(l.Samples) != len(l.Poisoned) {
+			return nil, fmt.Errorf("dataset %d: got %d samples but %d ground-truth labels", i+1, len(l.Samples), len(l.Poisoned))
+		}
+	}
+	var poisoned []bool
+	for _, l := range data {
+		poisoned = append(poisoned, l.Poisoned...)
+	}
+
+	// Types are swept in order; each has its candidates, or the
+	// detector's threshold.
+	current := d.Thresholds()
+	types := detect.Checks()
+	candidates := make([][]float64, len(types))
+	for k, t := range types {
+		candidates[k] = append([]float64(nil), grid.Thresholds[t]...)
+		for _, v := range candidates[k] {
+			if v < 0 || v > 1 || math.IsNaN(v) {
+				return nil, fmt.Errorf("threshold %v of %s is not between 0 and 1", v, t)
+			}
+		}
+		sort.Float64s(candidates[k])
+		if len(candidates[k]) == 0 {
+			candidates[k] = []float64{current[t]}
+		}
+	}
+	methods := grid.Scaling
+	if len(methods) == 0 {
+		methods = []preprocess.Method{preprocess.None}
+	}
+
+	unscaled, err := searchScores(d, data, types, preprocess.None)
+	if err != nil {
+		return nil, err
+	}
+	baseline := make([]float64, len(types))
+	for k, t := range types {
+		baseline[k] = current[t]
+	}
+	result := &SearchResult{
+		Baseline:  countFlags(unscaled, poisoned, baseline, opts.Beta),
+		ByScaling: make(map[preprocess.Method]Metrics),
+	}
+
+	best := math.Inf(-1)
+	for _, method := range methods {
+		scores := unscaled
+		if method != preprocess.None {
+			if scores, err = searchScores(d, data, types, method); err != nil {
+				return nil, err
+			}
+		}
+
+		methodBest := math.Inf(-1)
+		index := make([]int, len(types))
+		thresholds := make([]float64, len(types))
+		for {
+			for k := range types {
+				thresholds[k] = candidates[k][index[k]]
+			}
+			m := countFlags(scores, poisoned, thresholds, opts.Beta)
+			result.Evaluated++
+			score := objective(m, opts)
+			if score > methodBest+1e-12 {
+				methodBest = score
+				result.ByScaling[method] = m
+			}
+			if score > best+1e-12 {
+				best = score
+				result.Metrics = m
+				result.Scaling = method
+				result.Thresholds = make(map[detect.PoisonType]float64, len(types))
+				for k, t := range types {
+					result.Thresholds[t] = thresholds[k]
+				}
+			}
+
+			// Advance the odometer of candidate indices.
+			k := len(index) - 1
+			for ; k >= 0; k-- {
+				if index[k]++; index[k] < len(candidates[k]) {
+					break
+				}
+				index[k] = 0
+			}
+			if k < 0 {
+				break
+			}
+		}
+	}
+	result.TargetMet = opts.TargetPrecision <= 0 || result.Metrics.Precision >= opts.TargetPrecision
+
+	return result, nil
+}
+
+// searchScores scores the samples of data, scaled by method, with the
+// checks of types.
+func searchScores(d *detect.Detector, data []Labeled, types []detect.PoisonType, method preprocess.Method) ([][]float64, error) {
+	var scores [][]float64
+	for i, l := range data {
+		samples := l.Samples
+		if method != preprocess.None {
+			scaler, err := preprocess.FitSamples(preprocess.Config{Method: method}, l.Features, samples)
+			if err != nil {
+				return nil, fmt.Errorf("dataset %d: %w", i+1, err)
+			}
+			samples = scaler.Transform(samples)
+		}
+		for _, sample := range samples {
+			sampleScores := d.ScoreSample(sample)
+			row := make([]float64, len(types))
+			for k, t := range types {
+				row[k] = sampleScores[t]
+			}
+			scores = append(scores, row)
+		}
+	}
+	return scores, nil
+}
+
+// countFlags computes metrics for per-sample check scores flagged above
+// thresholds, both in the order of the checks.
+func countFlags(scores [][]float64, poisoned []bool, thresholds []float64, beta float64) Metrics {
+	var m Metrics
+	for i, row := range scores {
+		flagged := false
+		for k, score := range row {
+			if score > thresholds[k] {
+				flagged = true
+				break
+			}
+		}
+
+		switch {
+		case flagged && poisoned[i]:
+			m.TruePositives++
+		case flagged:
+			m.FalsePositives++
+		case poisoned[i]:
+			m.FalseNegatives++
+		default:
+			m.TrueNegatives++
+		}
+	}
+
+	return finish(m, beta)
+}
+
+// GenerateSearchReport generates a grid search report.
+func GenerateSearchReport(result *SearchResult) string {
+	var report string
+
+	report += "=== Grid Search Report ===\n\n"
+	report += fmt.Sprintf("Configurations: %d\n", result.Evaluated)
+	report += fmt.Sprintf("Scaling: %s\n\n", result.Scaling)
+
+	var types []detect.PoisonType
+	for t := range result.Thresholds {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	report += "Thresholds:\n"
+	for _, t := range types {
+		report += fmt.Sprintf("  %-16s %.2f\n", t, result.Thresholds[t])
+	}
+	report += "\n"
+
+	report += fmt.Sprintf("%-10s %9s %9s %9s %5s %5s %5s\n", "", "Precision", "Recall", "F", "TP", "FP", "FN")
+	type row struct {
+		name string
+		m    Metrics
+	}
+	rows := []row{{"Baseline", result.Baseline}, {"Best", result.Metrics}}
+	for _, method := range preprocess.Methods() {
+		if m, ok := result.ByScaling[method]; ok {
+			rows = append(rows, row{"  " + string(method), m})
+		}
+	}
+	for _, row := range rows {
+		report += fmt.Sprintf("%-10s %8.1f%% %8.1f%% %9.3f %5d %5d %5d\n", row.name,
+			row.m.Precision*100, row.m.Recall*100, row.m.FScore, row.m.TruePositives, row.m.FalsePositives, row.m.FalseNegatives)
+	}
+
+	if !result.TargetMet {
+		report += "\nWarning: target precision was not reached\n"
+	}
+
+	return report
+}
@@ -0,0 +1,149 @@
+package tune
+
+import (
+	"math"
+	"testing"
+
+	"github.com/hallucinaut/modelpoison/pkg/attack"
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+	"github.com/hallucinaut/modelpoison/pkg/preprocess"
+	"github.com/hallucinaut/modelpoison/pkg/synthetic"
+)
+
+// labeled returns a small dataset with backdoored samples and their
+// ground truth.
+func labeled(t *testing.T, seed int64) Labeled {
+	t.Helper()
+	poison := attack.DefaultConfig()
+	poison.Rate = 0.1
+	poison.Seed = seed
+	cfg := synthetic.DefaultConfig()
+	cfg.Samples = 300
+	cfg.Features = 8
+	cfg.Seed = seed
+	cfg.Poison = &poison
+	data, err := synthetic.Generate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Labeled{Features: data.Features, Samples: data.Samples, Poisoned: data.Poisoned}
+}
+
+// fScore returns the F1 score of flagging the samples a check scores above
+// its threshold, computed without the package's helpers.
+func fScore(scores []map[detect.PoisonType]float64, poisoned []bool, thresholds map[detect.PoisonType]float64) float64 {
+	tp, fp, fn := 0, 0, 0
+	for i, sampleScores := range scores {
+		flagged := false
+		for t, score := range sampleScores {
+			flagged = flagged || score > thresholds[t]
+		}
+		switch {
+		case flagged && poisoned[i]:
+			tp++
+		case flagged:
+			fp++
+		case poisoned[i]:
+			fn++
+		}
+	}
+	if tp == 0 {
+		return 0
+	}
+	return 2 * float64(tp) / float64(2*tp+fp+fn)
+}
+
+func TestSearch(t *testing.T) {
+	d := detect.NewDetector()
+	data := labeled(t, 1)
+	values := []float64{0.1, 0.3, 0.5, 0.7, 0.9}
+	grid := Grid{Thresholds: map[detect.PoisonType][]float64{
+		detect.TypeBackdoor:      values,
+		detect.TypeFeaturePoison: values,
+	}}
+	result, err := Search(d, []Labeled{data}, grid, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Evaluated != grid.Size() || grid.Size() != 25 {
+		t.Errorf("evaluated %d configurations of a grid of %d", result.Evaluated, grid.Size())
+	}
+	if result.Scaling != preprocess.None || !result.TargetMet {
+		t.Errorf("scaling %q, target met %v", result.Scaling, result.TargetMet)
+	}
+
+	// No configuration of the grid scores higher than the one chosen.
+	scores := make([]map[detect.PoisonType]float64, len(data.Samples))
+	for i, sample := range data.Samples {
+		scores[i] = d.ScoreSample(sample)
+	}
+	best := math.Inf(-1)
+	for _, backdoor := range values {
+		for _, feature := range values {
+			thresholds := d.Thresholds()
+			thresholds[detect.TypeBackdoor] = backdoor
+			thresholds[detect.TypeFeaturePoison] = feature
+			best = math.Max(best, fScore(scores, data.Poisoned, thresholds))
+		}
+	}
+	if best <= 0 {
+		t.Fatal("no configuration flags the backdoor")
+	}
+	if got := fScore(scores, data.Poisoned, result.Thresholds); math.Abs(got-best) > 1e-12 || math.Abs(result.Metrics.FScore-best) > 1e-12 {
+		t.Errorf("chose %v scoring F %v (reported %v), want the best F %v", result.Thresholds, got, result.Metrics.FScore, best)
+	}
+	if got := fScore(scores, data.Poisoned, d.Thresholds()); math.Abs(result.Baseline.FScore-got) > 1e-12 {
+		t.Errorf("baseline F %v, want %v", result.Baseline.FScore, got)
+	}
+	for _, typ := range []detect.PoisonType{detect.TypeLabelFlip, detect.TypeGradientPoison} {
+		if result.Thresholds[typ] != d.Thresholds()[typ] {
+			t.Errorf("%s threshold %v, want the detector's %v", typ, result.Thresholds[typ], d.Thresholds()[typ])
+		}
+	}
+}
+
+func TestSearchScaling(t *testing.T) {
+	grid := Grid{
+		Thresholds: map[detect.PoisonType][]float64{detect.TypeBackdoor: {0.2, 0.5, 0.8}},
+		Scaling:    []preprocess.Method{preprocess.None, preprocess.ZScore},
+	}
+	result, err := Search(detect.NewDetector(), []Labeled{labeled(t, 1), labeled(t, 2)}, grid, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Evaluated != 6 || len(result.ByScaling) != 2 {
+		t.Fatalf("evaluated %d configurations, best by scaling %v", result.Evaluated, result.ByScaling)
+	}
+	for method, m := range result.ByScaling {
+		if m.FScore > result.Metrics.FScore+1e-12 {
+			t.Errorf("%s scores F %v, above the best %v", method, m.FScore, result.Metrics.FScore)
+		}
+	}
+	if result.ByScaling[result.Scaling] != result.Metrics {
+		t.Errorf("best metrics %+v, but %s scored %+v", result.Metrics, result.Scaling, result.ByScaling[result.Scaling])
+	}
+}
+
+func TestSearchTargetPrecision(t *testing.T) {
+	grid := Grid{Thresholds: map[detect.PoisonType][]float64{detect.TypeBackdoor: {0.1, 0.5, 0.9}}}
+	result, err := Search(detect.NewDetector(), []Labeled{labeled(t, 1)}, grid, Options{TargetPrecision: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TargetMet {
+		t.Errorf("met an unreachable precision target: %+v", result.Metrics)
+	}
+}
+
+func TestSearchErrors(t *testing.T) {
+	data := labeled(t, 1)
+	short := data
+	short.Poisoned = short.Poisoned[1:]
+	if _, err := Search(detect.NewDetector(), []Labeled{short}, Grid{}, Options{}); err == nil {
+		t.Error("searched samples without their ground truth")
+	}
+	bad := Grid{Thresholds: map[detect.PoisonType][]float64{detect.TypeBackdoor: {1.5}}}
+	if _, err := Search(detect.NewDetector(), []Labeled{data}, bad, Options{}); err == nil {
+		t.Error("searched a threshold above 1")
+	}
+}
@@ -0,0 +1,227 @@
+// Package tune optimizes detector thresholds against labeled data.
+package tune
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Options controls the threshold sweep.
+type Options struct {
+	// Beta weights recall against precision in the F-beta objective.
+	Beta float64
+	// TargetPrecision, when positive, maximizes recall subject to reaching
+	// this precision instead of maximizing F-beta.
+	TargetPrecision float64
+	// Step is the threshold grid spacing.
+	Step float64
+	// MaxRounds limits the coordinate-descent passes over all types.
+	MaxRounds int
+}
+
+// DefaultOptions returns the default sweep options.
+func DefaultOptions() Options {
+	return Options{
+		Beta:      1.0,
+		Step:      0.05,
+		MaxRounds: 10,
+	}
+}
+
+// Metrics contains confusion counts and derived metrics.
+type Metrics struct {
+	TruePositives  int     `json:"true_positives"`
+	FalsePositives int     `json:"false_positives"`
+	FalseNegatives int     `json:"false_negatives"`
+	TrueNegatives  int     `json:"true_negatives"`
+	Precision      float64 `json:"precision"`
+	Recall         float64 `json:"recall"`
+	FScore         float64 `json:"f_score"`
+}
+
+// Result contains the optimized thresholds.
+type Result struct {
+	Thresholds map[detect.PoisonType]float64 `json:"thresholds"`
+	Metrics    Metrics                       `json:"metrics"`
+	Baseline   Metrics                       `json:"baseline"`
+	TargetMet  bool                          `json:"target_met"`
+	Rounds     int                           `json:"rounds"`
+}
+
+// Tune sweeps the thresholds of d's checks against samples whose ground
+// truth is given by poisoned, starting from d's current thresholds. It
+// uses coordinate descent: each round tries every grid value for each type
+// while holding the others fixed, until no change improves the objective.
+func Tune(d *detect.Detector, samples []detect.Sample, poisoned []bool, opts Options) (*Result, error) {
+	if len(samples) != len(poisoned) {
+		return nil, fmt.Errorf("got %d samples but %d ground-truth labels", len(samples), len(poisoned))
+	}
+	if err := prepare(&opts); err != nil {
+		return nil, err
+	}
+
+	scores := sampleScores(d, samples)
+	types := scoredTypes(scores)
+	thresholds := d.Thresholds()
+	result := &Result{Baseline: evaluate(scores, poisoned, thresholds, opts.Beta)}
+	result.Rounds = descend(scores, poisoned, types, thresholds, opts)
+	result.Thresholds = thresholds
+	result.Metrics = evaluate(scores, poisoned, thresholds, opts.Beta)
+	result.TargetMet = opts.TargetPrecision <= 0 || result.Metrics.Precision >= opts.TargetPrecision
+
+	return result, nil
+}
+
+// prepare checks the sweep options of opts and fills in their defaults.
+func prepare(opts *Options) error {
+	if opts.Step <= 0 || opts.Step >= 1 {
+		return fmt.Errorf("step must be between 0 and 1, got %v", opts.Step)
+	}
+	if opts.Beta <= 0 {
+		opts.Beta = 1.0
+	}
+	if opts.MaxRounds <= 0 {
+		opts.MaxRounds = 1
+	}
+	return nil
+}
+
+// sampleScores scores each sample with every check of d.
+func sampleScores(d *detect.Detector, samples []detect.Sample) []map[detect.PoisonType]float64 {
+	scores := make([]map[detect.PoisonType]float64, len(samples))
+	for i, sample := range samples {
+		scores[i] = d.ScoreSample(sample)
+	}
+	return scores
+}
+
+// scoredTypes returns the types scored, sorted. Only types with a check
+// are tuned.
+func scoredTypes(scores []map[detect.PoisonType]float64) []detect.PoisonType {
+	var types []detect.PoisonType
+	if len(scores) > 0 {
+		for t := range scores[0] {
+			types = append(types, t)
+		}
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+	return types
+}
+
+// descend tunes thresholds of types in place by coordinate descent and
+// returns the number of rounds run.
+func descend(scores []map[detect.PoisonType]float64, poisoned []bool, types []detect.PoisonType, thresholds map[detect.PoisonType]float64, opts Options) int {
+	best := objective(evaluate(scores, poisoned, thresholds, opts.Beta), opts)
+
+	rounds := 0
+	for round := 1; round <= opts.MaxRounds; round++ {
+		rounds = round
+		improved := false
+
+		for _, t := range types {
+			current := thresholds[t]
+			for v := opts.Step; v < 1.0; v += opts.Step {
+				candidate := math.Round(v*1000) / 1000
+				thresholds[t] = candidate
+				score := objective(evaluate(scores, poisoned, thresholds, opts.Beta), opts)
+				if score > best+1e-12 {
+					best, current, improved = score, candidate, true
+				}
+			}
+			thresholds[t] = current
+		}
+
+		if !improved {
+			break
+		}
+	}
+	return rounds
+}
+
+// objective scores metrics according to the options. With a target
+// precision, configurations meeting it always beat ones that do not.
+func objective(m Metrics, opts Options) float64 {
+	if opts.TargetPrecision <= 0 {
+		return m.FScore
+	}
+	if m.Precision >= opts.TargetPrecision {
+		return 1.0 + m.Recall
+	}
+	return m.Precision
+}
+
+// evaluate computes metrics for a threshold configuration.
+func evaluate(scores []map[detect.PoisonType]float64, poisoned []bool, thresholds map[detect.PoisonType]float64, beta float64) Metrics {
+	var m Metrics
+	for i, sampleScores := range scores {
+		flagged := false
+		for t, score := range sampleScores {
+			if score > thresholds[t] {
+				flagged = true
+				break
+			}
+		}
+
+		switch {
+		case flagged && poisoned[i]:
+			m.TruePositives++
+		case flagged:
+			m.FalsePositives++
+		case poisoned[i]:
+			m.FalseNegatives++
+		default:
+			m.TrueNegatives++
+		}
+	}
+
+	return finish(m, beta)
+}
+
+// finish derives precision, recall and F-beta from confusion counts.
+func finish(m Metrics, beta float64) Metrics {
+	if tp := m.TruePositives; tp > 0 {
+		m.Precision = float64(tp) / float64(tp+m.FalsePositives)
+		m.Recall = float64(tp) / float64(tp+m.FalseNegatives)
+		b2 := beta * beta
+		m.FScore = (1 + b2) * m.Precision * m.Recall / (b2*m.Precision + m.Recall)
+	}
+	return m
+}
+
+// GenerateReport generates a tuning report.
+func GenerateReport(result *Result) string {
+	var report string
+
+	report += "=== Threshold Tuning Report ===\n\n"
+	report += fmt.Sprintf("Rounds: %d\n\n", result.Rounds)
+
+	var types []detect.PoisonType
+	for t := range result.Thresholds {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+
+	report += "Thresholds:\n"
+	for _, t := range types {
+		report += fmt.Sprintf("  %-16s %.2f\n", t, result.Thresholds[t])
+	}
+	report += "\n"
+
+	report += fmt.Sprintf("%-10s %9s %9s %9s %5s %5s %5s\n", "", "Precision", "Recall", "F", "TP", "FP", "FN")
+	for _, row := range []struct {
+		name string
+		m    Metrics
+	}{{"Baseline", result.Baseline}, {"Tuned", result.Metrics}} {
+		report += fmt.Sprintf("%-10s %8.1f%% %8.1f%% %9.3f %5d %5d %5d\n", row.name,
+			row.m.Precision*100, row.m.Recall*100, row.m.FScore, row.m.TruePositives, row.m.FalsePositives, row.m.FalseNegatives)
+	}
+
+	if !result.TargetMet {
+		report += "\nWarning: target precision was not reached\n"
+	}
+
+	return report
+}
@@ -0,0 +1,318 @@
+// Package webhook notifies external services when scans complete.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+// Events.
+const (
+	// EventScanCompleted fires after every scan.
+	EventScanCompleted = "scan.completed"
+	// EventRiskExceeded fires when the risk score exceeds the hook's
+	// risk threshold.
+	EventRiskExceeded = "risk.exceeded"
+)
+
+// Headers set on deliveries.
+const (
+	HeaderEvent     = "X-Modelpoison-Event"
+	HeaderSignature = "X-Modelpoison-Signature"
+)
+
+// DefaultRiskThreshold is the risk threshold of hooks that do not set one.
+const DefaultRiskThreshold = 0.5
+
+// Hook is a configured webhook endpoint.
+type Hook struct {
+	URL string `yaml:"url"`
+	// Secret signs payloads. A value of the form $NAME or ${NAME} is read
+	// from the environment.
+	Secret string `yaml:"secret,omitempty"`
+	// Events lists the events delivered to the hook (default
+	// scan.completed).
+	Events []string `yaml:"events,omitempty"`
+	// RiskThreshold is the risk score above which risk.exceeded fires
+	// (default 0.5).
+	RiskThreshold float64 `yaml:"risk_threshold,omitempty"`
+}
+
+// Check reports errors in the hook configuration.
+func (h Hook) Check() error {
+	u, err := url.Parse(h.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q", h.URL)
+	}
+	for _, event := range h.Events {
+		if event != EventScanCompleted && event != EventRiskExceeded {
+			return fmt.Errorf("%s: unknown event %q (want %s or %s)", h.URL, event, EventScanCompleted, EventRiskExceeded)
+		}
+	}
+	if h.RiskThreshold < 0 || h.RiskThreshold > 1 {
+		return fmt.Errorf("%s: risk_threshold must be between 0 and 1", h.URL)
+	}
+	return nil
+}
+
+// events returns the events that fire for result.
+func (h Hook) events(result *detect.DetectionResult) []string {
+	subscribed := h.Events
+	if len(subscribed) == 0 {
+		subscribed = []string{EventScanCompleted}
+	}
+
+	var events []string
+	for _, event := range subscribed {
+		switch event {
+		case EventScanCompleted:
+			events = append(events, event)
+		case EventRiskExceeded:
+			if result.RiskScore > h.riskThreshold() {
+				events = append(events, event)
+			}
+		}
+	}
+	return events
+}
+
+// riskThreshold returns the hook's risk threshold.
+func (h Hook) riskThreshold() float64 {
+	if h.RiskThreshold > 0 {
+		return h.RiskThreshold
+	}
+	return DefaultRiskThreshold
+}
+
+// secret returns the signing secret, expanding environment references.
+func (h Hook) secret() string {
+	if strings.HasPrefix(h.Secret, "$") {
+		return os.ExpandEnv(h.Secret)
+	}
+	return h.Secret
+}
+
+// Scan describes a completed scan.
+type Scan struct {
+	// ID identifies the scan, if it has an ID.
+	ID string
+	// Project names the server project of the scan, if any.
+	Project string
+	// Dataset names the scanned dataset.
+	Dataset string
+	// ResultURL links to the full result.
+	ResultURL string
+	// ReportURL links to the rendered report, if any.
+	ReportURL string
+	Result    *detect.DetectionResult
+}
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	Event     string    `json:"event"`
+	Timestamp time.Time `json:"timestamp"`
+	ScanID    string    `json:"scan_id,omitempty"`
+	Project   string    `json:"project,omitempty"`
+	Dataset   string    `json:"dataset"`
+	Summary   Summary   `json:"summary"`
+	// RiskThreshold is the threshold exceeded by a risk.exceeded event.
+	RiskThreshold float64 `json:"risk_threshold,omitempty"`
+	ResultURL     string  `json:"result_url,omitempty"`
+	ReportURL     string  `json:"report_url,omitempty"`
+}
+
+// Summary summarizes a detection result.
+type Summary struct {
+	IsPoisoned    bool           `json:"is_poisoned"`
+	SampleCount   int            `json:"sample_count"`
+	PoisonedCount int            `json:"poisoned_count"`
+	RiskScore     float64        `json:"risk_score"`
+	Types         map[string]int `json:"types"`
+	Partial       bool           `json:"partial,omitempty"`
+}
+
+// Summarize summarizes a detection result.
+func Summarize(result *detect.DetectionResult) Summary {
+	summary := Summary{
+		IsPoisoned:    result.IsPoisoned,
+		SampleCount:   result.SampleCount,
+		PoisonedCount: result.PoisonedCount,
+		RiskScore:     result.RiskScore,
+		Types:         make(map[string]int),
+		Partial:       result.Partial != nil,
+	}
+	for _, sample := range result.Samples {
+		if sample.IsPoisoned {
+			summary.Types[string(sample.Type)]++
+		}
+	}
+	return summary
+}
+
+// Sign returns the signature header value for body sent at timestamp:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<unix seconds>.<body>">".
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + t + ",v1=" + signature(secret, t, body)
+}
+
+// Verify checks a signature header against body. Signatures older than
+// tolerance are rejected to prevent replays; a zero tolerance disables the
+// check.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var t, v1 string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			v1 = value
+		}
+	}
+	if t == "" || v1 == "" {
+		return errors.New("malformed signature header")
+	}
+
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil {
+		return errors.New("malformed signature timestamp")
+	}
+	if tolerance > 0 && time.Since(time.Unix(unix, 0)) > tolerance {
+		return errors.New("signature has expired")
+	}
+	if !hmac.Equal([]byte(v1), []byte(signature(secret, t, body))) {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+// signature computes the hex HMAC-SHA256 of "<t>.<body>".
+func signature(secret, t string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Notifier delivers scan events to hooks.
+type Notifier struct {
+	hooks  []Hook
+	client *http.Client
+
+	// Attempts is the number of delivery attempts per event (default 3).
+	Attempts int
+	// Backoff is the delay before the first retry; it doubles on each
+	// further retry (default 1s).
+	Backoff time.Duration
+}
+
+// New creates a notifier for hooks. A nil client uses a client with a 10s
+// timeout.
+func New(hooks []Hook, client *http.Client) *Notifier {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Notifier{hooks: hooks, client: client, Attempts: 3, Backoff: time.Second}
+}
+
+// Notify delivers the events fired by scan to every hook. It returns the
+// delivery errors, joined.
+func (n *Notifier) Notify(ctx context.Context, scan Scan) error {
+	if n == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, hook := range n.hooks {
+		for _, event := range hook.events(scan.Result) {
+			payload := Payload{
+				Event:     event,
+				Timestamp: time.Now().UTC(),
+				ScanID:    scan.ID,
+				Project:   scan.Project,
+				Dataset:   scan.Dataset,
+				Summary:   Summarize(scan.Result),
+				ResultURL: scan.ResultURL,
+				ReportURL: scan.ReportURL,
+			}
+			if event == EventRiskExceeded {
+				payload.RiskThreshold = hook.riskThreshold()
+			}
+			if err := n.deliver(ctx, hook, payload); err != nil {
+				errs = append(errs, fmt.Errorf("webhook %s (%s): %w", hook.URL, event, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver posts a payload to a hook, retrying network errors, 429s and
+// server errors.
+func (n *Notifier) deliver(ctx context.Context, hook Hook, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	attempts := n.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := n.Backoff
+
+	for attempt := 1; ; attempt++ {
+		retry, err := n.post(ctx, hook, payload, body)
+		if err == nil || !retry || attempt == attempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post makes one delivery attempt, reporting whether a failure is worth
+// retrying.
+func (n *Notifier) post(ctx context.Context, hook Hook, payload Payload, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, payload.Event)
+	if secret := hook.secret(); secret != "" {
+		req.Header.Set(HeaderSignature, Sign(secret, payload.Timestamp, body))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return false, nil
+}
@@ -0,0 +1,80 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hallucinaut/modelpoison/pkg/detect"
+)
+
+func TestNotifySignsPayloads(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify("s3cret", r.Header.Get(HeaderSignature), body, time.Minute); err != nil {
+			t.Errorf("Verify: %v", err)
+		}
+		var payload Payload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("payload: %v", err)
+		}
+		if payload.Event != r.Header.Get(HeaderEvent) {
+			t.Errorf("event %q, header %q", payload.Event, r.Header.Get(HeaderEvent))
+		}
+		if payload.Summary.Types["backdoor"] != 1 || payload.ResultURL != "https://example.com/r" {
+			t.Errorf("payload = %+v", payload)
+		}
+		mu.Lock()
+		events = append(events, payload.Event)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	result := &detect.DetectionResult{
+		SampleCount:   2,
+		PoisonedCount: 1,
+		RiskScore:     0.4,
+		Samples: []detect.PoisonedSample{
+			{ID: "a", IsPoisoned: true, Type: detect.TypeBackdoor},
+			{ID: "b"},
+		},
+	}
+	hooks := []Hook{
+		{URL: server.URL, Secret: "s3cret", Events: []string{EventScanCompleted, EventRiskExceeded}, RiskThreshold: 0.3},
+		{URL: server.URL, Secret: "s3cret", Events: []string{EventRiskExceeded}},
+	}
+
+	scan := Scan{Dataset: "d.csv", ResultURL: "https://example.com/r", Result: result}
+	if err := New(hooks, nil).Notify(context.Background(), scan); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0] != EventScanCompleted || events[1] != EventRiskExceeded {
+		t.Errorf("events = %v, want scan.completed and one risk.exceeded", events)
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	body := []byte(`{"event":"scan.completed"}`)
+	header := Sign("key", time.Now(), body)
+
+	if err := Verify("key", header, body, time.Minute); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if err := Verify("other", header, body, time.Minute); err == nil {
+		t.Error("wrong secret verified")
+	}
+	if err := Verify("key", header, []byte(`{}`), time.Minute); err == nil {
+		t.Error("tampered body verified")
+	}
+	old := Sign("key", time.Now().Add(-time.Hour), body)
+	if err := Verify("key", old, body, time.Minute); err == nil {
+		t.Error("expired signature verified")
+	}
+}